@@ -0,0 +1,67 @@
+// Command shapexml-gen writes reflection-free XML codecs for the structs
+// of a Go package annotated with //shapexml:codec:
+//
+//	//go:generate go run github.com/shapestone/shape-xml/cmd/shapexml-gen
+//
+//	//shapexml:codec
+//	type Person struct {
+//	    ID   string `xml:"id,attr"`
+//	    Name string `xml:"name"`
+//	}
+//
+// The generated file, shapexml_codec.go by default, gives each annotated
+// struct AppendXMLElement, MarshalXML and UnmarshalXML methods that
+// xml.Marshal and xml.Unmarshal use instead of reflection. They produce and
+// accept the same XML as the reflection path with default options, for the
+// struct tags and field types the generator supports; it reports anything
+// else as an error.
+//
+// Usage:
+//
+//	shapexml-gen [-o file] [dir]
+//
+// dir defaults to the current directory.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/shapestone/shape-xml/internal/codegen"
+)
+
+func main() {
+	output := flag.String("o", codegen.DefaultOutput, "name of the generated file in the package directory")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: shapexml-gen [-o file] [dir]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	} else if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+
+	if err := run(dir, *output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run generates the codecs for the package in dir into output.
+func run(dir, output string) error {
+	src, err := codegen.Generate(dir, output)
+	if err != nil {
+		return err
+	}
+	if src == nil {
+		return fmt.Errorf("shapexml-gen: %s: no struct is annotated with %s", dir, codegen.Annotation)
+	}
+	return os.WriteFile(filepath.Join(dir, output), src, 0o644)
+}
@@ -0,0 +1,259 @@
+package charset
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/shapestone/shape-xml/internal/xmlerr"
+)
+
+// Mode selects what happens to characters that XML does not allow.
+type Mode int
+
+const (
+	// Reject makes them an error.
+	Reject Mode = iota
+	// Strip removes them.
+	Strip
+	// Replace replaces each of them with U+FFFD.
+	Replace
+)
+
+// IsChar reports whether r matches the Char production of XML 1.0: tab,
+// line feed, carriage return, and every character from U+0020 on except
+// the surrogates, U+FFFE and U+FFFF.
+func IsChar(r rune) bool {
+	switch {
+	case r < 0x20:
+		return r == '\t' || r == '\n' || r == '\r'
+	case r < 0xD800:
+		return true
+	case r < 0xE000:
+		return false
+	case r < 0xFFFE:
+		return true
+	}
+	return r > 0xFFFF && r <= utf8.MaxRune
+}
+
+// CharRef parses a character reference such as "#60" or "#x3C", given
+// without its '&' and ';'. It reports false if ref is not one; the
+// character it stands for may still be one XML does not allow, see IsChar.
+func CharRef(ref string) (rune, bool) {
+	if len(ref) < 2 || ref[0] != '#' {
+		return 0, false
+	}
+	var n uint64
+	var err error
+	if ref[1] == 'x' {
+		n, err = strconv.ParseUint(ref[2:], 16, 32)
+	} else {
+		n, err = strconv.ParseUint(ref[1:], 10, 32)
+	}
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			// Too large for any character.
+			return utf8.MaxRune + 1, true
+		}
+		return 0, false
+	}
+	return rune(n), true
+}
+
+// InvalidRefError returns the error for the character reference &ref; to
+// r, which XML does not allow.
+func InvalidRefError(ref string, r rune) error {
+	return fmt.Errorf("reference &%s; to illegal character %U", ref, r)
+}
+
+// BadUTF8 is the character IndexInvalid reports for a byte that is not
+// part of a valid UTF-8 sequence.
+const BadUTF8 rune = -1
+
+// InvalidError returns the error for the character r, which XML does not
+// allow, or for a byte that is not UTF-8 if r is BadUTF8.
+func InvalidError(r rune) error {
+	if r == BadUTF8 {
+		return errors.New("invalid UTF-8")
+	}
+	return fmt.Errorf("illegal character %U", r)
+}
+
+// IndexInvalid returns the byte offset in data of the first character XML
+// does not allow, and the character, or -1 if there is none. In UTF-8
+// these are the control characters, U+FFFE and U+FFFF, and bytes that are
+// not UTF-8 at all, which are reported as BadUTF8; surrogates are among
+// the latter.
+func IndexInvalid(data []byte) (int, rune) {
+	for i := 0; i < len(data); {
+		c := data[i]
+		if c < utf8.RuneSelf {
+			if c < 0x20 && c != '\t' && c != '\n' && c != '\r' {
+				return i, rune(c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			return i, BadUTF8
+		case r == 0xFFFE || r == 0xFFFF:
+			return i, r
+		}
+		i += size
+	}
+	return -1, 0
+}
+
+// IndexInvalidString is IndexInvalid for a string.
+func IndexInvalidString(s string) (int, rune) {
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c < 0x20 && c != '\t' && c != '\n' && c != '\r' {
+				return i, rune(c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			return i, BadUTF8
+		case r == 0xFFFE || r == 0xFFFF:
+			return i, r
+		}
+		i += size
+	}
+	return -1, 0
+}
+
+// Clean returns data with the characters XML does not allow handled as
+// mode says. It returns data itself if there are none, or for Reject.
+func Clean(data []byte, mode Mode) []byte {
+	if mode == Reject {
+		return data
+	}
+	if i, _ := IndexInvalid(data); i < 0 {
+		return data
+	}
+	return appendClean(make([]byte, 0, len(data)), data, mode)
+}
+
+// CleanString is Clean for a string.
+func CleanString(s string, mode Mode) string {
+	if mode == Reject {
+		return s
+	}
+	if i, _ := IndexInvalidString(s); i < 0 {
+		return s
+	}
+	return string(appendClean(make([]byte, 0, len(s)), []byte(s), mode))
+}
+
+// appendClean appends data to buf, with the characters XML does not allow
+// stripped or replaced.
+func appendClean(buf, data []byte, mode Mode) []byte {
+	for {
+		i, r := IndexInvalid(data)
+		if i < 0 {
+			return append(buf, data...)
+		}
+		buf = append(buf, data[:i]...)
+		if mode == Replace {
+			buf = utf8.AppendRune(buf, utf8.RuneError)
+		}
+		size := 1
+		if r != BadUTF8 {
+			size = utf8.RuneLen(r)
+		}
+		data = data[i+size:]
+	}
+}
+
+// charReader checks the characters of a UTF-8 document read from src, as
+// mode says. In Reject mode it stops at the first character XML does not
+// allow with an *xmlerr.SyntaxError locating it.
+type charReader struct {
+	src  io.Reader
+	mode Mode
+	// in holds bytes read from src that may start a character not read
+	// completely, out those checked and not yet returned.
+	in, out []byte
+	// offset, line and lineStart locate the start of in.
+	offset, lineStart int
+	line              int
+	err               error
+	// written counts the bytes checked so far. changed is set once a
+	// character has been stripped or replaced, at offset changedAt of
+	// the output.
+	written   int64
+	changed   bool
+	changedAt int64
+}
+
+func (r *charReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 && r.err == nil {
+		r.fill()
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	if n > 0 {
+		return n, nil
+	}
+	return 0, r.err
+}
+
+// fill reads from src and checks what it read into out.
+func (r *charReader) fill() {
+	var buf [4096]byte
+	n, err := r.src.Read(buf[:])
+	r.in = append(r.in, buf[:n]...)
+	end := len(r.in)
+	if err == nil {
+		// Leave the start of a character not read completely for the
+		// next read.
+		for i := 1; i < utf8.UTFMax && i <= end; i++ {
+			if utf8.RuneStart(r.in[end-i]) {
+				if !utf8.FullRune(r.in[end-i : end]) {
+					end -= i
+				}
+				break
+			}
+		}
+	}
+	r.out = r.out[:0]
+	if r.mode != Reject {
+		if i, _ := IndexInvalid(r.in[:end]); i >= 0 && !r.changed {
+			r.changed, r.changedAt = true, r.written+int64(i)
+		}
+		r.out = appendClean(r.out, r.in[:end], r.mode)
+		r.written += int64(len(r.out))
+	} else if i, c := IndexInvalid(r.in[:end]); i >= 0 {
+		r.out = append(r.out, r.in[:i]...)
+		r.advance(r.in[:i])
+		r.err = &xmlerr.SyntaxError{Err: InvalidError(c), Offset: r.offset, Line: r.line + 1, Column: r.offset - r.lineStart + 1}
+		return
+	} else {
+		r.out = append(r.out, r.in[:end]...)
+		r.advance(r.in[:end])
+	}
+	r.in = append(r.in[:0], r.in[end:]...)
+	if err != nil {
+		r.err = err
+	}
+}
+
+// advance moves the position over b.
+func (r *charReader) advance(b []byte) {
+	if i := bytes.LastIndexByte(b, '\n'); i >= 0 {
+		r.line += bytes.Count(b, []byte{'\n'})
+		r.lineStart = r.offset + i + 1
+	}
+	r.offset += len(b)
+}
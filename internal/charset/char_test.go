@@ -0,0 +1,104 @@
+package charset
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/shapestone/shape-xml/internal/xmlerr"
+)
+
+func TestIsChar(t *testing.T) {
+	for _, r := range []rune{'\t', '\n', '\r', ' ', 'a', 0x7F, 0x85, 0xD7FF, 0xE000, 0xFFFD, 0x10000, 0x10FFFF} {
+		if !IsChar(r) {
+			t.Errorf("IsChar(%U) = false", r)
+		}
+	}
+	for _, r := range []rune{0, 0x01, 0x0B, 0x0C, 0x1F, 0xD800, 0xDFFF, 0xFFFE, 0xFFFF, 0x110000, -1} {
+		if IsChar(r) {
+			t.Errorf("IsChar(%U) = true", r)
+		}
+	}
+}
+
+func TestIndexInvalid(t *testing.T) {
+	tests := []struct {
+		input string
+		index int
+		r     rune
+	}{
+		{"<a>\t\r\n</a>", -1, 0},
+		{"\xEF\xBB\xBF<a>é�</a>", -1, 0},
+		{"<a>x\x00</a>", 4, 0},
+		{"<a x='\x1B'/>", 6, 0x1B},
+		{"<a>\xEF\xBF\xBE</a>", 3, 0xFFFE},
+		{"<a>\xEF\xBF\xBF</a>", 3, 0xFFFF},
+		{"<a>\xEF\xBF", 3, BadUTF8},
+		{"<a>\xFF</a>", 3, BadUTF8},
+		{"<a>\xED\xA0\x80</a>", 3, BadUTF8}, // a surrogate
+		{"<a>\U0001F600</a>", -1, 0},
+	}
+	for _, tt := range tests {
+		i, r := IndexInvalid([]byte(tt.input))
+		if i != tt.index || r != tt.r {
+			t.Errorf("IndexInvalid(%q) = %d, %U; want %d, %U", tt.input, i, r, tt.index, tt.r)
+		}
+		if i, r := IndexInvalidString(tt.input); i != tt.index || r != tt.r {
+			t.Errorf("IndexInvalidString(%q) = %d, %U; want %d, %U", tt.input, i, r, tt.index, tt.r)
+		}
+	}
+}
+
+func TestClean(t *testing.T) {
+	input := "<a>x\x01y\xEF\xBF\xBFz\xFFé😀</a>"
+	tests := []struct {
+		mode Mode
+		want string
+	}{
+		{Reject, input},
+		{Strip, "<a>xyzé😀</a>"},
+		{Replace, "<a>x�y�z�é😀</a>"},
+	}
+	for _, tt := range tests {
+		if got := Clean([]byte(input), tt.mode); string(got) != tt.want {
+			t.Errorf("Clean(%d) = %q, want %q", tt.mode, got, tt.want)
+		}
+		if got := CleanString(input, tt.mode); got != tt.want {
+			t.Errorf("CleanString(%d) = %q, want %q", tt.mode, got, tt.want)
+		}
+		// A byte at a time, U+FFFF and the characters after it are split
+		// across reads.
+		r := NewReader(iotest.OneByteReader(bytes.NewReader([]byte(input))))
+		r.Mode = tt.mode
+		got, err := io.ReadAll(r)
+		if tt.mode == Reject {
+			if string(got) != "<a>x" || err == nil {
+				t.Errorf("Reader(Reject) = %q, %v", got, err)
+			}
+			continue
+		}
+		if err != nil || string(got) != tt.want {
+			t.Errorf("Reader(%d) = %q, %v; want %q", tt.mode, got, err, tt.want)
+		}
+	}
+}
+
+func TestReader_InvalidPosition(t *testing.T) {
+	r := NewReader(iotest.HalfReader(bytes.NewReader([]byte("<a>\n  <b>\xEF\xBF\xBE</b>\n</a>"))))
+	_, err := io.ReadAll(r)
+	var syntaxErr *xmlerr.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("got %v, want a *xmlerr.SyntaxError", err)
+	}
+	if syntaxErr.Offset != 9 || syntaxErr.Line != 2 || syntaxErr.Column != 6 {
+		t.Errorf("got offset %d, line %d, column %d; want 9, 2, 6", syntaxErr.Offset, syntaxErr.Line, syntaxErr.Column)
+	}
+	if syntaxErr.Error() != "xml: syntax error at line 2, column 6: illegal character U+FFFE" {
+		t.Errorf("got %q", syntaxErr.Error())
+	}
+	if r.Err() != err {
+		t.Errorf("Err() = %v, want %v", r.Err(), err)
+	}
+}
@@ -0,0 +1,350 @@
+// Package charset detects the character encoding of XML documents and
+// converts documents not encoded in UTF-8, as described in appendix F of
+// the XML specification: a byte-order mark or the layout of the first
+// bytes selects UTF-16, and otherwise the encoding declaration names the
+// encoding. ISO-8859-1, Windows-1252 and UTF-16 are converted by the
+// package itself; other encodings need a Func.
+package charset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Func returns a reader converting input, encoded in charset, to UTF-8.
+// It has the signature of encoding/xml's Decoder.CharsetReader.
+type Func func(charset string, input io.Reader) (io.Reader, error)
+
+// ByteOrderMark is the UTF-8 encoding of U+FEFF, which may start a UTF-8
+// document.
+const ByteOrderMark = "\xEF\xBB\xBF"
+
+// Encodings detected from the first bytes of a document.
+const (
+	UTF16LE = "utf-16le"
+	UTF16BE = "utf-16be"
+)
+
+// maxDecl is the number of bytes read at most to find the end of the XML
+// declaration.
+const maxDecl = 512
+
+// Detect returns the encoding of the document starting with prefix, or ""
+// for UTF-8, along with the length of the byte-order mark of a UTF-16
+// document. A UTF-8 byte-order mark is part of the document; the parsers
+// skip it. Encodings compatible with UTF-8, such as US-ASCII, and UTF-16
+// declared by a document whose first bytes are not UTF-16 count as UTF-8.
+func Detect(prefix []byte) (encoding string, bom int) {
+	switch {
+	case bytes.HasPrefix(prefix, []byte(ByteOrderMark)):
+		return "", 0
+	case bytes.HasPrefix(prefix, []byte{0xFE, 0xFF}):
+		return UTF16BE, 2
+	case bytes.HasPrefix(prefix, []byte{0xFF, 0xFE}):
+		return UTF16LE, 2
+	case bytes.HasPrefix(prefix, []byte{0, '<', 0, '?'}):
+		return UTF16BE, 0
+	case bytes.HasPrefix(prefix, []byte{'<', 0, '?', 0}):
+		return UTF16LE, 0
+	}
+	enc, _, _ := declaredEncoding(prefix)
+	switch strings.ToLower(enc) {
+	case "", "utf-8", "utf8", "us-ascii", "ascii", "utf-16", "utf-16le", "utf-16be":
+		return "", 0
+	}
+	return enc, 0
+}
+
+// declaredEncoding returns the encoding named by the XML declaration at the
+// start of data, and the offsets of the name in data. It returns "" if
+// there is no declaration or it names no encoding.
+func declaredEncoding(data []byte) (enc string, start, end int) {
+	if !bytes.HasPrefix(data, []byte("<?xml")) || len(data) < 6 || !isSpace(data[5]) {
+		return "", 0, 0
+	}
+	declEnd := bytes.IndexByte(data, '>')
+	if declEnd < 0 {
+		return "", 0, 0
+	}
+	decl := data[:declEnd]
+	i := bytes.Index(decl, []byte("encoding"))
+	if i < 0 {
+		return "", 0, 0
+	}
+	i += len("encoding")
+	i = skipSpace(decl, i)
+	if i >= len(decl) || decl[i] != '=' {
+		return "", 0, 0
+	}
+	i = skipSpace(decl, i+1)
+	if i >= len(decl) || (decl[i] != '"' && decl[i] != '\'') {
+		return "", 0, 0
+	}
+	n := bytes.IndexByte(decl[i+1:], decl[i])
+	if n < 0 {
+		return "", 0, 0
+	}
+	return string(decl[i+1 : i+1+n]), i + 1, i + 1 + n
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+func skipSpace(b []byte, i int) int {
+	for i < len(b) && isSpace(b[i]) {
+		i++
+	}
+	return i
+}
+
+// Convert returns data converted to UTF-8, using fn for encodings the
+// package does not know. A document in UTF-8 is returned as it is;
+// otherwise the encoding declaration of the result names UTF-8, so that
+// converting it again leaves it unchanged.
+func Convert(data []byte, fn Func) ([]byte, error) {
+	enc, bom := Detect(data)
+	if enc == "" {
+		return data, nil
+	}
+	r, err := decode(enc, bytes.NewReader(data[bom:]), fn)
+	if err != nil {
+		return nil, err
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("xml: converting from %s: %w", enc, err)
+	}
+	if _, start, end := declaredEncoding(out); end > start {
+		out = append(out[:start], append([]byte("UTF-8"), out[end:]...)...)
+	}
+	return out, nil
+}
+
+// Reader converts the document read from an io.Reader to UTF-8, like
+// Convert, and checks its characters. The encoding is detected on the
+// first call to Read; unlike Convert, Reader leaves the encoding
+// declaration as it is.
+type Reader struct {
+	// Func converts encodings the package does not know, and Mode selects
+	// what happens to characters XML does not allow. Both may be set until
+	// the first call to Read.
+	Func Func
+	Mode Mode
+	// Encoding is the encoding of the input, "" for UTF-8. It is detected
+	// on the first call to Read unless the Reader was made by
+	// NewReaderEncoding.
+	Encoding string
+
+	r     io.Reader
+	known bool
+	bom   int
+	out   *charReader
+	err   error
+}
+
+// NewReader returns a Reader converting r to UTF-8.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// NewReaderEncoding returns a Reader converting r, which is known to be
+// in the encoding enc and positioned after any byte-order mark, to UTF-8.
+// It is used to continue reading a document from the middle, where the
+// encoding cannot be detected.
+func NewReaderEncoding(r io.Reader, enc string) *Reader {
+	return &Reader{r: r, Encoding: enc, known: true}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.out == nil && r.err == nil {
+		var out io.Reader
+		if out, r.err = r.detect(); r.err == nil {
+			r.out = &charReader{src: out, mode: r.Mode}
+		}
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.out.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// Err returns the error other than io.EOF that Read stopped at, if any,
+// for readers of Reader that do not pass on errors.
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// InputOffset returns the offset in the input of the byte at offset n of
+// the UTF-8 form, given the number of UTF-8 continuation bytes cont and of
+// four-byte characters wide among the first n bytes. ok is false if the
+// offsets are not related: for encodings converted by Func, and once a
+// character before n has been stripped or replaced.
+func (r *Reader) InputOffset(n, cont, wide int64) (offset int64, ok bool) {
+	if r.out != nil && r.out.changed && r.out.changedAt < n {
+		return 0, false
+	}
+	switch enc := strings.ToLower(r.Encoding); {
+	case enc == "":
+		return n, true
+	case enc == UTF16LE || enc == UTF16BE:
+		// Two bytes per character, four for those beyond U+FFFF.
+		return int64(r.bom) + 2*(n-cont) + 2*wide, true
+	case runeDecoder(enc) != nil:
+		return int64(r.bom) + n - cont, true // one byte per character
+	}
+	return 0, false
+}
+
+// detect reads the start of the document and returns the reader of its
+// UTF-8 form.
+func (r *Reader) detect() (io.Reader, error) {
+	if r.known {
+		if r.Encoding == "" {
+			return r.r, nil
+		}
+		return decode(r.Encoding, r.r, r.Func)
+	}
+	prefix := make([]byte, 0, maxDecl)
+	// Read enough to see a byte-order mark and, if the document starts
+	// with one, the whole XML declaration.
+	for len(prefix) < len("<?xml ") || (bytes.HasPrefix(prefix, []byte("<?xml")) &&
+		bytes.IndexByte(prefix, '>') < 0 && len(prefix) < cap(prefix)) {
+		n, err := r.r.Read(prefix[len(prefix):cap(prefix)])
+		prefix = prefix[:len(prefix)+n]
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	enc, bom := Detect(prefix)
+	r.Encoding, r.bom = enc, bom
+	src := io.MultiReader(bytes.NewReader(prefix[bom:]), r.r)
+	if enc == "" {
+		return src, nil
+	}
+	return decode(enc, src, r.Func)
+}
+
+// decode returns a reader converting src, encoded in enc, to UTF-8.
+func decode(enc string, src io.Reader, fn Func) (io.Reader, error) {
+	if next := runeDecoder(enc); next != nil {
+		return &runeReader{src: bufio.NewReader(src), next: next}, nil
+	}
+	if fn == nil {
+		return nil, fmt.Errorf("xml: unsupported encoding %q", enc)
+	}
+	r, err := fn(enc, src)
+	if err != nil {
+		return nil, fmt.Errorf("xml: opening encoding %q: %w", enc, err)
+	}
+	return r, nil
+}
+
+// runeDecoder returns the function reading one character of the encoding
+// enc, or nil if the package does not know it.
+func runeDecoder(enc string) func(*bufio.Reader) (rune, error) {
+	switch strings.ToLower(enc) {
+	case "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "l1", "cp819", "ibm819", "iso-ir-100":
+		return func(r *bufio.Reader) (rune, error) {
+			b, err := r.ReadByte()
+			return rune(b), err
+		}
+	case "windows-1252", "cp1252", "x-cp1252":
+		return func(r *bufio.Reader) (rune, error) {
+			b, err := r.ReadByte()
+			if b >= 0x80 && b < 0xA0 {
+				return windows1252[b-0x80], err
+			}
+			return rune(b), err
+		}
+	case UTF16LE:
+		return utf16Decoder(binary.LittleEndian)
+	case UTF16BE:
+		return utf16Decoder(binary.BigEndian)
+	}
+	return nil
+}
+
+// windows1252 maps the bytes 0x80 to 0x9F of Windows-1252, where it differs
+// from ISO-8859-1. Bytes it leaves undefined map to the same code point.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// utf16Decoder returns the function reading one character of UTF-16 in
+// the given byte order. Unpaired surrogates and a trailing odd byte read
+// as U+FFFD.
+func utf16Decoder(order binary.ByteOrder) func(*bufio.Reader) (rune, error) {
+	var buf [2]byte
+	return func(r *bufio.Reader) (rune, error) {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return utf8.RuneError, nil
+			}
+			return 0, err
+		}
+		c := rune(order.Uint16(buf[:]))
+		if !utf16.IsSurrogate(c) {
+			return c, nil
+		}
+		next, err := r.Peek(2)
+		if c >= 0xDC00 || err != nil {
+			return utf8.RuneError, nil
+		}
+		low := rune(order.Uint16(next))
+		if low < 0xDC00 || low > 0xDFFF {
+			return utf8.RuneError, nil
+		}
+		r.Discard(2)
+		return utf16.DecodeRune(c, low), nil
+	}
+}
+
+// runeReader encodes the characters read by next from src as UTF-8.
+type runeReader struct {
+	src  *bufio.Reader
+	next func(*bufio.Reader) (rune, error)
+	// pending holds the bytes of a character that did not fit the last
+	// Read.
+	pending []byte
+	err     error
+}
+
+func (r *runeReader) Read(p []byte) (int, error) {
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	for n < len(p) && len(r.pending) == 0 && r.err == nil {
+		c, err := r.next(r.src)
+		if err != nil {
+			r.err = err
+			break
+		}
+		if len(p)-n >= utf8.UTFMax {
+			n += utf8.EncodeRune(p[n:], c)
+			continue
+		}
+		var buf [utf8.UTFMax]byte
+		size := utf8.EncodeRune(buf[:], c)
+		k := copy(p[n:], buf[:size])
+		n += k
+		r.pending = append(r.pending[:0], buf[k:size]...)
+	}
+	if n > 0 {
+		return n, nil
+	}
+	return 0, r.err
+}
@@ -0,0 +1,125 @@
+package charset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+)
+
+// encodeUTF16 returns s in UTF-16 in the given byte order.
+func encodeUTF16(s string, order binary.ByteOrder) []byte {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(u))
+	for i, c := range u {
+		order.PutUint16(b[2*i:], c)
+	}
+	return b
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		input string
+		enc   string
+		bom   int
+	}{
+		{`<a/>`, "", 0},
+		{ByteOrderMark + `<?xml version="1.0" encoding="ISO-8859-1"?><a/>`, "", 0},
+		{"\xFE\xFF\x00<", UTF16BE, 2},
+		{"\xFF\xFE<\x00", UTF16LE, 2},
+		{"\x00<\x00?\x00x", UTF16BE, 0},
+		{"<\x00?\x00x\x00", UTF16LE, 0},
+		{`<?xml version="1.0" encoding="ISO-8859-1"?><a/>`, "ISO-8859-1", 0},
+		{`<?xml version='1.0' encoding = 'windows-1252' ?><a/>`, "windows-1252", 0},
+		{`<?xml version="1.0" encoding="us-ascii"?><a/>`, "", 0},
+		{`<?xml version="1.0" encoding="UTF-16"?><a/>`, "", 0},
+		{`<?xml version="1.0"?><a encoding="latin1"/>`, "", 0},
+		{`<?xml-stylesheet encoding="latin1"?><a/>`, "", 0},
+	}
+	for _, tt := range tests {
+		enc, bom := Detect([]byte(tt.input))
+		if enc != tt.enc || bom != tt.bom {
+			t.Errorf("Detect(%q) = %q, %d; want %q, %d", tt.input, enc, bom, tt.enc, tt.bom)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	utf16Doc := `<?xml version="1.0" encoding="UTF-16"?><a>€ 𝄞</a>`
+	tests := []struct {
+		name  string
+		input []byte
+		want  string
+	}{
+		{"UTF-8", []byte(`<a>é</a>`), `<a>é</a>`},
+		{"ISO-8859-1", []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><a>caf\xE9</a>"),
+			`<?xml version="1.0" encoding="UTF-8"?><a>café</a>`},
+		{"Windows-1252", []byte("<?xml version='1.0' encoding='cp1252'?><a>\x80 \x93q\x94 \x81</a>"),
+			"<?xml version='1.0' encoding='UTF-8'?><a>€ “q” \u0081</a>"},
+		{"UTF-16LE BOM", append([]byte{0xFF, 0xFE}, encodeUTF16(utf16Doc, binary.LittleEndian)...),
+			`<?xml version="1.0" encoding="UTF-8"?><a>€ 𝄞</a>`},
+		{"UTF-16BE BOM", append([]byte{0xFE, 0xFF}, encodeUTF16(`<a>x</a>`, binary.BigEndian)...), `<a>x</a>`},
+		{"UTF-16LE", encodeUTF16(utf16Doc, binary.LittleEndian), `<?xml version="1.0" encoding="UTF-8"?><a>€ 𝄞</a>`},
+		{"unpaired surrogate", append([]byte{0xFF, 0xFE}, encodeUTF16("<a>", binary.LittleEndian)...), "<a>"},
+	}
+	tests[len(tests)-1].input = append(tests[len(tests)-1].input, 0x00, 0xD8, 'x', 0, 0x41)
+	tests[len(tests)-1].want = "<a>�x�"
+	for _, tt := range tests {
+		got, err := Convert(tt.input, nil)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		// Converting again leaves the document unchanged.
+		if again, err := Convert(got, nil); err != nil || !bytes.Equal(again, got) {
+			t.Errorf("%s: converting again gave %q, %v", tt.name, again, err)
+		}
+		// Reader gives the same text, read a byte at a time.
+		r := NewReader(iotest.OneByteReader(bytes.NewReader(tt.input)))
+		out, err := io.ReadAll(iotest.OneByteReader(r))
+		if err != nil {
+			t.Errorf("%s: Reader: %v", tt.name, err)
+		}
+		if _, start, end := declaredEncoding(out); end > start {
+			out = append(out[:start], append([]byte("UTF-8"), out[end:]...)...)
+		}
+		if string(out) != tt.want {
+			t.Errorf("%s: Reader gave %q, want %q", tt.name, out, tt.want)
+		}
+	}
+}
+
+func TestConvert_CharsetFunc(t *testing.T) {
+	input := []byte(`<?xml version="1.0" encoding="x-upper"?><a>shout</a>`)
+	if _, err := Convert(input, nil); err == nil || err.Error() != `xml: unsupported encoding "x-upper"` {
+		t.Errorf("without a Func: %v", err)
+	}
+
+	upper := func(charset string, r io.Reader) (io.Reader, error) {
+		if charset != "x-upper" {
+			return nil, errors.New("unknown charset")
+		}
+		data, err := io.ReadAll(r)
+		return strings.NewReader(strings.ToUpper(string(data))), err
+	}
+	got, err := Convert(input, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<?XML VERSION="1.0" ENCODING="X-UPPER"?><A>SHOUT</A>`; string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	r := NewReader(bytes.NewReader([]byte(`<?xml version="1.0" encoding="x-other"?><a/>`)))
+	r.Func = upper
+	if _, err := io.ReadAll(r); err == nil || !strings.Contains(err.Error(), "unknown charset") {
+		t.Errorf("Reader: %v", err)
+	}
+}
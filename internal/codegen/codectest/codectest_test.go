@@ -0,0 +1,232 @@
+package codectest
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/shapestone/shape-xml/pkg/xml"
+)
+
+// The plain types mirror the generated ones without their methods, so
+// Marshal and Unmarshal handle them by reflection.
+
+type plainOrder struct {
+	XMLName  xml.Name      `xml:"order"`
+	ID       int64         `xml:"id,attr"`
+	Status   Status        `xml:"status,attr,omitempty"`
+	Rush     *bool         `xml:"rush,attr"`
+	Customer plainCustomer `xml:"customer"`
+	Items    []plainItem   `xml:"item"`
+	Notes    []string      `xml:"note,omitempty"`
+	Coupon   *string       `xml:"coupon"`
+	Discount float64       `xml:"discount,omitempty"`
+	Ship     *plainAddress `xml:"ship,omitempty"`
+	Internal string        `xml:"-"`
+}
+
+func (o plainOrder) XMLAttr_count() string {
+	return strconv.Itoa(len(o.Items))
+}
+
+type plainCustomer struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email,omitempty"`
+	Tier  uint8  `xml:"tier,attr"`
+}
+
+type plainItem struct {
+	SKU   string  `xml:"sku,attr"`
+	Qty   int     `xml:"qty,attr,omitempty"`
+	Price float32 `xml:"price,attr"`
+	Title string  `xml:",chardata"`
+}
+
+type plainAddress struct {
+	XMLName xml.Name
+	Lines   []string `xml:"line"`
+	Zip     *plainZip
+}
+
+type plainZip struct {
+	XMLName struct{} `xml:"zip"`
+	Code    string   `xml:",chardata"`
+}
+
+// convert copies from into to, which have the same fields.
+func convert(t *testing.T, from, to interface{}) {
+	t.Helper()
+	data, err := json.Marshal(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, to); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testOrders() map[string]Order {
+	rush := true
+	coupon := "SAVE & <more>"
+	return map[string]Order{
+		"zero": {},
+		"full": {
+			ID:       42,
+			Status:   "open",
+			Rush:     &rush,
+			Customer: Customer{Name: "Ada", Email: "ada@example.com", Tier: 3},
+			Items: []Item{
+				{SKU: "a-1", Qty: 2, Price: 9.99, Title: "Widget"},
+				{SKU: "b-2", Price: 0.1, Title: "  spaced  "},
+				{SKU: "c-3"},
+			},
+			Notes:    []string{"fragile", "gift"},
+			Coupon:   &coupon,
+			Discount: 1.5,
+			Ship:     &Address{Lines: []string{"1 Main St"}, Zip: &Zip{Code: "12345"}},
+			Internal: "not written",
+			secret:   "not written",
+		},
+		"named address": {
+			Ship: &Address{XMLName: xml.Name{Local: "dest"}, Zip: &Zip{}},
+		},
+		"empty slices": {
+			Items: []Item{},
+			Notes: []string{},
+		},
+		"empty items": {
+			Items: []Item{{}},
+			Notes: []string{""},
+			Ship:  &Address{Lines: []string{""}},
+		},
+	}
+}
+
+func TestGenerated_MarshalMatchesReflection(t *testing.T) {
+	for name, order := range testOrders() {
+		t.Run(name, func(t *testing.T) {
+			got, err := xml.Marshal(order)
+			if err != nil {
+				t.Fatalf("generated Marshal failed: %v", err)
+			}
+			var plain plainOrder
+			convert(t, order, &plain)
+			want, err := xml.Marshal(plain)
+			if err != nil {
+				t.Fatalf("reflection Marshal failed: %v", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("generated  %s\nreflection %s", got, want)
+			}
+		})
+	}
+}
+
+func TestGenerated_UnmarshalMatchesReflection(t *testing.T) {
+	for name, order := range testOrders() {
+		t.Run(name, func(t *testing.T) {
+			var plain plainOrder
+			convert(t, order, &plain)
+			data, err := xml.Marshal(plain)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+
+			var got Order
+			if err := xml.Unmarshal(data, &got); err != nil {
+				t.Fatalf("generated Unmarshal failed: %v", err)
+			}
+			var want plainOrder
+			if err := xml.Unmarshal(data, &want); err != nil {
+				t.Fatalf("reflection Unmarshal failed: %v", err)
+			}
+			var gotPlain plainOrder
+			convert(t, got, &gotPlain)
+			if !reflect.DeepEqual(gotPlain, want) {
+				t.Errorf("generated  %+v\nreflection %+v", gotPlain, want)
+			}
+		})
+	}
+}
+
+func TestGenerated_NestedInReflectedStruct(t *testing.T) {
+	type envelope struct {
+		Order     Order      `xml:"o"`
+		Customers []Customer `xml:"c"`
+		Missing   *Customer  `xml:"m"`
+		Named     *Address   `xml:"addr"`
+		Items     []*Item    `xml:"i"`
+	}
+	v := envelope{
+		Customers: []Customer{{Name: "a"}, {Name: "b"}},
+		Named:     &Address{},
+		Items:     []*Item{nil, {SKU: "x"}},
+	}
+	got, err := xml.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	// Generated types keep the field's name, except where their XMLName
+	// tag names the element.
+	// Nil pointer fields and nil slices are left out; a nil item of a
+	// slice is not.
+	want := `<envelope><order count="0" id="0"><customer tier="0"><name></name></customer></order>` +
+		`<c tier="0"><name>a</name></c><c tier="0"><name>b</name></c>` +
+		`<addr/><i/><i price="0" sku="x"/></envelope>`
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestGenerated_UnsupportedFloat(t *testing.T) {
+	_, err := xml.Marshal(Order{Discount: math.NaN()})
+	var uve *xml.UnsupportedValueError
+	if !errors.As(err, &uve) {
+		t.Errorf("err = %v, want *UnsupportedValueError", err)
+	}
+}
+
+func TestGenerated_UnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		path string
+	}{
+		{`<order id="x"/>`, "/order/@id"},
+		{`<order><discount>lots</discount></order>`, "/order/discount"},
+		{`<order><item qty="1.5"/></order>`, "/order/item/@qty"},
+	}
+	for _, tt := range tests {
+		var v Order
+		err := xml.Unmarshal([]byte(tt.data), &v)
+		var ue *xml.UnmarshalError
+		if !errors.As(err, &ue) {
+			t.Errorf("%s: err = %v, want *UnmarshalError", tt.data, err)
+			continue
+		}
+		if ue.Path != tt.path {
+			t.Errorf("%s: Path = %q, want %q", tt.data, ue.Path, tt.path)
+		}
+	}
+
+	var v Order
+	if err := xml.Unmarshal([]byte(`<order><item>`), &v); err == nil {
+		t.Error("expected a syntax error for unclosed elements")
+	}
+	if err := xml.Unmarshal([]byte(``), &v); err == nil {
+		t.Error("expected an error for a document without root")
+	}
+}
+
+func TestGenerated_EmptyStruct(t *testing.T) {
+	got, err := xml.Marshal(Empty{})
+	if err != nil || string(got) != "<Empty/>" {
+		t.Errorf("Marshal = %s, %v", got, err)
+	}
+	var e Empty
+	if err := xml.Unmarshal([]byte(`<Empty><skipped><x/></skipped></Empty>`), &e); err != nil {
+		t.Errorf("Unmarshal failed: %v", err)
+	}
+}
@@ -0,0 +1,682 @@
+// Code generated by shapexml-gen. DO NOT EDIT.
+
+package codectest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	shapexml "github.com/shapestone/shape-xml/pkg/xml"
+)
+
+// AppendXMLElement appends the XML encoding of v to buf as an element
+// named name. It implements xml.ElementMarshaler.
+func (v Order) AppendXMLElement(buf []byte, name string) ([]byte, error) {
+	name = "order"
+	buf = append(buf, '<')
+	buf = append(buf, name...)
+	if s1 := v.XMLAttr_count(); s1 != "" {
+		buf = append(buf, " count=\""...)
+		buf = shapexml.AppendEscapedAttr(buf, s1)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, " id=\""...)
+	buf = strconv.AppendInt(buf, int64(v.ID), 10)
+	buf = append(buf, '"')
+	if v.Rush != nil {
+		buf = append(buf, " rush=\""...)
+		buf = strconv.AppendBool(buf, bool(*v.Rush))
+		buf = append(buf, '"')
+	}
+	if v.Status != "" {
+		buf = append(buf, " status=\""...)
+		buf = shapexml.AppendEscapedAttr(buf, string(v.Status))
+		buf = append(buf, '"')
+	}
+	buf = append(buf, '>')
+	var err error
+	if buf, err = v.Customer.AppendXMLElement(buf, "customer"); err != nil {
+		return buf, err
+	}
+	for _, item2 := range v.Items {
+		if buf, err = item2.AppendXMLElement(buf, "item"); err != nil {
+			return buf, err
+		}
+	}
+	if len(v.Notes) != 0 {
+		for _, item3 := range v.Notes {
+			buf = append(buf, "<note>"...)
+			buf = shapexml.AppendEscapedText(buf, string(item3))
+			buf = append(buf, "</note>"...)
+		}
+	}
+	if v.Coupon != nil {
+		buf = append(buf, "<coupon>"...)
+		buf = shapexml.AppendEscapedText(buf, string(*v.Coupon))
+		buf = append(buf, "</coupon>"...)
+	}
+	if v.Discount != 0 {
+		f4 := shapexmlFormatFloat(float64(v.Discount), 64)
+		if f4.err != nil {
+			return buf, f4.err
+		}
+		buf = append(buf, "<discount>"...)
+		buf = append(buf, f4.s...)
+		buf = append(buf, "</discount>"...)
+	}
+	if v.Ship != nil {
+		if buf, err = v.Ship.AppendXMLElement(buf, "ship"); err != nil {
+			return buf, err
+		}
+	}
+	buf = append(buf, "</"...)
+	buf = append(buf, name...)
+	return append(buf, '>'), nil
+}
+
+// MarshalXML returns the XML encoding of v as the root element.
+// It implements xml.Marshaler.
+func (v Order) MarshalXML() ([]byte, error) {
+	return v.AppendXMLElement(nil, "Order")
+}
+
+// UnmarshalXML decodes the root element of data into v. It implements
+// xml.Unmarshaler.
+func (v *Order) UnmarshalXML(data []byte) error {
+	d := shapexml.NewDecoder(bytes.NewReader(data))
+	start, err := shapexmlRoot(d)
+	if err != nil {
+		return err
+	}
+	return v.decodeXMLElement(d, start, "/"+start.Name)
+}
+
+// decodeXMLElement decodes the element start opened into v, reading up
+// to its end tag. path locates the element for errors.
+func (v *Order) decodeXMLElement(d *shapexml.Decoder, start shapexml.StartElement, path string) error {
+	v.XMLName.Space, v.XMLName.Local = shapexmlElementName(start)
+	for _, a := range start.Attr {
+		switch a.Name {
+		case "id":
+			n1, err := shapexmlParseInt(a.Value, 64, "int64")
+			if err != nil {
+				return &shapexml.UnmarshalError{Path: path + "/@id", Err: err}
+			}
+			v.ID = int64(n1)
+		case "rush":
+			if v.Rush == nil {
+				v.Rush = new(bool)
+			}
+			n2, err := shapexmlParseBool(a.Value, "bool")
+			if err != nil {
+				return &shapexml.UnmarshalError{Path: path + "/@rush", Err: err}
+			}
+			*v.Rush = bool(n2)
+		case "status":
+			v.Status = Status(a.Value)
+		}
+	}
+	var nItems int
+	var nNotes int
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case shapexml.StartElement:
+			switch t.Name {
+			case "customer":
+				if err := v.Customer.decodeXMLElement(d, t, path+"/"+t.Name); err != nil {
+					return err
+				}
+			case "item":
+				var item3 Item
+				if err := item3.decodeXMLElement(d, t, path+"/"+t.Name); err != nil {
+					return err
+				}
+				if nItems == 0 {
+					v.Items = nil
+				}
+				nItems++
+				v.Items = append(v.Items, item3)
+			case "note":
+				var item4 string
+				s5, err := shapexmlText(d)
+				if err != nil {
+					return err
+				}
+				item4 = string(s5)
+				if nNotes == 0 {
+					v.Notes = nil
+				}
+				nNotes++
+				v.Notes = append(v.Notes, item4)
+			case "coupon":
+				if v.Coupon == nil {
+					v.Coupon = new(string)
+				}
+				s6, err := shapexmlText(d)
+				if err != nil {
+					return err
+				}
+				*v.Coupon = string(s6)
+			case "discount":
+				s7, err := shapexmlText(d)
+				if err != nil {
+					return err
+				}
+				n8, err := shapexmlParseFloat(s7, 64, "float64")
+				if err != nil {
+					return &shapexml.UnmarshalError{Path: path + "/" + t.Name, Err: err}
+				}
+				v.Discount = float64(n8)
+			case "ship":
+				if v.Ship == nil {
+					v.Ship = new(Address)
+				}
+				if err := v.Ship.decodeXMLElement(d, t, path+"/"+t.Name); err != nil {
+					return err
+				}
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+		case shapexml.EndElement:
+			return nil
+		}
+	}
+}
+
+// AppendXMLElement appends the XML encoding of v to buf as an element
+// named name. It implements xml.ElementMarshaler.
+func (v Customer) AppendXMLElement(buf []byte, name string) ([]byte, error) {
+	buf = append(buf, '<')
+	buf = append(buf, name...)
+	buf = append(buf, " tier=\""...)
+	buf = strconv.AppendUint(buf, uint64(v.Tier), 10)
+	buf = append(buf, '"')
+	buf = append(buf, '>')
+	buf = append(buf, "<name>"...)
+	buf = shapexml.AppendEscapedText(buf, string(v.Name))
+	buf = append(buf, "</name>"...)
+	if v.Email != "" {
+		buf = append(buf, "<email>"...)
+		buf = shapexml.AppendEscapedText(buf, string(v.Email))
+		buf = append(buf, "</email>"...)
+	}
+	buf = append(buf, "</"...)
+	buf = append(buf, name...)
+	return append(buf, '>'), nil
+}
+
+// MarshalXML returns the XML encoding of v as the root element.
+// It implements xml.Marshaler.
+func (v Customer) MarshalXML() ([]byte, error) {
+	return v.AppendXMLElement(nil, "Customer")
+}
+
+// UnmarshalXML decodes the root element of data into v. It implements
+// xml.Unmarshaler.
+func (v *Customer) UnmarshalXML(data []byte) error {
+	d := shapexml.NewDecoder(bytes.NewReader(data))
+	start, err := shapexmlRoot(d)
+	if err != nil {
+		return err
+	}
+	return v.decodeXMLElement(d, start, "/"+start.Name)
+}
+
+// decodeXMLElement decodes the element start opened into v, reading up
+// to its end tag. path locates the element for errors.
+func (v *Customer) decodeXMLElement(d *shapexml.Decoder, start shapexml.StartElement, path string) error {
+	for _, a := range start.Attr {
+		switch a.Name {
+		case "tier":
+			n1, err := shapexmlParseUint(a.Value, 8, "uint8")
+			if err != nil {
+				return &shapexml.UnmarshalError{Path: path + "/@tier", Err: err}
+			}
+			v.Tier = uint8(n1)
+		}
+	}
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case shapexml.StartElement:
+			switch t.Name {
+			case "name":
+				s2, err := shapexmlText(d)
+				if err != nil {
+					return err
+				}
+				v.Name = string(s2)
+			case "email":
+				s3, err := shapexmlText(d)
+				if err != nil {
+					return err
+				}
+				v.Email = string(s3)
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+		case shapexml.EndElement:
+			return nil
+		}
+	}
+}
+
+// AppendXMLElement appends the XML encoding of v to buf as an element
+// named name. It implements xml.ElementMarshaler.
+func (v Item) AppendXMLElement(buf []byte, name string) ([]byte, error) {
+	buf = append(buf, '<')
+	buf = append(buf, name...)
+	buf = append(buf, " price=\""...)
+	f1 := shapexmlFormatFloat(float64(v.Price), 32)
+	if f1.err != nil {
+		return buf, f1.err
+	}
+	buf = append(buf, f1.s...)
+	buf = append(buf, '"')
+	if v.Qty != 0 {
+		buf = append(buf, " qty=\""...)
+		buf = strconv.AppendInt(buf, int64(v.Qty), 10)
+		buf = append(buf, '"')
+	}
+	if v.SKU != "" {
+		buf = append(buf, " sku=\""...)
+		buf = shapexml.AppendEscapedAttr(buf, string(v.SKU))
+		buf = append(buf, '"')
+	}
+	var text string
+	text = string(v.Title)
+	if !(text != "") {
+		return append(buf, '/', '>'), nil
+	}
+	buf = append(buf, '>')
+	buf = shapexml.AppendEscapedText(buf, text)
+	buf = append(buf, "</"...)
+	buf = append(buf, name...)
+	return append(buf, '>'), nil
+}
+
+// MarshalXML returns the XML encoding of v as the root element.
+// It implements xml.Marshaler.
+func (v Item) MarshalXML() ([]byte, error) {
+	return v.AppendXMLElement(nil, "Item")
+}
+
+// UnmarshalXML decodes the root element of data into v. It implements
+// xml.Unmarshaler.
+func (v *Item) UnmarshalXML(data []byte) error {
+	d := shapexml.NewDecoder(bytes.NewReader(data))
+	start, err := shapexmlRoot(d)
+	if err != nil {
+		return err
+	}
+	return v.decodeXMLElement(d, start, "/"+start.Name)
+}
+
+// decodeXMLElement decodes the element start opened into v, reading up
+// to its end tag. path locates the element for errors.
+func (v *Item) decodeXMLElement(d *shapexml.Decoder, start shapexml.StartElement, path string) error {
+	for _, a := range start.Attr {
+		switch a.Name {
+		case "price":
+			n1, err := shapexmlParseFloat(a.Value, 32, "float32")
+			if err != nil {
+				return &shapexml.UnmarshalError{Path: path + "/@price", Err: err}
+			}
+			v.Price = float32(n1)
+		case "qty":
+			n2, err := shapexmlParseInt(a.Value, strconv.IntSize, "int")
+			if err != nil {
+				return &shapexml.UnmarshalError{Path: path + "/@qty", Err: err}
+			}
+			v.Qty = int(n2)
+		case "sku":
+			v.SKU = string(a.Value)
+		}
+	}
+	var text []byte
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case shapexml.StartElement:
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		case shapexml.CharData:
+			text = append(text, t...)
+		case shapexml.EndElement:
+			s := strings.TrimSpace(string(text))
+			v.Title = string(s)
+			return nil
+		}
+	}
+}
+
+// AppendXMLElement appends the XML encoding of v to buf as an element
+// named name. It implements xml.ElementMarshaler.
+func (v Address) AppendXMLElement(buf []byte, name string) ([]byte, error) {
+	if v.XMLName.Local != "" {
+		name = v.XMLName.Local
+	}
+	buf = append(buf, '<')
+	buf = append(buf, name...)
+	if !(len(v.Lines) != 0 || v.Zip != nil) {
+		return append(buf, '/', '>'), nil
+	}
+	buf = append(buf, '>')
+	var err error
+	for _, item1 := range v.Lines {
+		buf = append(buf, "<line>"...)
+		buf = shapexml.AppendEscapedText(buf, string(item1))
+		buf = append(buf, "</line>"...)
+	}
+	if v.Zip != nil {
+		if buf, err = v.Zip.AppendXMLElement(buf, "zip"); err != nil {
+			return buf, err
+		}
+	}
+	buf = append(buf, "</"...)
+	buf = append(buf, name...)
+	return append(buf, '>'), nil
+}
+
+// MarshalXML returns the XML encoding of v as the root element.
+// It implements xml.Marshaler.
+func (v Address) MarshalXML() ([]byte, error) {
+	return v.AppendXMLElement(nil, "Address")
+}
+
+// UnmarshalXML decodes the root element of data into v. It implements
+// xml.Unmarshaler.
+func (v *Address) UnmarshalXML(data []byte) error {
+	d := shapexml.NewDecoder(bytes.NewReader(data))
+	start, err := shapexmlRoot(d)
+	if err != nil {
+		return err
+	}
+	return v.decodeXMLElement(d, start, "/"+start.Name)
+}
+
+// decodeXMLElement decodes the element start opened into v, reading up
+// to its end tag. path locates the element for errors.
+func (v *Address) decodeXMLElement(d *shapexml.Decoder, start shapexml.StartElement, path string) error {
+	v.XMLName.Space, v.XMLName.Local = shapexmlElementName(start)
+	var nLines int
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case shapexml.StartElement:
+			switch t.Name {
+			case "line":
+				var item1 string
+				s2, err := shapexmlText(d)
+				if err != nil {
+					return err
+				}
+				item1 = string(s2)
+				if nLines == 0 {
+					v.Lines = nil
+				}
+				nLines++
+				v.Lines = append(v.Lines, item1)
+			case "zip":
+				if v.Zip == nil {
+					v.Zip = new(Zip)
+				}
+				if err := v.Zip.decodeXMLElement(d, t, path+"/"+t.Name); err != nil {
+					return err
+				}
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+		case shapexml.EndElement:
+			return nil
+		}
+	}
+}
+
+// AppendXMLElement appends the XML encoding of v to buf as an element
+// named name. It implements xml.ElementMarshaler.
+func (v Zip) AppendXMLElement(buf []byte, name string) ([]byte, error) {
+	name = "zip"
+	buf = append(buf, '<')
+	buf = append(buf, name...)
+	var text string
+	text = string(v.Code)
+	if !(text != "") {
+		return append(buf, '/', '>'), nil
+	}
+	buf = append(buf, '>')
+	buf = shapexml.AppendEscapedText(buf, text)
+	buf = append(buf, "</"...)
+	buf = append(buf, name...)
+	return append(buf, '>'), nil
+}
+
+// MarshalXML returns the XML encoding of v as the root element.
+// It implements xml.Marshaler.
+func (v Zip) MarshalXML() ([]byte, error) {
+	return v.AppendXMLElement(nil, "Zip")
+}
+
+// UnmarshalXML decodes the root element of data into v. It implements
+// xml.Unmarshaler.
+func (v *Zip) UnmarshalXML(data []byte) error {
+	d := shapexml.NewDecoder(bytes.NewReader(data))
+	start, err := shapexmlRoot(d)
+	if err != nil {
+		return err
+	}
+	return v.decodeXMLElement(d, start, "/"+start.Name)
+}
+
+// decodeXMLElement decodes the element start opened into v, reading up
+// to its end tag. path locates the element for errors.
+func (v *Zip) decodeXMLElement(d *shapexml.Decoder, start shapexml.StartElement, path string) error {
+	var text []byte
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case shapexml.StartElement:
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		case shapexml.CharData:
+			text = append(text, t...)
+		case shapexml.EndElement:
+			s := strings.TrimSpace(string(text))
+			v.Code = string(s)
+			return nil
+		}
+	}
+}
+
+// AppendXMLElement appends the XML encoding of v to buf as an element
+// named name. It implements xml.ElementMarshaler.
+func (v Empty) AppendXMLElement(buf []byte, name string) ([]byte, error) {
+	buf = append(buf, '<')
+	buf = append(buf, name...)
+	return append(buf, '/', '>'), nil
+}
+
+// MarshalXML returns the XML encoding of v as the root element.
+// It implements xml.Marshaler.
+func (v Empty) MarshalXML() ([]byte, error) {
+	return v.AppendXMLElement(nil, "Empty")
+}
+
+// UnmarshalXML decodes the root element of data into v. It implements
+// xml.Unmarshaler.
+func (v *Empty) UnmarshalXML(data []byte) error {
+	d := shapexml.NewDecoder(bytes.NewReader(data))
+	start, err := shapexmlRoot(d)
+	if err != nil {
+		return err
+	}
+	return v.decodeXMLElement(d, start, "/"+start.Name)
+}
+
+// decodeXMLElement decodes the element start opened into v, reading up
+// to its end tag. path locates the element for errors.
+func (v *Empty) decodeXMLElement(d *shapexml.Decoder, start shapexml.StartElement, path string) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok.(type) {
+		case shapexml.StartElement:
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		case shapexml.EndElement:
+			return nil
+		}
+	}
+}
+
+// shapexmlFloat is the text of a float, or the error for NaN and infinity,
+// which Marshal rejects by default.
+type shapexmlFloat struct {
+	s   string
+	err error
+}
+
+func shapexmlFormatFloat(f float64, bits int) shapexmlFloat {
+	s := strconv.FormatFloat(f, 'g', -1, bits)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return shapexmlFloat{err: &shapexml.UnsupportedValueError{Str: s}}
+	}
+	return shapexmlFloat{s: s}
+}
+
+// shapexmlRoot returns the start tag of the root element.
+func shapexmlRoot(d *shapexml.Decoder) (shapexml.StartElement, error) {
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return shapexml.StartElement{}, errors.New("xml: no root element")
+		}
+		if err != nil {
+			return shapexml.StartElement{}, err
+		}
+		if start, ok := tok.(shapexml.StartElement); ok {
+			return start, nil
+		}
+	}
+}
+
+// shapexmlText reads the text of the element just opened up to its end
+// tag, with surrounding whitespace removed. Nested elements are skipped.
+func shapexmlText(d *shapexml.Decoder) (string, error) {
+	var text []byte
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case shapexml.CharData:
+			text = append(text, t...)
+		case shapexml.StartElement:
+			if err := d.Skip(); err != nil {
+				return "", err
+			}
+		case shapexml.EndElement:
+			return strings.TrimSpace(string(text)), nil
+		}
+	}
+}
+
+// shapexmlElementName returns the namespace declared on start for its
+// prefix, and its local name.
+func shapexmlElementName(start shapexml.StartElement) (space, local string) {
+	prefix, local := shapexml.SplitName(start.Name)
+	attr := "xmlns"
+	if prefix != "" {
+		attr += ":" + prefix
+	}
+	for _, a := range start.Attr {
+		if a.Name == attr {
+			space = a.Value
+		}
+	}
+	return space, local
+}
+
+func shapexmlParseBool(s, typ string) (bool, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("xml: cannot parse %q as %s", s, typ)
+	}
+	return b, nil
+}
+
+func shapexmlParseInt(s string, bits int, typ string) (int64, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(s, 10, bits)
+	if err != nil {
+		return 0, shapexmlParseError(s, typ, err)
+	}
+	return n, nil
+}
+
+func shapexmlParseUint(s string, bits int, typ string) (uint64, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(s, 10, bits)
+	if err != nil {
+		return 0, shapexmlParseError(s, typ, err)
+	}
+	return n, nil
+}
+
+func shapexmlParseFloat(s string, bits int, typ string) (float64, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(s, bits)
+	if err != nil {
+		return 0, shapexmlParseError(s, typ, err)
+	}
+	return f, nil
+}
+
+func shapexmlParseError(s, typ string, err error) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf("xml: cannot parse %q as %s: %w", s, typ, strconv.ErrRange)
+	}
+	return fmt.Errorf("xml: cannot parse %q as %s", s, typ)
+}
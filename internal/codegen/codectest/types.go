@@ -0,0 +1,66 @@
+// Package codectest holds structs with codecs generated by shapexml-gen,
+// which the tests compare against the reflection path.
+package codectest
+
+//go:generate go run ../../../cmd/shapexml-gen
+
+import (
+	"strconv"
+
+	"github.com/shapestone/shape-xml/pkg/xml"
+)
+
+// Status is a named scalar type.
+type Status string
+
+//shapexml:codec
+type Order struct {
+	XMLName  xml.Name `xml:"order"`
+	ID       int64    `xml:"id,attr"`
+	Status   Status   `xml:"status,attr,omitempty"`
+	Rush     *bool    `xml:"rush,attr"`
+	Customer Customer `xml:"customer"`
+	Items    []Item   `xml:"item"`
+	Notes    []string `xml:"note,omitempty"`
+	Coupon   *string  `xml:"coupon"`
+	Discount float64  `xml:"discount,omitempty"`
+	Ship     *Address `xml:"ship,omitempty"`
+	Internal string   `xml:"-"`
+	secret   string
+}
+
+// XMLAttr_count is a computed attribute holding the number of items.
+func (o Order) XMLAttr_count() string {
+	return strconv.Itoa(len(o.Items))
+}
+
+//shapexml:codec
+type Customer struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email,omitempty"`
+	Tier  uint8  `xml:"tier,attr"`
+}
+
+//shapexml:codec
+type Item struct {
+	SKU   string  `xml:"sku,attr"`
+	Qty   int     `xml:"qty,attr,omitempty"`
+	Price float32 `xml:"price,attr"`
+	Title string  `xml:",chardata"`
+}
+
+//shapexml:codec
+type Address struct {
+	XMLName xml.Name
+	Lines   []string `xml:"line"`
+	Zip     *Zip
+}
+
+//shapexml:codec
+type Zip struct {
+	XMLName struct{} `xml:"zip"`
+	Code    string   `xml:",chardata"`
+}
+
+//shapexml:codec
+type Empty struct{}
@@ -0,0 +1,501 @@
+// Package codegen generates reflection-free XML codecs for annotated
+// structs. It backs cmd/shapexml-gen.
+//
+// A struct is annotated by a //shapexml:codec line in its doc comment:
+//
+//	//shapexml:codec
+//	type Person struct {
+//	    ID   string `xml:"id,attr"`
+//	    Name string `xml:"name"`
+//	}
+//
+// For each annotated struct the generated code has value-receiver methods
+// AppendXMLElement and MarshalXML, which Marshal picks up through
+// xml.ElementMarshaler and xml.Marshaler, and a pointer-receiver
+// UnmarshalXML, which Unmarshal picks up for the value it is given.
+//
+// The generated code writes what Marshal writes with default options and
+// reads what Unmarshal reads, for the subset of struct tags it supports:
+// element names, attr, chardata, omitempty and "-", and XMLName fields.
+// Fields may be strings, booleans, integers, floats, named types of those
+// declared in the package, annotated structs, and pointers and slices of
+// any of these other than []byte. Embedded structs must have a tag.
+// Anything else is reported as an error rather than generated, so the
+// reflection path never silently differs.
+package codegen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Annotation marks a struct type for code generation when it appears on a
+// line of its own in the type's doc comment.
+const Annotation = "//shapexml:codec"
+
+// DefaultOutput is the name of the generated file in the package directory.
+const DefaultOutput = "shapexml_codec.go"
+
+// kind classifies a field type.
+type kind int
+
+const (
+	kindScalar kind = iota
+	kindStruct
+	kindPtr
+	kindSlice
+)
+
+// fieldType describes the Go type of a field.
+type fieldType struct {
+	kind kind
+	// expr is the type as written in Go source, e.g. "[]*Item".
+	expr string
+	// basic is the predeclared type underlying a scalar, e.g. "int64".
+	basic string
+	// elem is the element type of a pointer or slice.
+	elem *fieldType
+}
+
+// field is an attribute, chardata or child element field.
+type field struct {
+	goName    string
+	xmlName   string
+	omitEmpty bool
+	typ       *fieldType
+	// method is set for computed attributes, goName being the method.
+	method bool
+}
+
+// structInfo is an annotated struct.
+type structInfo struct {
+	name string
+	// xmlName is set if the struct has an XMLName field; xmlTag is the
+	// element name in its tag, if any. xmlNameValue is set if the field
+	// is a Name, which holds the element name.
+	xmlName      bool
+	xmlTag       string
+	xmlNameValue bool
+	attrs        []field
+	text         *field
+	kids         []field
+	// hidden holds attribute fields replaced by computed attributes. They
+	// are not written but still receive the decoded value.
+	hidden []field
+}
+
+// pkgInfo holds the declarations of the package being generated.
+type pkgInfo struct {
+	name string
+	// types maps the names of package-level types to their declarations.
+	types map[string]ast.Expr
+	// annotated lists the annotated structs in source order.
+	annotated []string
+	// computed maps struct names to their XMLAttr_ methods.
+	computed map[string][]string
+	// structs caches the analysed annotated structs.
+	structs map[string]*structInfo
+}
+
+// Generate parses the Go package in dir, skipping test files and the
+// previously generated file output, and returns the source of the new
+// generated file. It returns nil if no struct is annotated.
+func Generate(dir, output string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != output
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("shapexml-gen: %s: want one package, found %d", dir, len(pkgs))
+	}
+	var files []*ast.File
+	var pkgName string
+	for name, pkg := range pkgs {
+		pkgName = name
+		names := make([]string, 0, len(pkg.Files))
+		for fn := range pkg.Files {
+			names = append(names, fn)
+		}
+		sort.Strings(names)
+		for _, fn := range names {
+			files = append(files, pkg.Files[fn])
+		}
+	}
+	return generateFiles(pkgName, files)
+}
+
+// GenerateSource is like Generate for a single file's source, which is
+// useful in tests.
+func GenerateSource(src string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	return generateFiles(f.Name.Name, []*ast.File{f})
+}
+
+func generateFiles(pkgName string, files []*ast.File) ([]byte, error) {
+	p := &pkgInfo{
+		name:     pkgName,
+		types:    make(map[string]ast.Expr),
+		computed: make(map[string][]string),
+		structs:  make(map[string]*structInfo),
+	}
+	for _, f := range files {
+		p.collect(f)
+	}
+	if len(p.annotated) == 0 {
+		return nil, nil
+	}
+	var structs []*structInfo
+	for _, name := range p.annotated {
+		s, err := p.analyse(name)
+		if err != nil {
+			return nil, err
+		}
+		structs = append(structs, s)
+	}
+	return emit(pkgName, structs)
+}
+
+// collect records the type declarations, annotations and computed
+// attribute methods of f.
+func (p *pkgInfo) collect(f *ast.File) {
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				p.types[ts.Name.Name] = ts.Type
+				doc := ts.Doc
+				if doc == nil && len(d.Specs) == 1 {
+					doc = d.Doc
+				}
+				if _, ok := ts.Type.(*ast.StructType); ok && hasAnnotation(doc) {
+					p.annotated = append(p.annotated, ts.Name.Name)
+				}
+			}
+		case *ast.FuncDecl:
+			if d.Recv == nil || len(d.Recv.List) != 1 || !strings.HasPrefix(d.Name.Name, "XMLAttr_") {
+				continue
+			}
+			// Only value receivers count, as for Marshal.
+			if recv, ok := d.Recv.List[0].Type.(*ast.Ident); ok {
+				p.computed[recv.Name] = append(p.computed[recv.Name], d.Name.Name)
+			}
+		}
+	}
+}
+
+// hasAnnotation reports whether doc holds the annotation line.
+func hasAnnotation(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == Annotation {
+			return true
+		}
+	}
+	return false
+}
+
+// analyse returns the fields of annotated struct name.
+func (p *pkgInfo) analyse(name string) (*structInfo, error) {
+	if s, ok := p.structs[name]; ok {
+		return s, nil
+	}
+	st := p.types[name].(*ast.StructType)
+	s := &structInfo{name: name}
+	p.structs[name] = s
+
+	for _, f := range st.Fields.List {
+		names := f.Names
+		if len(names) == 0 {
+			// An embedded field is named after its type.
+			id := embeddedName(f.Type)
+			if id == nil {
+				return nil, fmt.Errorf("shapexml-gen: %s: unsupported embedded field %s", name, exprString(f.Type))
+			}
+			names = []*ast.Ident{id}
+		}
+		tag := ""
+		if f.Tag != nil {
+			raw, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, fmt.Errorf("shapexml-gen: %s: bad tag %s", name, f.Tag.Value)
+			}
+			tag = reflect.StructTag(raw).Get("xml")
+		}
+		if len(f.Names) == 0 && tag == "" && p.isStruct(names[0].Name) {
+			// Marshal promotes the fields of an untagged embedded struct.
+			return nil, fmt.Errorf("shapexml-gen: %s: embedded struct %s is not supported", name, exprString(f.Type))
+		}
+		for _, id := range names {
+			if !id.IsExported() || tag == "-" {
+				continue
+			}
+			if err := p.addField(s, id.Name, tag, f.Type); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// A computed attribute replaces an attribute field of the same name.
+	methods := p.computed[name]
+	sort.Strings(methods)
+	for _, m := range methods {
+		attr := strings.TrimPrefix(m, "XMLAttr_")
+		kept := s.attrs[:0]
+		for _, a := range s.attrs {
+			if a.xmlName != attr {
+				kept = append(kept, a)
+			} else if !a.method {
+				s.hidden = append(s.hidden, a)
+			}
+		}
+		s.attrs = append(kept, field{goName: m, xmlName: attr, method: true})
+	}
+	sort.SliceStable(s.attrs, func(i, j int) bool { return s.attrs[i].xmlName < s.attrs[j].xmlName })
+	return s, nil
+}
+
+// addField adds the field goName with the given xml tag and type to s.
+func (p *pkgInfo) addField(s *structInfo, goName, tag string, typeExpr ast.Expr) error {
+	errorf := func(format string, args ...interface{}) error {
+		return fmt.Errorf("shapexml-gen: %s.%s: %s", s.name, goName, fmt.Sprintf(format, args...))
+	}
+
+	parts := strings.Split(tag, ",")
+	xmlName := parts[0]
+	if strings.ContainsAny(xmlName, " >") {
+		return errorf("namespaces and a>b paths are not supported")
+	}
+	var attr, text, omitEmpty bool
+	for _, opt := range parts[1:] {
+		switch strings.TrimSpace(opt) {
+		case "attr":
+			attr = true
+		case "chardata":
+			text = true
+		case "omitempty":
+			omitEmpty = true
+		case "":
+		default:
+			return errorf("option %q is not supported", opt)
+		}
+	}
+
+	if goName == "XMLName" {
+		if attr || text {
+			return errorf("XMLName must name an element")
+		}
+		if xmlName != "" && !isName(xmlName) {
+			return errorf("invalid element name %q", xmlName)
+		}
+		sel, ok := typeExpr.(*ast.SelectorExpr)
+		s.xmlName = true
+		s.xmlTag = xmlName
+		s.xmlNameValue = ok && sel.Sel.Name == "Name"
+		return nil
+	}
+
+	ft, err := p.resolve(typeExpr)
+	if err != nil {
+		return errorf("%v", err)
+	}
+	if xmlName == "" {
+		xmlName = goName
+		if tagName := p.xmlTagOf(ft); tagName != "" {
+			// The XMLName tag of the field's type names its element.
+			xmlName = tagName
+		}
+	} else if tagName := p.xmlTagOf(ft); tagName != "" && tagName != xmlName {
+		return errorf("name %q conflicts with name %q in the XMLName tag of its type", xmlName, tagName)
+	}
+	if !text && !isName(xmlName) {
+		return errorf("invalid name %q", xmlName)
+	}
+	f := field{goName: goName, xmlName: xmlName, omitEmpty: omitEmpty, typ: ft}
+
+	switch {
+	case attr || text:
+		if t := ft; t.kind != kindScalar && !(t.kind == kindPtr && t.elem.kind == kindScalar) {
+			return errorf("attributes and chardata need a scalar type, not %s", ft.expr)
+		}
+		if attr {
+			s.attrs = append(s.attrs, f)
+		} else {
+			s.text = &f
+		}
+	default:
+		s.kids = append(s.kids, f)
+	}
+	return nil
+}
+
+// isName reports whether s is a valid XML name.
+func isName(s string) bool {
+	for i, r := range s {
+		start := r == '_' || r == ':' || unicode.IsLetter(r)
+		if i == 0 && !start || !start && r != '-' && r != '.' && r != 0xB7 && !unicode.IsDigit(r) &&
+			!unicode.Is(unicode.Mn, r) && !unicode.Is(unicode.Mc, r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// xmlTagOf returns the XMLName tag name of the struct ft holds, if any.
+func (p *pkgInfo) xmlTagOf(ft *fieldType) string {
+	for ft.kind == kindPtr || ft.kind == kindSlice {
+		ft = ft.elem
+	}
+	if ft.kind != kindStruct {
+		return ""
+	}
+	if s, ok := p.structs[ft.expr]; ok && s.xmlName {
+		return s.xmlTag
+	}
+	// Not analysed yet: look for the XMLName field directly.
+	for _, f := range p.types[ft.expr].(*ast.StructType).Fields.List {
+		for _, id := range f.Names {
+			if id.Name == "XMLName" && f.Tag != nil {
+				raw, _ := strconv.Unquote(f.Tag.Value)
+				return strings.Split(reflect.StructTag(raw).Get("xml"), ",")[0]
+			}
+		}
+	}
+	return ""
+}
+
+// basicTypes are the predeclared types a scalar field may have.
+var basicTypes = map[string]bool{
+	"string": true, "bool": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"byte": true, "rune": true, "float32": true, "float64": true,
+}
+
+// resolve describes the type expression e.
+func (p *pkgInfo) resolve(e ast.Expr) (*fieldType, error) {
+	switch t := e.(type) {
+	case *ast.Ident:
+		if basicTypes[t.Name] {
+			basic := t.Name
+			switch basic {
+			case "byte":
+				basic = "uint8"
+			case "rune":
+				basic = "int32"
+			}
+			return &fieldType{kind: kindScalar, expr: t.Name, basic: basic}, nil
+		}
+		decl, ok := p.types[t.Name]
+		if !ok {
+			return nil, fmt.Errorf("type %s is not supported", t.Name)
+		}
+		if _, ok := decl.(*ast.StructType); ok {
+			if !p.isAnnotated(t.Name) {
+				return nil, fmt.Errorf("struct %s needs the %s annotation too", t.Name, Annotation)
+			}
+			return &fieldType{kind: kindStruct, expr: t.Name}, nil
+		}
+		// A named scalar type such as `type Status string`.
+		if id, ok := decl.(*ast.Ident); ok && basicTypes[id.Name] {
+			under, err := p.resolve(id)
+			if err != nil {
+				return nil, err
+			}
+			return &fieldType{kind: kindScalar, expr: t.Name, basic: under.basic}, nil
+		}
+		return nil, fmt.Errorf("type %s is not supported", t.Name)
+	case *ast.StarExpr:
+		elem, err := p.resolve(t.X)
+		if err != nil {
+			return nil, err
+		}
+		if elem.kind == kindPtr {
+			return nil, fmt.Errorf("type %s is not supported", exprString(e))
+		}
+		return &fieldType{kind: kindPtr, expr: "*" + elem.expr, elem: elem}, nil
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return nil, fmt.Errorf("type %s is not supported", exprString(e))
+		}
+		elem, err := p.resolve(t.Elt)
+		if err != nil {
+			return nil, err
+		}
+		if elem.kind == kindScalar && elem.basic == "uint8" {
+			// Marshal writes a []byte as text, not as a list.
+			return nil, fmt.Errorf("type %s is not supported", exprString(e))
+		}
+		return &fieldType{kind: kindSlice, expr: "[]" + elem.expr, elem: elem}, nil
+	}
+	return nil, fmt.Errorf("type %s is not supported", exprString(e))
+}
+
+// isAnnotated reports whether the struct name is annotated.
+func (p *pkgInfo) isAnnotated(name string) bool {
+	for _, n := range p.annotated {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isStruct reports whether name is a struct type declared in the package.
+func (p *pkgInfo) isStruct(name string) bool {
+	_, ok := p.types[name].(*ast.StructType)
+	return ok
+}
+
+// embeddedName returns the name of an embedded field of type e.
+func embeddedName(e ast.Expr) *ast.Ident {
+	switch t := e.(type) {
+	case *ast.Ident:
+		return t
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	}
+	return nil
+}
+
+// exprString formats a type expression for error messages.
+func exprString(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return "*" + exprString(t.X)
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "[]" + exprString(t.Elt)
+		}
+		return "[...]" + exprString(t.Elt)
+	case *ast.SelectorExpr:
+		return exprString(t.X) + "." + t.Sel.Name
+	case *ast.MapType:
+		return "map[" + exprString(t.Key) + "]" + exprString(t.Value)
+	case *ast.InterfaceType:
+		return "interface{}"
+	}
+	return fmt.Sprintf("%T", e)
+}
@@ -0,0 +1,68 @@
+package codegen
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate_Golden(t *testing.T) {
+	dir := filepath.Join("codectest")
+	got, err := Generate(dir, DefaultOutput)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	want, err := os.ReadFile(filepath.Join(dir, DefaultOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s is stale; run go generate ./internal/codegen/codectest", DefaultOutput)
+	}
+}
+
+func TestGenerateSource_NoAnnotation(t *testing.T) {
+	src, err := GenerateSource("package p\n\ntype T struct{ A int }\n")
+	if err != nil || src != nil {
+		t.Errorf("GenerateSource = %q, %v; want nil, nil", src, err)
+	}
+}
+
+func TestGenerateSource_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"namespace", "A string `xml:\"urn:x a\"`", "namespaces"},
+		{"path", "A string `xml:\"a>b\"`", "a>b paths"},
+		{"option", "A string `xml:\",innerxml\"`", `option "innerxml"`},
+		{"map", "A map[string]string", "not supported"},
+		{"selector", "A time.Time", "not supported"},
+		{"attr struct", "A U `xml:\"a,attr\"`", "need a scalar type"},
+		{"not annotated", "A V", "struct V needs the " + Annotation},
+		{"name conflict", "A W `xml:\"a\"`", `conflicts with name "w"`},
+		{"embedded", "fmt.Stringer", "unsupported embedded field"},
+		{"embedded struct", "U", "embedded struct U is not supported"},
+		{"embedded struct pointer", "*U", "embedded struct *U is not supported"},
+		{"bytes", "A []byte", "type []byte is not supported"},
+		{"element name", "A string `xml:\"a<b\"`", `invalid name "a<b"`},
+		{"attr name", "A string `xml:\"1a,attr\"`", `invalid name "1a"`},
+		{"XMLName", "XMLName xml.Name `xml:\"a&\"`", `invalid element name "a&"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "package p\n\n" +
+				Annotation + "\ntype T struct {\n" + tt.body + "\n}\n\n" +
+				Annotation + "\ntype U struct{}\n\n" +
+				"type V struct{}\n\n" +
+				Annotation + "\ntype W struct {\nXMLName xml.Name `xml:\"w\"`\n}\n"
+			_, err := GenerateSource(src)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
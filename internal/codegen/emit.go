@@ -0,0 +1,678 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// runtimeImport is the import path of the package generated code calls.
+const runtimeImport = "github.com/shapestone/shape-xml/pkg/xml"
+
+// emitter writes the generated file.
+type emitter struct {
+	buf     bytes.Buffer
+	imports map[string]bool
+	// tmp numbers the temporary variables of the current method.
+	tmp int
+}
+
+func (e *emitter) line(format string, args ...interface{}) {
+	fmt.Fprintf(&e.buf, format, args...)
+	e.buf.WriteByte('\n')
+}
+
+func (e *emitter) use(path string) {
+	e.imports[path] = true
+}
+
+// newVar returns a fresh variable name with the given prefix.
+func (e *emitter) newVar(prefix string) string {
+	e.tmp++
+	return prefix + strconv.Itoa(e.tmp)
+}
+
+// emit returns the formatted source of the generated file.
+func emit(pkgName string, structs []*structInfo) ([]byte, error) {
+	e := &emitter{imports: map[string]bool{runtimeImport: true}}
+	for _, s := range structs {
+		e.marshal(s)
+		e.unmarshal(s)
+	}
+	e.helpers()
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by shapexml-gen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkgName)
+	paths := make([]string, 0, len(e.imports))
+	for path := range e.imports {
+		if path != runtimeImport {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+	fmt.Fprintf(&out, "\n\tshapexml %q\n)\n\n", runtimeImport)
+	out.Write(e.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("shapexml-gen: formatting generated code: %v", err)
+	}
+	return src, nil
+}
+
+// ---------- Encoding ----------
+
+// marshal writes the AppendXMLElement and MarshalXML methods of s.
+func (e *emitter) marshal(s *structInfo) {
+	e.tmp = 0
+	e.line("// AppendXMLElement appends the XML encoding of v to buf as an element")
+	e.line("// named name. It implements xml.ElementMarshaler.")
+	e.line("func (v %s) AppendXMLElement(buf []byte, name string) ([]byte, error) {", s.name)
+	if s.xmlName {
+		if s.xmlTag != "" {
+			e.line("name = %q", s.xmlTag)
+		} else if s.xmlNameValue {
+			e.line("if v.XMLName.Local != \"\" {")
+			e.line("name = v.XMLName.Local")
+			e.line("}")
+		}
+	}
+	e.line("buf = append(buf, '<')")
+	e.line("buf = append(buf, name...)")
+	for _, a := range s.attrs {
+		e.attr(a)
+	}
+
+	// The element is self-closing when it has no content.
+	var content []string
+	if s.text != nil {
+		e.line("var text string")
+		e.scalarText(s.text, "text")
+		content = append(content, `text != ""`)
+	}
+	for _, k := range s.kids {
+		// Nil pointers and empty slices write nothing.
+		if !k.omitEmpty && k.typ.kind == kindScalar || k.typ.kind == kindStruct {
+			content = nil
+			content = append(content, "true")
+			break
+		}
+		content = append(content, notEmpty(k.typ, "v."+k.goName))
+	}
+	if len(content) == 0 {
+		e.line("return append(buf, '/', '>'), nil")
+		e.line("}")
+		e.line("")
+	} else {
+		if content[0] != "true" {
+			e.line("if !(%s) {", strings.Join(content, " || "))
+			e.line("return append(buf, '/', '>'), nil")
+			e.line("}")
+		}
+		e.line("buf = append(buf, '>')")
+		if s.text != nil {
+			e.line("buf = shapexml.AppendEscapedText(buf, text)")
+		}
+		needErr := false
+		for _, k := range s.kids {
+			if needsErr(k.typ) {
+				needErr = true
+			}
+		}
+		if needErr {
+			e.line("var err error")
+		}
+		for _, k := range s.kids {
+			expr := "v." + k.goName
+			// A nil pointer is left out, as Marshal does, so that it
+			// decodes back to nil.
+			if (k.omitEmpty || k.typ.kind == kindPtr) && k.typ.kind != kindStruct {
+				e.line("if %s {", notEmpty(k.typ, expr))
+				e.nonNilElement(k.typ, expr, k.xmlName)
+				e.line("}")
+			} else {
+				e.element(k.typ, expr, k.xmlName)
+			}
+		}
+		e.line("buf = append(buf, \"</\"...)")
+		e.line("buf = append(buf, name...)")
+		e.line("return append(buf, '>'), nil")
+		e.line("}")
+		e.line("")
+	}
+
+	e.line("// MarshalXML returns the XML encoding of v as the root element.")
+	e.line("// It implements xml.Marshaler.")
+	e.line("func (v %s) MarshalXML() ([]byte, error) {", s.name)
+	e.line("return v.AppendXMLElement(nil, %q)", s.name)
+	e.line("}")
+	e.line("")
+}
+
+// attr writes attribute a, leaving it out if its text is empty.
+func (e *emitter) attr(a field) {
+	prefix := strconv.Quote(" " + a.xmlName + `="`)
+	if a.method {
+		v := e.newVar("s")
+		e.line("if %s := v.%s(); %s != \"\" {", v, a.goName, v)
+		e.line("buf = append(buf, %s...)", prefix)
+		e.line("buf = shapexml.AppendEscapedAttr(buf, %s)", v)
+		e.line("buf = append(buf, '\"')")
+		e.line("}")
+		return
+	}
+	expr := "v." + a.goName
+	var cond []string
+	if a.omitEmpty {
+		cond = append(cond, notEmpty(a.typ, expr))
+	}
+	t := a.typ
+	if t.kind == kindPtr {
+		if !a.omitEmpty {
+			cond = append(cond, expr+" != nil")
+		}
+		expr = "*" + expr
+		t = t.elem
+	}
+	if t.basic == "string" && (!a.omitEmpty || a.typ.kind == kindPtr) {
+		// Empty strings are left out even without omitempty.
+		cond = append(cond, notEmpty(t, expr))
+	}
+	if len(cond) > 0 {
+		e.line("if %s {", strings.Join(cond, " && "))
+	}
+	e.line("buf = append(buf, %s...)", prefix)
+	switch t.basic {
+	case "string":
+		e.line("buf = shapexml.AppendEscapedAttr(buf, string(%s))", expr)
+	case "float32", "float64":
+		v := e.newVar("f")
+		e.line("%s := %s", v, e.format(t, expr))
+		e.line("if %s.err != nil {", v)
+		e.line("return buf, %s.err", v)
+		e.line("}")
+		e.line("buf = append(buf, %s.s...)", v)
+	default:
+		e.line("buf = %s", appendScalar(t, expr))
+	}
+	e.line("buf = append(buf, '\"')")
+	if len(cond) > 0 {
+		e.line("}")
+	}
+}
+
+// scalarText assigns the text of the chardata field f to variable dst.
+func (e *emitter) scalarText(f *field, dst string) {
+	expr := "v." + f.goName
+	t := f.typ
+	var cond []string
+	if f.omitEmpty {
+		cond = append(cond, notEmpty(t, expr))
+	}
+	if t.kind == kindPtr {
+		cond = append(cond, expr+" != nil")
+		expr = "*" + expr
+		t = t.elem
+	}
+	if len(cond) > 0 {
+		e.line("if %s {", strings.Join(cond, " && "))
+	}
+	if t.basic == "float32" || t.basic == "float64" {
+		v := e.newVar("f")
+		e.line("%s := %s", v, e.format(t, expr))
+		e.line("if %s.err != nil {", v)
+		e.line("return buf, %s.err", v)
+		e.line("}")
+		e.line("%s = %s.s", dst, v)
+	} else {
+		e.line("%s = %s", dst, e.format(t, expr))
+	}
+	if len(cond) > 0 {
+		e.line("}")
+	}
+}
+
+// element writes expr, of type t, as elements named name.
+func (e *emitter) element(t *fieldType, expr, name string) {
+	switch t.kind {
+	case kindScalar:
+		open := strconv.Quote("<" + name + ">")
+		end := strconv.Quote("</" + name + ">")
+		if t.basic == "float32" || t.basic == "float64" {
+			v := e.newVar("f")
+			e.line("%s := %s", v, e.format(t, expr))
+			e.line("if %s.err != nil {", v)
+			e.line("return buf, %s.err", v)
+			e.line("}")
+			e.line("buf = append(buf, %s...)", open)
+			e.line("buf = append(buf, %s.s...)", v)
+		} else {
+			e.line("buf = append(buf, %s...)", open)
+			if t.basic == "string" {
+				e.line("buf = shapexml.AppendEscapedText(buf, string(%s))", expr)
+			} else {
+				e.line("buf = %s", appendScalar(t, expr))
+			}
+		}
+		e.line("buf = append(buf, %s...)", end)
+	case kindStruct:
+		// Methods of a struct are called through pointers directly.
+		expr = strings.TrimPrefix(expr, "*")
+		e.line("if buf, err = %s.AppendXMLElement(buf, %q); err != nil {", expr, name)
+		e.line("return buf, err")
+		e.line("}")
+	case kindPtr:
+		e.line("if %s == nil {", expr)
+		e.line("buf = append(buf, %s...)", strconv.Quote("<"+name+"/>"))
+		e.line("} else {")
+		e.element(t.elem, "*"+expr, name)
+		e.line("}")
+	case kindSlice:
+		item := e.newVar("item")
+		e.line("for _, %s := range %s {", item, expr)
+		e.element(t.elem, item, name)
+		e.line("}")
+	}
+}
+
+// nonNilElement is like element for an expr known not to be nil.
+func (e *emitter) nonNilElement(t *fieldType, expr, name string) {
+	switch t.kind {
+	case kindPtr:
+		e.element(t.elem, "*"+expr, name)
+	case kindSlice:
+		item := e.newVar("item")
+		e.line("for _, %s := range %s {", item, expr)
+		e.element(t.elem, item, name)
+		e.line("}")
+	default:
+		e.element(t, expr, name)
+	}
+}
+
+// format returns an expression for the text of scalar expr. For floats it
+// is a shapexmlFloat holding the text and the error for NaN and infinity.
+func (e *emitter) format(t *fieldType, expr string) string {
+	switch {
+	case t.basic == "string":
+		return "string(" + expr + ")"
+	case t.basic == "bool":
+		e.use("strconv")
+		return "strconv.FormatBool(bool(" + expr + "))"
+	case strings.HasPrefix(t.basic, "int"):
+		e.use("strconv")
+		return "strconv.FormatInt(int64(" + expr + "), 10)"
+	case strings.HasPrefix(t.basic, "uint"):
+		e.use("strconv")
+		return "strconv.FormatUint(uint64(" + expr + "), 10)"
+	default:
+		return fmt.Sprintf("shapexmlFormatFloat(float64(%s), %s)", expr, bitSize(t.basic))
+	}
+}
+
+// appendScalar returns an expression appending the text of a bool or
+// integer expr to buf.
+func appendScalar(t *fieldType, expr string) string {
+	switch {
+	case t.basic == "bool":
+		return "strconv.AppendBool(buf, bool(" + expr + "))"
+	case strings.HasPrefix(t.basic, "int"):
+		return "strconv.AppendInt(buf, int64(" + expr + "), 10)"
+	default:
+		return "strconv.AppendUint(buf, uint64(" + expr + "), 10)"
+	}
+}
+
+// notEmpty returns a condition that holds unless expr is empty for
+// omitempty.
+func notEmpty(t *fieldType, expr string) string {
+	switch t.kind {
+	case kindPtr:
+		return expr + " != nil"
+	case kindSlice:
+		return "len(" + expr + ") != 0"
+	case kindStruct:
+		return "true"
+	}
+	switch {
+	case t.basic == "string":
+		return expr + ` != ""`
+	case t.basic == "bool":
+		return "bool(" + expr + ")"
+	default:
+		return expr + " != 0"
+	}
+}
+
+// needsErr reports whether encoding an element of type t assigns err.
+func needsErr(t *fieldType) bool {
+	for t.kind == kindPtr || t.kind == kindSlice {
+		t = t.elem
+	}
+	return t.kind == kindStruct
+}
+
+// bitSize returns the size in bits of a numeric basic type.
+func bitSize(basic string) string {
+	switch basic {
+	case "int", "uint":
+		return "strconv.IntSize"
+	case "float32":
+		return "32"
+	case "float64":
+		return "64"
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(basic, "u"), "int")
+}
+
+// ---------- Decoding ----------
+
+// unmarshal writes the UnmarshalXML and decodeXMLElement methods of s.
+func (e *emitter) unmarshal(s *structInfo) {
+	e.tmp = 0
+	e.use("bytes")
+	e.line("// UnmarshalXML decodes the root element of data into v. It implements")
+	e.line("// xml.Unmarshaler.")
+	e.line("func (v *%s) UnmarshalXML(data []byte) error {", s.name)
+	e.line("d := shapexml.NewDecoder(bytes.NewReader(data))")
+	e.line("start, err := shapexmlRoot(d)")
+	e.line("if err != nil {")
+	e.line("return err")
+	e.line("}")
+	e.line("return v.decodeXMLElement(d, start, \"/\"+start.Name)")
+	e.line("}")
+	e.line("")
+
+	e.line("// decodeXMLElement decodes the element start opened into v, reading up")
+	e.line("// to its end tag. path locates the element for errors.")
+	e.line("func (v *%s) decodeXMLElement(d *shapexml.Decoder, start shapexml.StartElement, path string) error {", s.name)
+	if s.xmlNameValue {
+		e.line("v.XMLName.Space, v.XMLName.Local = shapexmlElementName(start)")
+	}
+	// Computed attributes decode into the attribute field they replace.
+	var attrs []field
+	for _, a := range append(s.attrs, s.hidden...) {
+		if !a.method {
+			attrs = append(attrs, a)
+		}
+	}
+	if len(attrs) > 0 {
+		e.line("for _, a := range start.Attr {")
+		e.line("switch a.Name {")
+		for _, a := range attrs {
+			e.line("case %q:", a.xmlName)
+			e.parseAt(a.typ, "v."+a.goName, "a.Value", `path+"/@`+a.xmlName+`"`)
+		}
+		e.line("}")
+		e.line("}")
+	}
+	// A slice field starts over at its first element.
+	for _, k := range s.kids {
+		if k.typ.kind == kindSlice {
+			e.line("var n%s int", k.goName)
+		}
+	}
+	if s.text != nil {
+		e.line("var text []byte")
+	}
+	e.line("for {")
+	e.line("tok, err := d.Token()")
+	e.line("if err != nil {")
+	e.line("return err")
+	e.line("}")
+	if len(s.kids) > 0 || s.text != nil {
+		e.line("switch t := tok.(type) {")
+	} else {
+		e.line("switch tok.(type) {")
+	}
+	e.line("case shapexml.StartElement:")
+	if len(s.kids) > 0 {
+		e.line("switch t.Name {")
+		for _, k := range s.kids {
+			e.line("case %q:", k.xmlName)
+			if k.typ.kind != kindSlice {
+				e.decodeElement(k.typ, "v."+k.goName, `path+"/"+t.Name`)
+				continue
+			}
+			target := "v." + k.goName
+			item := e.newVar("item")
+			e.line("var %s %s", item, k.typ.elem.expr)
+			e.decodeElement(k.typ.elem, item, `path+"/"+t.Name`)
+			e.line("if n%s == 0 {", k.goName)
+			e.line("%s = nil", target)
+			e.line("}")
+			e.line("n%s++", k.goName)
+			e.line("%s = append(%s, %s)", target, target, item)
+		}
+		e.line("default:")
+		e.line("if err := d.Skip(); err != nil {")
+		e.line("return err")
+		e.line("}")
+		e.line("}")
+	} else {
+		e.line("if err := d.Skip(); err != nil {")
+		e.line("return err")
+		e.line("}")
+	}
+	if s.text != nil {
+		e.line("case shapexml.CharData:")
+		e.line("text = append(text, t...)")
+	}
+	e.line("case shapexml.EndElement:")
+	if s.text != nil {
+		e.use("strings")
+		e.line("s := strings.TrimSpace(string(text))")
+		e.parseAt(s.text.typ, "v."+s.text.goName, "s", "path")
+	}
+	e.line("return nil")
+	e.line("}")
+	e.line("}")
+	e.line("}")
+	e.line("")
+}
+
+// decodeElement decodes the element t just opened into target, of type ft.
+func (e *emitter) decodeElement(ft *fieldType, target, path string) {
+	switch ft.kind {
+	case kindScalar:
+		s := e.newVar("s")
+		e.line("%s, err := shapexmlText(d)", s)
+		e.line("if err != nil {")
+		e.line("return err")
+		e.line("}")
+		e.parseAt(ft, target, s, path)
+	case kindStruct:
+		target = strings.TrimPrefix(target, "*")
+		e.line("if err := %s.decodeXMLElement(d, t, %s); err != nil {", target, path)
+		e.line("return err")
+		e.line("}")
+	case kindPtr:
+		e.line("if %s == nil {", target)
+		e.line("%s = new(%s)", target, ft.elem.expr)
+		e.line("}")
+		e.decodeElement(ft.elem, "*"+target, path)
+	case kindSlice:
+		item := e.newVar("item")
+		e.line("var %s %s", item, ft.elem.expr)
+		e.decodeElement(ft.elem, item, path)
+		e.line("%s = append(%s, %s)", target, target, item)
+	}
+}
+
+// parseAt stores the text expr src into the scalar or pointer target,
+// reporting errors at path.
+func (e *emitter) parseAt(ft *fieldType, target, src, path string) {
+	if ft.kind == kindPtr {
+		e.line("if %s == nil {", target)
+		e.line("%s = new(%s)", target, ft.elem.expr)
+		e.line("}")
+		target = "*" + target
+		ft = ft.elem
+	}
+	if ft.basic == "string" {
+		e.line("%s = %s(%s)", target, ft.expr, src)
+		return
+	}
+	var call string
+	switch {
+	case ft.basic == "bool":
+		call = "shapexmlParseBool"
+	case strings.HasPrefix(ft.basic, "int"):
+		call = "shapexmlParseInt"
+	case strings.HasPrefix(ft.basic, "uint"):
+		call = "shapexmlParseUint"
+	default:
+		call = "shapexmlParseFloat"
+	}
+	n := e.newVar("n")
+	if ft.basic == "bool" {
+		e.line("%s, err := %s(%s, %q)", n, call, src, ft.expr)
+	} else {
+		e.use("strconv")
+		e.line("%s, err := %s(%s, %s, %q)", n, call, src, bitSize(ft.basic), ft.expr)
+	}
+	e.line("if err != nil {")
+	e.line("return &shapexml.UnmarshalError{Path: %s, Err: err}", path)
+	e.line("}")
+	e.line("%s = %s(%s)", target, ft.expr, n)
+}
+
+// ---------- Helpers ----------
+
+// helpers writes the unexported functions the generated methods share.
+func (e *emitter) helpers() {
+	e.use("errors")
+	e.use("fmt")
+	e.use("io")
+	e.use("math")
+	e.use("strconv")
+	e.use("strings")
+	e.buf.WriteString(helperSource)
+}
+
+const helperSource = `// shapexmlFloat is the text of a float, or the error for NaN and infinity,
+// which Marshal rejects by default.
+type shapexmlFloat struct {
+	s   string
+	err error
+}
+
+func shapexmlFormatFloat(f float64, bits int) shapexmlFloat {
+	s := strconv.FormatFloat(f, 'g', -1, bits)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return shapexmlFloat{err: &shapexml.UnsupportedValueError{Str: s}}
+	}
+	return shapexmlFloat{s: s}
+}
+
+// shapexmlRoot returns the start tag of the root element.
+func shapexmlRoot(d *shapexml.Decoder) (shapexml.StartElement, error) {
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return shapexml.StartElement{}, errors.New("xml: no root element")
+		}
+		if err != nil {
+			return shapexml.StartElement{}, err
+		}
+		if start, ok := tok.(shapexml.StartElement); ok {
+			return start, nil
+		}
+	}
+}
+
+// shapexmlText reads the text of the element just opened up to its end
+// tag, with surrounding whitespace removed. Nested elements are skipped.
+func shapexmlText(d *shapexml.Decoder) (string, error) {
+	var text []byte
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case shapexml.CharData:
+			text = append(text, t...)
+		case shapexml.StartElement:
+			if err := d.Skip(); err != nil {
+				return "", err
+			}
+		case shapexml.EndElement:
+			return strings.TrimSpace(string(text)), nil
+		}
+	}
+}
+
+// shapexmlElementName returns the namespace declared on start for its
+// prefix, and its local name.
+func shapexmlElementName(start shapexml.StartElement) (space, local string) {
+	prefix, local := shapexml.SplitName(start.Name)
+	attr := "xmlns"
+	if prefix != "" {
+		attr += ":" + prefix
+	}
+	for _, a := range start.Attr {
+		if a.Name == attr {
+			space = a.Value
+		}
+	}
+	return space, local
+}
+
+func shapexmlParseBool(s, typ string) (bool, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("xml: cannot parse %q as %s", s, typ)
+	}
+	return b, nil
+}
+
+func shapexmlParseInt(s string, bits int, typ string) (int64, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(s, 10, bits)
+	if err != nil {
+		return 0, shapexmlParseError(s, typ, err)
+	}
+	return n, nil
+}
+
+func shapexmlParseUint(s string, bits int, typ string) (uint64, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(s, 10, bits)
+	if err != nil {
+		return 0, shapexmlParseError(s, typ, err)
+	}
+	return n, nil
+}
+
+func shapexmlParseFloat(s string, bits int, typ string) (float64, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(s, bits)
+	if err != nil {
+		return 0, shapexmlParseError(s, typ, err)
+	}
+	return f, nil
+}
+
+func shapexmlParseError(s, typ string, err error) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf("xml: cannot parse %q as %s: %w", s, typ, strconv.ErrRange)
+	}
+	return fmt.Errorf("xml: cannot parse %q as %s", s, typ)
+}
+`
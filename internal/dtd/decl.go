@@ -0,0 +1,516 @@
+package dtd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ContentKind is the kind of content an element declaration allows.
+type ContentKind int
+
+const (
+	ContentEmpty    ContentKind = iota // EMPTY
+	ContentAny                         // ANY
+	ContentMixed                       // (#PCDATA | a | b)*
+	ContentChildren                    // a content model of child elements only
+)
+
+// ElementDecl is an <!ELEMENT> declaration.
+type ElementDecl struct {
+	Name    string
+	Content ContentKind
+	// Model is the content model of ContentChildren elements.
+	Model *Particle
+	// Mixed lists the child elements ContentMixed elements may hold.
+	Mixed []string
+}
+
+// Particle is a node of a content model: an element name, or a sequence
+// or choice of particles, with its occurrence indicator.
+type Particle struct {
+	// Name is the element name of a leaf particle.
+	Name string
+	// Kind is 0 for a leaf, ',' for a sequence and '|' for a choice.
+	Kind byte
+	// Items are the particles of a sequence or choice.
+	Items []*Particle
+	// Occur is 0, '?', '*' or '+'.
+	Occur byte
+}
+
+// AttDecl is one attribute definition of an <!ATTLIST> declaration.
+type AttDecl struct {
+	Name string
+	// Type is CDATA, ID, IDREF, IDREFS, ENTITY, ENTITIES, NMTOKEN, NMTOKENS
+	// or NOTATION, or "" for an enumeration.
+	Type string
+	// Enum holds the values allowed by an enumeration or NOTATION type.
+	Enum []string
+	// Default is #REQUIRED, #IMPLIED, #FIXED, or "" for an attribute with a
+	// plain default value.
+	Default string
+	// Value is the default or fixed value, with character references
+	// expanded.
+	Value string
+}
+
+// errParamRef reports a parameter entity reference within a declaration.
+// Parameter entities are not expanded, so such declarations are skipped.
+var errParamRef = errors.New("parameter entity reference")
+
+// Allows reports whether an element with the child elements names, in
+// document order, matches the declaration. Character data is checked by
+// the caller: only ContentMixed and ContentAny elements may hold any.
+func (e *ElementDecl) Allows(names []string) bool {
+	switch e.Content {
+	case ContentEmpty:
+		return len(names) == 0
+	case ContentMixed:
+		for _, name := range names {
+			if !contains(e.Mixed, name) {
+				return false
+			}
+		}
+		return true
+	case ContentChildren:
+		start := make([]bool, len(names)+1)
+		start[0] = true
+		return e.Model.match(names, start)[len(names)]
+	}
+	return true
+}
+
+// String returns the content specification as declared, e.g. "(a, b*)".
+func (e *ElementDecl) String() string {
+	switch e.Content {
+	case ContentEmpty:
+		return "EMPTY"
+	case ContentAny:
+		return "ANY"
+	case ContentMixed:
+		if len(e.Mixed) == 0 {
+			return "(#PCDATA)"
+		}
+		return "(#PCDATA | " + strings.Join(e.Mixed, " | ") + ")*"
+	}
+	return e.Model.String()
+}
+
+func (p *Particle) String() string {
+	s := p.Name
+	if p.Kind != 0 {
+		items := make([]string, len(p.Items))
+		for i, item := range p.Items {
+			items[i] = item.String()
+		}
+		sep := ", "
+		if p.Kind == '|' {
+			sep = " | "
+		}
+		s = "(" + strings.Join(items, sep) + ")"
+	}
+	if p.Occur != 0 {
+		s += string(p.Occur)
+	}
+	return s
+}
+
+// match returns the positions in names at which p can end when it starts
+// at any of the positions set in start.
+func (p *Particle) match(names []string, start []bool) []bool {
+	switch p.Occur {
+	case '?':
+		return union(start, p.matchOnce(names, start))
+	case '*', '+':
+		reached := p.matchOnce(names, start)
+		all := union(nil, reached)
+		for {
+			next := p.matchOnce(names, reached)
+			added := false
+			for i, ok := range next {
+				if ok && !all[i] {
+					all[i] = true
+					added = true
+				} else {
+					next[i] = false
+				}
+			}
+			if !added {
+				break
+			}
+			reached = next
+		}
+		if p.Occur == '*' {
+			all = union(all, start)
+		}
+		return all
+	}
+	return p.matchOnce(names, start)
+}
+
+// matchOnce is match for a single occurrence of p.
+func (p *Particle) matchOnce(names []string, start []bool) []bool {
+	switch p.Kind {
+	case ',':
+		cur := start
+		for _, item := range p.Items {
+			cur = item.match(names, cur)
+		}
+		return cur
+	case '|':
+		var all []bool
+		for _, item := range p.Items {
+			all = union(all, item.match(names, start))
+		}
+		return all
+	}
+	end := make([]bool, len(start))
+	for i, ok := range start {
+		if ok && i < len(names) && names[i] == p.Name {
+			end[i+1] = true
+		}
+	}
+	return end
+}
+
+// union returns the positions set in a or b, reusing a.
+func union(a, b []bool) []bool {
+	if a == nil {
+		a = make([]bool, len(b))
+	}
+	for i, ok := range b {
+		if ok {
+			a[i] = true
+		}
+	}
+	return a
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// declaration parses the rest of a declaration with parse, which reads it
+// after its keyword. Declarations using parameter entity references are
+// skipped.
+func (p *scanner) declaration(d *Doctype, parse func(*Doctype) error) error {
+	start := p.pos
+	err := parse(d)
+	if errors.Is(err, errParamRef) {
+		p.pos = start
+		return p.skipDecl()
+	}
+	return err
+}
+
+// elementDecl parses the rest of an element declaration after "<!ELEMENT".
+// The first declaration of an element is binding.
+func (p *scanner) elementDecl(d *Doctype) error {
+	if !p.space() {
+		return errors.New("expected space after <!ELEMENT")
+	}
+	decl := &ElementDecl{Name: p.token()}
+	if decl.Name == "" {
+		return p.declError("expected element name")
+	}
+	if !p.space() {
+		return p.declError("expected space after element name")
+	}
+	switch {
+	case p.consume("EMPTY"):
+		decl.Content = ContentEmpty
+	case p.consume("ANY"):
+		decl.Content = ContentAny
+	case p.consume("("):
+		p.space()
+		if p.consume("#PCDATA") {
+			if err := p.mixed(decl); err != nil {
+				return err
+			}
+			break
+		}
+		model, err := p.group()
+		if err != nil {
+			return err
+		}
+		decl.Content = ContentChildren
+		decl.Model = model
+	default:
+		return p.declError(fmt.Sprintf("expected content specification for element %q", decl.Name))
+	}
+	p.space()
+	if !p.consume(">") {
+		return p.declError(fmt.Sprintf("expected '>' to end declaration of element %q", decl.Name))
+	}
+	if _, ok := d.Elements[decl.Name]; ok {
+		return nil
+	}
+	if d.Elements == nil {
+		d.Elements = make(map[string]*ElementDecl)
+	}
+	d.Elements[decl.Name] = decl
+	return nil
+}
+
+// mixed parses a mixed content specification after "(#PCDATA".
+func (p *scanner) mixed(decl *ElementDecl) error {
+	decl.Content = ContentMixed
+	for {
+		p.space()
+		if p.consume(")") {
+			if len(decl.Mixed) > 0 && !p.consume("*") {
+				return p.declError("expected ')*' after mixed content with elements")
+			}
+			if len(decl.Mixed) == 0 {
+				p.consume("*")
+			}
+			return nil
+		}
+		if !p.consume("|") {
+			return p.declError("expected '|' or ')' in mixed content")
+		}
+		p.space()
+		name := p.token()
+		if name == "" {
+			return p.declError("expected element name in mixed content")
+		}
+		decl.Mixed = append(decl.Mixed, name)
+	}
+}
+
+// group parses a sequence or choice after its '(', and its occurrence
+// indicator.
+func (p *scanner) group() (*Particle, error) {
+	g := &Particle{}
+	for {
+		p.space()
+		item, err := p.particle()
+		if err != nil {
+			return nil, err
+		}
+		g.Items = append(g.Items, item)
+		p.space()
+		if p.consume(")") {
+			break
+		}
+		if p.pos >= len(p.src) {
+			return nil, errors.New("unterminated content model")
+		}
+		sep := p.src[p.pos]
+		if sep != ',' && sep != '|' {
+			return nil, p.declError(fmt.Sprintf("unexpected %q in content model", sep))
+		}
+		if g.Kind != 0 && g.Kind != sep {
+			return nil, p.declError("content model mixes ',' and '|'")
+		}
+		g.Kind = sep
+		p.pos++
+	}
+	if g.Kind == 0 {
+		// A single item in parentheses is a one-item sequence.
+		g.Kind = ','
+	}
+	g.Occur = p.occurrence()
+	return g, nil
+}
+
+// particle parses an element name or a nested group.
+func (p *scanner) particle() (*Particle, error) {
+	if p.consume("(") {
+		return p.group()
+	}
+	name := p.token()
+	if name == "" {
+		return nil, p.declError("expected element name in content model")
+	}
+	return &Particle{Name: name, Occur: p.occurrence()}, nil
+}
+
+// occurrence reads an optional '?', '*' or '+'.
+func (p *scanner) occurrence() byte {
+	if p.pos < len(p.src) {
+		switch c := p.src[p.pos]; c {
+		case '?', '*', '+':
+			p.pos++
+			return c
+		}
+	}
+	return 0
+}
+
+// attlistDecl parses the rest of an attribute-list declaration after
+// "<!ATTLIST". The first definition of an attribute is binding.
+func (p *scanner) attlistDecl(d *Doctype) error {
+	if !p.space() {
+		return errors.New("expected space after <!ATTLIST")
+	}
+	element := p.token()
+	if element == "" {
+		return p.declError("expected element name")
+	}
+	var defs []*AttDecl
+	for {
+		p.space()
+		if p.consume(">") {
+			break
+		}
+		def, err := p.attDef()
+		if err != nil {
+			return err
+		}
+		defs = append(defs, def)
+	}
+	if d.Attributes == nil {
+		d.Attributes = make(map[string][]*AttDecl)
+	}
+	for _, def := range defs {
+		if d.Attribute(element, def.Name) == nil {
+			d.Attributes[element] = append(d.Attributes[element], def)
+		}
+	}
+	return nil
+}
+
+// attDef parses one attribute definition: Name S AttType S DefaultDecl.
+func (p *scanner) attDef() (*AttDecl, error) {
+	def := &AttDecl{Name: p.token()}
+	if def.Name == "" {
+		return nil, p.declError("expected attribute name")
+	}
+	if !p.space() {
+		return nil, p.declError(fmt.Sprintf("expected type of attribute %q", def.Name))
+	}
+	switch {
+	case p.consume("("):
+		enum, err := p.enumeration()
+		if err != nil {
+			return nil, err
+		}
+		def.Enum = enum
+	case p.consume("NOTATION"):
+		p.space()
+		if !p.consume("(") {
+			return nil, p.declError("expected '(' after NOTATION")
+		}
+		enum, err := p.enumeration()
+		if err != nil {
+			return nil, err
+		}
+		def.Type, def.Enum = "NOTATION", enum
+	default:
+		def.Type = p.token()
+		switch def.Type {
+		case "CDATA", "ID", "IDREF", "IDREFS", "ENTITY", "ENTITIES", "NMTOKEN", "NMTOKENS":
+		default:
+			return nil, p.declError(fmt.Sprintf("unknown type %q of attribute %q", def.Type, def.Name))
+		}
+	}
+	if !p.space() {
+		return nil, p.declError(fmt.Sprintf("expected default of attribute %q", def.Name))
+	}
+	switch {
+	case p.consume("#REQUIRED"):
+		def.Default = "#REQUIRED"
+		return def, nil
+	case p.consume("#IMPLIED"):
+		def.Default = "#IMPLIED"
+		return def, nil
+	case p.consume("#FIXED"):
+		def.Default = "#FIXED"
+		p.space()
+	}
+	value, err := p.literal()
+	if err != nil {
+		return nil, p.declError(fmt.Sprintf("expected default value of attribute %q", def.Name))
+	}
+	if def.Value, err = expandCharRefs(value); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// enumeration parses the values of an enumerated type after its '('.
+func (p *scanner) enumeration() ([]string, error) {
+	var values []string
+	for {
+		p.space()
+		value := p.token()
+		if value == "" {
+			return nil, p.declError("expected value in enumeration")
+		}
+		values = append(values, value)
+		p.space()
+		if p.consume(")") {
+			return values, nil
+		}
+		if !p.consume("|") {
+			return nil, p.declError("expected '|' or ')' in enumeration")
+		}
+	}
+}
+
+// token reads a name or name token within a declaration.
+func (p *scanner) token() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case ' ', '\t', '\n', '\r', '>', '<', '(', ')', '|', ',', '?', '*', '+', '"', '\'', '[', ']':
+			return string(p.src[start:p.pos])
+		}
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+// declError returns errParamRef if a parameter entity reference stopped
+// parsing, and an error with msg otherwise.
+func (p *scanner) declError(msg string) error {
+	if p.pos < len(p.src) && p.src[p.pos] == '%' {
+		return errParamRef
+	}
+	return errors.New(msg)
+}
+
+// Element returns the declaration of the element name, or nil if there is
+// none.
+func (d *Doctype) Element(name string) *ElementDecl {
+	if d == nil {
+		return nil
+	}
+	return d.Elements[name]
+}
+
+// Attribute returns the definition of the attribute attr of the element
+// name, or nil if there is none.
+func (d *Doctype) Attribute(name, attr string) *AttDecl {
+	if d == nil {
+		return nil
+	}
+	for _, def := range d.Attributes[name] {
+		if def.Name == attr {
+			return def
+		}
+	}
+	return nil
+}
+
+// ParseExternal adds the declarations of an external subset, such as the
+// contents of a .dtd file, to d. Declarations already in d, from the
+// internal subset or an earlier call, stay binding. Conditional sections
+// are not supported.
+func (d *Doctype) ParseExternal(src []byte) error {
+	p := &scanner{src: append(append([]byte(nil), src...), ']')}
+	if err := p.subset(d, true); err != nil {
+		return fmt.Errorf("at position %d: %v", p.pos, err)
+	}
+	if p.pos != len(p.src) {
+		return fmt.Errorf("at position %d: unexpected ']'", p.pos-1)
+	}
+	return nil
+}
@@ -0,0 +1,449 @@
+// Package dtd parses document type declarations and expands the general
+// entities declared in their internal subset. It is shared by the fast-path
+// parser and the streaming Decoder. Element and attribute-list declarations
+// are recorded for DTD validation.
+//
+// Only internal entities are expanded. External entities (SYSTEM or PUBLIC)
+// are recorded but never loaded, so a document cannot make the parser read
+// files or URLs. Expansion is bounded by MaxExpansion to defuse
+// exponential-growth documents ("billion laughs").
+package dtd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shapestone/shape-xml/internal/charset"
+)
+
+// MaxExpansion bounds the total number of bytes produced by entity expansion
+// in one document.
+const MaxExpansion = 10 << 20
+
+// maxDepth bounds the nesting of entity references within entity values.
+const maxDepth = 16
+
+// ErrExternalEntity is returned for references to external entities, which
+// are never loaded.
+var ErrExternalEntity = errors.New("external entity not supported")
+
+// Doctype is a parsed <!DOCTYPE ...> declaration.
+type Doctype struct {
+	// Name is the declared root element name.
+	Name string
+	// PublicID and SystemID are the external subset identifiers, if any.
+	PublicID string
+	SystemID string
+	// Entities maps the general internal entities of the internal subset to
+	// their replacement text, with character references already expanded
+	// and references to other entities still in place.
+	Entities map[string]string
+	// Elements maps element names to their declarations.
+	Elements map[string]*ElementDecl
+	// Attributes maps element names to their attribute definitions, in
+	// declaration order.
+	Attributes map[string][]*AttDecl
+
+	// external records general entities declared with an external ID.
+	external map[string]bool
+	// cache holds fully expanded replacement texts.
+	cache map[string]string
+	// expanded counts the bytes produced by Expand so far.
+	expanded int
+}
+
+// Parse parses a document type declaration at the start of src, which must
+// begin with "<!DOCTYPE". It returns the declaration and the number of bytes
+// it occupies; on error, n is the offset at which parsing failed. If src
+// ends before the declaration does, n is len(src), so a reader can tell a
+// declaration it has not read completely from a malformed one.
+func Parse(src []byte) (d *Doctype, n int, err error) {
+	p := &scanner{src: src}
+	d, err = p.doctype()
+	if err != nil {
+		return nil, p.pos, err
+	}
+	return d, p.pos, nil
+}
+
+// Expand returns the replacement text of the general entity name with all
+// nested entity and character references expanded. ok is false if the
+// entity is not declared.
+func (d *Doctype) Expand(name string) (text string, ok bool, err error) {
+	if d == nil {
+		return "", false, nil
+	}
+	text, ok, err = d.expand(name, 0)
+	if err == nil && ok {
+		d.expanded += len(text)
+		if d.expanded > MaxExpansion {
+			return "", false, fmt.Errorf("entity expansion exceeds %d bytes", MaxExpansion)
+		}
+	}
+	return text, ok, err
+}
+
+func (d *Doctype) expand(name string, depth int) (string, bool, error) {
+	if text, ok := d.cache[name]; ok {
+		return text, true, nil
+	}
+	if d.external[name] {
+		return "", false, fmt.Errorf("&%s;: %w", name, ErrExternalEntity)
+	}
+	raw, ok := d.Entities[name]
+	if !ok {
+		return "", false, nil
+	}
+	if depth >= maxDepth {
+		return "", false, fmt.Errorf("entity &%s; nested too deeply or recursive", name)
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(raw, '&')
+		if i < 0 {
+			break
+		}
+		end := strings.IndexByte(raw[i:], ';')
+		if end < 0 {
+			break
+		}
+		b.WriteString(raw[:i])
+		ref := raw[i+1 : i+end]
+		raw = raw[i+end+1:]
+		if r, ok := predefined(ref); ok {
+			b.WriteRune(r)
+			continue
+		}
+		text, ok, err := d.expand(ref, depth+1)
+		if err != nil {
+			return "", false, err
+		}
+		if !ok {
+			b.WriteString("&" + ref + ";")
+			continue
+		}
+		if b.Len()+len(text) > MaxExpansion {
+			return "", false, fmt.Errorf("entity expansion exceeds %d bytes", MaxExpansion)
+		}
+		b.WriteString(text)
+	}
+	b.WriteString(raw)
+
+	if d.cache == nil {
+		d.cache = make(map[string]string)
+	}
+	text := b.String()
+	d.cache[name] = text
+	return text, true, nil
+}
+
+// predefined resolves the predefined entities and character references.
+func predefined(ref string) (rune, bool) {
+	switch ref {
+	case "lt":
+		return '<', true
+	case "gt":
+		return '>', true
+	case "amp":
+		return '&', true
+	case "apos":
+		return '\'', true
+	case "quot":
+		return '"', true
+	}
+	return charRef(ref)
+}
+
+// charRef resolves a character reference such as "#60" or "#x3C" to a
+// character XML allows.
+func charRef(ref string) (rune, bool) {
+	r, ok := charset.CharRef(ref)
+	if !ok || !charset.IsChar(r) {
+		return 0, false
+	}
+	return r, true
+}
+
+// scanner reads a DOCTYPE declaration.
+type scanner struct {
+	src []byte
+	pos int
+}
+
+// doctype parses:
+//
+//	'<!DOCTYPE' S Name (S ExternalID)? S? ('[' intSubset ']' S?)? '>'
+func (p *scanner) doctype() (*Doctype, error) {
+	if !p.consume("<!DOCTYPE") {
+		return nil, errors.New("expected <!DOCTYPE")
+	}
+	d := &Doctype{}
+	if !p.space() {
+		return nil, errors.New("expected space after <!DOCTYPE")
+	}
+	d.Name = p.name()
+	if d.Name == "" {
+		return nil, errors.New("expected root element name")
+	}
+	p.space()
+	var err error
+	if d.PublicID, d.SystemID, err = p.externalID(); err != nil {
+		return nil, err
+	}
+	p.space()
+	if p.consume("[") {
+		if err := p.subset(d, false); err != nil {
+			return nil, err
+		}
+		p.space()
+	}
+	if !p.consume(">") {
+		return nil, errors.New("expected '>'")
+	}
+	return d, nil
+}
+
+// externalID parses an optional SYSTEM or PUBLIC identifier.
+func (p *scanner) externalID() (public, system string, err error) {
+	switch {
+	case p.consume("SYSTEM"):
+		p.space()
+		system, err = p.literal()
+	case p.consume("PUBLIC"):
+		p.space()
+		if public, err = p.literal(); err != nil {
+			return "", "", err
+		}
+		p.space()
+		system, err = p.literal()
+	}
+	return public, system, err
+}
+
+// subset parses declarations up to and including the closing ']'. Entity,
+// element and attribute-list declarations are recorded; other declarations,
+// comments and processing instructions are skipped. An external subset may
+// not hold conditional sections, which are not supported.
+func (p *scanner) subset(d *Doctype, external bool) error {
+	for {
+		p.space()
+		switch {
+		case p.pos >= len(p.src):
+			return errors.New("unterminated internal subset")
+		case p.consume("]"):
+			return nil
+		case external && p.consume("<!["):
+			return errors.New("conditional sections are not supported")
+		case p.consume("<!--"):
+			if !p.skipPast("-->") {
+				return errors.New("unterminated comment")
+			}
+		case p.consume("<?"):
+			if !p.skipPast("?>") {
+				return errors.New("unterminated processing instruction")
+			}
+		case p.consume("<!ENTITY"):
+			if err := p.entityDecl(d); err != nil {
+				return err
+			}
+		case p.consume("<!ELEMENT"):
+			if err := p.declaration(d, p.elementDecl); err != nil {
+				return err
+			}
+		case p.consume("<!ATTLIST"):
+			if err := p.declaration(d, p.attlistDecl); err != nil {
+				return err
+			}
+		case p.consume("<!"):
+			if err := p.skipDecl(); err != nil {
+				return err
+			}
+		case p.src[p.pos] == '%':
+			// Parameter entity reference: not expanded.
+			if !p.skipPast(";") {
+				return errors.New("unterminated parameter entity reference")
+			}
+		default:
+			return fmt.Errorf("unexpected %q in internal subset", p.src[p.pos])
+		}
+	}
+}
+
+// entityDecl parses the rest of an entity declaration after "<!ENTITY".
+func (p *scanner) entityDecl(d *Doctype) error {
+	if !p.space() {
+		return errors.New("expected space after <!ENTITY")
+	}
+	parameter := p.consume("%")
+	if parameter {
+		p.space()
+	}
+	name := p.name()
+	if name == "" {
+		return errors.New("expected entity name")
+	}
+	p.space()
+
+	var value string
+	external := false
+	if p.pos < len(p.src) && (p.src[p.pos] == '"' || p.src[p.pos] == '\'') {
+		raw, err := p.literal()
+		if err != nil {
+			return err
+		}
+		if value, err = expandCharRefs(raw); err != nil {
+			return err
+		}
+	} else {
+		public, system, err := p.externalID()
+		if err != nil {
+			return err
+		}
+		if public == "" && system == "" {
+			return fmt.Errorf("expected value for entity %q", name)
+		}
+		external = true
+	}
+	if err := p.skipDecl(); err != nil { // NDATA and trailing space
+		return err
+	}
+	if parameter {
+		return nil
+	}
+
+	// The first declaration of an entity is binding.
+	if _, ok := d.Entities[name]; ok || d.external[name] {
+		return nil
+	}
+	if external {
+		if d.external == nil {
+			d.external = make(map[string]bool)
+		}
+		d.external[name] = true
+		return nil
+	}
+	if d.Entities == nil {
+		d.Entities = make(map[string]string)
+	}
+	d.Entities[name] = value
+	return nil
+}
+
+// expandCharRefs replaces character references in an entity value. A
+// reference to a character XML does not allow is an error.
+func expandCharRefs(s string) (string, error) {
+	if !strings.Contains(s, "&#") {
+		return s, nil
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "&#")
+		if i < 0 {
+			break
+		}
+		end := strings.IndexByte(s[i:], ';')
+		if end < 0 {
+			break
+		}
+		b.WriteString(s[:i])
+		ref := s[i+1 : i+end]
+		if r, ok := charset.CharRef(ref); !ok {
+			b.WriteString(s[i : i+end+1])
+		} else if !charset.IsChar(r) {
+			return "", charset.InvalidRefError(ref, r)
+		} else {
+			b.WriteRune(r)
+		}
+		s = s[i+end+1:]
+	}
+	b.WriteString(s)
+	return b.String(), nil
+}
+
+// skipDecl skips to the end of a markup declaration, past its '>'.
+func (p *scanner) skipDecl() error {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; c {
+		case '>':
+			p.pos++
+			return nil
+		case '"', '\'':
+			if _, err := p.literal(); err != nil {
+				return err
+			}
+		default:
+			p.pos++
+		}
+	}
+	return errors.New("unterminated declaration")
+}
+
+// literal reads a quoted string.
+func (p *scanner) literal() (string, error) {
+	if p.pos >= len(p.src) || (p.src[p.pos] != '"' && p.src[p.pos] != '\'') {
+		return "", errors.New("expected quoted literal")
+	}
+	quote := p.src[p.pos]
+	p.pos++
+	start := p.pos
+	for p.pos < len(p.src) {
+		if p.src[p.pos] == quote {
+			s := string(p.src[start:p.pos])
+			p.pos++
+			return s, nil
+		}
+		p.pos++
+	}
+	return "", errors.New("unterminated literal")
+}
+
+// name reads an XML name.
+func (p *scanner) name() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '>' || c == '[' ||
+			c == '"' || c == '\'' || c == ';' || c == '%' || c == '<' {
+			break
+		}
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+// space skips whitespace and reports whether there was any.
+func (p *scanner) space() bool {
+	start := p.pos
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.pos > start
+}
+
+// consume reads s if the input continues with it.
+func (p *scanner) consume(s string) bool {
+	if bytes.HasPrefix(p.src[p.pos:], []byte(s)) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// skipPast skips up to and including delim.
+func (p *scanner) skipPast(delim string) bool {
+	i := bytes.Index(p.src[p.pos:], []byte(delim))
+	if i < 0 {
+		p.pos = len(p.src)
+		return false
+	}
+	p.pos += i + len(delim)
+	return true
+}
@@ -0,0 +1,263 @@
+package dtd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	src := `<!DOCTYPE note PUBLIC "-//Example//Note" 'note.dtd' [
+  <!-- entities -->
+  <!ENTITY who "World">
+  <!ENTITY who "ignored">
+  <!ENTITY hello "Hello, &who;&#33;">
+  <!ENTITY % pe "parameter">
+  <!ENTITY logo SYSTEM "logo.gif" NDATA gif>
+  <!ELEMENT note (#PCDATA)>
+  <!ATTLIST note lang CDATA "en>">
+  <?pi data?>
+  %pe;
+]><note/>`
+	d, n, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := src[n:]; got != "<note/>" {
+		t.Errorf("Parse consumed up to %q", got)
+	}
+	if d.Name != "note" || d.PublicID != "-//Example//Note" || d.SystemID != "note.dtd" {
+		t.Errorf("got %+v", d)
+	}
+	if len(d.Entities) != 2 {
+		t.Errorf("Entities = %v", d.Entities)
+	}
+	text, ok, err := d.Expand("hello")
+	if err != nil || !ok || text != "Hello, World!" {
+		t.Errorf("Expand(hello) = %q, %v, %v", text, ok, err)
+	}
+	if _, ok, _ := d.Expand("pe"); ok {
+		t.Error("parameter entities must not be expanded as general entities")
+	}
+	if _, _, err := d.Expand("logo"); !errors.Is(err, ErrExternalEntity) {
+		t.Errorf("Expand(logo) = %v, want ErrExternalEntity", err)
+	}
+}
+
+func TestParse_NoSubset(t *testing.T) {
+	d, n, err := Parse([]byte(`<!DOCTYPE html><html/>`))
+	if err != nil || d.Name != "html" || n != len("<!DOCTYPE html>") {
+		t.Errorf("got %+v, %d, %v", d, n, err)
+	}
+	var nilDoctype *Doctype
+	if _, ok, err := nilDoctype.Expand("x"); ok || err != nil {
+		t.Error("a nil Doctype declares no entities")
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, src := range []string{
+		`<!DOCTYPE`,
+		`<!DOCTYPE >`,
+		`<!DOCTYPE r [<!ENTITY a "x">`,
+		`<!DOCTYPE r [<!ENTITY a "x>]>`,
+		`<!DOCTYPE r [<!ENTITY a>]>`,
+		`<!DOCTYPE r [junk]>`,
+		`<!DOCTYPE r SYSTEM>`,
+	} {
+		if _, _, err := Parse([]byte(src)); err == nil {
+			t.Errorf("Parse(%q): expected an error", src)
+		}
+	}
+}
+
+func TestExpand_Recursive(t *testing.T) {
+	d, _, err := Parse([]byte(`<!DOCTYPE r [<!ENTITY a "&b;"><!ENTITY b "&a;">]>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := d.Expand("a"); err == nil {
+		t.Error("expected an error for a recursive entity")
+	}
+}
+
+func TestExpand_BillionLaughs(t *testing.T) {
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE lolz [<!ENTITY lol0 "lol">`)
+	for i := 1; i <= 9; i++ {
+		b.WriteString(`<!ENTITY lol` + string(rune('0'+i)) + ` "`)
+		for j := 0; j < 10; j++ {
+			b.WriteString(`&lol` + string(rune('0'+i-1)) + `;`)
+		}
+		b.WriteString(`">`)
+	}
+	b.WriteString(`]>`)
+	d, _, err := Parse([]byte(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := d.Expand("lol9"); err == nil {
+		t.Error("expected expansion to be bounded")
+	}
+	// Repeated small expansions are bounded in total as well.
+	var total error
+	for i := 0; i < 100 && total == nil; i++ {
+		_, _, total = d.Expand("lol5") // 300 KB each
+	}
+	if total == nil {
+		t.Error("expected the total expansion to be bounded")
+	}
+}
+
+func TestParse_Declarations(t *testing.T) {
+	src := `<!DOCTYPE order [
+  <!ELEMENT order (customer, (item | gift)+, note?)>
+  <!ELEMENT order EMPTY>
+  <!ELEMENT customer (#PCDATA)>
+  <!ELEMENT note (#PCDATA | b | i)*>
+  <!ELEMENT item EMPTY>
+  <!ELEMENT gift ANY>
+  <!ELEMENT b %inline;>
+  <!ATTLIST item
+      sku    ID               #REQUIRED
+      qty    NMTOKEN          "1"
+      kind   (book|cd)        #IMPLIED
+      media  NOTATION (png)   #IMPLIED
+      cur    CDATA   #FIXED   "EUR&#33;">
+  <!ATTLIST item qty CDATA #IMPLIED>
+]>`
+	d, _, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	specs := map[string]string{
+		"order":    "(customer, (item | gift)+, note?)",
+		"customer": "(#PCDATA)",
+		"note":     "(#PCDATA | b | i)*",
+		"item":     "EMPTY",
+		"gift":     "ANY",
+	}
+	for name, want := range specs {
+		decl := d.Element(name)
+		if decl == nil {
+			t.Errorf("element %q not declared", name)
+			continue
+		}
+		if got := decl.String(); got != want {
+			t.Errorf("element %q = %s, want %s", name, got, want)
+		}
+	}
+	if d.Element("b") != nil {
+		t.Error("a declaration with a parameter entity reference was recorded")
+	}
+
+	if n := len(d.Attributes["item"]); n != 5 {
+		t.Fatalf("item has %d attributes, want 5", n)
+	}
+	tests := []AttDecl{
+		{Name: "sku", Type: "ID", Default: "#REQUIRED"},
+		{Name: "qty", Type: "NMTOKEN", Value: "1"},
+		{Name: "kind", Enum: []string{"book", "cd"}, Default: "#IMPLIED"},
+		{Name: "media", Type: "NOTATION", Enum: []string{"png"}, Default: "#IMPLIED"},
+		{Name: "cur", Type: "CDATA", Default: "#FIXED", Value: "EUR!"},
+	}
+	for _, want := range tests {
+		got := d.Attribute("item", want.Name)
+		if got == nil || got.Type != want.Type || got.Default != want.Default || got.Value != want.Value ||
+			strings.Join(got.Enum, "|") != strings.Join(want.Enum, "|") {
+			t.Errorf("attribute %s = %+v, want %+v", want.Name, got, want)
+		}
+	}
+}
+
+func TestElementDecl_Allows(t *testing.T) {
+	d, _, err := Parse([]byte(`<!DOCTYPE r [
+  <!ELEMENT seq (a, b*, c?)>
+  <!ELEMENT choice (a | b)+>
+  <!ELEMENT nested ((a, b) | c)*>
+  <!ELEMENT nullable (a?, b?)*>
+  <!ELEMENT mixed (#PCDATA | a)*>
+  <!ELEMENT empty EMPTY>
+  <!ELEMENT any ANY>
+]>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		element  string
+		children string
+		want     bool
+	}{
+		{"seq", "a", true},
+		{"seq", "a b b c", true},
+		{"seq", "a c", true},
+		{"seq", "", false},
+		{"seq", "b", false},
+		{"seq", "a c b", false},
+		{"seq", "a c c", false},
+		{"choice", "b a b", true},
+		{"choice", "", false},
+		{"choice", "c", false},
+		{"nested", "", true},
+		{"nested", "a b c a b", true},
+		{"nested", "a c", false},
+		{"nullable", "b a b a", true},
+		{"nullable", "", true},
+		{"mixed", "a a", true},
+		{"mixed", "b", false},
+		{"empty", "", true},
+		{"empty", "a", false},
+		{"any", "x y", true},
+	}
+	for _, tt := range tests {
+		if got := d.Element(tt.element).Allows(strings.Fields(tt.children)); got != tt.want {
+			t.Errorf("%s.Allows(%q) = %v, want %v", tt.element, tt.children, got, tt.want)
+		}
+	}
+}
+
+func TestParse_DeclarationErrors(t *testing.T) {
+	for _, src := range []string{
+		`<!DOCTYPE r [<!ELEMENT r>]>`,
+		`<!DOCTYPE r [<!ELEMENT r (a, b | c)>]>`,
+		`<!DOCTYPE r [<!ELEMENT r (#PCDATA | a)>]>`,
+		`<!DOCTYPE r [<!ELEMENT r (a>]>`,
+		`<!DOCTYPE r [<!ATTLIST r a STRING #IMPLIED>]>`,
+		`<!DOCTYPE r [<!ATTLIST r a CDATA>]>`,
+		`<!DOCTYPE r [<!ATTLIST r a (x|) #IMPLIED>]>`,
+	} {
+		if _, _, err := Parse([]byte(src)); err == nil {
+			t.Errorf("Parse(%q): expected an error", src)
+		}
+	}
+}
+
+func TestParseExternal(t *testing.T) {
+	d, _, err := Parse([]byte(`<!DOCTYPE r SYSTEM "r.dtd" [<!ATTLIST r v CDATA "internal">]>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	external := `<?xml version="1.0" encoding="UTF-8"?>
+<!-- r.dtd -->
+<!ELEMENT r (#PCDATA)>
+<!ATTLIST r v CDATA "external" w CDATA #IMPLIED>
+<!ENTITY e "text">`
+	if err := d.ParseExternal([]byte(external)); err != nil {
+		t.Fatalf("ParseExternal failed: %v", err)
+	}
+	if d.Element("r") == nil || d.Attribute("r", "w") == nil {
+		t.Error("external declarations were not added")
+	}
+	if v := d.Attribute("r", "v").Value; v != "internal" {
+		t.Errorf("v defaults to %q; the internal subset must stay binding", v)
+	}
+	if text, ok, _ := d.Expand("e"); !ok || text != "text" {
+		t.Errorf("Expand(e) = %q, %v", text, ok)
+	}
+
+	for _, src := range []string{`<![INCLUDE[<!ELEMENT r ANY>]]>`, `<!ELEMENT r ANY>]`, `<!ELEMENT r`} {
+		if err := new(Doctype).ParseExternal([]byte(src)); err == nil {
+			t.Errorf("ParseExternal(%q): expected an error", src)
+		}
+	}
+}
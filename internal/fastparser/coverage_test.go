@@ -59,6 +59,7 @@ func TestParse_EscapedAttributes(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			p := NewParser([]byte(tt.input))
+			p.SetBackslashEscapes(true)
 			result, err := p.Parse()
 			if err != nil {
 				t.Fatalf("Parse() error = %v", err)
@@ -281,6 +282,7 @@ func TestUnmarshal_NilPointer(t *testing.T) {
 func TestParse_EscapeAtEndOfString(t *testing.T) {
 	input := `<root attr="value\"></root>`
 	p := NewParser([]byte(input))
+	p.SetBackslashEscapes(true)
 	_, err := p.Parse()
 	// The backslash escapes the quote, so the string runs to end of input
 	if err == nil {
@@ -288,6 +290,18 @@ func TestParse_EscapeAtEndOfString(t *testing.T) {
 	}
 }
 
+func TestParse_BackslashIsLiteral(t *testing.T) {
+	input := `<root a="C:\dir\" b='a\nb' c="&quot;q&#92;"></root>`
+	result, err := NewParser([]byte(input)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := map[string]interface{}{"@a": `C:\dir\`, "@b": `a\nb`, "@c": `"q\`}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("got %q, want %q", result, want)
+	}
+}
+
 // ---------- Multiple text segments joined via joinStrings multi-part ----------
 
 func TestParse_TextCDataText(t *testing.T) {
@@ -0,0 +1,55 @@
+package fastparser
+
+// Summary selects how content below the depth limit set by SetMaxDepth is
+// represented.
+type Summary int
+
+const (
+	// SummaryRaw stores the unparsed inner XML of a cut-off element under InnerKey.
+	SummaryRaw Summary = iota
+	// SummaryCount stores the number of direct child elements of a cut-off
+	// element under CountKey.
+	SummaryCount
+)
+
+// Keys used for the summaries of elements at the depth limit.
+const (
+	InnerKey = "#inner"
+	CountKey = "#count"
+)
+
+// SetMaxDepth stops Parse from descending below depth elements, where the
+// root element has depth 1. Elements at the limit keep their attributes;
+// if they have child elements, their content is scanned for well-formedness
+// and replaced by a summary. Elements at the limit with only text are parsed
+// as usual. A depth of 0 or less disables the limit.
+func (p *Parser) SetMaxDepth(depth int, summary Summary) {
+	p.maxDepth = depth
+	p.summary = summary
+}
+
+// summarize replaces the content of the element starting at start with a
+// summary. It reports false, with p.pos back at startTagEnd, when the element
+// has no child elements and should be parsed normally.
+func (p *Parser) summarize(start, startTagEnd int, result map[string]interface{}) (bool, error) {
+	p.pos = start
+	children, contentEnd, err := p.scanElement()
+	if err != nil {
+		return false, err
+	}
+	if children == 0 {
+		p.pos = startTagEnd
+		return false, nil
+	}
+
+	switch p.summary {
+	case SummaryCount:
+		result[CountKey] = children
+	default:
+		result[InnerKey] = string(p.data[startTagEnd:contentEnd])
+	}
+	if p.recordSpans {
+		p.closeSpan(startTagEnd, contentEnd)
+	}
+	return true, nil
+}
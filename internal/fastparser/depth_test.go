@@ -0,0 +1,93 @@
+package fastparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const depthTestDoc = `<library name="city">
+  <shelf id="a"><book><title>One</title></book><book><title>Two</title></book></shelf>
+  <shelf id="b"/>
+  <note>plain text</note>
+</library>`
+
+func parseWithDepth(t *testing.T, input string, depth int, summary Summary) map[string]interface{} {
+	t.Helper()
+	p := NewParser([]byte(input))
+	p.SetMaxDepth(depth, summary)
+	v, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	return v.(map[string]interface{})
+}
+
+func TestSetMaxDepth_Raw(t *testing.T) {
+	got := parseWithDepth(t, depthTestDoc, 2, SummaryRaw)
+	want := map[string]interface{}{
+		"@name": "city",
+		"shelf": []interface{}{
+			map[string]interface{}{
+				"@id":    "a",
+				InnerKey: `<book><title>One</title></book><book><title>Two</title></book>`,
+			},
+			map[string]interface{}{"@id": "b"},
+		},
+		"note": map[string]interface{}{"#text": "plain text"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestSetMaxDepth_Count(t *testing.T) {
+	got := parseWithDepth(t, depthTestDoc, 2, SummaryCount)
+	shelf := got["shelf"].([]interface{})[0].(map[string]interface{})
+	if shelf[CountKey] != 2 {
+		t.Errorf("expected count 2, got %#v", shelf)
+	}
+
+	root := parseWithDepth(t, depthTestDoc, 1, SummaryCount)
+	if !reflect.DeepEqual(root, map[string]interface{}{"@name": "city", CountKey: 3}) {
+		t.Errorf("got %#v", root)
+	}
+}
+
+func TestSetMaxDepth_Unlimited(t *testing.T) {
+	want := parseWithDepth(t, depthTestDoc, 0, SummaryRaw)
+	got := parseWithDepth(t, depthTestDoc, 10, SummaryRaw)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("deep limit changed the result: %#v", got)
+	}
+}
+
+func TestSetMaxDepth_Spans(t *testing.T) {
+	p := NewParser([]byte(depthTestDoc))
+	p.SetMaxDepth(2, SummaryRaw)
+	_, span, err := p.ParseWithSpans()
+	if err != nil {
+		t.Fatalf("ParseWithSpans() error = %v", err)
+	}
+	if len(span.Children) != 3 {
+		t.Fatalf("expected 3 child spans, got %d", len(span.Children))
+	}
+	shelf := span.Children[0]
+	if len(shelf.Children) != 0 {
+		t.Errorf("expected no spans below the limit, got %d", len(shelf.Children))
+	}
+	if got := depthTestDoc[shelf.StartTagEnd:shelf.ContentEnd]; !strings.HasPrefix(got, "<book>") {
+		t.Errorf("shelf content span = %q", got)
+	}
+	if got := depthTestDoc[shelf.Start:shelf.End]; !strings.HasSuffix(got, "</shelf>") {
+		t.Errorf("shelf span = %q", got)
+	}
+}
+
+func TestSetMaxDepth_MalformedBelowLimit(t *testing.T) {
+	p := NewParser([]byte(`<a><b><c></d></b></a>`))
+	p.SetMaxDepth(2, SummaryCount)
+	if _, err := p.Parse(); err == nil || !strings.Contains(err.Error(), "mismatched tags") {
+		t.Errorf("expected mismatched tags error, got %v", err)
+	}
+}
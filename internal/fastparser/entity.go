@@ -0,0 +1,104 @@
+package fastparser
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"github.com/shapestone/shape-xml/internal/charset"
+	"github.com/shapestone/shape-xml/internal/dtd"
+)
+
+// decodeEntities returns b as a string with the predefined entities (&lt;
+// &gt; &amp; &apos; &quot;) and character references (&#60; &#x3C;)
+// replaced. Other references are kept verbatim.
+func decodeEntities(b []byte) string {
+	s, _ := decodeEntitiesWith(b, nil, charset.Reject)
+	return s
+}
+
+// decodeEntitiesWith is decodeEntities that also expands the general
+// entities declared by doctype, which may be nil. A character reference to
+// a character XML does not allow is handled as mode says: for Reject it is
+// an *invalidRefError.
+func decodeEntitiesWith(b []byte, doctype *dtd.Doctype, mode charset.Mode) (string, error) {
+	i := bytes.IndexByte(b, '&')
+	if i < 0 {
+		return string(b), nil
+	}
+
+	buf := make([]byte, 0, len(b))
+	for i >= 0 {
+		buf = append(buf, b[:i]...)
+		b = b[i:]
+		end := bytes.IndexByte(b, ';')
+		if end < 0 {
+			break
+		}
+		if r, ok := entityRune(b[1:end]); ok {
+			if !charset.IsChar(r) {
+				switch mode {
+				case charset.Reject:
+					return "", &invalidRefError{ref: string(b[1:end]), r: r}
+				case charset.Replace:
+					buf = utf8.AppendRune(buf, utf8.RuneError)
+				}
+			} else {
+				buf = utf8.AppendRune(buf, r)
+			}
+			b = b[end+1:]
+		} else if text, ok, err := doctype.Expand(string(b[1:end])); err != nil {
+			return "", err
+		} else if ok {
+			buf = append(buf, text...)
+			b = b[end+1:]
+		} else {
+			buf = append(buf, '&')
+			b = b[1:]
+		}
+		i = bytes.IndexByte(b, '&')
+	}
+	buf = append(buf, b...)
+	return string(buf), nil
+}
+
+// invalidRefError reports a character reference, &ref;, to r, which XML
+// does not allow.
+type invalidRefError struct {
+	ref string
+	r   rune
+}
+
+func (e *invalidRefError) Error() string {
+	return charset.InvalidRefError(e.ref, e.r).Error()
+}
+
+// decode decodes the references in b, read from p.data at start, with
+// decodeEntitiesWith. An invalid character reference leaves p.pos at it,
+// for the syntax error.
+func (p *Parser) decode(b []byte, start int) (string, error) {
+	s, err := decodeEntitiesWith(b, p.doctype, p.invalidChars)
+	if e, ok := err.(*invalidRefError); ok {
+		if i := bytes.Index(p.data[start:], []byte("&"+e.ref+";")); i >= 0 {
+			p.pos = start + i
+		}
+	}
+	return s, err
+}
+
+// entityRune resolves the name of an entity or character reference. The
+// character a reference stands for may be one XML does not allow.
+func entityRune(ref []byte) (rune, bool) {
+	switch string(ref) {
+	case "lt":
+		return '<', true
+	case "gt":
+		return '>', true
+	case "amp":
+		return '&', true
+	case "apos":
+		return '\'', true
+	case "quot":
+		return '"', true
+	}
+	return charset.CharRef(string(ref))
+}
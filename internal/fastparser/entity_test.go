@@ -0,0 +1,118 @@
+package fastparser
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shapestone/shape-xml/internal/charset"
+	"github.com/shapestone/shape-xml/internal/xmlerr"
+)
+
+func TestDecodeEntities(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"plain", "plain"},
+		{"a &lt; b &amp;&amp; c &gt; d", "a < b && c > d"},
+		{"&quot;q&quot; &apos;a&apos;", `"q" 'a'`},
+		{"&#60;&#x3C;&#x20AC;", "<<€"},
+		{"&unknown; stays", "&unknown; stays"},
+		{"&#xZZ; &#;", "&#xZZ; &#;"},
+		{"dangling & and &amp", "dangling & and &amp"},
+	}
+	for _, tt := range tests {
+		if got := decodeEntities([]byte(tt.input)); got != tt.want {
+			t.Errorf("decodeEntities(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParse_Entities(t *testing.T) {
+	p := NewParser([]byte(`<r a="x &amp; y" b='&#39;'>1 &lt; 2</r>`))
+	got, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := map[string]interface{}{"@a": "x & y", "@b": "'", "#text": "1 < 2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse = %#v, want %#v", got, want)
+	}
+}
+
+func TestParse_InvalidCharRefs(t *testing.T) {
+	for _, input := range []string{
+		`<r>a &#1; b</r>`,
+		`<r>&#xFFFE;</r>`,
+		`<r>&#0;</r>`,
+		`<r>&#xD800;</r>`,
+		`<r>&#x110000;</r>`,
+		`<r>&#99999999999;</r>`,
+		`<r a="&#x1F;"/>`,
+		`<!DOCTYPE r [<!ENTITY e "&#1;">]><r>&e;</r>`,
+	} {
+		_, err := NewParser([]byte(input)).Parse()
+		var se *xmlerr.SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("Parse(%q) = %v, want a syntax error", input, err)
+			continue
+		}
+		if !strings.Contains(se.Err.Error(), "illegal character") {
+			t.Errorf("Parse(%q) = %v", input, err)
+		}
+	}
+
+	_, err := NewParser([]byte("<r>\n  ok &#1;</r>")).Parse()
+	var se *xmlerr.SyntaxError
+	if !errors.As(err, &se) || se.Offset != 9 || se.Line != 2 || se.Column != 6 {
+		t.Errorf("Parse = %#v, want the error at offset 9, line 2, column 6", err)
+	}
+
+	for mode, want := range map[charset.Mode]string{charset.Strip: "ab", charset.Replace: "a\uFFFDb"} {
+		p := NewParser([]byte(`<r a="a&#1;b">a&#xFFFF;b</r>`))
+		p.SetInvalidChars(mode)
+		got, err := p.Parse()
+		if err != nil {
+			t.Fatalf("Parse, mode %d: %v", mode, err)
+		}
+		if m := got.(map[string]interface{}); m["@a"] != want || m["#text"] != want {
+			t.Errorf("Parse, mode %d = %#v, want %q", mode, got, want)
+		}
+	}
+}
+
+func TestParse_DoctypeEntities(t *testing.T) {
+	p := NewParser([]byte(`<?xml version="1.0"?>
+<!-- before -->
+<!DOCTYPE r [
+  <!ENTITY company "Acme &amp; Sons">
+  <!ENTITY greeting "Hello from &company;">
+  <!ATTLIST r a CDATA #IMPLIED>
+]>
+<r a="&company;">&greeting; &unknown;</r>`))
+	got, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := map[string]interface{}{"@a": "Acme & Sons", "#text": "Hello from Acme & Sons &unknown;"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse = %#v, want %#v", got, want)
+	}
+	if p.RootName() != "r" {
+		t.Errorf("RootName = %q", p.RootName())
+	}
+}
+
+func TestParse_DoctypeErrors(t *testing.T) {
+	for _, input := range []string{
+		`<!DOCTYPE r [<!ENTITY a "x"><r/>`,
+		`<!DOCTYPE r [<!ENTITY ext SYSTEM "file:///etc/passwd">]><r>&ext;</r>`,
+		`<!DOCTYPE r [<!ENTITY a "&b;"><!ENTITY b "&a;">]><r>&a;</r>`,
+	} {
+		if _, err := NewParser([]byte(input)).Parse(); err == nil {
+			t.Errorf("Parse(%q): expected an error", input)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package fastparser
+
+import "reflect"
+
+// VisibleFields returns the exported fields of struct type t that encode
+// and decode as XML, in declaration order, with the Index path leading to
+// each. Fields of embedded structs without an xml tag are promoted into t
+// as Go promotes them, in place of the embedded field: a field of the outer
+// struct hides a field of the same name further down. Embedded pointers to
+// unexported struct types are left out, since decoding could not allocate
+// them.
+func VisibleFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for _, f := range reflect.VisibleFields(t) {
+		if f.PkgPath != "" || promoted(f) || !reachable(t, f.Index) {
+			continue
+		}
+		if len(f.Index) > 1 && f.Name == "XMLName" {
+			continue // names the embedded struct's own element
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// promoted reports whether the fields of the embedded field f take its
+// place in the struct holding it.
+func promoted(f reflect.StructField) bool {
+	if !f.Anonymous || f.Tag.Get("xml") != "" {
+		return false
+	}
+	t := f.Type
+	if t.Kind() == reflect.Ptr {
+		if f.PkgPath != "" {
+			return false
+		}
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// reachable reports whether the field at index path index of struct type
+// t lies only in promoted embedded fields.
+func reachable(t reflect.Type, index []int) bool {
+	for i := 1; i < len(index); i++ {
+		if !promoted(t.FieldByIndex(index[:i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// FieldByIndex returns the field of struct value rv at index path index
+// as VisibleFields returns it. Nil embedded pointers along the path are
+// allocated if alloc is set; otherwise ok is false when there is one.
+func FieldByIndex(rv reflect.Value, index []int, alloc bool) (fv reflect.Value, ok bool) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, true
+}
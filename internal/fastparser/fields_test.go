@@ -0,0 +1,58 @@
+package fastparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+type fieldsBase struct {
+	XMLName testName
+	A       string
+	B       string
+	hidden  string
+}
+
+type FieldsPtr struct {
+	C string
+}
+
+type fieldsPtr struct {
+	D string
+}
+
+type fieldsDoc struct {
+	fieldsBase
+	*FieldsPtr
+	*fieldsPtr
+	Tagged FieldsPtr `xml:"tagged"`
+	FieldsTagged
+	B string
+}
+
+type FieldsTagged struct {
+	E string
+}
+
+func TestVisibleFields(t *testing.T) {
+	var names []string
+	var index [][]int
+	for _, f := range VisibleFields(reflect.TypeOf(fieldsDoc{})) {
+		names = append(names, f.Name)
+		index = append(index, f.Index)
+	}
+	wantNames := []string{"A", "C", "Tagged", "E", "B"}
+	wantIndex := [][]int{{0, 1}, {1, 0}, {3}, {4, 0}, {5}}
+	if !reflect.DeepEqual(names, wantNames) || !reflect.DeepEqual(index, wantIndex) {
+		t.Errorf("VisibleFields = %v %v, want %v %v", names, index, wantNames, wantIndex)
+	}
+}
+
+func TestUnmarshal_EmbeddedPointerAllocated(t *testing.T) {
+	var v fieldsDoc
+	if err := Unmarshal([]byte(`<r><C>c</C><B>b</B></r>`), &v); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if v.FieldsPtr == nil || v.C != "c" || v.B != "b" || v.fieldsBase.B != "" {
+		t.Errorf("got %+v", v)
+	}
+}
@@ -0,0 +1,256 @@
+package fastparser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Filter is a compiled element whitelist. Elements whose path from the root
+// matches a whitelisted path are kept with their whole subtree; ancestors of
+// a whitelisted path keep their attributes and text but only the children
+// leading to a match. Every other subtree is skipped without building values.
+type Filter struct {
+	// keep is true when the element and everything below it is kept.
+	keep     bool
+	children map[string]*Filter
+}
+
+// NewFilter compiles paths such as "/order/item/sku" into a Filter. Each path
+// starts at the root element; the leading '/' is optional and "*" matches
+// any element name.
+func NewFilter(paths []string) (*Filter, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no paths given")
+	}
+	root := &Filter{}
+	for _, path := range paths {
+		segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+		node := root
+		for _, seg := range segments {
+			if seg != "*" && !isValidName(seg) {
+				return nil, fmt.Errorf("invalid path %q: bad element name %q", path, seg)
+			}
+			next := node.children[seg]
+			if next == nil {
+				next = &Filter{}
+				if node.children == nil {
+					node.children = make(map[string]*Filter)
+				}
+				node.children[seg] = next
+			}
+			node = next
+		}
+		node.keep = true
+	}
+	root.mergeWildcards()
+	return root, nil
+}
+
+// child returns the filter for a child element, or nil if it is skipped.
+func (f *Filter) child(name string) *Filter {
+	if c, ok := f.children[name]; ok {
+		return c
+	}
+	return f.children["*"]
+}
+
+// mergeWildcards folds each "*" subtree into its named siblings, so that a
+// named child also matches the paths that continue through the wildcard.
+func (f *Filter) mergeWildcards() {
+	if wild := f.children["*"]; wild != nil {
+		for name, c := range f.children {
+			if name != "*" {
+				c.merge(wild)
+			}
+		}
+	}
+	for _, c := range f.children {
+		c.mergeWildcards()
+	}
+}
+
+// merge adds the paths of src to f.
+func (f *Filter) merge(src *Filter) {
+	f.keep = f.keep || src.keep
+	for name, sc := range src.children {
+		dc := f.children[name]
+		if dc == nil {
+			dc = &Filter{}
+			if f.children == nil {
+				f.children = make(map[string]*Filter)
+			}
+			f.children[name] = dc
+		}
+		dc.merge(sc)
+	}
+}
+
+// SetFilter restricts Parse to the elements whitelisted by f.
+// A nil Filter keeps every element.
+func (p *Parser) SetFilter(f *Filter) {
+	p.filter = f
+}
+
+// rootFilter returns the filter for the root element at p.pos. A root that
+// matches no path is still parsed, but all of its children are skipped.
+func (p *Parser) rootFilter() *Filter {
+	saved := p.pos
+	p.pos++ // skip '<'
+	nameStart := p.pos
+	p.scanName()
+	name := string(p.data[nameStart:p.pos])
+	p.pos = saved
+
+	f := p.filter.child(name)
+	switch {
+	case f == nil:
+		return &Filter{}
+	case f.keep:
+		return nil
+	default:
+		return f
+	}
+}
+
+// skipElement skips the element starting at p.pos without building values.
+// Tag nesting is still checked, so mismatched or unterminated elements are
+// reported as they are by parseElement.
+func (p *Parser) skipElement() error {
+	_, _, err := p.scanElement()
+	return err
+}
+
+// scanElement skips the element starting at p.pos like skipElement and
+// returns the number of its direct child elements and the offset of its
+// closing tag's "</" (p.pos for a self-closing element).
+func (p *Parser) scanElement() (children, contentEnd int, err error) {
+	stack := p.skipStack[:0]
+	defer func() { p.skipStack = stack[:0] }()
+
+	for {
+		// p.pos is at the '<' of a start tag.
+		if len(stack) == 1 {
+			children++
+		}
+		p.pos++
+		nameStart := p.pos
+		p.scanName()
+		if p.pos == nameStart {
+			return 0, 0, errors.New("expected element name")
+		}
+		nameEnd := p.pos
+
+		selfClosing, err := p.skipStartTagRest()
+		if err != nil {
+			return 0, 0, err
+		}
+		if !selfClosing {
+			stack = append(stack, [2]int{nameStart, nameEnd})
+		} else if len(stack) == 0 {
+			return 0, p.pos, nil
+		}
+
+	content:
+		for len(stack) > 0 {
+			i := bytes.IndexByte(p.data[p.pos:], '<')
+			if i < 0 {
+				top := stack[len(stack)-1]
+				return 0, 0, fmt.Errorf("unexpected end of input, expected closing tag for %q", p.data[top[0]:top[1]])
+			}
+			p.pos += i
+
+			switch {
+			case p.peekString("</"):
+				if len(stack) == 1 {
+					contentEnd = p.pos
+				}
+				p.pos += 2
+				closeStart := p.pos
+				p.scanName()
+				top := stack[len(stack)-1]
+				if !bytes.Equal(p.data[closeStart:p.pos], p.data[top[0]:top[1]]) {
+					open := p.open
+					for _, el := range stack {
+						if start := el[0] - 1; len(open) == 0 || start > open[len(open)-1] {
+							open = append(open[:len(open):len(open)], start)
+						}
+					}
+					return 0, 0, p.mismatchedTags(open, string(p.data[closeStart:p.pos]), closeStart-2)
+				}
+				p.skipWhitespace()
+				if !p.consume('>') {
+					return 0, 0, fmt.Errorf("expected '>' in closing tag for element %q", p.data[top[0]:top[1]])
+				}
+				stack = stack[:len(stack)-1]
+			case p.peekString("<!--"):
+				if err := p.skipComment(); err != nil {
+					return 0, 0, err
+				}
+			case p.peekString("<![CDATA["):
+				end := bytes.Index(p.data[p.pos+9:], []byte("]]>"))
+				if end < 0 {
+					return 0, 0, errors.New("unterminated CDATA section")
+				}
+				p.pos += 9 + end + 3
+			default:
+				break content
+			}
+		}
+		if len(stack) == 0 {
+			return children, contentEnd, nil
+		}
+	}
+}
+
+// skipStartTagRest skips attributes up to and including '>' or "/>",
+// and reports whether the tag was self-closing.
+func (p *Parser) skipStartTagRest() (bool, error) {
+	for p.pos < p.length {
+		switch c := p.data[p.pos]; c {
+		case '"', '\'':
+			end := bytes.IndexByte(p.data[p.pos+1:], c)
+			if end < 0 {
+				return false, errors.New("unterminated string")
+			}
+			p.pos += end + 2
+		case '>':
+			p.pos++
+			return false, nil
+		case '/':
+			if p.peekString("/>") {
+				p.pos += 2
+				return true, nil
+			}
+			p.pos++
+		default:
+			p.pos++
+		}
+	}
+	return false, errors.New("unexpected end of input in start tag")
+}
+
+// scanName advances past an XML name without allocating.
+func (p *Parser) scanName() {
+	if p.pos >= p.length || !isNameStartChar(p.data[p.pos]) {
+		return
+	}
+	p.pos++
+	for p.pos < p.length && isNameChar(p.data[p.pos]) {
+		p.pos++
+	}
+}
+
+// isValidName reports whether s is a name accepted by readName.
+func isValidName(s string) bool {
+	if s == "" || !isNameStartChar(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isNameChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
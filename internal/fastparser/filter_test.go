@@ -0,0 +1,142 @@
+package fastparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const filterTestDoc = `<?xml version="1.0"?>
+<feed lang="en">
+  <title>News</title>
+  <entry id="1">
+    <title>First</title>
+    <body><p>long <b>text</b></p><![CDATA[ <not a tag> ]]><!-- </body> --></body>
+    <meta><author>ann</author><tags><tag>a</tag></tags></meta>
+  </entry>
+  <entry id="2">
+    <title>Second</title>
+    <body/>
+    <meta><author>bob</author></meta>
+  </entry>
+</feed>`
+
+func parseFiltered(t *testing.T, input string, paths ...string) map[string]interface{} {
+	t.Helper()
+	f, err := NewFilter(paths)
+	if err != nil {
+		t.Fatalf("NewFilter(%q) error = %v", paths, err)
+	}
+	p := NewParser([]byte(input))
+	p.SetFilter(f)
+	v, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	return v.(map[string]interface{})
+}
+
+func TestFilter_KeepsWhitelistedPaths(t *testing.T) {
+	got := parseFiltered(t, filterTestDoc, "/feed/entry/title")
+	want := map[string]interface{}{
+		"@lang": "en",
+		"entry": []interface{}{
+			map[string]interface{}{"@id": "1", "title": map[string]interface{}{"#text": "First"}},
+			map[string]interface{}{"@id": "2", "title": map[string]interface{}{"#text": "Second"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestFilter_KeepsWholeSubtree(t *testing.T) {
+	got := parseFiltered(t, filterTestDoc, "feed/entry/meta")
+	entries := got["entry"].([]interface{})
+	meta := entries[0].(map[string]interface{})["meta"].(map[string]interface{})
+	if _, ok := meta["tags"]; !ok {
+		t.Errorf("expected full meta subtree, got %#v", meta)
+	}
+	if _, ok := entries[0].(map[string]interface{})["body"]; ok {
+		t.Error("body should have been skipped")
+	}
+}
+
+func TestFilter_Wildcard(t *testing.T) {
+	got := parseFiltered(t, filterTestDoc, "/feed/*/title", "/feed/entry/meta/author")
+	if got["title"].(map[string]interface{})["#text"] != "News" {
+		t.Errorf("expected feed title to be kept via wildcard, got %#v", got)
+	}
+	first := got["entry"].([]interface{})[0].(map[string]interface{})
+	if first["title"] == nil || first["meta"] == nil {
+		t.Errorf("expected title and meta on entry, got %#v", first)
+	}
+	if _, ok := first["meta"].(map[string]interface{})["tags"]; ok {
+		t.Error("tags should have been skipped")
+	}
+}
+
+func TestFilter_RootMismatch(t *testing.T) {
+	got := parseFiltered(t, filterTestDoc, "/other/title")
+	if !reflect.DeepEqual(got, map[string]interface{}{"@lang": "en"}) {
+		t.Errorf("got %#v", got)
+	}
+}
+
+func TestFilter_RootOnly(t *testing.T) {
+	got := parseFiltered(t, filterTestDoc, "/feed")
+	if len(got["entry"].([]interface{})) != 2 {
+		t.Errorf("expected the whole document, got %#v", got)
+	}
+}
+
+func TestFilter_SkippedSubtreeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"mismatched", `<a><skip><x></y></skip><keep/></a>`, "mismatched tags"},
+		{"unterminated", `<a><skip><x>`, "unexpected end of input"},
+		{"cdata", `<a><skip><![CDATA[ x </skip></a>`, "unterminated CDATA"},
+		{"attribute", `<a><skip v="1></skip></a>`, "unterminated string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := NewFilter([]string{"/a/keep"})
+			p := NewParser([]byte(tt.input))
+			p.SetFilter(f)
+			_, err := p.Parse()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_SkipHandlesMarkupInContent(t *testing.T) {
+	input := `<a><skip x="/>" y='>'>t<![CDATA[</skip>]]><!-- </skip> --><n/></skip><keep>k</keep></a>`
+	got := parseFiltered(t, input, "/a/keep")
+	want := map[string]interface{}{"keep": map[string]interface{}{"#text": "k"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v", got)
+	}
+}
+
+func TestNewFilter_Invalid(t *testing.T) {
+	for _, paths := range [][]string{nil, {"/a//b"}, {"/a/1b"}, {""}} {
+		if _, err := NewFilter(paths); err == nil {
+			t.Errorf("NewFilter(%q) expected error", paths)
+		}
+	}
+}
+
+func TestParser_RootName(t *testing.T) {
+	p := NewParser([]byte(`<!-- c --><catalog><item/></catalog>`))
+	if _, err := p.Parse(); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got := p.RootName(); got != "catalog" {
+		t.Errorf("RootName() = %q", got)
+	}
+}
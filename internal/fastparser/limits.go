@@ -0,0 +1,29 @@
+package fastparser
+
+import (
+	"github.com/shapestone/shape-xml/internal/limits"
+)
+
+// SetLimits bounds the document Parse accepts. Without it the depth is
+// limited to limits.DefaultMaxDepth and nothing else is. Content skipped by
+// a filter or summarized at the depth set by SetMaxDepth is only checked
+// for well-formedness.
+func (p *Parser) SetLimits(l limits.Limits) {
+	p.limits = l
+}
+
+// limitError returns the error for limit, of value max, exceeded by the
+// construct at offset.
+func (p *Parser) limitError(limit string, max, offset int) error {
+	line, column := lineColumn(p.data, offset)
+	return &limits.Error{Limit: limit, Max: int64(max), Line: line, Column: column}
+}
+
+// checkLength returns an error if the token from offset start to end is
+// longer than allowed.
+func (p *Parser) checkLength(start, end int) error {
+	if max := p.limits.MaxTokenLength; max > 0 && end-start > max {
+		return p.limitError(limits.TokenLength, max, start)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package fastparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// closeOpen drops the open elements from index n on.
+func (p *Parser) closeOpen(n int) {
+	p.open = p.open[:n]
+}
+
+// mismatchedTags returns the error for an end tag named closing, whose
+// "</" is at offset end, that does not match the innermost open element.
+// open holds the offsets of the start tags of the open elements,
+// outermost first. The error is located at the end tag; the message gives
+// the position of the start tag and the open elements as a path, so the
+// unclosed element can be found in a large document.
+func (p *Parser) mismatchedTags(open []int, closing string, end int) error {
+	names := make([]string, len(open))
+	for i, start := range open {
+		names[i] = p.nameAt(start + 1)
+	}
+	openLine, openColumn := lineColumn(p.data, open[len(open)-1])
+	p.pos = end
+	return fmt.Errorf("mismatched tags: opening %q at line %d, column %d, closing %q; open elements: /%s",
+		names[len(names)-1], openLine, openColumn, closing, strings.Join(names, "/"))
+}
+
+// nameAt returns the name starting at offset pos, "" past the end of the
+// input.
+func (p *Parser) nameAt(pos int) string {
+	if pos >= p.length {
+		return ""
+	}
+	saved := p.pos
+	p.pos = pos
+	p.scanName()
+	name := string(p.data[pos:p.pos])
+	p.pos = saved
+	return name
+}
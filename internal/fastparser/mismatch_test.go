@@ -0,0 +1,39 @@
+package fastparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMismatchedTags(t *testing.T) {
+	input := "<root>\n  <a>\n    <b>x</c>\n  </a>\n</root>"
+	want := `xml: syntax error at line 3, column 9: mismatched tags: opening "b" at line 3, column 5, closing "c"; open elements: /root/a/b`
+
+	filter, err := NewFilter([]string{"root/keep"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		setup func(p *Parser)
+	}{
+		{"parse", func(p *Parser) {}},
+		{"skipped by filter", func(p *Parser) { p.SetFilter(filter) }},
+		{"below max depth", func(p *Parser) { p.SetMaxDepth(2, SummaryCount) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser([]byte(input))
+			tt.setup(p)
+			_, err := p.Parse()
+			if err == nil || err.Error() != want {
+				t.Errorf("got  %v\nwant %s", err, want)
+			}
+		})
+	}
+
+	_, err = NewParser([]byte("<root><done/><x><y></y></z></root>")).Parse()
+	if err == nil || !strings.HasSuffix(err.Error(), "open elements: /root/x") {
+		t.Errorf("got %v", err)
+	}
+}
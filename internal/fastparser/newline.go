@@ -0,0 +1,42 @@
+package fastparser
+
+import "bytes"
+
+// normalizeNewlines returns b with each "\r\n" and each "\r" not followed
+// by "\n" replaced by "\n", the end-of-line handling of XML 1.0 section
+// 2.11. b itself is never modified: input holding "\r" is copied. It runs
+// before entity references are decoded, so a &#13; reference still yields
+// "\r".
+func normalizeNewlines(b []byte) []byte {
+	i := bytes.IndexByte(b, '\r')
+	if i < 0 {
+		return b
+	}
+	out := make([]byte, 0, len(b))
+	for i >= 0 {
+		out = append(out, b[:i]...)
+		out = append(out, '\n')
+		b = b[i+1:]
+		if len(b) > 0 && b[0] == '\n' {
+			b = b[1:]
+		}
+		i = bytes.IndexByte(b, '\r')
+	}
+	return append(out, b...)
+}
+
+// SetRawNewlines makes the parser keep "\r\n" and "\r" line ends in text,
+// CDATA sections and attribute values as written. By default they become
+// "\n", as the XML specification requires.
+func (p *Parser) SetRawNewlines(on bool) {
+	p.rawNewlines = on
+}
+
+// newlines returns b with its line ends normalized, unless SetRawNewlines
+// is on.
+func (p *Parser) newlines(b []byte) []byte {
+	if p.rawNewlines {
+		return b
+	}
+	return normalizeNewlines(b)
+}
@@ -0,0 +1,50 @@
+package fastparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewlineNormalization(t *testing.T) {
+	input := "<r a=\"x\r\ny\" e=\"p\\nq\r\">one\r\ntwo\rthree&#13;x<b><![CDATA[c\r\nd]]></b></r>"
+	tests := []struct {
+		raw  bool
+		want map[string]interface{}
+	}{
+		{false, map[string]interface{}{
+			"@a": "x\ny", "@e": "p\nq\n", "#text": "one\ntwo\nthree\rx",
+			"b": map[string]interface{}{"#cdata": "c\nd"},
+		}},
+		{true, map[string]interface{}{
+			"@a": "x\r\ny", "@e": "p\nq\r", "#text": "one\r\ntwo\rthree\rx",
+			"b": map[string]interface{}{"#cdata": "c\r\nd"},
+		}},
+	}
+	for _, tt := range tests {
+		p := NewParser([]byte(input))
+		p.SetRawNewlines(tt.raw)
+		// e takes the escape path.
+		p.SetBackslashEscapes(true)
+		got, err := p.Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("raw=%v: got %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeNewlines(t *testing.T) {
+	tests := map[string]string{
+		"":             "",
+		"a\nb":         "a\nb",
+		"a\r\nb\rc\r":  "a\nb\nc\n",
+		"\r\r\n\n\r\n": "\n\n\n\n",
+	}
+	for in, want := range tests {
+		if got := string(normalizeNewlines([]byte(in))); got != want {
+			t.Errorf("normalizeNewlines(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
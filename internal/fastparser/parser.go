@@ -14,15 +14,71 @@
 package fastparser
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+
+	"github.com/shapestone/shape-xml/internal/charset"
+	"github.com/shapestone/shape-xml/internal/dtd"
+	"github.com/shapestone/shape-xml/internal/intern"
+	"github.com/shapestone/shape-xml/internal/limits"
+	"github.com/shapestone/shape-xml/internal/xmlerr"
 )
 
 // Parser implements a zero-allocation XML validator that checks well-formedness without AST.
 type Parser struct {
-	data   []byte
-	pos    int
-	length int
+	data     []byte
+	pos      int
+	length   int
+	rootName string
+
+	// doctype is the document type declaration, nil if there is none.
+	doctype *dtd.Doctype
+
+	// Span recording (enabled by ParseWithSpans)
+	recordSpans bool
+	rootSpan    *Span
+	spanStack   []*Span
+
+	// Element filtering (enabled by SetFilter). node is the filter for the
+	// element being parsed, nil when its whole subtree is kept.
+	filter    *Filter
+	node      *Filter
+	skipStack [][2]int
+
+	// Depth limiting (enabled by SetMaxDepth). depth is the depth of the
+	// element being parsed, starting at 1 for the root.
+	maxDepth int
+	summary  Summary
+	depth    int
+
+	// open holds the offsets of the start tags of the elements being
+	// parsed, outermost first, for error messages.
+	open []int
+
+	// Name interning (enabled by SetInternPool). key is scratch space for
+	// building "@name" attribute keys.
+	names *intern.Pool
+	key   []byte
+
+	// rawNewlines keeps line ends as written, see SetRawNewlines.
+	rawNewlines bool
+
+	// preserveSpace keeps whitespace in text, see SetPreserveSpace, and
+	// space is whether the current element does, following xml:space.
+	preserveSpace bool
+	space         bool
+
+	// backslashEscapes reads backslash escapes in attribute values, see
+	// SetBackslashEscapes.
+	backslashEscapes bool
+
+	// invalidChars handles references to characters XML does not allow,
+	// see SetInvalidChars.
+	invalidChars charset.Mode
+
+	// limits bounds the document, see SetLimits.
+	limits limits.Limits
 }
 
 // NewParser creates a new fast parser for the given data.
@@ -34,10 +90,55 @@ func NewParser(data []byte) *Parser {
 	}
 }
 
+// SetInternPool makes the parser take element names and attribute keys from
+// pool, so that documents sharing a vocabulary share the strings.
+func (p *Parser) SetInternPool(pool *intern.Pool) {
+	p.names = pool
+}
+
+// RootName returns the name of the root element once Parse has read it.
+func (p *Parser) RootName() string {
+	return p.rootName
+}
+
 // Parse parses the XML data and returns the value as interface{} (map[string]interface{}).
 // This is used by Unmarshal and Validate.
 // For validation, the caller can simply discard the returned value.
+//
+// A document that is not well-formed is reported as an
+// *xmlerr.SyntaxError, and one exceeding its limits as a *limits.Error.
 func (p *Parser) Parse() (interface{}, error) {
+	if err := p.limits.CheckSize(int64(p.length)); err != nil {
+		return nil, err
+	}
+	value, err := p.parse()
+	if err != nil {
+		return nil, p.syntaxError(err)
+	}
+	return value, nil
+}
+
+// syntaxError returns err as an *xmlerr.SyntaxError at the position where
+// parsing stopped. Limit errors are returned as they are.
+func (p *Parser) syntaxError(err error) error {
+	if _, ok := err.(*limits.Error); ok {
+		return err
+	}
+	var element string
+	if n := len(p.open); n > 0 {
+		element = p.nameAt(p.open[n-1] + 1)
+	}
+	return xmlerr.New(p.data, p.pos, element, err)
+}
+
+func (p *Parser) parse() (interface{}, error) {
+	if bytes.HasPrefix(p.data, []byte(charset.ByteOrderMark)) {
+		p.pos = len(charset.ByteOrderMark)
+	}
+	if i, r := charset.IndexInvalid(p.data); i >= 0 {
+		p.pos = i
+		return nil, charset.InvalidError(r)
+	}
 	p.skipWhitespace()
 	if p.pos >= p.length {
 		return nil, errors.New("unexpected end of XML input")
@@ -55,7 +156,25 @@ func (p *Parser) Parse() (interface{}, error) {
 	// Skip any comments before root element
 	p.skipComments()
 
+	// Document type declaration; its internal subset may declare entities
+	if p.peekString("<!DOCTYPE") {
+		doctype, n, err := dtd.Parse(p.data[p.pos:])
+		if err != nil {
+			p.pos += n
+			return nil, fmt.Errorf("in DOCTYPE: %w", err)
+		}
+		p.doctype = doctype
+		p.pos += n
+		p.skipWhitespace()
+		p.skipComments()
+	}
+
+	if p.filter != nil {
+		p.node = p.rootFilter()
+	}
+
 	// Parse root element to Go map
+	p.depth = 1
 	result, err := p.parseElement()
 	if err != nil {
 		return nil, err
@@ -66,7 +185,7 @@ func (p *Parser) Parse() (interface{}, error) {
 
 	// After parsing the root element, we should be at EOF
 	if p.pos < p.length {
-		return nil, fmt.Errorf("unexpected content after root element at position %d", p.pos)
+		return nil, errors.New("unexpected content after root element")
 	}
 
 	return result, nil
@@ -78,21 +197,45 @@ func (p *Parser) Parse() (interface{}, error) {
 //   - "childElement": child element nodes
 //   - "#text": text content
 //   - "#cdata": CDATA content
-func (p *Parser) parseElement() (map[string]interface{}, error) {
+func (p *Parser) parseElement() (_ map[string]interface{}, err error) {
+	start := p.pos
+
 	// Expect '<'
 	if !p.consume('<') {
-		return nil, fmt.Errorf("expected '<' at position %d", p.pos)
+		return nil, errors.New("expected '<'")
 	}
 
 	// Read element name
 	elementName := p.readName()
 	if elementName == "" {
-		return nil, fmt.Errorf("expected element name at position %d", p.pos)
+		return nil, errors.New("expected element name")
+	}
+
+	p.open = append(p.open, start)
+	// On error the element stays open, for syntaxError to name it.
+	defer func(n int) {
+		if err == nil {
+			p.closeOpen(n)
+		}
+	}(len(p.open) - 1)
+	if max := p.limits.Depth(); max > 0 && len(p.open) > max {
+		return nil, p.limitError(limits.Depth, max, start)
+	}
+	if err := p.checkLength(start+1, p.pos); err != nil {
+		return nil, err
+	}
+
+	if p.rootName == "" {
+		p.rootName = elementName
 	}
 
 	result := make(map[string]interface{})
+	if p.recordSpans {
+		p.openSpan(elementName, start, result)
+	}
 
 	// Read attributes
+	attrs := 0
 	for {
 		p.skipWhitespace()
 
@@ -104,6 +247,9 @@ func (p *Parser) parseElement() (map[string]interface{}, error) {
 		// Self-closing tag: />
 		if p.peekString("/>") {
 			p.pos += 2
+			if p.recordSpans {
+				p.closeSpan(p.pos, p.pos)
+			}
 			return result, nil
 		}
 
@@ -114,20 +260,43 @@ func (p *Parser) parseElement() (map[string]interface{}, error) {
 		}
 
 		// Must be an attribute
+		attrs++
+		if max := p.limits.MaxAttributes; max > 0 && attrs > max {
+			return nil, p.limitError(limits.Attributes, max, p.pos)
+		}
+		attrStart := p.pos
 		attrName, attrValue, err := p.parseAttribute()
 		if err != nil {
-			return nil, fmt.Errorf("in element %q: %w", elementName, err)
+			return nil, err
+		}
+		if p.recordSpans {
+			p.attrSpan(attrName, attrStart)
 		}
 		// Prefix attribute names with @
-		result["@"+attrName] = attrValue
+		result[p.attrKey(attrName)] = attrValue
+	}
+
+	startTagEnd := p.pos
+
+	if p.maxDepth > 0 && p.depth >= p.maxDepth {
+		done, err := p.summarize(start, startTagEnd, result)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return result, nil
+		}
 	}
 
 	// Parse content (text, CDATA, child elements)
 	var textParts []string
 	var cdataParts []string
+	defer func(space bool) { p.space = space }(p.enterSpace(result))
 
 	for {
-		p.skipWhitespace()
+		if !p.space {
+			p.skipWhitespace()
+		}
 
 		if p.pos >= p.length {
 			return nil, fmt.Errorf("unexpected end of input, expected closing tag for %q", elementName)
@@ -135,23 +304,23 @@ func (p *Parser) parseElement() (map[string]interface{}, error) {
 
 		// Check for closing tag
 		if p.peekString("</") {
+			contentEnd := p.pos
 			p.pos += 2
 
 			closingName := p.readName()
 			if closingName != elementName {
-				return nil, fmt.Errorf("mismatched tags: opening %q, closing %q at position %d",
-					elementName, closingName, p.pos)
+				p.pos = contentEnd
+				return nil, p.mismatchedTags(p.open, closingName, contentEnd)
 			}
 
 			p.skipWhitespace()
 			if !p.consume('>') {
-				return nil, fmt.Errorf("expected '>' in closing tag for element %q at position %d",
-					elementName, p.pos)
+				return nil, fmt.Errorf("expected '>' in closing tag for element %q", elementName)
 			}
 
 			// Add accumulated text and CDATA if any
 			if len(textParts) > 0 {
-				text := trimSpace(joinStrings(textParts))
+				text := p.trimText(joinStrings(textParts))
 				if text != "" {
 					result["#text"] = text
 				}
@@ -160,6 +329,9 @@ func (p *Parser) parseElement() (map[string]interface{}, error) {
 				result["#cdata"] = joinStrings(cdataParts)
 			}
 
+			if p.recordSpans {
+				p.closeSpan(startTagEnd, contentEnd)
+			}
 			return result, nil
 		}
 
@@ -185,7 +357,7 @@ func (p *Parser) parseElement() (map[string]interface{}, error) {
 		if p.peek() == '<' {
 			// Save accumulated text before parsing child
 			if len(textParts) > 0 {
-				text := trimSpace(joinStrings(textParts))
+				text := p.trimText(joinStrings(textParts))
 				if text != "" {
 					result["#text"] = text
 				}
@@ -199,12 +371,30 @@ func (p *Parser) parseElement() (map[string]interface{}, error) {
 			p.pos = savedPos // restore position
 
 			if childName == "" {
-				return nil, fmt.Errorf("expected child element name at position %d", p.pos)
+				return nil, errors.New("expected child element name")
+			}
+
+			parentNode := p.node
+			if parentNode != nil {
+				next := parentNode.child(childName)
+				if next == nil {
+					if err := p.skipElement(); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				if next.keep {
+					next = nil
+				}
+				p.node = next
 			}
 
+			p.depth++
 			childNode, err := p.parseElement()
+			p.depth--
+			p.node = parentNode
 			if err != nil {
-				return nil, fmt.Errorf("in element %q: %w", elementName, err)
+				return nil, err
 			}
 
 			// Store child by element name
@@ -236,22 +426,29 @@ func (p *Parser) parseElement() (map[string]interface{}, error) {
 // Attribute = Name "=" String
 func (p *Parser) parseAttribute() (string, string, error) {
 	// Read attribute name
+	start := p.pos
 	attrName := p.readName()
 	if attrName == "" {
-		return "", "", fmt.Errorf("expected attribute name at position %d", p.pos)
+		return "", "", errors.New("expected attribute name")
+	}
+	if err := p.checkLength(start, p.pos); err != nil {
+		return "", "", err
 	}
 
 	p.skipWhitespace()
 
 	// Expect '='
 	if !p.consume('=') {
-		return "", "", fmt.Errorf("expected '=' after attribute name %q at position %d", attrName, p.pos)
+		return "", "", fmt.Errorf("expected '=' after attribute name %q", attrName)
 	}
 
 	p.skipWhitespace()
 
 	// Read string value
 	attrValue, err := p.parseString()
+	if _, ok := err.(*limits.Error); ok {
+		return "", "", err
+	}
 	if err != nil {
 		return "", "", fmt.Errorf("invalid value for attribute %q: %w", attrName, err)
 	}
@@ -267,7 +464,7 @@ func (p *Parser) parseString() (string, error) {
 
 	quote := p.data[p.pos]
 	if quote != '"' && quote != '\'' {
-		return "", fmt.Errorf("expected quote at position %d", p.pos)
+		return "", errors.New("expected quote")
 	}
 	p.pos++ // skip opening quote
 
@@ -279,13 +476,18 @@ func (p *Parser) parseString() (string, error) {
 
 		if c == quote {
 			// Found closing quote
-			s := string(p.data[start:p.pos])
+			if err := p.checkLength(start, p.pos); err != nil {
+				return "", err
+			}
+			s, err := p.decode(p.newlines(p.data[start:p.pos]), start)
+			if err != nil {
+				return "", err
+			}
 			p.pos++ // skip closing quote
 			return s, nil
 		}
 
-		// Handle escape sequences
-		if c == '\\' {
+		if c == '\\' && p.backslashEscapes {
 			// Found escape, use slow path
 			return p.parseStringWithEscapes(start, quote)
 		}
@@ -296,18 +498,39 @@ func (p *Parser) parseString() (string, error) {
 	return "", errors.New("unterminated string")
 }
 
-// parseStringWithEscapes handles strings containing escape sequences.
+// SetBackslashEscapes makes the parser read \", \', \\, \n, \t and \r in
+// attribute values as escape sequences, as it did before it followed the
+// XML specification. By default a backslash is an ordinary character and
+// only entity and character references are decoded.
+func (p *Parser) SetBackslashEscapes(on bool) {
+	p.backslashEscapes = on
+}
+
+// SetInvalidChars selects what happens to character references, such as
+// &#1;, to characters XML does not allow. By default, for charset.Reject,
+// they are a syntax error; charset.Strip removes them and charset.Replace
+// decodes them as U+FFFD. Such characters written literally are always an
+// error: the caller cleans the document first.
+func (p *Parser) SetInvalidChars(mode charset.Mode) {
+	p.invalidChars = mode
+}
+
+// parseStringWithEscapes handles strings containing escape sequences, see
+// SetBackslashEscapes.
 func (p *Parser) parseStringWithEscapes(start int, quote byte) (string, error) {
 	// We already found an escape at p.pos, everything before is in data[start:p.pos]
 	var buf []byte
-	buf = append(buf, p.data[start:p.pos]...)
+	buf = append(buf, p.newlines(p.data[start:p.pos])...)
 
 	for p.pos < p.length {
 		c := p.data[p.pos]
 
 		if c == quote {
+			if err := p.checkLength(start, p.pos); err != nil {
+				return "", err
+			}
 			p.pos++ // skip closing quote
-			return string(buf), nil
+			return p.decode(buf, start)
 		}
 
 		if c == '\\' {
@@ -333,6 +556,12 @@ func (p *Parser) parseStringWithEscapes(start int, quote byte) (string, error) {
 				// For other escapes, preserve the backslash
 				buf = append(buf, '\\', escaped)
 			}
+		} else if c == '\r' && !p.rawNewlines {
+			buf = append(buf, '\n')
+			p.pos++
+			if p.pos < p.length && p.data[p.pos] == '\n' {
+				p.pos++
+			}
 		} else {
 			buf = append(buf, c)
 			p.pos++
@@ -368,16 +597,19 @@ func (p *Parser) skipComment() error {
 		return nil
 	}
 	p.pos += 4
+	start := p.pos
 
 	// Find -->
 	for p.pos < p.length-2 {
 		if p.data[p.pos] == '-' && p.data[p.pos+1] == '-' && p.data[p.pos+2] == '>' {
+			end := p.pos
 			p.pos += 3
-			return nil
+			return p.checkLength(start, end)
 		}
 		p.pos++
 	}
 
+	p.pos = p.length
 	return errors.New("unterminated comment")
 }
 
@@ -388,11 +620,14 @@ func (p *Parser) parseText() (string, error) {
 	for p.pos < p.length {
 		c := p.data[p.pos]
 		if c == '<' {
-			return string(p.data[start:p.pos]), nil
+			break
 		}
 		p.pos++
 	}
-	return string(p.data[start:p.pos]), nil
+	if err := p.checkLength(start, p.pos); err != nil {
+		return "", err
+	}
+	return p.decode(p.newlines(p.data[start:p.pos]), start)
 }
 
 // parseCDataContent parses a CDATA section and returns its content.
@@ -408,13 +643,17 @@ func (p *Parser) parseCDataContent() (string, error) {
 	// Find ]]>
 	for p.pos < p.length-2 {
 		if p.data[p.pos] == ']' && p.data[p.pos+1] == ']' && p.data[p.pos+2] == '>' {
-			content := string(p.data[start:p.pos])
+			if err := p.checkLength(start, p.pos); err != nil {
+				return "", err
+			}
+			content := string(p.newlines(p.data[start:p.pos]))
 			p.pos += 3 // skip "]]>"
 			return content, nil
 		}
 		p.pos++
 	}
 
+	p.pos = p.length
 	return "", errors.New("unterminated CDATA section")
 }
 
@@ -485,9 +724,22 @@ func (p *Parser) readName() string {
 		p.pos++
 	}
 
+	if p.names != nil {
+		return p.names.Intern(p.data[start:p.pos])
+	}
 	return string(p.data[start:p.pos])
 }
 
+// attrKey returns the map key "@"+name of an attribute, from the intern pool
+// if one is set.
+func (p *Parser) attrKey(name string) string {
+	if p.names == nil {
+		return "@" + name
+	}
+	p.key = append(append(p.key[:0], '@'), name...)
+	return p.names.Intern(p.key)
+}
+
 // peek returns the current character without advancing.
 func (p *Parser) peek() byte {
 	if p.pos >= p.length {
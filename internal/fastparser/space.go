@@ -0,0 +1,33 @@
+package fastparser
+
+// SetPreserveSpace makes the parser keep text content as written: leading
+// and trailing whitespace, and text that is only whitespace, such as the
+// indentation between child elements. By default text is trimmed, except
+// inside an element marked xml:space="preserve"; xml:space="default"
+// returns to the setting of SetPreserveSpace.
+func (p *Parser) SetPreserveSpace(on bool) {
+	p.preserveSpace = on
+	p.space = on
+}
+
+// enterSpace sets whether the content of the element with the given
+// attributes keeps its whitespace, following its xml:space attribute, and
+// returns the setting to restore at its end.
+func (p *Parser) enterSpace(attrs map[string]interface{}) bool {
+	saved := p.space
+	switch attrs["@xml:space"] {
+	case "preserve":
+		p.space = true
+	case "default":
+		p.space = p.preserveSpace
+	}
+	return saved
+}
+
+// trimText returns text trimmed, unless whitespace is kept.
+func (p *Parser) trimText(text string) string {
+	if p.space {
+		return text
+	}
+	return trimSpace(text)
+}
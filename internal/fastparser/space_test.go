@@ -0,0 +1,39 @@
+package fastparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPreserveSpace(t *testing.T) {
+	input := "<r>\n <a xml:space=\"preserve\"> x <b>  </b><e xml:space=\"default\"> w </e></a>\n <c>  y  </c>\n <d xml:space=\"default\"> z </d>\n</r>"
+	tests := []struct {
+		preserve bool
+		want     map[string]interface{}
+	}{
+		{false, map[string]interface{}{
+			"a": map[string]interface{}{"@xml:space": "preserve", "#text": " x ", "b": map[string]interface{}{"#text": "  "},
+				"e": map[string]interface{}{"@xml:space": "default", "#text": "w"}},
+			"c": map[string]interface{}{"#text": "y"},
+			"d": map[string]interface{}{"@xml:space": "default", "#text": "z"},
+		}},
+		{true, map[string]interface{}{
+			"#text": "\n",
+			"a": map[string]interface{}{"@xml:space": "preserve", "#text": " x ", "b": map[string]interface{}{"#text": "  "},
+				"e": map[string]interface{}{"@xml:space": "default", "#text": " w "}},
+			"c": map[string]interface{}{"#text": "  y  "},
+			"d": map[string]interface{}{"@xml:space": "default", "#text": " z "},
+		}},
+	}
+	for _, tt := range tests {
+		p := NewParser([]byte(input))
+		p.SetPreserveSpace(tt.preserve)
+		got, err := p.Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("preserve=%v: got %q, want %q", tt.preserve, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package fastparser
+
+// Span records where an element appears in the source document.
+//
+// All offsets are byte offsets into the data passed to NewParser:
+//   - Start is the offset of the element's '<'
+//   - StartTagEnd is the offset just past the start tag ('>' or '/>')
+//   - ContentEnd is the offset of the closing tag's "</" (equal to End for self-closing elements)
+//   - End is the offset just past the element's last byte
+type Span struct {
+	Name        string
+	Start       int
+	StartTagEnd int
+	ContentEnd  int
+	End         int
+
+	// Value is the map produced for this element. It is the same map that is
+	// stored in the parent's result, so changes made through either are visible
+	// through both.
+	Value map[string]interface{}
+
+	// Attrs holds the spans of the attributes in the start tag, in document
+	// order.
+	Attrs []AttrSpan
+
+	// Children holds the spans of child elements in document order.
+	Children []*Span
+}
+
+// AttrSpan records where an attribute appears in its start tag: Start is
+// the offset of its name and End the offset just past its closing quote.
+type AttrSpan struct {
+	Name       string
+	Start, End int
+}
+
+// SelfClosing reports whether the element was written as <name/>.
+func (s *Span) SelfClosing() bool {
+	return s.StartTagEnd == s.End
+}
+
+// ParseWithSpans parses the XML data like Parse and additionally returns the
+// span tree for the root element.
+func (p *Parser) ParseWithSpans() (map[string]interface{}, *Span, error) {
+	p.recordSpans = true
+	p.rootSpan = nil
+	p.spanStack = p.spanStack[:0]
+
+	value, err := p.Parse()
+	if err != nil {
+		return nil, nil, err
+	}
+	return value.(map[string]interface{}), p.rootSpan, nil
+}
+
+// openSpan starts recording a span for an element whose '<' is at start.
+func (p *Parser) openSpan(name string, start int, value map[string]interface{}) {
+	span := &Span{Name: name, Start: start, Value: value}
+	if n := len(p.spanStack); n > 0 {
+		parent := p.spanStack[n-1]
+		parent.Children = append(parent.Children, span)
+	} else {
+		p.rootSpan = span
+	}
+	p.spanStack = append(p.spanStack, span)
+}
+
+// closeSpan finishes the innermost open span.
+func (p *Parser) closeSpan(startTagEnd, contentEnd int) {
+	n := len(p.spanStack)
+	span := p.spanStack[n-1]
+	span.StartTagEnd = startTagEnd
+	span.ContentEnd = contentEnd
+	span.End = p.pos
+	p.spanStack = p.spanStack[:n-1]
+}
+
+// attrSpan records the attribute name, read from start to p.pos, in the
+// innermost open span.
+func (p *Parser) attrSpan(name string, start int) {
+	span := p.spanStack[len(p.spanStack)-1]
+	span.Attrs = append(span.Attrs, AttrSpan{Name: name, Start: start, End: p.pos})
+}
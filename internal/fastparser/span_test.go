@@ -0,0 +1,54 @@
+package fastparser
+
+import (
+	"testing"
+)
+
+func TestParseWithSpans(t *testing.T) {
+	input := `<?xml version="1.0"?>
+<config a="1">
+  <server port="80"/>
+  <name>demo</name>
+</config>`
+
+	p := NewParser([]byte(input))
+	value, span, err := p.ParseWithSpans()
+	if err != nil {
+		t.Fatalf("ParseWithSpans() error = %v", err)
+	}
+	if span == nil || span.Name != "config" {
+		t.Fatalf("expected root span for config, got %+v", span)
+	}
+	if got := input[span.Start:span.End]; got[:7] != "<config" || got[len(got)-9:] != "</config>" {
+		t.Errorf("root span covers %q", got)
+	}
+	if len(span.Children) != 2 {
+		t.Fatalf("expected 2 child spans, got %d", len(span.Children))
+	}
+
+	server := span.Children[0]
+	if got := input[server.Start:server.End]; got != `<server port="80"/>` {
+		t.Errorf("server span = %q", got)
+	}
+	if !server.SelfClosing() {
+		t.Error("expected server to be self-closing")
+	}
+	if len(server.Attrs) != 1 || server.Attrs[0].Name != "port" || input[server.Attrs[0].Start:server.Attrs[0].End] != `port="80"` {
+		t.Errorf("server attributes = %+v", server.Attrs)
+	}
+
+	name := span.Children[1]
+	if got := input[name.Start:name.StartTagEnd]; got != "<name>" {
+		t.Errorf("name start tag = %q", got)
+	}
+	if got := input[name.StartTagEnd:name.ContentEnd]; got != "demo" {
+		t.Errorf("name content = %q", got)
+	}
+
+	// Span values are the same maps returned in the parse result
+	name.Value["#text"] = "changed"
+	root := value
+	if root["name"].(map[string]interface{})["#text"] != "changed" {
+		t.Error("expected span value to share the result map")
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !tinygo && !shapexml_lean
+
+package fastparser
+
+import (
+	"reflect"
+	"sync"
+)
+
+// structFieldCache caches the fields of each struct type by map key.
+var structFieldCache sync.Map // map[reflect.Type]map[string][]structField
+
+// structFields returns the decodable fields of struct type t, see
+// buildStructFields.
+func structFields(t reflect.Type) map[string][]structField {
+	if f, ok := structFieldCache.Load(t); ok {
+		return f.(map[string][]structField)
+	}
+	f, _ := structFieldCache.LoadOrStore(t, buildStructFields(t))
+	return f.(map[string][]structField)
+}
@@ -0,0 +1,12 @@
+//go:build tinygo || shapexml_lean
+
+package fastparser
+
+import "reflect"
+
+// structFields returns the decodable fields of struct type t, see
+// buildStructFields. Lean builds keep no process-wide cache and look the
+// fields up again for each struct value.
+func structFields(t reflect.Type) map[string][]structField {
+	return buildStructFields(t)
+}
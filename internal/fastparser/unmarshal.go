@@ -1,11 +1,26 @@
 package fastparser
 
 import (
+	"encoding"
 	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shapestone/shape-xml/internal/intern"
+	"github.com/shapestone/shape-xml/internal/lexical"
 )
 
+// durationType is decoded from Go ("1m30s") or ISO 8601 ("PT1M30S") duration strings.
+var durationType = reflect.TypeOf(time.Duration(0))
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// timeType is decoded in the layout given by the layout tag option, if any.
+var timeType = reflect.TypeOf(time.Time{})
+
 // Unmarshaler is the interface implemented by types that can unmarshal an XML description of themselves.
 type Unmarshaler interface {
 	UnmarshalXML([]byte) error
@@ -14,6 +29,12 @@ type Unmarshaler interface {
 // Unmarshal parses XML and unmarshals it into the value pointed to by v.
 // This is the fast path that bypasses AST construction.
 func Unmarshal(data []byte, v interface{}) error {
+	return UnmarshalInterned(data, v, nil)
+}
+
+// UnmarshalInterned works like Unmarshal, taking names from pool if it is
+// not nil.
+func UnmarshalInterned(data []byte, v interface{}, pool *intern.Pool) error {
 	rv := reflect.ValueOf(v)
 	if !rv.IsValid() || v == nil {
 		return errors.New("xml: Unmarshal(nil)")
@@ -34,6 +55,7 @@ func Unmarshal(data []byte, v interface{}) error {
 	}
 
 	p := NewParser(data)
+	p.SetInternPool(pool)
 	// Parse to map[string]interface{}
 	value, err := p.Parse()
 	if err != nil {
@@ -41,18 +63,32 @@ func Unmarshal(data []byte, v interface{}) error {
 	}
 
 	// Unmarshal from the parsed map
-	return unmarshalValue(value, rv.Elem())
+	return UnmarshalDocumentValue(value, rv.Elem(), p.RootName(), data)
 }
 
 // UnmarshalValue unmarshals a parsed value into a reflect.Value.
 // This is exported for use by the AST path unmarshal function.
+// Errors are *UnmarshalError values with paths relative to value.
 func UnmarshalValue(value interface{}, rv reflect.Value) error {
-	return unmarshalValue(value, rv)
+	return toUnmarshalError(unmarshalValue(value, rv), "", nil)
+}
+
+// UnmarshalDocumentValue is UnmarshalValue for the value parsed from the
+// document data with root element root, so that errors carry the full path
+// and the position of the failing element.
+func UnmarshalDocumentValue(value interface{}, rv reflect.Value, root string, data []byte) error {
+	if err := unmarshalValue(value, rv); err != nil {
+		return toUnmarshalError(err, root, data)
+	}
+	if root != "" {
+		setXMLName(value, rv, root)
+	}
+	return nil
 }
 
 // unmarshalValue unmarshals a parsed value into a reflect.Value.
 func unmarshalValue(value interface{}, rv reflect.Value) error {
-	if value == nil {
+	if value == nil || isNil(value) {
 		rv.Set(reflect.Zero(rv.Type()))
 		return nil
 	}
@@ -71,13 +107,29 @@ func unmarshalValue(value interface{}, rv reflect.Value) error {
 		return unmarshalValue(value, rv.Elem())
 	}
 
+	// A single element decodes into a slice or array as a one-item list,
+	// since the parser only produces arrays for repeated elements; an empty
+	// element is one zero-valued item, as in encoding/xml. Slice and array
+	// types decoding from text, such as net.IP, are scalars.
+	if _, ok := value.([]interface{}); !ok && (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && !isScalar(rv) {
+		return unmarshalArray([]interface{}{value}, rv)
+	}
+
 	// Route based on Go type
 	switch v := value.(type) {
 	case map[string]interface{}:
-		// If target is a string and map has #text, extract text content
-		if rv.Kind() == reflect.String {
-			text := extractTextContent(v)
-			return unmarshalString(text, rv)
+		// Scalar targets take the element's text content
+		if isScalar(rv) {
+			if hasChildElements(v) {
+				return fmt.Errorf("xml: cannot unmarshal object into Go value of type %s", rv.Type())
+			}
+			if _, ok := v["#cdata"]; ok {
+				return unmarshalString(extractTextContent(v), rv)
+			}
+			if text, ok := v["#text"]; ok {
+				return unmarshalValue(text, rv)
+			}
+			return unmarshalString("", rv)
 		}
 		switch rv.Kind() {
 		case reflect.Struct:
@@ -92,70 +144,163 @@ func unmarshalValue(value interface{}, rv reflect.Value) error {
 	case string:
 		return unmarshalString(v, rv)
 	default:
+		// Named string types, such as the number type produced by the
+		// UseNumber option, decode like their text.
+		sv := reflect.ValueOf(value)
+		if sv.Kind() == reflect.String {
+			return unmarshalString(sv.String(), rv)
+		}
+		// Values already converted by the caller, such as typed values from
+		// the WithTypes option, are stored directly or via their text.
+		if sv.Type().AssignableTo(rv.Type()) {
+			rv.Set(sv)
+			return nil
+		}
+		if rv.Kind() != reflect.String {
+			switch sv.Kind() {
+			case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+				return unmarshalString(fmt.Sprint(value), rv)
+			}
+		}
 		return fmt.Errorf("xml: unexpected value type %T", value)
 	}
 }
 
-// unmarshalStruct unmarshals a map into a struct.
-func unmarshalStruct(m map[string]interface{}, rv reflect.Value) error {
-	structType := rv.Type()
+// isNil reports whether value is an element marked xsi:nil="true", as
+// written by Marshal for floats under FloatNil.
+func isNil(value interface{}) bool {
+	m, ok := value.(map[string]interface{})
+	return ok && m["@xsi:nil"] == "true"
+}
 
-	// Build field map
-	fieldMap := make(map[string]int)
-	for i := 0; i < structType.NumField(); i++ {
-		field := structType.Field(i)
-		if field.PkgPath != "" { // Skip unexported fields
-			continue
+// isScalar reports whether rv decodes from text content.
+func isScalar(rv reflect.Value) bool {
+	if _, ok := textUnmarshaler(rv); ok {
+		return true
+	}
+	switch rv.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return rv.Type().Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
+// hasChildElements reports whether m holds child elements rather than only
+// attributes and text.
+func hasChildElements(m map[string]interface{}) bool {
+	for k := range m {
+		if k != "" && k[0] != '@' && k[0] != '#' {
+			return true
 		}
+	}
+	return false
+}
+
+// structField maps a struct field to the key it decodes from.
+type structField struct {
+	// index is the field's index path, which leads through embedded
+	// structs for promoted fields.
+	index []int
+	// key is the attribute holding the map key of each element, for map
+	// fields tagged key=name.
+	key string
+	// path holds the element names below the key for fields tagged with a
+	// nested path such as "tags>tag".
+	path []string
+	// name is the element name recorded in the XMLName field of the
+	// structs the field decodes into; empty if their type has none.
+	name string
+	// layout is the time layout of a time.Time field with the layout
+	// option.
+	layout string
+}
 
-		// Check XML tag
+// buildStructFields returns the decodable fields of struct type t by map
+// key: "@name" for attributes, "#text" for chardata, "#cdata" for cdata and
+// the element name for child elements. Fields with a nested path are listed
+// under its first element, which several fields may share. Fields promoted
+// from embedded structs are listed as fields of t, see VisibleFields.
+func buildStructFields(t reflect.Type) map[string][]structField {
+	fields := make(map[string][]structField)
+	for _, field := range VisibleFields(t) {
 		tag := field.Tag.Get("xml")
 		if tag == "-" {
 			continue
 		}
+		if len(field.Index) == 1 && field.Name == "XMLName" {
+			if _, ok := xmlNameField(t); ok {
+				continue // holds the element's own name
+			}
+		}
 
-		// Get XML name from tag or use field name
-		xmlName := field.Name
-		isAttr := false
-		isCharData := false
-
-		if tag != "" {
-			// Parse tag: "name,attr" or ",chardata"
-			for idx := 0; idx < len(tag); idx++ {
-				if tag[idx] == ',' {
-					if idx > 0 {
-						xmlName = tag[:idx]
-					}
-					remainder := tag[idx+1:]
-					if remainder == "attr" {
-						isAttr = true
-					} else if remainder == "chardata" {
-						isCharData = true
-					}
-					break
-				}
+		// Parse tag: "name,opt,opt" with options attr, chardata, cdata,
+		// key=name, layout=layout and omitempty; omitempty only affects
+		// encoding. A layout extends to the end of the tag.
+		parts := strings.Split(tag, ",")
+		xmlName := parts[0]
+		if i := strings.LastIndexByte(xmlName, ' '); i >= 0 {
+			// "uri name": elements match by their name as written.
+			xmlName = xmlName[i+1:]
+		}
+		tagName, named := xmlNameTag(field.Type)
+		if xmlName == "" {
+			// The XMLName tag of the field's type names its element.
+			xmlName = tagName
+		}
+		if xmlName == "" {
+			xmlName = field.Name
+		}
+		key := xmlName
+		sf := structField{index: field.Index}
+		for j, opt := range parts[1:] {
+			if layout, ok := strings.CutPrefix(strings.TrimLeft(opt, " "), "layout="); ok {
+				sf.layout = strings.Join(append([]string{layout}, parts[j+2:]...), ",")
+				break
 			}
-			if !isAttr && !isCharData && tag[0] != ',' {
-				xmlName = tag
+			switch opt = strings.TrimSpace(opt); opt {
+			case "attr":
+				key = "@" + xmlName
+			case "chardata":
+				key = "#text"
+			case "cdata":
+				key = "#cdata"
+			default:
+				if name, ok := strings.CutPrefix(opt, "key="); ok {
+					sf.key = name
+				}
 			}
 		}
-
-		// Map XML name to field index
-		if isAttr {
-			fieldMap["@"+xmlName] = i
-		} else if isCharData {
-			fieldMap["#text"] = i
-		} else {
-			fieldMap[xmlName] = i
+		if key == xmlName && strings.Contains(xmlName, ">") {
+			path := strings.Split(xmlName, ">")
+			key, sf.path = path[0], path[1:]
+		}
+		if named && key != "" && key[0] != '@' && key[0] != '#' && sf.key == "" {
+			sf.name = xmlName
+			if len(sf.path) > 0 {
+				sf.name = sf.path[len(sf.path)-1]
+			}
 		}
+		fields[key] = append(fields[key], sf)
 	}
+	return fields
+}
+
+// unmarshalStruct unmarshals a map into a struct.
+func unmarshalStruct(m map[string]interface{}, rv reflect.Value) error {
+	fields := structFields(rv.Type())
 
 	// Populate struct fields from map
 	for key, value := range m {
-		if fieldIdx, ok := fieldMap[key]; ok {
-			fieldValue := rv.Field(fieldIdx)
-			if err := unmarshalValue(value, fieldValue); err != nil {
-				return fmt.Errorf("field %s: %w", structType.Field(fieldIdx).Name, err)
+		for _, field := range fields[key] {
+			fv, _ := FieldByIndex(rv, field.index, true)
+			if err := unmarshalField(value, fv, field); err != nil {
+				return wrapKey(err, key)
 			}
 		}
 	}
@@ -163,6 +308,68 @@ func unmarshalStruct(m map[string]interface{}, rv reflect.Value) error {
 	return nil
 }
 
+// unmarshalField unmarshals the value stored under a field's key into the
+// field, following the field's nested path first.
+func unmarshalField(value interface{}, fv reflect.Value, field structField) error {
+	if len(field.path) > 0 {
+		var ok bool
+		if value, ok = descend(value, field.path); !ok {
+			return nil // a missing element leaves the field untouched
+		}
+	}
+	var err error
+	if field.key != "" {
+		err = unmarshalKeyedMap(value, fv, field.key)
+	} else if field.layout != "" {
+		err = unmarshalTime(value, fv, field.layout)
+	} else if err = unmarshalValue(value, fv); err == nil && field.name != "" {
+		setXMLName(value, fv, field.name)
+	}
+	if err != nil {
+		for i := len(field.path) - 1; i >= 0; i-- {
+			err = wrapPath(err, field.path[i])
+		}
+	}
+	return err
+}
+
+// descend returns the value of the element that path leads to from a
+// wrapper element's value, and false if there is none. Repeated elements
+// along the path are merged: the values found below each of them are
+// collected in document order, so a list split across several wrappers,
+// or held in a wrapper with a single item, decodes as one list.
+func descend(value interface{}, path []string) (interface{}, bool) {
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
+	for _, name := range path {
+		var next []interface{}
+		for _, v := range values {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				continue // text-only or empty wrapper
+			}
+			switch child := m[name].(type) {
+			case nil:
+			case []interface{}:
+				next = append(next, child...)
+			default:
+				next = append(next, child)
+			}
+		}
+		values = next
+	}
+	switch len(values) {
+	case 0:
+		return nil, false
+	case 1:
+		return values[0], true
+	default:
+		return values, true
+	}
+}
+
 // unmarshalMap unmarshals a map into a Go map.
 func unmarshalMap(m map[string]interface{}, rv reflect.Value) error {
 	if rv.IsNil() {
@@ -180,7 +387,7 @@ func unmarshalMap(m map[string]interface{}, rv reflect.Value) error {
 
 		elemValue := reflect.New(valueType).Elem()
 		if err := unmarshalValue(v, elemValue); err != nil {
-			return fmt.Errorf("map key %s: %w", k, err)
+			return wrapKey(err, k)
 		}
 
 		rv.SetMapIndex(keyValue, elemValue)
@@ -189,6 +396,56 @@ func unmarshalMap(m map[string]interface{}, rv reflect.Value) error {
 	return nil
 }
 
+// unmarshalKeyedMap unmarshals the elements of a map field tagged key=attr
+// into a map keyed by each element's attribute attr. An element whose key
+// is already present is decoded over the existing entry, merging the two.
+func unmarshalKeyedMap(value interface{}, rv reflect.Value, attr string) error {
+	if rv.Kind() != reflect.Map {
+		return fmt.Errorf("xml: key=%s needs a map, not %s", attr, rv.Type())
+	}
+	elems, ok := value.([]interface{})
+	if !ok {
+		elems = []interface{}{value}
+	}
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMap(rv.Type()))
+	}
+
+	for i, elem := range elems {
+		err := unmarshalKeyedEntry(elem, rv, attr)
+		if err != nil && len(elems) > 1 {
+			err = wrapIndex(err, i)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalKeyedEntry stores one element of a key=attr map field in rv.
+func unmarshalKeyedEntry(elem interface{}, rv reflect.Value, attr string) error {
+	m, _ := elem.(map[string]interface{})
+	k, ok := m["@"+attr].(string)
+	if !ok {
+		return fmt.Errorf("xml: missing key attribute %q", attr)
+	}
+	keyValue := reflect.New(rv.Type().Key()).Elem()
+	if err := unmarshalString(k, keyValue); err != nil {
+		return wrapPath(err, "@"+attr)
+	}
+
+	elemValue := reflect.New(rv.Type().Elem()).Elem()
+	if existing := rv.MapIndex(keyValue); existing.IsValid() {
+		elemValue.Set(existing)
+	}
+	if err := unmarshalValue(elem, elemValue); err != nil {
+		return err
+	}
+	rv.SetMapIndex(keyValue, elemValue)
+	return nil
+}
+
 // unmarshalArray unmarshals an array into a Go slice.
 func unmarshalArray(arr []interface{}, rv reflect.Value) error {
 	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
@@ -199,12 +456,20 @@ func unmarshalArray(arr []interface{}, rv reflect.Value) error {
 		rv.Set(reflect.MakeSlice(rv.Type(), len(arr), len(arr)))
 	}
 
+	// Array entries without an element are zeroed, as a slice is replaced.
+	for i := len(arr); i < rv.Len(); i++ {
+		rv.Index(i).Set(reflect.Zero(rv.Type().Elem()))
+	}
+
 	for i, elem := range arr {
 		if i >= rv.Len() {
 			break // Array is full
 		}
 		if err := unmarshalValue(elem, rv.Index(i)); err != nil {
-			return fmt.Errorf("array index %d: %w", i, err)
+			if len(arr) == 1 {
+				return err // a single element, not a repeated one
+			}
+			return wrapIndex(err, i)
 		}
 	}
 
@@ -212,28 +477,193 @@ func unmarshalArray(arr []interface{}, rv reflect.Value) error {
 }
 
 // unmarshalString unmarshals a string or map with #text into a Go value.
+// Numbers and booleans are parsed from the text with surrounding whitespace
+// removed; empty text decodes as zero. A []byte receives the text bytes,
+// nil for empty text. Types implementing encoding.TextUnmarshaler receive
+// non-empty text as is.
 func unmarshalString(s string, rv reflect.Value) error {
+	if u, ok := textUnmarshaler(rv); ok {
+		if strings.TrimSpace(s) == "" {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		if err := u.UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("xml: cannot parse %q as %s: %w", s, rv.Type(), err)
+		}
+		return nil
+	}
+	if rv.Type() == durationType {
+		d, err := lexical.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("xml: cannot parse %q as time.Duration: %w", s, err)
+		}
+		rv.SetInt(int64(d))
+		return nil
+	}
 	switch rv.Kind() {
 	case reflect.String:
 		rv.SetString(s)
 		return nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() != reflect.Uint8 {
+			break
+		}
+		if s == "" {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		rv.SetBytes([]byte(s))
+		return nil
 	case reflect.Interface:
 		if rv.NumMethod() == 0 {
 			rv.Set(reflect.ValueOf(s))
 			return nil
 		}
+	case reflect.Bool:
+		t := strings.TrimSpace(s)
+		if t == "" {
+			rv.SetBool(false)
+			return nil
+		}
+		b, err := strconv.ParseBool(t)
+		if err != nil {
+			return fmt.Errorf("xml: cannot parse %q as %s", s, rv.Type())
+		}
+		rv.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		t := strings.TrimSpace(s)
+		if t == "" {
+			rv.SetInt(0)
+			return nil
+		}
+		n, err := strconv.ParseInt(t, 10, rv.Type().Bits())
+		if err != nil {
+			return parseError(s, rv, err)
+		}
+		rv.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		t := strings.TrimSpace(s)
+		if t == "" {
+			rv.SetUint(0)
+			return nil
+		}
+		n, err := strconv.ParseUint(t, 10, rv.Type().Bits())
+		if err != nil {
+			return parseError(s, rv, err)
+		}
+		rv.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		t := strings.TrimSpace(s)
+		if t == "" {
+			rv.SetFloat(0)
+			return nil
+		}
+		f, err := strconv.ParseFloat(t, rv.Type().Bits())
+		if err != nil {
+			return parseError(s, rv, err)
+		}
+		rv.SetFloat(f)
+		return nil
 	}
 	return fmt.Errorf("xml: cannot unmarshal string into Go value of type %s", rv.Type())
 }
 
-// Extract text content from a value that might be a string or map with #text
+// parseError returns the error for text s that does not parse as the number
+// type of rv. Values out of the type's range say so and wrap
+// strconv.ErrRange.
+func parseError(s string, rv reflect.Value, err error) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf("xml: cannot parse %q as %s: %w", s, rv.Type(), strconv.ErrRange)
+	}
+	return fmt.Errorf("xml: cannot parse %q as %s", s, rv.Type())
+}
+
+// unmarshalTime decodes value into rv, a time.Time or a pointer, slice or
+// array of them, parsing times in layout.
+func unmarshalTime(value interface{}, rv reflect.Value, layout string) error {
+	if value == nil || isNil(value) {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return unmarshalTime(value, rv.Elem(), layout)
+	case reflect.Slice, reflect.Array:
+		arr, ok := value.([]interface{})
+		if !ok {
+			if m, ok := value.(map[string]interface{}); ok && len(m) == 0 && rv.Kind() == reflect.Slice {
+				rv.Set(reflect.Zero(rv.Type()))
+				return nil
+			}
+			arr = []interface{}{value}
+		}
+		if rv.Kind() == reflect.Slice {
+			rv.Set(reflect.MakeSlice(rv.Type(), len(arr), len(arr)))
+		}
+		for i := 0; i < len(arr) && i < rv.Len(); i++ {
+			if err := unmarshalTime(arr[i], rv.Index(i), layout); err != nil {
+				if len(arr) == 1 {
+					return err
+				}
+				return wrapIndex(err, i)
+			}
+		}
+		return nil
+	}
+	if rv.Type() != timeType {
+		return fmt.Errorf("xml: layout needs a time.Time, not %s", rv.Type())
+	}
+	if m, ok := value.(map[string]interface{}); ok && hasChildElements(m) {
+		return fmt.Errorf("xml: cannot unmarshal object into Go value of type %s", rv.Type())
+	}
+	s := strings.TrimSpace(extractTextContent(value))
+	if s == "" {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return fmt.Errorf("xml: cannot parse %q as time.Time: %w", s, err)
+	}
+	rv.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// textUnmarshaler returns the address of rv as an encoding.TextUnmarshaler
+// if its type implements one, as time.Time and net.IP do.
+func textUnmarshaler(rv reflect.Value) (encoding.TextUnmarshaler, bool) {
+	if rv.Kind() == reflect.Ptr || !rv.CanAddr() || !rv.Addr().Type().Implements(textUnmarshalerType) {
+		return nil, false
+	}
+	return rv.Addr().Interface().(encoding.TextUnmarshaler), true
+}
+
+// Extract text content from a value that might be a string or map with
+// #text; CDATA content counts as text and follows it.
 func extractTextContent(value interface{}) string {
 	switch v := value.(type) {
 	case string:
 		return v
 	case map[string]interface{}:
-		if text, ok := v["#text"]; ok {
+		text, hasText := v["#text"]
+		cdata, hasCDATA := v["#cdata"]
+		switch {
+		case hasText && hasCDATA:
+			return extractTextContent(text) + extractTextContent(cdata)
+		case hasText:
 			return extractTextContent(text)
+		case hasCDATA:
+			return extractTextContent(cdata)
+		}
+	default:
+		if sv := reflect.ValueOf(value); sv.Kind() == reflect.String {
+			return sv.String()
 		}
 	}
 	return ""
@@ -0,0 +1,165 @@
+package fastparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// UnmarshalError reports a value that could not be decoded into its Go
+// target, together with the location of the element or attribute holding it.
+type UnmarshalError struct {
+	// Path locates the value from the root element, e.g.
+	// "/orders/order[3]/total" or "/orders/order/@id". Repeated elements
+	// carry their 1-based position among same-named siblings.
+	Path string
+	// Line and Column give the 1-based position of the element's start tag,
+	// or 0 if unknown.
+	Line, Column int
+	// Err is the underlying conversion error.
+	Err error
+}
+
+func (e *UnmarshalError) Error() string {
+	msg := strings.TrimPrefix(e.Err.Error(), "xml: ")
+	if e.Line > 0 {
+		return fmt.Sprintf("xml: %s (line %d, column %d): %s", e.Path, e.Line, e.Column, msg)
+	}
+	return fmt.Sprintf("xml: %s: %s", e.Path, msg)
+}
+
+// Unwrap returns the underlying conversion error.
+func (e *UnmarshalError) Unwrap() error {
+	return e.Err
+}
+
+// pathError collects the location of a decode error while it propagates up
+// from the failing value. Segments are appended innermost first, so nothing
+// is allocated unless decoding fails.
+type pathError struct {
+	segments []string
+	err      error
+}
+
+func (e *pathError) Error() string {
+	return e.path("") + ": " + e.err.Error()
+}
+
+func (e *pathError) Unwrap() error {
+	return e.err
+}
+
+// path returns the location below root, outermost segment first.
+func (e *pathError) path(root string) string {
+	var b strings.Builder
+	if root != "" {
+		b.WriteString("/" + root)
+	}
+	for i := len(e.segments) - 1; i >= 0; i-- {
+		seg := e.segments[i]
+		if !strings.HasPrefix(seg, "[") && (b.Len() > 0 || root != "") {
+			b.WriteByte('/')
+		}
+		b.WriteString(seg)
+	}
+	return b.String()
+}
+
+// wrapPath adds the element or attribute key seg to the location of err.
+func wrapPath(err error, seg string) error {
+	if pe, ok := err.(*pathError); ok {
+		pe.segments = append(pe.segments, seg)
+		return pe
+	}
+	return &pathError{segments: []string{seg}, err: err}
+}
+
+// wrapKey adds the map key of a failing value to the location of err.
+// Text and CDATA keys belong to the element itself and add nothing.
+func wrapKey(err error, key string) error {
+	if key != "" && key[0] == '#' {
+		return err
+	}
+	return wrapPath(err, key)
+}
+
+// wrapIndex adds the 0-based position i among repeated elements to the
+// location of err.
+func wrapIndex(err error, i int) error {
+	return wrapPath(err, "["+strconv.Itoa(i+1)+"]")
+}
+
+// toUnmarshalError converts a located decode error into an UnmarshalError
+// with a path starting at root. If data is the parsed document, the
+// position of the failing element is looked up in it.
+func toUnmarshalError(err error, root string, data []byte) error {
+	if err == nil {
+		return nil
+	}
+	pe, ok := err.(*pathError)
+	if !ok {
+		if root == "" {
+			return err
+		}
+		// The root element itself failed to decode.
+		pe = &pathError{err: err}
+	}
+	ue := &UnmarshalError{Path: pe.path(root), Err: pe.err}
+	if data != nil {
+		if offset, ok := locate(data, pe.segments); ok {
+			ue.Line, ue.Column = lineColumn(data, offset)
+		}
+	}
+	return ue
+}
+
+// locate returns the offset of the start tag of the element the segments
+// lead to from the root, innermost segment first. Attribute segments
+// resolve to their element.
+func locate(data []byte, segments []string) (int, bool) {
+	_, span, err := NewParser(data).ParseWithSpans()
+	if err != nil || span == nil {
+		return 0, false
+	}
+	for i := len(segments) - 1; i >= 0; i-- {
+		seg := segments[i]
+		if strings.HasPrefix(seg, "@") {
+			break
+		}
+		if strings.HasPrefix(seg, "[") {
+			continue
+		}
+		index := 1
+		if i > 0 && strings.HasPrefix(segments[i-1], "[") {
+			index, _ = strconv.Atoi(segments[i-1][1 : len(segments[i-1])-1])
+		}
+		var next *Span
+		for _, child := range span.Children {
+			if child.Name == seg {
+				if index--; index == 0 {
+					next = child
+					break
+				}
+			}
+		}
+		if next == nil {
+			break
+		}
+		span = next
+	}
+	return span.Start, true
+}
+
+// lineColumn converts a byte offset into a 1-based line and column.
+func lineColumn(data []byte, offset int) (line, column int) {
+	line, column = 1, 1
+	for _, c := range data[:offset] {
+		if c == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return line, column
+}
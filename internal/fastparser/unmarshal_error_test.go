@@ -0,0 +1,83 @@
+package fastparser
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+type errOrder struct {
+	ID    int    `xml:"id,attr"`
+	Total int    `xml:"total"`
+	Note  string `xml:",chardata"`
+}
+
+type errOrders struct {
+	Orders []errOrder       `xml:"order"`
+	Limits map[string]uint8 `xml:"limits"`
+	Single *errOrder        `xml:"single"`
+}
+
+func TestUnmarshalError_Path(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		path   string
+		line   int
+		column int
+	}{
+		{"repeated element", "<orders>\n  <order><total>1</total></order>\n  <order><total>2</total></order>\n  <order><total>abc</total></order>\n</orders>",
+			"/orders/order[3]/total", 4, 10},
+		{"attribute", `<orders><order id="x"/></orders>`, "/orders/order/@id", 1, 9},
+		{"map value", `<orders><limits><max>300</max></limits></orders>`, "/orders/limits/max", 1, 17},
+		{"pointer", `<orders><single><total>1.5</total></single></orders>`, "/orders/single/total", 1, 17},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v errOrders
+			err := Unmarshal([]byte(tt.input), &v)
+			var ue *UnmarshalError
+			if !errors.As(err, &ue) {
+				t.Fatalf("expected *UnmarshalError, got %v", err)
+			}
+			if ue.Path != tt.path || ue.Line != tt.line || ue.Column != tt.column {
+				t.Errorf("got %s at %d:%d, want %s at %d:%d", ue.Path, ue.Line, ue.Column, tt.path, tt.line, tt.column)
+			}
+			var numErr *strconv.NumError
+			if errors.As(err, &numErr) {
+				t.Errorf("conversion error should not leak strconv details: %v", err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalError_Message(t *testing.T) {
+	var v errOrders
+	err := Unmarshal([]byte("<orders><order/><order><total>abc</total></order></orders>"), &v)
+	want := `xml: /orders/order[2]/total (line 1, column 24): cannot parse "abc" as int`
+	if err == nil || err.Error() != want {
+		t.Errorf("got  %v\nwant %s", err, want)
+	}
+
+	// A failing root element names just the root.
+	var n int
+	err = Unmarshal([]byte("<n>x</n>"), &n)
+	if err == nil || err.Error() != `xml: /n (line 1, column 1): cannot parse "x" as int` {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestUnmarshalValue_RelativePath(t *testing.T) {
+	var v errOrders
+	err := UnmarshalValue(map[string]interface{}{
+		"order": []interface{}{
+			map[string]interface{}{},
+			map[string]interface{}{"@id": "z"},
+		},
+	}, reflect.ValueOf(&v).Elem())
+	var ue *UnmarshalError
+	if !errors.As(err, &ue) || ue.Path != "order[2]/@id" || ue.Line != 0 {
+		t.Errorf("got %#v", err)
+	}
+}
@@ -1,8 +1,11 @@
 package fastparser
 
 import (
+	"errors"
 	"reflect"
+	"strconv"
 	"testing"
+	"time"
 )
 
 type SimpleStruct struct {
@@ -24,6 +27,22 @@ type WithOmitEmpty struct {
 	Value string `xml:"value,omitempty"`
 }
 
+type TypedStruct struct {
+	ID   int    `xml:"id,attr,omitempty"`
+	Age  uint8  `xml:"age"`
+	Name string `xml:"name"`
+}
+
+type TextStruct struct {
+	Text string `xml:",chardata"`
+	Raw  string `xml:",cdata"`
+}
+
+type PointerStruct struct {
+	ID   *int          `xml:"id,attr"`
+	User *SimpleStruct `xml:"user"`
+}
+
 type CustomUnmarshaler struct {
 	Data string
 }
@@ -204,13 +223,60 @@ func TestUnmarshalStruct(t *testing.T) {
 			target: &WithAttributes{},
 			want:   &WithAttributes{ID: "123", Name: "Test"},
 		},
-		// Omit empty only affects marshaling, not unmarshaling
-		// {
-		// 	name:   "omit empty fields",
-		// 	input:  map[string]interface{}{"name": map[string]interface{}{"#text": "Test"}},
-		// 	target: &WithOmitEmpty{},
-		// 	want:   &WithOmitEmpty{Name: "Test"},
-		// },
+		{
+			name:   "omit empty fields",
+			input:  map[string]interface{}{"name": map[string]interface{}{"#text": "Test"}},
+			target: &WithOmitEmpty{},
+			want:   &WithOmitEmpty{Name: "Test"},
+		},
+		{
+			name:   "typed fields",
+			input:  map[string]interface{}{"@id": "7", "age": map[string]interface{}{"#text": "42"}, "name": "Bob"},
+			target: &TypedStruct{},
+			want:   &TypedStruct{ID: 7, Age: 42, Name: "Bob"},
+		},
+		{
+			name:   "chardata and cdata",
+			input:  map[string]interface{}{"#text": "plain", "#cdata": "a<b"},
+			target: &TextStruct{},
+			want:   &TextStruct{Text: "plain", Raw: "a<b"},
+		},
+		{
+			name:   "single element into slice",
+			input:  map[string]interface{}{"tag": map[string]interface{}{"#text": "x"}},
+			target: &struct{ Tags []string `xml:"tag"` }{},
+			want:   &struct{ Tags []string `xml:"tag"` }{Tags: []string{"x"}},
+		},
+		{
+			name:   "pointer fields",
+			input:  map[string]interface{}{"@id": "3", "user": map[string]interface{}{"name": "Al"}},
+			target: &PointerStruct{},
+			want:   &PointerStruct{ID: func() *int { v := 3; return &v }(), User: &SimpleStruct{Name: "Al"}},
+		},
+		{
+			name:   "xsi:nil leaves pointer nil",
+			input:  map[string]interface{}{"user": map[string]interface{}{"@xsi:nil": "true"}},
+			target: &PointerStruct{},
+			want:   &PointerStruct{},
+		},
+		{
+			name:   "empty element into slice",
+			input:  map[string]interface{}{"tag": map[string]interface{}{}},
+			target: &struct{ Tags []string `xml:"tag"` }{Tags: []string{"old"}},
+			want:   &struct{ Tags []string `xml:"tag"` }{Tags: []string{""}},
+		},
+		{
+			name:    "invalid number",
+			input:   map[string]interface{}{"age": "old"},
+			target:  &TypedStruct{},
+			wantErr: true,
+		},
+		{
+			name:    "object into number",
+			input:   map[string]interface{}{"age": map[string]interface{}{"years": "3"}},
+			target:  &TypedStruct{},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -282,13 +348,12 @@ func TestUnmarshalArray(t *testing.T) {
 			target: &[]string{},
 			want:   &[]string{"a", "b", "c"},
 		},
-		// Note: unmarshal doesn't support string-to-int conversion
-		// {
-		// 	name:   "int slice",
-		// 	input:  []interface{}{"1", "2", "3"},
-		// 	target: &[]int{},
-		// 	want:   &[]int{1, 2, 3},
-		// },
+		{
+			name:   "int slice",
+			input:  []interface{}{"1", "2", "3"},
+			target: &[]int{},
+			want:   &[]int{1, 2, 3},
+		},
 		{
 			name:   "empty slice",
 			input:  []interface{}{},
@@ -332,29 +397,64 @@ func TestUnmarshalString(t *testing.T) {
 			target: new(string),
 			want:   stringPtr("hello"),
 		},
-		// Note: unmarshalString only handles string types, not numeric/bool conversions
 		{
-			name:    "string to int - unsupported",
-			input:   "123",
+			name:   "string to int",
+			input:  "123",
+			target: new(int),
+			want:   intPtr(123),
+		},
+		{
+			name:   "string to int64 with whitespace",
+			input:  " -456 ",
+			target: new(int64),
+			want:   func() *int64 { v := int64(-456); return &v }(),
+		},
+		{
+			name:   "string to uint8",
+			input:  "255",
+			target: new(uint8),
+			want:   func() *uint8 { v := uint8(255); return &v }(),
+		},
+		{
+			name:   "string to float64",
+			input:  "3.14",
+			target: new(float64),
+			want:   func() *float64 { v := 3.14; return &v }(),
+		},
+		{
+			name:   "string to bool",
+			input:  "true",
+			target: new(bool),
+			want:   func() *bool { v := true; return &v }(),
+		},
+		{
+			name:   "empty string to int",
+			input:  "",
+			target: new(int),
+			want:   intPtr(0),
+		},
+		{
+			name:    "invalid int",
+			input:   "12a",
 			target:  new(int),
 			wantErr: true,
 		},
 		{
-			name:    "string to int64 - unsupported",
-			input:   "456",
-			target:  new(int64),
+			name:    "int overflow",
+			input:   "256",
+			target:  new(uint8),
 			wantErr: true,
 		},
 		{
-			name:    "string to float64 - unsupported",
-			input:   "3.14",
-			target:  new(float64),
+			name:    "invalid bool",
+			input:   "yes",
+			target:  new(bool),
 			wantErr: true,
 		},
 		{
-			name:    "string to bool - unsupported",
-			input:   "true",
-			target:  new(bool),
+			name:    "string to struct",
+			input:   "x",
+			target:  new(struct{}),
 			wantErr: true,
 		},
 	}
@@ -374,6 +474,70 @@ func TestUnmarshalString(t *testing.T) {
 	}
 }
 
+func TestUnmarshal_NumericFields(t *testing.T) {
+	type numbers struct {
+		I   int     `xml:"i"`
+		I8  int8    `xml:"i8"`
+		I16 int16   `xml:"i16"`
+		I32 int32   `xml:"i32,attr"`
+		I64 int64   `xml:"i64"`
+		U   uint    `xml:"u"`
+		U8  uint8   `xml:"u8,attr"`
+		U16 uint16  `xml:"u16"`
+		U32 uint32  `xml:"u32"`
+		U64 uint64  `xml:"u64"`
+		F32 float32 `xml:"f32"`
+		F64 float64 `xml:"f64"`
+		B   bool    `xml:"b"`
+		B1  bool    `xml:"b1,attr"`
+	}
+	input := `<n i32=" -7 " u8="200" b1="1">
+		<i>30</i><i8>-128</i8><i16>32767</i16><i64>-9223372036854775808</i64>
+		<u>7</u><u16>65535</u16><u32>4294967295</u32><u64>18446744073709551615</u64>
+		<f32> 1.5 </f32><f64>-2.5e-3</f64><b>
+			true
+		</b></n>`
+	var got numbers
+	if err := Unmarshal([]byte(input), &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := numbers{
+		I: 30, I8: -128, I16: 32767, I32: -7, I64: -9223372036854775808,
+		U: 7, U8: 200, U16: 65535, U32: 4294967295, U64: 18446744073709551615,
+		F32: 1.5, F64: -2.5e-3, B: true, B1: true,
+	}
+	if got != want {
+		t.Errorf("Unmarshal() got %+v, want %+v", got, want)
+	}
+}
+
+func TestUnmarshalString_Errors(t *testing.T) {
+	tests := []struct {
+		input   string
+		target  interface{}
+		want    string
+		inRange bool
+	}{
+		{"12a", new(int), `xml: cannot parse "12a" as int`, true},
+		{"128", new(int8), `xml: cannot parse "128" as int8: value out of range`, false},
+		{"-1", new(uint), `xml: cannot parse "-1" as uint`, true},
+		{"70000", new(uint16), `xml: cannot parse "70000" as uint16: value out of range`, false},
+		{"1e39", new(float32), `xml: cannot parse "1e39" as float32: value out of range`, false},
+		{"1,5", new(float64), `xml: cannot parse "1,5" as float64`, true},
+		{"yes", new(bool), `xml: cannot parse "yes" as bool`, true},
+	}
+	for _, tt := range tests {
+		err := unmarshalString(tt.input, reflect.ValueOf(tt.target).Elem())
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("unmarshalString(%q) error = %v, want %s", tt.input, err, tt.want)
+			continue
+		}
+		if errors.Is(err, strconv.ErrRange) == tt.inRange {
+			t.Errorf("unmarshalString(%q): errors.Is(err, strconv.ErrRange) = %v", tt.input, !tt.inRange)
+		}
+	}
+}
+
 func TestExtractTextContent(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -390,6 +554,16 @@ func TestExtractTextContent(t *testing.T) {
 			input: map[string]interface{}{"other": "value"},
 			want:  "",
 		},
+		{
+			name:  "cdata",
+			input: map[string]interface{}{"#cdata": "a <b>"},
+			want:  "a <b>",
+		},
+		{
+			name:  "text and cdata",
+			input: map[string]interface{}{"#text": "a", "#cdata": "b"},
+			want:  "ab",
+		},
 		{
 			name:  "empty map",
 			input: map[string]interface{}{},
@@ -408,7 +582,155 @@ func TestExtractTextContent(t *testing.T) {
 }
 
 // Helper functions
+func intPtr(i int) *int {
+	return &i
+}
+
 func stringPtr(s string) *string {
 	return &s
 }
 
+
+func TestUnmarshalDuration(t *testing.T) {
+	type Job struct {
+		Timeout time.Duration `xml:"timeout,attr"`
+		Retry   time.Duration `xml:"retry"`
+	}
+
+	tests := []struct {
+		input string
+		want  Job
+	}{
+		{`<job timeout="30s"><retry>1m30s</retry></job>`, Job{30 * time.Second, 90 * time.Second}},
+		{`<job timeout="PT30S"><retry>PT1M30S</retry></job>`, Job{30 * time.Second, 90 * time.Second}},
+		{`<job timeout="P1D"><retry> PT1H </retry></job>`, Job{24 * time.Hour, time.Hour}},
+	}
+	for _, tt := range tests {
+		var got Job
+		if err := Unmarshal([]byte(tt.input), &got); err != nil {
+			t.Errorf("Unmarshal(%s) error = %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Unmarshal(%s) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+
+	var job Job
+	if err := Unmarshal([]byte(`<job timeout="P1M"/>`), &job); err == nil {
+		t.Error("expected error for calendar month duration")
+	}
+}
+
+func TestUnmarshalValue_NamedString(t *testing.T) {
+	type literal string
+	var s struct {
+		A string      `xml:"a,attr"`
+		B interface{} `xml:"b"`
+	}
+	m := map[string]interface{}{"@a": literal("1.50"), "b": literal("2")}
+	if err := UnmarshalValue(m, reflect.ValueOf(&s).Elem()); err != nil {
+		t.Fatalf("UnmarshalValue failed: %v", err)
+	}
+	if s.A != "1.50" || s.B != literal("2") {
+		t.Errorf("got %+v", s)
+	}
+	if got := extractTextContent(map[string]interface{}{"#text": literal("x")}); got != "x" {
+		t.Errorf("extractTextContent = %q", got)
+	}
+}
+
+func TestUnmarshal_RepeatedElements(t *testing.T) {
+	type Role struct {
+		Name  string `xml:"name,attr"`
+		Title string `xml:",chardata"`
+	}
+	type User struct {
+		Names  []string   `xml:"roles>role"`
+		Roles  []Role     `xml:"role"`
+		Ptrs   []*Role    `xml:"ptr"`
+		Scores [3]int     `xml:"score"`
+		IDs    *[]int     `xml:"id"`
+		Nested [][]string `xml:"tag"`
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  User
+	}{
+		{"several",
+			`<u><roles><role>a</role><role>b</role></roles><role name="x">X</role><role name="y"/><ptr name="x">X</ptr><ptr name="y"/><score>1</score><score>2</score><id>5</id><id>6</id><tag>t</tag><tag>u</tag></u>`,
+			User{
+				Names:  []string{"a", "b"},
+				Roles:  []Role{{"x", "X"}, {"y", ""}},
+				Ptrs:   []*Role{{"x", "X"}, {"y", ""}},
+				Scores: [3]int{1, 2, 0},
+				IDs:    &[]int{5, 6},
+				Nested: [][]string{{"t"}, {"u"}},
+			}},
+		{"single",
+			`<u><roles><role>a</role></roles><role name="x">X</role><ptr name="x">X</ptr><score>1</score><id>5</id><tag>t</tag></u>`,
+			User{
+				Names:  []string{"a"},
+				Roles:  []Role{{"x", "X"}},
+				Ptrs:   []*Role{{"x", "X"}},
+				Scores: [3]int{1, 0, 0},
+				IDs:    &[]int{5},
+				Nested: [][]string{{"t"}},
+			}},
+		{"more than the array holds",
+			`<u><score>1</score><score>2</score><score>3</score><score>4</score></u>`,
+			User{Scores: [3]int{1, 2, 3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := User{Scores: [3]int{7, 8, 9}}
+			if err := Unmarshal([]byte(tt.input), &got); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_ListWrapper(t *testing.T) {
+	type Post struct {
+		Tags   []string `xml:"tags>tag"`
+		Author string   `xml:"meta>author"`
+		Year   int      `xml:"meta>year"`
+		Deep   []int    `xml:"a>b>c"`
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  Post
+	}{
+		{"several", `<post><tags><tag>a</tag><tag>b</tag></tags></post>`, Post{Tags: []string{"a", "b"}}},
+		{"single", `<post><tags><tag>a</tag></tags></post>`, Post{Tags: []string{"a"}}},
+		{"whitespace", "<post>\n  <tags>\n    <tag>a</tag>\n    <tag> b </tag>\n  </tags>\n</post>", Post{Tags: []string{"a", "b"}}},
+		{"empty wrapper", `<post><tags/></post>`, Post{}},
+		{"missing wrapper", `<post/>`, Post{}},
+		{"repeated wrappers", `<post><tags><tag>a</tag></tags><tags><tag>b</tag><tag>c</tag></tags></post>`, Post{Tags: []string{"a", "b", "c"}}},
+		{"shared wrapper", `<post><meta><year>2024</year><author>Ann</author></meta></post>`, Post{Author: "Ann", Year: 2024}},
+		{"deep", `<post><a><b><c>1</c></b><b><c>2</c><c>3</c></b></a></post>`, Post{Deep: []int{1, 2, 3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Post
+			if err := Unmarshal([]byte(tt.input), &got); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	var p Post
+	err := Unmarshal([]byte(`<post><meta><year>soon</year></meta></post>`), &p)
+	if err == nil || err.Error() != `xml: /post/meta/year (line 1, column 13): cannot parse "soon" as int` {
+		t.Errorf("got %v", err)
+	}
+}
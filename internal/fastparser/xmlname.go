@@ -0,0 +1,99 @@
+package fastparser
+
+import (
+	"reflect"
+	"strings"
+)
+
+// xmlNameField returns the index of the XMLName field of struct type t, a
+// field of that name of any type, which names the element rather than a
+// child. ok is false if t has none.
+func xmlNameField(t reflect.Type) (index int, ok bool) {
+	f, found := t.FieldByName("XMLName")
+	if !found || len(f.Index) != 1 {
+		return 0, false
+	}
+	return f.Index[0], true
+}
+
+// holdsName reports whether t is a struct with string fields Space and
+// Local, such as xml.Name, which an XMLName field of type t stores the
+// element name in.
+func holdsName(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	space, hasSpace := t.FieldByName("Space")
+	local, hasLocal := t.FieldByName("Local")
+	return hasSpace && hasLocal && space.Type.Kind() == reflect.String && local.Type.Kind() == reflect.String
+}
+
+// xmlNameTag reports whether the struct type t leads to, through pointers,
+// slices and arrays, has an XMLName field, and returns the element name in
+// its tag, "" if the tag gives none.
+func xmlNameTag(t reflect.Type) (name string, ok bool) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return "", false
+	}
+	i, ok := xmlNameField(t)
+	if !ok {
+		return "", false
+	}
+	name, _, _ = strings.Cut(t.Field(i).Tag.Get("xml"), ",")
+	if i := strings.LastIndexByte(name, ' '); i >= 0 {
+		name = name[i+1:]
+	}
+	return name, true
+}
+
+// setXMLName stores the name of the element value was decoded from in the
+// XMLName field of the struct rv holds, following pointers, and of each
+// item of a slice or array of such structs. Local is the name without its
+// prefix; Space is resolved from the namespace declarations on the element
+// itself.
+func setXMLName(value interface{}, rv reflect.Value, name string) {
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if !rv.IsNil() {
+			setXMLName(value, rv.Elem(), name)
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			items = []interface{}{value}
+		}
+		for i := 0; i < rv.Len() && i < len(items); i++ {
+			setXMLName(items[i], rv.Index(i), name)
+		}
+	case reflect.Struct:
+		i, ok := xmlNameField(rv.Type())
+		if !ok || !holdsName(rv.Type().Field(i).Type) {
+			return
+		}
+		f := rv.Field(i)
+		local := name
+		if _, l, found := strings.Cut(name, ":"); found {
+			local = l
+		}
+		f.FieldByName("Space").SetString(declaredNamespace(value, name))
+		f.FieldByName("Local").SetString(local)
+	}
+}
+
+// declaredNamespace returns the namespace URI the element's own attributes
+// declare for the prefix of name, or "" if they declare none.
+func declaredNamespace(value interface{}, name string) string {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	key := "@xmlns"
+	if prefix, _, found := strings.Cut(name, ":"); found {
+		key += ":" + prefix
+	}
+	uri, _ := m[key].(string)
+	return uri
+}
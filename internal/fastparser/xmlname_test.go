@@ -0,0 +1,76 @@
+package fastparser
+
+import "testing"
+
+// testName mirrors xml.Name, which this package cannot import.
+type testName struct {
+	Space string
+	Local string
+}
+
+type namedItem struct {
+	XMLName testName `xml:"urn:items item"`
+	ID      string   `xml:"id,attr"`
+}
+
+type namedList struct {
+	XMLName testName
+	Items   []namedItem
+	Wrapped []namedItem `xml:"wrap>entry"`
+}
+
+func TestUnmarshal_XMLName(t *testing.T) {
+	data := `<p:list xmlns:p="urn:list">` +
+		`<item id="1" xmlns="urn:items"/><item id="2"/>` +
+		`<wrap><entry id="3"/></wrap>` +
+		`<XMLName>ignored</XMLName></p:list>`
+	var v namedList
+	if err := Unmarshal([]byte(data), &v); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if want := (testName{Space: "urn:list", Local: "list"}); v.XMLName != want {
+		t.Errorf("root XMLName = %+v, want %+v", v.XMLName, want)
+	}
+	if len(v.Items) != 2 {
+		t.Fatalf("Items = %+v, want 2 items matched by the XMLName tag", v.Items)
+	}
+	if want := (testName{Space: "urn:items", Local: "item"}); v.Items[0].XMLName != want {
+		t.Errorf("Items[0].XMLName = %+v, want %+v", v.Items[0].XMLName, want)
+	}
+	if want := (testName{Local: "item"}); v.Items[1].XMLName != want {
+		t.Errorf("Items[1].XMLName = %+v, want %+v", v.Items[1].XMLName, want)
+	}
+	if len(v.Wrapped) != 1 || v.Wrapped[0].XMLName.Local != "entry" || v.Wrapped[0].ID != "3" {
+		t.Errorf("Wrapped = %+v", v.Wrapped)
+	}
+}
+
+func TestXMLNameField_WrongType(t *testing.T) {
+	type notName struct {
+		XMLName string
+	}
+	type tagged struct {
+		XMLName struct{} `xml:"entry"`
+		ID      string   `xml:"id,attr"`
+	}
+	type holder struct {
+		Entries []tagged
+	}
+	var v notName
+	if err := Unmarshal([]byte(`<r><XMLName>x</XMLName></r>`), &v); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	// A field that is not a name still names the element, as in
+	// encoding/xml, and is left unset rather than decoded as a child.
+	if v.XMLName != "" {
+		t.Errorf("XMLName = %q, want it unset", v.XMLName)
+	}
+
+	var h holder
+	if err := Unmarshal([]byte(`<h><entry id="1"/><entry id="2"/></h>`), &h); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(h.Entries) != 2 || h.Entries[1].ID != "2" {
+		t.Errorf("Entries = %+v, want 2 items matched by the XMLName tag", h.Entries)
+	}
+}
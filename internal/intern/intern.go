@@ -0,0 +1,53 @@
+// Package intern deduplicates the element and attribute names produced by
+// parsing. It is shared by the fast-path parser and the streaming Decoder.
+//
+// A Pool holds at most a fixed number of names, so documents with unbounded
+// or hostile vocabularies cannot grow it without limit. Once it is full,
+// names it does not hold are returned as fresh strings.
+package intern
+
+import "sync"
+
+// Pool is a bounded set of strings. It is safe for concurrent use.
+type Pool struct {
+	mu    sync.RWMutex
+	names map[string]string
+	max   int
+}
+
+// New returns a Pool holding at most max names. A max of zero or less
+// gives a pool that never stores a name.
+func New(max int) *Pool {
+	if max < 0 {
+		max = 0
+	}
+	return &Pool{names: make(map[string]string), max: max}
+}
+
+// Intern returns a string equal to b, the one already in the pool if there
+// is one. It allocates only for names not yet in the pool.
+func (p *Pool) Intern(b []byte) string {
+	p.mu.RLock()
+	s, ok := p.names[string(b)] // no allocation for the lookup
+	p.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	s = string(b)
+	p.mu.Lock()
+	if existing, ok := p.names[s]; ok {
+		s = existing
+	} else if len(p.names) < p.max {
+		p.names[s] = s
+	}
+	p.mu.Unlock()
+	return s
+}
+
+// Len returns the number of names in the pool.
+func (p *Pool) Len() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.names)
+}
@@ -0,0 +1,59 @@
+package intern
+
+import (
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func TestPool_Intern(t *testing.T) {
+	p := New(2)
+	a := p.Intern([]byte("item"))
+	b := p.Intern([]byte("item"))
+	if a != "item" || unsafe.StringData(a) != unsafe.StringData(b) {
+		t.Errorf("repeated name not shared: %q %q", a, b)
+	}
+	p.Intern([]byte("id"))
+	c := p.Intern([]byte("extra"))
+	if c != "extra" || p.Len() != 2 {
+		t.Errorf("full pool: got %q, Len %d", c, p.Len())
+	}
+	if d := p.Intern([]byte("extra")); unsafe.StringData(c) == unsafe.StringData(d) {
+		t.Error("full pool stored a new name")
+	}
+}
+
+func TestPool_NoAllocOnHit(t *testing.T) {
+	p := New(10)
+	name := []byte("element")
+	p.Intern(name)
+	if n := testing.AllocsPerRun(100, func() { p.Intern(name) }); n != 0 {
+		t.Errorf("Intern of a held name allocates %v times", n)
+	}
+}
+
+func TestPool_ZeroMax(t *testing.T) {
+	p := New(-1)
+	if s := p.Intern([]byte("a")); s != "a" || p.Len() != 0 {
+		t.Errorf("got %q, Len %d", s, p.Len())
+	}
+}
+
+func TestPool_Concurrent(t *testing.T) {
+	p := New(100)
+	names := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				p.Intern(names[j%len(names)])
+			}
+		}()
+	}
+	wg.Wait()
+	if p.Len() != 3 {
+		t.Errorf("Len = %d, want 3", p.Len())
+	}
+}
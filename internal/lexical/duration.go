@@ -0,0 +1,154 @@
+// Package lexical implements the XML Schema lexical forms of values that have
+// no direct equivalent in Go's standard formatting, shared by the encoder and
+// the fast-path decoder.
+package lexical
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatDuration formats d as an ISO 8601 / xsd:duration value, e.g. "PT1M30S".
+// Only day-time components are produced since time.Duration has no notion of
+// calendar months or years. Fractional seconds keep nanosecond precision.
+func FormatDuration(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+
+	var b strings.Builder
+	u := uint64(d)
+	if d < 0 {
+		b.WriteByte('-')
+		u = -u
+	}
+	b.WriteString("PT")
+
+	hours := u / uint64(time.Hour)
+	u -= hours * uint64(time.Hour)
+	minutes := u / uint64(time.Minute)
+	u -= minutes * uint64(time.Minute)
+	seconds := u / uint64(time.Second)
+	nanos := u - seconds*uint64(time.Second)
+
+	if hours > 0 {
+		b.WriteString(strconv.FormatUint(hours, 10))
+		b.WriteByte('H')
+	}
+	if minutes > 0 {
+		b.WriteString(strconv.FormatUint(minutes, 10))
+		b.WriteByte('M')
+	}
+	if seconds > 0 || nanos > 0 {
+		b.WriteString(strconv.FormatUint(seconds, 10))
+		if nanos > 0 {
+			frac := fmt.Sprintf("%09d", nanos)
+			b.WriteByte('.')
+			b.WriteString(strings.TrimRight(frac, "0"))
+		}
+		b.WriteByte('S')
+	}
+	return b.String()
+}
+
+// ParseDuration parses either a Go duration string ("1m30s") or an ISO 8601
+// day-time duration ("PT1M30S", "P1DT2H"). Year and month components are
+// rejected because their length in time is not fixed.
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("empty duration")
+	}
+	iso := s
+	if iso[0] == '-' || iso[0] == '+' {
+		iso = iso[1:]
+	}
+	if !strings.HasPrefix(iso, "P") {
+		return time.ParseDuration(s)
+	}
+	return parseISODuration(s)
+}
+
+// parseISODuration parses an ISO 8601 duration with an optional sign.
+func parseISODuration(s string) (time.Duration, error) {
+	orig := s
+	neg := false
+	if s[0] == '-' || s[0] == '+' {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	s = s[1:] // 'P'
+	if s == "" {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+
+	var total time.Duration
+	inTime := false
+	seen := false
+	for s != "" {
+		if s[0] == 'T' {
+			if inTime || len(s) == 1 {
+				return 0, fmt.Errorf("invalid duration %q", orig)
+			}
+			inTime = true
+			s = s[1:]
+			continue
+		}
+
+		i := 0
+		for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+			i++
+		}
+		if i == 0 || i == len(s) {
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		num, unit := s[:i], s[i]
+		s = s[i+1:]
+
+		var scale time.Duration
+		switch {
+		case !inTime && unit == 'D':
+			scale = 24 * time.Hour
+		case !inTime && unit == 'W':
+			scale = 7 * 24 * time.Hour
+		case !inTime && (unit == 'Y' || unit == 'M'):
+			return 0, fmt.Errorf("duration %q has calendar component %c with no fixed length", orig, unit)
+		case inTime && unit == 'H':
+			scale = time.Hour
+		case inTime && unit == 'M':
+			scale = time.Minute
+		case inTime && unit == 'S':
+			scale = time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+
+		if strings.Contains(num, ".") {
+			if unit != 'S' {
+				return 0, fmt.Errorf("invalid duration %q: only seconds may be fractional", orig)
+			}
+			f, err := strconv.ParseFloat(num, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", orig)
+			}
+			total += time.Duration(f * float64(time.Second))
+		} else {
+			n, err := strconv.ParseInt(num, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", orig)
+			}
+			total += time.Duration(n) * scale
+		}
+		seen = true
+	}
+	if !seen {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+	if neg {
+		total = -total
+	}
+	return total, nil
+}
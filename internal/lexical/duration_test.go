@@ -0,0 +1,73 @@
+package lexical
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "PT0S"},
+		{90 * time.Second, "PT1M30S"},
+		{2 * time.Hour, "PT2H"},
+		{26*time.Hour + 5*time.Second, "PT26H5S"},
+		{1500 * time.Millisecond, "PT1.5S"},
+		{time.Nanosecond, "PT0.000000001S"},
+		{-90 * time.Second, "-PT1M30S"},
+	}
+	for _, tt := range tests {
+		if got := FormatDuration(tt.d); got != tt.want {
+			t.Errorf("FormatDuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+	}{
+		{"1m30s", 90 * time.Second},
+		{"-2h", -2 * time.Hour},
+		{"PT1M30S", 90 * time.Second},
+		{"PT0S", 0},
+		{"P1DT2H", 26 * time.Hour},
+		{"P1W", 7 * 24 * time.Hour},
+		{"PT1.5S", 1500 * time.Millisecond},
+		{"-PT1M", -time.Minute},
+		{"  PT5S ", 5 * time.Second},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.input)
+		if err != nil {
+			t.Errorf("ParseDuration(%q) error = %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseDuration_Invalid(t *testing.T) {
+	for _, input := range []string{"", "P", "PT", "P1Y", "P2M", "PT1.5M", "P1H", "PTS", "abc", "P1DT"} {
+		if _, err := ParseDuration(input); err == nil {
+			t.Errorf("ParseDuration(%q) expected error", input)
+		}
+	}
+}
+
+func TestDurationRoundTrip(t *testing.T) {
+	for _, d := range []time.Duration{0, time.Nanosecond, 1234567891011, -time.Hour - time.Millisecond} {
+		got, err := ParseDuration(FormatDuration(d))
+		if err != nil {
+			t.Fatalf("round trip of %v: %v", d, err)
+		}
+		if got != d {
+			t.Errorf("round trip of %v gave %v", d, got)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Package limits bounds the resources the parsers spend on a document, so
+// that hostile input is rejected with an error instead of exhausting the
+// stack or memory. It is shared by the AST parser and the fast parser.
+package limits
+
+import "fmt"
+
+// DefaultMaxDepth is the element depth allowed when Limits.MaxDepth is
+// zero. It matches the limit of encoding/xml.
+const DefaultMaxDepth = 10000
+
+// Names of the limits, as reported in Error.Limit.
+const (
+	Depth        = "depth"
+	Attributes   = "attribute count"
+	TokenLength  = "token length"
+	DocumentSize = "document size"
+)
+
+// Limits bounds a parse. A zero field leaves its resource unbounded,
+// except MaxDepth, which then is DefaultMaxDepth; a negative MaxDepth
+// allows any depth.
+type Limits struct {
+	MaxDepth        int
+	MaxAttributes   int
+	MaxTokenLength  int
+	MaxDocumentSize int64
+}
+
+// Depth returns the maximum element depth, or 0 if there is none.
+func (l Limits) Depth() int {
+	switch {
+	case l.MaxDepth == 0:
+		return DefaultMaxDepth
+	case l.MaxDepth < 0:
+		return 0
+	}
+	return l.MaxDepth
+}
+
+// CheckSize returns an *Error if a document of size bytes is too large.
+func (l Limits) CheckSize(size int64) error {
+	if l.MaxDocumentSize > 0 && size > l.MaxDocumentSize {
+		return &Error{Limit: DocumentSize, Max: l.MaxDocumentSize}
+	}
+	return nil
+}
+
+// Error reports a document that exceeds one of its Limits.
+type Error struct {
+	// Limit names the limit, one of the constants of this package.
+	Limit string
+	// Max is the value of the limit.
+	Max int64
+	// Line and Column locate the construct exceeding the limit, both
+	// starting at 1. They are 0 for DocumentSize.
+	Line, Column int
+}
+
+func (e *Error) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("xml: %s limit of %d exceeded", e.Limit, e.Max)
+	}
+	return fmt.Sprintf("xml: %s limit of %d exceeded at line %d, column %d", e.Limit, e.Max, e.Line, e.Column)
+}
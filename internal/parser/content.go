@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+// contentOrder collects the items of an element's content in document
+// order for SetMixedContent.
+type contentOrder struct {
+	items []ast.SchemaNode
+	// run holds the raw text since the last item, which starts at runPos.
+	run    []string
+	runPos ast.Position
+	// text is set once a run holds more than whitespace.
+	text bool
+	// comment is set once a comment was added.
+	comment bool
+	// rawNewlines keeps line ends as written, see SetRawNewlines.
+	rawNewlines bool
+	// space keeps whitespace runs, see SetPreserveSpace.
+	space bool
+}
+
+// addText appends raw text, which may hold entity references, to the
+// current run.
+func (c *contentOrder) addText(raw string, pos ast.Position) {
+	if len(c.run) == 0 {
+		c.runPos = pos
+	}
+	c.run = append(c.run, raw)
+}
+
+// add ends the current run and appends an item holding key.
+func (c *contentOrder) add(key, value string, pos ast.Position) {
+	c.flush()
+	if key == "#comment" {
+		c.comment = true
+	}
+	c.push(key, value, pos)
+}
+
+// push appends an item holding key.
+func (c *contentOrder) push(key, value string, pos ast.Position) {
+	item := map[string]ast.SchemaNode{key: ast.NewLiteralNode(value, pos)}
+	c.items = append(c.items, ast.NewObjectNode(item, pos))
+}
+
+// flush appends the current run as a text item.
+func (c *contentOrder) flush() {
+	if len(c.run) == 0 {
+		return
+	}
+	text := strings.Join(c.run, "")
+	if !c.rawNewlines {
+		text = normalizeNewlines(text)
+	}
+	text = decodeEntities(text)
+	if strings.TrimSpace(text) != "" {
+		c.text = true
+	}
+	c.push("#text", text, c.runPos)
+	c.run = nil
+}
+
+// finish returns the "#content" node, or nil if the content has at most
+// one item and no comment. Whitespace runs are dropped unless the content
+// holds text or keeps its whitespace.
+func (c *contentOrder) finish() ast.SchemaNode {
+	c.flush()
+	items := c.items
+	if !c.text && !c.space {
+		items = items[:0]
+		for _, item := range c.items {
+			if _, ok := item.(*ast.ObjectNode).Properties()["#text"]; !ok {
+				items = append(items, item)
+			}
+		}
+	}
+	if len(items) == 0 || (len(items) < 2 && !c.comment) {
+		return nil
+	}
+	return ast.NewArrayDataNode(items, items[0].Position())
+}
@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+// contentItems returns the "#content" items of node as key=value strings.
+func contentItems(t *testing.T, node ast.SchemaNode) []string {
+	t.Helper()
+	content, ok := node.(*ast.ObjectNode).Properties()["#content"].(*ast.ArrayDataNode)
+	if !ok {
+		return nil
+	}
+	var items []string
+	for _, item := range content.Elements() {
+		for k, v := range item.(*ast.ObjectNode).Properties() {
+			items = append(items, k+"="+v.(*ast.LiteralNode).Value().(string))
+		}
+	}
+	return items
+}
+
+func TestSetMixedContent(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+		text  string
+	}{
+		{`<p>Hello <b>world</b>!</p>`, []string{"#text=Hello ", "#element=b", "#text=!"}, "Hello !"},
+		{`<p>a &amp; <i>b</i> <i>c</i></p>`, []string{"#text=a & ", "#element=i", "#text= ", "#element=i"}, "a &"},
+		{`<p>x<![CDATA[y]]>z</p>`, []string{"#text=x", "#cdata=y", "#text=z"}, "xz"},
+		{"<r>\n  <a/>\n  <b/>\n  <a/>\n</r>", []string{"#element=a", "#element=b", "#element=a"}, ""},
+		{`<p>only text</p>`, nil, "only text"},
+		{`<r><a/></r>`, nil, ""},
+	}
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		p.SetMixedContent(true)
+		node, err := p.Parse()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.input, err)
+		}
+		got := contentItems(t, node)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: content = %q, want %q", tt.input, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: content = %q, want %q", tt.input, got, tt.want)
+				break
+			}
+		}
+		text := ""
+		if lit, ok := node.(*ast.ObjectNode).Properties()["#text"].(*ast.LiteralNode); ok {
+			text = lit.Value().(string)
+		}
+		if text != tt.text {
+			t.Errorf("%s: #text = %q, want %q", tt.input, text, tt.text)
+		}
+	}
+
+	// Off by default.
+	node, err := NewParser(`<p>Hello <b>world</b>!</p>`).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := contentItems(t, node); got != nil {
+		t.Errorf("content recorded by default: %q", got)
+	}
+}
+
+func TestSetComments(t *testing.T) {
+	input := "<!-- head --><!DOCTYPE r><!--a-->\n<r><!-- one --><a/><b>x<!--in b-->y</b><c/></r>\n<!-- tail -->"
+	p := NewParser(input)
+	p.SetComments(true)
+	node, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := node.(*ast.ObjectNode).Properties()
+
+	want := []string{"#comment= one ", "#element=a", "#element=b", "#element=c"}
+	if got := contentItems(t, node); len(got) != len(want) || got[0] != want[0] || got[3] != want[3] {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+	b := props["b"]
+	if got := contentItems(t, b); len(got) != 3 || got[1] != "#comment=in b" {
+		t.Errorf("b content = %q", got)
+	}
+	if got := contentItems(t, props["c"]); got != nil {
+		t.Errorf("c content = %q, want none", got)
+	}
+	for key, want := range map[string][]string{
+		"#prolog": {"#comment= head ", "#comment=a"},
+		"#epilog": {"#comment= tail "},
+	} {
+		got := contentItems(t, ast.NewObjectNode(map[string]ast.SchemaNode{"#content": props[key]}, ast.Position{}))
+		if len(got) != len(want) || got[0] != want[0] {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+
+	// Without mixed content, elements without comments keep no order.
+	p = NewParser(`<r><a/><b/><a/></r>`)
+	p.SetComments(true)
+	node, err = p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := contentItems(t, node); got != nil {
+		t.Errorf("content = %q, want none", got)
+	}
+
+	// Off by default, and comment text is never parsed as markup.
+	node, err = NewParser(`<!--<x>--><r><!-- <a> & --><a/></r>`).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	props = node.(*ast.ObjectNode).Properties()
+	if _, ok := props["#prolog"]; ok {
+		t.Error("#prolog recorded by default")
+	}
+	if got := contentItems(t, node); got != nil {
+		t.Errorf("content recorded by default: %q", got)
+	}
+}
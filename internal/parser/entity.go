@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+	"github.com/shapestone/shape-xml/internal/charset"
+)
+
+// decodeEntities returns s with the predefined entities (&lt; &gt; &amp;
+// &apos; &quot;) and character references (&#60; &#x3C;) replaced. Other
+// references, such as entities declared in a DOCTYPE, are kept verbatim.
+func decodeEntities(s string) string {
+	i := strings.IndexByte(s, '&')
+	if i < 0 {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i >= 0 {
+		b.WriteString(s[:i])
+		s = s[i:]
+		end := strings.IndexByte(s, ';')
+		if end < 0 {
+			break
+		}
+		if r, ok := entityRune(s[1:end]); ok {
+			b.WriteRune(r)
+			s = s[end+1:]
+		} else {
+			b.WriteByte('&')
+			s = s[1:]
+		}
+		i = strings.IndexByte(s, '&')
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// entityRune resolves the name of an entity or character reference to a
+// character XML allows.
+func entityRune(ref string) (rune, bool) {
+	switch ref {
+	case "lt":
+		return '<', true
+	case "gt":
+		return '>', true
+	case "amp":
+		return '&', true
+	case "apos":
+		return '\'', true
+	case "quot":
+		return '"', true
+	}
+	r, ok := charset.CharRef(ref)
+	if !ok || !charset.IsChar(r) {
+		return 0, false
+	}
+	return r, true
+}
+
+// SetInvalidChars selects what happens to character references, such as
+// &#1;, to characters XML does not allow. By default, for charset.Reject,
+// they are a syntax error, recorded in lenient mode; charset.Strip removes
+// them and charset.Replace decodes them as U+FFFD. Such characters written
+// literally are always an error: the caller cleans the document first.
+func (p *Parser) SetInvalidChars(mode charset.Mode) {
+	p.invalidChars = mode
+}
+
+// charRefs returns s, text or a quoted attribute value read at pos, with
+// the character references to characters XML does not allow handled as
+// SetInvalidChars says. In lenient mode they are recorded and replaced
+// by U+FFFD instead of being an error.
+func (p *Parser) charRefs(s string, pos ast.Position) (string, error) {
+	i := strings.Index(s, "&#")
+	if i < 0 {
+		return s, nil
+	}
+	var b strings.Builder
+	last := 0
+	for i >= 0 {
+		end := strings.IndexByte(s[i:], ';')
+		if end < 0 {
+			break
+		}
+		end += i
+		ref := s[i+1 : end]
+		if r, ok := charset.CharRef(ref); ok && !charset.IsChar(r) {
+			if p.invalidChars == charset.Reject {
+				refPos := p.advancePos(pos, s[:i])
+				if !p.lenient {
+					p.errPos = refPos
+					return "", charset.InvalidRefError(ref, r)
+				}
+				p.recordf(refPos, "%v", charset.InvalidRefError(ref, r))
+			}
+			b.WriteString(s[last:i])
+			if p.invalidChars != charset.Strip {
+				b.WriteRune(utf8.RuneError)
+			}
+			last = end + 1
+		}
+		next := strings.Index(s[end:], "&#")
+		if next < 0 {
+			break
+		}
+		i = end + next
+	}
+	if last == 0 {
+		return s, nil
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
@@ -317,14 +317,21 @@ func TestParserGrammarAlignment(t *testing.T) {
 			description: "Text with entity references",
 		},
 
-		// CDATA - Note: Currently has parsing issues with certain content
-		// {
-		// 	name:        "CDATA section",
-		// 	input:       `<code><![CDATA[<xml>raw</xml>]]></code>`,
-		// 	shouldParse: true,
-		// 	grammarRule: "CDATA",
-		// 	description: "CDATA = \"<![CDATA[\" CDATAContent \"]]>\"",
-		// },
+		// CDATA
+		{
+			name:        "CDATA section",
+			input:       `<code><![CDATA[<xml>raw</xml>]]></code>`,
+			shouldParse: true,
+			grammarRule: "CDATA",
+			description: "CDATA = \"<![CDATA[\" CDATAContent \"]]>\"",
+		},
+		{
+			name:        "unterminated CDATA section",
+			input:       `<code><![CDATA[raw</code>`,
+			shouldParse: false,
+			grammarRule: "CDATA",
+			description: "CDATA must end with ]]>",
+		},
 
 		// Comment - Note: Currently has parsing issues
 		// {
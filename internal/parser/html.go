@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+	"github.com/shapestone/shape-xml/internal/tokenizer"
+)
+
+// voidElements are the HTML elements that never have content or an end
+// tag.
+var voidElements = setOf("area", "base", "br", "col", "embed", "hr", "img", "input",
+	"link", "meta", "param", "source", "track", "wbr")
+
+// rawTextElements are the HTML elements whose content is text up to their
+// end tag, with no markup or references in it.
+var rawTextElements = setOf("script", "style")
+
+// closedBy maps the HTML elements whose end tag may be left out to the
+// start tags that end them when they are the innermost open element.
+var closedBy = map[string]map[string]bool{
+	"p": setOf("address", "article", "aside", "blockquote", "details", "div", "dl",
+		"fieldset", "figcaption", "figure", "footer", "form", "h1", "h2", "h3", "h4",
+		"h5", "h6", "header", "hr", "main", "nav", "ol", "p", "pre", "section", "table", "ul"),
+	"li":       setOf("li"),
+	"dt":       setOf("dt", "dd"),
+	"dd":       setOf("dt", "dd"),
+	"tr":       setOf("tr", "tbody", "tfoot"),
+	"td":       setOf("td", "th", "tr", "tbody", "tfoot"),
+	"th":       setOf("td", "th", "tr", "tbody", "tfoot"),
+	"thead":    setOf("tbody", "tfoot"),
+	"tbody":    setOf("tbody", "tfoot"),
+	"option":   setOf("option", "optgroup"),
+	"optgroup": setOf("optgroup"),
+	"rt":       setOf("rt", "rp"),
+	"rp":       setOf("rt", "rp"),
+}
+
+// optionalEnd reports whether the end tag of the HTML element name may be
+// left out, so that it closes silently at the end of its parent or of the
+// input.
+func optionalEnd(name string) bool {
+	switch name {
+	case "html", "head", "body", "colgroup", "caption", "tfoot":
+		return true
+	}
+	return closedBy[name] != nil
+}
+
+func setOf(names ...string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// SetHTML makes Parse read HTML as well as XML. It implies SetLenient, and
+// also:
+//   - element and attribute names are lower-cased, so tags match
+//     regardless of case
+//   - void elements such as <br> and <img> have no content and no end tag
+//   - the content of <script> and <style> is text up to their end tag
+//   - elements whose end tag HTML allows to leave out, such as <p>, <li>
+//     and <td>, are closed by the start tags that end them, by the end tag
+//     of an enclosing element and by the end of the input
+//
+// Leaving out those end tags, attribute values without quotes, attributes
+// without a value and a '&' that does not start a reference are not
+// recorded as errors, as HTML allows them.
+func (p *Parser) SetHTML(on bool) {
+	p.html = on
+	if on {
+		p.lenient = true
+	}
+}
+
+// htmlName returns name lower-cased in HTML mode.
+func (p *Parser) htmlName(name string) string {
+	if p.html {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// closedByStart reports whether the start tag beginning at the current
+// "<" token ends the innermost open element in HTML mode.
+func (p *Parser) closedByStart() bool {
+	ends := closedBy[p.open[len(p.open)-1].name]
+	if ends == nil {
+		return false
+	}
+	next, ok := p.tokenizer.PeekToken()
+	return ok && next.Kind() == tokenizer.TokenName && ends[strings.ToLower(next.ValueString())]
+}
+
+// parseRawText reads the content of the raw text element name, up to its
+// end tag, as its "#text". The current token is the ">" ending the start
+// tag.
+func (p *Parser) parseRawText(name string, properties map[string]ast.SchemaNode) error {
+	pos := ast.NewPosition(p.stream.GetOffset(), p.stream.GetRow(), p.stream.GetColumn())
+	end := "</" + name
+	var b strings.Builder
+	for {
+		r, ok := p.stream.PeekChar()
+		if !ok {
+			break
+		}
+		if r == '<' && p.atEndTag(end) {
+			break
+		}
+		p.stream.NextChar()
+		b.WriteRune(r)
+	}
+	if err := p.checkLength(b.Len(), pos); err != nil {
+		return err
+	}
+	if text := p.trimText(p.newlines(b.String())); text != "" {
+		properties["#text"] = ast.NewLiteralNode(text, pos)
+	}
+	p.advance()
+	return nil
+}
+
+// atEndTag reports whether the stream is at end, in any case, without
+// moving it.
+func (p *Parser) atEndTag(end string) bool {
+	loc := p.stream.GetLocation()
+	defer p.stream.SetLocation(loc)
+	for _, want := range end {
+		r, ok := p.stream.NextChar()
+		if !ok || unicode.ToLower(r) != want {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		errors []string
+	}{
+		{
+			"case-insensitive names", `<DIV Class="x"><Span>t</SPAN></div>`,
+			`{"@class": "x", "span": {"#text": "t"}}`,
+			nil,
+		},
+		{
+			"void elements", `<p>a<br>b<img src=a.png alt=""><input disabled></p>`,
+			`{"#text": "b", "br": {}, "img": {"@alt": "", "@src": "a.png"}, "input": {"@disabled": ""}}`,
+			nil,
+		},
+		{
+			"raw text", `<html><script>if (a < b && c) { s = "</div>" }</script><style>p { }</STYLE></html>`,
+			`{"script": {"#text": "if (a < b && c) { s = \"</div>\" }"}, "style": {"#text": "p { }"}}`,
+			nil,
+		},
+		{
+			"optional end tags", `<div><ul><li>a<li>b</ul><p>x<p>y<table><tr><td>1<td>2<tr><th>3</table></div>`,
+			`{"p": [{"#text": "x"}, {"#text": "y"}], "table": {"tr": [{"td": [{"#text": "1"}, {"#text": "2"}]}, {"th": {"#text": "3"}}]}, "ul": {"li": [{"#text": "a"}, {"#text": "b"}]}}`,
+			nil,
+		},
+		{
+			"optional end at end of input", `<body><p>text`,
+			`{"p": {"#text": "text"}}`,
+			nil,
+		},
+		{
+			"unterminated tags", `<a><b c`,
+			`{"b": {"@c": ""}}`,
+			[]string{`1:8 unexpected end of input in start tag of element "b"`, `1:8 element "a" not closed at end of input`},
+		},
+		{
+			"unterminated void element", `<br`,
+			`{}`,
+			[]string{`1:4 unexpected end of input in start tag of element "br"`},
+		},
+		{
+			"errors", `<div><b>x</i></div><span>`,
+			`{"b": {"#text": "x"}}`,
+			[]string{
+				`1:10 closing tag "i" does not match an open element`,
+				`1:14 element "b" not closed before closing tag "div"`,
+				`1:20 unexpected content after root element`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		p.SetHTML(true)
+		node, err := p.Parse()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := node.String(); got != tt.want {
+			t.Errorf("%s: got  %s\nwant %s", tt.name, got, tt.want)
+		}
+		var got []string
+		for _, e := range p.Errors() {
+			got = append(got, fmt.Sprintf("%d:%d %v", e.Line, e.Column, e.Err))
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.errors, "\n") {
+			t.Errorf("%s: errors\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.errors, "\n"))
+		}
+	}
+}
+
+func TestHTML_XMLUnchanged(t *testing.T) {
+	// Without SetHTML, <br> needs an end tag and names keep their case.
+	p := NewParser(`<P><br>x</P>`)
+	p.SetLenient(true)
+	node, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := node.String(), `{"br": {"#text": "x"}}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if len(p.Errors()) != 1 {
+		t.Errorf("errors: %v", p.Errors())
+	}
+}
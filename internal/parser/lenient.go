@@ -0,0 +1,245 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+	"github.com/shapestone/shape-xml/internal/charset"
+	"github.com/shapestone/shape-xml/internal/tokenizer"
+	"github.com/shapestone/shape-xml/internal/xmlerr"
+)
+
+// maxErrors is the number of errors recorded in lenient mode, after which
+// Parse still recovers but only records that there were too many.
+const maxErrors = 100
+
+// SetLenient makes Parse recover from common errors in hand-written
+// documents instead of failing, recording each one for Errors:
+//   - an end tag that does not match the innermost open element closes
+//     the open elements up to the one it names, or is skipped if it names
+//     none
+//   - elements still open at the end of the input are closed there
+//   - a '&' that does not start a reference is kept as text
+//   - an attribute value without quotes runs up to whitespace, ">" or
+//     "/>", and an attribute without a value is empty
+//   - content after the root element is ignored
+//
+// Other errors still fail Parse.
+func (p *Parser) SetLenient(on bool) {
+	p.lenient = on
+}
+
+// Errors returns the errors Parse recovered from in lenient mode, in
+// document order. Past maxErrors the last one reports that there were too
+// many.
+func (p *Parser) Errors() []*xmlerr.SyntaxError {
+	return p.errors
+}
+
+// recordf records an error recovered from at pos.
+func (p *Parser) recordf(pos ast.Position, format string, args ...interface{}) {
+	switch {
+	case len(p.errors) < maxErrors:
+		p.errors = append(p.errors, p.syntaxErrorAt(fmt.Errorf(format, args...), pos))
+	case len(p.errors) == maxErrors:
+		p.errors = append(p.errors, p.syntaxErrorAt(fmt.Errorf("too many errors"), pos))
+	}
+}
+
+// lenientEndTag reads the end tag starting at the current token. It
+// returns true if the tag closes an open element, setting p.closing, and
+// false if it closes none and was skipped.
+func (p *Parser) lenientEndTag() bool {
+	pos := p.position()
+	p.advance()
+	if p.peek() == nil || !p.hasToken || p.current.Kind() != tokenizer.TokenName {
+		p.recordf(pos, "expected element name in closing tag")
+		p.skipTagClose()
+		return false
+	}
+	name := ast.InternString(p.htmlName(p.current.ValueString()))
+	p.advance()
+	for i := len(p.open) - 1; i >= 0; i-- {
+		if p.open[i].name == name {
+			p.closing, p.closingPos = name, pos
+			return true
+		}
+	}
+	p.recordf(pos, "closing tag %q does not match an open element", name)
+	p.skipTagClose()
+	return false
+}
+
+// closeLenient ends the element name once its content has been read in
+// lenient mode. Unless the end tag read by lenientEndTag names it, the
+// element is recorded as unclosed and the end tag is left to an enclosing
+// element.
+func (p *Parser) closeLenient(name string) {
+	if p.implicitClose {
+		p.implicitClose = false
+		return
+	}
+	switch p.closing {
+	case "":
+		if !p.html || !optionalEnd(name) {
+			p.recordf(ast.ZeroPosition(), "element %q not closed at end of input", name)
+		}
+		return
+	case name:
+		p.closing = ""
+	default:
+		if !p.html || !optionalEnd(name) {
+			p.recordf(p.closingPos, "element %q not closed before closing tag %q", name, p.closing)
+		}
+		return
+	}
+	if p.peek() == nil || !p.hasToken || p.current.Kind() != tokenizer.TokenTagClose {
+		p.recordf(p.position(), "expected > in closing tag for element %q", name)
+		return
+	}
+	p.advance()
+}
+
+// skipTagClose skips the ">" of a tag, if it is there.
+func (p *Parser) skipTagClose() {
+	if p.peek() != nil && p.hasToken && p.current.Kind() == tokenizer.TokenTagClose {
+		p.advance()
+	}
+}
+
+// lenientAttribute parses the rest of the attribute name, at pos, in
+// lenient mode, once its name has been read.
+func (p *Parser) lenientAttribute(name string, pos ast.Position) (string, ast.SchemaNode, error) {
+	if p.peek() == nil || !p.hasToken || p.current.Kind() != tokenizer.TokenEquals {
+		if !p.html {
+			p.recordf(pos, "attribute %q has no value", name)
+		}
+		return name, ast.NewLiteralNode("", pos), nil
+	}
+	// The stream is just past "=": read the value from there unless it
+	// is quoted.
+	loc := p.stream.GetLocation()
+	p.advance()
+	if p.peek() != nil && p.hasToken && p.current.Kind() == tokenizer.TokenString {
+		quoted := p.current.ValueString()
+		if err := p.checkLength(len(quoted)-2, p.position()); err != nil {
+			return "", nil, err
+		}
+		p.checkReferences(quoted, p.position())
+		quoted, err := p.charRefs(quoted, p.position())
+		if err != nil {
+			return "", nil, err
+		}
+		value := p.unquoteString(quoted)
+		p.advance()
+		return name, ast.NewLiteralNode(value, pos), nil
+	}
+
+	p.stream.SetLocation(loc)
+	for r, ok := p.stream.PeekChar(); ok && unicode.IsSpace(r); r, ok = p.stream.PeekChar() {
+		p.stream.NextChar()
+	}
+	valuePos := ast.NewPosition(p.stream.GetOffset(), p.stream.GetRow(), p.stream.GetColumn())
+	raw := p.unquotedValue()
+	if err := p.checkLength(len(raw), valuePos); err != nil {
+		return "", nil, err
+	}
+	if !p.html {
+		p.recordf(valuePos, "value of attribute %q is not quoted", name)
+	}
+	p.checkReferences(raw, valuePos)
+	raw, err := p.charRefs(raw, valuePos)
+	if err != nil {
+		return "", nil, err
+	}
+	p.advance()
+	return name, ast.NewLiteralNode(decodeEntities(p.newlines(raw)), pos), nil
+}
+
+// unquotedValue reads an attribute value written without quotes, which
+// runs up to whitespace, "<", ">" or "/>".
+func (p *Parser) unquotedValue() string {
+	var b strings.Builder
+	for {
+		r, ok := p.stream.PeekChar()
+		if !ok || r == '<' || r == '>' || unicode.IsSpace(r) {
+			return b.String()
+		}
+		if r == '/' {
+			loc := p.stream.GetLocation()
+			p.stream.NextChar()
+			next, ok := p.stream.PeekChar()
+			p.stream.SetLocation(loc)
+			if ok && next == '>' {
+				return b.String()
+			}
+		}
+		p.stream.NextChar()
+		b.WriteRune(r)
+	}
+}
+
+// checkReferences records every '&' in s, found at pos, that does not
+// start an entity or character reference. HTML allows them.
+func (p *Parser) checkReferences(s string, pos ast.Position) {
+	if p.html {
+		return
+	}
+	for i := strings.IndexByte(s, '&'); i >= 0; {
+		if !isReference(s[i+1:]) {
+			p.recordf(p.advancePos(pos, s[:i]), "'&' does not start a reference")
+		}
+		next := strings.IndexByte(s[i+1:], '&')
+		if next < 0 {
+			return
+		}
+		i += next + 1
+	}
+}
+
+// isReference reports whether s starts with the name of an entity, or a
+// character reference, followed by ';'.
+func isReference(s string) bool {
+	end := strings.IndexByte(s, ';')
+	if end <= 0 {
+		return false
+	}
+	ref := s[:end]
+	if ref[0] == '#' {
+		// References to characters XML does not allow are recorded by
+		// charRefs.
+		_, ok := charset.CharRef(ref)
+		return ok
+	}
+	for i, r := range ref {
+		switch {
+		case unicode.IsLetter(r), r == '_', r == ':':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// advancePos returns pos moved over s, in the units of the input's
+// offsets.
+func (p *Parser) advancePos(pos ast.Position, s string) ast.Position {
+	for _, r := range s {
+		if p.data != nil {
+			pos.Offset += utf8.RuneLen(r)
+		} else {
+			pos.Offset++
+		}
+		if r == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+	return pos
+}
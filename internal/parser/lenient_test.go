@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLenient(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		errors []string
+	}{
+		{
+			"mismatched end tag", `<a><b>t</a>`,
+			`{"b": {"#text": "t"}}`,
+			[]string{`1:8 element "b" not closed before closing tag "a"`},
+		},
+		{
+			"stray end tag", `<a><b>t</c></b></a>`,
+			`{"b": {"#text": "t"}}`,
+			[]string{`1:8 closing tag "c" does not match an open element`},
+		},
+		{
+			"unclosed at end of input", "<a>\n<b>t",
+			`{"b": {"#text": "t"}}`,
+			[]string{`2:5 element "b" not closed at end of input`, `2:5 element "a" not closed at end of input`},
+		},
+		{
+			"stray ampersand", `<a x="Q&A">Q & A &amp; &#65; &ent; &#zz;</a>`,
+			`{"#text": "Q & A & A &ent; &#zz;", "@x": "Q&A"}`,
+			[]string{`1:8 '&' does not start a reference`, `1:14 '&' does not start a reference`, `1:36 '&' does not start a reference`},
+		},
+		{
+			"unquoted attributes", `<a x=1 y=/p/q/ z="2" on/>`,
+			`{"@on": "", "@x": "1", "@y": "/p/q/", "@z": "2"}`,
+			[]string{`1:6 value of attribute "x" is not quoted`, `1:10 value of attribute "y" is not quoted`, `1:22 attribute "on" has no value`},
+		},
+		{
+			"content after root", `<a/><b/>`,
+			`{}`,
+			[]string{`1:5 unexpected content after root element`},
+		},
+		{
+			"truncated start tag", `<a`,
+			`{}`,
+			[]string{`1:3 unexpected end of input in start tag of element "a"`},
+		},
+		{
+			"truncated attribute", `<a b`,
+			`{"@b": ""}`,
+			[]string{`1:4 attribute "b" has no value`, `1:5 unexpected end of input in start tag of element "a"`},
+		},
+		{
+			"truncated child", `<a><b`,
+			`{"b": {}}`,
+			[]string{`1:6 unexpected end of input in start tag of element "b"`, `1:6 element "a" not closed at end of input`},
+		},
+		{
+			"well-formed", `<a x="1"><b>t</b></a>`,
+			`{"@x": "1", "b": {"#text": "t"}}`,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		for _, bytes := range []bool{false, true} {
+			p := NewParser(tt.input)
+			if bytes {
+				p = NewParserFromBytes([]byte(tt.input))
+			}
+			p.SetLenient(true)
+			node, err := p.Parse()
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+				continue
+			}
+			if got := node.String(); got != tt.want {
+				t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+			}
+			var got []string
+			for _, e := range p.Errors() {
+				got = append(got, fmt.Sprintf("%d:%d %v", e.Line, e.Column, e.Err))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.errors, "\n") {
+				t.Errorf("%s: errors\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.errors, "\n"))
+			}
+		}
+	}
+}
+
+func TestLenient_StrictUnchanged(t *testing.T) {
+	for _, input := range []string{`<a><b>t</a>`, `<a><b>t`, `<a x=1/>`} {
+		if _, err := NewParser(input).Parse(); err == nil {
+			t.Errorf("%s: expected an error without SetLenient", input)
+		}
+	}
+}
+
+func TestLenient_TooManyErrors(t *testing.T) {
+	p := NewParser("<a>" + strings.Repeat("& ", 2*maxErrors) + "</a>")
+	p.SetLenient(true)
+	if _, err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	errs := p.Errors()
+	if len(errs) != maxErrors+1 || errs[maxErrors].Err.Error() != "too many errors" {
+		t.Errorf("got %d errors, last %v", len(errs), errs[len(errs)-1])
+	}
+}
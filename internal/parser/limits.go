@@ -0,0 +1,28 @@
+package parser
+
+import (
+	"github.com/shapestone/shape-core/pkg/ast"
+	"github.com/shapestone/shape-xml/internal/limits"
+)
+
+// SetLimits bounds the document Parse accepts. Without it the depth is
+// limited to limits.DefaultMaxDepth and nothing else is. The document size
+// is not checked for a parser reading a stream.
+func (p *Parser) SetLimits(l limits.Limits) {
+	p.limits = l
+}
+
+// limitError returns the error for limit, of value max, exceeded by the
+// construct at pos.
+func limitError(limit string, max int, pos ast.Position) error {
+	return &limits.Error{Limit: limit, Max: int64(max), Line: pos.Line, Column: pos.Column}
+}
+
+// checkLength returns an error if a token of n bytes at pos is longer than
+// allowed.
+func (p *Parser) checkLength(n int, pos ast.Position) error {
+	if max := p.limits.MaxTokenLength; max > 0 && n > max {
+		return limitError(limits.TokenLength, max, pos)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+// openTag is an element whose end tag has not been read yet.
+type openTag struct {
+	name string
+	pos  ast.Position
+}
+
+// closeOpen drops the open elements from index n on.
+func (p *Parser) closeOpen(n int) {
+	p.open = p.open[:n]
+}
+
+// mismatchedTags returns the error for an end tag named closing, at pos,
+// that does not match the innermost open element. The error is located at
+// the end tag; the message gives the position of the start tag and the open
+// elements as a path, so the unclosed element can be found in a large
+// document.
+func (p *Parser) mismatchedTags(closing string, pos ast.Position) error {
+	names := make([]string, len(p.open))
+	for i, tag := range p.open {
+		names[i] = tag.name
+	}
+	inner := p.open[len(p.open)-1]
+	p.errPos = pos
+	return fmt.Errorf("mismatched tags: opening %q at %s, closing %q; open elements: /%s",
+		inner.name, inner.pos, closing, strings.Join(names, "/"))
+}
@@ -0,0 +1,24 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMismatchedTags(t *testing.T) {
+	input := "<root>\n  <a>\n    <b>x</c>\n  </a>\n</root>"
+	_, err := NewParser(input).Parse()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	want := `xml: syntax error at line 3, column 9: mismatched tags: opening "b" at line 3, column 5, closing "c"; open elements: /root/a/b`
+	if err.Error() != want {
+		t.Errorf("got  %v\nwant %s", err, want)
+	}
+
+	// The stack only holds the elements still open.
+	_, err = NewParser("<root><done/><x><y></y></z></root>").Parse()
+	if err == nil || !strings.HasSuffix(err.Error(), "open elements: /root/x") {
+		t.Errorf("got %v", err)
+	}
+}
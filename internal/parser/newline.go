@@ -0,0 +1,24 @@
+package parser
+
+import "strings"
+
+// normalizeNewlines returns s with each "\r\n" and each "\r" not followed
+// by "\n" replaced by "\n", the end-of-line handling of XML 1.0 section
+// 2.11. It runs before entity references are decoded, so a &#13;
+// reference still yields "\r".
+func normalizeNewlines(s string) string {
+	if strings.IndexByte(s, '\r') < 0 {
+		return s
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\r", "\n")
+}
+
+// newlines returns s with its line ends normalized, unless SetRawNewlines
+// is on.
+func (p *Parser) newlines(s string) string {
+	if p.rawNewlines {
+		return s
+	}
+	return normalizeNewlines(s)
+}
@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+func TestNewlineNormalization(t *testing.T) {
+	input := "<r a=\"x\r\ny\">one\r\ntwo\rthree&#13;x<b><![CDATA[c\r\nd]]></b></r>"
+	for _, raw := range []bool{false, true} {
+		p := NewParser(input)
+		p.SetRawNewlines(raw)
+		node, err := p.Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		props := node.(*ast.ObjectNode).Properties()
+		want := map[string]string{"@a": "x\ny", "#text": "one\ntwo\nthree\rx"}
+		cdata := "c\nd"
+		if raw {
+			want = map[string]string{"@a": "x\r\ny", "#text": "one\r\ntwo\rthree\rx"}
+			cdata = "c\r\nd"
+		}
+		for key, want := range want {
+			if got := props[key].(*ast.LiteralNode).Value(); got != want {
+				t.Errorf("raw=%v: %s = %q, want %q", raw, key, got, want)
+			}
+		}
+		b := props["b"].(*ast.ObjectNode).Properties()
+		if got := b["#cdata"].(*ast.LiteralNode).Value(); got != cdata {
+			t.Errorf("raw=%v: #cdata = %q, want %q", raw, got, cdata)
+		}
+	}
+}
+
+func TestTextKeepsInnerWhitespace(t *testing.T) {
+	node, err := NewParser("<r>  hello   world\n</r>").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := node.(*ast.ObjectNode).Properties()["#text"].(*ast.LiteralNode).Value(); got != "hello   world" {
+		t.Errorf("#text = %q, want %q", got, "hello   world")
+	}
+}
@@ -2,26 +2,97 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/shapestone/shape-core/pkg/ast"
 	shapetokenizer "github.com/shapestone/shape-core/pkg/tokenizer"
+	"github.com/shapestone/shape-xml/internal/charset"
+	"github.com/shapestone/shape-xml/internal/dtd"
+	"github.com/shapestone/shape-xml/internal/limits"
 	"github.com/shapestone/shape-xml/internal/tokenizer"
+	"github.com/shapestone/shape-xml/internal/xmlerr"
 )
 
 // Parser implements LL(1) recursive descent parsing for XML.
 // It maintains a single token lookahead for predictive parsing.
 type Parser struct {
 	tokenizer *shapetokenizer.Tokenizer
+	stream    shapetokenizer.Stream
 	current   *shapetokenizer.Token
 	hasToken  bool
+	rootName  string
+	// mixed records the order of element content, see SetMixedContent.
+	mixed bool
+	// comments keeps comments, see SetComments.
+	comments bool
+	// rawNewlines keeps line ends as written, see SetRawNewlines.
+	rawNewlines bool
+	// open holds the elements being parsed, outermost first, for error
+	// messages.
+	open []openTag
+	// err is returned by Parse for input that cannot be tokenized.
+	err error
+	// limits bounds the document, see SetLimits.
+	limits limits.Limits
+	// size is the length of the input in bytes, or -1 for a stream.
+	size int64
+	// src or data holds the input, unless it is a stream, for syntax
+	// errors to quote.
+	src  string
+	data []byte
+	// errPos, if valid, is where the error being returned occurred, when
+	// that is not the current token.
+	errPos ast.Position
+	// lenient recovers from common errors, see SetLenient.
+	lenient bool
+	// errors holds the errors recovered from in lenient mode.
+	errors []*xmlerr.SyntaxError
+	// closing names the element closed by the end tag just read in
+	// lenient mode, at closingPos, until the element is found.
+	closing    string
+	closingPos ast.Position
+	// html reads HTML as well as XML, see SetHTML.
+	html bool
+	// implicitClose is set in HTML mode when a start tag has ended the
+	// innermost open element.
+	implicitClose bool
+	// preserveSpace keeps whitespace in text, see SetPreserveSpace, and
+	// space is whether the current element does, following xml:space.
+	preserveSpace bool
+	space         bool
+	// invalidChars handles references to characters XML does not allow,
+	// see SetInvalidChars.
+	invalidChars charset.Mode
 }
 
 // NewParser creates a new XML parser for the given input string.
 // For parsing from io.Reader, use NewParserFromStream instead.
 func NewParser(input string) *Parser {
-	return newParserWithStream(shapetokenizer.NewStream(input))
+	// XML documents are text; the tokenizer cannot step over bytes that
+	// are not UTF-8.
+	if i, r := charset.IndexInvalidString(input); i >= 0 {
+		return &Parser{err: xmlerr.New([]byte(input), i, "", charset.InvalidError(r))}
+	}
+	p := newParserWithStream(shapetokenizer.NewStream(input))
+	p.size = int64(len(input))
+	p.src = input
+	return p
+}
+
+// NewParserFromBytes creates a new XML parser reading input in place,
+// without the copies NewParser makes. The offsets of positions count bytes
+// rather than characters. input must not be modified until parsing is
+// done.
+func NewParserFromBytes(input []byte) *Parser {
+	if i, r := charset.IndexInvalid(input); i >= 0 {
+		return &Parser{err: xmlerr.New(input, i, "", charset.InvalidError(r))}
+	}
+	p := newParserWithStream(tokenizer.NewByteStream(input))
+	p.size = int64(len(input))
+	p.data = input
+	return p
 }
 
 // NewParserFromStream creates a new XML parser using a pre-configured stream.
@@ -32,10 +103,16 @@ func NewParserFromStream(stream shapetokenizer.Stream) *Parser {
 
 // newParserWithStream is the internal constructor that accepts a stream.
 func newParserWithStream(stream shapetokenizer.Stream) *Parser {
+	// A byte-order mark is not part of the document.
+	if r, ok := stream.PeekChar(); ok && r == '\uFEFF' {
+		stream.NextChar()
+	}
 	tok := tokenizer.NewTokenizerWithStream(stream)
 
 	p := &Parser{
 		tokenizer: &tok,
+		stream:    stream,
+		size:      -1,
 	}
 	p.advance() // Load first token
 	return p
@@ -45,11 +122,81 @@ func newParserWithStream(stream shapetokenizer.Stream) *Parser {
 //
 // Grammar:
 //
-//	Document = [ XMLDecl ] Element
+//	Document = [ XMLDecl ] [ Doctype ] Element
+//
+// A document type declaration is skipped; its internal subset is not
+// interpreted.
 //
 // Returns ast.SchemaNode - the root of the AST.
 // For XML data, this will be an ObjectNode representing the root element.
+//
+// A document that is not well-formed is reported as an
+// *xmlerr.SyntaxError, and one exceeding its limits as a *limits.Error.
 func (p *Parser) Parse() (ast.SchemaNode, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.size >= 0 {
+		if err := p.limits.CheckSize(p.size); err != nil {
+			return nil, err
+		}
+	}
+	node, err := p.parse()
+	if err != nil {
+		return nil, p.syntaxError(err)
+	}
+	return node, nil
+}
+
+// syntaxError returns err as an *xmlerr.SyntaxError at the token where
+// parsing stopped. Limit errors are returned as they are.
+func (p *Parser) syntaxError(err error) error {
+	if _, ok := err.(*limits.Error); ok {
+		return err
+	}
+	pos := p.position()
+	if p.errPos.IsValid() {
+		pos = p.errPos
+	}
+	return p.syntaxErrorAt(err, pos)
+}
+
+// syntaxErrorAt returns err as an *xmlerr.SyntaxError at pos, or at the
+// end of the input if pos is not valid.
+func (p *Parser) syntaxErrorAt(err error, pos ast.Position) *xmlerr.SyntaxError {
+	var element string
+	if n := len(p.open); n > 0 {
+		element = p.open[n-1].name
+	}
+	switch {
+	case p.data != nil:
+		if !pos.IsValid() {
+			pos.Offset = len(p.data)
+		}
+		return xmlerr.New(p.data, pos.Offset, element, err)
+	case p.size >= 0:
+		// The offsets of a string stream count characters.
+		offset := len(p.src)
+		if pos.IsValid() {
+			offset = byteOffset(p.src, pos.Offset)
+		}
+		return xmlerr.New([]byte(p.src), offset, element, err)
+	}
+	return &xmlerr.SyntaxError{Err: err, Offset: pos.Offset, Line: pos.Line, Column: pos.Column, Element: element}
+}
+
+// byteOffset returns the byte offset of the character at index n of s.
+func byteOffset(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(s)
+}
+
+func (p *Parser) parse() (ast.SchemaNode, error) {
 	// Skip XML declaration if present
 	if p.peek() != nil && p.peek().Kind() == tokenizer.TokenXMLDeclStart {
 		if err := p.skipXMLDeclaration(); err != nil {
@@ -58,26 +205,93 @@ func (p *Parser) Parse() (ast.SchemaNode, error) {
 	}
 
 	// Skip any comments before root element
-	p.skipComments()
+	prolog := p.skipComments(nil)
+
+	// Skip the document type declaration, which must parse as one
+	if p.peek() != nil && p.peek().Kind() == tokenizer.TokenDoctype {
+		decl := p.peek().ValueString()
+		if _, n, err := dtd.Parse([]byte(decl)); err != nil {
+			pos := p.advancePos(p.position(), decl[:n])
+			if !p.lenient {
+				p.errPos = pos
+				return nil, fmt.Errorf("in DOCTYPE: %w", err)
+			}
+			p.recordf(pos, "in DOCTYPE: %v", err)
+		}
+		p.advance()
+		prolog = p.skipComments(prolog)
+	}
 
 	// Parse root element
-	node, err := p.parseElement()
+	node, _, err := p.parseElement()
 	if err != nil {
 		return nil, err
 	}
 
 	// Skip trailing comments and whitespace
-	p.skipCommentsAndWhitespace()
+	epilog := p.skipComments(nil)
+	if obj, ok := node.(*ast.ObjectNode); ok {
+		if len(prolog) > 0 {
+			obj.Properties()["#prolog"] = ast.NewArrayDataNode(prolog, prolog[0].Position())
+		}
+		if len(epilog) > 0 {
+			obj.Properties()["#epilog"] = ast.NewArrayDataNode(epilog, epilog[0].Position())
+		}
+	}
 
 	// After parsing the root element, we should be at EOF
 	token := p.peek()
 	if token != nil && p.hasToken && token.Kind() != tokenizer.TokenEOF {
-		return nil, fmt.Errorf("unexpected content after root element at %s", p.positionStr())
+		if p.lenient {
+			p.recordf(p.position(), "unexpected content after root element")
+			return node, nil
+		}
+		return nil, errors.New("unexpected content after root element")
 	}
 
 	return node, nil
 }
 
+// SetMixedContent makes Parse record, for every element with more than
+// one item of content, the order of its text, CDATA sections and child
+// elements under the "#content" property, an *ast.ArrayDataNode of
+// *ast.ObjectNode items:
+//   - {"#text": run}: a run of text, untrimmed and with entities decoded
+//   - {"#cdata": content}: a CDATA section
+//   - {"#element": name}: the next occurrence of the child element name
+//
+// Runs of whitespace are only recorded in elements that also hold other
+// text. The "#text" property of such elements holds all of their text,
+// rather than only the last run.
+func (p *Parser) SetMixedContent(on bool) {
+	p.mixed = on
+}
+
+// SetRawNewlines makes Parse keep "\r\n" and "\r" line ends in text,
+// CDATA sections, comments and attribute values as written. By default
+// they become "\n", as the XML specification requires.
+func (p *Parser) SetRawNewlines(on bool) {
+	p.rawNewlines = on
+}
+
+// SetComments makes Parse keep comments instead of skipping them.
+// Comments inside an element are recorded in its "#content" order, as
+// {"#comment": text} items, which SetMixedContent describes; an element
+// holding a comment always has a "#content" property, and without
+// SetMixedContent only such elements have one. Comments before and
+// after the root element are recorded as lists of such items under the
+// root's "#prolog" and "#epilog" properties. The text is kept as written,
+// without the "<!--" and "-->" delimiters.
+func (p *Parser) SetComments(on bool) {
+	p.comments = on
+}
+
+// RootName returns the name of the document's root element once Parse has
+// read its start tag.
+func (p *Parser) RootName() string {
+	return p.rootName
+}
+
 // parseElement parses an XML element.
 //
 // Grammar:
@@ -87,34 +301,52 @@ func (p *Parser) Parse() (ast.SchemaNode, error) {
 //	StartTag = "<" Name { Attribute } ">"
 //	EndTag = "</" Name ">"
 //
-// Returns *ast.ObjectNode with properties:
+// Returns the element name and an *ast.ObjectNode with properties:
 //   - "@attribute": attribute values (prefixed with @)
-//   - "childElement": child element nodes
+//   - "childElement": child element nodes, keyed by element name
 //   - "#text": text content
 //   - "#cdata": CDATA content
-func (p *Parser) parseElement() (ast.SchemaNode, error) {
+func (p *Parser) parseElement() (_ ast.SchemaNode, _ string, err error) {
 	startPos := p.position()
 
 	// "<"
 	if err := p.expect(tokenizer.TokenTagOpen); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	// Element name
 	if p.peek().Kind() != tokenizer.TokenName {
-		return nil, fmt.Errorf("expected element name at %s, got %s",
-			p.positionStr(), p.peek().Kind())
+		return nil, "", fmt.Errorf("expected element name, got %s", p.peek().Kind())
 	}
 	// Intern element name to reduce allocations for repeated tags
-	elementName := ast.InternString(p.current.ValueString())
+	elementName := ast.InternString(p.htmlName(p.current.ValueString()))
+	if err := p.checkLength(len(elementName), p.position()); err != nil {
+		return nil, "", err
+	}
 	p.advance()
+	if p.rootName == "" {
+		p.rootName = elementName
+	}
+	p.open = append(p.open, openTag{elementName, startPos})
+	// On error the element stays open, for syntaxError to name it.
+	defer func(n int) {
+		if err == nil {
+			p.closeOpen(n)
+		}
+	}(len(p.open) - 1)
+	if max := p.limits.Depth(); max > 0 && len(p.open) > max {
+		return nil, "", limitError(limits.Depth, max, startPos)
+	}
 
 	// Parse attributes - pre-size map for typical element (most have <8 properties)
 	properties := make(map[string]ast.SchemaNode, 8)
-	for p.peek() != nil && p.peek().Kind() == tokenizer.TokenName {
+	for p.peek() != nil && p.hasToken && p.current.Kind() == tokenizer.TokenName {
+		if max := p.limits.MaxAttributes; max > 0 && len(properties) >= max {
+			return nil, "", limitError(limits.Attributes, max, p.position())
+		}
 		attrName, attrValue, err := p.parseAttribute()
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		// Prefix attribute names with @
 		properties["@"+attrName] = attrValue
@@ -122,33 +354,55 @@ func (p *Parser) parseElement() (ast.SchemaNode, error) {
 
 	// Check for self-closing or regular closing
 	token := p.peek()
-	if token == nil {
-		return nil, fmt.Errorf("unexpected end of input in element %q", elementName)
+	if token == nil || !p.hasToken {
+		if p.lenient {
+			p.recordf(p.position(), "unexpected end of input in start tag of element %q", elementName)
+			return ast.NewObjectNode(properties, startPos), elementName, nil
+		}
+		return nil, "", fmt.Errorf("unexpected end of input in element %q", elementName)
 	}
 
 	if token.Kind() == tokenizer.TokenTagSelfClose {
 		// Self-closing element: />
 		p.advance()
-		return ast.NewObjectNode(properties, startPos), nil
+		return ast.NewObjectNode(properties, startPos), elementName, nil
 	}
 
 	// Regular closing: >
-	if err := p.expect(tokenizer.TokenTagClose); err != nil {
-		return nil, err
+	switch {
+	case p.html && token.Kind() == tokenizer.TokenTagClose && voidElements[elementName]:
+		p.advance()
+		return ast.NewObjectNode(properties, startPos), elementName, nil
+	case p.html && token.Kind() == tokenizer.TokenTagClose && rawTextElements[elementName]:
+		if err := p.parseRawText(elementName, properties); err != nil {
+			return nil, "", err
+		}
+	default:
+		if err := p.expect(tokenizer.TokenTagClose); err != nil {
+			return nil, "", err
+		}
 	}
 
 	// Parse content (text, CDATA, child elements)
-	if err := p.parseContent(properties); err != nil {
-		return nil, fmt.Errorf("in element %q: %w", elementName, err)
+	space := p.enterSpace(properties)
+	err = p.parseContent(properties)
+	p.space = space
+	if err != nil {
+		return nil, "", err
+	}
+	if p.lenient {
+		p.closeLenient(elementName)
+		return ast.NewObjectNode(properties, startPos), elementName, nil
 	}
 
 	// End tag: </name>
+	endPos := p.position()
 	if err := p.expect(tokenizer.TokenEndTagOpen); err != nil {
-		return nil, fmt.Errorf("expected closing tag for element %q: %w", elementName, err)
+		return nil, "", fmt.Errorf("expected closing tag for element %q: %w", elementName, err)
 	}
 
 	if p.peek().Kind() != tokenizer.TokenName {
-		return nil, fmt.Errorf("expected element name in closing tag at %s", p.positionStr())
+		return nil, "", errors.New("expected element name in closing tag")
 	}
 
 	// Intern closing name for comparison (same string instance if matching)
@@ -156,15 +410,14 @@ func (p *Parser) parseElement() (ast.SchemaNode, error) {
 	p.advance()
 
 	if closingName != elementName {
-		return nil, fmt.Errorf("mismatched tags: opening %q, closing %q at %s",
-			elementName, closingName, p.positionStr())
+		return nil, "", p.mismatchedTags(closingName, endPos)
 	}
 
 	if err := p.expect(tokenizer.TokenTagClose); err != nil {
-		return nil, fmt.Errorf("expected > in closing tag for element %q: %w", elementName, err)
+		return nil, "", fmt.Errorf("expected > in closing tag for element %q: %w", elementName, err)
 	}
 
-	return ast.NewObjectNode(properties, startPos), nil
+	return ast.NewObjectNode(properties, startPos), elementName, nil
 }
 
 // parseAttribute parses an XML attribute.
@@ -177,14 +430,21 @@ func (p *Parser) parseElement() (ast.SchemaNode, error) {
 func (p *Parser) parseAttribute() (string, ast.SchemaNode, error) {
 	// Attribute name
 	if p.peek().Kind() != tokenizer.TokenName {
-		return "", nil, fmt.Errorf("expected attribute name at %s", p.positionStr())
+		return "", nil, errors.New("expected attribute name")
 	}
 
 	// Intern attribute name to reduce allocations for common attributes
-	attrName := ast.InternString(p.current.ValueString())
+	attrName := ast.InternString(p.htmlName(p.current.ValueString()))
 	pos := p.position()
+	if err := p.checkLength(len(attrName), pos); err != nil {
+		return "", nil, err
+	}
 	p.advance()
 
+	if p.lenient {
+		return p.lenientAttribute(attrName, pos)
+	}
+
 	// "="
 	if err := p.expect(tokenizer.TokenEquals); err != nil {
 		return "", nil, fmt.Errorf("expected = after attribute name %q: %w", attrName, err)
@@ -192,11 +452,18 @@ func (p *Parser) parseAttribute() (string, ast.SchemaNode, error) {
 
 	// String value
 	if p.peek().Kind() != tokenizer.TokenString {
-		return "", nil, fmt.Errorf("expected string value for attribute %q at %s",
-			attrName, p.positionStr())
+		return "", nil, fmt.Errorf("expected string value for attribute %q", attrName)
 	}
 
-	valueStr := p.unquoteString(p.current.ValueString())
+	quoted := p.current.ValueString()
+	if err := p.checkLength(len(quoted)-2, p.position()); err != nil {
+		return "", nil, err
+	}
+	quoted, err := p.charRefs(quoted, p.position())
+	if err != nil {
+		return "", nil, err
+	}
+	valueStr := p.unquoteString(quoted)
 	p.advance()
 
 	return attrName, ast.NewLiteralNode(valueStr, pos), nil
@@ -215,8 +482,58 @@ func (p *Parser) parseAttribute() (string, ast.SchemaNode, error) {
 func (p *Parser) parseContent(properties map[string]ast.SchemaNode) error {
 	var textParts []string
 	var cdataParts []string
+	var c *contentOrder
+	if p.mixed || p.comments {
+		c = &contentOrder{rawNewlines: p.rawNewlines, space: p.space}
+	}
+	// run is the length in bytes of the text read since the last markup,
+	// which started at runPos.
+	var run int
+	var runPos ast.Position
+	addRun := func(text string) error {
+		if run == 0 {
+			runPos = p.position()
+		}
+		run += len(text)
+		return p.checkLength(run, runPos)
+	}
+
+	// finish adds the accumulated content to properties.
+	finish := func() {
+		if c != nil && (p.mixed || c.comment) {
+			if content := c.finish(); content != nil {
+				properties["#content"] = content
+			}
+		}
+		// Add accumulated text/cdata if any
+		if len(textParts) > 0 {
+			combined := decodeEntities(p.newlines(strings.Join(textParts, "")))
+			if text := p.trimText(combined); text != "" {
+				properties["#text"] = ast.NewLiteralNode(text, p.position())
+			}
+		}
+		if len(cdataParts) > 0 {
+			properties["#cdata"] = ast.NewLiteralNode(strings.Join(cdataParts, ""), p.position())
+		}
+	}
 
 	for {
+		if p.hasToken && p.current != nil && p.current.Kind() == "Whitespace" {
+			// Whitespace between words is part of the text, and may be
+			// part of mixed content.
+			// Leading whitespace does not count, as for the fast parser.
+			if run > 0 {
+				if err := addRun(p.current.ValueString()); err != nil {
+					return err
+				}
+			}
+			if c != nil {
+				c.addText(p.current.ValueString(), p.position())
+			}
+			textParts = append(textParts, p.current.ValueString())
+			p.advance()
+			continue
+		}
 		token := p.peek()
 		if token == nil || !p.hasToken {
 			break
@@ -225,115 +542,138 @@ func (p *Parser) parseContent(properties map[string]ast.SchemaNode) error {
 		switch token.Kind() {
 		case tokenizer.TokenEndTagOpen:
 			// End of content, closing tag coming
-			// Add accumulated text/cdata if any
-			if len(textParts) > 0 {
-				combined := strings.Join(textParts, "")
-				trimmed := strings.TrimSpace(combined)
-				if trimmed != "" {
-					properties["#text"] = ast.NewLiteralNode(trimmed, p.position())
-				}
-			}
-			if len(cdataParts) > 0 {
-				properties["#cdata"] = ast.NewLiteralNode(strings.Join(cdataParts, ""), p.position())
+			if p.lenient && !p.lenientEndTag() {
+				continue
 			}
+			finish()
 			return nil
 
-		case tokenizer.TokenText:
-			// Text content
-			textParts = append(textParts, p.current.ValueString())
-			p.advance()
-
-		case tokenizer.TokenName:
+		case tokenizer.TokenText, tokenizer.TokenName, tokenizer.TokenEquals, tokenizer.TokenString, tokenizer.TokenTagClose:
 			// In some cases, text content can be tokenized as Name
 			// This happens when text doesn't contain special characters
-			// Treat it as text content
-			textParts = append(textParts, p.current.ValueString())
+			// Treat it as text content, as well as the "=", quoted strings
+			// and ">" that the tokenizer takes for markup in text such as
+			// a="b" or base64 padding
+			if err := addRun(p.current.ValueString()); err != nil {
+				return err
+			}
+			if p.lenient {
+				p.checkReferences(p.current.ValueString(), p.position())
+			}
+			text, err := p.charRefs(p.current.ValueString(), p.position())
+			if err != nil {
+				return err
+			}
+			if c != nil {
+				c.addText(text, p.position())
+			}
+			textParts = append(textParts, text)
 			p.advance()
 
 		case tokenizer.TokenCDataStart:
-			// CDATA section - for now, skip CDATA sections
-			// A proper implementation would tokenize the CDATA content
-			p.advance() // consume <![CDATA[
-
-			// Note: CDATA is fully supported via fastparser (see internal/fastparser/parser.go)
-			// This AST parser provides basic CDATA handling. For full CDATA support,
-			// use Validate() or ValidateReader() which use the fastparser.
-			for {
-				tok := p.peek()
-				if tok == nil || !p.hasToken {
-					return fmt.Errorf("unterminated CDATA section")
-				}
-				// For now, just advance past CDATA
-				// In a real implementation, we'd look for ]]> token
-				p.advance()
-				break // Simplified - just skip CDATA for now
+			run = 0
+			pos := p.position()
+			cdata, err := p.parseCDATA()
+			if err != nil {
+				return err
+			}
+			cdata = p.newlines(cdata)
+			cdataParts = append(cdataParts, cdata)
+			if c != nil {
+				c.add("#cdata", cdata, pos)
 			}
 
 		case tokenizer.TokenTagOpen:
+			run = 0
+			if p.html && p.closedByStart() {
+				p.implicitClose = true
+				finish()
+				return nil
+			}
 			// Child element
-			// First, save any accumulated text
-			if len(textParts) > 0 {
-				combined := strings.Join(textParts, "")
-				trimmed := strings.TrimSpace(combined)
-				if trimmed != "" {
-					properties["#text"] = ast.NewLiteralNode(trimmed, p.position())
+			// First, save any accumulated text; mixed content keeps all of it
+			if len(textParts) > 0 && c == nil {
+				combined := decodeEntities(p.newlines(strings.Join(textParts, "")))
+				if text := p.trimText(combined); text != "" {
+					properties["#text"] = ast.NewLiteralNode(text, p.position())
 				}
 				textParts = nil
 			}
 
-			childNode, err := p.parseElement()
+			childNode, childName, err := p.parseElement()
 			if err != nil {
 				return err
 			}
+			if c != nil {
+				c.add("#element", childName, childNode.Position())
+			}
 
-			// Determine child element name by looking ahead
-			// For now, use a generic key - in real implementation,
-			// we'd need to track element name from parseElement
-			// This is a simplified version that accumulates children
-			// into an array if multiple children exist
-
-			// For this implementation, we'll use the element structure
-			// to determine the name. Since we return ObjectNode, we need
-			// to extract element name somehow. Let's use a simpler approach:
-			// just accumulate children with numeric keys
-
-			// Better: let's store children by their tag names
-			// We need to modify parseElement to return the element name too
-			// For now, let's use a workaround
-
-			// Store child - need to handle repeated elements as arrays
-			childKey := "child" // placeholder - ideally we'd know the element name
-
-			if existing, exists := properties[childKey]; exists {
-				// Already have this element - convert to array or append to array
+			// Store child by element name; repeated elements become an array
+			if existing, exists := properties[childName]; exists {
 				if arrayNode, ok := existing.(*ast.ArrayDataNode); ok {
-					// Already an array, append
-					elements := arrayNode.Elements()
-					elements = append(elements, childNode)
-					properties[childKey] = ast.NewArrayDataNode(elements, arrayNode.Position())
+					elements := append(arrayNode.Elements(), childNode)
+					properties[childName] = ast.NewArrayDataNode(elements, arrayNode.Position())
 				} else {
-					// Convert single element to array
 					elements := []ast.SchemaNode{existing, childNode}
-					properties[childKey] = ast.NewArrayDataNode(elements, existing.Position())
+					properties[childName] = ast.NewArrayDataNode(elements, existing.Position())
 				}
 			} else {
-				// First occurrence
-				properties[childKey] = childNode
+				properties[childName] = childNode
+			}
+			if p.closing != "" {
+				// In lenient mode the end tag ending the child closes
+				// this element too.
+				finish()
+				return nil
 			}
 
 		case tokenizer.TokenCommentStart:
-			// Skip comment
-			p.skipComment()
+			run = 0
+			if err := p.checkLength(len(commentText(p.current.ValueString())), p.position()); err != nil {
+				return err
+			}
+			if c != nil && p.comments {
+				c.add("#comment", commentText(p.newlines(p.current.ValueString())), p.position())
+			}
+			p.advance()
 
 		default:
-			return fmt.Errorf("unexpected token in element content: %s at %s",
-				token.Kind(), p.positionStr())
+			return fmt.Errorf("unexpected token in element content: %s", token.Kind())
 		}
 	}
 
+	// End of input: the caller reports the missing end tag, unless it is
+	// lenient.
+	finish()
 	return nil
 }
 
+// parseCDATA parses a CDATA section and returns its content verbatim.
+//
+// Grammar:
+//
+//	CDATA = "<![CDATA[" CDATAContent "]]>"
+func (p *Parser) parseCDATA() (string, error) {
+	if err := p.expect(tokenizer.TokenCDataStart); err != nil {
+		return "", err
+	}
+
+	var content string
+	if p.hasToken && p.current.Kind() == tokenizer.TokenCDataContent {
+		content = p.current.ValueString()
+		if err := p.checkLength(len(content), p.position()); err != nil {
+			return "", err
+		}
+		p.advance()
+	}
+
+	if !p.hasToken || p.current.Kind() != tokenizer.TokenCDataEnd {
+		return "", errors.New("unterminated CDATA section")
+	}
+	p.advance()
+	return content, nil
+}
+
 // skipXMLDeclaration skips the XML declaration.
 // <?xml version="1.0" encoding="UTF-8"?>
 func (p *Parser) skipXMLDeclaration() error {
@@ -357,54 +697,36 @@ func (p *Parser) skipXMLDeclaration() error {
 	}
 }
 
-// skipComment skips a comment section.
+// skipComment skips a comment, which is a single token.
 func (p *Parser) skipComment() {
 	if p.peek() == nil || p.peek().Kind() != tokenizer.TokenCommentStart {
 		return
 	}
+	p.advance()
+}
 
-	p.advance() // consume <!--
-
-	// Skip until -->
-	for {
-		token := p.peek()
-		if token == nil || !p.hasToken {
-			return
-		}
-
-		if token.Kind() == tokenizer.TokenCommentEnd {
-			p.advance()
-			return
+// skipComments skips comments, appending them to items as {"#comment":
+// text} nodes if comments are kept, see SetComments.
+func (p *Parser) skipComments(items []ast.SchemaNode) []ast.SchemaNode {
+	for p.peek() != nil && p.hasToken && p.peek().Kind() == tokenizer.TokenCommentStart {
+		if p.comments {
+			items = append(items, commentNode(p.newlines(p.current.ValueString()), p.position()))
 		}
-
 		p.advance()
 	}
+	return items
 }
 
-// skipComments skips multiple comments.
-func (p *Parser) skipComments() {
-	for p.peek() != nil && p.peek().Kind() == tokenizer.TokenCommentStart {
-		p.skipComment()
-	}
+// commentNode returns the {"#comment": text} node for the comment token
+// value raw.
+func commentNode(raw string, pos ast.Position) ast.SchemaNode {
+	text := ast.NewLiteralNode(commentText(raw), pos)
+	return ast.NewObjectNode(map[string]ast.SchemaNode{"#comment": text}, pos)
 }
 
-// skipCommentsAndWhitespace skips comments and whitespace.
-func (p *Parser) skipCommentsAndWhitespace() {
-	for {
-		token := p.peek()
-		if token == nil || !p.hasToken {
-			return
-		}
-
-		kind := token.Kind()
-		if kind == tokenizer.TokenCommentStart {
-			p.skipComment()
-		} else if kind == "Whitespace" {
-			p.advance()
-		} else {
-			return
-		}
-	}
+// commentText returns the text between "<!--" and "-->".
+func commentText(raw string) string {
+	return raw[len("<!--") : len(raw)-len("-->")]
 }
 
 // Helper methods
@@ -434,12 +756,10 @@ func (p *Parser) advance() {
 func (p *Parser) expect(kind string) error {
 	token := p.peek()
 	if token == nil {
-		return fmt.Errorf("expected %s at %s, got EOF",
-			kind, p.positionStr())
+		return fmt.Errorf("expected %s, got EOF", kind)
 	}
 	if token.Kind() != kind {
-		return fmt.Errorf("expected %s at %s, got %s",
-			kind, p.positionStr(), token.Kind())
+		return fmt.Errorf("expected %s, got %s", kind, token.Kind())
 	}
 	p.advance()
 	return nil
@@ -457,11 +777,6 @@ func (p *Parser) position() ast.Position {
 	return ast.ZeroPosition()
 }
 
-// positionStr returns current position as a string for error messages.
-func (p *Parser) positionStr() string {
-	return p.position().String()
-}
-
 // unquoteString removes quotes from an XML attribute value.
 // Handles both single and double quotes.
 func (p *Parser) unquoteString(s string) string {
@@ -473,12 +788,5 @@ func (p *Parser) unquoteString(s string) string {
 		}
 	}
 
-	// Unescape XML entities
-	s = strings.ReplaceAll(s, "&lt;", "<")
-	s = strings.ReplaceAll(s, "&gt;", ">")
-	s = strings.ReplaceAll(s, "&amp;", "&")
-	s = strings.ReplaceAll(s, "&apos;", "'")
-	s = strings.ReplaceAll(s, "&quot;", "\"")
-
-	return s
+	return decodeEntities(p.newlines(s))
 }
@@ -2,7 +2,9 @@ package parser
 
 import (
 	"testing"
+	"github.com/shapestone/shape-core/pkg/ast"
 	"github.com/shapestone/shape-core/pkg/tokenizer"
+	"github.com/shapestone/shape-xml/internal/charset"
 )
 
 // TestNewParserFromStream tests the NewParserFromStream constructor
@@ -46,3 +48,190 @@ func TestSkipCommentsAndWhitespace(t *testing.T) {
 		})
 	}
 }
+
+func TestParser_RootName(t *testing.T) {
+	p := NewParser(`<?xml version="1.0"?><catalog><book/></catalog>`)
+	if _, err := p.Parse(); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := p.RootName(); got != "catalog" {
+		t.Errorf("RootName() = %q, want %q", got, "catalog")
+	}
+}
+
+func TestParser_CDATA(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		text  string
+		cdata string
+	}{
+		{"markup", `<data><![CDATA[<raw> & ]]]></data>`, "", "<raw> & ]"},
+		{"mixed with text", `<data>a<![CDATA[ b ]]>c<![CDATA[d]]></data>`, "ac", " b d"},
+		{"empty", `<data><![CDATA[]]></data>`, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := NewParser(tt.input).Parse()
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			props := node.(*ast.ObjectNode).Properties()
+			cdata, ok := props["#cdata"].(*ast.LiteralNode)
+			if !ok || cdata.Value() != tt.cdata {
+				t.Errorf("#cdata = %v, want %q", props["#cdata"], tt.cdata)
+			}
+			if tt.text == "" {
+				if _, ok := props["#text"]; ok {
+					t.Errorf("unexpected #text %v", props["#text"])
+				}
+			} else if text, ok := props["#text"].(*ast.LiteralNode); !ok || text.Value() != tt.text {
+				t.Errorf("#text = %v, want %q", props["#text"], tt.text)
+			}
+		})
+	}
+
+	if _, err := NewParser(`<data><![CDATA[open</data>`).Parse(); err == nil {
+		t.Error("expected an error for an unterminated CDATA section")
+	}
+}
+
+func TestParser_Doctype(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"html", "<!DOCTYPE html>\n<html><body>hi</body></html>"},
+		{"public id", `<?xml version="1.0"?>
+<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd">
+<svg width="10"/>`},
+		{"internal subset", `<!DOCTYPE note [<!ELEMENT note (#PCDATA)><!ATTLIST note x CDATA "a>b">]><note/>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(tt.input)
+			if _, err := p.Parse(); err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if p.RootName() == "" {
+				t.Error("root element not parsed")
+			}
+		})
+	}
+
+	if _, err := NewParser(`<!DOCTYPE a [<a/>`).Parse(); err == nil {
+		t.Error("expected an error for an unterminated DOCTYPE")
+	}
+}
+
+func TestParser_EntityReferences(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		text  string
+		attr  string
+	}{
+		{"predefined", `<a x="&lt;&amp;&quot;&apos;&gt;">1 &lt; 2 &amp;&amp; 3 &gt; 2</a>`, "1 < 2 && 3 > 2", `<&"'>`},
+		{"character", `<a x="&#60;&#x3E;">&#65;&#x42;&#x1F600;</a>`, "AB\U0001F600", "<>"},
+		{"escaped reference", `<a x="&amp;lt;">&amp;amp;</a>`, "&amp;", "&lt;"},
+		{"undeclared kept", `<a x="&e;">&e; &#xZZ;</a>`, "&e; &#xZZ;", "&e;"},
+		{"trimmed after decoding", `<a x="">&#32;x </a>`, "x", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := NewParser(tt.input).Parse()
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			props := node.(*ast.ObjectNode).Properties()
+			if text, ok := props["#text"].(*ast.LiteralNode); !ok || text.Value() != tt.text {
+				t.Errorf("#text = %v, want %q", props["#text"], tt.text)
+			}
+			if attr, ok := props["@x"].(*ast.LiteralNode); !ok || attr.Value() != tt.attr {
+				t.Errorf("@x = %v, want %q", props["@x"], tt.attr)
+			}
+		})
+	}
+}
+
+func TestParser_InvalidCharRefs(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`<a>x &#0;</a>`, `xml: syntax error at line 1, column 6: reference &#0; to illegal character U+0000`},
+		{"<a>\n  &#xFFFE;</a>", `xml: syntax error at line 2, column 3: reference &#xFFFE; to illegal character U+FFFE`},
+		{`<a x="ok&#1;"/>`, `xml: syntax error at line 1, column 9: reference &#1; to illegal character U+0001`},
+		{`<a>&#x110000;</a>`, `xml: syntax error at line 1, column 4: reference &#x110000; to illegal character U+110000`},
+	}
+	for _, tt := range tests {
+		_, err := NewParser(tt.input).Parse()
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Parse(%q) = %v\nwant %s", tt.input, err, tt.want)
+		}
+		if _, err := NewParserFromBytes([]byte(tt.input)).Parse(); err == nil || err.Error() != tt.want {
+			t.Errorf("Parse bytes (%q) = %v\nwant %s", tt.input, err, tt.want)
+		}
+	}
+
+	for mode, want := range map[charset.Mode]string{charset.Strip: "ab", charset.Replace: "a\uFFFDb"} {
+		p := NewParser(`<a x="a&#1;b">a&#xFFFF;b</a>`)
+		p.SetInvalidChars(mode)
+		node, err := p.Parse()
+		if err != nil {
+			t.Fatalf("Parse, mode %d: %v", mode, err)
+		}
+		props := node.(*ast.ObjectNode).Properties()
+		if text := props["#text"].(*ast.LiteralNode).Value(); text != want {
+			t.Errorf("#text, mode %d = %q, want %q", mode, text, want)
+		}
+		if attr := props["@x"].(*ast.LiteralNode).Value(); attr != want {
+			t.Errorf("@x, mode %d = %q, want %q", mode, attr, want)
+		}
+	}
+
+	p := NewParser(`<a x="&#1;">&#2;</a>`)
+	p.SetLenient(true)
+	node, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(p.Errors()); n != 2 {
+		t.Errorf("lenient: %d errors, want 2: %v", n, p.Errors())
+	}
+	if text := node.(*ast.ObjectNode).Properties()["#text"].(*ast.LiteralNode).Value(); text != "\uFFFD" {
+		t.Errorf("lenient: #text = %q", text)
+	}
+}
+
+func TestParser_InvalidUTF8(t *testing.T) {
+	_, err := NewParser("<a>ok\x81</a>").Parse()
+	want := "xml: syntax error at line 1, column 6: invalid UTF-8"
+	if err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %s", err, want)
+	}
+}
+
+func TestParser_MarkupCharactersInText(t *testing.T) {
+	tests := []struct {
+		input string
+		text  string
+	}{
+		{`<a>dGV4dA==</a>`, "dGV4dA=="},
+		{`<a>x = "y"</a>`, `x = "y"`},
+		{`<a>a > b</a>`, "a > b"},
+		{`<a><b/>it's 'q' <c/></a>`, "it's 'q'"},
+	}
+	for _, tt := range tests {
+		node, err := NewParser(tt.input).Parse()
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.input, err)
+			continue
+		}
+		text, ok := node.(*ast.ObjectNode).Properties()["#text"].(*ast.LiteralNode)
+		if !ok || text.Value() != tt.text {
+			t.Errorf("Parse(%q) #text = %v, want %q", tt.input, text, tt.text)
+		}
+	}
+}
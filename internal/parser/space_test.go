@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+func TestPreserveSpace(t *testing.T) {
+	input := "<r>\n <a xml:space=\"preserve\"> x <b>  </b><e xml:space=\"default\"> w </e></a>\n <c>  y  </c>\n <d xml:space=\"default\"> z </d>\n</r>"
+	for _, preserve := range []bool{false, true} {
+		p := NewParser(input)
+		p.SetPreserveSpace(preserve)
+		node, err := p.Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		props := node.(*ast.ObjectNode).Properties()
+		text := func(props map[string]ast.SchemaNode, name string) string {
+			lit, ok := props[name].(*ast.ObjectNode).Properties()["#text"].(*ast.LiteralNode)
+			if !ok {
+				return ""
+			}
+			return lit.Value().(string)
+		}
+		a := props["a"].(*ast.ObjectNode).Properties()
+		want := map[string]string{"a": " x ", "c": "y", "d": "z"}
+		wantE := "w"
+		if preserve {
+			want["c"], want["d"], wantE = "  y  ", " z ", " w "
+		}
+		for name, want := range want {
+			if got := text(props, name); got != want {
+				t.Errorf("preserve=%v: %s = %q, want %q", preserve, name, got, want)
+			}
+		}
+		if got := text(a, "b"); got != "  " {
+			t.Errorf("preserve=%v: b = %q, want the whitespace kept", preserve, got)
+		}
+		if got := text(a, "e"); got != wantE {
+			t.Errorf("preserve=%v: e = %q, want %q", preserve, got, wantE)
+		}
+		if _, ok := props["#text"]; ok != preserve {
+			t.Errorf("preserve=%v: whitespace-only #text of r present = %v", preserve, ok)
+		}
+	}
+}
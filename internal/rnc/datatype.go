@@ -0,0 +1,415 @@
+package rnc
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shapestone/shape-xml/pkg/xsdtypes"
+)
+
+// xsdNamespace is the datatype library of the xsd prefix.
+const xsdNamespace = "http://www.w3.org/2001/XMLSchema-datatypes"
+
+// datatype is a datatype of the built-in library (string and token) or of
+// XML Schema, restricted by the parameters given in the schema.
+type datatype struct {
+	name string
+	// base is the primitive kind the type is checked and compared as.
+	base baseKind
+	// check checks the lexical form, after whitespace handling.
+	check func(string) error
+	// whitespace is 'p' to preserve, 'r' to replace and 'c' to collapse.
+	whitespace byte
+	// list types count their items for the length facets.
+	list bool
+
+	length, minLength, maxLength int
+	patterns                     []*regexp.Regexp
+	min, max                     *big.Rat
+	minExclusive, maxExclusive   bool
+	totalDigits, fractionDigits  int
+}
+
+type baseKind uint8
+
+const (
+	baseString baseKind = iota
+	baseDecimal
+	baseFloat
+	baseBoolean
+)
+
+// datatypeInfo describes a type of the library: how its values are
+// compared and checked.
+type datatypeInfo struct {
+	base       baseKind
+	whitespace byte
+	check      func(string) error
+	list       bool
+}
+
+var (
+	maxLong  = big.NewRat(1<<63-1, 1)
+	minLong  = big.NewRat(-1<<63, 1)
+	maxULong = new(big.Rat).SetInt(new(big.Int).SetUint64(1<<64 - 1))
+)
+
+// integerRange returns a check for integers in [min, max]; nil bounds are
+// open.
+func integerRange(min, max *big.Rat) func(string) error {
+	return func(s string) error {
+		r, ok := parseInteger(s)
+		if !ok {
+			return fmt.Errorf("%q is not an integer", s)
+		}
+		if (min != nil && r.Cmp(min) < 0) || (max != nil && r.Cmp(max) > 0) {
+			return fmt.Errorf("%s is out of range", s)
+		}
+		return nil
+	}
+}
+
+var datatypes = map[string]datatypeInfo{
+	"string":             {whitespace: 'p'},
+	"normalizedString":   {whitespace: 'r'},
+	"token":              {whitespace: 'c'},
+	"language":           {whitespace: 'c', check: matches(`[a-zA-Z]{1,8}(-[a-zA-Z0-9]{1,8})*`, "language tag")},
+	"Name":               {whitespace: 'c', check: checkName(false)},
+	"NCName":             {whitespace: 'c', check: checkName(true)},
+	"ID":                 {whitespace: 'c', check: checkName(true)},
+	"IDREF":              {whitespace: 'c', check: checkName(true)},
+	"ENTITY":             {whitespace: 'c', check: checkName(true)},
+	"IDREFS":             {whitespace: 'c', check: listOf(checkName(true)), list: true},
+	"ENTITIES":           {whitespace: 'c', check: listOf(checkName(true)), list: true},
+	"NMTOKEN":            {whitespace: 'c', check: checkNmtoken},
+	"NMTOKENS":           {whitespace: 'c', check: listOf(checkNmtoken), list: true},
+	"QName":              {whitespace: 'c', check: checkQName},
+	"anyURI":             {whitespace: 'c', check: checkURI},
+	"boolean":            {base: baseBoolean, whitespace: 'c', check: checkBoolean},
+	"decimal":            {base: baseDecimal, whitespace: 'c', check: checkDecimal},
+	"integer":            {base: baseDecimal, whitespace: 'c', check: integerRange(nil, nil)},
+	"nonPositiveInteger": {base: baseDecimal, whitespace: 'c', check: integerRange(nil, big.NewRat(0, 1))},
+	"negativeInteger":    {base: baseDecimal, whitespace: 'c', check: integerRange(nil, big.NewRat(-1, 1))},
+	"nonNegativeInteger": {base: baseDecimal, whitespace: 'c', check: integerRange(big.NewRat(0, 1), nil)},
+	"positiveInteger":    {base: baseDecimal, whitespace: 'c', check: integerRange(big.NewRat(1, 1), nil)},
+	"long":               {base: baseDecimal, whitespace: 'c', check: integerRange(minLong, maxLong)},
+	"int":                {base: baseDecimal, whitespace: 'c', check: integerRange(big.NewRat(-1<<31, 1), big.NewRat(1<<31-1, 1))},
+	"short":              {base: baseDecimal, whitespace: 'c', check: integerRange(big.NewRat(-1<<15, 1), big.NewRat(1<<15-1, 1))},
+	"byte":               {base: baseDecimal, whitespace: 'c', check: integerRange(big.NewRat(-1<<7, 1), big.NewRat(1<<7-1, 1))},
+	"unsignedLong":       {base: baseDecimal, whitespace: 'c', check: integerRange(big.NewRat(0, 1), maxULong)},
+	"unsignedInt":        {base: baseDecimal, whitespace: 'c', check: integerRange(big.NewRat(0, 1), big.NewRat(1<<32-1, 1))},
+	"unsignedShort":      {base: baseDecimal, whitespace: 'c', check: integerRange(big.NewRat(0, 1), big.NewRat(1<<16-1, 1))},
+	"unsignedByte":       {base: baseDecimal, whitespace: 'c', check: integerRange(big.NewRat(0, 1), big.NewRat(1<<8-1, 1))},
+	"float":              {base: baseFloat, whitespace: 'c', check: checkFloat},
+	"double":             {base: baseFloat, whitespace: 'c', check: checkFloat},
+	"date":               {whitespace: 'c', check: func(s string) error { return xsdtypes.Date(s).Validate() }},
+	"time":               {whitespace: 'c', check: func(s string) error { return xsdtypes.Time(s).Validate() }},
+	"dateTime":           {whitespace: 'c', check: func(s string) error { return xsdtypes.DateTime(s).Validate() }},
+	"gYearMonth":         {whitespace: 'c', check: func(s string) error { return xsdtypes.GYearMonth(s).Validate() }},
+	"gYear":              {whitespace: 'c', check: matches(`-?\d{4,}(Z|[+-]\d\d:\d\d)?`, "gYear")},
+	"gMonthDay":          {whitespace: 'c', check: matches(`--(0[1-9]|1[0-2])-(0[1-9]|[12]\d|3[01])(Z|[+-]\d\d:\d\d)?`, "gMonthDay")},
+	"gDay":               {whitespace: 'c', check: matches(`---(0[1-9]|[12]\d|3[01])(Z|[+-]\d\d:\d\d)?`, "gDay")},
+	"gMonth":             {whitespace: 'c', check: matches(`--(0[1-9]|1[0-2])(Z|[+-]\d\d:\d\d)?`, "gMonth")},
+	"duration":           {whitespace: 'c', check: func(s string) error { return xsdtypes.Duration(s).Validate() }},
+	"hexBinary": {whitespace: 'c', check: func(s string) error {
+		_, err := hex.DecodeString(s)
+		return err
+	}},
+	"base64Binary": {whitespace: 'c', check: func(s string) error {
+		_, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(s, " ", ""))
+		return err
+	}},
+}
+
+// newDatatype returns the datatype name of the library uri, or of the
+// built-in library if uri is "", restricted by params.
+func newDatatype(uri, name string, params [][2]string) (*datatype, error) {
+	var info datatypeInfo
+	switch uri {
+	case "":
+		if name != "string" && name != "token" {
+			return nil, fmt.Errorf("unknown built-in datatype %q", name)
+		}
+		info = datatypes[name]
+	case xsdNamespace:
+		var ok bool
+		if info, ok = datatypes[name]; !ok {
+			return nil, fmt.Errorf("unsupported XML Schema datatype %q", name)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported datatype library %q", uri)
+	}
+	dt := &datatype{name: name, base: info.base, check: info.check, whitespace: info.whitespace, list: info.list,
+		length: -1, minLength: -1, maxLength: -1, totalDigits: -1, fractionDigits: -1}
+	if len(params) > 0 && uri == "" {
+		return nil, fmt.Errorf("built-in datatype %q takes no parameters", name)
+	}
+	for _, param := range params {
+		if err := dt.setParam(param[0], param[1]); err != nil {
+			return nil, err
+		}
+	}
+	return dt, nil
+}
+
+// setParam applies the facet name with value.
+func (dt *datatype) setParam(name, value string) error {
+	length := func(dst *int) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("parameter %s: %q is not a length", name, value)
+		}
+		*dst = n
+		return nil
+	}
+	bound := func(dst **big.Rat, exclusive *bool, excl bool) error {
+		if dt.base != baseDecimal && dt.base != baseFloat {
+			return fmt.Errorf("parameter %s is not supported for %s", name, dt.name)
+		}
+		r, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+		if !ok || dt.check(strings.TrimSpace(value)) != nil {
+			return fmt.Errorf("parameter %s: %q is not a valid %s", name, value, dt.name)
+		}
+		*dst, *exclusive = r, excl
+		return nil
+	}
+	switch name {
+	case "length":
+		return length(&dt.length)
+	case "minLength":
+		return length(&dt.minLength)
+	case "maxLength":
+		return length(&dt.maxLength)
+	case "totalDigits":
+		return length(&dt.totalDigits)
+	case "fractionDigits":
+		return length(&dt.fractionDigits)
+	case "pattern":
+		re, err := regexp.Compile(`^(?:` + value + `)$`)
+		if err != nil {
+			return fmt.Errorf("parameter pattern: %v", err)
+		}
+		dt.patterns = append(dt.patterns, re)
+	case "minInclusive":
+		return bound(&dt.min, &dt.minExclusive, false)
+	case "minExclusive":
+		return bound(&dt.min, &dt.minExclusive, true)
+	case "maxInclusive":
+		return bound(&dt.max, &dt.maxExclusive, false)
+	case "maxExclusive":
+		return bound(&dt.max, &dt.maxExclusive, true)
+	default:
+		return fmt.Errorf("unsupported parameter %q", name)
+	}
+	return nil
+}
+
+// normalize applies the whitespace handling of the datatype.
+func (dt *datatype) normalize(s string) string {
+	switch dt.whitespace {
+	case 'r':
+		return strings.Map(func(r rune) rune {
+			if r == '\t' || r == '\n' || r == '\r' {
+				return ' '
+			}
+			return r
+		}, s)
+	case 'c':
+		return strings.Join(strings.Fields(s), " ")
+	}
+	return s
+}
+
+// allows returns an error if s is not a valid value of the datatype.
+func (dt *datatype) allows(s string) error {
+	s = dt.normalize(s)
+	if dt.check != nil {
+		if err := dt.check(s); err != nil {
+			return err
+		}
+	}
+	n := utf8.RuneCountInString(s)
+	if dt.list {
+		n = len(strings.Fields(s))
+	}
+	switch {
+	case dt.length >= 0 && n != dt.length:
+		return fmt.Errorf("length is %d, not %d", n, dt.length)
+	case dt.minLength >= 0 && n < dt.minLength:
+		return fmt.Errorf("length %d is less than %d", n, dt.minLength)
+	case dt.maxLength >= 0 && n > dt.maxLength:
+		return fmt.Errorf("length %d is more than %d", n, dt.maxLength)
+	}
+	for _, re := range dt.patterns {
+		if !re.MatchString(s) {
+			return fmt.Errorf("%q does not match pattern %s", s, re)
+		}
+	}
+	if dt.min != nil || dt.max != nil {
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return fmt.Errorf("%q is not comparable", s)
+		}
+		if dt.min != nil && (r.Cmp(dt.min) < 0 || (dt.minExclusive && r.Cmp(dt.min) == 0)) {
+			return fmt.Errorf("%s is too small", s)
+		}
+		if dt.max != nil && (r.Cmp(dt.max) > 0 || (dt.maxExclusive && r.Cmp(dt.max) == 0)) {
+			return fmt.Errorf("%s is too large", s)
+		}
+	}
+	if dt.totalDigits >= 0 || dt.fractionDigits >= 0 {
+		digits := strings.TrimLeft(strings.TrimLeft(s, "+-"), "0")
+		fraction := ""
+		if i := strings.IndexByte(digits, '.'); i >= 0 {
+			fraction = strings.TrimRight(digits[i+1:], "0")
+			digits = digits[:i] + fraction
+		}
+		if dt.totalDigits >= 0 && len(digits) > dt.totalDigits {
+			return fmt.Errorf("%s has more than %d digits", s, dt.totalDigits)
+		}
+		if dt.fractionDigits >= 0 && len(fraction) > dt.fractionDigits {
+			return fmt.Errorf("%s has more than %d fraction digits", s, dt.fractionDigits)
+		}
+	}
+	return nil
+}
+
+// equal reports whether s is the value want, declared with the datatype,
+// comparing values rather than their lexical forms where the two differ.
+func (dt *datatype) equal(want, s string) bool {
+	s = dt.normalize(s)
+	want = dt.normalize(want)
+	if dt.check != nil && dt.check(s) != nil {
+		return false
+	}
+	switch dt.base {
+	case baseDecimal:
+		a, ok1 := new(big.Rat).SetString(want)
+		b, ok2 := new(big.Rat).SetString(s)
+		return ok1 && ok2 && a.Cmp(b) == 0
+	case baseFloat:
+		a, err1 := parseFloat(want)
+		b, err2 := parseFloat(s)
+		return err1 == nil && err2 == nil && a == b
+	case baseBoolean:
+		return (want == "true" || want == "1") == (s == "true" || s == "1")
+	}
+	return want == s
+}
+
+// parseInteger parses an xsd:integer.
+func parseInteger(s string) (*big.Rat, bool) {
+	digits := strings.TrimLeft(s, "+-")
+	if digits == "" || len(s)-len(digits) > 1 || strings.Trim(digits, "0123456789") != "" {
+		return nil, false
+	}
+	return new(big.Rat).SetString(s)
+}
+
+func checkDecimal(s string) error {
+	digits := strings.TrimLeft(s, "+-")
+	if len(s)-len(digits) > 1 || strings.Count(digits, ".") > 1 ||
+		strings.Trim(digits, "0123456789.") != "" || strings.Trim(digits, ".") == "" {
+		return fmt.Errorf("%q is not a decimal", s)
+	}
+	return nil
+}
+
+func parseFloat(s string) (float64, error) {
+	switch s {
+	case "INF":
+		s = "+Inf"
+	case "-INF":
+		s = "-Inf"
+	case "NaN":
+	default:
+		if strings.ContainsAny(s, "xXpP_iInN") {
+			return 0, fmt.Errorf("%q is not a floating-point number", s)
+		}
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func checkFloat(s string) error {
+	if _, err := parseFloat(s); err != nil {
+		return fmt.Errorf("%q is not a floating-point number", s)
+	}
+	return nil
+}
+
+func checkBoolean(s string) error {
+	switch s {
+	case "true", "false", "1", "0":
+		return nil
+	}
+	return fmt.Errorf("%q is not a boolean", s)
+}
+
+func checkURI(s string) error {
+	if _, err := url.Parse(s); err != nil {
+		return fmt.Errorf("%q is not a URI", s)
+	}
+	return nil
+}
+
+// checkName returns a check for XML names, without colons if ncName.
+func checkName(ncName bool) func(string) error {
+	return func(s string) error {
+		if !isName(s) || (ncName && strings.Contains(s, ":")) {
+			return fmt.Errorf("%q is not a name", s)
+		}
+		return nil
+	}
+}
+
+func checkQName(s string) error {
+	prefix, local, ok := strings.Cut(s, ":")
+	if !ok {
+		local, prefix = prefix, ""
+	}
+	if (ok && !isNCName(prefix)) || !isNCName(local) {
+		return fmt.Errorf("%q is not a qualified name", s)
+	}
+	return nil
+}
+
+func checkNmtoken(s string) error {
+	if s == "" || strings.IndexFunc(s, func(r rune) bool { return !isNameChar(r) }) >= 0 {
+		return fmt.Errorf("%q is not a name token", s)
+	}
+	return nil
+}
+
+// listOf returns a check for a whitespace-separated list of items.
+func listOf(item func(string) error) func(string) error {
+	return func(s string) error {
+		items := strings.Fields(s)
+		if len(items) == 0 {
+			return fmt.Errorf("empty list")
+		}
+		for _, it := range items {
+			if err := item(it); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// matches returns a check that s matches the regular expression expr.
+func matches(expr, what string) func(string) error {
+	re := regexp.MustCompile(`^(?:` + expr + `)$`)
+	return func(s string) error {
+		if !re.MatchString(s) {
+			return fmt.Errorf("%q is not a valid %s", s, what)
+		}
+		return nil
+	}
+}
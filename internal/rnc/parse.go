@@ -0,0 +1,759 @@
+package rnc
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Schema is a compiled RELAX NG schema. It is immutable and safe for
+// concurrent use; each document is checked with its own Validator.
+type Schema struct {
+	start *Pattern
+}
+
+// Parse compiles a schema in the RELAX NG compact syntax. Schemas are
+// self-contained: include, external and parent references and nested
+// grammars are not supported. Annotations are skipped.
+func Parse(src string) (*Schema, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{
+		toks:       toks,
+		namespaces: map[string]string{"xml": "http://www.w3.org/XML/1998/namespace"},
+		datatypes:  map[string]string{"xsd": xsdNamespace},
+		defines:    make(map[string]*define),
+	}
+	start, err := p.topLevel()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	return &Schema{start: start}, nil
+}
+
+// tokKind identifies the type of a token.
+type tokKind uint8
+
+const (
+	tokEOF     tokKind = iota
+	tokIdent           // an identifier or keyword
+	tokCName           // prefix:local
+	tokNsName          // prefix:*
+	tokLiteral         // a quoted string, text holding its value
+	tokPunct           // an operator or bracket
+)
+
+type token struct {
+	kind tokKind
+	text string
+	// escaped is set for identifiers written with a backslash, which are
+	// never keywords.
+	escaped      bool
+	line, column int
+}
+
+// keywords of the compact syntax.
+var keywords = map[string]bool{
+	"attribute": true, "default": true, "datatypes": true, "div": true, "element": true,
+	"empty": true, "external": true, "grammar": true, "include": true, "inherit": true,
+	"list": true, "mixed": true, "namespace": true, "notAllowed": true, "parent": true,
+	"start": true, "string": true, "text": true, "token": true,
+}
+
+// is reports whether t is the punctuation or keyword s.
+func (t token) is(s string) bool {
+	switch t.kind {
+	case tokPunct:
+		return t.text == s
+	case tokIdent:
+		return !t.escaped && t.text == s && keywords[s]
+	}
+	return false
+}
+
+// isIdentifier reports whether t is an identifier other than a keyword.
+func (t token) isIdentifier() bool {
+	return t.kind == tokIdent && (t.escaped || !keywords[t.text])
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of schema"
+	case tokLiteral:
+		return fmt.Sprintf("literal %q", t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lex splits src into tokens.
+func lex(src string) ([]token, error) {
+	var toks []token
+	line, lineStart := 1, 0
+	errorf := func(i int, format string, args ...interface{}) error {
+		return fmt.Errorf("line %d, column %d: %s", line, i-lineStart+1, fmt.Sprintf(format, args...))
+	}
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			i++
+			line, lineStart = line+1, i
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		}
+
+		tok := token{line: line, column: i - lineStart + 1}
+		switch {
+		case c == '"' || c == '\'':
+			delim := string(c)
+			if strings.HasPrefix(src[i:], strings.Repeat(delim, 3)) {
+				delim = strings.Repeat(delim, 3)
+			}
+			end := strings.Index(src[i+len(delim):], delim)
+			if end < 0 || (len(delim) == 1 && strings.Contains(src[i+1:i+1+end], "\n")) {
+				return nil, errorf(i, "unterminated literal")
+			}
+			tok.kind, tok.text = tokLiteral, src[i+len(delim):i+len(delim)+end]
+			for _, r := range tok.text {
+				if r == '\n' {
+					line++
+				}
+			}
+			if n := strings.LastIndexByte(tok.text, '\n'); n >= 0 {
+				lineStart = i + len(delim) + n + 1
+			}
+			i += len(delim)*2 + end
+		case strings.HasPrefix(src[i:], "|=") || strings.HasPrefix(src[i:], "&=") || strings.HasPrefix(src[i:], ">>"):
+			tok.kind, tok.text = tokPunct, src[i:i+2]
+			i += 2
+		case strings.IndexByte("={}()[],|&?*+-~", c) >= 0:
+			tok.kind, tok.text = tokPunct, src[i:i+1]
+			i++
+		default:
+			if c == '\\' {
+				tok.escaped = true
+				i++
+			}
+			n := ncNameLen(src[i:])
+			if n == 0 {
+				r, _ := utf8.DecodeRuneInString(src[i:])
+				return nil, errorf(i, "unexpected %q", r)
+			}
+			tok.kind, tok.text = tokIdent, src[i:i+n]
+			i += n
+			if !tok.escaped && i < len(src) && src[i] == ':' {
+				if i+1 < len(src) && src[i+1] == '*' {
+					tok.kind = tokNsName
+					i += 2
+				} else if m := ncNameLen(src[i+1:]); m > 0 {
+					tok.kind, tok.text = tokCName, src[i-n:i+1+m]
+					i += 1 + m
+				}
+			}
+		}
+		toks = append(toks, tok)
+	}
+	return append(toks, token{kind: tokEOF, line: line, column: len(src) - lineStart + 1}), nil
+}
+
+// ncNameLen returns the length of the NCName at the start of s.
+func ncNameLen(s string) int {
+	n := 0
+	for n < len(s) {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if r == ':' || (n == 0 && !isNameStart(r)) || !isNameChar(r) {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+func isNameStart(r rune) bool {
+	return r == '_' || r == ':' || unicode.IsLetter(r)
+}
+
+func isNameChar(r rune) bool {
+	return isNameStart(r) || r == '-' || r == '.' || unicode.IsDigit(r) ||
+		r == 0xB7 || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r)
+}
+
+func isName(s string) bool {
+	for i, r := range s {
+		if (i == 0 && !isNameStart(r)) || !isNameChar(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isNCName(s string) bool {
+	return isName(s) && !strings.Contains(s, ":")
+}
+
+// parser reads a schema from its tokens.
+type parser struct {
+	toks       []token
+	pos        int
+	namespaces map[string]string
+	defaultNS  string
+	datatypes  map[string]string
+	defines    map[string]*define
+	// refs records the first reference to each definition.
+	refs map[*define]token
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the punctuation or keyword s.
+func (p *parser) accept(s string) bool {
+	if p.peek().is(s) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		return p.errorf(p.peek(), "expected %q, found %s", s, p.peek())
+	}
+	return nil
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("line %d, column %d: %s", t.line, t.column, fmt.Sprintf(format, args...))
+}
+
+// topLevel parses the declarations and then either a grammar or a single
+// pattern, returning the start pattern.
+func (p *parser) topLevel() (*Pattern, error) {
+	for {
+		p.skipAnnotations()
+		ok, err := p.decl()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+	}
+	if !p.isGrammarContent() {
+		start, err := p.pattern()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.peek(); t.kind != tokEOF {
+			return nil, p.errorf(t, "unexpected %s after pattern", t)
+		}
+		return start, nil
+	}
+	if err := p.grammarContent(); err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	start := p.defines["start"]
+	if start == nil || start.pattern == nil {
+		return nil, p.errorf(p.peek(), "grammar has no start pattern")
+	}
+	return start.pattern, nil
+}
+
+// decl parses a namespace or datatypes declaration, reporting whether
+// there was one.
+func (p *parser) decl() (bool, error) {
+	t := p.peek()
+	var isDefault bool
+	switch {
+	case t.is("default"):
+		isDefault = true
+		p.next()
+		if err := p.expect("namespace"); err != nil {
+			return false, err
+		}
+	case t.is("namespace"), t.is("datatypes"):
+		p.next()
+	default:
+		return false, nil
+	}
+	prefix := ""
+	if name := p.peek(); name.kind == tokIdent {
+		prefix = p.next().text
+	} else if !isDefault {
+		return false, p.errorf(name, "expected prefix, found %s", name)
+	}
+	if err := p.expect("="); err != nil {
+		return false, err
+	}
+	uri := ""
+	if !p.accept("inherit") {
+		lit, err := p.literal()
+		if err != nil {
+			return false, err
+		}
+		uri = lit
+	}
+	switch {
+	case t.is("datatypes"):
+		p.datatypes[prefix] = uri
+	default:
+		if prefix != "" {
+			p.namespaces[prefix] = uri
+		}
+		if isDefault {
+			p.defaultNS = uri
+		}
+	}
+	return true, nil
+}
+
+// isGrammarContent reports whether the schema continues with grammar
+// content rather than a pattern.
+func (p *parser) isGrammarContent() bool {
+	t := p.peek()
+	if t.is("start") || t.is("div") || t.is("include") {
+		return true
+	}
+	if t.isIdentifier() {
+		next := p.toks[p.pos+1]
+		return next.is("=") || next.is("|=") || next.is("&=")
+	}
+	return t.kind == tokCName && p.toks[p.pos+1].is("[")
+}
+
+// grammarContent parses definitions up to the end of the schema or of a
+// div.
+func (p *parser) grammarContent() error {
+	for {
+		p.skipAnnotations()
+		t := p.peek()
+		switch {
+		case t.kind == tokEOF, t.is("}"):
+			return nil
+		case t.is("div"):
+			p.next()
+			if err := p.expect("{"); err != nil {
+				return err
+			}
+			if err := p.grammarContent(); err != nil {
+				return err
+			}
+			if err := p.expect("}"); err != nil {
+				return err
+			}
+		case t.is("include"):
+			return p.errorf(t, "include is not supported; combine the schemas into one")
+		case t.kind == tokCName && p.toks[p.pos+1].is("["):
+			// An annotation element.
+			p.next()
+			p.skipAnnotations()
+		case t.is("start"), t.isIdentifier():
+			p.next()
+			op := p.next()
+			if !op.is("=") && !op.is("|=") && !op.is("&=") {
+				return p.errorf(op, "expected '=', '|=' or '&=' after %q, found %s", t.text, op)
+			}
+			pattern, err := p.pattern()
+			if err != nil {
+				return err
+			}
+			if err := p.define(t, op.text, pattern); err != nil {
+				return err
+			}
+		default:
+			return p.errorf(t, "expected a definition, found %s", t)
+		}
+	}
+}
+
+// define records the definition of name, combining it with earlier ones
+// for |= and &=.
+func (p *parser) define(name token, op string, pattern *Pattern) error {
+	d := p.lookup(name.text)
+	if d.pattern == nil {
+		d.pattern, d.line, d.column = pattern, name.line, name.column
+		if op == "=" {
+			d.assigned = true
+		} else {
+			d.combine = op[0]
+		}
+		return nil
+	}
+	if op == "=" {
+		if d.assigned {
+			return p.errorf(name, "%q is already defined at line %d", name.text, d.line)
+		}
+		d.assigned = true
+	} else {
+		if d.combine != 0 && d.combine != op[0] {
+			return p.errorf(name, "%q is combined with both |= and &=", name.text)
+		}
+		d.combine = op[0]
+	}
+	if d.combine == '|' {
+		d.pattern = &Pattern{kind: kindChoice, p1: d.pattern, p2: pattern}
+	} else {
+		d.pattern = &Pattern{kind: kindInterleave, p1: d.pattern, p2: pattern}
+	}
+	return nil
+}
+
+func (p *parser) lookup(name string) *define {
+	d := p.defines[name]
+	if d == nil {
+		d = &define{name: name}
+		p.defines[name] = d
+	}
+	return d
+}
+
+// pattern parses particles joined by one kind of operator.
+func (p *parser) pattern() (*Pattern, error) {
+	first, err := p.particle()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek()
+	if !op.is(",") && !op.is("|") && !op.is("&") {
+		return first, nil
+	}
+	k := map[string]kind{",": kindGroup, "|": kindChoice, "&": kindInterleave}[op.text]
+	result := first
+	for p.accept(op.text) {
+		next, err := p.particle()
+		if err != nil {
+			return nil, err
+		}
+		result = &Pattern{kind: k, p1: result, p2: next}
+	}
+	if t := p.peek(); t.is(",") || t.is("|") || t.is("&") {
+		return nil, p.errorf(t, "cannot mix %q and %q without parentheses", op.text, t.text)
+	}
+	return result, nil
+}
+
+// particle parses a primary pattern and its occurrence indicator.
+func (p *parser) particle() (*Pattern, error) {
+	primary, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.accept("?"):
+		primary = &Pattern{kind: kindChoice, p1: primary, p2: empty}
+	case p.accept("*"):
+		primary = &Pattern{kind: kindChoice, p1: &Pattern{kind: kindOneOrMore, p1: primary}, p2: empty}
+	case p.accept("+"):
+		primary = &Pattern{kind: kindOneOrMore, p1: primary}
+	}
+	p.skipFollowAnnotations()
+	return primary, nil
+}
+
+// primary parses a pattern without operators.
+func (p *parser) primary() (*Pattern, error) {
+	p.skipAnnotations()
+	t := p.next()
+	switch {
+	case t.is("element"), t.is("attribute"):
+		nc, err := p.nameClass(t.is("attribute"))
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("{"); err != nil {
+			return nil, err
+		}
+		content, err := p.pattern()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("}"); err != nil {
+			return nil, err
+		}
+		if t.is("attribute") {
+			return &Pattern{kind: kindAttribute, nc: nc, p1: content}, nil
+		}
+		return &Pattern{kind: kindElement, nc: nc, p1: content}, nil
+	case t.is("mixed"), t.is("list"):
+		if err := p.expect("{"); err != nil {
+			return nil, err
+		}
+		content, err := p.pattern()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("}"); err != nil {
+			return nil, err
+		}
+		if t.is("list") {
+			return &Pattern{kind: kindList, p1: content}, nil
+		}
+		return &Pattern{kind: kindInterleave, p1: content, p2: text}, nil
+	case t.is("("):
+		inner, err := p.pattern()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	case t.is("empty"):
+		return empty, nil
+	case t.is("text"):
+		return text, nil
+	case t.is("notAllowed"):
+		return notAllowed, nil
+	case t.is("parent"), t.is("external"), t.is("grammar"):
+		return nil, p.errorf(t, "%q is not supported", t.text)
+	case t.kind == tokLiteral:
+		p.pos--
+		value, err := p.literal()
+		if err != nil {
+			return nil, err
+		}
+		dt, _ := newDatatype("", "token", nil)
+		return &Pattern{kind: kindValue, dt: dt, value: value}, nil
+	case t.is("string"), t.is("token"), t.kind == tokCName:
+		return p.data(t)
+	case t.isIdentifier():
+		d := p.lookup(t.text)
+		if p.refs == nil {
+			p.refs = make(map[*define]token)
+		}
+		if _, ok := p.refs[d]; !ok {
+			p.refs[d] = t
+		}
+		return &Pattern{kind: kindRef, ref: d}, nil
+	}
+	return nil, p.errorf(t, "expected a pattern, found %s", t)
+}
+
+// data parses a data or value pattern after its datatype name.
+func (p *parser) data(name token) (*Pattern, error) {
+	uri, local := "", name.text
+	if name.kind == tokCName {
+		prefix, rest, _ := strings.Cut(name.text, ":")
+		var ok bool
+		if uri, ok = p.datatypes[prefix]; !ok {
+			return nil, p.errorf(name, "undeclared datatype prefix %q", prefix)
+		}
+		local = rest
+	}
+
+	if p.peek().kind == tokLiteral {
+		value, err := p.literal()
+		if err != nil {
+			return nil, err
+		}
+		dt, err := newDatatype(uri, local, nil)
+		if err != nil {
+			return nil, p.errorf(name, "%v", err)
+		}
+		if dt.check != nil {
+			if err := dt.check(dt.normalize(value)); err != nil {
+				return nil, p.errorf(name, "value %q is not a valid %s: %v", value, local, err)
+			}
+		}
+		return &Pattern{kind: kindValue, dt: dt, value: value}, nil
+	}
+
+	var params [][2]string
+	if p.accept("{") {
+		for !p.accept("}") {
+			p.skipAnnotations()
+			param := p.next()
+			if param.kind != tokIdent {
+				return nil, p.errorf(param, "expected parameter name, found %s", param)
+			}
+			if err := p.expect("="); err != nil {
+				return nil, err
+			}
+			value, err := p.literal()
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, [2]string{param.text, value})
+		}
+	}
+	dt, err := newDatatype(uri, local, params)
+	if err != nil {
+		return nil, p.errorf(name, "%v", err)
+	}
+	pattern := &Pattern{kind: kindData, dt: dt}
+	if p.accept("-") {
+		except, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		pattern.kind, pattern.p1 = kindDataExcept, except
+	}
+	return pattern, nil
+}
+
+// literal parses a literal, joining parts concatenated with '~'.
+func (p *parser) literal() (string, error) {
+	var b strings.Builder
+	for {
+		t := p.next()
+		if t.kind != tokLiteral {
+			return "", p.errorf(t, "expected a literal, found %s", t)
+		}
+		b.WriteString(t.text)
+		if !p.accept("~") {
+			return b.String(), nil
+		}
+	}
+}
+
+// nameClass parses the name class of an element or attribute pattern.
+func (p *parser) nameClass(attribute bool) (*NameClass, error) {
+	first, err := p.ncPrimary(attribute)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.peek().is("-"):
+		if first.kind != ncAnyName && first.kind != ncNsName {
+			return nil, p.errorf(p.peek(), "only * and prefix:* name classes take an exception")
+		}
+		p.next()
+		except, err := p.ncPrimary(attribute)
+		if err != nil {
+			return nil, err
+		}
+		first.except = except
+		first.display += " - " + except.display
+	case p.peek().is("|"):
+		for p.accept("|") {
+			next, err := p.ncPrimary(attribute)
+			if err != nil {
+				return nil, err
+			}
+			first = &NameClass{kind: ncChoice, c1: first, c2: next, display: first.display + " | " + next.display}
+		}
+	}
+	return first, nil
+}
+
+func (p *parser) ncPrimary(attribute bool) (*NameClass, error) {
+	p.skipAnnotations()
+	t := p.next()
+	switch {
+	case t.is("("):
+		nc, err := p.nameClass(attribute)
+		if err != nil {
+			return nil, err
+		}
+		nc.display = "(" + nc.display + ")"
+		return nc, p.expect(")")
+	case t.is("*"):
+		return &NameClass{kind: ncAnyName, display: "*"}, nil
+	case t.kind == tokNsName:
+		uri, ok := p.namespaces[t.text]
+		if !ok {
+			return nil, p.errorf(t, "undeclared namespace prefix %q", t.text)
+		}
+		return &NameClass{kind: ncNsName, space: uri, display: t.text + ":*"}, nil
+	case t.kind == tokCName:
+		prefix, local, _ := strings.Cut(t.text, ":")
+		uri, ok := p.namespaces[prefix]
+		if !ok {
+			return nil, p.errorf(t, "undeclared namespace prefix %q", prefix)
+		}
+		return &NameClass{kind: ncName, name: QName{Space: uri, Local: local}, display: t.text}, nil
+	case t.kind == tokIdent:
+		// Keywords are names here, as in element text { ... }.
+		space := p.defaultNS
+		if attribute {
+			space = ""
+		}
+		return &NameClass{kind: ncName, name: QName{Space: space, Local: t.text}, display: t.text}, nil
+	}
+	return nil, p.errorf(t, "expected a name, found %s", t)
+}
+
+// skipAnnotations skips bracketed annotations.
+func (p *parser) skipAnnotations() {
+	for p.peek().is("[") {
+		depth := 0
+		for {
+			t := p.next()
+			if t.is("[") {
+				depth++
+			} else if t.is("]") {
+				depth--
+			}
+			if depth == 0 || t.kind == tokEOF {
+				break
+			}
+		}
+	}
+}
+
+// skipFollowAnnotations skips ">> name [ ... ]" annotations.
+func (p *parser) skipFollowAnnotations() {
+	for p.peek().is(">>") {
+		p.next()
+		p.next()
+		p.skipAnnotations()
+	}
+}
+
+// check reports references to undefined patterns and recursion that does
+// not pass through an element.
+func (p *parser) check() error {
+	for d, t := range p.refs {
+		if d.pattern == nil {
+			return p.errorf(t, "reference to undefined pattern %q", d.name)
+		}
+	}
+	for _, d := range p.defines {
+		if d.pattern != nil && reaches(d.pattern, d, make(map[*define]bool)) {
+			return fmt.Errorf("line %d, column %d: recursive reference to %q outside an element", d.line, d.column, d.name)
+		}
+	}
+	return nil
+}
+
+// reaches reports whether p refers to target without passing through an
+// element pattern.
+func reaches(p *Pattern, target *define, seen map[*define]bool) bool {
+	switch p.kind {
+	case kindRef:
+		if p.ref == target {
+			return true
+		}
+		if seen[p.ref] {
+			return false
+		}
+		seen[p.ref] = true
+		return reaches(p.ref.pattern, target, seen)
+	case kindElement:
+		return false
+	}
+	return (p.p1 != nil && reaches(p.p1, target, seen)) || (p.p2 != nil && reaches(p.p2, target, seen))
+}
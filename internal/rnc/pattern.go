@@ -0,0 +1,587 @@
+// Package rnc compiles RELAX NG schemas written in the compact syntax and
+// validates documents against them with the derivative algorithm described
+// in James Clark's "An algorithm for RELAX NG validation". The caller walks
+// the document and feeds each start tag, attribute, text and end tag to a
+// Validator, which keeps no state beyond the current pattern, so that
+// errors can be reported and recovered from wherever they occur.
+package rnc
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// kind identifies the type of a Pattern.
+type kind uint8
+
+const (
+	kindNotAllowed kind = iota
+	kindEmpty
+	kindText
+	kindChoice
+	kindInterleave
+	kindGroup
+	kindOneOrMore
+	kindList
+	kindData
+	kindDataExcept
+	kindValue
+	kindAttribute
+	kindElement
+	kindAfter
+	kindRef
+)
+
+// Pattern is a node of a compiled schema, or a derivative of one computed
+// while validating: what the rest of the document may still hold.
+type Pattern struct {
+	kind   kind
+	p1, p2 *Pattern
+	// nc is the name class of element and attribute patterns.
+	nc *NameClass
+	// dt is the datatype of data and value patterns.
+	dt *datatype
+	// value is the value of a value pattern.
+	value string
+	// ref is the definition a reference pattern refers to.
+	ref *define
+}
+
+// define is a named pattern of a grammar.
+type define struct {
+	name    string
+	pattern *Pattern
+	// combine is '|' or '&' for definitions combined with |= or &=, and
+	// assigned is set once a definition with plain '=' was read.
+	combine  byte
+	assigned bool
+	line     int
+	column   int
+}
+
+var (
+	notAllowed = &Pattern{kind: kindNotAllowed}
+	empty      = &Pattern{kind: kindEmpty}
+	text       = &Pattern{kind: kindText}
+)
+
+// NotAllowed reports whether p matches nothing: the document has failed to
+// match the schema at the point p was derived for.
+func (p *Pattern) NotAllowed() bool {
+	return p.kind == kindNotAllowed
+}
+
+// Nullable reports whether p matches an empty sequence, so that the
+// content it was derived for may end here.
+func (p *Pattern) Nullable() bool {
+	switch p.kind {
+	case kindEmpty, kindText:
+		return true
+	case kindGroup, kindInterleave:
+		return p.p1.Nullable() && p.p2.Nullable()
+	case kindChoice:
+		return p.p1.Nullable() || p.p2.Nullable()
+	case kindOneOrMore:
+		return p.p1.Nullable()
+	case kindRef:
+		return p.ref.pattern.Nullable()
+	}
+	return false
+}
+
+// QName is an expanded name: a namespace URI and a local name.
+type QName struct {
+	Space string
+	Local string
+}
+
+// NameClass is the set of names an element or attribute pattern allows.
+type NameClass struct {
+	kind ncKind
+	// name is the name of ncName name classes.
+	name QName
+	// space is the namespace of ncNsName name classes.
+	space string
+	// display is the name class as written in the schema, for messages.
+	display string
+	c1, c2  *NameClass
+	// except is the exception of ncAnyName and ncNsName name classes.
+	except *NameClass
+}
+
+type ncKind uint8
+
+const (
+	ncName ncKind = iota
+	ncAnyName
+	ncNsName
+	ncChoice
+)
+
+// Contains reports whether the name class allows name.
+func (nc *NameClass) Contains(name QName) bool {
+	switch nc.kind {
+	case ncName:
+		return nc.name == name
+	case ncAnyName:
+		return nc.except == nil || !nc.except.Contains(name)
+	case ncNsName:
+		return nc.space == name.Space && (nc.except == nil || !nc.except.Contains(name))
+	}
+	return nc.c1.Contains(name) || nc.c2.Contains(name)
+}
+
+func (nc *NameClass) String() string {
+	return nc.display
+}
+
+// Validator computes derivatives of the patterns of a Schema. It interns
+// the patterns it builds, so that equal derivatives are shared and do not
+// grow without bound. A Validator is not safe for concurrent use; create
+// one per document.
+type Validator struct {
+	schema *Schema
+	cache  map[patternKey]*Pattern
+}
+
+// patternKey identifies an interned pattern by its kind and operands.
+type patternKey struct {
+	kind   kind
+	p1, p2 *Pattern
+}
+
+// NewValidator returns a Validator for documents checked against s.
+func (s *Schema) NewValidator() *Validator {
+	return &Validator{schema: s, cache: make(map[patternKey]*Pattern)}
+}
+
+// Start returns the pattern the root element must match.
+func (v *Validator) Start() *Pattern {
+	return v.schema.start
+}
+
+func (v *Validator) intern(k kind, p1, p2 *Pattern) *Pattern {
+	key := patternKey{k, p1, p2}
+	if p, ok := v.cache[key]; ok {
+		return p
+	}
+	p := &Pattern{kind: k, p1: p1, p2: p2}
+	v.cache[key] = p
+	return p
+}
+
+func (v *Validator) choice(p1, p2 *Pattern) *Pattern {
+	switch {
+	case p1.kind == kindNotAllowed:
+		return p2
+	case p2.kind == kindNotAllowed, p1 == p2:
+		return p1
+	}
+	return v.intern(kindChoice, p1, p2)
+}
+
+func (v *Validator) group(p1, p2 *Pattern) *Pattern {
+	switch {
+	case p1.kind == kindNotAllowed, p2.kind == kindNotAllowed:
+		return notAllowed
+	case p1.kind == kindEmpty:
+		return p2
+	case p2.kind == kindEmpty:
+		return p1
+	}
+	return v.intern(kindGroup, p1, p2)
+}
+
+func (v *Validator) interleave(p1, p2 *Pattern) *Pattern {
+	switch {
+	case p1.kind == kindNotAllowed, p2.kind == kindNotAllowed:
+		return notAllowed
+	case p1.kind == kindEmpty:
+		return p2
+	case p2.kind == kindEmpty:
+		return p1
+	}
+	return v.intern(kindInterleave, p1, p2)
+}
+
+func (v *Validator) after(p1, p2 *Pattern) *Pattern {
+	if p1.kind == kindNotAllowed || p2.kind == kindNotAllowed {
+		return notAllowed
+	}
+	return v.intern(kindAfter, p1, p2)
+}
+
+func (v *Validator) oneOrMore(p *Pattern) *Pattern {
+	if p.kind == kindNotAllowed {
+		return notAllowed
+	}
+	return v.intern(kindOneOrMore, p, nil)
+}
+
+// applyAfter applies f to the second operand of the After patterns of p.
+func (v *Validator) applyAfter(f func(*Pattern) *Pattern, p *Pattern) *Pattern {
+	switch p.kind {
+	case kindAfter:
+		return v.after(p.p1, f(p.p2))
+	case kindChoice:
+		return v.choice(v.applyAfter(f, p.p1), v.applyAfter(f, p.p2))
+	}
+	return notAllowed
+}
+
+// StartTagOpen returns the derivative of p for the start of an element
+// named name. The result holds After patterns pairing the element's
+// content with what may follow the element.
+func (v *Validator) StartTagOpen(p *Pattern, name QName) *Pattern {
+	switch p.kind {
+	case kindChoice:
+		return v.choice(v.StartTagOpen(p.p1, name), v.StartTagOpen(p.p2, name))
+	case kindElement:
+		if p.nc.Contains(name) {
+			return v.after(p.p1, empty)
+		}
+	case kindInterleave:
+		p1, p2 := p.p1, p.p2
+		return v.choice(
+			v.applyAfter(func(x *Pattern) *Pattern { return v.interleave(x, p2) }, v.StartTagOpen(p1, name)),
+			v.applyAfter(func(x *Pattern) *Pattern { return v.interleave(p1, x) }, v.StartTagOpen(p2, name)))
+	case kindOneOrMore:
+		rest := v.choice(p, empty)
+		return v.applyAfter(func(x *Pattern) *Pattern { return v.group(x, rest) }, v.StartTagOpen(p.p1, name))
+	case kindGroup:
+		p2 := p.p2
+		x := v.applyAfter(func(x *Pattern) *Pattern { return v.group(x, p2) }, v.StartTagOpen(p.p1, name))
+		if p.p1.Nullable() {
+			return v.choice(x, v.StartTagOpen(p2, name))
+		}
+		return x
+	case kindAfter:
+		p2 := p.p2
+		return v.applyAfter(func(x *Pattern) *Pattern { return v.after(x, p2) }, v.StartTagOpen(p.p1, name))
+	case kindRef:
+		return v.StartTagOpen(p.ref.pattern, name)
+	}
+	return notAllowed
+}
+
+// Attribute returns the derivative of p for an attribute.
+func (v *Validator) Attribute(p *Pattern, name QName, value string) *Pattern {
+	switch p.kind {
+	case kindAfter:
+		return v.after(v.Attribute(p.p1, name, value), p.p2)
+	case kindChoice:
+		return v.choice(v.Attribute(p.p1, name, value), v.Attribute(p.p2, name, value))
+	case kindGroup:
+		return v.choice(v.group(v.Attribute(p.p1, name, value), p.p2), v.group(p.p1, v.Attribute(p.p2, name, value)))
+	case kindInterleave:
+		return v.choice(v.interleave(v.Attribute(p.p1, name, value), p.p2), v.interleave(p.p1, v.Attribute(p.p2, name, value)))
+	case kindOneOrMore:
+		return v.group(v.Attribute(p.p1, name, value), v.choice(p, empty))
+	case kindAttribute:
+		if p.nc.Contains(name) && v.valueMatches(p.p1, value) {
+			return empty
+		}
+	case kindRef:
+		return v.Attribute(p.ref.pattern, name, value)
+	}
+	return notAllowed
+}
+
+// valueMatches reports whether the attribute value s matches p.
+func (v *Validator) valueMatches(p *Pattern, s string) bool {
+	return (p.Nullable() && isWhitespace(s)) || v.Text(p, s).Nullable()
+}
+
+// StartTagClose returns the derivative of p for the end of a start tag:
+// attributes not given are missing. With recover, they are treated as
+// optional instead, so that validation can continue after reporting them.
+func (v *Validator) StartTagClose(p *Pattern, recover bool) *Pattern {
+	switch p.kind {
+	case kindAfter:
+		return v.after(v.StartTagClose(p.p1, recover), p.p2)
+	case kindChoice:
+		return v.choice(v.StartTagClose(p.p1, recover), v.StartTagClose(p.p2, recover))
+	case kindGroup:
+		return v.group(v.StartTagClose(p.p1, recover), v.StartTagClose(p.p2, recover))
+	case kindInterleave:
+		return v.interleave(v.StartTagClose(p.p1, recover), v.StartTagClose(p.p2, recover))
+	case kindOneOrMore:
+		return v.oneOrMore(v.StartTagClose(p.p1, recover))
+	case kindAttribute:
+		if recover {
+			return empty
+		}
+		return notAllowed
+	case kindRef:
+		if hasAttributes(p.ref.pattern, nil) {
+			return v.StartTagClose(p.ref.pattern, recover)
+		}
+	}
+	return p
+}
+
+// hasAttributes reports whether p holds attribute patterns outside
+// elements.
+func hasAttributes(p *Pattern, seen map[*define]bool) bool {
+	switch p.kind {
+	case kindAttribute:
+		return true
+	case kindChoice, kindGroup, kindInterleave, kindAfter:
+		return hasAttributes(p.p1, seen) || hasAttributes(p.p2, seen)
+	case kindOneOrMore:
+		return hasAttributes(p.p1, seen)
+	case kindRef:
+		if seen[p.ref] {
+			return false
+		}
+		if seen == nil {
+			seen = make(map[*define]bool)
+		}
+		seen[p.ref] = true
+		return hasAttributes(p.ref.pattern, seen)
+	}
+	return false
+}
+
+// Text returns the derivative of p for the text s.
+func (v *Validator) Text(p *Pattern, s string) *Pattern {
+	switch p.kind {
+	case kindChoice:
+		return v.choice(v.Text(p.p1, s), v.Text(p.p2, s))
+	case kindInterleave:
+		return v.choice(v.interleave(v.Text(p.p1, s), p.p2), v.interleave(p.p1, v.Text(p.p2, s)))
+	case kindGroup:
+		x := v.group(v.Text(p.p1, s), p.p2)
+		if p.p1.Nullable() {
+			return v.choice(x, v.Text(p.p2, s))
+		}
+		return x
+	case kindAfter:
+		return v.after(v.Text(p.p1, s), p.p2)
+	case kindOneOrMore:
+		return v.group(v.Text(p.p1, s), v.choice(p, empty))
+	case kindText:
+		return p
+	case kindValue:
+		if p.dt.equal(p.value, s) {
+			return empty
+		}
+	case kindData:
+		if p.dt.allows(s) == nil {
+			return empty
+		}
+	case kindDataExcept:
+		if p.dt.allows(s) == nil && !v.Text(p.p1, s).Nullable() {
+			return empty
+		}
+	case kindList:
+		x := p.p1
+		for _, tok := range strings.Fields(s) {
+			x = v.Text(x, tok)
+		}
+		if x.Nullable() {
+			return empty
+		}
+	case kindRef:
+		return v.Text(p.ref.pattern, s)
+	}
+	return notAllowed
+}
+
+// Whitespace returns the derivative of p for the text s that holds only
+// whitespace, or for no text at all if s is "". Such text may be ignored,
+// unless it is the value of a data pattern.
+func (v *Validator) Whitespace(p *Pattern, s string) *Pattern {
+	return v.choice(p, v.Text(p, s))
+}
+
+// EndTag returns the derivative of p for an end tag. With recover,
+// content that is incomplete is accepted, so that validation can continue
+// after reporting it.
+func (v *Validator) EndTag(p *Pattern, recover bool) *Pattern {
+	switch p.kind {
+	case kindChoice:
+		return v.choice(v.EndTag(p.p1, recover), v.EndTag(p.p2, recover))
+	case kindAfter:
+		if recover || p.p1.Nullable() {
+			return p.p2
+		}
+	}
+	return notAllowed
+}
+
+// AllowsAttribute reports whether p allows an attribute named name, with
+// some value.
+func AllowsAttribute(p *Pattern, name QName) bool {
+	return allowsAttribute(p, name, make(map[*define]bool))
+}
+
+func allowsAttribute(p *Pattern, name QName, seen map[*define]bool) bool {
+	switch p.kind {
+	case kindAttribute:
+		return p.nc.Contains(name)
+	case kindChoice, kindGroup, kindInterleave:
+		return allowsAttribute(p.p1, name, seen) || allowsAttribute(p.p2, name, seen)
+	case kindOneOrMore, kindAfter:
+		return allowsAttribute(p.p1, name, seen)
+	case kindRef:
+		if !seen[p.ref] {
+			seen[p.ref] = true
+			return allowsAttribute(p.ref.pattern, name, seen)
+		}
+	}
+	return false
+}
+
+// AttributeValue returns the content pattern of the attributes named name
+// that p allows, for explaining an invalid value with Explain.
+func AttributeValue(p *Pattern, name QName) *Pattern {
+	return attributeValue(p, name, make(map[*define]bool))
+}
+
+func attributeValue(p *Pattern, name QName, seen map[*define]bool) *Pattern {
+	switch p.kind {
+	case kindAttribute:
+		if p.nc.Contains(name) {
+			return p.p1
+		}
+	case kindChoice, kindGroup, kindInterleave:
+		if a := attributeValue(p.p1, name, seen); a != nil {
+			return a
+		}
+		return attributeValue(p.p2, name, seen)
+	case kindOneOrMore, kindAfter:
+		return attributeValue(p.p1, name, seen)
+	case kindRef:
+		if !seen[p.ref] {
+			seen[p.ref] = true
+			return attributeValue(p.ref.pattern, name, seen)
+		}
+	}
+	return nil
+}
+
+// Explain describes why the text s does not match the data and value
+// patterns p allows next, or returns "" if p allows no such pattern.
+func Explain(p *Pattern, s string) string {
+	var values []string
+	var dataErr error
+	var walk func(*Pattern, map[*define]bool)
+	walk = func(p *Pattern, seen map[*define]bool) {
+		switch p.kind {
+		case kindChoice, kindInterleave:
+			walk(p.p1, seen)
+			walk(p.p2, seen)
+		case kindGroup:
+			walk(p.p1, seen)
+			if p.p1.Nullable() {
+				walk(p.p2, seen)
+			}
+		case kindOneOrMore, kindAfter:
+			walk(p.p1, seen)
+		case kindValue:
+			values = append(values, strconv.Quote(p.value))
+		case kindData, kindDataExcept:
+			if err := p.dt.allows(s); err != nil && dataErr == nil {
+				dataErr = fmt.Errorf("%s: %v", p.dt.name, err)
+			} else if err == nil && dataErr == nil {
+				dataErr = fmt.Errorf("%s: value is excluded", p.dt.name)
+			}
+		case kindRef:
+			if !seen[p.ref] {
+				seen[p.ref] = true
+				walk(p.ref.pattern, seen)
+			}
+		}
+	}
+	walk(p, make(map[*define]bool))
+	switch {
+	case dataErr != nil:
+		return dataErr.Error()
+	case len(values) > 0:
+		return "expected " + strings.Join(values, " or ")
+	}
+	return ""
+}
+
+// Expected describes what p allows next: the names of the elements it may
+// start with, and "text" if it allows character data. The result is
+// sorted, and within After patterns only the content side is considered.
+func Expected(p *Pattern) []string {
+	set := make(map[string]bool)
+	expected(p, set, make(map[*define]bool))
+	list := make([]string, 0, len(set))
+	for s := range set {
+		list = append(list, s)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func expected(p *Pattern, set map[string]bool, seen map[*define]bool) {
+	switch p.kind {
+	case kindChoice, kindInterleave:
+		expected(p.p1, set, seen)
+		expected(p.p2, set, seen)
+	case kindGroup:
+		expected(p.p1, set, seen)
+		if p.p1.Nullable() {
+			expected(p.p2, set, seen)
+		}
+	case kindOneOrMore, kindAfter:
+		expected(p.p1, set, seen)
+	case kindElement:
+		set["element "+p.nc.String()] = true
+	case kindText, kindData, kindDataExcept, kindValue, kindList:
+		set["text"] = true
+	case kindRef:
+		if !seen[p.ref] {
+			seen[p.ref] = true
+			expected(p.ref.pattern, set, seen)
+		}
+	}
+}
+
+// RequiredAttributes returns the names of the attributes p requires in
+// every alternative, for reporting missing attributes.
+func RequiredAttributes(p *Pattern) []string {
+	list := requiredAttributes(p, make(map[*define]bool))
+	sort.Strings(list)
+	return list
+}
+
+func requiredAttributes(p *Pattern, seen map[*define]bool) []string {
+	switch p.kind {
+	case kindAttribute:
+		return []string{p.nc.String()}
+	case kindGroup, kindInterleave:
+		return append(requiredAttributes(p.p1, seen), requiredAttributes(p.p2, seen)...)
+	case kindOneOrMore, kindAfter:
+		return requiredAttributes(p.p1, seen)
+	case kindChoice:
+		a, b := requiredAttributes(p.p1, seen), requiredAttributes(p.p2, seen)
+		var both []string
+		for _, name := range a {
+			for _, other := range b {
+				if name == other {
+					both = append(both, name)
+					break
+				}
+			}
+		}
+		return both
+	case kindRef:
+		if !seen[p.ref] {
+			seen[p.ref] = true
+			return requiredAttributes(p.ref.pattern, seen)
+		}
+	}
+	return nil
+}
+
+// isWhitespace reports whether s holds only XML whitespace.
+func isWhitespace(s string) bool {
+	return strings.Trim(s, " \t\r\n") == ""
+}
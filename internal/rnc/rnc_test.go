@@ -0,0 +1,252 @@
+package rnc
+
+import (
+	"strings"
+	"testing"
+)
+
+// event is a step of a document: a start tag with attributes, text, or an
+// end tag.
+type event struct {
+	start string
+	attrs [][2]string
+	text  string
+	end   bool
+}
+
+func open(name string, attrs ...string) event {
+	e := event{start: name}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		e.attrs = append(e.attrs, [2]string{attrs[i], attrs[i+1]})
+	}
+	return e
+}
+
+func chars(s string) event { return event{text: s} }
+
+var end = event{end: true}
+
+// valid runs events through the schema and reports whether the document
+// is valid.
+func valid(t *testing.T, s *Schema, events ...event) bool {
+	t.Helper()
+	v := s.NewValidator()
+	p := v.Start()
+	for _, e := range events {
+		switch {
+		case e.start != "":
+			p = v.StartTagOpen(p, QName{Local: e.start})
+			for _, a := range e.attrs {
+				p = v.Attribute(p, QName{Local: a[0]}, a[1])
+			}
+			p = v.StartTagClose(p, false)
+		case e.end:
+			p = v.EndTag(p, false)
+		default:
+			p = v.Text(p, e.text)
+		}
+		if p.NotAllowed() {
+			return false
+		}
+	}
+	return p.Nullable()
+}
+
+func mustParse(t *testing.T, src string) *Schema {
+	t.Helper()
+	s, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return s
+}
+
+func TestPatterns(t *testing.T) {
+	s := mustParse(t, `
+# A comment.
+default namespace = ""
+start = book
+book = element book {
+    attribute id { xsd:NCName },
+    attribute lang { "en" | "de" }?,
+    element title { text },
+    author+,
+    (element isbn { xsd:string { pattern = "[0-9]{13}" } } | element draft { empty }),
+    element pages { xsd:positiveInteger }?,
+    element tags { list { xsd:token* } }?
+}
+author = element author { mixed { element em { text }* } }
+`)
+	ok := []event{
+		open("book", "id", "b1", "lang", "de"),
+		open("title"), chars("Go"), end,
+		open("author"), chars("A "), open("em"), chars("B"), end, end,
+		open("author"), end,
+		open("isbn"), chars("9780000000000"), end,
+		open("pages"), chars(" 12 "), end,
+		open("tags"), chars("a b c"), end,
+		end,
+	}
+	if !valid(t, s, ok...) {
+		t.Error("valid document rejected")
+	}
+
+	tests := []struct {
+		name   string
+		events []event
+	}{
+		{"missing attribute", []event{open("book"), open("title"), end, open("author"), end, open("draft"), end, end}},
+		{"bad value", []event{open("book", "id", "b1", "lang", "fr"), open("title"), end, open("author"), end, open("draft"), end, end}},
+		{"bad datatype", []event{open("book", "id", "1b"), open("title"), end, open("author"), end, open("draft"), end, end}},
+		{"missing author", []event{open("book", "id", "b1"), open("title"), end, open("draft"), end, end}},
+		{"order", []event{open("book", "id", "b1"), open("author"), end, open("title"), end, open("draft"), end, end}},
+		{"pattern facet", []event{open("book", "id", "b1"), open("title"), end, open("author"), end, open("isbn"), chars("123"), end, end}},
+		{"empty", []event{open("book", "id", "b1"), open("title"), end, open("author"), end, open("draft"), chars("x"), end, end}},
+		{"integer", []event{open("book", "id", "b1"), open("title"), end, open("author"), end, open("draft"), end, open("pages"), chars("0"), end, end}},
+		{"incomplete", []event{open("book", "id", "b1"), open("title"), end}},
+	}
+	for _, tt := range tests {
+		if valid(t, s, tt.events...) {
+			t.Errorf("%s: invalid document accepted", tt.name)
+		}
+	}
+}
+
+func TestInterleaveAndCombine(t *testing.T) {
+	s := mustParse(t, `
+start = element r { a & b* }
+a = element a { empty }
+b = element b { empty }
+a |= element c { empty }
+`)
+	for _, events := range [][]event{
+		{open("r"), open("b"), end, open("a"), end, open("b"), end, end},
+		{open("r"), open("c"), end, end},
+	} {
+		if !valid(t, s, events...) {
+			t.Errorf("valid document %v rejected", events)
+		}
+	}
+	if valid(t, s, open("r"), open("b"), end, end) {
+		t.Error("document without a accepted")
+	}
+	if valid(t, s, open("r"), open("a"), end, open("c"), end, end) {
+		t.Error("document with a and c accepted")
+	}
+}
+
+func TestNameClasses(t *testing.T) {
+	s := mustParse(t, `
+namespace x = "urn:x"
+element * - x:* { attribute * { text }*, element x:* { empty }* }
+`)
+	v := s.NewValidator()
+	p := v.StartTagOpen(v.Start(), QName{Space: "urn:x", Local: "r"})
+	if !p.NotAllowed() {
+		t.Error("root in the excluded namespace accepted")
+	}
+	p = v.StartTagOpen(v.Start(), QName{Local: "r"})
+	p = v.Attribute(p, QName{Local: "any"}, "1")
+	p = v.StartTagClose(p, false)
+	p = v.StartTagOpen(p, QName{Space: "urn:x", Local: "c"})
+	p = v.EndTag(v.StartTagClose(p, false), false)
+	p = v.EndTag(p, false)
+	if !p.Nullable() {
+		t.Error("valid document rejected")
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	s := mustParse(t, `element r { attribute id { text }, element a { text } }`)
+	v := s.NewValidator()
+	p := v.StartTagOpen(v.Start(), QName{Local: "r"})
+	if got := RequiredAttributes(p); len(got) != 1 || got[0] != "id" {
+		t.Errorf("RequiredAttributes = %v", got)
+	}
+	if !v.StartTagClose(p, false).NotAllowed() {
+		t.Fatal("missing attribute accepted")
+	}
+	p = v.StartTagClose(p, true)
+	if got := strings.Join(Expected(p), ", "); got != "element a" {
+		t.Errorf("Expected = %q", got)
+	}
+	if !v.EndTag(p, false).NotAllowed() {
+		t.Fatal("incomplete content accepted")
+	}
+	if !v.EndTag(p, true).Nullable() {
+		t.Error("EndTag with recover did not close the element")
+	}
+}
+
+func TestExplain(t *testing.T) {
+	s := mustParse(t, `element r { xsd:int { minInclusive = "1" } | "none" }`)
+	v := s.NewValidator()
+	p := v.StartTagClose(v.StartTagOpen(v.Start(), QName{Local: "r"}), false)
+	if got := Explain(p, "0"); !strings.Contains(got, "int") {
+		t.Errorf("Explain(0) = %q", got)
+	}
+	s = mustParse(t, `element r { "a" | "b" }`)
+	v = s.NewValidator()
+	p = v.StartTagClose(v.StartTagOpen(v.Start(), QName{Local: "r"}), false)
+	if got := Explain(p, "c"); got != `expected "a" or "b"` {
+		t.Errorf("Explain(c) = %q", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`start = foo`, `"foo"`},
+		{`element r { text`, "line 1"},
+		{`include "other.rnc"`, "include"},
+		{`start = a a = a`, "recurs"},
+		{`element r { xsd:int { bogus = "1" } }`, "bogus"},
+		{`element r { x:a }`, "x"},
+		{"element r {\n  text, \"unterminated }", "line 2"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		if err == nil {
+			t.Errorf("Parse(%q): expected an error", tt.src)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) = %v, want it to mention %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestDatatypes(t *testing.T) {
+	tests := []struct {
+		typ     string
+		valid   []string
+		invalid []string
+	}{
+		{"xsd:boolean", []string{"true", " 0 "}, []string{"yes"}},
+		{"xsd:decimal", []string{"-1.50", "+.5"}, []string{"1e3", "."}},
+		{"xsd:double", []string{"1e3", "INF", "NaN"}, []string{"inf", "1e"}},
+		{"xsd:byte", []string{"127", "-128"}, []string{"128"}},
+		{"xsd:unsignedInt", []string{"4294967295"}, []string{"-1"}},
+		{"xsd:date", []string{"2024-02-29"}, []string{"2023-02-29"}},
+		{"xsd:anyURI", []string{"http://example.com/a b"}, []string{}},
+		{"xsd:NCName", []string{"a-b"}, []string{"a:b", "1a"}},
+		{"xsd:QName", []string{"a:b"}, []string{":b"}},
+		{`xsd:string { maxLength = "3" }`, []string{"abc"}, []string{"abcd"}},
+		{`xsd:decimal { totalDigits = "3" fractionDigits = "1" }`, []string{"12.5"}, []string{"1.25", "1234"}},
+	}
+	for _, tt := range tests {
+		s := mustParse(t, "element r { "+tt.typ+" }")
+		for _, value := range tt.valid {
+			if !valid(t, s, open("r"), chars(value), end) {
+				t.Errorf("%s rejected %q", tt.typ, value)
+			}
+		}
+		for _, value := range tt.invalid {
+			if valid(t, s, open("r"), chars(value), end) {
+				t.Errorf("%s accepted %q", tt.typ, value)
+			}
+		}
+	}
+}
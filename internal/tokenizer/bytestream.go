@@ -0,0 +1,193 @@
+package tokenizer
+
+import (
+	"unicode/utf8"
+
+	"github.com/shapestone/shape-core/pkg/tokenizer"
+)
+
+// byteStream is a tokenizer.ByteStream reading a byte slice in place.
+// Unlike tokenizer.NewStream it neither copies the input nor decodes it into
+// runes up front: runes are decoded as they are read, and the cursor of a
+// Location is a byte offset, so positions count bytes rather than runes.
+type byteStream struct {
+	// origin identifies the stream a clone was made from, see Match.
+	origin   *byteStream
+	data     []byte
+	location tokenizer.Location
+}
+
+// NewByteStream returns a stream over data, which must be valid UTF-8 and
+// must not be modified while the stream is in use.
+func NewByteStream(data []byte) tokenizer.ByteStream {
+	s := &byteStream{data: data, location: tokenizer.Location{Row: 1, Column: 1}}
+	s.origin = s
+	return s
+}
+
+// Clone returns a copy of the stream at the same position.
+func (s *byteStream) Clone() tokenizer.Stream {
+	c := *s
+	return &c
+}
+
+// Match moves the stream to the position of other, a clone of it.
+func (s *byteStream) Match(other tokenizer.Stream) {
+	o, ok := other.(*byteStream)
+	if !ok || o.origin != s.origin {
+		panic("trying to match two different streams")
+	}
+	s.location = o.location
+}
+
+func (s *byteStream) PeekChar() (rune, bool) {
+	if s.IsEos() {
+		return 0, false
+	}
+	if b := s.data[s.location.Cursor]; b < utf8.RuneSelf {
+		return rune(b), true
+	}
+	r, _ := utf8.DecodeRune(s.data[s.location.Cursor:])
+	return r, true
+}
+
+func (s *byteStream) NextChar() (rune, bool) {
+	if s.IsEos() {
+		return 0, false
+	}
+	r, size := rune(s.data[s.location.Cursor]), 1
+	if r >= utf8.RuneSelf {
+		r, size = utf8.DecodeRune(s.data[s.location.Cursor:])
+	}
+	s.advance(r == '\n', size)
+	return r, true
+}
+
+// advance moves the cursor over size bytes of one character, newline if
+// it is a line feed.
+func (s *byteStream) advance(newline bool, size int) {
+	s.location.Cursor += size
+	if newline {
+		s.location.Row++
+		s.location.Column = 1
+	} else {
+		s.location.Column++
+	}
+}
+
+func (s *byteStream) MatchChars(match []rune) bool {
+	saved := s.location
+	for _, mr := range match {
+		r, ok := s.NextChar()
+		if !ok || r != mr {
+			s.location = saved
+			return false
+		}
+	}
+	return true
+}
+
+func (s *byteStream) IsEos() bool {
+	return s.location.Cursor >= len(s.data)
+}
+
+// GetOffset returns the byte offset of the stream.
+func (s *byteStream) GetOffset() int {
+	return s.location.Cursor
+}
+
+func (s *byteStream) GetRow() int {
+	return s.location.Row
+}
+
+func (s *byteStream) GetColumn() int {
+	return s.location.Column
+}
+
+func (s *byteStream) Reset() {
+	s.location = tokenizer.Location{Row: 1, Column: 1}
+}
+
+func (s *byteStream) GetLocation() tokenizer.Location {
+	return s.location
+}
+
+func (s *byteStream) SetLocation(loc tokenizer.Location) {
+	s.location = loc
+}
+
+func (s *byteStream) PeekByte() (byte, bool) {
+	if s.IsEos() {
+		return 0, false
+	}
+	return s.data[s.location.Cursor], true
+}
+
+func (s *byteStream) NextByte() (byte, bool) {
+	if s.IsEos() {
+		return 0, false
+	}
+	b := s.data[s.location.Cursor]
+	s.advance(b == '\n', 1)
+	return b, true
+}
+
+func (s *byteStream) PeekBytes(n int) []byte {
+	rest := s.RemainingBytes()
+	if n > len(rest) {
+		n = len(rest)
+	}
+	return rest[:n]
+}
+
+func (s *byteStream) SkipWhitespace() {
+	for !s.IsEos() {
+		b := s.data[s.location.Cursor]
+		if b != ' ' && b != '\t' && b != '\n' && b != '\r' {
+			return
+		}
+		s.advance(b == '\n', 1)
+	}
+}
+
+// SkipUntil advances to the next delim, or to the end of the stream, and
+// returns the number of bytes skipped. Like the streams of the tokenizer
+// package it does not count lines.
+func (s *byteStream) SkipUntil(delim byte) int {
+	n := s.FindByte(delim)
+	if n < 0 {
+		n = len(s.data) - s.location.Cursor
+	}
+	s.location.Cursor += n
+	return n
+}
+
+func (s *byteStream) FindByte(b byte) int {
+	return tokenizer.FindByte(s.RemainingBytes(), b)
+}
+
+func (s *byteStream) FindAny(chars []byte) int {
+	for i, b := range s.RemainingBytes() {
+		for _, c := range chars {
+			if b == c {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func (s *byteStream) SliceFrom(start int) []byte {
+	if start < 0 || start > s.location.Cursor {
+		return nil
+	}
+	return s.data[start:s.location.Cursor]
+}
+
+func (s *byteStream) BytePosition() int {
+	return s.location.Cursor
+}
+
+func (s *byteStream) RemainingBytes() []byte {
+	return s.data[s.location.Cursor:]
+}
@@ -0,0 +1,80 @@
+package tokenizer
+
+import (
+	"testing"
+
+	"github.com/shapestone/shape-core/pkg/tokenizer"
+)
+
+func TestByteStream_TokensMatchNewStream(t *testing.T) {
+	inputs := []string{
+		`<root/>`,
+		`<?xml version="1.0"?><a x="1" y='2'>text &amp; more<b/></a>`,
+		"<a>\n  <b>héllo wörld</b>\n  <c attr=\"日本語\">✓</c>\n</a>",
+		`<a><![CDATA[<raw> ]] ]]></a><!-- c -- x --><a/>`,
+		`<!DOCTYPE a [<!ENTITY e "v">]><a>&e;</a>`,
+	}
+	for _, input := range inputs {
+		want := tokenize(tokenizer.NewStream(input))
+		got := tokenize(NewByteStream([]byte(input)))
+		if len(got) != len(want) {
+			t.Fatalf("%q: got %d tokens, want %d", input, len(got), len(want))
+		}
+		for i := range want {
+			if got[i].Kind() != want[i].Kind() || got[i].ValueString() != want[i].ValueString() {
+				t.Errorf("%q: token %d = %s %q, want %s %q", input, i,
+					got[i].Kind(), got[i].ValueString(), want[i].Kind(), want[i].ValueString())
+			}
+			if got[i].Row() != want[i].Row() {
+				t.Errorf("%q: token %d on row %d, want %d", input, i, got[i].Row(), want[i].Row())
+			}
+		}
+	}
+}
+
+func tokenize(stream tokenizer.Stream) []*tokenizer.Token {
+	tok := NewTokenizerWithStream(stream)
+	var tokens []*tokenizer.Token
+	for {
+		token, ok := tok.NextToken()
+		if !ok {
+			return tokens
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+func TestByteStream_OffsetsCountBytes(t *testing.T) {
+	s := NewByteStream([]byte("é\nb"))
+	if r, _ := s.NextChar(); r != 'é' {
+		t.Fatalf("NextChar = %q, want 'é'", r)
+	}
+	if s.GetOffset() != 2 || s.GetColumn() != 2 {
+		t.Errorf("offset %d column %d, want 2 and 2", s.GetOffset(), s.GetColumn())
+	}
+	loc := s.GetLocation()
+	s.NextChar()
+	if s.GetRow() != 2 || s.GetColumn() != 1 {
+		t.Errorf("after newline: row %d column %d, want 2 and 1", s.GetRow(), s.GetColumn())
+	}
+	s.SetLocation(loc)
+	if r, _ := s.PeekChar(); r != '\n' {
+		t.Errorf("PeekChar after SetLocation = %q, want '\\n'", r)
+	}
+
+	clone := s.Clone()
+	clone.NextChar()
+	s.Match(clone)
+	if b, _ := s.PeekByte(); b != 'b' {
+		t.Errorf("PeekByte after Match = %q, want 'b'", b)
+	}
+}
+
+func TestByteStream_MatchOtherStreamPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Match of an unrelated stream did not panic")
+		}
+	}()
+	NewByteStream([]byte("a")).Match(NewByteStream([]byte("a")))
+}
@@ -73,19 +73,19 @@ func TestStringMatcherRune(t *testing.T) {
 		want   string
 	}{
 		{
-			name:   "double quoted with escape",
+			name:   "backslash before double quote",
 			input:  `"hello\"world"`,
 			wantOk: true,
-			want:   `"hello\"world"`,
+			want:   `"hello\"`,
 		},
 		{
-			name:   "single quoted with escape",
+			name:   "backslash before single quote",
 			input:  `'hello\'world'`,
 			wantOk: true,
-			want:   `'hello\'world'`,
+			want:   `'hello\'`,
 		},
 		{
-			name:   "backslash escape",
+			name:   "backslashes",
 			input:  `"path\\to\\file"`,
 			wantOk: true,
 			want:   `"path\\to\\file"`,
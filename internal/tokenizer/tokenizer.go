@@ -1,7 +1,11 @@
 package tokenizer
 
 import (
+	"unicode/utf8"
+
 	"github.com/shapestone/shape-core/pkg/tokenizer"
+
+	"github.com/shapestone/shape-xml/internal/dtd"
 )
 
 // NewTokenizer creates a tokenizer for XML format.
@@ -13,12 +17,23 @@ import (
 // 3. Inside CDATA: look for ]]>
 // 4. Inside comments: look for -->
 func NewTokenizer() tokenizer.Tokenizer {
-	return tokenizer.NewTokenizer(
+	cdata := newCDataMatchers()
+	return tokenizer.NewTokenizerWithoutWhitespace(
+		// CDATA content and terminator (must be first, before whitespace:
+		// inside a CDATA section every character up to ]]> is content)
+		cdata.content,
+		cdata.end,
+
+		tokenizer.WhiteSpaceMatcher,
+
 		// Comments (must be before < to avoid conflict)
 		CommentMatcher(),
 
+		// Document type declarations
+		DoctypeMatcher(),
+
 		// CDATA sections
-		CDataMatcher(),
+		cdata.start,
 
 		// Processing instructions and XML declaration
 		PIAndXMLDeclMatcher(),
@@ -55,7 +70,8 @@ func NewTokenizerWithStream(stream tokenizer.Stream) tokenizer.Tokenizer {
 }
 
 // CommentMatcher creates a matcher for XML comments.
-// Matches: <!-- ... -->
+// Matches: <!-- ... --> as a single TokenCommentStart token holding the
+// whole comment, so the comment text is never tokenized as markup.
 func CommentMatcher() tokenizer.Matcher {
 	return func(stream tokenizer.Stream) *tokenizer.Token {
 		// Check for <!--
@@ -63,6 +79,7 @@ func CommentMatcher() tokenizer.Matcher {
 			return nil
 		}
 
+		value := []rune("<!--")
 		// Find -->
 		for {
 			r, ok := stream.PeekChar()
@@ -75,28 +92,115 @@ func CommentMatcher() tokenizer.Matcher {
 				savedLoc := stream.GetLocation()
 				if matchString(stream, "-->") {
 					// Return comment token
-					return tokenizer.NewToken(TokenCommentStart, []rune("<!--"))
+					return tokenizer.NewToken(TokenCommentStart, append(value, '-', '-', '>'))
 				}
 				// Reset and continue
 				stream.SetLocation(savedLoc)
 			}
 
 			stream.NextChar()
+			value = append(value, r)
 		}
 	}
 }
 
-// CDataMatcher creates a matcher for CDATA sections.
-// Matches: <![CDATA[ ... ]]>
-func CDataMatcher() tokenizer.Matcher {
+// DoctypeMatcher creates a matcher for a document type declaration.
+// Matches: <!DOCTYPE ... > as a single token, including an internal subset
+// in [...]. The end of the declaration is found by dtd.Parse, as in the
+// fast parser, so '>', quotes and brackets in literals, comments and
+// processing instructions do not end it early. A malformed declaration
+// ends at the '>' where dtd.Parse fails, for the parser to report.
+func DoctypeMatcher() tokenizer.Matcher {
 	return func(stream tokenizer.Stream) *tokenizer.Token {
-		if !matchString(stream, "<![CDATA[") {
+		savedLoc := stream.GetLocation()
+		if !matchString(stream, "<!DOCTYPE") {
 			return nil
 		}
 
-		// Return CDATA start token
+		value := []rune("<!DOCTYPE")
+		src := []byte("<!DOCTYPE")
+		for {
+			r, ok := stream.NextChar()
+			if !ok {
+				stream.SetLocation(savedLoc)
+				return nil // Unterminated declaration
+			}
+			value = append(value, r)
+			src = utf8.AppendRune(src, r)
+			if r != '>' {
+				continue
+			}
+			if _, n, err := dtd.Parse(src); err == nil || n < len(src) {
+				return tokenizer.NewToken(TokenDoctype, value)
+			}
+		}
+	}
+}
+
+// CDataMatcher creates a matcher for the start of a CDATA section.
+// Matches: <![CDATA[
+//
+// The content and terminator of the section are tokenized by matchers that
+// share state with the start matcher, see NewTokenizer.
+func CDataMatcher() tokenizer.Matcher {
+	return newCDataMatchers().start
+}
+
+// cdataMatchers tokenize a CDATA section as TokenCDataStart, an optional
+// TokenCDataContent and TokenCDataEnd. The content cannot be recognized by
+// itself, so the three matchers share state recording the position within
+// the section.
+type cdataMatchers struct {
+	start, content, end tokenizer.Matcher
+
+	// inside is set after <![CDATA[ until the content has been read.
+	inside bool
+	// closing is set after the content until ]]> has been read.
+	closing bool
+}
+
+func newCDataMatchers() *cdataMatchers {
+	c := &cdataMatchers{}
+	c.start = func(stream tokenizer.Stream) *tokenizer.Token {
+		if !matchString(stream, "<![CDATA[") {
+			return nil
+		}
+		c.inside = true
 		return tokenizer.NewToken(TokenCDataStart, []rune("<![CDATA["))
 	}
+	c.content = func(stream tokenizer.Stream) *tokenizer.Token {
+		if !c.inside {
+			return nil
+		}
+		c.inside = false
+
+		var value []rune
+		for {
+			savedLoc := stream.GetLocation()
+			if matchString(stream, "]]>") {
+				stream.SetLocation(savedLoc)
+				c.closing = true
+				break
+			}
+			r, ok := stream.NextChar()
+			if !ok {
+				break // Unterminated section, reported by the parser
+			}
+			value = append(value, r)
+		}
+		if len(value) == 0 {
+			return nil
+		}
+		return tokenizer.NewToken(TokenCDataContent, value)
+	}
+	c.end = func(stream tokenizer.Stream) *tokenizer.Token {
+		if !c.closing || !matchString(stream, "]]>") {
+			return nil
+		}
+		c.closing = false
+		return tokenizer.NewToken(TokenCDataEnd, []rune("]]>"))
+	}
+	return c
 }
 
 // PIAndXMLDeclMatcher creates a matcher for processing instructions and XML declarations.
@@ -186,8 +290,9 @@ func stringMatcherByte(stream tokenizer.ByteStream) *tokenizer.Token {
 	remaining := stream.RemainingBytes()
 	offset := tokenizer.FindByte(remaining, quote)
 
-	if offset == -1 {
-		// No closing quote found
+	if offset == -1 || tokenizer.FindByte(remaining[:offset], '<') != -1 {
+		// No closing quote found, or one after markup: attribute values
+		// cannot hold '<', so this quote is part of text content
 		return nil
 	}
 
@@ -230,20 +335,14 @@ func stringMatcherRune(stream tokenizer.Stream) *tokenizer.Token {
 			return nil // Unterminated string
 		}
 
+		if r == '<' {
+			return nil // Part of text content
+		}
 		value = append(value, r)
 
 		if r == quote {
 			return tokenizer.NewToken(TokenString, value)
 		}
-
-		// Handle escape sequences if needed
-		if r == '\\' {
-			escaped, ok := stream.NextChar()
-			if !ok {
-				return nil
-			}
-			value = append(value, escaped)
-		}
 	}
 }
 
@@ -1,6 +1,7 @@
 package tokenizer
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/shapestone/shape-core/pkg/tokenizer"
@@ -30,7 +31,7 @@ func TestCommentMatcher(t *testing.T) {
 			name:    "valid comment",
 			input:   "<!-- comment -->",
 			wantOk:  true,
-			wantLen: 4, // "<!--"
+			wantLen: 16, // the whole comment
 		},
 		{
 			name:   "not a comment",
@@ -51,13 +52,13 @@ func TestCommentMatcher(t *testing.T) {
 			name:    "comment with dashes",
 			input:   "<!-- some-text -->",
 			wantOk:  true,
-			wantLen: 4,
+			wantLen: 18,
 		},
 		{
 			name:    "empty comment",
 			input:   "<!---->",
 			wantOk:  true,
-			wantLen: 4,
+			wantLen: 7,
 		},
 	}
 
@@ -326,6 +327,11 @@ func TestStringMatcher(t *testing.T) {
 			input:  `"hello`,
 			wantOk: false,
 		},
+		{
+			name:   "quote in text before markup",
+			input:  `'s <b/> 'q'`,
+			wantOk: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -737,3 +743,55 @@ func TestTokenizerIntegration(t *testing.T) {
 		})
 	}
 }
+
+func TestNewTokenizer_CDATA(t *testing.T) {
+	tok := NewTokenizerWithStream(tokenizer.NewStream(`<a><![CDATA[<b> ]] >]]></a>`))
+	var kinds, values []string
+	for {
+		token, ok := tok.NextToken()
+		if !ok {
+			break
+		}
+		kinds = append(kinds, token.Kind())
+		values = append(values, token.ValueString())
+	}
+	wantKinds := []string{TokenTagOpen, TokenName, TokenTagClose, TokenCDataStart, TokenCDataContent, TokenCDataEnd, TokenEndTagOpen, TokenName, TokenTagClose}
+	if strings.Join(kinds, " ") != strings.Join(wantKinds, " ") {
+		t.Fatalf("kinds = %v, want %v", kinds, wantKinds)
+	}
+	if values[4] != "<b> ]] >" {
+		t.Errorf("content = %q", values[4])
+	}
+}
+
+func TestNewTokenizer_Doctype(t *testing.T) {
+	decl := `<!DOCTYPE a [<!ENTITY x "a>b">]>`
+	tok := NewTokenizerWithStream(tokenizer.NewStream(decl + `<a/>`))
+	token, ok := tok.NextToken()
+	if !ok || token.Kind() != TokenDoctype || token.ValueString() != decl {
+		t.Fatalf("got %v, want a %s token for %q", token, TokenDoctype, decl)
+	}
+	token, ok = tok.NextToken()
+	if !ok || token.Kind() != TokenTagOpen {
+		t.Errorf("got %v after the declaration, want %s", token, TokenTagOpen)
+	}
+
+	// Markup in the internal subset does not end the declaration.
+	for _, decl := range []string{
+		`<!DOCTYPE a [<!-- it's -->]>`,
+		`<!DOCTYPE a [<!-- ] > -->]>`,
+		`<!DOCTYPE a [<?pi '?>]>`,
+		`<!DOCTYPE a SYSTEM "x[y">`,
+	} {
+		tok = NewTokenizerWithStream(tokenizer.NewStream(decl + `<a/>`))
+		if token, ok := tok.NextToken(); !ok || token.Kind() != TokenDoctype || token.ValueString() != decl {
+			t.Errorf("got %v, want a %s token for %q", token, TokenDoctype, decl)
+		}
+	}
+
+	// An unterminated declaration is not matched.
+	tok = NewTokenizerWithStream(tokenizer.NewStream(`<!DOCTYPE a [`))
+	if token, ok := tok.NextToken(); ok && token.Kind() == TokenDoctype {
+		t.Errorf("unterminated declaration matched as %q", token.ValueString())
+	}
+}
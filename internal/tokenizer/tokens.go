@@ -27,10 +27,13 @@ const (
 	TokenPIEnd         = "PIEnd"         // ?>
 
 	// Comments
-	TokenCommentStart  = "CommentStart"  // <!--
+	TokenCommentStart  = "CommentStart"  // <!-- ... -->, the whole comment
 	TokenCommentEnd    = "CommentEnd"    // -->
 	TokenCommentContent = "CommentContent" // Comment text
 
+	// Document type declaration
+	TokenDoctype       = "Doctype"       // <!DOCTYPE ...>, including any internal subset
+
 	// Special token
 	TokenEOF           = "EOF"           // End of file
 )
@@ -0,0 +1,79 @@
+// Package xmlerr defines the syntax error returned by the AST parser and
+// the fast parser, locating the error in the source document.
+package xmlerr
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// snippetContext is the number of bytes a snippet shows on either side of
+// the error, at most.
+const snippetContext = 30
+
+// SyntaxError reports a document that is not well-formed XML.
+type SyntaxError struct {
+	// Err describes the error.
+	Err error
+	// Offset is the byte offset of the error in the document.
+	Offset int
+	// Line and Column locate the error, both starting at 1 and the column
+	// counted in bytes.
+	Line, Column int
+	// Element is the name of the innermost element open at the error,
+	// empty outside the root element.
+	Element string
+	// Snippet is the source around the error, from its line only.
+	Snippet string
+}
+
+// New returns the *SyntaxError for err at offset in src, inside the
+// element named element.
+func New(src []byte, offset int, element string, err error) *SyntaxError {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	lineStart := bytes.LastIndexByte(src[:offset], '\n') + 1
+	return &SyntaxError{
+		Err:     err,
+		Offset:  offset,
+		Line:    bytes.Count(src[:lineStart], []byte{'\n'}) + 1,
+		Column:  offset - lineStart + 1,
+		Element: element,
+		Snippet: snippet(src, lineStart, offset),
+	}
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("xml: syntax error at line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// snippet returns up to snippetContext bytes on either side of offset in
+// src, without leaving the line starting at lineStart or splitting a
+// character.
+func snippet(src []byte, lineStart, offset int) string {
+	start := offset - snippetContext
+	if start < lineStart {
+		start = lineStart
+	}
+	end := offset + snippetContext
+	if i := bytes.IndexByte(src[offset:], '\n'); i >= 0 && offset+i < end {
+		end = offset + i
+	}
+	if end > len(src) {
+		end = len(src)
+	}
+	for start < offset && !utf8.RuneStart(src[start]) {
+		start++
+	}
+	for end > offset && end < len(src) && !utf8.RuneStart(src[end]) {
+		end--
+	}
+	return strings.TrimRight(string(src[start:end]), "\r")
+}
@@ -0,0 +1,46 @@
+package xmlerr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	src := []byte("<a>\n  <b>x</c>\n</a>")
+	err := New(src, 12, "b", errors.New("mismatched tags"))
+	if err.Line != 2 || err.Column != 9 || err.Offset != 12 || err.Element != "b" {
+		t.Errorf("New = %+v, want line 2, column 9, offset 12 in b", err)
+	}
+	if err.Snippet != "  <b>x</c>" {
+		t.Errorf("Snippet = %q, want the whole line", err.Snippet)
+	}
+	if got, want := err.Error(), "xml: syntax error at line 2, column 9: mismatched tags"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if errors.Unwrap(err) != err.Err {
+		t.Error("Unwrap does not return Err")
+	}
+}
+
+func TestNew_EndOfInput(t *testing.T) {
+	err := New([]byte("<a>\r\n<b>"), 100, "b", errors.New("unexpected end"))
+	if err.Offset != 8 || err.Line != 2 || err.Column != 4 || err.Snippet != "<b>" {
+		t.Errorf("New = %+v, want offset 8, line 2, column 4 and snippet <b>", err)
+	}
+	if err := New([]byte("<a>\r\n"), 3, "a", errors.New("x")); err.Snippet != "<a>" {
+		t.Errorf("Snippet = %q, want it without the carriage return", err.Snippet)
+	}
+}
+
+func TestNew_SnippetOfLongLine(t *testing.T) {
+	line := strings.Repeat("é", 40) + "<x" + strings.Repeat("ü", 40)
+	offset := strings.Index(line, "<x")
+	err := New([]byte(line), offset, "", errors.New("x"))
+	if len(err.Snippet) > 2*snippetContext || !strings.Contains(err.Snippet, "<x") {
+		t.Errorf("Snippet = %q, want at most %d bytes around <x", err.Snippet, 2*snippetContext)
+	}
+	if !strings.HasPrefix(err.Snippet, "é") || !strings.HasSuffix(err.Snippet, "ü") {
+		t.Errorf("Snippet = %q, want it cut between characters", err.Snippet)
+	}
+}
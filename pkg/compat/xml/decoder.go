@@ -0,0 +1,263 @@
+package xml
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	shapexml "github.com/shapestone/shape-xml/pkg/xml"
+)
+
+// A Decoder represents an XML parser reading a particular input stream.
+// Input in UTF-16, ISO-8859-1 or Windows-1252 is converted to UTF-8, and
+// input in other charsets by CharsetReader.
+//
+// Parsing is always strict: the fields that relax it in encoding/xml are
+// accepted for compatibility, but Strict, AutoClose and Entity have no
+// effect. Entities declared in the document's DTD
+// are expanded.
+type Decoder struct {
+	// Strict is accepted for compatibility; parsing is always strict.
+	Strict bool
+
+	// AutoClose is accepted for compatibility and ignored.
+	AutoClose []string
+
+	// Entity is accepted for compatibility and ignored.
+	Entity map[string]string
+
+	// CharsetReader, if non-nil, defines a function to generate
+	// charset-conversion readers, converting from the provided non-UTF-8
+	// charset into UTF-8. It is consulted for charsets the decoder does not
+	// know, and must be set before the first call to Token.
+	CharsetReader func(charset string, input io.Reader) (io.Reader, error)
+
+	// DefaultSpace sets the default name space used for unadorned tags,
+	// as if the entire XML stream were wrapped in an element containing
+	// the attribute xmlns="DefaultSpace".
+	DefaultSpace string
+
+	d *shapexml.Decoder
+
+	// ns holds the namespace declarations of the open elements, innermost
+	// last; marks holds the length of ns before each element's own.
+	ns    []nsBinding
+	marks []int
+	// open holds the raw start tags of the open elements.
+	open []shapexml.StartElement
+}
+
+// nsBinding binds a prefix, "" for the default namespace, to a URI.
+type nsBinding struct {
+	prefix string
+	uri    string
+}
+
+// NewDecoder creates a new XML parser reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{Strict: true, d: shapexml.NewDecoder(r)}
+}
+
+// Decode works like Unmarshal, except it reads the decoder stream to find
+// the start element.
+func (d *Decoder) Decode(v interface{}) error {
+	return d.DecodeElement(v, nil)
+}
+
+// DecodeElement works like Unmarshal except that it takes a pointer to the
+// start XML element to decode into v. It is useful when a client reads
+// some raw XML tokens itself but also wants to defer to Unmarshal for some
+// elements. start must be the StartElement most recently returned by Token.
+func (d *Decoder) DecodeElement(v interface{}, start *StartElement) error {
+	if start == nil {
+		for {
+			tok, err := d.Token()
+			if err != nil {
+				return err
+			}
+			if se, ok := tok.(StartElement); ok {
+				start = &se
+				break
+			}
+		}
+	}
+	if u, ok := v.(Unmarshaler); ok {
+		return u.UnmarshalXML(d, *start)
+	}
+	n := len(d.open)
+	if n == 0 {
+		return fmt.Errorf("xml: DecodeElement: <%s> is not the current element", start.Name.Local)
+	}
+	raw := d.open[n-1]
+	if err := d.d.DecodeElement(v, &raw); err != nil {
+		return err
+	}
+	d.popElement()
+	return nil
+}
+
+// Skip reads tokens until it has consumed the end element matching the
+// most recent start element already consumed, skipping nested structures.
+// It returns nil if it finds an end element matching the start element;
+// otherwise it returns an error describing the problem.
+func (d *Decoder) Skip() error {
+	if err := d.d.Skip(); err != nil {
+		return err
+	}
+	d.popElement()
+	return nil
+}
+
+// Token returns the next XML token in the input stream. At the end of the
+// input stream, Token returns nil, io.EOF.
+//
+// Token guarantees that the StartElement and EndElement tokens it returns
+// are properly nested and matched. Names are translated to their namespace
+// URI: Name.Space of an element or attribute is the URI its prefix, or the
+// default namespace for an unprefixed element, is bound to. Namespace
+// declarations are returned as attributes with Space "xmlns", or as the
+// attribute named xmlns for the default namespace.
+//
+// Whitespace outside the root element is not returned.
+func (d *Decoder) Token() (Token, error) {
+	tok, err := d.token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case shapexml.StartElement:
+		d.pushElement(t)
+		start := StartElement{Name: d.translate(t.Name, true)}
+		for _, a := range t.Attr {
+			start.Attr = append(start.Attr, Attr{Name: d.translateAttr(a.Name), Value: a.Value})
+		}
+		return start, nil
+	case shapexml.EndElement:
+		end := EndElement{Name: d.translate(t.Name, true)}
+		d.popElement()
+		return end, nil
+	default:
+		return convertToken(tok), nil
+	}
+}
+
+// RawToken is like Token but does not translate names to namespace URIs:
+// Name.Space holds the prefix as written.
+func (d *Decoder) RawToken() (Token, error) {
+	tok, err := d.token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case shapexml.StartElement:
+		d.pushElement(t)
+		start := StartElement{Name: rawName(t.Name)}
+		for _, a := range t.Attr {
+			start.Attr = append(start.Attr, Attr{Name: rawName(a.Name), Value: a.Value})
+		}
+		return start, nil
+	case shapexml.EndElement:
+		d.popElement()
+		return EndElement{Name: rawName(t.Name)}, nil
+	default:
+		return convertToken(tok), nil
+	}
+}
+
+// token returns the next token of the underlying decoder, which uses
+// CharsetReader from the first token on.
+func (d *Decoder) token() (shapexml.Token, error) {
+	if d.CharsetReader != nil {
+		d.d.SetCharsetReader(d.CharsetReader)
+	}
+	return d.d.Token()
+}
+
+// InputOffset returns the input stream byte offset of the current decoder
+// position.
+func (d *Decoder) InputOffset() int64 {
+	return d.d.InputOffset()
+}
+
+// convertToken converts a character data, comment or processing
+// instruction token.
+func convertToken(tok shapexml.Token) Token {
+	switch t := tok.(type) {
+	case shapexml.CharData:
+		return CharData(t)
+	case shapexml.Comment:
+		return Comment(t)
+	case shapexml.ProcInst:
+		return ProcInst{Target: t.Target, Inst: t.Inst}
+	}
+	return tok
+}
+
+// pushElement records the start tag t and the namespaces it declares.
+func (d *Decoder) pushElement(t shapexml.StartElement) {
+	d.open = append(d.open, t)
+	d.marks = append(d.marks, len(d.ns))
+	for _, a := range t.Attr {
+		switch {
+		case a.Name == "xmlns":
+			d.ns = append(d.ns, nsBinding{uri: a.Value})
+		case strings.HasPrefix(a.Name, "xmlns:"):
+			d.ns = append(d.ns, nsBinding{prefix: a.Name[len("xmlns:"):], uri: a.Value})
+		}
+	}
+}
+
+// popElement forgets the innermost open element and its declarations.
+func (d *Decoder) popElement() {
+	n := len(d.open)
+	if n == 0 {
+		return
+	}
+	d.ns = d.ns[:d.marks[n-1]]
+	d.open = d.open[:n-1]
+	d.marks = d.marks[:n-1]
+}
+
+// lookup returns the URI prefix is bound to.
+func (d *Decoder) lookup(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return shapexml.XMLNamespace, true
+	}
+	for i := len(d.ns) - 1; i >= 0; i-- {
+		if d.ns[i].prefix == prefix {
+			return d.ns[i].uri, true
+		}
+	}
+	if prefix == "" && d.DefaultSpace != "" {
+		return d.DefaultSpace, true
+	}
+	return "", false
+}
+
+// translate resolves the qualified element name qname. An undeclared
+// prefix is kept as Space.
+func (d *Decoder) translate(qname string, element bool) Name {
+	prefix, local := shapexml.SplitName(qname)
+	if prefix == "" && !element {
+		return Name{Local: local}
+	}
+	if uri, ok := d.lookup(prefix); ok {
+		return Name{Space: uri, Local: local}
+	}
+	return Name{Space: prefix, Local: local}
+}
+
+// translateAttr resolves an attribute name. Namespace declarations keep
+// the xmlns prefix as Space.
+func (d *Decoder) translateAttr(qname string) Name {
+	if prefix, local := shapexml.SplitName(qname); prefix == "xmlns" {
+		return Name{Space: "xmlns", Local: local}
+	}
+	return d.translate(qname, false)
+}
+
+// rawName splits qname into its prefix, as Space, and local part.
+func rawName(qname string) Name {
+	prefix, local := shapexml.SplitName(qname)
+	return Name{Space: prefix, Local: local}
+}
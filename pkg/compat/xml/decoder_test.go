@@ -0,0 +1,217 @@
+package xml
+
+import (
+	stdxml "encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+const nsDoc = `<?xml version="1.0"?>` +
+	`<feed xmlns="urn:atom" xmlns:x="urn:x" xml:lang="en">` +
+	`<!--c--><x:entry x:id="1" plain="p">a &amp; b</x:entry>` +
+	`<entry><title xmlns="">t</title></entry></feed>`
+
+// tokenStrings formats each token of a stream for comparison.
+func tokenStrings(next func() (interface{}, error)) ([]string, error) {
+	var out []string
+	for {
+		tok, err := next()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+		out = append(out, fmt.Sprintf("%T %v", tok, tok))
+	}
+}
+
+// fromStdlib converts an encoding/xml token to the equivalent token of
+// this package.
+func fromStdlib(tok stdxml.Token) Token {
+	name := func(n stdxml.Name) Name { return Name{Space: n.Space, Local: n.Local} }
+	switch t := tok.(type) {
+	case stdxml.StartElement:
+		start := StartElement{Name: name(t.Name)}
+		for _, a := range t.Attr {
+			start.Attr = append(start.Attr, Attr{Name: name(a.Name), Value: a.Value})
+		}
+		return start
+	case stdxml.EndElement:
+		return EndElement{Name: name(t.Name)}
+	case stdxml.CharData:
+		return CharData(t)
+	case stdxml.Comment:
+		return Comment(t)
+	case stdxml.ProcInst:
+		return ProcInst{Target: t.Target, Inst: t.Inst}
+	case stdxml.Directive:
+		return Directive(t)
+	}
+	return tok
+}
+
+func TestDecoder_TokenMatchesStdlib(t *testing.T) {
+	d := NewDecoder(strings.NewReader(nsDoc))
+	got, err := tokenStrings(func() (interface{}, error) { return d.Token() })
+	if err != nil {
+		t.Fatalf("Token failed: %v", err)
+	}
+	sd := stdxml.NewDecoder(strings.NewReader(nsDoc))
+	want, err := tokenStrings(func() (interface{}, error) {
+		tok, err := sd.Token()
+		return fromStdlib(tok), err
+	})
+	if err != nil {
+		t.Fatalf("encoding/xml Token failed: %v", err)
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDecoder_RawToken(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`<a xmlns:p="urn:p"><p:b p:c="1"/></a>`))
+	var names []string
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("RawToken failed: %v", err)
+		}
+		if se, ok := tok.(StartElement); ok {
+			names = append(names, se.Name.Space+"|"+se.Name.Local)
+			for _, a := range se.Attr {
+				names = append(names, "@"+a.Name.Space+"|"+a.Name.Local)
+			}
+		}
+	}
+	if got, want := strings.Join(names, " "), "|a @xmlns|p p|b @p|c"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestDecoder_DecodeElementAndSkip(t *testing.T) {
+	doc := `<catalog xmlns="urn:c"><skip><deep/></skip><book id="1"><title>one</title></book>` +
+		`<book id="2"><title>two</title></book></catalog>`
+	d := NewDecoder(strings.NewReader(doc))
+	var books []book
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Token failed: %v", err)
+		}
+		se, ok := tok.(StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "skip":
+			if err := d.Skip(); err != nil {
+				t.Fatalf("Skip failed: %v", err)
+			}
+		case "book":
+			var b book
+			if err := d.DecodeElement(&b, &se); err != nil {
+				t.Fatalf("DecodeElement failed: %v", err)
+			}
+			books = append(books, b)
+		}
+	}
+	if len(books) != 2 || books[0].Title != "one" || books[1].ID != "2" {
+		t.Errorf("books = %+v", books)
+	}
+	// The namespace scope unwound with each decoded element.
+	if len(d.ns) != 0 || len(d.open) != 0 {
+		t.Errorf("decoder state not unwound: ns=%v open=%v", d.ns, d.open)
+	}
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`<?xml version="1.0"?><book id="7"><title>x</title></book>`))
+	var b book
+	if err := d.Decode(&b); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if b.ID != "7" || b.Title != "x" {
+		t.Errorf("got %+v", b)
+	}
+	if _, err := d.Token(); err != io.EOF {
+		t.Errorf("Token after Decode = %v, want io.EOF", err)
+	}
+}
+
+func TestDecoder_DefaultSpace(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`<a><b xmlns="urn:b"/></a>`))
+	d.DefaultSpace = "urn:default"
+	var spaces []string
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Token failed: %v", err)
+		}
+		if se, ok := tok.(StartElement); ok {
+			spaces = append(spaces, se.Name.Space)
+		}
+	}
+	if got, want := strings.Join(spaces, " "), "urn:default urn:b"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestDecoder_SyntaxError(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`<a></b>`))
+	if _, err := d.Token(); err != nil {
+		t.Fatalf("first Token failed: %v", err)
+	}
+	if _, err := d.Token(); err == nil {
+		t.Error("expected an error for mismatched tags")
+	}
+}
+
+func TestDecoder_CharsetReader(t *testing.T) {
+	doc := "<?xml version=\"1.0\" encoding=\"x-latin\"?><n>caf\xE9</n>"
+	// latin reads the charset "x-latin" as ISO-8859-1.
+	latin := func(charset string, input io.Reader) (io.Reader, error) {
+		if charset != "x-latin" {
+			return nil, fmt.Errorf("unknown charset %q", charset)
+		}
+		data, err := io.ReadAll(input)
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return strings.NewReader(string(runes)), err
+	}
+
+	var want, got struct {
+		Text string `xml:",chardata"`
+	}
+	std := stdxml.NewDecoder(strings.NewReader(doc))
+	std.CharsetReader = latin
+	if err := std.Decode(&want); err != nil {
+		t.Fatalf("encoding/xml Decode failed: %v", err)
+	}
+	d := NewDecoder(strings.NewReader(doc))
+	d.CharsetReader = latin
+	if err := d.Decode(&got); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if got != want || got.Text != "café" {
+		t.Errorf("got %q, want %q", got.Text, want.Text)
+	}
+
+	if err := NewDecoder(strings.NewReader(doc)).Decode(&got); err == nil {
+		t.Error("expected an error without a CharsetReader")
+	}
+}
@@ -0,0 +1,395 @@
+package xml
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	shapexml "github.com/shapestone/shape-xml/pkg/xml"
+)
+
+// An Encoder writes XML data to an output stream.
+type Encoder struct {
+	w   *bufio.Writer
+	err error
+
+	// open holds the currently open elements written by EncodeToken.
+	open []openElement
+
+	prefix, indent string
+	depth          int
+	// indentedIn is set right after a start tag, so that an end tag that
+	// follows immediately stays on the same line.
+	indentedIn bool
+	// written is set once anything has been written.
+	written bool
+}
+
+// openElement is an element whose start tag EncodeToken wrote.
+type openElement struct {
+	name Name
+	// space is the default namespace in scope inside the element.
+	space string
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w)}
+}
+
+// Indent sets the encoder to generate XML in which each element begins on
+// a new indented line that starts with prefix and is followed by one or
+// more copies of indent according to the nesting depth.
+func (enc *Encoder) Indent(prefix, indent string) {
+	enc.prefix = prefix
+	enc.indent = indent
+}
+
+// Encode writes the XML encoding of v to the stream, under the element
+// name Marshal would use. Encode calls Flush before returning.
+func (enc *Encoder) Encode(v interface{}) error {
+	return enc.EncodeElement(v, StartElement{})
+}
+
+// EncodeElement writes the XML encoding of v to the stream, using start as
+// the outermost tag in the encoding. An empty start.Name selects the name
+// Marshal would use; a struct's XMLName field takes precedence over it. A
+// non-empty start.Name.Space declares that namespace on the element, and
+// start.Attr are added to its attributes. EncodeElement calls Flush before
+// returning.
+func (enc *Encoder) EncodeElement(v interface{}, start StartElement) error {
+	if m, ok := v.(Marshaler); ok {
+		if start.Name.Local == "" {
+			start.Name.Local = typeName(v)
+		}
+		if err := m.MarshalXML(enc, start); err != nil {
+			return err
+		}
+		return enc.Flush()
+	}
+	if enc.err != nil {
+		return enc.err
+	}
+
+	var b bytes.Buffer
+	inner := shapexml.NewEncoder(&b)
+	if err := inner.EncodeElement(v, start.Name.Local); err != nil {
+		return err
+	}
+	if err := inner.Flush(); err != nil {
+		return err
+	}
+	data := b.Bytes()
+	if start.Name.Space != "" || len(start.Attr) > 0 {
+		markup, err := enc.attrMarkup(start)
+		if err != nil {
+			return err
+		}
+		data = insertStartTagAttrs(data, markup)
+	}
+
+	if enc.prefix == "" && enc.indent == "" {
+		enc.written = true
+		enc.write(data)
+	} else if err := enc.writeIndented(data); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+// EncodeToken writes the given XML token to the stream. It returns an
+// error if StartElement and EndElement tokens are not properly matched.
+//
+// EncodeToken does not call Flush, because usually it is part of a larger
+// operation such as Encode or EncodeElement (or a custom Marshaler's
+// MarshalXML invoked during those), and those will call Flush when
+// finished. Callers that create an Encoder and then invoke EncodeToken
+// directly, without using Encode or EncodeElement, need to call Flush when
+// finished to ensure that the XML is written to the underlying writer.
+//
+// EncodeToken allows writing a ProcInst with Target set to "xml" only as
+// the first token in the stream.
+func (enc *Encoder) EncodeToken(t Token) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	switch t := t.(type) {
+	case StartElement:
+		if t.Name.Local == "" {
+			return errors.New("xml: start tag with no name")
+		}
+		markup, err := enc.attrMarkup(t)
+		if err != nil {
+			return err
+		}
+		space := enc.defaultSpace()
+		if t.Name.Space != "" {
+			space = t.Name.Space
+		}
+		enc.writeIndent(1)
+		enc.writeString("<" + t.Name.Local)
+		enc.write(markup)
+		enc.writeString(">")
+		enc.open = append(enc.open, openElement{name: t.Name, space: space})
+	case EndElement:
+		n := len(enc.open)
+		if n == 0 {
+			return fmt.Errorf("xml: end tag </%s> without start tag", t.Name.Local)
+		}
+		if top := enc.open[n-1].name; top.Local != t.Name.Local {
+			return fmt.Errorf("xml: end tag </%s> does not match start tag <%s>", t.Name.Local, top.Local)
+		} else if t.Name.Space != "" && t.Name.Space != top.Space {
+			return fmt.Errorf("xml: end tag </%s> in namespace %s does not match start tag <%s> in namespace %s",
+				t.Name.Local, t.Name.Space, top.Local, top.Space)
+		}
+		enc.open = enc.open[:n-1]
+		enc.writeIndent(-1)
+		enc.writeString("</" + t.Name.Local + ">")
+	case CharData:
+		enc.written = true
+		enc.write(shapexml.AppendEscapedText(nil, string(t)))
+	case Comment:
+		if bytes.Contains(t, []byte("-->")) {
+			return errors.New("xml: EncodeToken of Comment containing --> marker")
+		}
+		enc.writeIndent(0)
+		enc.writeString("<!--")
+		enc.write(t)
+		enc.writeString("-->")
+	case ProcInst:
+		// First token to be encoded which is also a ProcInst with target
+		// of xml is the xml declaration.
+		if t.Target == "xml" && enc.written {
+			return errors.New("xml: EncodeToken of ProcInst xml target only valid for xml declaration, first token encoded")
+		}
+		if t.Target == "" || strings.ContainsAny(t.Target, " \t\r\n?>") {
+			return errors.New("xml: EncodeToken of ProcInst with invalid Target")
+		}
+		if bytes.Contains(t.Inst, []byte("?>")) {
+			return errors.New("xml: EncodeToken of ProcInst containing ?> marker")
+		}
+		enc.writeIndent(0)
+		enc.writeString("<?" + t.Target)
+		if len(t.Inst) > 0 {
+			enc.writeString(" ")
+			enc.write(t.Inst)
+		}
+		enc.writeString("?>")
+	case Directive:
+		if !isValidDirective(t) {
+			return errors.New("xml: EncodeToken of Directive containing wrong < or > markers")
+		}
+		enc.writeIndent(0)
+		enc.writeString("<!")
+		enc.write(t)
+		enc.writeString(">")
+	default:
+		return errors.New("xml: EncodeToken of invalid token type")
+	}
+	return enc.err
+}
+
+// Flush flushes any buffered XML to the underlying writer.
+func (enc *Encoder) Flush() error {
+	if enc.err != nil {
+		return enc.err
+	}
+	enc.err = enc.w.Flush()
+	return enc.err
+}
+
+// Close the Encoder, indicating that no more data will be written. It
+// flushes any buffered XML to the underlying writer and returns an error if
+// the written XML is invalid (e.g. by containing unclosed elements).
+func (enc *Encoder) Close() error {
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	if n := len(enc.open); n > 0 {
+		return fmt.Errorf("xml: unclosed tag <%s>, %d tags still open", enc.open[n-1].name.Local, n)
+	}
+	return nil
+}
+
+// defaultSpace returns the default namespace in scope for the next element.
+func (enc *Encoder) defaultSpace() string {
+	if n := len(enc.open); n > 0 {
+		return enc.open[n-1].space
+	}
+	return ""
+}
+
+// attrMarkup returns the attributes of start as markup to write after the
+// element name: an xmlns declaration for start.Name.Space unless it is the
+// default namespace already, then start.Attr in order.
+func (enc *Encoder) attrMarkup(start StartElement) ([]byte, error) {
+	var markup []byte
+	if start.Name.Space != "" && start.Name.Space != enc.defaultSpace() {
+		markup = append(markup, ` xmlns="`...)
+		markup = shapexml.AppendEscapedAttr(markup, start.Name.Space)
+		markup = append(markup, '"')
+	}
+	for _, a := range start.Attr {
+		if a.Name.Local == "" {
+			continue
+		}
+		var name string
+		switch a.Name.Space {
+		case "":
+			name = a.Name.Local
+		case "xmlns":
+			name = "xmlns:" + a.Name.Local
+		case "xml", shapexml.XMLNamespace:
+			name = "xml:" + a.Name.Local
+		default:
+			return nil, fmt.Errorf("xml: attribute %s: only the xml and xmlns namespaces are supported on attributes", a.Name)
+		}
+		markup = append(markup, ' ')
+		markup = append(markup, name...)
+		markup = append(markup, '=', '"')
+		markup = shapexml.AppendEscapedAttr(markup, a.Value)
+		markup = append(markup, '"')
+	}
+	return markup, nil
+}
+
+// writeIndented re-emits the encoded element data token by token so that
+// it is indented like the tokens around it.
+func (enc *Encoder) writeIndented(data []byte) error {
+	d := shapexml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case shapexml.StartElement:
+			enc.writeIndent(1)
+			enc.writeString("<" + t.Name)
+			for _, a := range t.Attr {
+				enc.writeString(" " + a.Name + `="`)
+				enc.write(shapexml.AppendEscapedAttr(nil, a.Value))
+				enc.writeString(`"`)
+			}
+			enc.writeString(">")
+		case shapexml.EndElement:
+			enc.writeIndent(-1)
+			enc.writeString("</" + t.Name + ">")
+		case shapexml.CharData:
+			enc.write(shapexml.AppendEscapedText(nil, string(t)))
+		}
+	}
+}
+
+// writeIndent starts a new indented line for a token that changes the
+// nesting depth by depthDelta.
+func (enc *Encoder) writeIndent(depthDelta int) {
+	if enc.prefix == "" && enc.indent == "" {
+		enc.written = true
+		return
+	}
+	if depthDelta < 0 {
+		enc.depth--
+		if enc.indentedIn {
+			enc.indentedIn = false
+			return
+		}
+	}
+	enc.indentedIn = false
+	if enc.written {
+		enc.writeString("\n")
+	}
+	enc.written = true
+	enc.writeString(enc.prefix)
+	for i := 0; i < enc.depth; i++ {
+		enc.writeString(enc.indent)
+	}
+	if depthDelta > 0 {
+		enc.depth++
+		enc.indentedIn = true
+	}
+}
+
+func (enc *Encoder) write(b []byte) {
+	if enc.err == nil {
+		_, enc.err = enc.w.Write(b)
+	}
+}
+
+func (enc *Encoder) writeString(s string) {
+	if enc.err == nil {
+		_, enc.err = enc.w.WriteString(s)
+	}
+}
+
+// typeName returns the name of the type of v, through pointers, or "root"
+// for unnamed types.
+func typeName(v interface{}) string {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if name := t.Name(); name != "" {
+		return name
+	}
+	return "root"
+}
+
+// insertStartTagAttrs inserts markup right after the element name of the
+// first start tag in data.
+func insertStartTagAttrs(data, markup []byte) []byte {
+	i := bytes.IndexByte(data, '<')
+	if i < 0 {
+		return data
+	}
+	end := i + 1
+	for end < len(data) && !strings.ContainsRune(" \t\r\n/>", rune(data[end])) {
+		end++
+	}
+	out := make([]byte, 0, len(data)+len(markup))
+	out = append(out, data[:end]...)
+	out = append(out, markup...)
+	return append(out, data[end:]...)
+}
+
+// isValidDirective reports whether dir is a valid directive text, meaning
+// angle brackets are matched outside of quotes and comments.
+func isValidDirective(dir Directive) bool {
+	var depth int
+	var inQuote byte
+	inComment := false
+	for i := 0; i < len(dir); i++ {
+		c := dir[i]
+		switch {
+		case inComment:
+			if c == '>' && i >= 2 && dir[i-1] == '-' && dir[i-2] == '-' {
+				inComment = false
+			}
+		case inQuote != 0:
+			if c == inQuote {
+				inQuote = 0
+			}
+		case c == '\'' || c == '"':
+			inQuote = c
+		case c == '<':
+			if i+3 < len(dir) && string(dir[i:i+4]) == "<!--" {
+				inComment = true
+			} else {
+				depth++
+			}
+		case c == '>':
+			if depth == 0 {
+				return false
+			}
+			depth--
+		}
+	}
+	return depth == 0 && inQuote == 0 && !inComment
+}
@@ -0,0 +1,141 @@
+package xml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncodeToken(t *testing.T) {
+	var b bytes.Buffer
+	enc := NewEncoder(&b)
+	feed := StartElement{
+		Name: Name{Space: "http://www.w3.org/2005/Atom", Local: "feed"},
+		Attr: []Attr{
+			{Name: Name{Space: "xmlns", Local: "x"}, Value: "urn:x"},
+			{Name: Name{Space: "xml", Local: "lang"}, Value: "en"},
+		},
+	}
+	entry := StartElement{Name: Name{Space: "http://www.w3.org/2005/Atom", Local: "entry"}}
+	tokens := []Token{
+		ProcInst{Target: "xml", Inst: []byte(`version="1.0"`)},
+		feed,
+		Comment(" c "),
+		entry,
+		CharData("a < b"),
+		entry.End(),
+		feed.End(),
+	}
+	for _, tok := range tokens {
+		if err := enc.EncodeToken(tok); err != nil {
+			t.Fatalf("EncodeToken(%v) failed: %v", tok, err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	// The entry inherits the default namespace declared on the feed.
+	want := `<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom" xmlns:x="urn:x" xml:lang="en">` +
+		`<!-- c --><entry>a &lt; b</entry></feed>`
+	if b.String() != want {
+		t.Errorf("got  %s\nwant %s", b.String(), want)
+	}
+}
+
+func TestEncodeToken_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		tokens []Token
+		want   string
+	}{
+		{"no name", []Token{StartElement{}}, "no name"},
+		{"end without start", []Token{EndElement{Name: Name{Local: "a"}}}, "without start tag"},
+		{"mismatch", []Token{StartElement{Name: Name{Local: "a"}}, EndElement{Name: Name{Local: "b"}}}, "does not match"},
+		{"comment marker", []Token{Comment("a-->b")}, "-->"},
+		{"late declaration", []Token{Comment("c"), ProcInst{Target: "xml"}}, "first token"},
+		{"procinst marker", []Token{ProcInst{Target: "pi", Inst: []byte("?>")}}, "?>"},
+		{"directive", []Token{Directive("DOCTYPE a >")}, "markers"},
+		{"attr namespace", []Token{StartElement{Name: Name{Local: "a"}, Attr: []Attr{{Name: Name{Space: "urn:x", Local: "b"}}}}}, "namespaces"},
+		{"invalid", []Token{42}, "invalid token type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := NewEncoder(&bytes.Buffer{})
+			var err error
+			for _, tok := range tt.tokens {
+				if err = enc.EncodeToken(tok); err != nil {
+					break
+				}
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncoder_Close_Unclosed(t *testing.T) {
+	enc := NewEncoder(&bytes.Buffer{})
+	if err := enc.EncodeToken(StartElement{Name: Name{Local: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err == nil || !strings.Contains(err.Error(), "unclosed tag <a>") {
+		t.Errorf("Close err = %v", err)
+	}
+}
+
+func TestEncodeElement(t *testing.T) {
+	var b bytes.Buffer
+	enc := NewEncoder(&b)
+	start := StartElement{
+		Name: Name{Space: "urn:books", Local: "item"},
+		Attr: []Attr{{Name: Name{Local: "rev"}, Value: `2"`}},
+	}
+	if err := enc.EncodeElement(struct {
+		Title string `xml:"title"`
+	}{"t"}, start); err != nil {
+		t.Fatalf("EncodeElement failed: %v", err)
+	}
+	want := `<item xmlns="urn:books" rev="2&#34;"><title>t</title></item>`
+	if b.String() != want {
+		t.Errorf("got  %s\nwant %s", b.String(), want)
+	}
+}
+
+func TestEncoder_IndentTokensAndValues(t *testing.T) {
+	var b bytes.Buffer
+	enc := NewEncoder(&b)
+	enc.Indent("> ", "\t")
+	list := StartElement{Name: Name{Local: "list"}}
+	if err := enc.EncodeToken(list); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(book{ID: "1", Title: "t"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.EncodeToken(list.End()); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := "> <list>\n> \t<book id=\"1\">\n> \t\t<title>t</title>\n> \t</book>\n> </list>"
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestIsValidDirective(t *testing.T) {
+	valid := []string{"DOCTYPE a", `DOCTYPE a [<!ENTITY e "x>y">]`, "DOCTYPE a [<!-- > -->]"}
+	for _, d := range valid {
+		if !isValidDirective(Directive(d)) {
+			t.Errorf("isValidDirective(%q) = false", d)
+		}
+	}
+	invalid := []string{"a>", "DOCTYPE a [<!ELEMENT", `DOCTYPE "a`}
+	for _, d := range invalid {
+		if isValidDirective(Directive(d)) {
+			t.Errorf("isValidDirective(%q) = true", d)
+		}
+	}
+}
@@ -0,0 +1,456 @@
+package xml
+
+import (
+	"bytes"
+	"encoding/json"
+	stdxml "encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The parity suite runs Marshal and Unmarshal cases through this package
+// and encoding/xml and compares the results. Marshal output is compared as
+// a token stream, so <a/> and <a></a>, or different escapes of the same
+// text, are equal. Unmarshal results are compared with reflect.DeepEqual,
+// and both sides must agree on whether an error occurs.
+//
+// Every case that differs must be listed in testdata/deviations.json with
+// the reason, and every listed case must still differ, so the file stays
+// an accurate record of the known deviations.
+
+// deviation is an entry of testdata/deviations.json.
+type deviation struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+func loadDeviations(t *testing.T) map[string]string {
+	t.Helper()
+	data, err := os.ReadFile("testdata/deviations.json")
+	if err != nil {
+		t.Fatalf("reading deviations: %v", err)
+	}
+	var list []deviation
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatalf("parsing deviations: %v", err)
+	}
+	out := make(map[string]string, len(list))
+	for _, d := range list {
+		if d.Reason == "" {
+			t.Errorf("deviation %s has no reason", d.ID)
+		}
+		if _, dup := out[d.ID]; dup {
+			t.Errorf("deviation %s listed twice", d.ID)
+		}
+		out[d.ID] = d.Reason
+	}
+	return out
+}
+
+// ---------- Marshal cases ----------
+
+type pScalars struct {
+	S   string
+	I   int
+	I8  int8
+	U   uint16
+	F32 float32
+	F64 float64
+	B   bool
+}
+
+type pAttrs struct {
+	ID    string  `xml:"id,attr"`
+	N     int     `xml:"n,attr"`
+	Ok    bool    `xml:"ok,attr"`
+	Ratio float64 `xml:"ratio,attr"`
+	Name  string  `xml:"name"`
+}
+
+type pCharData struct {
+	Lang string `xml:"lang,attr"`
+	Text string `xml:",chardata"`
+}
+
+type pCDATA struct {
+	Body string `xml:",cdata"`
+}
+
+type pOmit struct {
+	A string   `xml:"a,omitempty"`
+	B int      `xml:"b,omitempty"`
+	C []string `xml:"c,omitempty"`
+	D *int     `xml:"d,omitempty"`
+	E string   `xml:"e,attr,omitempty"`
+	F string   `xml:"f"`
+}
+
+type pSkip struct {
+	Keep string `xml:"keep"`
+	Drop string `xml:"-"`
+	priv string
+}
+
+type pInner struct {
+	V string `xml:"v"`
+}
+
+type pNested struct {
+	Inner  pInner  `xml:"inner"`
+	PInner *pInner `xml:"pinner"`
+}
+
+type pNilPtr struct {
+	P *pInner `xml:"p"`
+}
+
+type pSlices struct {
+	Tags  []string `xml:"tag"`
+	Items []pInner `xml:"item"`
+	Nums  [2]int   `xml:"num"`
+}
+
+type pNilSlice struct {
+	Tags []string `xml:"tag"`
+}
+
+type pEmpty struct{}
+
+type pXMLName struct {
+	XMLName stdxml.Name `xml:"renamed"`
+	V       string      `xml:"v"`
+}
+
+type pXMLNameCompat struct {
+	XMLName Name   `xml:"renamed"`
+	V       string `xml:"v"`
+}
+
+type pNamespace struct {
+	XMLName Name   `xml:"urn:example doc"`
+	V       string `xml:"v"`
+}
+
+type pNamespaceStd struct {
+	XMLName stdxml.Name `xml:"urn:example doc"`
+	V       string      `xml:"v"`
+}
+
+type pXMLNameValue struct {
+	XMLName stdxml.Name
+	V       string `xml:"v"`
+}
+
+type pXMLNameOther struct {
+	XMLName struct{} `xml:"c"`
+	V       string   `xml:"v,attr"`
+}
+
+type pXMLNameOthers struct {
+	Items []pXMLNameOther
+}
+
+type pPaths struct {
+	Tags   []string `xml:"tags>tag"`
+	Author string   `xml:"meta>author"`
+	Year   int      `xml:"meta>year"`
+}
+
+type pEscapes struct {
+	Text string `xml:"text"`
+	Attr string `xml:"attr,attr"`
+}
+
+type pInnerXML struct {
+	Raw string `xml:",innerxml"`
+}
+
+type pComment struct {
+	C string `xml:",comment"`
+}
+
+type pTime struct {
+	T time.Time `xml:"t"`
+}
+
+type pBytes struct {
+	B []byte `xml:"b"`
+}
+
+type pMap struct {
+	M map[string]string `xml:"m"`
+}
+
+type pIface struct {
+	V interface{} `xml:"v"`
+}
+
+type pEmbedded struct {
+	pInner
+	Own string `xml:"own"`
+}
+
+type pExported struct {
+	Base pInner
+	Own  string `xml:"own"`
+}
+
+type pDuration struct {
+	D time.Duration `xml:"d"`
+}
+
+type pAny struct {
+	Known string   `xml:"known"`
+	Rest  []string `xml:",any"`
+}
+
+type pFloats struct {
+	Big   float64 `xml:"big"`
+	Small float64 `xml:"small"`
+	Third float32 `xml:"third"`
+}
+
+var marshalCases = []struct {
+	id string
+	v  interface{}
+}{
+	{"marshal/scalars", pScalars{S: "s", I: -1, I8: 8, U: 16, F32: 1.5, F64: 2.25, B: true}},
+	{"marshal/scalars-zero", pScalars{}},
+	{"marshal/attrs", pAttrs{ID: "x", N: 3, Ok: true, Ratio: 0.5, Name: "n"}},
+	{"marshal/attrs-zero", pAttrs{}},
+	{"marshal/chardata", pCharData{Lang: "en", Text: "hello"}},
+	{"marshal/cdata", pCDATA{Body: "a <b> & c"}},
+	{"marshal/omitempty-empty", pOmit{}},
+	{"marshal/omitempty-set", pOmit{A: "a", B: 1, C: []string{"c"}, E: "e", F: "f"}},
+	{"marshal/skip", pSkip{Keep: "k", Drop: "d", priv: "p"}},
+	{"marshal/nested", pNested{Inner: pInner{V: "1"}, PInner: &pInner{V: "2"}}},
+	{"marshal/nil-pointer", pNilPtr{}},
+	{"marshal/slices", pSlices{Tags: []string{"a", "b"}, Items: []pInner{{V: "1"}, {V: "2"}}, Nums: [2]int{1, 2}}},
+	{"marshal/nil-slice", pNilSlice{}},
+	{"marshal/empty-struct", pEmpty{}},
+	{"marshal/pointer-root", &pInner{V: "p"}},
+	{"marshal/xmlname-stdlib-type", pXMLName{V: "v"}},
+	{"marshal/xmlname", pXMLNameCompat{V: "v"}},
+	{"marshal/xmlname-namespace", pNamespace{V: "v"}},
+	{"marshal/xmlname-value", pXMLNameValue{XMLName: stdxml.Name{Space: "urn:v", Local: "dyn"}, V: "v"}},
+	{"marshal/xmlname-other-type", pXMLNameOthers{Items: []pXMLNameOther{{V: "1"}, {V: "2"}}}},
+	{"marshal/paths", pPaths{Tags: []string{"a", "b"}, Author: "me", Year: 2024}},
+	{"marshal/escapes", pEscapes{Text: "a<b>&\"'\tc\nd", Attr: "a<b>&\"'\tc\nd"}},
+	{"marshal/innerxml", pInnerXML{Raw: "<x>1</x>"}},
+	{"marshal/comment", pComment{C: "note"}},
+	{"marshal/time", pTime{T: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}},
+	{"marshal/bytes", pBytes{B: []byte("raw")}},
+	{"marshal/map", pMap{M: map[string]string{"k": "v"}}},
+	{"marshal/interface", pIface{V: "x"}},
+	{"marshal/embedded", pEmbedded{pInner: pInner{V: "1"}, Own: "2"}},
+	{"marshal/struct-field-default-name", pExported{Base: pInner{V: "1"}, Own: "2"}},
+	{"marshal/duration", pDuration{D: 90 * time.Second}},
+	{"marshal/any", pAny{Known: "k", Rest: []string{"r"}}},
+	{"marshal/floats", pFloats{Big: 1e21, Small: 1e-7, Third: 1.0 / 3}},
+	{"marshal/top-level-string", "text"},
+	{"marshal/top-level-int", 42},
+	{"marshal/top-level-slice", []pInner{{V: "1"}, {V: "2"}}},
+}
+
+// canonicalTokens returns the token stream of data as read by encoding/xml,
+// with adjacent character data merged and whitespace-only character data
+// between elements dropped.
+func canonicalTokens(data []byte) (string, error) {
+	d := stdxml.NewDecoder(bytes.NewReader(data))
+	var out []string
+	var text strings.Builder
+	flush := func() {
+		if s := text.String(); strings.TrimSpace(s) != "" {
+			out = append(out, fmt.Sprintf("text %q", s))
+		}
+		text.Reset()
+	}
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case stdxml.CharData:
+			text.Write(t)
+			continue
+		case stdxml.StartElement:
+			flush()
+			attrs := make([]string, 0, len(t.Attr))
+			for _, a := range t.Attr {
+				attrs = append(attrs, fmt.Sprintf("%s:%s=%q", a.Name.Space, a.Name.Local, a.Value))
+			}
+			sort.Strings(attrs)
+			out = append(out, fmt.Sprintf("start %s:%s %v", t.Name.Space, t.Name.Local, attrs))
+		case stdxml.EndElement:
+			flush()
+			out = append(out, fmt.Sprintf("end %s:%s", t.Name.Space, t.Name.Local))
+		case stdxml.Comment:
+			flush()
+			out = append(out, fmt.Sprintf("comment %q", t))
+		case stdxml.ProcInst:
+			flush()
+			out = append(out, fmt.Sprintf("procinst %s %q", t.Target, t.Inst))
+		}
+	}
+	flush()
+	return strings.Join(out, "\n"), nil
+}
+
+// marshalVerdict describes the outcome of marshaling: the canonical token
+// stream, or the fact that marshaling failed.
+func marshalVerdict(data []byte, err error) string {
+	if err != nil {
+		return "error"
+	}
+	tokens, err := canonicalTokens(data)
+	if err != nil {
+		return "malformed: " + err.Error()
+	}
+	return tokens
+}
+
+func TestParity_Marshal(t *testing.T) {
+	deviations := loadDeviations(t)
+	for _, tc := range marshalCases {
+		t.Run(tc.id, func(t *testing.T) {
+			got, gotErr := Marshal(tc.v)
+			want, wantErr := stdxml.Marshal(tc.v)
+			g, w := marshalVerdict(got, gotErr), marshalVerdict(want, wantErr)
+			checkParity(t, deviations, tc.id, g == w, func() string {
+				return fmt.Sprintf("shape-xml:   %s (err %v)\nencoding/xml: %s (err %v)", got, gotErr, want, wantErr)
+			})
+		})
+	}
+}
+
+// ---------- Unmarshal cases ----------
+
+type pText struct {
+	S string `xml:"s"`
+}
+
+type pNums struct {
+	I int     `xml:"i"`
+	U uint8   `xml:"u"`
+	F float64 `xml:"f"`
+	B bool    `xml:"b"`
+}
+
+type pList struct {
+	Items []string `xml:"item"`
+}
+
+type pPtrs struct {
+	P *string `xml:"p"`
+	Q *pInner `xml:"q"`
+}
+
+type pNSMatch struct {
+	V string `xml:"v"`
+}
+
+type pMixed struct {
+	Text  string `xml:",chardata"`
+	Child string `xml:"child"`
+}
+
+var unmarshalCases = []struct {
+	id   string
+	doc  string
+	into func() interface{}
+}{
+	{"unmarshal/scalars", `<r><S>s</S><I>-1</I><I8>8</I8><U>16</U><F32>1.5</F32><F64>2.25</F64><B>true</B></r>`, func() interface{} { return new(pScalars) }},
+	{"unmarshal/attrs", `<r id="x" n="3" ok="true" ratio="0.5"><name>n</name></r>`, func() interface{} { return new(pAttrs) }},
+	{"unmarshal/attrs-missing", `<r/>`, func() interface{} { return new(pAttrs) }},
+	{"unmarshal/chardata", `<r lang="en">hello</r>`, func() interface{} { return new(pCharData) }},
+	{"unmarshal/cdata-into-string", `<r><s><![CDATA[a <b> & c]]></s></r>`, func() interface{} { return new(pText) }},
+	{"unmarshal/entities", `<r><s>a &amp; b &lt; c &#65;&#x42;</s></r>`, func() interface{} { return new(pText) }},
+	{"unmarshal/surrounding-whitespace", `<r><s>  padded  </s></r>`, func() interface{} { return new(pText) }},
+	{"unmarshal/inner-whitespace", `<r><s>a  b</s></r>`, func() interface{} { return new(pText) }},
+	{"unmarshal/numbers-trimmed", `<r><i> 42 </i><u>7</u><f> 1.5 </f><b> true </b></r>`, func() interface{} { return new(pNums) }},
+	{"unmarshal/bool-numeric", `<r><b>1</b></r>`, func() interface{} { return new(pNums) }},
+	{"unmarshal/int-invalid", `<r><i>x</i></r>`, func() interface{} { return new(pNums) }},
+	{"unmarshal/uint-overflow", `<r><u>300</u></r>`, func() interface{} { return new(pNums) }},
+	{"unmarshal/int-empty", `<r><i></i></r>`, func() interface{} { return new(pNums) }},
+	{"unmarshal/repeated", `<r><item>a</item><item>b</item></r>`, func() interface{} { return new(pList) }},
+	{"unmarshal/single-into-slice", `<r><item>a</item></r>`, func() interface{} { return new(pList) }},
+	{"unmarshal/unknown-elements", `<r><other>x</other><s>s</s></r>`, func() interface{} { return new(pText) }},
+	{"unmarshal/missing-elements", `<r/>`, func() interface{} { return new(pNums) }},
+	{"unmarshal/pointers", `<r><p>x</p><q><v>1</v></q></r>`, func() interface{} { return new(pPtrs) }},
+	{"unmarshal/empty-into-pointer", `<r><p/><q/></r>`, func() interface{} { return new(pPtrs) }},
+	{"unmarshal/nested", `<r><inner><v>1</v></inner><pinner><v>2</v></pinner></r>`, func() interface{} { return new(pNested) }},
+	{"unmarshal/paths", `<r><tags><tag>a</tag><tag>b</tag></tags><meta><author>me</author><year>2024</year></meta></r>`, func() interface{} { return new(pPaths) }},
+	{"unmarshal/xmlname", `<renamed><v>v</v></renamed>`, func() interface{} { return new(pXMLName) }},
+	{"unmarshal/xmlname-namespace", `<doc xmlns="urn:example"><v>v</v></doc>`, func() interface{} { return new(pNamespaceStd) }},
+	{"unmarshal/xmlname-prefixed", `<e:doc xmlns:e="urn:example"><v>v</v></e:doc>`, func() interface{} { return new(pNamespaceStd) }},
+	{"unmarshal/xmlname-other-type", `<c v="1"/>`, func() interface{} { return new(pXMLNameOther) }},
+	{"unmarshal/xmlname-child", `<r><c v="1"/><c v="2"/></r>`, func() interface{} { return new(pXMLNameOthers) }},
+	{"unmarshal/prefixed-child", `<r xmlns:p="urn:p"><p:v>x</p:v></r>`, func() interface{} { return new(pNSMatch) }},
+	{"unmarshal/mixed-content", `<r>a<child>c</child>b</r>`, func() interface{} { return new(pMixed) }},
+	{"unmarshal/skip-tag", `<r><keep>k</keep><Drop>d</Drop></r>`, func() interface{} { return new(pSkip) }},
+	{"unmarshal/comments-ignored", `<r><!-- c --><s>s<!-- c -->t</s></r>`, func() interface{} { return new(pText) }},
+	{"unmarshal/doctype-entity", `<!DOCTYPE r [<!ENTITY e "ent">]><r><s>&e;</s></r>`, func() interface{} { return new(pText) }},
+	{"unmarshal/undefined-entity", `<r><s>&nope;</s></r>`, func() interface{} { return new(pText) }},
+	{"unmarshal/malformed-mismatch", `<r><s>x</t></r>`, func() interface{} { return new(pText) }},
+	{"unmarshal/malformed-unclosed", `<r><s>x</s>`, func() interface{} { return new(pText) }},
+	{"unmarshal/trailing-element", `<r/><r/>`, func() interface{} { return new(pText) }},
+	{"unmarshal/empty-input", ``, func() interface{} { return new(pText) }},
+	{"unmarshal/time", `<r><t>2024-01-02T03:04:05Z</t></r>`, func() interface{} { return new(pTime) }},
+	{"unmarshal/bytes", `<r><b>raw</b></r>`, func() interface{} { return new(pBytes) }},
+	{"unmarshal/duration", `<r><d>90000000000</d></r>`, func() interface{} { return new(pDuration) }},
+	{"unmarshal/embedded", `<r><v>1</v><own>2</own></r>`, func() interface{} { return new(pEmbedded) }},
+	{"unmarshal/innerxml", `<r><x>1</x></r>`, func() interface{} { return new(pInnerXML) }},
+}
+
+func TestParity_Unmarshal(t *testing.T) {
+	deviations := loadDeviations(t)
+	for _, tc := range unmarshalCases {
+		t.Run(tc.id, func(t *testing.T) {
+			got, want := tc.into(), tc.into()
+			gotErr := Unmarshal([]byte(tc.doc), got)
+			wantErr := stdxml.Unmarshal([]byte(tc.doc), want)
+			same := (gotErr == nil) == (wantErr == nil) && (gotErr != nil || reflect.DeepEqual(got, want))
+			checkParity(t, deviations, tc.id, same, func() string {
+				return fmt.Sprintf("shape-xml:    %+v (err %v)\nencoding/xml: %+v (err %v)", got, gotErr, want, wantErr)
+			})
+		})
+	}
+}
+
+// checkParity fails the test if the outcome of case id does not match its
+// entry, or lack of one, in the deviations file.
+func checkParity(t *testing.T, deviations map[string]string, id string, same bool, describe func() string) {
+	t.Helper()
+	reason, listed := deviations[id]
+	switch {
+	case !same && !listed:
+		t.Errorf("undocumented deviation:\n%s", describe())
+	case same && listed:
+		t.Errorf("listed as a deviation (%s) but behaves like encoding/xml", reason)
+	}
+}
+
+func TestParity_DeviationsHaveCases(t *testing.T) {
+	ids := make(map[string]bool)
+	for _, tc := range marshalCases {
+		ids[tc.id] = true
+	}
+	for _, tc := range unmarshalCases {
+		ids[tc.id] = true
+	}
+	for id := range loadDeviations(t) {
+		if !ids[id] {
+			t.Errorf("deviation %s has no case", id)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package xml
+
+import (
+	shapexml "github.com/shapestone/shape-xml/pkg/xml"
+)
+
+// Name is an XML name annotated with a namespace identifier (Space). In
+// tokens returned by Decoder.Token, Space is the namespace URI; in those
+// returned by Decoder.RawToken, it is the prefix as written.
+//
+// Name is shape-xml's Name, so XMLName fields declared with either type
+// are recognized by the encoder and decoder.
+type Name = shapexml.Name
+
+// Attr represents an attribute in an XML element (Name=Value).
+type Attr struct {
+	Name  Name
+	Value string
+}
+
+// A Token is an interface holding one of the token types:
+// StartElement, EndElement, CharData, Comment, ProcInst, or Directive.
+type Token interface{}
+
+// StartElement represents an XML start element.
+type StartElement struct {
+	Name Name
+	Attr []Attr
+}
+
+// Copy creates a new copy of StartElement.
+func (e StartElement) Copy() StartElement {
+	attrs := make([]Attr, len(e.Attr))
+	copy(attrs, e.Attr)
+	e.Attr = attrs
+	return e
+}
+
+// End returns the corresponding XML end element.
+func (e StartElement) End() EndElement {
+	return EndElement{e.Name}
+}
+
+// EndElement represents an XML end element.
+type EndElement struct {
+	Name Name
+}
+
+// CharData represents XML character data (raw text), in which XML escape
+// sequences have been replaced by the characters they represent.
+type CharData []byte
+
+// Copy creates a new copy of CharData.
+func (c CharData) Copy() CharData { return CharData(cloneBytes(c)) }
+
+// Comment represents an XML comment of the form <!--comment-->. The bytes
+// do not include the <!-- and --> comment markers.
+type Comment []byte
+
+// Copy creates a new copy of Comment.
+func (c Comment) Copy() Comment { return Comment(cloneBytes(c)) }
+
+// ProcInst represents an XML processing instruction of the form <?target inst?>.
+type ProcInst struct {
+	Target string
+	Inst   []byte
+}
+
+// Copy creates a new copy of ProcInst.
+func (p ProcInst) Copy() ProcInst {
+	p.Inst = cloneBytes(p.Inst)
+	return p
+}
+
+// Directive represents an XML directive of the form <!text>. The bytes do
+// not include the <! and > markers.
+type Directive []byte
+
+// Copy creates a new copy of Directive.
+func (d Directive) Copy() Directive { return Directive(cloneBytes(d)) }
+
+// CopyToken returns a copy of a Token.
+func CopyToken(t Token) Token {
+	switch v := t.(type) {
+	case CharData:
+		return v.Copy()
+	case Comment:
+		return v.Copy()
+	case Directive:
+		return v.Copy()
+	case ProcInst:
+		return v.Copy()
+	case StartElement:
+		return v.Copy()
+	}
+	return t
+}
+
+// cloneBytes returns a copy of b, keeping nil as nil.
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
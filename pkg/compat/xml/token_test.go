@@ -0,0 +1,38 @@
+package xml
+
+import "testing"
+
+func TestCopyToken(t *testing.T) {
+	start := StartElement{Name: Name{Local: "a"}, Attr: []Attr{{Name: Name{Local: "k"}, Value: "v"}}}
+	cp := CopyToken(start).(StartElement)
+	cp.Attr[0].Value = "changed"
+	if start.Attr[0].Value != "v" {
+		t.Error("StartElement copy shares its attributes")
+	}
+	if end := start.End(); end.Name != start.Name {
+		t.Errorf("End() = %v", end)
+	}
+
+	data := CharData("text")
+	cd := CopyToken(data).(CharData)
+	cd[0] = 'X'
+	if string(data) != "text" {
+		t.Error("CharData copy shares its bytes")
+	}
+
+	pi := ProcInst{Target: "t", Inst: []byte("i")}
+	cpi := CopyToken(pi).(ProcInst)
+	cpi.Inst[0] = 'X'
+	if string(pi.Inst) != "i" {
+		t.Error("ProcInst copy shares its bytes")
+	}
+
+	for _, tok := range []Token{Comment("c"), Directive("d"), EndElement{Name: Name{Local: "a"}}} {
+		if got := CopyToken(tok); got == nil {
+			t.Errorf("CopyToken(%v) = nil", tok)
+		}
+	}
+	if CopyToken(CharData(nil)).(CharData) != nil {
+		t.Error("copy of nil CharData is not nil")
+	}
+}
@@ -0,0 +1,97 @@
+// Package xml mirrors the API of the standard library's encoding/xml on top
+// of shape-xml, so that most code written against encoding/xml can switch by
+// changing its import path:
+//
+//	import "github.com/shapestone/shape-xml/pkg/compat/xml"
+//
+// The package has Marshal, MarshalIndent and Unmarshal, Encoder and Decoder
+// with their methods, the Name, Attr and token types, and the Marshaler and
+// Unmarshaler interfaces. Values are encoded and decoded by shape-xml, so
+// struct tags, XMLName fields, omitempty and a>b>c paths behave as
+// documented there; output may differ from encoding/xml in details such as
+// self-closing empty elements.
+//
+// It is not a complete replacement. The innerxml and comment tag options
+// are not supported, and the Decoder's Strict, AutoClose and Entity fields
+// are ignored, so code relying on them should stay on encoding/xml.
+//
+// A parity suite runs the same cases through this package and encoding/xml.
+// Every case where the two still disagree is listed with its reason in
+// testdata/deviations.json.
+//
+// Marshaler and Unmarshaler are honored for the value passed to Marshal,
+// Unmarshal, Encode, EncodeElement, Decode and DecodeElement. Values nested
+// inside it use shape-xml's own Marshaler and Unmarshaler interfaces.
+package xml
+
+import (
+	"bytes"
+	"io"
+
+	shapexml "github.com/shapestone/shape-xml/pkg/xml"
+)
+
+// Header is a generic XML header suitable for use with the output of
+// Marshal. It is not added automatically.
+const Header = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+
+// Marshaler is the interface implemented by objects that can marshal
+// themselves into valid XML elements, as in encoding/xml.
+type Marshaler interface {
+	MarshalXML(e *Encoder, start StartElement) error
+}
+
+// Unmarshaler is the interface implemented by objects that can unmarshal
+// an XML element description of themselves, as in encoding/xml.
+type Unmarshaler interface {
+	UnmarshalXML(d *Decoder, start StartElement) error
+}
+
+// Marshal returns the XML encoding of v.
+func Marshal(v interface{}) ([]byte, error) {
+	if _, ok := v.(Marshaler); !ok {
+		return shapexml.Marshal(v)
+	}
+	var b bytes.Buffer
+	if err := NewEncoder(&b).Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// MarshalIndent works like Marshal, but each XML element begins on a new
+// indented line that starts with prefix and is followed by one or more
+// copies of indent according to the nesting depth.
+func MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) {
+	var b bytes.Buffer
+	enc := NewEncoder(&b)
+	enc.Indent(prefix, indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Unmarshal parses the XML-encoded data and stores the result in the value
+// pointed to by v, which must be a non-nil pointer.
+func Unmarshal(data []byte, v interface{}) error {
+	if _, ok := v.(Unmarshaler); !ok {
+		return shapexml.Unmarshal(data, v)
+	}
+	return NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// EscapeText writes to w the properly escaped XML equivalent of the plain
+// text data s.
+func EscapeText(w io.Writer, s []byte) error {
+	_, err := w.Write(shapexml.AppendEscapedText(nil, string(s)))
+	return err
+}
+
+// Escape is like EscapeText but omits the error return value.
+func Escape(w io.Writer, s []byte) {
+	EscapeText(w, s)
+}
@@ -0,0 +1,111 @@
+package xml
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+)
+
+type book struct {
+	XMLName Name     `xml:"book"`
+	ID      string   `xml:"id,attr"`
+	Title   string   `xml:"title"`
+	Authors []string `xml:"author"`
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	in := book{ID: "b1", Title: "Go & XML", Authors: []string{"a", "b"}}
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `<book id="b1"><title>Go &amp; XML</title><author>a</author><author>b</author></book>`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+
+	var out book
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out.ID != in.ID || out.Title != in.Title || len(out.Authors) != 2 || out.XMLName.Local != "book" {
+		t.Errorf("round trip = %+v", out)
+	}
+}
+
+func TestMarshalIndent(t *testing.T) {
+	data, err := MarshalIndent(book{ID: "b1", Title: "t", Authors: []string{"a"}}, "", "  ")
+	if err != nil {
+		t.Fatalf("MarshalIndent failed: %v", err)
+	}
+	want := "<book id=\"b1\">\n  <title>t</title>\n  <author>a</author>\n</book>"
+	if string(data) != want {
+		t.Errorf("got\n%s\nwant\n%s", data, want)
+	}
+}
+
+// celsius marshals itself with the encoding/xml Marshaler signature.
+type celsius float64
+
+func (c celsius) MarshalXML(e *Encoder, start StartElement) error {
+	start.Attr = append(start.Attr, Attr{Name: Name{Local: "unit"}, Value: "C"})
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := e.EncodeToken(CharData(strconv.FormatFloat(float64(c), 'f', -1, 64))); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// point unmarshals itself with the encoding/xml Unmarshaler signature.
+type point struct {
+	X, Y string
+}
+
+func (p *point) UnmarshalXML(d *Decoder, start StartElement) error {
+	for _, a := range start.Attr {
+		switch a.Name.Local {
+		case "x":
+			p.X = a.Value
+		case "y":
+			p.Y = a.Value
+		}
+	}
+	return d.Skip()
+}
+
+func TestMarshaler(t *testing.T) {
+	data, err := Marshal(celsius(7))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if want := `<celsius unit="C">7</celsius>`; string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
+
+func TestUnmarshaler(t *testing.T) {
+	var p point
+	if err := Unmarshal([]byte(`<p x="1" y="2"><ignored/></p>`), &p); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if p.X != "1" || p.Y != "2" {
+		t.Errorf("got %+v", p)
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	var b bytes.Buffer
+	if err := EscapeText(&b, []byte(`a<b & "c"`)); err != nil {
+		t.Fatalf("EscapeText failed: %v", err)
+	}
+	if want := `a&lt;b &amp; "c"`; b.String() != want {
+		t.Errorf("got %s, want %s", b.String(), want)
+	}
+	b.Reset()
+	Escape(&b, []byte("x>y]]>"))
+	if want := "x>y]]&gt;"; b.String() != want {
+		t.Errorf("got %s, want %s", b.String(), want)
+	}
+}
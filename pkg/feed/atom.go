@@ -0,0 +1,163 @@
+package feed
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shapestone/shape-xml/pkg/xml"
+)
+
+// atomNamespace is the namespace of Atom 1.0 elements.
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+// ParseAtom reads an Atom feed. Its elements are matched by namespace, so
+// they may carry any prefix, as in <atom:feed xmlns:atom="...">. Text
+// constructs of type "html" are returned as the HTML they hold, and those
+// of type "xhtml" as the markup inside their <div>.
+//
+// Example:
+//
+//	f, err := feed.ParseAtom(body)
+//	if err != nil {
+//	    return err
+//	}
+//	for _, entry := range f.Items {
+//	    fmt.Println(entry.ID, entry.Updated)
+//	}
+func ParseAtom(input string) (*Feed, error) {
+	root, err := parse(input)
+	if err != nil {
+		return nil, err
+	}
+	if !isAtom(root) {
+		return nil, fmt.Errorf("feed: root element <%s> is not an Atom feed", root.Name())
+	}
+	return atomFeed(root), nil
+}
+
+// isAtom reports whether root is an Atom feed element: a feed in the Atom
+// namespace whatever its prefix, or an unprefixed feed as older and
+// namespace-less feeds have.
+func isAtom(root *xml.Element) bool {
+	return root.QName() == xml.Name{Space: atomNamespace, Local: "feed"} || root.Name() == "feed"
+}
+
+func atomFeed(root *xml.Element) *Feed {
+	entries := atomChildren(root, "entry")
+	f := &Feed{
+		Format:      "atom",
+		Title:       atomText(root, "title"),
+		Link:        atomLink(root),
+		Description: atomText(root, "subtitle"),
+		Updated:     atomDate(root, "updated"),
+		Items:       make([]Item, 0, len(entries)),
+	}
+	// An entry without an author has the authors of the feed.
+	author := atomAuthor(root)
+	for _, e := range entries {
+		item := Item{
+			ID:        atomChildText(e, "id"),
+			Title:     atomText(e, "title"),
+			Link:      atomLink(e),
+			Author:    atomAuthor(e),
+			Published: atomDate(e, "published", "updated"),
+			Updated:   atomDate(e, "updated"),
+			Summary:   atomText(e, "summary"),
+			Content:   atomText(e, "content"),
+		}
+		if item.Author == "" {
+			item.Author = author
+		}
+		if item.Content == "" {
+			item.Content = item.Summary
+		}
+		f.Items = append(f.Items, item)
+	}
+	return f
+}
+
+// atomLink returns the href of the first alternate link of e, a link
+// without a rel attribute being an alternate one.
+func atomLink(e *xml.Element) string {
+	for _, link := range atomChildren(e, "link") {
+		if rel, _ := link.GetAttr("rel"); rel == "" || rel == "alternate" {
+			href, _ := link.GetAttr("href")
+			return href
+		}
+	}
+	return ""
+}
+
+// atomAuthor returns the name of the first author of e.
+func atomAuthor(e *xml.Element) string {
+	if author, ok := atomChild(e, "author"); ok {
+		return atomChildText(author, "name")
+	}
+	return ""
+}
+
+// atomText returns the text construct named name: its text, or for type
+// "xhtml" the markup inside its <div>.
+func atomText(e *xml.Element, name string) string {
+	child, ok := atomChild(e, name)
+	if !ok {
+		return ""
+	}
+	if typ, _ := child.GetAttr("type"); typ != "xhtml" {
+		return text(child)
+	}
+	div, ok := child.First("div")
+	if !ok {
+		return text(child)
+	}
+	var b []byte
+	for _, n := range div.Content() {
+		switch {
+		case n.Element != nil:
+			node, err := xml.InterfaceToNode(n.Element.ToMap())
+			if err != nil {
+				continue
+			}
+			markup, err := xml.RenderWithOptions(node, xml.MarshalOptions{RootName: n.Name})
+			if err != nil {
+				continue
+			}
+			b = append(b, markup...)
+		case !n.Comment:
+			b = xml.AppendEscapedText(b, n.Text)
+		}
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// atomChildren returns the children of e named local in the namespace of
+// e, whatever prefix the document uses for it.
+func atomChildren(e *xml.Element, local string) []*xml.Element {
+	return e.ChildrenNS(e.NamespaceURI(), local)
+}
+
+// atomChild returns the first of atomChildren(e, local).
+func atomChild(e *xml.Element, local string) (*xml.Element, bool) {
+	return e.ChildNS(e.NamespaceURI(), local)
+}
+
+// atomChildText returns the text of atomChild(e, local), or "".
+func atomChildText(e *xml.Element, local string) string {
+	child, ok := atomChild(e, local)
+	if !ok {
+		return ""
+	}
+	return text(child)
+}
+
+// atomDate returns the first of the named Atom children of e holding a
+// valid date.
+func atomDate(e *xml.Element, names ...string) time.Time {
+	for _, name := range names {
+		if t := parseDate(atomChildText(e, name)); !t.IsZero() {
+			return t
+		}
+	}
+	return time.Time{}
+}
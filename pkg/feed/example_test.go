@@ -0,0 +1,25 @@
+package feed_test
+
+import (
+	"fmt"
+
+	"github.com/shapestone/shape-xml/pkg/feed"
+)
+
+func ExampleParse() {
+	f, err := feed.Parse(`<rss version="2.0"><channel>
+  <title>Example</title>
+  <item>
+    <title>Hello</title>
+    <link>https://example.com/hello</link>
+    <pubDate>Fri, 15 Mar 2024 09:30:00 GMT</pubDate>
+  </item>
+</channel></rss>`)
+	if err != nil {
+		panic(err)
+	}
+	for _, item := range f.Items {
+		fmt.Println(item.Published.Format("2006-01-02"), item.Title, item.Link)
+	}
+	// Output: 2024-03-15 Hello https://example.com/hello
+}
@@ -0,0 +1,143 @@
+// Package feed reads RSS and Atom feeds into one normalized form, so that
+// code consuming feeds does not navigate the parsed XML by hand.
+//
+// ParseRSS reads RSS 2.0, 0.9x and RSS 1.0 (RDF) documents, ParseAtom reads
+// Atom 1.0 documents, and Parse accepts either:
+//
+//	f, err := feed.Parse(body)
+//	if err != nil {
+//	    return err
+//	}
+//	for _, item := range f.Items {
+//	    fmt.Println(item.Published.Format(time.DateOnly), item.Title, item.Link)
+//	}
+//
+// Feeds in the wild are often sloppy, so the parsers are lenient about
+// content: missing elements leave fields empty, and dates that cannot be
+// parsed leave them zero. Only input that is not well-formed XML, or whose
+// root element is not a feed, is an error.
+package feed
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shapestone/shape-xml/pkg/xml"
+)
+
+// Feed is an RSS channel or an Atom feed.
+type Feed struct {
+	// Format is "rss" or "atom".
+	Format      string
+	Title       string
+	Link        string
+	Description string
+	// Updated is the time the feed last changed: the RSS lastBuildDate or
+	// pubDate, or the Atom updated element.
+	Updated time.Time
+	Items   []Item
+}
+
+// Item is an RSS item or an Atom entry.
+type Item struct {
+	// ID is the RSS guid or the Atom id.
+	ID     string
+	Title  string
+	Link   string
+	Author string
+	// Published is when the item was first published. For Atom entries
+	// without a published element, it is the updated time.
+	Published time.Time
+	Updated   time.Time
+	// Summary is the RSS description or the Atom summary.
+	Summary string
+	// Content is the full content of the item: the RSS content:encoded or
+	// the Atom content, falling back to Summary when the feed has none.
+	// HTML content is returned as HTML.
+	Content string
+}
+
+// Parse reads an RSS or Atom feed, choosing the format from the root
+// element.
+func Parse(input string) (*Feed, error) {
+	root, err := parse(input)
+	if err != nil {
+		return nil, err
+	}
+	if isAtom(root) {
+		return atomFeed(root), nil
+	}
+	return rssFeed(root)
+}
+
+// parse parses input with the content order recorded, which text mixing
+// character data and CDATA sections needs.
+func parse(input string) (*xml.Element, error) {
+	root, err := xml.ParseElementWithOptions(input, xml.WithMixedContent())
+	if err != nil {
+		return nil, fmt.Errorf("feed: %w", err)
+	}
+	return root, nil
+}
+
+// childText returns the text of the first child named name, or "".
+func childText(e *xml.Element, name string) string {
+	child, ok := e.First(name)
+	if !ok {
+		return ""
+	}
+	return text(child)
+}
+
+// text returns the character data and CDATA sections of e in order,
+// trimmed.
+func text(e *xml.Element) string {
+	var b strings.Builder
+	for _, n := range e.Content() {
+		if n.Element == nil && !n.Comment {
+			b.WriteString(n.Text)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// dateLayouts are the RFC 822 variants found in RSS dates, followed by the
+// W3C date-time profile of ISO 8601 used by Atom and Dublin Core.
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 2006 15:04 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseDate parses a feed date, returning the zero time if no layout
+// matches.
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// firstDate returns the first of the named children of e holding a valid
+// date.
+func firstDate(e *xml.Element, names ...string) time.Time {
+	for _, name := range names {
+		if t := parseDate(childText(e, name)); !t.IsZero() {
+			return t
+		}
+	}
+	return time.Time{}
+}
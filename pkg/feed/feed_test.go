@@ -0,0 +1,248 @@
+package feed
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const rss2 = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel>
+    <title>Example &amp; Co</title>
+    <link>https://example.com/</link>
+    <description>News</description>
+    <lastBuildDate>Mon, 02 Jan 2006 15:04:05 -0700</lastBuildDate>
+    <item>
+      <title>First</title>
+      <link>https://example.com/1</link>
+      <guid isPermaLink="false">urn:1</guid>
+      <dc:creator>Ann</dc:creator>
+      <pubDate>Sun, 1 Jan 2006 10:00:00 GMT</pubDate>
+      <description>&lt;p&gt;Short&lt;/p&gt;</description>
+      <content:encoded><![CDATA[<p>Long <b>text</b></p>]]></content:encoded>
+    </item>
+    <item>
+      <guid>https://example.com/2</guid>
+      <title>Second</title>
+      <description>Plain and <![CDATA[<i>mixed</i>]]> text</description>
+      <pubDate>not a date</pubDate>
+    </item>
+  </channel>
+</rss>`
+
+func TestParseRSS(t *testing.T) {
+	f, err := ParseRSS(rss2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Feed{
+		Format:      "rss",
+		Title:       "Example & Co",
+		Link:        "https://example.com/",
+		Description: "News",
+		Updated:     time.Date(2006, 1, 2, 15, 4, 5, 0, time.FixedZone("", -7*3600)),
+		Items: []Item{
+			{
+				ID:        "urn:1",
+				Title:     "First",
+				Link:      "https://example.com/1",
+				Author:    "Ann",
+				Published: time.Date(2006, 1, 1, 10, 0, 0, 0, time.UTC),
+				Summary:   "<p>Short</p>",
+				Content:   "<p>Long <b>text</b></p>",
+			},
+			{
+				ID:      "https://example.com/2",
+				Title:   "Second",
+				Link:    "https://example.com/2",
+				Summary: "Plain and <i>mixed</i> text",
+				Content: "Plain and <i>mixed</i> text",
+			},
+		},
+	}
+	assertFeed(t, f, want)
+}
+
+func TestParseRSS_RDF(t *testing.T) {
+	input := `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel rdf:about="https://example.org/"><title>RDF</title><link>https://example.org/</link><dc:date>2024-03-15T09:30:00Z</dc:date></channel>
+  <item rdf:about="https://example.org/a"><title>A</title><link>https://example.org/a</link><dc:date>2024-03-14T08:00:00+01:00</dc:date></item>
+</rdf:RDF>`
+	f, err := ParseRSS(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Title != "RDF" || !f.Updated.Equal(time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)) || len(f.Items) != 1 {
+		t.Fatalf("got %+v", f)
+	}
+	item := f.Items[0]
+	if item.ID != "https://example.org/a" || item.Title != "A" || !item.Published.Equal(time.Date(2024, 3, 14, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("got %+v", item)
+	}
+}
+
+const atom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title type="text">Example Feed</title>
+  <subtitle type="html">A &lt;em&gt;lot&lt;/em&gt; of news</subtitle>
+  <link rel="self" href="https://example.org/feed.atom"/>
+  <link href="https://example.org/"/>
+  <updated>2003-12-13T18:30:02Z</updated>
+  <author><name>John Doe</name></author>
+  <id>urn:uuid:60a76c80-d399-11d9-b93C-0003939e0af6</id>
+  <entry>
+    <title>Atom-Powered Robots Run Amok</title>
+    <link rel="alternate" href="https://example.org/2003/12/13/atom03"/>
+    <link rel="edit" href="https://example.org/edit/1"/>
+    <id>urn:uuid:1225c695-cfb8-4ebb-aaaa-80da344efa6a</id>
+    <updated>2003-12-13T18:30:02Z</updated>
+    <summary>Some text.</summary>
+  </entry>
+  <entry>
+    <title type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml">Less: <em>&lt;</em></div></title>
+    <id>urn:2</id>
+    <author><name>Jane</name></author>
+    <published>2003-12-12T10:00:00+02:00</published>
+    <updated>2003-12-13T10:00:00+02:00</updated>
+    <content type="xhtml">
+      <div xmlns="http://www.w3.org/1999/xhtml"><p>One <a href="https://example.org/?a=1&amp;b=2">link</a> &amp; more</p></div>
+    </content>
+  </entry>
+</feed>`
+
+func TestParseAtom(t *testing.T) {
+	f, err := ParseAtom(atom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plus2 := time.FixedZone("", 2*3600)
+	want := &Feed{
+		Format:      "atom",
+		Title:       "Example Feed",
+		Link:        "https://example.org/",
+		Description: "A <em>lot</em> of news",
+		Updated:     time.Date(2003, 12, 13, 18, 30, 2, 0, time.UTC),
+		Items: []Item{
+			{
+				ID:        "urn:uuid:1225c695-cfb8-4ebb-aaaa-80da344efa6a",
+				Title:     "Atom-Powered Robots Run Amok",
+				Link:      "https://example.org/2003/12/13/atom03",
+				Author:    "John Doe",
+				Published: time.Date(2003, 12, 13, 18, 30, 2, 0, time.UTC),
+				Updated:   time.Date(2003, 12, 13, 18, 30, 2, 0, time.UTC),
+				Summary:   "Some text.",
+				Content:   "Some text.",
+			},
+			{
+				ID:        "urn:2",
+				Title:     "Less: <em>&lt;</em>",
+				Author:    "Jane",
+				Published: time.Date(2003, 12, 12, 10, 0, 0, 0, plus2),
+				Updated:   time.Date(2003, 12, 13, 10, 0, 0, 0, plus2),
+				Content:   `<p>One <a href="https://example.org/?a=1&amp;b=2">link</a> &amp; more</p>`,
+			},
+		},
+	}
+	assertFeed(t, f, want)
+}
+
+func TestParseAtom_Prefixed(t *testing.T) {
+	const input = `<atom:feed xmlns:atom="http://www.w3.org/2005/Atom" xmlns:ext="urn:ext">
+  <atom:title>Prefixed</atom:title>
+  <atom:link href="https://example.org/"/>
+  <ext:title>Not Atom</ext:title>
+  <atom:entry>
+    <atom:id>urn:1</atom:id>
+    <atom:title type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml">A <em>b</em></div></atom:title>
+    <atom:author><atom:name>Ann</atom:name></atom:author>
+    <atom:updated>2024-03-14T07:00:00Z</atom:updated>
+  </atom:entry>
+</atom:feed>`
+	for _, parse := range []func(string) (*Feed, error){Parse, ParseAtom} {
+		f, err := parse(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		updated := time.Date(2024, 3, 14, 7, 0, 0, 0, time.UTC)
+		assertFeed(t, f, &Feed{
+			Format: "atom",
+			Title:  "Prefixed",
+			Link:   "https://example.org/",
+			Items: []Item{{
+				ID:        "urn:1",
+				Title:     "A <em>b</em>",
+				Author:    "Ann",
+				Published: updated,
+				Updated:   updated,
+			}},
+		})
+	}
+
+	if _, err := ParseAtom(`<x:feed xmlns:x="urn:other"/>`); err == nil {
+		t.Error("ParseAtom accepted a feed element outside the Atom namespace")
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		input  string
+		format string
+	}{
+		{rss2, "rss"},
+		{atom, "atom"},
+	} {
+		f, err := Parse(tt.input)
+		if err != nil || f.Format != tt.format {
+			t.Errorf("Parse = %v, %v, want format %q", f, err, tt.format)
+		}
+	}
+
+	for _, tt := range []struct {
+		parse func(string) (*Feed, error)
+		input string
+		want  string
+	}{
+		{Parse, `<html/>`, "root element <html> is not an RSS or Atom feed"},
+		{ParseRSS, atom, "root element <feed> is not an RSS or Atom feed"},
+		{ParseRSS, `<rss version="2.0"/>`, "<rss> has no <channel>"},
+		{ParseAtom, rss2, "root element <rss> is not an Atom feed"},
+		{Parse, `<rss><channel>`, "feed: "},
+	} {
+		if _, err := tt.parse(tt.input); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parse(%.20q) = %v, want an error containing %q", tt.input, err, tt.want)
+		}
+	}
+}
+
+func assertFeed(t *testing.T, got, want *Feed) {
+	t.Helper()
+	if len(got.Items) != len(want.Items) {
+		t.Fatalf("got %d items, want %d", len(got.Items), len(want.Items))
+	}
+	for i := range want.Items {
+		if !itemEqual(got.Items[i], want.Items[i]) {
+			t.Errorf("item %d:\n got %+v\nwant %+v", i, got.Items[i], want.Items[i])
+		}
+	}
+	g, w := *got, *want
+	g.Items, w.Items = nil, nil
+	if !g.Updated.Equal(w.Updated) {
+		t.Errorf("Updated = %v, want %v", g.Updated, w.Updated)
+	}
+	g.Updated, w.Updated = time.Time{}, time.Time{}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("got %+v, want %+v", g, w)
+	}
+}
+
+// itemEqual compares items with times compared as instants.
+func itemEqual(a, b Item) bool {
+	if !a.Published.Equal(b.Published) || !a.Updated.Equal(b.Updated) {
+		return false
+	}
+	a.Published, b.Published = time.Time{}, time.Time{}
+	a.Updated, b.Updated = time.Time{}, time.Time{}
+	return a == b
+}
@@ -0,0 +1,90 @@
+package feed
+
+import (
+	"fmt"
+
+	"github.com/shapestone/shape-xml/pkg/xml"
+)
+
+// ParseRSS reads an RSS feed: an <rss> document, or an RSS 1.0 <rdf:RDF>
+// document whose items follow its channel.
+//
+// Example:
+//
+//	f, err := feed.ParseRSS(body)
+//	if err != nil {
+//	    return err
+//	}
+//	fmt.Println(f.Title, len(f.Items))
+func ParseRSS(input string) (*Feed, error) {
+	root, err := parse(input)
+	if err != nil {
+		return nil, err
+	}
+	return rssFeed(root)
+}
+
+func rssFeed(root *xml.Element) (*Feed, error) {
+	var items []*xml.Element
+	switch root.Name() {
+	case "rss":
+		channel, ok := root.First("channel")
+		if !ok {
+			return nil, fmt.Errorf("feed: <rss> has no <channel>")
+		}
+		items = channel.GetChildren("item")
+		root = channel
+	case "rdf:RDF":
+		items = root.GetChildren("item")
+		channel, ok := root.First("channel")
+		if !ok {
+			return nil, fmt.Errorf("feed: <rdf:RDF> has no <channel>")
+		}
+		root = channel
+	default:
+		return nil, fmt.Errorf("feed: root element <%s> is not an RSS or Atom feed", root.Name())
+	}
+
+	f := &Feed{
+		Format:      "rss",
+		Title:       childText(root, "title"),
+		Link:        childText(root, "link"),
+		Description: childText(root, "description"),
+		Updated:     firstDate(root, "lastBuildDate", "pubDate", "dc:date"),
+		Items:       make([]Item, 0, len(items)),
+	}
+	for _, e := range items {
+		f.Items = append(f.Items, rssItem(e))
+	}
+	return f, nil
+}
+
+func rssItem(e *xml.Element) Item {
+	item := Item{
+		ID:        childText(e, "guid"),
+		Title:     childText(e, "title"),
+		Link:      childText(e, "link"),
+		Author:    childText(e, "author"),
+		Published: firstDate(e, "pubDate", "dc:date"),
+		Summary:   childText(e, "description"),
+		Content:   childText(e, "content:encoded"),
+	}
+	if item.ID == "" {
+		item.ID, _ = e.GetAttr("rdf:about")
+	}
+	if item.Link == "" {
+		// A guid is the item's URL unless isPermaLink is false.
+		if guid, ok := e.First("guid"); ok {
+			if permaLink, _ := guid.GetAttr("isPermaLink"); permaLink != "false" {
+				item.Link = item.ID
+			}
+		}
+	}
+	if item.Author == "" {
+		item.Author = childText(e, "dc:creator")
+	}
+	if item.Content == "" {
+		item.Content = item.Summary
+	}
+	return item
+}
@@ -0,0 +1,122 @@
+package xml
+
+import (
+	"bytes"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+// ArrayHintNamespace is the namespace of the array hint attribute.
+//
+// With MarshalOptions.ArrayHints, a list holding a single item is written
+// with a hint, so that the map form does not lose the fact that it is a list:
+//
+//	<items><item shape:array="true" xmlns:shape="urn:shapestone:xml:array">x</item></items>
+//
+// Parsing with WithArrayHints removes the hint attributes again and stores
+// the hinted element as a one-item []interface{} instead of a single value.
+// The hint is recognized by its qualified name shape:array.
+const ArrayHintNamespace = "urn:shapestone:xml:array"
+
+const (
+	arrayHintAttr  = "shape:array"
+	arrayHintXmlns = "xmlns:shape"
+)
+
+// arrayHintMarkup is the attribute text inserted into hinted start tags.
+var arrayHintMarkup = []byte(` ` + arrayHintAttr + `="true" ` + arrayHintXmlns + `="` + ArrayHintNamespace + `"`)
+
+// appendArrayHint inserts the array hint into the start tag of the element
+// named elemName written at or after buf[start:]. Output that does not start
+// with that element, such as from a Marshaler, is left unchanged.
+func appendArrayHint(buf []byte, start int, elemName string) []byte {
+	return insertStartTagAttrs(buf, start, elemName, arrayHintMarkup)
+}
+
+// insertStartTagAttrs inserts markup, one or more attributes each preceded
+// by a space, right after the name in the start tag of the element named
+// elemName written at or after buf[start:]. Output that does not start with
+// that element is left unchanged.
+func insertStartTagAttrs(buf []byte, start int, elemName string, markup []byte) []byte {
+	i := bytes.IndexByte(buf[start:], '<')
+	if i < 0 {
+		return buf
+	}
+	pos := start + i + 1
+	if !bytes.HasPrefix(buf[pos:], []byte(elemName)) {
+		return buf
+	}
+	pos += len(elemName)
+	if pos >= len(buf) || (buf[pos] != ' ' && buf[pos] != '>' && buf[pos] != '/') {
+		return buf
+	}
+	buf = append(buf, markup...)
+	copy(buf[pos+len(markup):], buf[pos:len(buf)-len(markup)])
+	copy(buf[pos:], markup)
+	return buf
+}
+
+// applyArrayHints wraps hinted child elements of every map in v into
+// one-item slices and removes the hint attributes.
+func applyArrayHints(v interface{}) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		stripArrayHint(val)
+		for k, child := range val {
+			if m, ok := child.(map[string]interface{}); ok && stripArrayHint(m) {
+				val[k] = []interface{}{m}
+			}
+			applyArrayHints(child)
+		}
+	case []interface{}:
+		for _, item := range val {
+			applyArrayHints(item)
+		}
+	}
+}
+
+// stripArrayHint removes the hint attributes from m and reports whether the
+// element was hinted.
+func stripArrayHint(m map[string]interface{}) bool {
+	hint, ok := m["@"+arrayHintAttr]
+	if !ok {
+		return false
+	}
+	delete(m, "@"+arrayHintAttr)
+	if m["@"+arrayHintXmlns] == ArrayHintNamespace {
+		delete(m, "@"+arrayHintXmlns)
+	}
+	return hint == "true"
+}
+
+// applyArrayHintNodes is applyArrayHints for the AST.
+func applyArrayHintNodes(node ast.SchemaNode) {
+	switch n := node.(type) {
+	case *ast.ObjectNode:
+		props := n.Properties()
+		stripArrayHintNode(props)
+		for k, child := range props {
+			if obj, ok := child.(*ast.ObjectNode); ok && stripArrayHintNode(obj.Properties()) {
+				props[k] = ast.NewArrayDataNode([]ast.SchemaNode{obj}, obj.Position())
+			}
+			applyArrayHintNodes(child)
+		}
+	case *ast.ArrayDataNode:
+		for _, elem := range n.Elements() {
+			applyArrayHintNodes(elem)
+		}
+	}
+}
+
+// stripArrayHintNode is stripArrayHint for AST properties.
+func stripArrayHintNode(props map[string]ast.SchemaNode) bool {
+	hint, ok := props["@"+arrayHintAttr].(*ast.LiteralNode)
+	if !ok {
+		return false
+	}
+	delete(props, "@"+arrayHintAttr)
+	if ns, ok := props["@"+arrayHintXmlns].(*ast.LiteralNode); ok && ns.Value() == ArrayHintNamespace {
+		delete(props, "@"+arrayHintXmlns)
+	}
+	return hint.Value() == "true"
+}
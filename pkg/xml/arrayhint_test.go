@@ -0,0 +1,111 @@
+package xml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+func TestArrayHints_MapRoundTrip(t *testing.T) {
+	v := map[string]interface{}{
+		"items": map[string]interface{}{
+			"item": []interface{}{"only"},
+		},
+	}
+
+	b, err := MarshalWithOptions(v, MarshalOptions{ArrayHints: true})
+	if err != nil {
+		t.Fatalf("MarshalWithOptions failed: %v", err)
+	}
+	wantXML := `<root><items><item shape:array="true" xmlns:shape="urn:shapestone:xml:array">only</item></items></root>`
+	if string(b) != wantXML {
+		t.Errorf("got %s\nwant %s", b, wantXML)
+	}
+
+	var got map[string]interface{}
+	if err := UnmarshalWithOptions(b, &got, WithArrayHints()); err != nil {
+		t.Fatalf("UnmarshalWithOptions failed: %v", err)
+	}
+	want := map[string]interface{}{
+		"items": map[string]interface{}{
+			"item": []interface{}{map[string]interface{}{"#text": "only"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip gave %#v", got)
+	}
+
+	// Without the option the hint is an ordinary attribute.
+	var plain map[string]interface{}
+	if err := Unmarshal(b, &plain); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	item := plain["items"].(map[string]interface{})["item"].(map[string]interface{})
+	if item["@shape:array"] != "true" {
+		t.Errorf("expected hint attribute without WithArrayHints, got %#v", item)
+	}
+}
+
+func TestArrayHints_OnlySingletons(t *testing.T) {
+	type Order struct {
+		Items []string `xml:"item"`
+		Tags  []string `xml:"tag"`
+	}
+	b, err := MarshalWithOptions(Order{Items: []string{"a"}, Tags: []string{"x", "y"}}, MarshalOptions{ArrayHints: true})
+	if err != nil {
+		t.Fatalf("MarshalWithOptions failed: %v", err)
+	}
+	out := string(b)
+	if strings.Count(out, "shape:array") != 1 || !strings.Contains(out, `<item shape:array="true"`) {
+		t.Errorf("expected a hint on the single item only, got %s", out)
+	}
+	if err := Validate(out); err != nil {
+		t.Errorf("hinted output is not valid XML: %v", err)
+	}
+
+	b, err = Marshal(Order{Items: []string{"a"}})
+	if err != nil || strings.Contains(string(b), "shape:array") {
+		t.Errorf("hint written without the option: %s, %v", b, err)
+	}
+}
+
+func TestArrayHints_Render(t *testing.T) {
+	node := ast.NewObjectNode(map[string]ast.SchemaNode{
+		"item": ast.NewArrayDataNode([]ast.SchemaNode{
+			ast.NewObjectNode(map[string]ast.SchemaNode{
+				"@id": ast.NewLiteralNode("1", ast.Position{}),
+			}, ast.Position{}),
+		}, ast.Position{}),
+	}, ast.Position{})
+
+	b, err := RenderWithOptions(node, MarshalOptions{ArrayHints: true})
+	if err != nil {
+		t.Fatalf("RenderWithOptions failed: %v", err)
+	}
+	want := `<root><item shape:array="true" xmlns:shape="urn:shapestone:xml:array" id="1"/></root>`
+	if string(b) != want {
+		t.Errorf("got %s\nwant %s", b, want)
+	}
+
+	e, err := ParseElementWithOptions(string(b), WithArrayHints())
+	if err != nil {
+		t.Fatalf("ParseElementWithOptions failed: %v", err)
+	}
+	items, ok := e.data["item"].([]interface{})
+	if !ok || len(items) != 1 {
+		t.Fatalf("expected a one-item list, got %#v", e.data)
+	}
+	if item := items[0].(map[string]interface{}); !reflect.DeepEqual(item, map[string]interface{}{"@id": "1"}) {
+		t.Errorf("hint attributes not removed: %#v", item)
+	}
+}
+
+func TestAppendArrayHint_ForeignOutput(t *testing.T) {
+	for _, in := range []string{"text", "<other/>", "<items/>"} {
+		if got := string(appendArrayHint([]byte(in), 0, "item")); got != in {
+			t.Errorf("appendArrayHint(%q) = %q", in, got)
+		}
+	}
+}
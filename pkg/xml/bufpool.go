@@ -0,0 +1,114 @@
+//go:build !tinygo && !shapexml_lean
+
+package xml
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+)
+
+// xmlBufPool pools []byte slices for the compiled-encoder fast path.
+var xmlBufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
+}
+
+// getEncodeBuffer returns an empty encoding buffer from xmlBufPool.
+func getEncodeBuffer() *[]byte {
+	bp := xmlBufPool.Get().(*[]byte)
+	*bp = (*bp)[:0]
+	return bp
+}
+
+// putEncodeBuffer returns an encoding buffer to xmlBufPool.
+func putEncodeBuffer(bp *[]byte) {
+	xmlBufPool.Put(bp)
+}
+
+// bufferTier is a pool of bytes.Buffer instances whose capacity does not exceed maxSize.
+type bufferTier struct {
+	maxSize int
+	pool    sync.Pool
+
+	gets atomic.Uint64
+	news atomic.Uint64
+	puts atomic.Uint64
+}
+
+// bufferTiers are the size classes used for rendering buffers. Buffers are
+// returned to the smallest tier that can hold their capacity, so repeated
+// renders of large documents reuse memory instead of allocating afresh.
+// Buffers larger than the last tier are dropped to avoid pinning excessive memory.
+var bufferTiers = newBufferTiers(64*1024, 1024*1024, 8*1024*1024)
+
+// bufferDiscards counts buffers that were too large to pool.
+var bufferDiscards atomic.Uint64
+
+func newBufferTiers(sizes ...int) []*bufferTier {
+	tiers := make([]*bufferTier, len(sizes))
+	for i, size := range sizes {
+		tier := &bufferTier{maxSize: size}
+		initial := 1024
+		if i > 0 {
+			initial = sizes[i-1]
+		}
+		tier.pool.New = func() interface{} {
+			tier.news.Add(1)
+			return bytes.NewBuffer(make([]byte, 0, initial))
+		}
+		tiers[i] = tier
+	}
+	return tiers
+}
+
+// getBuffer retrieves a reset buffer from the tier matching sizeHint and
+// ensures it can hold at least sizeHint bytes without growing.
+func getBuffer(sizeHint int) *bytes.Buffer {
+	for _, tier := range bufferTiers {
+		if sizeHint <= tier.maxSize {
+			tier.gets.Add(1)
+			buf := tier.pool.Get().(*bytes.Buffer)
+			buf.Reset()
+			buf.Grow(sizeHint)
+			return buf
+		}
+	}
+	// Larger than any tier: allocate exactly what is needed.
+	return bytes.NewBuffer(make([]byte, 0, sizeHint))
+}
+
+// putBuffer returns a buffer to the smallest tier that fits its capacity.
+// Buffers larger than the largest tier are not pooled.
+func putBuffer(buf *bytes.Buffer) {
+	c := buf.Cap()
+	for _, tier := range bufferTiers {
+		if c <= tier.maxSize {
+			tier.puts.Add(1)
+			tier.pool.Put(buf)
+			return
+		}
+	}
+	bufferDiscards.Add(1)
+}
+
+// RenderPoolStats returns a snapshot of the render buffer pool counters.
+// It is intended for metrics and tuning; a high ratio of News to Gets in a
+// tier means buffers are being reclaimed by the GC between renders.
+func RenderPoolStats() BufferPoolStats {
+	stats := BufferPoolStats{
+		Tiers:    make([]BufferTierStats, len(bufferTiers)),
+		Discards: bufferDiscards.Load(),
+	}
+	for i, tier := range bufferTiers {
+		stats.Tiers[i] = BufferTierStats{
+			MaxSize: tier.maxSize,
+			Gets:    tier.gets.Load(),
+			News:    tier.news.Load(),
+			Puts:    tier.puts.Load(),
+		}
+	}
+	return stats
+}
@@ -0,0 +1,32 @@
+//go:build tinygo || shapexml_lean
+
+package xml
+
+import "bytes"
+
+// Lean builds allocate buffers directly instead of pooling them: sync.Pool
+// and its bookkeeping cost more binary size and memory on WASM targets than
+// they save.
+
+// getEncodeBuffer returns a new empty encoding buffer.
+func getEncodeBuffer() *[]byte {
+	b := make([]byte, 0, 1024)
+	return &b
+}
+
+// putEncodeBuffer drops bp; lean builds do not pool buffers.
+func putEncodeBuffer(bp *[]byte) {}
+
+// getBuffer returns a new buffer that can hold sizeHint bytes without growing.
+func getBuffer(sizeHint int) *bytes.Buffer {
+	return bytes.NewBuffer(make([]byte, 0, sizeHint))
+}
+
+// putBuffer drops buf; lean builds do not pool buffers.
+func putBuffer(buf *bytes.Buffer) {}
+
+// RenderPoolStats returns a snapshot of the render buffer pool counters.
+// Lean builds have no pools, so the snapshot is always empty.
+func RenderPoolStats() BufferPoolStats {
+	return BufferPoolStats{}
+}
@@ -0,0 +1,75 @@
+//go:build !tinygo && !shapexml_lean
+
+package xml
+
+import (
+	"testing"
+)
+
+func TestGetBuffer_TierSelection(t *testing.T) {
+	tests := []struct {
+		hint    int
+		maxSize int
+	}{
+		{0, 64 * 1024},
+		{1024, 64 * 1024},
+		{64 * 1024, 64 * 1024},
+		{64*1024 + 1, 1024 * 1024},
+		{2 * 1024 * 1024, 8 * 1024 * 1024},
+	}
+
+	for _, tt := range tests {
+		before := RenderPoolStats()
+		buf := getBuffer(tt.hint)
+		if buf.Len() != 0 {
+			t.Errorf("hint %d: expected empty buffer, got len %d", tt.hint, buf.Len())
+		}
+		if buf.Cap() < tt.hint {
+			t.Errorf("hint %d: capacity %d below hint", tt.hint, buf.Cap())
+		}
+		after := RenderPoolStats()
+
+		for i, tier := range after.Tiers {
+			delta := tier.Gets - before.Tiers[i].Gets
+			if tier.MaxSize == tt.maxSize && delta == 0 {
+				t.Errorf("hint %d: expected a get from the %d tier", tt.hint, tt.maxSize)
+			}
+		}
+		putBuffer(buf)
+	}
+}
+
+func TestPutBuffer_DiscardsOversized(t *testing.T) {
+	before := RenderPoolStats().Discards
+	buf := getBuffer(9 * 1024 * 1024)
+	putBuffer(buf)
+	if got := RenderPoolStats().Discards; got != before+1 {
+		t.Errorf("expected discard count %d, got %d", before+1, got)
+	}
+}
+
+func TestRender_ReusesLargeBuffers(t *testing.T) {
+	node, err := Parse(generateLargeXML(3000))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if est := estimateRenderSize(node, "root"); est <= 64*1024 {
+		t.Skipf("document estimate %d too small to exercise the large tiers", est)
+	}
+
+	before := RenderPoolStats()
+	for i := 0; i < 5; i++ {
+		if _, err := Render(node); err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+	}
+	after := RenderPoolStats()
+
+	var puts uint64
+	for i := 1; i < len(after.Tiers); i++ {
+		puts += after.Tiers[i].Puts - before.Tiers[i].Puts
+	}
+	if puts == 0 {
+		t.Error("expected large render buffers to be returned to a large tier")
+	}
+}
@@ -0,0 +1,21 @@
+package xml
+
+// BufferTierStats reports usage counters for one render buffer size class.
+type BufferTierStats struct {
+	// MaxSize is the largest buffer capacity held by this tier.
+	MaxSize int
+	// Gets is the number of buffers requested from this tier.
+	Gets uint64
+	// News is the number of requests that had to allocate a new buffer.
+	News uint64
+	// Puts is the number of buffers returned to this tier.
+	Puts uint64
+}
+
+// BufferPoolStats reports usage counters for the buffer pools used by Render
+// and RenderIndent. Counters are cumulative for the life of the process.
+type BufferPoolStats struct {
+	Tiers []BufferTierStats
+	// Discards is the number of buffers dropped because they exceeded every tier.
+	Discards uint64
+}
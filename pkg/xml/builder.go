@@ -0,0 +1,224 @@
+package xml
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Schema describes the documents a Builder may construct. It covers the
+// structural rules that can be checked while a document is being written:
+// which elements exist, where they may appear, which attributes they take
+// and the types of attribute values and text.
+type Schema struct {
+	// Root is the name of the root element; "" allows any declared element.
+	Root string
+	// Elements declares every allowed element by name.
+	Elements map[string]ElementRule
+}
+
+// ElementRule declares one element of a Schema.
+type ElementRule struct {
+	// Attrs lists the allowed attributes and the types of their values.
+	Attrs map[string]ValueType
+	// Required lists the attributes that must be written.
+	Required []string
+	// Children lists the elements allowed as children.
+	Children []string
+	// Text allows character data other than whitespace in the element.
+	Text bool
+	// TextType is the type the element's text must convert to when Text is set.
+	TextType ValueType
+}
+
+// Builder writes a document through an Encoder and checks it against a
+// Schema as it is built. Each method fails at the call that breaks a rule,
+// with an error naming the element's path, and writes nothing in that case:
+//
+//	b := xml.NewBuilder(w, schema)
+//	b.Start("order")
+//	b.Attr("id", "17")
+//	b.Element("qty", "three") // xml: /order/qty: cannot convert "three" to int
+//
+// Checks that need the complete element, such as required attributes and
+// text types, run when the start tag or the element is closed.
+// A Builder is not safe for concurrent use.
+type Builder struct {
+	enc    *Encoder
+	schema *Schema
+	stack  []builderFrame
+}
+
+// builderFrame is an open element of a Builder.
+type builderFrame struct {
+	name  string
+	path  string
+	rule  ElementRule
+	attrs []string
+	// inStartTag is set until content is written to the element.
+	inStartTag bool
+	text       []byte
+	// counts holds the number of children started per name.
+	counts map[string]int
+}
+
+// NewBuilder returns a Builder writing to w. With a nil schema the Builder
+// only enforces well-formedness, like the Encoder writer methods.
+func NewBuilder(w io.Writer, schema *Schema) *Builder {
+	return &Builder{enc: NewEncoder(w), schema: schema}
+}
+
+// Start starts an element named name.
+func (b *Builder) Start(name string) error {
+	var rule ElementRule
+	var path string
+	if n := len(b.stack); n > 0 {
+		parent := &b.stack[n-1]
+		if err := b.closeStartTag(parent); err != nil {
+			return err
+		}
+		path = parent.path + "/" + name
+		if count := parent.counts[name]; count > 0 {
+			path += "[" + strconv.Itoa(count+1) + "]"
+		}
+	} else {
+		path = "/" + name
+	}
+	if b.schema != nil {
+		r, ok := b.schema.Elements[name]
+		switch {
+		case !ok:
+			return fmt.Errorf("xml: %s: element %q is not declared", path, name)
+		case len(b.stack) == 0 && b.schema.Root != "" && name != b.schema.Root:
+			return fmt.Errorf("xml: %s: root element must be %q", path, b.schema.Root)
+		case len(b.stack) > 0 && !contains(b.stack[len(b.stack)-1].rule.Children, name):
+			return fmt.Errorf("xml: %s: element %q is not allowed in %q", path, name, b.stack[len(b.stack)-1].name)
+		}
+		rule = r
+	}
+
+	if err := b.enc.WriteStartElement(name); err != nil {
+		return err
+	}
+	if n := len(b.stack); n > 0 {
+		parent := &b.stack[n-1]
+		if parent.counts == nil {
+			parent.counts = make(map[string]int)
+		}
+		parent.counts[name]++
+	}
+	b.stack = append(b.stack, builderFrame{name: name, path: path, rule: rule, inStartTag: true})
+	return nil
+}
+
+// Attr writes an attribute on the element most recently started.
+func (b *Builder) Attr(name, value string) error {
+	if b.schema != nil && len(b.stack) > 0 {
+		f := &b.stack[len(b.stack)-1]
+		typ, ok := f.rule.Attrs[name]
+		if !ok {
+			return fmt.Errorf("xml: %s: attribute %q is not allowed on %q", f.path, name, f.name)
+		}
+		if _, err := typ.convert(value); err != nil {
+			return fmt.Errorf("xml: %s/@%s: cannot convert %q to %v", f.path, name, value, typ)
+		}
+	}
+	if err := b.enc.WriteAttr(name, value); err != nil {
+		return err
+	}
+	f := &b.stack[len(b.stack)-1]
+	f.attrs = append(f.attrs, name)
+	return nil
+}
+
+// Text writes character data inside the current element.
+func (b *Builder) Text(text string) error {
+	if len(b.stack) > 0 {
+		f := &b.stack[len(b.stack)-1]
+		if err := b.closeStartTag(f); err != nil {
+			return err
+		}
+		if b.schema != nil && !f.rule.Text && strings.TrimSpace(text) != "" {
+			return fmt.Errorf("xml: %s: element %q does not allow text", f.path, f.name)
+		}
+	}
+	if err := b.enc.WriteText(text); err != nil {
+		return err
+	}
+	f := &b.stack[len(b.stack)-1]
+	if b.schema != nil && f.rule.Text {
+		f.text = append(f.text, text...)
+	}
+	return nil
+}
+
+// End closes the current element.
+func (b *Builder) End() error {
+	if len(b.stack) > 0 {
+		f := &b.stack[len(b.stack)-1]
+		if err := b.closeStartTag(f); err != nil {
+			return err
+		}
+		if b.schema != nil && f.rule.Text {
+			text := string(f.text)
+			if _, err := f.rule.TextType.convert(text); err != nil {
+				return fmt.Errorf("xml: %s: cannot convert %q to %v", f.path, text, f.rule.TextType)
+			}
+		}
+	}
+	if err := b.enc.WriteEnd(); err != nil {
+		return err
+	}
+	b.stack = b.stack[:len(b.stack)-1]
+	return nil
+}
+
+// Element writes an element holding only text: Start, Text and End.
+func (b *Builder) Element(name, text string) error {
+	if err := b.Start(name); err != nil {
+		return err
+	}
+	if text != "" {
+		if err := b.Text(text); err != nil {
+			return err
+		}
+	}
+	return b.End()
+}
+
+// Flush writes any buffered XML to the underlying writer.
+func (b *Builder) Flush() error {
+	return b.enc.Flush()
+}
+
+// Close flushes buffered output and reports an error if any element is
+// still open.
+func (b *Builder) Close() error {
+	return b.enc.Close()
+}
+
+// closeStartTag checks the rules that apply once the attributes of f are
+// complete. It runs before the first content of f is written.
+func (b *Builder) closeStartTag(f *builderFrame) error {
+	if !f.inStartTag {
+		return nil
+	}
+	for _, name := range f.rule.Required {
+		if !contains(f.attrs, name) {
+			return fmt.Errorf("xml: %s: missing required attribute %q", f.path, name)
+		}
+	}
+	f.inStartTag = false
+	return nil
+}
+
+// contains reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package xml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var orderSchema = &Schema{
+	Root: "orders",
+	Elements: map[string]ElementRule{
+		"orders": {Children: []string{"order"}},
+		"order": {
+			Attrs:    map[string]ValueType{"id": TypeInt, "note": TypeString},
+			Required: []string{"id"},
+			Children: []string{"qty", "sku"},
+		},
+		"qty": {Text: true, TextType: TypeInt},
+		"sku": {Text: true},
+	},
+}
+
+func TestBuilder_Valid(t *testing.T) {
+	var buf bytes.Buffer
+	b := NewBuilder(&buf, orderSchema)
+	steps := []error{
+		b.Start("orders"),
+		b.Text("\n  "),
+		b.Start("order"),
+		b.Attr("id", "17"),
+		b.Element("sku", "A&B"),
+		b.Element("qty", "3"),
+		b.End(),
+		b.End(),
+		b.Close(),
+	}
+	for i, err := range steps {
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+	want := "<orders>\n  <order id=\"17\"><sku>A&amp;B</sku><qty>3</qty></order></orders>"
+	if buf.String() != want {
+		t.Errorf("got  %s\nwant %s", buf.String(), want)
+	}
+}
+
+func TestBuilder_Violations(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(b *Builder) error
+		want  string
+	}{
+		{"wrong root", func(b *Builder) error {
+			return b.Start("order")
+		}, `/order: root element must be "orders"`},
+		{"undeclared", func(b *Builder) error {
+			b.Start("orders")
+			return b.Start("invoice")
+		}, `/orders/invoice: element "invoice" is not declared`},
+		{"misplaced", func(b *Builder) error {
+			b.Start("orders")
+			return b.Start("qty")
+		}, `/orders/qty: element "qty" is not allowed in "orders"`},
+		{"unknown attribute", func(b *Builder) error {
+			b.Start("orders")
+			b.Start("order")
+			return b.Attr("code", "x")
+		}, `/orders/order: attribute "code" is not allowed on "order"`},
+		{"attribute type", func(b *Builder) error {
+			b.Start("orders")
+			b.Start("order")
+			return b.Attr("id", "seven")
+		}, `/orders/order/@id: cannot convert "seven" to int`},
+		{"missing required", func(b *Builder) error {
+			b.Start("orders")
+			b.Start("order")
+			return b.Start("qty")
+		}, `/orders/order: missing required attribute "id"`},
+		{"text not allowed", func(b *Builder) error {
+			b.Start("orders")
+			return b.Text("loose")
+		}, `/orders: element "orders" does not allow text`},
+		{"text type", func(b *Builder) error {
+			b.Start("orders")
+			b.Start("order")
+			b.Attr("id", "1")
+			b.Element("qty", "1")
+			return b.Element("qty", "three")
+		}, `/orders/order/qty[2]: cannot convert "three" to int`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			b := NewBuilder(&buf, orderSchema)
+			err := tt.build(b)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuilder_FailedCallWritesNothing(t *testing.T) {
+	var buf bytes.Buffer
+	b := NewBuilder(&buf, orderSchema)
+	b.Start("orders")
+	b.Start("order")
+	b.Attr("id", "1")
+	before := string(b.enc.buf)
+	if err := b.Attr("id", "x"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if err := b.Start("orders"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if string(b.enc.buf) != before {
+		t.Errorf("failed calls changed the output: %s", b.enc.buf)
+	}
+	if err := b.Element("qty", "2"); err != nil {
+		t.Fatalf("Builder unusable after an error: %v", err)
+	}
+}
+
+func TestBuilder_NoSchema(t *testing.T) {
+	var buf bytes.Buffer
+	b := NewBuilder(&buf, nil)
+	if err := b.Start("any"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Attr("x", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Element("free", "text"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.End(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.End(); err == nil {
+		t.Error("expected an error for End without an open element")
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != `<any x="1"><free>text</free></any>` {
+		t.Errorf("got %s", buf.String())
+	}
+}
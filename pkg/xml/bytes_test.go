@@ -0,0 +1,94 @@
+package xml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBytes_MatchesParse(t *testing.T) {
+	docs := []string{
+		`<root/>`,
+		`<?xml version="1.0"?><user id="1"><name>Alice</name><tag>a</tag><tag>b</tag></user>`,
+		"<a>\n  <b>héllo wörld</b>\n  <c attr=\"日本語\">✓ &lt;ok&gt;</c>\n</a>",
+		`<a><![CDATA[<raw> & ]]></a>`,
+		`<!DOCTYPE a [<!ENTITY e "value">]><a>&e;<!-- skipped --></a>`,
+	}
+	for _, doc := range docs {
+		want, err := Parse(doc)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", doc, err)
+		}
+		got, err := ParseBytes([]byte(doc))
+		if err != nil {
+			t.Fatalf("ParseBytes(%q): %v", doc, err)
+		}
+		if !reflect.DeepEqual(NodeToInterface(got), NodeToInterface(want)) {
+			t.Errorf("ParseBytes(%q) = %v, want %v", doc, NodeToInterface(got), NodeToInterface(want))
+		}
+	}
+}
+
+func TestParseBytes_Errors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"<a>\xff</a>", "line 1, column 4: invalid UTF-8"},
+		{`<a><b></a>`, "mismatched tags"},
+		{``, ""},
+	}
+	for _, tt := range tests {
+		_, err := ParseBytes([]byte(tt.input))
+		if err == nil {
+			t.Errorf("ParseBytes(%q) succeeded", tt.input)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseBytes(%q) error = %v, want it to mention %q", tt.input, err, tt.want)
+		}
+	}
+}
+
+func TestParseBytes_ErrorPositionsMatchParse(t *testing.T) {
+	for _, doc := range []string{"<a>é</b>", "<a x=\"é\">\n é<b>é</c></a>"} {
+		_, want := Parse(doc)
+		_, got := ParseBytes([]byte(doc))
+		if want == nil || got == nil {
+			t.Fatalf("%q: Parse error %v, ParseBytes error %v", doc, want, got)
+		}
+		if got.Error() != want.Error() {
+			t.Errorf("%q: ParseBytes error\n%v\nwant\n%v", doc, got, want)
+		}
+	}
+}
+
+func TestValidateBytes(t *testing.T) {
+	if err := ValidateBytes([]byte(`<a x="1"><b>é</b></a>`)); err != nil {
+		t.Errorf("ValidateBytes of a valid document: %v", err)
+	}
+	for _, input := range []string{`<a><b></a>`, `<a>`, ``} {
+		if err := ValidateBytes([]byte(input)); err == nil {
+			t.Errorf("ValidateBytes(%q) succeeded", input)
+		}
+		if (ValidateBytes([]byte(input)) == nil) != (Validate(input) == nil) {
+			t.Errorf("ValidateBytes(%q) and Validate disagree", input)
+		}
+	}
+}
+
+func TestUnmarshalWithOptions_MixedContentFromBytes(t *testing.T) {
+	var v interface{}
+	err := UnmarshalWithOptions([]byte(`<p>Größe <b>XL</b> ✓</p>`), &v, WithMixedContent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, ok := v.(map[string]interface{})["#content"].([]interface{})
+	if !ok || len(content) != 3 {
+		t.Fatalf("#content = %#v, want 3 items", v.(map[string]interface{})["#content"])
+	}
+	want := map[string]interface{}{"#text": "Größe "}
+	if !reflect.DeepEqual(content[0], want) {
+		t.Errorf("first item = %#v, want %#v", content[0], want)
+	}
+}
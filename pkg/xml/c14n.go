@@ -0,0 +1,256 @@
+package xml
+
+import (
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+// excC14N identifies Exclusive XML Canonicalization 1.0 without comments,
+// the canonical form that Sign writes and VerifySignature checks.
+const excC14N = "http://www.w3.org/2001/10/xml-exc-c14n#"
+
+// c14nElement is an element to canonicalize, read from a document with
+// readC14N or from an AST with astC14N.
+type c14nElement struct {
+	name string
+	// attrs holds the attributes as written, namespace declarations
+	// included.
+	attrs []rawAttr
+	// content holds text as strings, child elements as *c14nElement and
+	// processing instructions as c14nProcInst, in document order.
+	content []interface{}
+}
+
+type c14nProcInst struct {
+	target, text string
+}
+
+// c14nDocument is a document to canonicalize: the root element and the
+// processing instructions around it.
+type c14nDocument struct {
+	root           *c14nElement
+	prolog, epilog []c14nProcInst
+}
+
+// readC14N reads input into a c14nDocument. Line breaks and attribute
+// values are normalized, entities expanded, CDATA sections become text and
+// comments are dropped, as canonicalization requires; other whitespace is
+// kept as written.
+func readC14N(input string) (*c14nDocument, error) {
+	// Line breaks are normalized before parsing, see XML 1.0 section 2.11.
+	input = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(input)
+	dec := NewDecoder(strings.NewReader(input))
+	dec.emitMarkup = true
+	dec.normalizeAttrs = true
+	doc := &c14nDocument{}
+	var stack []*c14nElement
+	for {
+		tok, err := dec.readToken()
+		if err == io.EOF {
+			return doc, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok.kind {
+		case tokenStart:
+			elem := &c14nElement{name: tok.name, attrs: tok.attrs}
+			if len(stack) == 0 {
+				doc.root = elem
+			} else {
+				parent := stack[len(stack)-1]
+				parent.content = append(parent.content, elem)
+			}
+			stack = append(stack, elem)
+		case tokenEnd:
+			stack = stack[:len(stack)-1]
+		case tokenText, tokenCDATA:
+			top := stack[len(stack)-1]
+			top.content = append(top.content, tok.text)
+		case tokenProcInst:
+			if strings.EqualFold(tok.name, "xml") {
+				continue
+			}
+			pi := c14nProcInst{target: tok.name, text: tok.text}
+			switch {
+			case len(stack) > 0:
+				top := stack[len(stack)-1]
+				top.content = append(top.content, pi)
+			case doc.root == nil:
+				doc.prolog = append(doc.prolog, pi)
+			default:
+				doc.epilog = append(doc.epilog, pi)
+			}
+		}
+	}
+}
+
+// astC14N converts the element name with the AST node into a c14nElement.
+// Content is taken in document order when node was parsed with
+// ParseOptions.MixedContent, and in position order otherwise.
+func astC14N(name string, node ast.SchemaNode) *c14nElement {
+	elem := &c14nElement{name: name}
+	if obj, ok := node.(*ast.ObjectNode); ok {
+		for key, value := range obj.Properties() {
+			if lit, ok := value.(*ast.LiteralNode); ok && strings.HasPrefix(key, "@") {
+				elem.attrs = append(elem.attrs, rawAttr{name: key[1:], value: stringValue(lit)})
+			}
+		}
+	}
+	for _, item := range contentItems(node) {
+		if item.isText {
+			elem.content = append(elem.content, item.text)
+		} else {
+			elem.content = append(elem.content, astC14N(item.name, item.node))
+		}
+	}
+	return elem
+}
+
+// declares returns the namespace scope of e's content: scope with the
+// declarations of e added.
+func (e *c14nElement) declares(scope nsScope) nsScope {
+	for _, attr := range e.attrs {
+		if attr.name == "xmlns" || strings.HasPrefix(attr.name, "xmlns:") {
+			prefix := strings.TrimPrefix(strings.TrimPrefix(attr.name, "xmlns"), ":")
+			if scope[prefix] != attr.value {
+				scope = scope.with(prefix, attr.value)
+			}
+		}
+	}
+	return scope
+}
+
+// attr returns the value of the attribute name.
+func (e *c14nElement) attr(name string) (string, bool) {
+	for _, attr := range e.attrs {
+		if attr.name == name {
+			return attr.value, true
+		}
+	}
+	return "", false
+}
+
+// canonicalizer writes the exclusive canonical form of an element.
+type canonicalizer struct {
+	b strings.Builder
+	// skip is left out of the output: the signature that the enveloped
+	// signature transform removes.
+	skip *c14nElement
+	// inclusive holds the prefixes of an InclusiveNamespaces PrefixList,
+	// "" for #default, which are written wherever they are in scope.
+	inclusive map[string]bool
+}
+
+// canonicalize returns the exclusive canonical form of e, whose ancestors
+// declare scope.
+func (c *canonicalizer) canonicalize(e *c14nElement, scope nsScope) []byte {
+	c.b.Reset()
+	c.element(e, scope, nsScope{})
+	return []byte(c.b.String())
+}
+
+// document returns the exclusive canonical form of doc.
+func (c *canonicalizer) document(doc *c14nDocument) []byte {
+	c.b.Reset()
+	for _, pi := range doc.prolog {
+		c.procInst(pi)
+		c.b.WriteByte('\n')
+	}
+	c.element(doc.root, nsScope{"xml": xmlNamespace}, nsScope{})
+	for _, pi := range doc.epilog {
+		c.b.WriteByte('\n')
+		c.procInst(pi)
+	}
+	return []byte(c.b.String())
+}
+
+// element writes e. rendered holds the namespace declarations in effect
+// in the output so far.
+func (c *canonicalizer) element(e *c14nElement, scope, rendered nsScope) {
+	scope = e.declares(scope)
+
+	type attr struct{ name, space, local, value string }
+	var attrs []attr
+	prefix, _ := SplitName(e.name)
+	used := map[string]bool{prefix: true}
+	for _, a := range e.attrs {
+		if a.name == "xmlns" || strings.HasPrefix(a.name, "xmlns:") {
+			continue
+		}
+		// Unprefixed attributes are in no namespace.
+		p, local := SplitName(a.name)
+		space := ""
+		if p != "" {
+			used[p] = true
+			space = scope[p]
+		}
+		attrs = append(attrs, attr{name: a.name, space: space, local: local, value: a.value})
+	}
+	for p := range c.inclusive {
+		if _, ok := scope[p]; ok {
+			used[p] = true
+		}
+	}
+
+	var decls []string
+	for p := range used {
+		if p != "xml" && rendered[p] != scope[p] {
+			decls = append(decls, p)
+		}
+	}
+	sort.Strings(decls)
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].space != attrs[j].space {
+			return attrs[i].space < attrs[j].space
+		}
+		return attrs[i].local < attrs[j].local
+	})
+
+	c.b.WriteString("<" + e.name)
+	for _, p := range decls {
+		rendered = rendered.with(p, scope[p])
+		if p == "" {
+			c.b.WriteString(` xmlns="`)
+		} else {
+			c.b.WriteString(" xmlns:" + p + `="`)
+		}
+		c14nAttrEscaper.WriteString(&c.b, scope[p])
+		c.b.WriteByte('"')
+	}
+	for _, a := range attrs {
+		c.b.WriteString(" " + a.name + `="`)
+		c14nAttrEscaper.WriteString(&c.b, a.value)
+		c.b.WriteByte('"')
+	}
+	c.b.WriteByte('>')
+	for _, item := range e.content {
+		switch item := item.(type) {
+		case string:
+			c14nTextEscaper.WriteString(&c.b, item)
+		case *c14nElement:
+			if item != c.skip {
+				c.element(item, scope, rendered)
+			}
+		case c14nProcInst:
+			c.procInst(item)
+		}
+	}
+	c.b.WriteString("</" + e.name + ">")
+}
+
+func (c *canonicalizer) procInst(pi c14nProcInst) {
+	c.b.WriteString("<?" + pi.target)
+	if pi.text != "" {
+		c.b.WriteString(" " + pi.text)
+	}
+	c.b.WriteString("?>")
+}
+
+var (
+	c14nTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	c14nAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
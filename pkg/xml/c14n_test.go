@@ -0,0 +1,61 @@
+package xml
+
+import "testing"
+
+// The expected canonical forms were checked against xmllint --exc-c14n.
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"namespaces",
+			`<r xmlns="urn:d" xmlns:a="urn:a" xmlns:u="urn:unused"><a:x b='1' a:c="2&#9;&amp;&lt;&gt;"  > t&amp;&gt;<![CDATA[<c>]]><y xmlns=""/></a:x>` + "\n" +
+				`<z xmlns:u="urn:u2" u:k="v"/></r>`,
+			`<r xmlns="urn:d"><a:x xmlns:a="urn:a" b="1" a:c="2&#x9;&amp;&lt;>"> t&amp;&gt;&lt;c&gt;<y xmlns=""></y></a:x>` + "\n" +
+				`<z xmlns:u="urn:u2" u:k="v"></z></r>`},
+		{"markup",
+			"<?xml version=\"1.0\"?>\n<?pi one?>\n<!DOCTYPE r [<!ENTITY e \"E\">]>\n" +
+				"<r a=\"x\ny\tz\" xml:lang=\"en\"><?p  two ?><!-- c --><s:e xmlns:s=\"urn:s\" xmlns:t=\"urn:t\"><t:f/>&e;</s:e></r>\n<?after?>\n",
+			"<?pi one?>\n<r a=\"x y z\" xml:lang=\"en\"><?p two ?><s:e xmlns:s=\"urn:s\"><t:f xmlns:t=\"urn:t\"></t:f>E</s:e></r>\n<?after?>"},
+		{"line breaks", "<r a=\"&#13;\">a\r\nb\rc&#13;</r>", "<r a=\"&#xD;\">a\nb\nc&#xD;</r>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := readC14N(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var c canonicalizer
+			if got := string(c.document(doc)); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalize_AST(t *testing.T) {
+	input := `<r xmlns:p="urn:p" z="1" a="2"><p:a>x <b/> y</p:a><c/><p:a/></r>`
+	node, err := ParseWithOptions(input, WithMixedContent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c canonicalizer
+	got := string(c.document(&c14nDocument{root: astC14N("r", node)}))
+	want := `<r a="2" z="1"><p:a xmlns:p="urn:p">x <b></b> y</p:a><c></c><p:a xmlns:p="urn:p"></p:a></r>`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCanonicalize_InclusivePrefixes(t *testing.T) {
+	doc, err := readC14N(`<r xmlns:xs="urn:xs" xmlns:u="urn:u"><a type="xs:string"/></r>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := canonicalizer{inclusive: map[string]bool{"xs": true}}
+	want := `<r xmlns:xs="urn:xs"><a type="xs:string"></a></r>`
+	if got := string(c.document(doc)); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,194 @@
+package xml
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+// ParseCacheOptions configures a ParseCache. A zero limit means no limit.
+type ParseCacheOptions struct {
+	// MaxEntries is the number of parsed documents the cache holds.
+	MaxEntries int
+	// MaxBytes bounds the total size of the inputs behind the cached
+	// documents. An input larger than MaxBytes is parsed but not cached.
+	MaxBytes int
+	// TTL is how long a document stays cached after it was parsed.
+	TTL time.Duration
+	// Options are applied to every parse made by the cache.
+	Options []ParseOption
+}
+
+// ParseCacheStats reports the activity of a ParseCache.
+type ParseCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+	Bytes     int
+}
+
+// ParseCache caches parsed documents by a SHA-256 fingerprint of their
+// input, for services that receive the same payload again and again, such
+// as a fleet of devices posting identical configuration. Identical inputs
+// share one parsed result, which must therefore not be modified:
+// ParseElement returns frozen Elements, while the AST nodes returned by
+// Parse are shared as they are. Inputs that fail to parse are cached too,
+// and return the same error on every call.
+//
+// When the cache is full, the least recently used document is evicted. A
+// ParseCache is safe for concurrent use.
+//
+// Example:
+//
+//	var configs = xml.NewParseCache(xml.ParseCacheOptions{
+//	    MaxEntries: 1024,
+//	    MaxBytes:   16 << 20,
+//	    TTL:        10 * time.Minute,
+//	})
+//
+//	func handle(body []byte) error {
+//	    cfg, err := configs.ParseElement(body)
+//	    if err != nil {
+//	        return err
+//	    }
+//	    version, _ := cfg.GetAttr("version")
+//	    ...
+//	}
+type ParseCache struct {
+	mu      sync.Mutex
+	opts    ParseCacheOptions
+	entries map[cacheKey]*list.Element
+	lru     *list.List // most recently used at the front
+	bytes   int
+	stats   ParseCacheStats
+	now     func() time.Time
+}
+
+// cacheKey identifies a cached result: the input fingerprint and whether
+// it was parsed to an Element or to an AST.
+type cacheKey struct {
+	sum     [sha256.Size]byte
+	element bool
+}
+
+// cacheEntry is a cached parse result.
+type cacheEntry struct {
+	key     cacheKey
+	size    int
+	expires time.Time
+	elem    *Element
+	node    ast.SchemaNode
+	err     error
+}
+
+// NewParseCache returns an empty ParseCache with the given limits.
+func NewParseCache(opts ParseCacheOptions) *ParseCache {
+	return &ParseCache{
+		opts:    opts,
+		entries: make(map[cacheKey]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// ParseElement parses data like ParseElementWithOptions, with the cache's
+// options, and returns the result frozen. A cached result is returned
+// without parsing data again.
+func (c *ParseCache) ParseElement(data []byte) (*Element, error) {
+	key := cacheKey{sum: sha256.Sum256(data), element: true}
+	if e, ok := c.get(key); ok {
+		return e.elem, e.err
+	}
+	elem, err := parseElementBytes(data, c.opts.Options...)
+	if elem != nil {
+		// The element is new, so it can be frozen without copying it.
+		elem.frozen = true
+	}
+	c.add(&cacheEntry{key: key, size: len(data), elem: elem, err: err})
+	return elem, err
+}
+
+// Parse parses data like ParseWithOptions, with the cache's options. A
+// cached result is returned without parsing data again; the returned AST is
+// shared with every caller that parsed the same input and must not be
+// modified.
+func (c *ParseCache) Parse(data []byte) (ast.SchemaNode, error) {
+	key := cacheKey{sum: sha256.Sum256(data)}
+	if e, ok := c.get(key); ok {
+		return e.node, e.err
+	}
+	node, err := parseBytesWithOptions(data, c.opts.Options...)
+	c.add(&cacheEntry{key: key, size: len(data), node: node, err: err})
+	return node, err
+}
+
+// Stats returns the cache's counters and current size.
+func (c *ParseCache) Stats() ParseCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	stats.Bytes = c.bytes
+	return stats
+}
+
+// Purge removes every cached document.
+func (c *ParseCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[cacheKey]*list.Element)
+	c.lru.Init()
+	c.bytes = 0
+}
+
+// get returns the live entry for key, counting a hit or a miss.
+func (c *ParseCache) get(key cacheKey) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if le, ok := c.entries[key]; ok {
+		e := le.Value.(*cacheEntry)
+		if e.expires.IsZero() || c.now().Before(e.expires) {
+			c.lru.MoveToFront(le)
+			c.stats.Hits++
+			return e, true
+		}
+		c.remove(le)
+	}
+	c.stats.Misses++
+	return nil, false
+}
+
+// add caches e, evicting the least recently used entries to stay within
+// the limits.
+func (c *ParseCache) add(e *cacheEntry) {
+	if c.opts.MaxBytes > 0 && e.size > c.opts.MaxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if le, ok := c.entries[e.key]; ok {
+		// Another caller parsed the same input meanwhile.
+		c.remove(le)
+	}
+	if c.opts.TTL > 0 {
+		e.expires = c.now().Add(c.opts.TTL)
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	c.bytes += e.size
+	for (c.opts.MaxEntries > 0 && c.lru.Len() > c.opts.MaxEntries) ||
+		(c.opts.MaxBytes > 0 && c.bytes > c.opts.MaxBytes) {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove drops the entry held by le.
+func (c *ParseCache) remove(le *list.Element) {
+	e := c.lru.Remove(le).(*cacheEntry)
+	delete(c.entries, e.key)
+	c.bytes -= e.size
+}
@@ -0,0 +1,148 @@
+package xml
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseCache_ParseElement(t *testing.T) {
+	c := NewParseCache(ParseCacheOptions{MaxEntries: 8})
+	doc := []byte(`<config version="3"><mode>eco</mode></config>`)
+
+	a, err := c.ParseElement(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.ParseElement(append([]byte(nil), doc...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Error("identical input did not return the cached element")
+	}
+	if !a.Frozen() || a.Name() != "config" {
+		t.Errorf("frozen = %v, name = %q", a.Frozen(), a.Name())
+	}
+	if v, _ := a.GetAttr("version"); v != "3" {
+		t.Errorf("version = %q", v)
+	}
+	a.Attr("version", "4")
+	if v, _ := b.GetAttr("version"); v != "3" {
+		t.Error("a setter changed the cached element")
+	}
+
+	if s := c.Stats(); s.Hits != 1 || s.Misses != 1 || s.Entries != 1 || s.Bytes != len(doc) {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestParseCache_Parse(t *testing.T) {
+	c := NewParseCache(ParseCacheOptions{Options: []ParseOption{WithSortedKeys()}})
+	doc := []byte(`<a><b>1</b></a>`)
+	n1, err := c.Parse(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n2, _ := c.Parse(doc)
+	if n1 != n2 {
+		t.Error("identical input did not return the cached AST")
+	}
+	// Elements and ASTs are cached separately.
+	if _, err := c.ParseElement(doc); err != nil {
+		t.Fatal(err)
+	}
+	if s := c.Stats(); s.Entries != 2 || s.Hits != 1 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestParseCache_Errors(t *testing.T) {
+	c := NewParseCache(ParseCacheOptions{})
+	_, err1 := c.ParseElement([]byte(`<a><b></a>`))
+	_, err2 := c.ParseElement([]byte(`<a><b></a>`))
+	if err1 == nil || err1 != err2 {
+		t.Errorf("errors = %v, %v; want the same cached error", err1, err2)
+	}
+}
+
+func TestParseCache_Limits(t *testing.T) {
+	c := NewParseCache(ParseCacheOptions{MaxEntries: 2, MaxBytes: 20})
+	docs := [][]byte{[]byte(`<a/>`), []byte(`<b/>`), []byte(`<c/>`)}
+	for _, d := range docs {
+		c.ParseElement(d)
+	}
+	if s := c.Stats(); s.Entries != 2 || s.Evictions != 1 {
+		t.Errorf("stats = %+v", s)
+	}
+	c.ParseElement(docs[0]) // evicted, parsed again
+	if s := c.Stats(); s.Misses != 4 {
+		t.Errorf("stats = %+v", s)
+	}
+
+	// Inputs over MaxBytes are not cached.
+	big := []byte(`<long>0123456789012345</long>`)
+	e, err := c.ParseElement(big)
+	if err != nil || !e.Frozen() {
+		t.Fatalf("err = %v", err)
+	}
+	if s := c.Stats(); s.Entries != 2 || s.Bytes > 20 {
+		t.Errorf("stats = %+v", s)
+	}
+
+	// The byte limit evicts as well.
+	c = NewParseCache(ParseCacheOptions{MaxBytes: 10})
+	c.ParseElement([]byte(`<a>1</a>`))
+	c.ParseElement([]byte(`<b>2</b>`))
+	if s := c.Stats(); s.Entries != 1 || s.Bytes != 8 {
+		t.Errorf("stats = %+v", s)
+	}
+
+	c.Purge()
+	if s := c.Stats(); s.Entries != 0 || s.Bytes != 0 {
+		t.Errorf("after Purge: %+v", s)
+	}
+}
+
+func TestParseCache_TTL(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := NewParseCache(ParseCacheOptions{TTL: time.Minute})
+	c.now = func() time.Time { return now }
+	doc := []byte(`<a/>`)
+
+	first, _ := c.ParseElement(doc)
+	now = now.Add(59 * time.Second)
+	if e, _ := c.ParseElement(doc); e != first {
+		t.Error("entry expired early")
+	}
+	now = now.Add(time.Second)
+	if e, _ := c.ParseElement(doc); e == first {
+		t.Error("expired entry was returned")
+	}
+}
+
+func TestParseCache_Concurrent(t *testing.T) {
+	c := NewParseCache(ParseCacheOptions{MaxEntries: 4})
+	docs := [][]byte{[]byte(`<a x="1"/>`), []byte(`<b x="2"/>`), []byte(`<c x="3"/>`), []byte(`<d><e/></d>`), []byte(`<f/>`)}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				d := docs[(i+j)%len(docs)]
+				e, err := c.ParseElement(d)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				e.Keys()
+				c.Parse(d)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if s := c.Stats(); s.Entries > 4 {
+		t.Errorf("stats = %+v", s)
+	}
+}
@@ -0,0 +1,57 @@
+package xml
+
+import (
+	"io"
+
+	"github.com/shapestone/shape-xml/internal/charset"
+)
+
+// CharsetReader returns a reader converting input, encoded in the named
+// charset, to UTF-8. It has the signature of encoding/xml's
+// Decoder.CharsetReader, so the same functions, such as
+// charset.NewReaderLabel from golang.org/x/net/html/charset, work with
+// both packages.
+//
+// Documents are detected as UTF-16 from a byte-order mark or their first
+// bytes, and otherwise as encoded in the charset named by their XML
+// declaration. UTF-8, US-ASCII, UTF-16, ISO-8859-1 and Windows-1252 are
+// read without a CharsetReader; other charsets need one, see
+// WithCharsetReader and Decoder.SetCharsetReader. Conversion applies to
+// documents given as bytes or readers, such as to ParseBytes, Unmarshal or
+// NewDecoder. Strings are text already and are not converted; a leading
+// byte-order mark is skipped in every case.
+//
+// Positions in errors and in the AST, and the offsets of a Decoder, refer
+// to the converted document. A Checkpoint of a converted document cannot
+// be resumed.
+type CharsetReader func(charset string, input io.Reader) (io.Reader, error)
+
+// WithCharsetReader makes UnmarshalWithOptions and the parse cache read
+// documents in charsets other than those known to the package, see
+// CharsetReader.
+//
+// Example:
+//
+//	err := xml.UnmarshalWithOptions(data, &feed, xml.WithCharsetReader(charset.NewReaderLabel))
+func WithCharsetReader(fn CharsetReader) ParseOption {
+	return func(o *ParseOptions) {
+		o.CharsetReader = fn
+	}
+}
+
+// SetCharsetReader makes the Decoder read documents in charsets other than
+// those known to the package, see CharsetReader. It has no effect once
+// reading has started.
+func (d *Decoder) SetCharsetReader(fn CharsetReader) {
+	d.charset.Func = charset.Func(fn)
+}
+
+// convert returns data converted to UTF-8, see CharsetReader, and cleaned
+// of the characters XML does not allow, see WithInvalidChars.
+func (o *ParseOptions) convert(data []byte) ([]byte, error) {
+	data, err := charset.Convert(data, charset.Func(o.CharsetReader))
+	if err != nil {
+		return nil, err
+	}
+	return o.clean(data), nil
+}
@@ -0,0 +1,144 @@
+package xml
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+const latin1Doc = "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><item><name>caf\xE9</name></item>"
+
+// utf16LE returns s in UTF-16LE, led by a byte-order mark.
+func utf16LE(s string) []byte {
+	b := []byte{0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u), byte(u>>8))
+	}
+	return b
+}
+
+// upperCharset reads the charset "x-upper" as UTF-8 upper-cased after the
+// XML declaration.
+func upperCharset(charset string, input io.Reader) (io.Reader, error) {
+	if charset != "x-upper" {
+		return nil, errors.New("unknown charset")
+	}
+	data, err := io.ReadAll(input)
+	decl, body, _ := strings.Cut(string(data), "?>")
+	return strings.NewReader(decl + "?>" + strings.ToUpper(body)), err
+}
+
+func TestCharset_Unmarshal(t *testing.T) {
+	type item struct {
+		Name string `xml:"name"`
+	}
+	for name, data := range map[string][]byte{
+		"ISO-8859-1": []byte(latin1Doc),
+		"UTF-16":     utf16LE(`<?xml version="1.0" encoding="UTF-16"?><item><name>café</name></item>`),
+		"UTF-8 BOM":  []byte("\xEF\xBB\xBF<item><name>café</name></item>"),
+	} {
+		var v item
+		if err := Unmarshal(data, &v); err != nil {
+			t.Errorf("%s: Unmarshal failed: %v", name, err)
+		} else if v.Name != "café" {
+			t.Errorf("%s: Name = %q, want %q", name, v.Name, "café")
+		}
+		var m interface{}
+		if err := UnmarshalWithOptions(data, &m, WithPreserveOrder()); err != nil {
+			t.Errorf("%s: UnmarshalWithOptions failed: %v", name, err)
+		}
+	}
+}
+
+func TestCharset_ParseAndValidate(t *testing.T) {
+	data := utf16LE(`<item id="é"/>`)
+	node, err := ParseBytes(data)
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	if got, err := Render(node); err != nil || !strings.Contains(string(got), `id="é"`) {
+		t.Errorf("ParseBytes gave %s, %v", got, err)
+	}
+	if err := ValidateBytes(data); err != nil {
+		t.Errorf("ValidateBytes failed: %v", err)
+	}
+	if err := ValidateReader(strings.NewReader(latin1Doc)); err != nil {
+		t.Errorf("ValidateReader failed: %v", err)
+	}
+	if _, err := ParseReader(strings.NewReader(latin1Doc)); err != nil {
+		t.Errorf("ParseReader failed: %v", err)
+	}
+
+	bom := "\xEF\xBB\xBF<item/>"
+	if _, err := Parse(bom); err != nil {
+		t.Errorf("Parse with a byte-order mark failed: %v", err)
+	}
+	if err := Validate(bom); err != nil {
+		t.Errorf("Validate with a byte-order mark failed: %v", err)
+	}
+
+	err = ValidateBytes([]byte(`<?xml version="1.0" encoding="x-upper"?><item/>`))
+	if err == nil || !strings.Contains(err.Error(), `unsupported encoding "x-upper"`) {
+		t.Errorf("unsupported encoding: got %v", err)
+	}
+}
+
+func TestCharset_WithCharsetReader(t *testing.T) {
+	data := []byte(`<?xml version="1.0" encoding="x-upper"?><item>quiet</item>`)
+	var v interface{}
+	if err := UnmarshalWithOptions(data, &v); err == nil {
+		t.Error("expected an error without a CharsetReader")
+	}
+	if err := UnmarshalWithOptions(data, &v, WithCharsetReader(upperCharset)); err != nil {
+		t.Fatalf("UnmarshalWithOptions failed: %v", err)
+	}
+	if want := map[string]interface{}{"#text": "QUIET"}; !reflect.DeepEqual(v, want) {
+		t.Errorf("got %#v, want %#v", v, want)
+	}
+}
+
+func TestCharset_Decoder(t *testing.T) {
+	tokens := func(d *Decoder) ([]Token, error) {
+		var out []Token
+		for {
+			tok, err := d.Token()
+			if err == io.EOF {
+				return out, nil
+			}
+			if err != nil {
+				return out, err
+			}
+			if _, ok := tok.(ProcInst); !ok {
+				out = append(out, tok)
+			}
+		}
+	}
+	want := []Token{StartElement{Name: "item"}, CharData("café"), EndElement{Name: "item"}}
+
+	for name, data := range map[string][]byte{
+		"ISO-8859-1": []byte("<?xml version=\"1.0\" encoding=\"latin1\"?><item>caf\xE9</item>"),
+		"UTF-16":     utf16LE(`<item>café</item>`),
+		"UTF-8 BOM":  []byte("\xEF\xBB\xBF<item>café</item>"),
+	} {
+		got, err := tokens(NewDecoder(strings.NewReader(string(data))))
+		if err != nil {
+			t.Errorf("%s: Token failed: %v", name, err)
+		} else if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %#v, want %#v", name, got, want)
+		}
+	}
+
+	d := NewDecoder(strings.NewReader(`<?xml version="1.0" encoding="x-upper"?><item>café</item>`))
+	d.SetCharsetReader(upperCharset)
+	got, err := tokens(d)
+	if err != nil {
+		t.Fatalf("Token failed: %v", err)
+	}
+	want = []Token{StartElement{Name: "ITEM"}, CharData("CAFÉ"), EndElement{Name: "ITEM"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
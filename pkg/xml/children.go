@@ -0,0 +1,141 @@
+package xml
+
+// A child element that occurs once is stored as a map, and a repeated one as
+// a []interface{} of maps. The helpers below hide the difference, so that
+// code reading a document does not depend on how many occurrences it holds.
+
+// GetChildren returns every child element named name, in document order.
+// It returns a single element for a child stored once, and nil if there is
+// none. Hand-built children holding plain text are returned as elements
+// with that text.
+//
+// Example:
+//
+//	order, _ := xml.ParseElement(input)
+//	for _, item := range order.GetChildren("item") {
+//	    sku, _ := item.GetAttr("sku")
+//	    fmt.Println(sku)
+//	}
+func (e *Element) GetChildren(name string) []*Element {
+	if !isChildName(name) {
+		return nil
+	}
+	v, ok := e.data[name]
+	if !ok {
+		return nil
+	}
+	var children []*Element
+	for i := 0; ; i++ {
+		child, ok := occurrence(v, i)
+		if !ok {
+			return children
+		}
+		children = append(children, e.contentChild(child, name))
+	}
+}
+
+// EachChild calls fn for every child element, ordered by name and then by
+// position among same-named siblings, until fn returns false. Repeated
+// children are passed one at a time, under their shared name.
+//
+// Example:
+//
+//	doc.EachChild(func(name string, child *xml.Element) bool {
+//	    fmt.Println(child.Path())
+//	    return true
+//	})
+func (e *Element) EachChild(fn func(name string, child *Element) bool) {
+	for _, name := range childNames(e.data) {
+		for _, child := range e.GetChildren(name) {
+			if !fn(name, child) {
+				return
+			}
+		}
+	}
+}
+
+// First returns the first child element named name, whether the child is
+// stored once or repeated. Returns nil and false if there is none.
+func (e *Element) First(name string) (*Element, bool) {
+	if !isChildName(name) {
+		return nil, false
+	}
+	child, ok := occurrence(e.data[name], 0)
+	if !ok || child == nil {
+		return nil, false
+	}
+	return e.contentChild(child, name), true
+}
+
+// Last returns the last child element named name, whether the child is
+// stored once or repeated. Returns nil and false if there is none.
+func (e *Element) Last(name string) (*Element, bool) {
+	children := e.GetChildren(name)
+	if len(children) == 0 {
+		return nil, false
+	}
+	return children[len(children)-1], true
+}
+
+// AppendChild adds child after any existing children named name and returns
+// the parent Element for chaining. A second child of the same name turns the
+// stored map into a []interface{}, as the parser stores repeated elements,
+// so that none is overwritten as with Child. A frozen child is copied into
+// the tree.
+//
+// Example:
+//
+//	order := xml.NewElement()
+//	for _, sku := range []string{"a", "b"} {
+//	    order.AppendChild("item", xml.NewElement().Attr("sku", sku))
+//	}
+//	// <order><item sku="a"/><item sku="b"/></order>
+func (e *Element) AppendChild(name string, child *Element) *Element {
+	e = e.mutable()
+	data := child.data
+	if child.frozen {
+		data = deepCopyMap(child.data)
+	} else {
+		child.parent = e
+		child.name = name
+	}
+	switch v := e.data[name].(type) {
+	case nil:
+		e.data[name] = data
+	case []interface{}:
+		e.data[name] = append(v, data)
+	default:
+		e.data[name] = []interface{}{v, data}
+	}
+	return e
+}
+
+// RemoveChildAt removes the i-th (0-based) child element named name and
+// returns the Element for chaining. A single remaining child is stored as a
+// plain map again. It does nothing if there is no such child.
+func (e *Element) RemoveChildAt(name string, i int) *Element {
+	if !isChildName(name) || i < 0 {
+		return e
+	}
+	v, ok := e.data[name]
+	if !ok {
+		return e
+	}
+	e = e.mutable()
+	if !isSlice(v) {
+		if i == 0 {
+			delete(e.data, name)
+		}
+		return e
+	}
+	if i < len(v.([]interface{})) {
+		e.removeAt(name, i)
+	}
+	return e
+}
+
+// isChildName reports whether the key name holds child elements rather
+// than an attribute or text.
+func isChildName(name string) bool {
+	return len(name) > 0 && name[0] != '@' && name[0] != '#'
+}
@@ -0,0 +1,210 @@
+package xml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestElement_GetChildren(t *testing.T) {
+	doc, err := ParseElement(`<order><item sku="a"/><item sku="b"/><item sku="c"/><note>n</note></order>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var skus, paths []string
+	for _, item := range doc.GetChildren("item") {
+		sku, _ := item.GetAttr("sku")
+		skus = append(skus, sku)
+		paths = append(paths, item.Path())
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(skus, want) {
+		t.Errorf("skus = %v, want %v", skus, want)
+	}
+	if want := []string{"/order/item[1]", "/order/item[2]", "/order/item[3]"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+
+	// A single child is a one-element list.
+	notes := doc.GetChildren("note")
+	if len(notes) != 1 || notes[0].Name() != "note" {
+		t.Fatalf("GetChildren(note) = %v", notes)
+	}
+	if text, _ := notes[0].GetText(); text != "n" {
+		t.Errorf("note text = %q", text)
+	}
+
+	for _, name := range []string{"missing", "@sku", "#text", ""} {
+		if got := doc.GetChildren(name); got != nil {
+			t.Errorf("GetChildren(%q) = %v, want nil", name, got)
+		}
+	}
+}
+
+func TestElement_FirstLast(t *testing.T) {
+	doc, err := ParseElement(`<list><v>1</v><v>2</v><v>3</v><one>x</one></list>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		first, last string
+	}{
+		{"v", "1", "3"},
+		{"one", "x", "x"},
+	}
+	for _, tt := range tests {
+		first, ok := doc.First(tt.name)
+		if !ok {
+			t.Fatalf("First(%q) not found", tt.name)
+		}
+		last, ok := doc.Last(tt.name)
+		if !ok {
+			t.Fatalf("Last(%q) not found", tt.name)
+		}
+		if got, _ := first.GetText(); got != tt.first {
+			t.Errorf("First(%q) = %q, want %q", tt.name, got, tt.first)
+		}
+		if got, _ := last.GetText(); got != tt.last {
+			t.Errorf("Last(%q) = %q, want %q", tt.name, got, tt.last)
+		}
+	}
+	if _, ok := doc.First("missing"); ok {
+		t.Error("First(missing) found an element")
+	}
+	if _, ok := doc.Last("missing"); ok {
+		t.Error("Last(missing) found an element")
+	}
+
+	// Changes through the returned element reach the document.
+	last, _ := doc.Last("v")
+	last.Text("9")
+	if out, _ := doc.XML("list"); out != "<root><one>x</one><v>1</v><v>2</v><v>9</v></root>" {
+		t.Errorf("XML = %s", out)
+	}
+}
+
+func TestElement_EachChild(t *testing.T) {
+	doc := NewElement().
+		Attr("id", "1").
+		Text("t").
+		ChildText("b", "plain").
+		Set("a", []interface{}{
+			map[string]interface{}{"#text": "a1"},
+			map[string]interface{}{"#text": "a2"},
+		})
+
+	var got []string
+	doc.EachChild(func(name string, child *Element) bool {
+		text, _ := child.GetText()
+		got = append(got, name+"="+text)
+		return true
+	})
+	if want := []string{"a=a1", "a=a2", "b=plain"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EachChild visited %v, want %v", got, want)
+	}
+
+	calls := 0
+	doc.EachChild(func(string, *Element) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("EachChild continued after false: %d calls", calls)
+	}
+}
+
+func TestElement_GetChildren_Frozen(t *testing.T) {
+	doc, err := ParseElement(`<r><i>1</i><i>2</i></r>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frozen := doc.Freeze()
+	items := frozen.GetChildren("i")
+	if len(items) != 2 || !items[0].Frozen() || !items[1].Frozen() {
+		t.Fatalf("children of a frozen element are not frozen: %v", items)
+	}
+	items[0].Text("changed")
+	if text, _ := frozen.GetChildren("i")[0].GetText(); text != "1" {
+		t.Errorf("frozen child changed to %q", text)
+	}
+}
+
+func TestElement_AppendChild(t *testing.T) {
+	order := NewElement()
+	var items []*Element
+	for _, sku := range []string{"a", "b", "c"} {
+		item := NewElement().Attr("sku", sku)
+		items = append(items, item)
+		order.AppendChild("item", item)
+	}
+	order.AppendChild("note", NewElement().Text("n"))
+
+	if out, _ := order.XML("order"); out != `<root><item sku="a"/><item sku="b"/><item sku="c"/><note>n</note></root>` {
+		t.Errorf("XML = %s", out)
+	}
+	if _, ok := order.Get("note"); !ok || isSlice(order.ToMap()["note"]) {
+		t.Error("a single appended child is not stored as a map")
+	}
+	if got := items[1].Path(); got != "/item[2]" {
+		t.Errorf("appended child path = %q", got)
+	}
+
+	// A child set with Child or ChildText is kept.
+	doc := NewElement().ChildText("v", "1").AppendChild("v", NewElement().Text("2"))
+	if out, _ := doc.XML("r"); out != "<root><v>1</v><v>2</v></root>" {
+		t.Errorf("XML = %s", out)
+	}
+
+	// A frozen child is copied, and a frozen parent is copied on write.
+	frozen := NewElement().Text("f").Freeze()
+	doc.AppendChild("v", frozen)
+	if n := len(doc.GetChildren("v")); n != 3 {
+		t.Errorf("%d v children, want 3", n)
+	}
+	snapshot := doc.Freeze()
+	changed := snapshot.AppendChild("v", NewElement())
+	if len(snapshot.GetChildren("v")) != 3 || len(changed.GetChildren("v")) != 4 {
+		t.Error("AppendChild changed a frozen element")
+	}
+}
+
+func TestElement_RemoveChildAt(t *testing.T) {
+	parse := func() *Element {
+		doc, err := ParseElement(`<r><i>0</i><i>1</i><i>2</i><one>x</one></r>`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return doc
+	}
+	tests := []struct {
+		name string
+		key  string
+		i    int
+		want string
+	}{
+		{"middle", "i", 1, "<root><i>0</i><i>2</i><one>x</one></root>"},
+		{"first", "i", 0, "<root><i>1</i><i>2</i><one>x</one></root>"},
+		{"single", "one", 0, "<root><i>0</i><i>1</i><i>2</i></root>"},
+		{"out of range", "i", 3, "<root><i>0</i><i>1</i><i>2</i><one>x</one></root>"},
+		{"negative", "i", -1, "<root><i>0</i><i>1</i><i>2</i><one>x</one></root>"},
+		{"single out of range", "one", 1, "<root><i>0</i><i>1</i><i>2</i><one>x</one></root>"},
+		{"missing", "none", 0, "<root><i>0</i><i>1</i><i>2</i><one>x</one></root>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := parse().RemoveChildAt(tt.key, tt.i)
+			if out, _ := doc.XML("r"); out != tt.want {
+				t.Errorf("XML = %s, want %s", out, tt.want)
+			}
+		})
+	}
+
+	// Down to one occurrence the child is a plain map again, and GetChild
+	// finds it.
+	doc := parse().RemoveChildAt("i", 0).RemoveChildAt("i", 0)
+	if child, ok := doc.GetChild("i"); !ok {
+		t.Error("GetChild does not find the remaining child")
+	} else if text, _ := child.GetText(); text != "2" {
+		t.Errorf("remaining child text = %q", text)
+	}
+}
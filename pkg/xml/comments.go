@@ -0,0 +1,76 @@
+package xml
+
+import (
+	"strings"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+// WithComments makes the parser keep comments, which it otherwise drops, so
+// that a document edited programmatically and written back with Render
+// keeps its documentation. A comment inside an element becomes a
+// {"#comment": text} item of the element's "#content" order, described at
+// WithMixedContent; only elements holding a comment get a "#content" list,
+// unless WithMixedContent is given too. Comments before and after the root
+// element are kept as lists of such items under the root's "#prolog" and
+// "#epilog" keys. The text is kept as written, without "<!--" and "-->".
+//
+// Render, Marshal of an *OrderedMap and Element.XML write the comments back
+// in place; Element.Content returns them as nodes with Comment set.
+//
+// WithComments applies to ParseWithOptions, ParseElementWithOptions and
+// UnmarshalWithOptions into interface{}; it is not supported together with
+// WithElementFilter or WithMaxDepth.
+//
+// Example:
+//
+//	input := "<config>\n  <!-- seconds -->\n  <timeout>30</timeout>\n</config>"
+//	cfg, _ := xml.ParseElementWithOptions(input, xml.WithComments())
+//	timeout, _ := cfg.GetChild("timeout")
+//	timeout.Text("60")
+//	out, _ := cfg.XMLIndent("config", "", "  ")
+//	// The "seconds" comment is still in front of <timeout>.
+func WithComments() ParseOption {
+	return func(o *ParseOptions) {
+		o.Comments = true
+	}
+}
+
+// appendComment appends text as a comment. Text a comment cannot hold, a
+// "--" or a trailing "-", is broken up with a space.
+func appendComment(dst []byte, text string) []byte {
+	dst = append(dst, "<!--"...)
+	dst = append(dst, strings.ReplaceAll(text, "--", "- -")...)
+	if strings.HasSuffix(text, "-") {
+		dst = append(dst, ' ')
+	}
+	return append(dst, "-->"...)
+}
+
+// renderComments writes the "#prolog" or "#epilog" comments of the root.
+func (r *renderer) renderComments(node ast.SchemaNode) {
+	list, ok := node.(*ast.ArrayDataNode)
+	if !ok {
+		return
+	}
+	for _, item := range list.Elements() {
+		obj, ok := item.(*ast.ObjectNode)
+		if !ok {
+			continue
+		}
+		if literal, ok := obj.Properties()["#comment"].(*ast.LiteralNode); ok {
+			r.buf.Write(appendComment(nil, textValue(literal.Value())))
+		}
+	}
+}
+
+// appendComments appends the "#prolog" or "#epilog" comments of m.
+func (m *OrderedMap) appendComments(buf []byte, key string) []byte {
+	items, _ := m.values[key].([]interface{})
+	for _, item := range items {
+		if k, v := contentItem(item); k == "#comment" {
+			buf = appendComment(buf, textValue(v))
+		}
+	}
+	return buf
+}
@@ -0,0 +1,91 @@
+package xml
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shapestone/shape-xml/internal/parser"
+)
+
+func TestWithComments_RoundTrip(t *testing.T) {
+	tests := []string{
+		`<!-- generated --><config><!-- seconds --><timeout>30</timeout><retries>3</retries></config><!-- end -->`,
+		`<a><b>1<!-- one --></b><c/><!--last--></a>`,
+		`<p>Hello <!-- greeting --><b>world</b>!</p>`,
+	}
+	for _, doc := range tests {
+		node, err := ParseWithOptions(doc, WithComments())
+		if err != nil {
+			t.Fatalf("%s: %v", doc, err)
+		}
+		_, root, _ := parseDocument(parser.NewParser(doc), newParseOptions(nil))
+		got, err := RenderWithOptions(node, MarshalOptions{RootName: root})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != doc {
+			t.Errorf("Render\ngot  %s\nwant %s", got, doc)
+		}
+
+		var v interface{}
+		if err := UnmarshalWithOptions([]byte(doc), &v, WithComments(), WithPreserveOrder()); err != nil {
+			t.Fatal(err)
+		}
+		out, err := MarshalWithOptions(v, MarshalOptions{RootName: root})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != doc {
+			t.Errorf("OrderedMap\ngot  %s\nwant %s", out, doc)
+		}
+	}
+}
+
+func TestWithComments_Indent(t *testing.T) {
+	input := "<!-- app -->\n<config>\n  <!-- seconds -->\n  <timeout>30</timeout>\n  <name>x</name>\n</config>\n"
+	cfg, err := ParseElementWithOptions(input, WithComments())
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeout, _ := cfg.GetChild("timeout")
+	timeout.Text("60")
+	out, err := cfg.XMLIndent("config", "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<!-- app -->\n<root>\n  <!-- seconds -->\n  <timeout>60</timeout>\n  <name>x</name>\n</root>"
+	if out != want {
+		t.Errorf("XMLIndent\ngot  %q\nwant %q", out, want)
+	}
+}
+
+func TestWithComments_Content(t *testing.T) {
+	elem, err := ParseElementWithOptions(`<r><a/><!-- note --><b/></r>`, WithComments())
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := elem.Content()
+	if len(nodes) != 3 || nodes[0].Name != "a" || !nodes[1].Comment || nodes[1].Text != " note " || nodes[2].Name != "b" {
+		t.Fatalf("Content = %+v", nodes)
+	}
+
+	elem.SetContent(ContentNode{Text: "bad -- text-", Comment: true}, ContentNode{Name: "c", Element: NewElement()})
+	out, _ := elem.XML("r")
+	if want := `<root><!--bad - - text- --><c/></root>`; out != want {
+		t.Errorf("XML = %s, want %s", out, want)
+	}
+}
+
+func TestWithComments_Default(t *testing.T) {
+	doc := `<!-- x --><r><!-- y --><a>1</a></r>`
+	elem, err := ParseElement(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := elem.XML("r"); out != `<root><a>1</a></root>` {
+		t.Errorf("comments kept by default: %s", out)
+	}
+	if _, err := ParseElementWithOptions(doc, WithComments(), WithMaxDepth(1, DepthSummaryRaw)); err == nil || !strings.Contains(err.Error(), "WithComments") {
+		t.Errorf("WithMaxDepth error = %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package xml
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type computedOrder struct {
+	ID    string `xml:"id,attr"`
+	Total int    `xml:"total,attr"`
+	Qty   []int  `xml:"qty"`
+}
+
+func (o computedOrder) XMLAttr_total() string {
+	sum := 0
+	for _, q := range o.Qty {
+		sum += q
+	}
+	return strconv.Itoa(sum)
+}
+
+func (o computedOrder) XMLAttr_note() string {
+	if o.ID == "" {
+		return ""
+	}
+	return "order <" + o.ID + ">"
+}
+
+type computedBadSig struct{}
+
+func (computedBadSig) XMLAttr_n() int { return 1 }
+
+func TestMarshal_ComputedAttr(t *testing.T) {
+	o := computedOrder{ID: "7", Total: 99, Qty: []int{1, 2}}
+	data, err := Marshal(o)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `<computedOrder id="7" note="order &lt;7&gt;" total="3"><qty>1</qty><qty>2</qty></computedOrder>`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+
+	// Through a pointer and nested, an empty result is omitted.
+	data, err = Marshal(struct {
+		Order *computedOrder `xml:"order"`
+	}{&computedOrder{}})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `<order total="0"/>`) {
+		t.Errorf("got %s", data)
+	}
+
+	// The replaced field receives the computed value when decoding.
+	var back computedOrder
+	if err := Unmarshal([]byte(want), &back); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if back.Total != 3 {
+		t.Errorf("Total = %d, want 3", back.Total)
+	}
+}
+
+func TestMarshal_ComputedAttrSignature(t *testing.T) {
+	_, err := Marshal(computedBadSig{})
+	if err == nil || !strings.Contains(err.Error(), "must have signature func() string") {
+		t.Errorf("expected a signature error, got %v", err)
+	}
+}
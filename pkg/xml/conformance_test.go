@@ -0,0 +1,283 @@
+//go:build xmlconf
+
+package xml
+
+// The W3C XML Conformance Test Suite runner. It is not part of the regular
+// test run; download and unpack the suite from
+// https://www.w3.org/XML/Test/ and run
+//
+//	go test -tags xmlconf -run TestConformance -v ./pkg/xml/ -xmlconf=/path/to/xmlconf
+//
+// or `make conformance XMLCONF=/path/to/xmlconf`. The runner reports pass
+// rates of Validate and Parse per test type and per suite. With
+// -xmlconf.update (`make conformance-update`) it records the tests that
+// pass in the baseline file, testdata/xmlconf-baseline.txt; later runs fail
+// if any of those tests fails, which guards against regressions. The
+// baseline file must exist unless it is being written.
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	xmlconfDir      = flag.String("xmlconf", os.Getenv("XMLCONF"), "directory of the W3C XML Conformance Test Suite, holding xmlconf.xml")
+	xmlconfBaseline = flag.String("xmlconf.baseline", "testdata/xmlconf-baseline.txt", "file listing the conformance tests that passed before")
+	xmlconfUpdate   = flag.Bool("xmlconf.update", false, "rewrite the baseline file with the tests that pass")
+	xmlconfTimeout  = flag.Duration("xmlconf.timeout", 5*time.Second, "time limit for parsing one test document")
+)
+
+// confTest is one TEST entry of a suite manifest.
+type confTest struct {
+	ID       string
+	Type     string // valid, invalid, not-wf or error
+	Entities string // none, general, parameter or both
+	Suite    string // the PROFILE of the enclosing TESTCASES
+	Path     string
+	Version  string // the XML version the test is for, "1.0" unless stated
+}
+
+// confFunc is a function under test, whose check returns nil for the
+// documents it accepts.
+type confFunc struct {
+	name  string
+	check func(string) error
+}
+
+var confFuncs = []confFunc{
+	{"Validate", Validate},
+	{"Parse", func(s string) error { _, err := Parse(s); return err }},
+}
+
+// confEntityRef matches the external entities through which xmlconf.xml
+// includes the manifest of each suite.
+var confEntityRef = regexp.MustCompile(`<!ENTITY\s+\S+\s+SYSTEM\s+"([^"]+)"\s*>`)
+
+func TestConformance(t *testing.T) {
+	baseline, err := readConfBaseline(*xmlconfBaseline)
+	if os.IsNotExist(err) && *xmlconfUpdate {
+		err = nil
+	}
+	if err != nil {
+		t.Fatalf("reading the baseline: %v (run with -xmlconf.update to write it)", err)
+	}
+	if *xmlconfDir == "" {
+		t.Skip("set -xmlconf or XMLCONF to the W3C XML Conformance Test Suite directory")
+	}
+	tests, err := loadConformanceSuite(*xmlconfDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// counts[function][group] holds passed and run tests.
+	type count struct{ passed, run int }
+	counts := make(map[string]map[string]*count)
+	add := func(fn, group string, ok bool) {
+		if counts[fn] == nil {
+			counts[fn] = make(map[string]*count)
+		}
+		c := counts[fn][group]
+		if c == nil {
+			c = &count{}
+			counts[fn][group] = c
+		}
+		c.run++
+		if ok {
+			c.passed++
+		}
+	}
+
+	var passed []string
+	skipped := 0
+	for _, tc := range tests {
+		// A non-validating parser that loads no external entities must
+		// reject not-wf documents and accept valid and invalid ones;
+		// "error" tests may go either way.
+		if tc.Type == "error" || tc.Version != "1.0" || (tc.Entities != "" && tc.Entities != "none") {
+			skipped++
+			continue
+		}
+		data, err := os.ReadFile(tc.Path)
+		if err != nil {
+			t.Errorf("%s: %v", tc.ID, err)
+			continue
+		}
+		for _, fn := range confFuncs {
+			err := runConfCheck(fn.check, string(data), *xmlconfTimeout)
+			ok := (err == nil) != (tc.Type == "not-wf")
+			add(fn.name, "type "+tc.Type, ok)
+			add(fn.name, "suite "+tc.Suite, ok)
+			add(fn.name, "total", ok)
+			key := fn.name + " " + tc.ID
+			if ok {
+				passed = append(passed, key)
+			} else if baseline[key] {
+				if err == nil {
+					err = errors.New("document accepted")
+				}
+				t.Errorf("%s: regression in %s (%s test): %v", key, tc.Path, tc.Type, err)
+			}
+		}
+	}
+
+	var groups []string
+	for group := range counts[confFuncs[0].name] {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	var report strings.Builder
+	fmt.Fprintf(&report, "W3C XML conformance, %d tests run, %d skipped\n", len(tests)-skipped, skipped)
+	for _, group := range groups {
+		fmt.Fprintf(&report, "  %-60s", group)
+		for _, fn := range confFuncs {
+			c := counts[fn.name][group]
+			fmt.Fprintf(&report, "  %s %4d/%-4d %5.1f%%", fn.name, c.passed, c.run, 100*float64(c.passed)/float64(c.run))
+		}
+		report.WriteByte('\n')
+	}
+	t.Log("\n" + report.String())
+
+	if *xmlconfUpdate {
+		sort.Strings(passed)
+		data := confBaselineHeader + strings.Join(passed, "\n") + "\n"
+		if err := os.WriteFile(*xmlconfBaseline, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// runConfCheck runs check on doc, turning panics and timeouts into errors.
+func runConfCheck(check func(string) error, doc string, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- check(doc)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("no result after %v", timeout)
+	}
+}
+
+// loadConformanceSuite reads the tests of every suite xmlconf.xml in dir
+// includes.
+func loadConformanceSuite(dir string) ([]confTest, error) {
+	master, err := os.ReadFile(filepath.Join(dir, "xmlconf.xml"))
+	if err != nil {
+		return nil, err
+	}
+	var tests []confTest
+	for _, m := range confEntityRef.FindAllSubmatch(master, -1) {
+		manifest := filepath.Join(dir, filepath.FromSlash(string(m[1])))
+		suite, err := loadConfManifest(manifest)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", manifest, err)
+		}
+		tests = append(tests, suite...)
+	}
+	if len(tests) == 0 {
+		return nil, fmt.Errorf("no tests found in %s", dir)
+	}
+	return tests, nil
+}
+
+// loadConfManifest reads the TEST entries of one suite manifest. Test URIs
+// are relative to the manifest and the xml:base of enclosing TESTCASES.
+func loadConfManifest(path string) ([]confTest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	type testcases struct{ base, profile string }
+	stack := []testcases{{base: filepath.Dir(path), profile: filepath.Base(filepath.Dir(path))}}
+	var tests []confTest
+	dec := NewDecoder(bufio.NewReader(f))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return tests, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case StartElement:
+			attrs := make(map[string]string, len(tok.Attr))
+			for _, a := range tok.Attr {
+				attrs[a.Name] = a.Value
+			}
+			top := stack[len(stack)-1]
+			switch tok.Name {
+			case "TESTCASES":
+				next := top
+				if base, ok := attrs["xml:base"]; ok {
+					next.base = filepath.Join(top.base, filepath.FromSlash(base))
+				}
+				if profile, ok := attrs["PROFILE"]; ok {
+					next.profile = profile
+				}
+				stack = append(stack, next)
+			case "TEST":
+				version := attrs["VERSION"]
+				if version == "" {
+					version = "1.0"
+				}
+				if strings.HasPrefix(attrs["RECOMMENDATION"], "XML1.1") {
+					version = "1.1"
+				}
+				tests = append(tests, confTest{
+					ID:       attrs["ID"],
+					Type:     attrs["TYPE"],
+					Entities: attrs["ENTITIES"],
+					Suite:    top.profile,
+					Path:     filepath.Join(top.base, filepath.FromSlash(attrs["URI"])),
+					Version:  version,
+				})
+			}
+		case EndElement:
+			if tok.Name == "TESTCASES" && len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+}
+
+// confBaselineHeader starts the baseline file -xmlconf.update writes.
+const confBaselineHeader = `# W3C XML Conformance Test Suite tests that pass, one "Function ID" per
+# line. Written by "make conformance-update"; TestConformance fails if any
+# of them fails.
+`
+
+// readConfBaseline reads the baseline file, one "Function ID" per line.
+// Blank lines and lines starting with # are ignored.
+func readConfBaseline(path string) (map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	baseline := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			baseline[line] = true
+		}
+	}
+	return baseline, nil
+}
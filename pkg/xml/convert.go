@@ -3,6 +3,7 @@ package xml
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 
 	"github.com/shapestone/shape-core/pkg/ast"
@@ -78,6 +79,81 @@ func NodeToInterface(node ast.SchemaNode) interface{} {
 	}
 }
 
+// NodeToInterfaceWithOptions converts an AST node to native Go types like
+// NodeToInterface, applying the given options. WithSortedKeys returns each
+// element as an *OrderedMap with keys in sorted order; WithUseNumber returns
+// numeric literals as Number; WithArrayHints keeps hinted elements as lists
+// and WithForceList turns the elements on the given paths into lists.
+// WithPreserveOrder returns *OrderedMap elements with keys in source order.
+// WithTypes converts values on the given paths and WithEmptyAsNil turns
+// empty elements into nil.
+//
+// Example:
+//
+//	node, _ := xml.Parse(`<user id="123" active="true"/>`)
+//	data := xml.NodeToInterfaceWithOptions(node, xml.WithSortedKeys())
+//	// data.(*xml.OrderedMap).Keys() is ["@active", "@id"]
+func NodeToInterfaceWithOptions(node ast.SchemaNode, opts ...ParseOption) interface{} {
+	o := newParseOptions(opts)
+	v := NodeToInterface(node)
+	if o.ArrayHints {
+		applyArrayHints(v)
+	}
+	if m, ok := v.(map[string]interface{}); ok && len(o.ForceList) > 0 {
+		// The node does not record the root element's name, so paths
+		// match under any root.
+		newForceList(o.ForceList).apply(m, []string{""})
+	}
+	if m, ok := v.(map[string]interface{}); ok && len(o.Types) > 0 {
+		// Values that fail to convert are left as strings.
+		_ = newTypeMap(o.Types).apply(m, []string{""})
+	}
+	if o.EmptyAsNil {
+		emptyToNil(v)
+	}
+	if o.UseNumber {
+		useNumbers(v)
+	}
+	if o.PreserveOrder {
+		v = sourceOrdered(node, v)
+	} else if o.SortedKeys {
+		v = sortedKeys(v)
+	}
+	return v
+}
+
+// KeyValue is one entry of an OrderedMap, as returned by OrderedMap.Pairs.
+type KeyValue struct {
+	Key   string
+	Value interface{}
+}
+
+// sortedKeys converts every map in v into an *OrderedMap with sorted keys.
+// Slices keep their order; other values are returned unchanged.
+func sortedKeys(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		m := NewOrderedMap()
+		for _, k := range keys {
+			m.Set(k, sortedKeys(val[k]))
+		}
+		return m
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = sortedKeys(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
 // ReleaseTree recursively releases all nodes in an AST tree back to their pools.
 // This should be called when you're completely done with an AST (after conversion,
 // rendering, etc.) to enable node reuse and reduce memory pressure.
@@ -115,7 +191,7 @@ func ReleaseTree(node ast.SchemaNode) {
 // InterfaceToNode converts native Go types to AST nodes for XML.
 //
 // Converts:
-//   - string → *ast.LiteralNode
+//   - string, Number → *ast.LiteralNode
 //   - int, int64, int32, etc → *ast.LiteralNode
 //   - float64, float32 → *ast.LiteralNode
 //   - bool → *ast.LiteralNode
@@ -153,6 +229,12 @@ func InterfaceToNode(v interface{}) (ast.SchemaNode, error) {
 	case string:
 		return ast.NewLiteralNode(val, pos), nil
 
+	// Numbers keep their exact text, raw fragments their markup
+	case Number:
+		return ast.NewLiteralNode(val, pos), nil
+	case RawXML:
+		return ast.NewLiteralNode(val, pos), nil
+
 	// Handle booleans
 	case bool:
 		return ast.NewLiteralNode(val, pos), nil
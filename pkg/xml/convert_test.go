@@ -1,6 +1,7 @@
 package xml
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 
@@ -325,3 +326,70 @@ func TestReleaseTree(t *testing.T) {
 		ReleaseTree(node)
 	})
 }
+
+func TestNodeToInterfaceWithOptions_SortedKeys(t *testing.T) {
+	node, err := Parse(`<user zeta="1" alpha="2" mid="3">text</user>`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	got, ok := NodeToInterfaceWithOptions(node, WithSortedKeys()).(*OrderedMap)
+	if !ok {
+		t.Fatalf("expected *OrderedMap, got %T", NodeToInterfaceWithOptions(node, WithSortedKeys()))
+	}
+	want := []KeyValue{
+		{Key: "#text", Value: "text"},
+		{Key: "@alpha", Value: "2"},
+		{Key: "@mid", Value: "3"},
+		{Key: "@zeta", Value: "1"},
+	}
+	if !reflect.DeepEqual(got.Pairs(), want) {
+		t.Errorf("got %#v\nwant %#v", got.Pairs(), want)
+	}
+
+	// Without options the result is the plain map form.
+	if _, ok := NodeToInterfaceWithOptions(node).(map[string]interface{}); !ok {
+		t.Error("expected map without options")
+	}
+}
+
+func TestUnmarshalWithOptions_SortedKeys(t *testing.T) {
+	input := []byte(`<order id="7"><item sku="b"/><item sku="a"/><total>9.50</total></order>`)
+
+	var first, second interface{}
+	if err := UnmarshalWithOptions(input, &first, WithSortedKeys()); err != nil {
+		t.Fatalf("UnmarshalWithOptions failed: %v", err)
+	}
+	if err := UnmarshalWithOptions(input, &second, WithSortedKeys(), WithUseNumber()); err != nil {
+		t.Fatalf("UnmarshalWithOptions failed: %v", err)
+	}
+
+	om, ok := first.(*OrderedMap)
+	if !ok {
+		t.Fatalf("expected *OrderedMap, got %T", first)
+	}
+	if keys := om.Keys(); !reflect.DeepEqual(keys, []string{"@id", "item", "total"}) {
+		t.Errorf("keys = %v", keys)
+	}
+	js, err := json.Marshal(om)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	want := `{"@id":"7","item":[{"@sku":"b"},{"@sku":"a"}],"total":{"#text":"9.50"}}`
+	if string(js) != want {
+		t.Errorf("got %s\nwant %s", js, want)
+	}
+
+	if v, _ := second.(*OrderedMap).Get("@id"); v != Number("7") {
+		t.Errorf("expected Number with WithUseNumber, got %#v", v)
+	}
+
+	// Typed targets are unaffected.
+	var m map[string]interface{}
+	if err := UnmarshalWithOptions(input, &m, WithSortedKeys()); err != nil {
+		t.Fatalf("UnmarshalWithOptions into map failed: %v", err)
+	}
+	if m["@id"] != "7" {
+		t.Errorf("map target got %#v", m)
+	}
+}
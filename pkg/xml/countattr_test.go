@@ -0,0 +1,96 @@
+package xml
+
+import (
+	"strings"
+	"testing"
+)
+
+type countLine struct {
+	SKU string `xml:"sku,attr"`
+}
+
+type countItems struct {
+	Lines []countLine    `xml:"line,countattr=count"`
+	Tags  *[]string      `xml:"tag,omitempty,countattr=tags"`
+	Meta  map[string]int `xml:"meta,countattr=metas"`
+	Pair  [2]int         `xml:"pair,countattr=pairs"`
+	Label string         `xml:"label,attr"`
+}
+
+type countReport struct {
+	Items countItems `xml:"items"`
+}
+
+func TestMarshal_CountAttr(t *testing.T) {
+	tags := []string{"a"}
+	r := countReport{Items: countItems{
+		Lines: []countLine{{SKU: "x"}, {SKU: "y"}, {SKU: "z"}},
+		Tags:  &tags,
+		Label: "L",
+	}}
+	data, err := Marshal(r)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	got := string(data)
+	// Attributes are sorted by name, count attributes included.
+	if !strings.HasPrefix(got, `<countReport><items count="3" label="L" metas="0" pairs="2" tags="1">`) {
+		t.Errorf("got %s", got)
+	}
+	if strings.Count(got, "<line ") != 3 {
+		t.Errorf("lines not encoded: %s", got)
+	}
+
+	// Empty and nil fields count as zero.
+	data, err = Marshal(countItems{})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.HasPrefix(string(data), `<countItems count="0" metas="0" pairs="2" tags="0">`) {
+		t.Errorf("got %s", data)
+	}
+}
+
+func TestMarshal_CountAttrRoundTrip(t *testing.T) {
+	type items struct {
+		Count int         `xml:"count,attr"`
+		Lines []countLine `xml:"line,countattr=count"`
+	}
+	data, err := Marshal(items{Lines: []countLine{{SKU: "a"}, {SKU: "b"}}})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if want := `<items count="2"><line sku="a"/><line sku="b"/></items>`; string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+	var back items
+	if err := Unmarshal(data, &back); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if back.Count != 2 || len(back.Lines) != 2 {
+		t.Errorf("got %+v from %s", back, data)
+	}
+}
+
+func TestMarshal_CountAttrErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"not a collection", struct {
+			N int `xml:"n,countattr=count"`
+		}{}, "countattr needs a slice, array or map"},
+		{"bad name", struct {
+			L []int `xml:"l,countattr=1st"`
+		}{}, `invalid countattr name "1st"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Marshal(tt.v)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,778 @@
+package xml
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shapestone/shape-xml/internal/charset"
+	"github.com/shapestone/shape-xml/internal/dtd"
+	"github.com/shapestone/shape-xml/internal/intern"
+)
+
+// Decoder reads XML from an input stream in a single forward pass, holding
+// only the currently open elements in memory. It is intended for documents
+// too large for Parse or Unmarshal:
+//
+//	dec := xml.NewDecoder(file)
+//	for {
+//	    total, err := dec.Match("/orders/order/total")
+//	    if err == io.EOF {
+//	        break
+//	    }
+//	    if err != nil {
+//	        return err
+//	    }
+//	    fmt.Println(total)
+//	}
+//
+// For full control, Token returns the input one token at a time and
+// DecodeElement decodes a selected element into a Go value.
+//
+// A Decoder is not safe for concurrent use.
+type Decoder struct {
+	r *bufio.Reader
+	// charset converts the input to UTF-8, see SetCharsetReader.
+	charset *charset.Reader
+	offset  int64
+	err     error
+
+	// open holds the names of the currently open elements.
+	open []string
+	// openAt holds the input offsets of their start tags, -1 if unknown.
+	openAt []int64
+	// sawRoot is set once the root element's start tag has been read.
+	sawRoot bool
+	// pendingEnd is set after a self-closing tag, whose end token is
+	// returned by the next call to readToken.
+	pendingEnd bool
+
+	// matcher is the active streaming query, see Match.
+	matcher *streamMatcher
+	// emitMarkup makes scanToken return comments and processing
+	// instructions instead of skipping them, see Token.
+	emitMarkup bool
+	// doctype holds the entities declared by the DOCTYPE, if any, and
+	// doctypeSrc the declaration itself, kept for checkpoints.
+	doctype    *dtd.Doctype
+	doctypeSrc []byte
+	// external is an external subset whose declarations are added to the
+	// DOCTYPE's, see DTD.Validate.
+	external []byte
+	// normalizeAttrs makes readAttr replace literal tabs and line breaks in
+	// attribute values with spaces, as XML 1.0 attribute-value
+	// normalization requires; character references are kept. A "\r\n"
+	// pair becomes a single space.
+	normalizeAttrs bool
+	// rawNewlines turns off end-of-line normalization, see SetRawNewlines.
+	rawNewlines bool
+	// names supplies element and attribute names, see SetInternPool.
+	names *intern.Pool
+
+	// line counts the line breaks read so far, and lineStart is the offset
+	// just after the last one; prevLineStart is the one before, restored
+	// when unreadByte puts a line break back.
+	line                     int
+	lineStart, prevLineStart int64
+	// tokenPos is the position at which the last token read began, see
+	// ParseEvents.
+	tokenPos Position
+
+	// cont and wide count the UTF-8 continuation bytes and four-byte
+	// characters read, and last is the last byte read, so that
+	// checkpoints can map offsets to the encoded input, see rawOffset.
+	cont, wide int64
+	last       byte
+	// start and rawStart are the offsets at which reading began, in the
+	// UTF-8 form and in the input; they are 0 unless resumed.
+	start, rawStart int64
+}
+
+// NewDecoder returns a new Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return newDecoder(charset.NewReader(r))
+}
+
+func newDecoder(cr *charset.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReaderSize(cr, 64*1024), charset: cr}
+}
+
+// InputOffset returns the number of bytes of the document's UTF-8 form
+// consumed so far. For input in another encoding, such as UTF-16 or
+// ISO-8859-1, it counts the bytes after conversion to UTF-8, not the
+// bytes read from the io.Reader.
+func (d *Decoder) InputOffset() int64 {
+	return d.offset
+}
+
+// rawOffset returns the offset in the encoded input that corresponds to
+// InputOffset. ok is false if there is none, see charset.Reader.InputOffset.
+func (d *Decoder) rawOffset() (int64, bool) {
+	n, ok := d.charset.InputOffset(d.offset-d.start, d.cont, d.wide)
+	return d.rawStart + n, ok
+}
+
+// count records the multi-byte characters in b, which was just read.
+func (d *Decoder) count(b []byte) {
+	for _, c := range b {
+		if c >= 0x80 {
+			d.countByte(c, 1)
+		}
+	}
+}
+
+// countByte adds delta to the counter the non-ASCII byte c belongs to.
+func (d *Decoder) countByte(c byte, delta int64) {
+	switch {
+	case c < 0xC0:
+		d.cont += delta
+	case c >= 0xF0:
+		d.wide += delta
+	}
+}
+
+// OpenElement is an element whose start tag a Decoder has read but whose
+// end tag it has not, see Decoder.Stack.
+type OpenElement struct {
+	// Name is the element name.
+	Name string
+	// Offset is the input offset of the '<' of the start tag, or -1 for
+	// elements of a decoder resumed from a checkpoint that did not record
+	// it.
+	Offset int64
+}
+
+// Stack returns the open elements, outermost first. After Token returns a
+// StartElement, the element it starts is last; after an EndElement, the
+// element it ends is gone. In the callbacks of DecodeEach, the stack holds
+// the ancestors of the record. The result is a copy, so it can be kept,
+// for example to log where a problem occurred:
+//
+//	for _, el := range dec.Stack() {
+//	    log.Printf("  in <%s> at offset %d", el.Name, el.Offset)
+//	}
+func (d *Decoder) Stack() []OpenElement {
+	stack := make([]OpenElement, len(d.open))
+	for i, name := range d.open {
+		stack[i] = OpenElement{Name: name, Offset: d.openAt[i]}
+	}
+	return stack
+}
+
+// Path returns the names of the open elements as a path, such as
+// "/orders/order/total", or "" when no element is open.
+func (d *Decoder) Path() string {
+	if len(d.open) == 0 {
+		return ""
+	}
+	return "/" + strings.Join(d.open, "/")
+}
+
+// maxEntityNameLen bounds the length of an entity or character reference name.
+const maxEntityNameLen = 32
+
+// tokenKind identifies the type of a rawToken.
+type tokenKind int
+
+const (
+	tokenStart    tokenKind = iota // start tag; self-closing tags are followed by tokenEnd
+	tokenEnd                       // end tag
+	tokenText                      // character data with entities decoded
+	tokenCDATA                     // CDATA section content
+	tokenComment                   // comment text, only when emitMarkup is set
+	tokenProcInst                  // processing instruction, only when emitMarkup is set
+)
+
+// rawAttr is an attribute of a start tag, with entities decoded.
+type rawAttr struct {
+	name  string
+	value string
+}
+
+// rawToken is one unit of the input returned by readToken. For processing
+// instructions name holds the target and text the instruction.
+type rawToken struct {
+	kind  tokenKind
+	name  string
+	attrs []rawAttr
+	text  string
+}
+
+// readToken returns the next start tag, end tag, text or CDATA token.
+// Comments, processing instructions, the XML declaration and the document
+// type declaration are skipped, as is whitespace outside the root element.
+// It returns io.EOF after the root element has been closed and the input is
+// exhausted.
+func (d *Decoder) readToken() (rawToken, error) {
+	if d.err != nil {
+		return rawToken{}, d.err
+	}
+	tok, err := d.scanToken()
+	if err != nil {
+		d.err = err
+	}
+	return tok, err
+}
+
+func (d *Decoder) scanToken() (rawToken, error) {
+	if d.pendingEnd {
+		d.pendingEnd = false
+		return d.closeElement(d.open[len(d.open)-1])
+	}
+
+	if d.offset == 0 {
+		d.consume(charset.ByteOrderMark)
+	}
+	for {
+		d.tokenPos = d.position()
+		b, err := d.readByte()
+		if err == io.EOF {
+			switch {
+			case len(d.open) > 0:
+				return rawToken{}, d.syntaxError(fmt.Sprintf("unexpected end of input, expected closing tag for %q", d.open[len(d.open)-1]))
+			case !d.sawRoot:
+				return rawToken{}, d.syntaxError("no root element")
+			}
+			return rawToken{}, io.EOF
+		}
+		if err != nil {
+			return rawToken{}, err
+		}
+
+		if b != '<' {
+			d.unreadByte()
+			text, err := d.readText()
+			if err != nil {
+				return rawToken{}, err
+			}
+			if len(d.open) == 0 {
+				if strings.TrimSpace(text) != "" {
+					return rawToken{}, d.syntaxError("character data outside the root element")
+				}
+				continue
+			}
+			return rawToken{kind: tokenText, text: text}, nil
+		}
+
+		b, err = d.readByte()
+		if err != nil {
+			return rawToken{}, d.eofError(err, "tag")
+		}
+		switch b {
+		case '/':
+			return d.readEndTag()
+		case '?':
+			if d.emitMarkup {
+				return d.readProcInst()
+			}
+			if err := d.skipUntil("?>", "processing instruction"); err != nil {
+				return rawToken{}, err
+			}
+		case '!':
+			tok, ok, err := d.readMarkupDecl()
+			if err != nil {
+				return rawToken{}, err
+			}
+			if ok {
+				return tok, nil
+			}
+		default:
+			d.unreadByte()
+			return d.readStartTag()
+		}
+	}
+}
+
+// readStartTag reads a start tag after its '<'.
+func (d *Decoder) readStartTag() (rawToken, error) {
+	start := d.offset - 1
+	if d.sawRoot && len(d.open) == 0 {
+		return rawToken{}, d.syntaxError("element after the root element")
+	}
+	name, err := d.readName()
+	if err != nil {
+		return rawToken{}, err
+	}
+
+	tok := rawToken{kind: tokenStart, name: name}
+	for {
+		if err := d.skipSpace(); err != nil {
+			return rawToken{}, d.eofError(err, "start tag")
+		}
+		b, err := d.readByte()
+		if err != nil {
+			return rawToken{}, d.eofError(err, "start tag")
+		}
+		switch b {
+		case '>':
+			d.openElement(name, start)
+			return tok, nil
+		case '/':
+			if b, err = d.readByte(); err != nil || b != '>' {
+				return rawToken{}, d.syntaxError(fmt.Sprintf("expected '>' after '/' in element %q", name))
+			}
+			d.openElement(name, start)
+			d.pendingEnd = true
+			return tok, nil
+		}
+		d.unreadByte()
+
+		attr, err := d.readAttr()
+		if err != nil {
+			return rawToken{}, err
+		}
+		for _, existing := range tok.attrs {
+			if existing.name == attr.name {
+				return rawToken{}, d.syntaxError(fmt.Sprintf("duplicate attribute %q in element %q", attr.name, name))
+			}
+		}
+		tok.attrs = append(tok.attrs, attr)
+	}
+}
+
+// readAttr reads name="value" inside a start tag.
+func (d *Decoder) readAttr() (rawAttr, error) {
+	name, err := d.readName()
+	if err != nil {
+		return rawAttr{}, err
+	}
+	if err := d.skipSpace(); err != nil {
+		return rawAttr{}, d.eofError(err, "attribute")
+	}
+	if b, err := d.readByte(); err != nil || b != '=' {
+		return rawAttr{}, d.syntaxError(fmt.Sprintf("expected '=' after attribute name %q", name))
+	}
+	if err := d.skipSpace(); err != nil {
+		return rawAttr{}, d.eofError(err, "attribute")
+	}
+	quote, err := d.readByte()
+	if err != nil || (quote != '"' && quote != '\'') {
+		return rawAttr{}, d.syntaxError(fmt.Sprintf("expected quoted value for attribute %q", name))
+	}
+
+	var buf bytes.Buffer
+	for {
+		b, err := d.readByte()
+		if err != nil {
+			return rawAttr{}, d.eofError(err, "attribute value")
+		}
+		switch b {
+		case quote:
+			return rawAttr{name: name, value: buf.String()}, nil
+		case '<':
+			return rawAttr{}, d.syntaxError(fmt.Sprintf("'<' in value of attribute %q", name))
+		case '&':
+			if err := d.readEntity(&buf); err != nil {
+				return rawAttr{}, err
+			}
+		case '\t', '\n', '\r':
+			if b == '\r' && !d.rawNewlines {
+				d.consume("\n")
+				b = '\n'
+			}
+			if !d.normalizeAttrs {
+				buf.WriteByte(b)
+				continue
+			}
+			buf.WriteByte(' ')
+		default:
+			buf.WriteByte(b)
+		}
+	}
+}
+
+// readEndTag reads an end tag after its "</".
+func (d *Decoder) readEndTag() (rawToken, error) {
+	start := d.offset - 2
+	name, err := d.readName()
+	if err != nil {
+		return rawToken{}, err
+	}
+	if err := d.skipSpace(); err != nil {
+		return rawToken{}, d.eofError(err, "end tag")
+	}
+	if b, err := d.readByte(); err != nil || b != '>' {
+		return rawToken{}, d.syntaxError(fmt.Sprintf("expected '>' in closing tag for element %q", name))
+	}
+	if len(d.open) == 0 {
+		return rawToken{}, d.syntaxError(fmt.Sprintf("unexpected closing tag %q", name))
+	}
+	if open := d.open[len(d.open)-1]; open != name {
+		return rawToken{}, d.mismatchedTags(name, start)
+	}
+	return d.closeElement(name)
+}
+
+// readProcInst reads a processing instruction after its "<?".
+func (d *Decoder) readProcInst() (rawToken, error) {
+	start := d.offset
+	text, err := d.readUntil("?>", "processing instruction")
+	if err != nil {
+		return rawToken{}, err
+	}
+	target, inst := text, ""
+	if i := strings.IndexAny(text, " \t\r\n"); i >= 0 {
+		target, inst = text[:i], strings.TrimLeft(text[i:], " \t\r\n")
+	}
+	if !isValidXMLName(target) {
+		return rawToken{}, d.syntaxErrorAt(fmt.Errorf("invalid processing instruction target %q", target), start)
+	}
+	return rawToken{kind: tokenProcInst, name: target, text: d.newlines(inst)}, nil
+}
+
+// readMarkupDecl reads the markup after "<!": a comment (skipped, ok is
+// false, unless emitMarkup is set), a DOCTYPE (never returned) or a CDATA
+// section.
+func (d *Decoder) readMarkupDecl() (tok rawToken, ok bool, err error) {
+	switch {
+	case d.consume("--"):
+		if d.emitMarkup {
+			text, err := d.readUntil("-->", "comment")
+			if err != nil {
+				return rawToken{}, false, err
+			}
+			return rawToken{kind: tokenComment, text: d.newlines(text)}, true, nil
+		}
+		return rawToken{}, false, d.skipUntil("-->", "comment")
+	case d.consume("[CDATA["):
+		if len(d.open) == 0 {
+			return rawToken{}, false, d.syntaxError("CDATA section outside the root element")
+		}
+		text, err := d.readUntil("]]>", "CDATA section")
+		if err != nil {
+			return rawToken{}, false, err
+		}
+		return rawToken{kind: tokenCDATA, text: d.newlines(text)}, true, nil
+	case d.consume("DOCTYPE"):
+		return rawToken{}, false, d.readDoctype()
+	default:
+		return rawToken{}, false, d.syntaxError("invalid markup declaration")
+	}
+}
+
+// readDoctype reads a DOCTYPE declaration, including an internal subset,
+// and keeps the entities it declares for readEntity. The declaration is
+// parsed by dtd.Parse, as in the fast parser, at each '>' until one ends
+// it.
+func (d *Decoder) readDoctype() error {
+	start := d.offset - int64(len("<!DOCTYPE"))
+	buf := []byte("<!DOCTYPE")
+	for {
+		b, err := d.readByte()
+		if err != nil {
+			return d.eofError(err, "DOCTYPE")
+		}
+		buf = append(buf, b)
+		if b != '>' {
+			continue
+		}
+		doctype, n, err := dtd.Parse(buf)
+		if err != nil && n == len(buf) {
+			continue // the '>' is inside the declaration
+		}
+		if err != nil {
+			// The declaration may span lines: count back from the end.
+			pos := Position{Offset: start + int64(n), Line: d.line + 1 - bytes.Count(buf[n:], []byte{'\n'})}
+			if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+				pos.Column = n - i
+			} else {
+				pos.Column = d.tokenPos.Column + n
+			}
+			return d.syntaxErrorPos(fmt.Errorf("in DOCTYPE: %w", err), pos)
+		}
+		if d.external != nil {
+			if err := doctype.ParseExternal(d.external); err != nil {
+				return fmt.Errorf("xml: in external DTD %v", err)
+			}
+		}
+		d.doctype, d.doctypeSrc = doctype, buf
+		return nil
+	}
+}
+
+// readText reads character data up to the next '<' or the end of input.
+func (d *Decoder) readText() (string, error) {
+	var buf bytes.Buffer
+	for {
+		b, err := d.readByte()
+		if err == io.EOF {
+			return buf.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case '<':
+			d.unreadByte()
+			return buf.String(), nil
+		case '&':
+			if err := d.readEntity(&buf); err != nil {
+				return "", err
+			}
+		case '\r':
+			if d.rawNewlines {
+				buf.WriteByte(b)
+				continue
+			}
+			d.consume("\n")
+			buf.WriteByte('\n')
+		default:
+			buf.WriteByte(b)
+		}
+	}
+}
+
+// newlines returns s, read from a comment, CDATA section or processing
+// instruction, with its line ends normalized unless rawNewlines is set.
+func (d *Decoder) newlines(s string) string {
+	if d.rawNewlines || strings.IndexByte(s, '\r') < 0 {
+		return s
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\r", "\n")
+}
+
+// readEntity decodes an entity or character reference after its '&'.
+func (d *Decoder) readEntity(buf *bytes.Buffer) error {
+	start := d.offset - 1
+	var name []byte
+	for {
+		b, err := d.readByte()
+		if err != nil {
+			return d.eofError(err, "entity reference")
+		}
+		if b == ';' {
+			break
+		}
+		if len(name) >= maxEntityNameLen || isSpace(b) || b == '<' || b == '&' {
+			return d.syntaxErrorAt(errors.New("unterminated entity reference"), start)
+		}
+		name = append(name, b)
+	}
+	ref := string(name)
+	switch ref {
+	case "lt":
+		buf.WriteByte('<')
+	case "gt":
+		buf.WriteByte('>')
+	case "amp":
+		buf.WriteByte('&')
+	case "apos":
+		buf.WriteByte('\'')
+	case "quot":
+		buf.WriteByte('"')
+	default:
+		if text, ok, err := d.doctype.Expand(ref); err != nil {
+			return d.syntaxErrorAt(fmt.Errorf("in entity reference &%s;: %w", ref, err), start)
+		} else if ok {
+			buf.WriteString(text)
+			return nil
+		}
+		r, ok := charset.CharRef(ref)
+		if !ok {
+			return d.syntaxErrorAt(fmt.Errorf("invalid entity reference &%s;", ref), start)
+		}
+		if !charset.IsChar(r) {
+			switch d.charset.Mode {
+			case charset.Reject:
+				return d.syntaxErrorAt(charset.InvalidRefError(ref, r), start)
+			case charset.Replace:
+				buf.WriteRune(utf8.RuneError)
+			}
+			return nil
+		}
+		buf.WriteRune(r)
+	}
+	return nil
+}
+
+// readName reads an XML name.
+func (d *Decoder) readName() (string, error) {
+	start := d.offset
+	var buf []byte
+	for {
+		b, err := d.readByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if isSpace(b) || b == '/' || b == '>' || b == '=' || b == '<' {
+			d.unreadByte()
+			break
+		}
+		buf = append(buf, b)
+	}
+	var name string
+	if d.names != nil {
+		name = d.names.Intern(buf)
+	} else {
+		name = string(buf)
+	}
+	if !isValidXMLName(name) {
+		return "", d.syntaxErrorAt(fmt.Errorf("invalid name %q", name), start)
+	}
+	return name, nil
+}
+
+// openElement pushes a start tag at offset start onto the element stack.
+func (d *Decoder) openElement(name string, start int64) {
+	d.open = append(d.open, name)
+	d.openAt = append(d.openAt, start)
+	d.sawRoot = true
+}
+
+// closeElement pops the innermost element and returns its end token.
+func (d *Decoder) closeElement(name string) (rawToken, error) {
+	d.open = d.open[:len(d.open)-1]
+	d.openAt = d.openAt[:len(d.open)]
+	return rawToken{kind: tokenEnd, name: name}, nil
+}
+
+// mismatchedTags returns the error for an end tag named name, starting at
+// offset end, that does not match the innermost open element. The error is
+// located at the end tag; the message gives the offset of the start tag and
+// the open elements as a path.
+func (d *Decoder) mismatchedTags(name string, end int64) error {
+	open := d.open[len(d.open)-1]
+	at := ""
+	if start := d.openAt[len(d.openAt)-1]; start >= 0 {
+		at = fmt.Sprintf(" at offset %d", start)
+	}
+	return d.syntaxErrorAt(fmt.Errorf("mismatched tags: opening %q%s, closing %q; open elements: %s",
+		open, at, name, d.Path()), end)
+}
+
+// skipSpace skips XML whitespace.
+func (d *Decoder) skipSpace() error {
+	for {
+		b, err := d.readByte()
+		if err != nil {
+			return err
+		}
+		if !isSpace(b) {
+			d.unreadByte()
+			return nil
+		}
+	}
+}
+
+// consume reads s if the input continues with it.
+func (d *Decoder) consume(s string) bool {
+	peek, err := d.r.Peek(len(s))
+	if err != nil || string(peek) != s {
+		return false
+	}
+	d.count(peek)
+	d.r.Discard(len(s))
+	d.offset += int64(len(s))
+	return true
+}
+
+// skipUntil discards input up to and including delim.
+func (d *Decoder) skipUntil(delim, what string) error {
+	_, err := d.readUntil(delim, what)
+	return err
+}
+
+// readUntil returns the input up to delim and consumes the delimiter.
+func (d *Decoder) readUntil(delim, what string) (string, error) {
+	var buf []byte
+	last := delim[len(delim)-1]
+	for {
+		chunk, err := d.r.ReadSlice(last)
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			d.line += bytes.Count(chunk, []byte{'\n'})
+			d.prevLineStart, d.lineStart = d.lineStart, d.offset+int64(i)+1
+		}
+		d.offset += int64(len(chunk))
+		d.count(chunk)
+		buf = append(buf, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", d.eofError(err, what)
+		}
+		if bytes.HasSuffix(buf, []byte(delim)) {
+			return string(buf[:len(buf)-len(delim)]), nil
+		}
+	}
+}
+
+func (d *Decoder) readByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err == nil {
+		d.offset++
+		d.last = b
+		if b >= 0x80 {
+			d.countByte(b, 1)
+		}
+		if b == '\n' {
+			d.line++
+			d.prevLineStart, d.lineStart = d.lineStart, d.offset
+		}
+	}
+	return b, err
+}
+
+func (d *Decoder) unreadByte() {
+	if d.r.UnreadByte() == nil {
+		d.offset--
+		if d.last >= 0x80 {
+			d.countByte(d.last, -1)
+		}
+		if d.lineStart > d.offset {
+			d.line--
+			d.lineStart = d.prevLineStart
+		}
+	}
+}
+
+// position returns the current position in the input.
+func (d *Decoder) position() Position {
+	return Position{Offset: d.offset, Line: d.line + 1, Column: int(d.offset-d.lineStart) + 1}
+}
+
+// syntaxError returns a *SyntaxError describing malformed input at the
+// current offset.
+func (d *Decoder) syntaxError(msg string) error {
+	return d.syntaxErrorPos(errors.New(msg), d.position())
+}
+
+// syntaxErrorAt returns err as a *SyntaxError at offset, which lies in the
+// token being read on the current or the previous line.
+func (d *Decoder) syntaxErrorAt(err error, offset int64) error {
+	pos := Position{Offset: offset, Line: d.line + 1, Column: int(offset-d.lineStart) + 1}
+	if offset < d.lineStart {
+		pos.Line, pos.Column = d.line, int(offset-d.prevLineStart)+1
+	}
+	return d.syntaxErrorPos(err, pos)
+}
+
+// syntaxErrorPos returns err as a *SyntaxError at pos, inside the innermost
+// open element.
+func (d *Decoder) syntaxErrorPos(err error, pos Position) error {
+	var element string
+	if n := len(d.open); n > 0 {
+		element = d.open[n-1]
+	}
+	return &SyntaxError{Err: err, Offset: int(pos.Offset), Line: pos.Line, Column: pos.Column, Element: element}
+}
+
+// eofError converts io.EOF inside a construct into a syntax error.
+func (d *Decoder) eofError(err error, what string) error {
+	if err == io.EOF {
+		return d.syntaxError("unexpected end of input in " + what)
+	}
+	return err
+}
+
+// isSpace reports whether b is XML whitespace.
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
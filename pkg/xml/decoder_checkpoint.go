@@ -0,0 +1,257 @@
+package xml
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/shapestone/shape-xml/internal/charset"
+	"github.com/shapestone/shape-xml/internal/dtd"
+)
+
+// Checkpoint is a snapshot of a Decoder's position: the input offset, the
+// stack of open elements, the line number, the encoding of the input, the
+// DOCTYPE whose entities are in use and, if Match is in use, the matching
+// state. A
+// Checkpoint taken between calls to Match can be persisted and later passed
+// to ResumeDecoder to continue reading a seekable input where it left off,
+// so long batch jobs can recover from restarts without reprocessing.
+//
+// Checkpoint implements encoding.BinaryMarshaler and encoding.TextMarshaler,
+// so it can be stored with encoding/gob, encoding/json or as raw bytes.
+type Checkpoint struct {
+	state checkpointState
+}
+
+// checkpointState holds the persisted fields of a Checkpoint. Fields are
+// exported for encoding/gob only.
+type checkpointState struct {
+	Offset     int64
+	Stack      []string
+	SawRoot    bool
+	PendingEnd bool
+
+	// StackOffsets holds the offsets of the start tags in Stack; it is
+	// missing from checkpoints of earlier versions.
+	StackOffsets []int64
+
+	// RawOffset is the offset in the encoded input that Offset, counted
+	// in the UTF-8 form, corresponds to, and Encoding the encoding of the
+	// input, "" for UTF-8. Line and LineStart locate Offset as in
+	// Position. Doctype is the DOCTYPE declaration read, if any, and
+	// InvalidChars the mode set by SetInvalidChars. These are missing from
+	// checkpoints of earlier versions, which resume UTF-8 input at Offset.
+	RawOffset    int64
+	Encoding     string
+	Line         int
+	LineStart    int64
+	Doctype      []byte
+	InvalidChars int
+
+	// Query is the expression of the active Match, "" if none.
+	Query  string
+	Frames []frameState
+	Queue  []string
+
+	// QueryNS is set when Query was compiled by CompileQueryNS with
+	// QueryNamespaces.
+	QueryNS         bool
+	QueryNamespaces map[string]string
+}
+
+// frameState is the persisted form of a matchFrame.
+type frameState struct {
+	States     []int
+	Counts     [][3]int // step, predicate, count
+	Collect    bool
+	Text       string
+	Namespaces map[string]string
+}
+
+// Offset returns the input offset of the checkpoint, as reported by
+// InputOffset when it was taken. For input not encoded in UTF-8 it
+// counts the bytes of the UTF-8 form; ResumeDecoder seeks the input to the
+// corresponding offset in the encoded bytes.
+func (c *Checkpoint) Offset() int64 {
+	return c.state.Offset
+}
+
+// Stack returns the names of the elements open at the checkpoint,
+// outermost first.
+func (c *Checkpoint) Stack() []string {
+	return append([]string(nil), c.state.Stack...)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (c *Checkpoint) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&c.state); err != nil {
+		return nil, fmt.Errorf("xml: encoding checkpoint: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (c *Checkpoint) UnmarshalBinary(data []byte) error {
+	var state checkpointState
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&state); err != nil {
+		return fmt.Errorf("xml: decoding checkpoint: %w", err)
+	}
+	c.state = state
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler using base64.
+func (c *Checkpoint) MarshalText() ([]byte, error) {
+	b, err := c.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, base64.StdEncoding.EncodedLen(len(b)))
+	base64.StdEncoding.Encode(out, b)
+	return out, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *Checkpoint) UnmarshalText(text []byte) error {
+	b := make([]byte, base64.StdEncoding.DecodedLen(len(text)))
+	n, err := base64.StdEncoding.Decode(b, text)
+	if err != nil {
+		return fmt.Errorf("xml: decoding checkpoint: %w", err)
+	}
+	return c.UnmarshalBinary(b[:n])
+}
+
+// Checkpoint returns a snapshot of the decoder's current position. Values
+// that Match has already read but not yet returned are part of the snapshot.
+// It returns an error if the decoder has failed, and if the position in the
+// input cannot be known: for input converted by a CharsetReader, and after
+// SetInvalidChars has stripped or replaced a character.
+func (d *Decoder) Checkpoint() (*Checkpoint, error) {
+	if d.err != nil && d.err != io.EOF {
+		return nil, fmt.Errorf("xml: cannot checkpoint a failed decoder: %w", d.err)
+	}
+	raw, ok := d.rawOffset()
+	if !ok {
+		return nil, errors.New("xml: cannot checkpoint: the input offset is unknown after conversion by a CharsetReader or after invalid characters were stripped or replaced")
+	}
+	state := checkpointState{
+		Offset:     d.offset,
+		Stack:      append([]string(nil), d.open...),
+		SawRoot:    d.sawRoot,
+		PendingEnd: d.pendingEnd,
+
+		StackOffsets: append([]int64(nil), d.openAt...),
+
+		RawOffset:    raw,
+		Encoding:     d.charset.Encoding,
+		Line:         d.line,
+		LineStart:    d.lineStart,
+		Doctype:      d.doctypeSrc,
+		InvalidChars: int(d.charset.Mode),
+	}
+	if m := d.matcher; m != nil {
+		state.Query = m.q.expr
+		state.Queue = append([]string(nil), m.queue...)
+		state.QueryNS, state.QueryNamespaces = m.q.namespaces != nil, m.q.namespaces
+		state.Frames = make([]frameState, len(m.frames))
+		for i := range m.frames {
+			f := &m.frames[i]
+			fs := frameState{
+				States:     append([]int(nil), f.states...),
+				Collect:    f.collect,
+				Text:       f.text.String(),
+				Namespaces: f.ns,
+			}
+			for key, n := range f.counts {
+				fs.Counts = append(fs.Counts, [3]int{key[0], key[1], n})
+			}
+			state.Frames[i] = fs
+		}
+	}
+	return &Checkpoint{state: state}, nil
+}
+
+// ResumeDecoder returns a Decoder that continues from cp. It seeks r to the
+// checkpoint's offset in the encoded input, so r must present the same
+// input the checkpointed Decoder read, with offset 0 at the start of the
+// document. The resumed Decoder reads the input in the same encoding,
+// expands the entities of the same DOCTYPE, reports the same line numbers
+// and treats invalid characters as set by SetInvalidChars. A Match in
+// progress at the checkpoint continues with the same expression.
+func ResumeDecoder(r io.ReadSeeker, cp *Checkpoint) (*Decoder, error) {
+	state := cp.state
+	raw := state.RawOffset
+	if raw == 0 {
+		raw = state.Offset // an earlier version's checkpoint
+	}
+	if _, err := r.Seek(raw, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("xml: resuming decoder: %w", err)
+	}
+
+	var d *Decoder
+	if raw == 0 {
+		d = NewDecoder(r) // nothing read yet: detect the encoding
+	} else {
+		d = newDecoder(charset.NewReaderEncoding(r, state.Encoding))
+	}
+	d.charset.Mode = charset.Mode(state.InvalidChars)
+	if len(state.Doctype) > 0 {
+		doctype, _, err := dtd.Parse(state.Doctype)
+		if err != nil {
+			return nil, fmt.Errorf("xml: corrupt checkpoint: DOCTYPE: %w", err)
+		}
+		d.doctype, d.doctypeSrc = doctype, state.Doctype
+	}
+	d.offset, d.start, d.rawStart = state.Offset, state.Offset, raw
+	d.line, d.lineStart, d.prevLineStart = state.Line, state.LineStart, state.LineStart
+	d.open = append([]string(nil), state.Stack...)
+	d.openAt = append([]int64(nil), state.StackOffsets...)
+	for len(d.openAt) < len(d.open) {
+		d.openAt = append(d.openAt, -1)
+	}
+	d.sawRoot = state.SawRoot
+	d.pendingEnd = state.PendingEnd
+
+	if state.Query != "" {
+		var q *Query
+		var err error
+		if state.QueryNS {
+			q, err = CompileQueryNS(state.Query, state.QueryNamespaces)
+		} else {
+			q, err = CompileQuery(state.Query)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(state.Frames) != len(state.Stack)+1 {
+			return nil, fmt.Errorf("xml: corrupt checkpoint: %d match frames for %d open elements",
+				len(state.Frames), len(state.Stack))
+		}
+		m := &streamMatcher{q: q, queue: append([]string(nil), state.Queue...)}
+		m.frames = make([]matchFrame, len(state.Frames))
+		for i, fs := range state.Frames {
+			f := &m.frames[i]
+			f.states = fs.States
+			f.collect = fs.Collect
+			f.text.WriteString(fs.Text)
+			if q.namespaces != nil {
+				f.ns = fs.Namespaces
+				if f.ns == nil {
+					f.ns = NamespaceContext{}
+				}
+			}
+			if len(fs.Counts) > 0 {
+				f.counts = make(map[[2]int]int, len(fs.Counts))
+				for _, c := range fs.Counts {
+					f.counts[[2]int{c[0], c[1]}] = c[2]
+				}
+			}
+		}
+		d.matcher = m
+	}
+	return d, nil
+}
@@ -0,0 +1,294 @@
+package xml
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestDecoder_CheckpointResume stops after every value, persists the
+// checkpoint as JSON and resumes on a fresh reader; the combined output must
+// equal a single uninterrupted pass.
+func TestDecoder_CheckpointResume(t *testing.T) {
+	for _, expr := range []string{"/orders/order/total", "//order[@status][3]/@id", "//item[2]/@sku", "order/total", "//total"} {
+		want := matchAll(t, queryTestDoc, expr)
+		for stop := 0; stop <= len(want); stop++ {
+			d := NewDecoder(strings.NewReader(queryTestDoc))
+			var got []string
+			for i := 0; i < stop; i++ {
+				v, err := d.Match(expr)
+				if err != nil {
+					t.Fatalf("%s: Match failed: %v", expr, err)
+				}
+				got = append(got, v)
+			}
+
+			cp, err := d.Checkpoint()
+			if err != nil {
+				t.Fatalf("Checkpoint failed: %v", err)
+			}
+			saved, err := json.Marshal(struct{ CP *Checkpoint }{cp})
+			if err != nil {
+				t.Fatalf("json.Marshal failed: %v", err)
+			}
+			var loaded struct{ CP *Checkpoint }
+			if err := json.Unmarshal(saved, &loaded); err != nil {
+				t.Fatalf("json.Unmarshal failed: %v", err)
+			}
+
+			resumed, err := ResumeDecoder(strings.NewReader(queryTestDoc), loaded.CP)
+			if err != nil {
+				t.Fatalf("ResumeDecoder failed: %v", err)
+			}
+			for {
+				v, err := resumed.Match(expr)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("%s: Match after resume failed: %v", expr, err)
+				}
+				got = append(got, v)
+			}
+			if !reflect.DeepEqual(got, want) && !(len(got) == 0 && len(want) == 0) {
+				t.Errorf("%s stopped after %d: got %q, want %q", expr, stop, got, want)
+			}
+		}
+	}
+}
+
+func TestDecoder_CheckpointState(t *testing.T) {
+	input := `<a><b x="1"/><c>text</c></a>`
+	d := NewDecoder(strings.NewReader(input))
+	for i := 0; i < 2; i++ { // <a>, <b/>
+		if _, err := d.readToken(); err != nil {
+			t.Fatalf("readToken failed: %v", err)
+		}
+	}
+
+	cp, err := d.Checkpoint()
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if cp.Offset() != d.InputOffset() || cp.Offset() != int64(len(`<a><b x="1"/>`)) {
+		t.Errorf("Offset() = %d", cp.Offset())
+	}
+	if got := cp.Stack(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Stack() = %q", got)
+	}
+
+	b, err := cp.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	var restored Checkpoint
+	if err := restored.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	resumed, err := ResumeDecoder(strings.NewReader(input), &restored)
+	if err != nil {
+		t.Fatalf("ResumeDecoder failed: %v", err)
+	}
+	var kinds []string
+	for {
+		tok, err := resumed.readToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("readToken after resume failed: %v", err)
+		}
+		kinds = append(kinds, tok.name+tok.text)
+	}
+	// The pending end of the self-closing <b/> comes first.
+	if want := []string{"b", "c", "text", "c", "a"}; !reflect.DeepEqual(kinds, want) {
+		t.Errorf("tokens after resume = %q, want %q", kinds, want)
+	}
+}
+
+func TestDecoder_CheckpointMismatchedTags(t *testing.T) {
+	input := `<a><b><c/></x></a>`
+	d := NewDecoder(strings.NewReader(input))
+	for i := 0; i < 2; i++ { // <a>, <b>
+		if _, err := d.readToken(); err != nil {
+			t.Fatalf("readToken failed: %v", err)
+		}
+	}
+	cp, err := d.Checkpoint()
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+
+	// The resumed decoder still knows where the open elements started.
+	resumed, err := ResumeDecoder(strings.NewReader(input), cp)
+	if err != nil {
+		t.Fatalf("ResumeDecoder failed: %v", err)
+	}
+	want := `line 1, column 11: mismatched tags: opening "b" at offset 3, closing "x"; open elements: /a/b`
+	for {
+		_, err := resumed.readToken()
+		if err != nil {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("got  %v\nwant %s", err, want)
+			}
+			break
+		}
+	}
+
+	// Checkpoints without offsets leave the position out.
+	cp.state.StackOffsets = nil
+	resumed, err = ResumeDecoder(strings.NewReader(input), cp)
+	if err != nil {
+		t.Fatalf("ResumeDecoder failed: %v", err)
+	}
+	want = `line 1, column 11: mismatched tags: opening "b", closing "x"; open elements: /a/b`
+	for {
+		_, err := resumed.readToken()
+		if err != nil {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("got  %v\nwant %s", err, want)
+			}
+			break
+		}
+	}
+}
+
+func TestDecoder_CheckpointErrors(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`<a></b>`))
+	for {
+		if _, err := d.readToken(); err != nil {
+			break
+		}
+	}
+	if _, err := d.Checkpoint(); err == nil {
+		t.Error("expected error checkpointing a failed decoder")
+	}
+
+	var cp Checkpoint
+	if err := cp.UnmarshalText([]byte("not base64!")); err == nil {
+		t.Error("expected error for malformed checkpoint text")
+	}
+	if _, err := ResumeDecoder(failingSeeker{}, &cp); err == nil {
+		t.Error("expected seek error")
+	}
+}
+
+type failingSeeker struct{ io.Reader }
+
+func (failingSeeker) Seek(int64, int) (int64, error) {
+	return 0, errors.New("not seekable")
+}
+
+func TestDecoder_CheckpointResumeEncodedInput(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  []byte
+		want []string
+	}{
+		{
+			"doctype",
+			[]byte("<!DOCTYPE r [<!-- it's --><!ENTITY e \"ent\">]>\n<r>\n<v>&e;1</v>\n<v>&e;2</v>\n<v>&e;3</v>\n</r>"),
+			[]string{"ent1", "ent2", "ent3"},
+		},
+		{
+			"iso-8859-1",
+			[]byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<r>\n<v>caf\xe9</v>\n<v>na\xefve</v>\n<v>\xfcber</v>\n</r>"),
+			[]string{"café", "naïve", "über"},
+		},
+		{
+			"utf-16",
+			utf16LE("<?xml version=\"1.0\" encoding=\"UTF-16\"?>\n<r>\n<v>é😀</v>\n<v>日本</v>\n<v>x</v>\n</r>"),
+			[]string{"é😀", "日本", "x"},
+		},
+	}
+	for _, tt := range tests {
+		for stop := 1; stop < len(tt.want); stop++ {
+			d := NewDecoder(bytes.NewReader(tt.doc))
+			var got []string
+			for i := 0; i < stop; i++ {
+				v, err := d.Match("/r/v")
+				if err != nil {
+					t.Fatalf("%s: Match failed: %v", tt.name, err)
+				}
+				got = append(got, v)
+			}
+			cp, err := d.Checkpoint()
+			if err != nil {
+				t.Fatalf("%s: Checkpoint failed: %v", tt.name, err)
+			}
+			text, err := cp.MarshalText()
+			if err != nil {
+				t.Fatalf("%s: MarshalText failed: %v", tt.name, err)
+			}
+			var loaded Checkpoint
+			if err := loaded.UnmarshalText(text); err != nil {
+				t.Fatalf("%s: UnmarshalText failed: %v", tt.name, err)
+			}
+
+			resumed, err := ResumeDecoder(bytes.NewReader(tt.doc), &loaded)
+			if err != nil {
+				t.Fatalf("%s: ResumeDecoder failed: %v", tt.name, err)
+			}
+			if resumed.position() != d.position() {
+				t.Errorf("%s stopped after %d: resumed at %+v, want %+v", tt.name, stop, resumed.position(), d.position())
+			}
+			for {
+				v, err := resumed.Match("/r/v")
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("%s stopped after %d: Match after resume failed: %v", tt.name, stop, err)
+				}
+				got = append(got, v)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s stopped after %d: got %q, want %q", tt.name, stop, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestDecoder_CheckpointAfterReplacedChars(t *testing.T) {
+	d := NewDecoder(strings.NewReader("<r><v>a\x01</v><v>b</v></r>"))
+	d.SetInvalidChars(InvalidCharsReplace)
+	if _, err := d.Match("/r/v"); err != nil {
+		t.Fatalf("Match failed: %v", err)
+	}
+	if _, err := d.Checkpoint(); err == nil {
+		t.Error("Checkpoint succeeded after a character was replaced")
+	}
+}
+
+func TestDecoder_CheckpointResumeQueryNS(t *testing.T) {
+	q := mustCompileQueryNS(t, "/a:feed/a:entry/media:thumb/@url", NamespaceContext{
+		"a": "http://www.w3.org/2005/Atom", "media": "urn:media",
+	})
+	d := NewDecoder(strings.NewReader(nsFeed))
+	if v, err := d.MatchQuery(q); err != nil || v != "a.png" {
+		t.Fatalf("MatchQuery = %q, %v", v, err)
+	}
+	cp, err := d.Checkpoint()
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	var loaded Checkpoint
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	resumed, err := ResumeDecoder(strings.NewReader(nsFeed), &loaded)
+	if err != nil {
+		t.Fatalf("ResumeDecoder failed: %v", err)
+	}
+	if v, err := resumed.MatchQuery(q); err != nil || v != "b.png" {
+		t.Errorf("MatchQuery after resume = %q, %v", v, err)
+	}
+}
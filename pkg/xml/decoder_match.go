@@ -0,0 +1,241 @@
+package xml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Match reads forward to the next value matched by the path expression expr
+// and returns it. Matching uses the same syntax as CompileQuery, evaluated
+// incrementally while the input is read, so extraction from arbitrarily large
+// documents takes a single pass and memory proportional to the nesting depth.
+//
+// Absolute expressions start at the document root; relative expressions are
+// evaluated from the root element. Matched elements yield their text content
+// (character data and CDATA directly inside the element, with surrounding
+// whitespace trimmed); expressions ending in an attribute step yield
+// attribute values. Values are returned in document order, elements when
+// their end tag is read.
+//
+// Match returns io.EOF once the input is exhausted. A Decoder evaluates one
+// expression over its lifetime; calling Match with a different expression
+// after matching has started returns an error.
+func (d *Decoder) Match(expr string) (string, error) {
+	if d.matcher != nil && d.matcher.q.expr == expr {
+		return d.MatchQuery(d.matcher.q)
+	}
+	q, err := CompileQuery(expr)
+	if err != nil {
+		return "", err
+	}
+	return d.MatchQuery(q)
+}
+
+// MatchQuery is like Match but takes a compiled Query, which avoids parsing
+// the expression when many documents are processed with the same query.
+// Steps of a query compiled by CompileQueryNS match elements by namespace
+// URI and local name, resolved from the xmlns declarations in scope.
+func (d *Decoder) MatchQuery(q *Query) (string, error) {
+	if d.matcher == nil {
+		if d.sawRoot {
+			return "", fmt.Errorf("xml: Match must be called before the root element is read")
+		}
+		d.matcher = newStreamMatcher(q)
+	} else if d.matcher.q.expr != q.expr {
+		return "", fmt.Errorf("xml: Match called with %q after matching %q", q.expr, d.matcher.q.expr)
+	}
+
+	m := d.matcher
+	for len(m.queue) == 0 {
+		tok, err := d.readToken()
+		if err != nil {
+			return "", err
+		}
+		m.feed(tok)
+	}
+	v := m.queue[0]
+	m.queue = m.queue[1:]
+	return v, nil
+}
+
+// streamMatcher evaluates a Query against a token stream. It keeps one frame
+// per open element (plus one for the document) recording which query steps
+// the element's children may match next.
+type streamMatcher struct {
+	q      *Query
+	frames []matchFrame
+	queue  []string
+}
+
+// matchFrame is the matching state of one open element.
+type matchFrame struct {
+	// states holds the positions of the steps that children of this
+	// element are tested against.
+	states []int
+	// counts holds per-(step, predicate) sibling counters for positional predicates.
+	counts map[[2]int]int
+	// collect is set when the element itself matched and its text is wanted.
+	collect bool
+	text    strings.Builder
+	// ns holds the namespace bindings in scope at the element, for
+	// queries compiled with CompileQueryNS. Frames share the map of their
+	// parent unless the element declares namespaces itself.
+	ns NamespaceContext
+}
+
+func newStreamMatcher(q *Query) *streamMatcher {
+	doc := matchFrame{}
+	if q.absolute {
+		doc.states = []int{0}
+	}
+	if q.namespaces != nil {
+		doc.ns = NamespaceContext{"xml": XMLNamespace}
+	}
+	return &streamMatcher{q: q, frames: []matchFrame{doc}}
+}
+
+// feed advances the matcher by one token, queueing any completed values.
+func (m *streamMatcher) feed(tok rawToken) {
+	switch tok.kind {
+	case tokenStart:
+		m.start(tok)
+	case tokenText, tokenCDATA:
+		if top := &m.frames[len(m.frames)-1]; top.collect {
+			top.text.WriteString(tok.text)
+		}
+	case tokenEnd:
+		top := &m.frames[len(m.frames)-1]
+		if top.collect {
+			m.queue = append(m.queue, strings.TrimSpace(top.text.String()))
+		}
+		m.frames = m.frames[:len(m.frames)-1]
+	}
+}
+
+// start pushes the frame for a new element and reports a match.
+func (m *streamMatcher) start(tok rawToken) {
+	steps := m.q.steps
+	parent := &m.frames[len(m.frames)-1]
+	ns := parent.ns
+	if ns != nil {
+		ns = scopeNamespaces(ns, tok.attrs)
+	}
+
+	var next []int
+	if !m.q.absolute && len(m.frames) == 1 {
+		// The root element is the context of a relative query.
+		next = []int{0}
+	} else {
+		for _, p := range parent.states {
+			if p == len(steps) {
+				continue
+			}
+			step := &steps[p]
+			if step.descendant {
+				next = appendState(next, p)
+			}
+			if m.test(step, p, tok, ns, parent) {
+				next = appendState(next, p+1)
+			}
+		}
+	}
+
+	matched := false
+	for _, p := range next {
+		if p == len(steps) {
+			matched = true
+		}
+	}
+
+	frame := matchFrame{states: next, ns: ns}
+	if matched {
+		if m.q.attr == "" {
+			frame.collect = true
+		} else {
+			for _, a := range tok.attrs {
+				if m.q.attr == "*" || a.name == m.q.attr {
+					m.queue = append(m.queue, a.value)
+				}
+			}
+		}
+	}
+	m.frames = append(m.frames, frame)
+}
+
+// test reports whether an element passes step p's name test and predicates.
+// Qualified steps resolve the element's prefix in ns. Positional predicates
+// count siblings in the parent frame.
+func (m *streamMatcher) test(step *queryStep, p int, tok rawToken, ns NamespaceContext, parent *matchFrame) bool {
+	if step.qualified {
+		prefix, local := SplitName(tok.name)
+		if local != step.local || ns[prefix] != step.space {
+			return false
+		}
+	} else if step.name != "*" && step.name != tok.name {
+		return false
+	}
+	for i := range step.preds {
+		pred := &step.preds[i]
+		if pred.index == 0 {
+			if !pred.matchRawAttrs(tok.attrs) {
+				return false
+			}
+			continue
+		}
+		if parent.counts == nil {
+			parent.counts = make(map[[2]int]int)
+		}
+		key := [2]int{p, i}
+		parent.counts[key]++
+		if parent.counts[key] != pred.index {
+			return false
+		}
+	}
+	return true
+}
+
+// matchRawAttrs reports whether a start tag satisfies an attribute predicate.
+func (p *queryPred) matchRawAttrs(attrs []rawAttr) bool {
+	for _, a := range attrs {
+		if a.name == p.attr {
+			return !p.hasValue || a.value == p.value
+		}
+	}
+	return false
+}
+
+// scopeNamespaces returns the bindings in scope at an element with attrs,
+// given those of its parent. The parent's map is returned unchanged when
+// the element declares no namespaces.
+func scopeNamespaces(parent NamespaceContext, attrs []rawAttr) NamespaceContext {
+	ns := parent
+	copied := false
+	for _, a := range attrs {
+		prefix, ok := xmlnsPrefix("@" + a.name)
+		if !ok {
+			continue
+		}
+		if !copied {
+			ns, copied = make(NamespaceContext, len(parent)+1), true
+			for k, v := range parent {
+				ns[k] = v
+			}
+		}
+		if a.value == "" {
+			delete(ns, prefix)
+		} else {
+			ns[prefix] = a.value
+		}
+	}
+	return ns
+}
+
+// appendState adds p to states if not already present.
+func appendState(states []int, p int) []int {
+	for _, s := range states {
+		if s == p {
+			return states
+		}
+	}
+	return append(states, p)
+}
@@ -0,0 +1,158 @@
+package xml
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// matchAll collects every value Match returns for expr.
+func matchAll(t *testing.T, input, expr string) []string {
+	t.Helper()
+	d := NewDecoder(strings.NewReader(input))
+	got := []string{}
+	for {
+		v, err := d.Match(expr)
+		if err == io.EOF {
+			return got
+		}
+		if err != nil {
+			t.Fatalf("Match(%q) failed: %v", expr, err)
+		}
+		got = append(got, v)
+	}
+}
+
+func TestDecoder_Match(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"/orders/order/total", []string{"10.00", "20.00", "30.00"}},
+		{"/orders/order[2]/total", []string{"20.00"}},
+		{"/orders/order[@status='paid']/total", []string{"10.00", "30.00"}},
+		{"/orders/order/@id", []string{"1", "2", "3"}},
+		{"/orders/*/total", []string{"10.00", "20.00", "30.00"}},
+		{"//total", []string{"10.00", "20.00", "ignored", "30.00"}},
+		{"/orders//note/total", []string{"ignored"}},
+		{"//item/@sku", []string{"a", "b"}},
+		{"//order[@status][3]/@id", []string{"3"}},
+		{"/orders/@region", []string{"eu"}},
+		{"order/total", []string{"10.00", "20.00", "30.00"}},
+		{"@region", []string{"eu"}},
+		{"/orders/order[4]", []string{}},
+		{"/other", []string{}},
+	}
+	for _, tt := range tests {
+		if got := matchAll(t, queryTestDoc, tt.expr); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+// TestDecoder_MatchAgreesWithQuery checks that streaming and tree evaluation
+// agree on queries whose results do not depend on sibling name order.
+func TestDecoder_MatchAgreesWithQuery(t *testing.T) {
+	doc, err := ParseDocument(queryTestDoc)
+	if err != nil {
+		t.Fatalf("ParseDocument failed: %v", err)
+	}
+	for _, expr := range []string{"//order/@status", "/orders/order[3]/total", "//item[2]/@sku", "//note"} {
+		want := MustCompileQuery(expr).Values(doc.Root())
+		if got := matchAll(t, queryTestDoc, expr); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: stream %q, tree %q", expr, got, want)
+		}
+	}
+}
+
+func TestDecoder_MatchText(t *testing.T) {
+	input := `<r><v>  a &amp; <![CDATA[<b>]]> <x/>c  </v></r>`
+	if got := matchAll(t, input, "/r/v"); !reflect.DeepEqual(got, []string{"a & <b> c"}) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestDecoder_MatchLarge(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("<feed>")
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&sb, `<entry n="%d"><title>t%d</title><body>%s</body></entry>`, i, i, strings.Repeat("x", 50))
+	}
+	sb.WriteString("</feed>")
+
+	d := NewDecoder(strings.NewReader(sb.String()))
+	q := MustCompileQuery("/feed/entry/title")
+	n := 0
+	for {
+		v, err := d.MatchQuery(q)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("MatchQuery failed: %v", err)
+		}
+		if v != fmt.Sprintf("t%d", n) {
+			t.Fatalf("value %d = %q", n, v)
+		}
+		n++
+	}
+	if n != 20000 {
+		t.Errorf("matched %d titles, want 20000", n)
+	}
+}
+
+func TestDecoder_MatchErrors(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`<a><b>1</b><b>2</b></a>`))
+	if _, err := d.Match("/a/["); err == nil {
+		t.Error("expected compile error")
+	}
+	if v, err := d.Match("/a/b"); err != nil || v != "1" {
+		t.Fatalf("Match = %q, %v", v, err)
+	}
+	if _, err := d.Match("/a/c"); err == nil {
+		t.Error("expected error switching queries")
+	}
+	if v, err := d.Match("/a/b"); err != nil || v != "2" {
+		t.Errorf("Match = %q, %v", v, err)
+	}
+
+	d = NewDecoder(strings.NewReader(`<a><b>1</a>`))
+	if _, err := d.Match("/a/b"); err == nil || !strings.Contains(err.Error(), "mismatched") {
+		t.Errorf("expected syntax error, got %v", err)
+	}
+}
+
+func TestDecoder_MatchQueryNS(t *testing.T) {
+	atom := NamespaceContext{"a": "http://www.w3.org/2005/Atom", "media": "urn:media"}
+	tests := []struct {
+		expr string
+		ns   NamespaceContext
+		want []string
+	}{
+		{"/a:feed/a:entry/media:thumb/@url", atom, []string{"a.png", "b.png"}},
+		{"//entry/title", NamespaceContext{"": "http://www.w3.org/2005/Atom"}, []string{"A", "B"}},
+		{"//title", nil, []string{}},
+		{"//plain", nil, []string{"none"}},
+		{"/a:feed/*/a:title", atom, []string{"A", "B"}},
+	}
+	for _, tt := range tests {
+		q := mustCompileQueryNS(t, tt.expr, tt.ns)
+		d := NewDecoder(strings.NewReader(nsFeed))
+		got := []string{}
+		for {
+			v, err := d.MatchQuery(q)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("MatchQuery(%q) failed: %v", tt.expr, err)
+			}
+			got = append(got, v)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MatchQuery(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package xml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// RecordError reports a record that DecodeEach could not decode.
+type RecordError struct {
+	// Index is the 0-based position of the record among all records read.
+	Index int
+	// Offset is the input offset at which the record starts.
+	Offset int64
+	// Err is the decode error, usually an *UnmarshalError whose path starts
+	// at the record element.
+	Err error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("xml: record %d at offset %d: %s", e.Index, e.Offset, strings.TrimPrefix(e.Err.Error(), "xml: "))
+}
+
+// Unwrap returns the underlying decode error.
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeEach decodes every element named name into v and calls fn after
+// each one, so a stream of sibling records can be imported without holding
+// more than one record in memory. v must be a non-nil pointer; it is reset
+// to its zero value before each record. Elements nested in a record are
+// part of that record and are not matched on their own.
+//
+// A record whose values cannot be converted is skipped: onError is called
+// with a *RecordError and decoding continues with the next record unless
+// onError returns an error. With a nil onError the first bad record stops
+// decoding. Malformed XML cannot be skipped and always stops decoding.
+//
+// DecodeEach returns nil at the end of the input, or the first error
+// returned by fn or onError:
+//
+//	var o Order
+//	err := dec.DecodeEach("order", &o, func() error {
+//	    return store(o)
+//	}, func(err *xml.RecordError) error {
+//	    log.Print(err) // xml: record 7 at offset 5120: /order/qty: cannot parse "x" as int
+//	    return nil
+//	})
+func (d *Decoder) DecodeEach(name string, v interface{}, fn func() error, onError func(*RecordError) error) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("xml: DecodeEach requires a non-nil pointer, got %T", v)
+	}
+	elem := rv.Elem()
+	zero := reflect.Zero(elem.Type())
+
+	for index := 0; ; {
+		offset := d.offset
+		tok, err := d.readToken()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if tok.kind != tokenStart || tok.name != name {
+			continue
+		}
+
+		elem.Set(zero)
+		start := startElement(tok)
+		err = d.DecodeElement(v, &start)
+		if err != nil {
+			var ue *UnmarshalError
+			if !errors.As(err, &ue) {
+				return err
+			}
+			rerr := &RecordError{Index: index, Offset: offset, Err: err}
+			index++
+			if onError == nil {
+				return rerr
+			}
+			if err := onError(rerr); err != nil {
+				return err
+			}
+			continue
+		}
+		index++
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+}
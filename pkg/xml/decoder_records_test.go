@@ -0,0 +1,91 @@
+package xml
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type eachOrder struct {
+	ID  string   `xml:"id,attr"`
+	Qty int      `xml:"qty"`
+	Tag []string `xml:"tag"`
+}
+
+const eachInput = `<orders>
+  <order id="1"><qty>2</qty><tag>a</tag></order>
+  <order id="2"><qty>many</qty></order>
+  <batch><order id="3"><qty>4</qty></order></batch>
+  <order id="4"><qty>5</qty></order>
+</orders>`
+
+func TestDecoderDecodeEach(t *testing.T) {
+	var o eachOrder
+	var got []eachOrder
+	var bad []*RecordError
+	err := NewDecoder(strings.NewReader(eachInput)).DecodeEach("order", &o, func() error {
+		got = append(got, o)
+		return nil
+	}, func(err *RecordError) error {
+		bad = append(bad, err)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DecodeEach failed: %v", err)
+	}
+	want := []eachOrder{
+		{ID: "1", Qty: 2, Tag: []string{"a"}},
+		{ID: "3", Qty: 4},
+		{ID: "4", Qty: 5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+	if len(bad) != 1 {
+		t.Fatalf("got %d bad records, want 1", len(bad))
+	}
+	wantErr := `xml: record 1 at offset 60: /order/qty: cannot parse "many" as int`
+	if bad[0].Error() != wantErr {
+		t.Errorf("got  %q\nwant %q", bad[0].Error(), wantErr)
+	}
+	if bad[0].Offset != int64(strings.Index(eachInput, `<order id="2"`)) {
+		t.Errorf("Offset = %d", bad[0].Offset)
+	}
+	var ue *UnmarshalError
+	if !errors.As(bad[0], &ue) || ue.Path != "/order/qty" {
+		t.Errorf("expected an *UnmarshalError for /order/qty, got %v", bad[0].Err)
+	}
+}
+
+func TestDecoderDecodeEach_Stop(t *testing.T) {
+	var o eachOrder
+	ok := func() error { return nil }
+
+	// Without onError the first bad record stops decoding.
+	err := NewDecoder(strings.NewReader(eachInput)).DecodeEach("order", &o, ok, nil)
+	var rerr *RecordError
+	if !errors.As(err, &rerr) || rerr.Index != 1 {
+		t.Errorf("got %v, want the error of record 1", err)
+	}
+
+	stop := errors.New("stop")
+	err = NewDecoder(strings.NewReader(eachInput)).DecodeEach("order", &o, ok, func(*RecordError) error { return stop })
+	if err != stop {
+		t.Errorf("got %v, want the onError result", err)
+	}
+	err = NewDecoder(strings.NewReader(eachInput)).DecodeEach("order", &o, func() error { return stop }, nil)
+	if err != stop {
+		t.Errorf("got %v, want the fn result", err)
+	}
+
+	// Malformed XML is not skipped.
+	err = NewDecoder(strings.NewReader(`<a><order><qty>1</x></order></a>`)).DecodeEach("order", &o, ok, func(*RecordError) error { return nil })
+	if err == nil || errors.As(err, &rerr) {
+		t.Errorf("got %v, want a syntax error", err)
+	}
+
+	if err := NewDecoder(strings.NewReader(eachInput)).DecodeEach("order", o, ok, nil); err == nil {
+		t.Error("expected an error for a non-pointer value")
+	}
+}
@@ -0,0 +1,216 @@
+package xml
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// readAllTokens drains a Decoder, formatting each token as a short string.
+func readAllTokens(t *testing.T, input string) ([]string, error) {
+	t.Helper()
+	d := NewDecoder(strings.NewReader(input))
+	var out []string
+	for {
+		tok, err := d.readToken()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+		switch tok.kind {
+		case tokenStart:
+			s := "<" + tok.name
+			for _, a := range tok.attrs {
+				s += " " + a.name + "=" + a.value
+			}
+			out = append(out, s+">")
+		case tokenEnd:
+			out = append(out, "</"+tok.name+">")
+		case tokenText:
+			out = append(out, "text:"+tok.text)
+		case tokenCDATA:
+			out = append(out, "cdata:"+tok.text)
+		}
+	}
+}
+
+func TestDecoder_Tokens(t *testing.T) {
+	input := `<?xml version="1.0"?>
+<!DOCTYPE a [<!ENTITY x "y">]>
+<!-- leading -->
+<a id='1' t="x &amp; &#65;&#x42;"><b/>hi &lt;there&gt;<![CDATA[<raw>]]><?pi data?><!-- c --></a>
+`
+	got, err := readAllTokens(t, input)
+	if err != nil {
+		t.Fatalf("readToken failed: %v", err)
+	}
+	want := []string{
+		"<a id=1 t=x & AB>", "<b>", "</b>", "text:hi <there>", "cdata:<raw>", "</a>",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestDecoder_DoctypeMarkupInSubset(t *testing.T) {
+	// Quotes and brackets in comments, processing instructions and
+	// literals do not end the declaration early.
+	for _, input := range []string{
+		`<!DOCTYPE a [<!-- it's -->]><a>x</a>`,
+		`<!DOCTYPE a [<!-- ] > --><!ENTITY e "x">]><a>&e;</a>`,
+		`<!DOCTYPE a [<?pi "?>]><a>x</a>`,
+		`<!DOCTYPE a SYSTEM "x[y"><a>x</a>`,
+		`<!DOCTYPE a [<!ENTITY e "x]>'">]><a>&e;</a>`,
+	} {
+		got, err := readAllTokens(t, input)
+		if err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+		if len(got) != 3 || got[0] != "<a>" || !strings.HasPrefix(got[1], "text:x") {
+			t.Errorf("%s: got %q", input, got)
+		}
+	}
+}
+
+func TestDecoder_OneByteReader(t *testing.T) {
+	input := `<root><item k="v">text</item><![CDATA[x]]></root>`
+	d := NewDecoder(iotest.OneByteReader(strings.NewReader(input)))
+	n := 0
+	for {
+		_, err := d.readToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("readToken failed: %v", err)
+		}
+		n++
+	}
+	if n != 6 {
+		t.Errorf("expected 6 tokens, got %d", n)
+	}
+	if d.InputOffset() != int64(len(input)) {
+		t.Errorf("InputOffset() = %d, want %d", d.InputOffset(), len(input))
+	}
+}
+
+func TestDecoder_SyntaxErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{``, "no root element"},
+		{`<a>`, "expected closing tag"},
+		{`<a></b>`, "mismatched tags"},
+		{`<a><b x="1"></c></a>`, `line 1, column 13: mismatched tags: opening "b" at offset 3, closing "c"; open elements: /a/b`},
+		{`<a x="1" x="2"/>`, "duplicate attribute"},
+		{`<a x=1/>`, "expected quoted value"},
+		{`<a x="<"/>`, "'<' in value"},
+		{`<a>&bogus;</a>`, "invalid entity reference"},
+		{`<a>&#0;</a>`, "reference &#0; to illegal character U+0000"},
+		{`<a>a & b</a>`, "unterminated entity reference"},
+		{`<a/><b/>`, "element after the root element"},
+		{`<a/>junk`, "character data outside the root element"},
+		{`</a>`, "unexpected closing tag"},
+		{`<a><!-- x</a>`, "unexpected end of input in comment"},
+		{`<a><![CDATA[x</a>`, "unexpected end of input in CDATA section"},
+		{`<a><!x></a>`, "invalid markup declaration"},
+		{`<1a/>`, "invalid name"},
+		{"<!DOCTYPE a [\ngarbage]><a/>", "line 2, column 1: in DOCTYPE"},
+		{`<?1pi?><a/>`, "invalid processing instruction target"},
+	}
+	for _, tt := range tests {
+		d := NewDecoder(strings.NewReader(tt.input))
+		d.emitMarkup = true
+		var err error
+		for err == nil {
+			_, err = d.readToken()
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: got error %v, want %q", tt.input, err, tt.want)
+		}
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%q: got %T, want a *SyntaxError", tt.input, err)
+		} else if strings.Contains(syntaxErr.Err.Error(), "position") {
+			t.Errorf("%q: message %q repeats the position", tt.input, syntaxErr.Err)
+		}
+	}
+}
+
+func TestDecoder_StickyError(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`<a></b>`))
+	var first error
+	for first == nil {
+		_, first = d.readToken()
+	}
+	if _, err := d.readToken(); err != first {
+		t.Errorf("expected sticky error %v, got %v", first, err)
+	}
+}
+
+func TestDecoder_ReaderError(t *testing.T) {
+	boom := errors.New("boom")
+	d := NewDecoder(iotest.ErrReader(boom))
+	if _, err := d.readToken(); !errors.Is(err, boom) {
+		t.Errorf("expected reader error, got %v", err)
+	}
+}
+
+func TestDecoder_Stack(t *testing.T) {
+	input := `<orders><order id="1"><item/><total>5</total></order></orders>`
+	d := NewDecoder(strings.NewReader(input))
+	if got := d.Stack(); len(got) != 0 || d.Path() != "" {
+		t.Errorf("before the root: %v %q", got, d.Path())
+	}
+
+	var paths []string
+	var total []OpenElement
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, d.Path())
+		if se, ok := tok.(StartElement); ok && se.Name == "total" {
+			total = d.Stack()
+		}
+	}
+	want := []string{
+		"/orders", "/orders/order",
+		"/orders/order/item", "/orders/order", // <item/> reports its start and end
+		"/orders/order/total", "/orders/order/total", "/orders/order",
+		"/orders", "",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %q\nwant    %q", paths, want)
+	}
+	wantStack := []OpenElement{{"orders", 0}, {"order", 8}, {"total", 29}}
+	if !reflect.DeepEqual(total, wantStack) {
+		t.Errorf("Stack() = %v, want %v", total, wantStack)
+	}
+
+	// DecodeEach callbacks see the ancestors of the record.
+	d = NewDecoder(strings.NewReader(input))
+	var o struct {
+		ID string `xml:"id,attr"`
+	}
+	err := d.DecodeEach("order", &o, func() error {
+		if d.Path() != "/orders" {
+			t.Errorf("Path() in DecodeEach = %q", d.Path())
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,204 @@
+package xml
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shapestone/shape-xml/internal/fastparser"
+)
+
+// Token is a unit of XML input returned by Decoder.Token: one of
+// StartElement, EndElement, CharData, Comment or ProcInst.
+type Token interface{}
+
+// Attr is an attribute of a start tag, with entities decoded.
+type Attr struct {
+	Name  string
+	Value string
+}
+
+// StartElement is a start tag. Self-closing tags are reported as a
+// StartElement immediately followed by the matching EndElement.
+type StartElement struct {
+	Name string
+	Attr []Attr
+}
+
+// End returns the EndElement that closes e.
+func (e StartElement) End() EndElement {
+	return EndElement{Name: e.Name}
+}
+
+// EndElement is an end tag.
+type EndElement struct {
+	Name string
+}
+
+// CharData is character data with entities decoded. CDATA sections are
+// reported as CharData as well.
+type CharData []byte
+
+// Comment is the text of a comment, without the <!-- and --> delimiters.
+type Comment []byte
+
+// ProcInst is a processing instruction such as <?xml version="1.0"?>.
+type ProcInst struct {
+	Target string
+	Inst   []byte
+}
+
+// Token returns the next token of the input, or io.EOF after the root
+// element has been closed and the input is exhausted. Whitespace outside
+// the root element and the document type declaration are skipped. Every
+// returned token owns its data, so it stays valid after later calls.
+//
+// Start and end tags are checked to nest properly. Token reads from the
+// same stream as Match, so the two should not be mixed on one Decoder.
+//
+// Example:
+//
+//	dec := xml.NewDecoder(file)
+//	for {
+//	    tok, err := dec.Token()
+//	    if err == io.EOF {
+//	        break
+//	    }
+//	    if err != nil {
+//	        return err
+//	    }
+//	    if start, ok := tok.(xml.StartElement); ok && start.Name == "order" {
+//	        var o Order
+//	        if err := dec.DecodeElement(&o, &start); err != nil {
+//	            return err
+//	        }
+//	        process(o)
+//	    }
+//	}
+func (d *Decoder) Token() (Token, error) {
+	d.emitMarkup = true
+	tok, err := d.readToken()
+	d.emitMarkup = false
+	if err != nil {
+		return nil, err
+	}
+	switch tok.kind {
+	case tokenStart:
+		return startElement(tok), nil
+	case tokenEnd:
+		return EndElement{Name: tok.name}, nil
+	case tokenComment:
+		return Comment(tok.text), nil
+	case tokenProcInst:
+		return ProcInst{Target: tok.name, Inst: []byte(tok.text)}, nil
+	default:
+		return CharData(tok.text), nil
+	}
+}
+
+// Skip reads tokens up to and including the end of the most recently
+// started element, discarding its remaining content. Call it after Token
+// returned a StartElement that is not needed.
+func (d *Decoder) Skip() error {
+	if len(d.open) == 0 {
+		return fmt.Errorf("xml: Skip called outside an element")
+	}
+	depth := len(d.open)
+	for len(d.open) >= depth {
+		if _, err := d.readToken(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeElement decodes the element that start opened into v, with the
+// same rules as Unmarshal, and consumes the input up to and including its
+// end tag. start must be the StartElement most recently returned by Token.
+// If start is nil, DecodeElement first reads up to the next start tag.
+//
+// Only the decoded element is held in memory, which makes Token and
+// DecodeElement suitable for feeds of many records too large to Unmarshal
+// as a whole.
+func (d *Decoder) DecodeElement(v interface{}, start *StartElement) error {
+	if start == nil {
+		for {
+			tok, err := d.readToken()
+			if err != nil {
+				return err
+			}
+			if tok.kind == tokenStart {
+				se := startElement(tok)
+				start = &se
+				break
+			}
+		}
+	}
+	if len(d.open) == 0 || d.open[len(d.open)-1] != start.Name {
+		return fmt.Errorf("xml: DecodeElement: %q is not the current element", start.Name)
+	}
+
+	data, err := d.readElement(start)
+	if err != nil {
+		return err
+	}
+	err = fastparser.UnmarshalInterned(data, v, d.names)
+	var ue *UnmarshalError
+	if errors.As(err, &ue) {
+		// Positions refer to the re-serialized element, not the input.
+		ue.Line, ue.Column = 0, 0
+	}
+	return err
+}
+
+// startElement converts a tokenStart rawToken into a StartElement.
+func startElement(tok rawToken) StartElement {
+	start := StartElement{Name: tok.name}
+	if len(tok.attrs) > 0 {
+		start.Attr = make([]Attr, len(tok.attrs))
+		for i, a := range tok.attrs {
+			start.Attr[i] = Attr{Name: a.name, Value: a.value}
+		}
+	}
+	return start
+}
+
+// readElement reads the rest of the element opened by start and returns
+// the whole element re-serialized as a standalone document.
+func (d *Decoder) readElement(start *StartElement) ([]byte, error) {
+	buf := appendStartTag(nil, start.Name, start.Attr)
+	depth := len(d.open)
+	for len(d.open) >= depth {
+		tok, err := d.readToken()
+		if err != nil {
+			return nil, err
+		}
+		switch tok.kind {
+		case tokenStart:
+			se := startElement(tok)
+			buf = appendStartTag(buf, se.Name, se.Attr)
+		case tokenEnd:
+			buf = append(buf, "</"...)
+			buf = append(buf, tok.name...)
+			buf = append(buf, '>')
+		case tokenText:
+			buf = AppendEscapedText(buf, tok.text)
+		case tokenCDATA:
+			buf = appendCDATA(buf, tok.text)
+		}
+	}
+	return buf, nil
+}
+
+// appendStartTag appends <name attr="value"...> to buf.
+func appendStartTag(buf []byte, name string, attrs []Attr) []byte {
+	buf = append(buf, '<')
+	buf = append(buf, name...)
+	for _, a := range attrs {
+		buf = append(buf, ' ')
+		buf = append(buf, a.Name...)
+		buf = append(buf, `="`...)
+		buf = AppendEscapedAttr(buf, a.Value)
+		buf = append(buf, '"')
+	}
+	return append(buf, '>')
+}
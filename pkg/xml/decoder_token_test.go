@@ -0,0 +1,196 @@
+package xml
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecoderToken(t *testing.T) {
+	input := `<?xml version="1.0"?>
+<!-- feed -->
+<feed a="1 &amp; 2"><item/>text<![CDATA[<raw>]]><?pi data?></feed>`
+	d := NewDecoder(strings.NewReader(input))
+	var got []Token
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Token failed: %v", err)
+		}
+		got = append(got, tok)
+	}
+	want := []Token{
+		ProcInst{Target: "xml", Inst: []byte(`version="1.0"`)},
+		Comment(" feed "),
+		StartElement{Name: "feed", Attr: []Attr{{Name: "a", Value: "1 & 2"}}},
+		StartElement{Name: "item"},
+		EndElement{Name: "item"},
+		CharData("text"),
+		CharData("<raw>"),
+		ProcInst{Target: "pi", Inst: []byte("data")},
+		EndElement{Name: "feed"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %#v\nwant %#v", got, want)
+	}
+}
+
+func TestDecoderToken_Errors(t *testing.T) {
+	for _, input := range []string{
+		`<a></b>`,
+		`<a>`,
+		`<?1bad?><a/>`,
+		`<a><!-- open</a>`,
+	} {
+		d := NewDecoder(strings.NewReader(input))
+		var err error
+		for err == nil {
+			_, err = d.Token()
+		}
+		if err == io.EOF {
+			t.Errorf("%q: expected a syntax error", input)
+		}
+	}
+}
+
+func TestDecoderDecodeElement(t *testing.T) {
+	type Order struct {
+		ID    string   `xml:"id,attr"`
+		Total float64  `xml:"total"`
+		Items []string `xml:"item"`
+		Note  string   `xml:"note"`
+	}
+	input := `<orders>
+  <order id="1"><total>9.50</total><item>a</item><item>b</item><note>x &lt; y</note></order>
+  <skip><order id="ignored"/></skip>
+  <order id="2"><!-- c --><total>3</total><note>Ann &amp; Co</note></order>
+</orders>`
+	d := NewDecoder(strings.NewReader(input))
+	var orders []Order
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Token failed: %v", err)
+		}
+		start, ok := tok.(StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name {
+		case "order":
+			var o Order
+			if err := d.DecodeElement(&o, &start); err != nil {
+				t.Fatalf("DecodeElement failed: %v", err)
+			}
+			orders = append(orders, o)
+		case "skip":
+			if err := d.Skip(); err != nil {
+				t.Fatalf("Skip failed: %v", err)
+			}
+		}
+	}
+	want := []Order{
+		{ID: "1", Total: 9.5, Items: []string{"a", "b"}, Note: "x < y"},
+		{ID: "2", Total: 3, Note: "Ann & Co"},
+	}
+	if !reflect.DeepEqual(orders, want) {
+		t.Errorf("got %+v\nwant %+v", orders, want)
+	}
+}
+
+func TestDecoderDecodeElement_NilStart(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`<?xml version="1.0"?><a x="1"><b>2</b></a>`))
+	var m map[string]interface{}
+	if err := d.DecodeElement(&m, nil); err != nil {
+		t.Fatalf("DecodeElement failed: %v", err)
+	}
+	want := map[string]interface{}{"@x": "1", "b": map[string]interface{}{"#text": "2"}}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("got %#v", m)
+	}
+	if _, err := d.Token(); err != io.EOF {
+		t.Errorf("expected io.EOF after the root element, got %v", err)
+	}
+}
+
+func TestDecoderDecodeElement_WrongStart(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`<a><b/></a>`))
+	if _, err := d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	var v interface{}
+	if err := d.DecodeElement(&v, &StartElement{Name: "b"}); err == nil {
+		t.Error("expected an error for a start element that is not current")
+	}
+	if err := NewDecoder(strings.NewReader(`<a/>`)).Skip(); err == nil {
+		t.Error("expected an error for Skip outside an element")
+	}
+}
+
+func TestDecoderDecodeElement_ErrorPath(t *testing.T) {
+	d := NewDecoder(strings.NewReader("<feed>\n<order><qty>1</qty><qty>x</qty></order></feed>"))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if start, ok := tok.(StartElement); ok && start.Name == "order" {
+			var o struct {
+				Qty []int `xml:"qty"`
+			}
+			err := d.DecodeElement(&o, &start)
+			// Paths start at the decoded element; positions are not reported.
+			if err == nil || err.Error() != `xml: /order/qty[2]: cannot parse "x" as int` {
+				t.Errorf("got %v", err)
+			}
+			return
+		}
+	}
+}
+
+func TestDecoderToken_DoctypeEntities(t *testing.T) {
+	input := `<!DOCTYPE a [<!ENTITY co "Acme &amp; Sons"><!ENTITY ext SYSTEM "x.xml">]>
+<a who="&co;">&co; &#33;</a>`
+	d := NewDecoder(strings.NewReader(input))
+	var got []Token
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Token failed: %v", err)
+		}
+		got = append(got, tok)
+	}
+	want := []Token{
+		StartElement{Name: "a", Attr: []Attr{{Name: "who", Value: "Acme & Sons"}}},
+		CharData("Acme & Sons !"),
+		EndElement{Name: "a"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %#v\nwant %#v", got, want)
+	}
+
+	for _, input := range []string{
+		`<!DOCTYPE a [<!ENTITY ext SYSTEM "x.xml">]><a>&ext;</a>`,
+		`<!DOCTYPE a [<!ENTITY a "x">]><a>&b;</a>`,
+		`<!DOCTYPE a [<!ENTITY>]><a/>`,
+	} {
+		d := NewDecoder(strings.NewReader(input))
+		var err error
+		for err == nil {
+			_, err = d.Token()
+		}
+		if err == io.EOF {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
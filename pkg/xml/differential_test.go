@@ -0,0 +1,116 @@
+package xml
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+// TestFormatting_AllPositions checks that a value is written the same way
+// whether it is an attribute, character data, a CDATA section or the text
+// of a child element, since all four go through one formatting path.
+func TestFormatting_AllPositions(t *testing.T) {
+	s := "text"
+	samples := []interface{}{
+		"plain", int8(-8), int64(1 << 40), uint16(65535), uint64(1 << 63),
+		float32(0.1), float64(0.1), float64(1e21), float32(-2.5), true, false,
+		90 * time.Second, &s,
+	}
+	re := regexp.MustCompile(`^<p a="(.*)">(.*)<!\[CDATA\[(.*)\]\]><e>(.*)</e></p>$`)
+	for _, sample := range samples {
+		typ := reflect.TypeOf(sample)
+		st := reflect.StructOf([]reflect.StructField{
+			{Name: "A", Type: typ, Tag: `xml:"a,attr"`},
+			{Name: "T", Type: typ, Tag: `xml:",chardata"`},
+			{Name: "C", Type: typ, Tag: `xml:",cdata"`},
+			{Name: "E", Type: typ, Tag: `xml:"e"`},
+		})
+		v := reflect.New(st).Elem()
+		for i := 0; i < 4; i++ {
+			v.Field(i).Set(reflect.ValueOf(sample))
+		}
+		es := &encodeState{}
+		out, err := xmlEncoderForType(st)(es, nil, v, "p")
+		if err != nil {
+			t.Fatalf("%T: %v", sample, err)
+		}
+		m := re.FindStringSubmatch(string(out))
+		if m == nil {
+			t.Errorf("%T: unexpected output %s", sample, out)
+			continue
+		}
+		if m[1] != m[2] || m[2] != m[3] || m[3] != m[4] {
+			t.Errorf("%T: positions disagree: attr %q, chardata %q, cdata %q, element %q", sample, m[1], m[2], m[3], m[4])
+		}
+	}
+}
+
+type diffItem struct {
+	ID    int               `xml:"id,attr"`
+	Name  string            `xml:"name"`
+	Price float64           `xml:"price"`
+	Tags  []string          `xml:"tag"`
+	Note  *string           `xml:"note,omitempty"`
+	Attrs map[string]string `xml:"attrs"`
+}
+
+type diffDoc struct {
+	Version string     `xml:"version,attr"`
+	Text    string     `xml:",chardata"`
+	Items   []diffItem `xml:"item"`
+	Flag    bool       `xml:"flag"`
+	Wait    time.Duration
+	Any     interface{} `xml:"any"`
+}
+
+// TestMarshal_Differential checks that Marshal, MarshalWithOptions with
+// zero options and a streaming Encoder produce the same bytes for random
+// documents, and that the result decodes back.
+func TestMarshal_Differential(t *testing.T) {
+	cfg := &quick.Config{MaxCount: 300, Rand: rand.New(rand.NewSource(1))}
+	check := func(version, text string, items []diffItem, flag bool, wait int64, anyInt int32) bool {
+		// Map keys become element names; keep them valid.
+		for i := range items {
+			attrs := make(map[string]string, len(items[i].Attrs))
+			for _, v := range items[i].Attrs {
+				attrs["k"+strconv.Itoa(len(attrs))] = v
+			}
+			items[i].Attrs = attrs
+		}
+		doc := diffDoc{Version: version, Text: text, Items: items, Flag: flag, Wait: time.Duration(wait), Any: anyInt}
+		want, err := Marshal(doc)
+		if err != nil {
+			t.Logf("Marshal: %v", err)
+			return false
+		}
+		withOpts, err := MarshalWithOptions(doc, MarshalOptions{})
+		if err != nil || !bytes.Equal(withOpts, want) {
+			t.Logf("MarshalWithOptions differs: %v", err)
+			return false
+		}
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		if err := enc.Encode(doc); err != nil {
+			t.Logf("Encode: %v", err)
+			return false
+		}
+		if err := enc.Flush(); err != nil || !bytes.Equal(buf.Bytes(), want) {
+			t.Logf("Encoder differs: %v\n%s\n%s", err, buf.Bytes(), want)
+			return false
+		}
+		var back diffDoc
+		if err := Unmarshal(want, &back); err != nil {
+			t.Logf("Unmarshal: %v\n%s", err, want)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(check, cfg); err != nil {
+		t.Error(err)
+	}
+}
@@ -22,13 +22,34 @@
 package xml
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/shapestone/shape-xml/internal/parser"
 )
 
 // Element represents an XML element with a fluent API for manipulation.
 // All setter methods return *Element to enable method chaining.
+//
+// Elements obtained through GetChild remember their parent, so Path, Parent,
+// Detach and ReplaceWith can be used to navigate and restructure a tree from
+// any depth; the changes are visible when rendering from the root.
+//
+// An Element is not safe for concurrent use while it is being changed; see
+// Freeze for sharing a tree between goroutines.
 type Element struct {
 	data map[string]interface{}
+
+	// parent is the element this one was obtained from, nil for a root.
+	parent *Element
+	// name is the element name; empty for roots created with NewElement.
+	name string
+	// frozen is set on the elements of a tree made by Freeze, whose maps
+	// are never changed.
+	frozen bool
 }
 
 // NewElement creates a new Element.
@@ -41,19 +62,45 @@ func NewElement() *Element {
 // ParseElement parses XML string into an Element with a fluent API.
 // Returns an error if the input is not valid XML.
 func ParseElement(input string) (*Element, error) {
+	return ParseElementWithOptions(input)
+}
+
+// ParseElementWithOptions parses XML into an Element like ParseElement,
+// applying the given options.
+func ParseElementWithOptions(input string, opts ...ParseOption) (*Element, error) {
+	return parseElementBytes([]byte(input), opts...)
+}
+
+// parseElementBytes is ParseElementWithOptions for input held in a byte
+// slice.
+func parseElementBytes(data []byte, opts ...ParseOption) (_ *Element, err error) {
+	o := newParseOptions(opts)
+	defer o.recoverPanic(&err)
+	data = o.clean(data)
+	if (o.MixedContent || o.Comments || o.Lenient) && o.mapOnly() {
+		return nil, errors.New("xml: WithMixedContent, WithComments and WithLenient are not supported with WithElementFilter, WithMaxDepth or WithBackslashEscapes")
+	}
+	if o.mapOnly() || (o.InternPool != nil && !o.MixedContent && !o.Comments && !o.Lenient) {
+		m, rootName, err := o.parseMap(data)
+		if err != nil {
+			return nil, err
+		}
+		return &Element{data: m, name: rootName}, nil
+	}
+
 	// Parse XML to AST
-	node, err := Parse(input)
+	node, rootName, err := parseDocument(parser.NewParserFromBytes(data), o)
 	if err != nil {
 		return nil, err
 	}
 
 	// Convert AST to map[string]interface{}
 	value := NodeToInterface(node)
-	data, ok := value.(map[string]interface{})
+	m, ok := value.(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("expected XML element, got %T", value)
 	}
-	return &Element{data: data}, nil
+	return &Element{data: m, name: rootName}, nil
 }
 
 // ============================================================================
@@ -62,6 +109,7 @@ func ParseElement(input string) (*Element, error) {
 
 // Set sets a generic value and returns the Element for chaining.
 func (e *Element) Set(key string, value interface{}) *Element {
+	e = e.mutable()
 	e.data[key] = value
 	return e
 }
@@ -69,34 +117,59 @@ func (e *Element) Set(key string, value interface{}) *Element {
 // Attr sets an attribute and returns the Element for chaining.
 // Attributes are stored with "@" prefix following XML AST convention.
 func (e *Element) Attr(name, value string) *Element {
+	e = e.mutable()
 	e.data["@"+name] = value
 	return e
 }
 
+// Number sets the text content to a Number and returns the Element for chaining.
+func (e *Element) Number(value Number) *Element {
+	e = e.mutable()
+	e.data["#text"] = value
+	delete(e.data, "#content")
+	return e
+}
+
 // Text sets the text content and returns the Element for chaining.
-// Text content is stored as "#text" following XML AST convention.
+// Text content is stored as "#text" following XML AST convention. Text,
+// Number and CDATA drop the content order recorded by WithMixedContent.
 func (e *Element) Text(value string) *Element {
+	e = e.mutable()
 	e.data["#text"] = value
+	delete(e.data, "#content")
 	return e
 }
 
 // CDATA sets CDATA content and returns the Element for chaining.
 // CDATA content is stored as "#cdata" following XML AST convention.
 func (e *Element) CDATA(value string) *Element {
+	e = e.mutable()
 	e.data["#cdata"] = value
+	delete(e.data, "#content")
 	return e
 }
 
 // Child adds a child element and returns the parent Element for chaining.
-// The name is the element name (e.g., "name", "email").
+// The name is the element name (e.g., "name", "email"). A frozen child is
+// copied into the tree. Child replaces any children of the same name; use
+// AppendChild to add repeated children.
 func (e *Element) Child(name string, child *Element) *Element {
+	e = e.mutable()
+	if child.frozen {
+		// The snapshot stays shared; the tree gets its own copy.
+		e.data[name] = deepCopyMap(child.data)
+		return e
+	}
 	e.data[name] = child.data
+	child.parent = e
+	child.name = name
 	return e
 }
 
 // ChildText adds a child element with text content and returns the parent Element for chaining.
 // This is a convenience method equivalent to Child(name, NewElement().Text(text)).
 func (e *Element) ChildText(name, text string) *Element {
+	e = e.mutable()
 	e.data[name] = map[string]interface{}{"#text": text}
 	return e
 }
@@ -105,17 +178,24 @@ func (e *Element) ChildText(name, text string) *Element {
 // Element Getter Methods (type-safe access)
 // ============================================================================
 
-// Get gets a value as interface{}. Returns nil if not found.
+// Get gets a value as interface{}. Returns nil if not found. Maps and
+// slices from a frozen element are copies.
 func (e *Element) Get(key string) (interface{}, bool) {
 	val, ok := e.data[key]
+	if e.frozen {
+		val = deepCopyValue(val)
+	}
 	return val, ok
 }
 
 // GetAttr gets an attribute value. Returns empty string and false if not found.
 func (e *Element) GetAttr(name string) (string, bool) {
 	if val, ok := e.data["@"+name]; ok {
-		if str, ok := val.(string); ok {
-			return str, true
+		switch v := val.(type) {
+		case string:
+			return v, true
+		case Number:
+			return string(v), true
 		}
 	}
 	return "", false
@@ -124,13 +204,26 @@ func (e *Element) GetAttr(name string) (string, bool) {
 // GetText gets the text content. Returns empty string and false if not found.
 func (e *Element) GetText() (string, bool) {
 	if val, ok := e.data["#text"]; ok {
-		if str, ok := val.(string); ok {
-			return str, true
+		switch v := val.(type) {
+		case string:
+			return v, true
+		case Number:
+			return string(v), true
 		}
 	}
 	return "", false
 }
 
+// GetNumber gets the text content as a Number. Returns false if there is no
+// text content or it is not a number.
+func (e *Element) GetNumber() (Number, bool) {
+	text, ok := e.GetText()
+	if !ok || !isNumber(text) {
+		return "", false
+	}
+	return Number(text), true
+}
+
 // GetCDATA gets the CDATA content. Returns empty string and false if not found.
 func (e *Element) GetCDATA() (string, bool) {
 	if val, ok := e.data["#cdata"]; ok {
@@ -145,7 +238,7 @@ func (e *Element) GetCDATA() (string, bool) {
 func (e *Element) GetChild(name string) (*Element, bool) {
 	if val, ok := e.data[name]; ok {
 		if m, ok := val.(map[string]interface{}); ok {
-			return &Element{data: m}, true
+			return e.child(m, name), true
 		}
 	}
 	return nil, false
@@ -165,12 +258,14 @@ func (e *Element) HasAttr(name string) bool {
 
 // Remove removes a key and returns the Element for chaining.
 func (e *Element) Remove(key string) *Element {
+	e = e.mutable()
 	delete(e.data, key)
 	return e
 }
 
 // RemoveAttr removes an attribute and returns the Element for chaining.
 func (e *Element) RemoveAttr(name string) *Element {
+	e = e.mutable()
 	delete(e.data, "@"+name)
 	return e
 }
@@ -206,11 +301,220 @@ func (e *Element) Children() []string {
 	return children
 }
 
-// ToMap returns the underlying map[string]interface{}.
+// ToMap returns the underlying map[string]interface{}, or a copy of it for
+// a frozen element.
 func (e *Element) ToMap() map[string]interface{} {
+	if e.frozen {
+		return deepCopyMap(e.data)
+	}
 	return e.data
 }
 
+// ============================================================================
+// Frozen Elements
+// ============================================================================
+
+// Freeze returns an immutable snapshot of the element and everything below
+// it. The snapshot is a deep copy, so later changes to e do not show
+// through, and it is a root: Parent reports none. Freezing a frozen element
+// returns it unchanged.
+//
+// A frozen tree, and every element obtained from it, may be read from many
+// goroutines at once, which makes it suitable for caching parsed documents.
+// Its setters and Remove methods are copy-on-write: they leave the frozen
+// element unchanged and apply the change to a mutable deep copy of it, which
+// they return, so their result must be used:
+//
+//	cached := doc.Freeze()
+//	mine := cached.Attr("seen", "true") // cached is unchanged
+func (e *Element) Freeze() *Element {
+	if e.frozen {
+		return e
+	}
+	return &Element{data: deepCopyMap(e.data), name: e.name, frozen: true}
+}
+
+// Frozen reports whether the element belongs to a tree made by Freeze.
+func (e *Element) Frozen() bool {
+	return e.frozen
+}
+
+// mutable returns e for the setters to change, or a mutable deep copy of
+// it, with no parent, if e is frozen.
+func (e *Element) mutable() *Element {
+	if !e.frozen {
+		return e
+	}
+	return &Element{data: deepCopyMap(e.data), name: e.name}
+}
+
+// child returns the element for the child map m named name, frozen if e is.
+func (e *Element) child(m map[string]interface{}, name string) *Element {
+	return &Element{data: m, parent: e, name: name, frozen: e.frozen}
+}
+
+// ============================================================================
+// Element Navigation
+// ============================================================================
+
+// Name returns the element name: the root element's name for elements from
+// ParseElement, the child name for elements from GetChild or Child, and ""
+// for elements created with NewElement.
+func (e *Element) Name() string {
+	return e.name
+}
+
+// Parent returns the element this one was obtained from.
+// Returns nil and false for a root or detached element.
+func (e *Element) Parent() (*Element, bool) {
+	return e.parent, e.parent != nil
+}
+
+// Root returns the top-most ancestor of the element, or the element itself
+// if it has no parent.
+func (e *Element) Root() *Element {
+	for e.parent != nil {
+		e = e.parent
+	}
+	return e
+}
+
+// Path returns the element's location from the root, e.g. "/order/item[2]/price".
+// Repeated siblings are addressed with a 1-based index. The root contributes
+// its name when known (see Name); otherwise the path starts at its children.
+func (e *Element) Path() string {
+	var segments []string
+	for cur := e; cur != nil; cur = cur.parent {
+		if cur.parent == nil && cur.name == "" {
+			break
+		}
+		seg := cur.name
+		if cur.parent != nil {
+			if i, n, ok := cur.parent.locate(cur); ok && n > 1 {
+				seg += "[" + strconv.Itoa(i+1) + "]"
+			}
+		}
+		segments = append(segments, seg)
+	}
+	for i, j := 0, len(segments)-1; i < j; i, j = i+1, j-1 {
+		segments[i], segments[j] = segments[j], segments[i]
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// Detach removes the element from its parent and returns it as a new root.
+// It does nothing for an element without a parent. A frozen tree is left
+// unchanged, and Detach returns a mutable copy of the element.
+func (e *Element) Detach() *Element {
+	if e.frozen {
+		return e.mutable()
+	}
+	if e.parent == nil {
+		return e
+	}
+	e.parent.replaceChild(e, nil)
+	e.parent = nil
+	return e
+}
+
+// ReplaceWith puts other in the element's place within its parent and
+// detaches the element. If other belongs to another tree it is detached from
+// there first; if other is frozen, a copy of it is used. Returns an error if
+// the element is frozen or has no parent, or if other is the element's
+// ancestor.
+func (e *Element) ReplaceWith(other *Element) error {
+	if e.frozen {
+		return errors.New("xml: cannot replace an element of a frozen tree")
+	}
+	if e.parent == nil {
+		return errors.New("xml: cannot replace an element without a parent")
+	}
+	other = other.mutable()
+	if other == e {
+		return nil
+	}
+	for cur := e.parent; cur != nil; cur = cur.parent {
+		if sameMap(cur.data, other.data) {
+			return errors.New("xml: cannot replace an element with its own ancestor")
+		}
+	}
+	other.Detach()
+	if !e.parent.replaceChild(e, other.data) {
+		return fmt.Errorf("xml: element %q is no longer attached to its parent", e.name)
+	}
+	other.parent = e.parent
+	other.name = e.name
+	e.parent = nil
+	return nil
+}
+
+// locate finds child among e's children named child.name. It returns the
+// child's index among its same-named siblings and the number of siblings.
+func (e *Element) locate(child *Element) (index, count int, ok bool) {
+	switch v := e.data[child.name].(type) {
+	case map[string]interface{}:
+		return 0, 1, sameMap(v, child.data)
+	case []interface{}:
+		for i, item := range v {
+			if m, isMap := item.(map[string]interface{}); isMap && sameMap(m, child.data) {
+				return i, len(v), true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// replaceChild replaces child with data in e, or removes it when data is nil.
+// A repeated element left with a single occurrence is stored as a plain map,
+// matching the parser's representation. It reports whether child was found.
+func (e *Element) replaceChild(child *Element, data map[string]interface{}) bool {
+	i, n, ok := e.locate(child)
+	if !ok {
+		return false
+	}
+	if n == 1 && !isSlice(e.data[child.name]) {
+		if data == nil {
+			delete(e.data, child.name)
+		} else {
+			e.data[child.name] = data
+		}
+		return true
+	}
+
+	if data != nil {
+		e.data[child.name].([]interface{})[i] = data
+		return true
+	}
+	e.removeAt(child.name, i)
+	return true
+}
+
+// removeAt removes item i of the repeated element name, storing a single
+// remaining occurrence as a plain map.
+func (e *Element) removeAt(name string, i int) {
+	items := e.data[name].([]interface{})
+	items = append(items[:i:i], items[i+1:]...)
+	switch len(items) {
+	case 0:
+		delete(e.data, name)
+	case 1:
+		e.data[name] = items[0]
+	default:
+		e.data[name] = items
+	}
+}
+
+// isSlice reports whether v is a list of repeated elements.
+func isSlice(v interface{}) bool {
+	_, ok := v.([]interface{})
+	return ok
+}
+
+// sameMap reports whether a and b are the same map, not merely equal ones.
+func sameMap(a, b map[string]interface{}) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
 // XML marshals the Element to an XML string with the given element name.
 //
 // Example:
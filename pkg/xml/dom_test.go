@@ -1,6 +1,7 @@
 package xml
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -256,17 +257,14 @@ func TestParseElement_Nested(t *testing.T) {
 		t.Fatalf("ParseElement failed: %v", err)
 	}
 
-	// Current parser puts all children under "child" array
-	// This test verifies the parser works but acknowledges current structure
-	children := elem.Children()
-	if len(children) == 0 {
-		t.Error("Expected child elements")
-	}
-
-	// Verify we have child data (even if structure is different than expected)
-	data := elem.ToMap()
-	if len(data) == 0 {
-		t.Error("Expected non-empty data")
+	for name, want := range map[string]string{"name": "Alice", "email": "alice@example.com"} {
+		child, ok := elem.GetChild(name)
+		if !ok {
+			t.Fatalf("GetChild(%q) not found in %v", name, elem.Children())
+		}
+		if got, _ := child.GetText(); got != want {
+			t.Errorf("%s text = %q, want %q", name, got, want)
+		}
 	}
 }
 
@@ -277,3 +275,168 @@ func TestParseElement_Invalid(t *testing.T) {
 		t.Error("Expected error for invalid XML")
 	}
 }
+
+// ============================================================================
+// Element Tests - Navigation
+// ============================================================================
+
+func TestElement_PathAndParent(t *testing.T) {
+	doc, err := ParseDocument(`<config><server><tls enabled="true"/></server></config>`)
+	if err != nil {
+		t.Fatalf("ParseDocument failed: %v", err)
+	}
+	root := doc.Root()
+	server, _ := root.GetChild("server")
+	tls, _ := server.GetChild("tls")
+
+	if got := tls.Path(); got != "/config/server/tls" {
+		t.Errorf("Path() = %q", got)
+	}
+	if got := root.Path(); got != "/config" {
+		t.Errorf("root Path() = %q", got)
+	}
+	if p, ok := tls.Parent(); !ok || p != server {
+		t.Errorf("Parent() = %v, %v", p, ok)
+	}
+	if _, ok := root.Parent(); ok {
+		t.Error("root should have no parent")
+	}
+	if tls.Root() != root {
+		t.Error("Root() should return the document root")
+	}
+	if tls.Name() != "tls" {
+		t.Errorf("Name() = %q", tls.Name())
+	}
+
+	// A deep modification is visible from the root.
+	tls.Attr("enabled", "false")
+	if !strings.Contains(doc.String(), `enabled="false"`) {
+		t.Errorf("deep change not rendered: %s", doc.String())
+	}
+}
+
+func TestElement_PathRepeated(t *testing.T) {
+	items := []interface{}{
+		map[string]interface{}{"#text": "a"},
+		map[string]interface{}{"#text": "b"},
+	}
+	root := &Element{data: map[string]interface{}{"item": items}, name: "list"}
+	second := &Element{data: items[1].(map[string]interface{}), parent: root, name: "item"}
+
+	if got := second.Path(); got != "/list/item[2]" {
+		t.Errorf("Path() = %q", got)
+	}
+
+	unnamed := NewElement().Child("a", NewElement())
+	a, _ := unnamed.GetChild("a")
+	if got := a.Path(); got != "/a" {
+		t.Errorf("Path() under unnamed root = %q", got)
+	}
+	if got := NewElement().Path(); got != "/" {
+		t.Errorf("Path() of unnamed root = %q", got)
+	}
+}
+
+func TestElement_ParseElementName(t *testing.T) {
+	elem, err := ParseElement(`<?xml version="1.0"?><user id="1"/>`)
+	if err != nil {
+		t.Fatalf("ParseElement failed: %v", err)
+	}
+	if elem.Name() != "user" || elem.Path() != "/user" {
+		t.Errorf("Name() = %q, Path() = %q", elem.Name(), elem.Path())
+	}
+}
+
+func TestElement_Detach(t *testing.T) {
+	root := NewElement().
+		Child("name", NewElement().Text("Alice")).
+		Child("email", NewElement().Text("a@example.com"))
+
+	email, _ := root.GetChild("email")
+	if email.Detach() != email {
+		t.Error("Detach should return the element")
+	}
+	if root.Has("email") {
+		t.Error("email should be removed from the parent")
+	}
+	if _, ok := email.Parent(); ok {
+		t.Error("detached element should have no parent")
+	}
+	if text, _ := email.GetText(); text != "a@example.com" {
+		t.Errorf("detached element lost its content: %q", text)
+	}
+	// Detaching a root is a no-op.
+	root.Detach()
+}
+
+func TestElement_DetachRepeated(t *testing.T) {
+	a := map[string]interface{}{"#text": "a"}
+	b := map[string]interface{}{"#text": "b"}
+	c := map[string]interface{}{"#text": "c"}
+	root := &Element{data: map[string]interface{}{"item": []interface{}{a, b, c}}}
+
+	(&Element{data: b, parent: root, name: "item"}).Detach()
+	items := root.data["item"].([]interface{})
+	if len(items) != 2 || !sameMap(items[0].(map[string]interface{}), a) || !sameMap(items[1].(map[string]interface{}), c) {
+		t.Fatalf("unexpected items after detach: %v", items)
+	}
+
+	(&Element{data: a, parent: root, name: "item"}).Detach()
+	if m, ok := root.data["item"].(map[string]interface{}); !ok || !sameMap(m, c) {
+		t.Errorf("single remaining item should collapse to a map, got %#v", root.data["item"])
+	}
+}
+
+func TestElement_ReplaceWith(t *testing.T) {
+	root := NewElement().Child("status", NewElement().Text("draft"))
+	status, _ := root.GetChild("status")
+
+	replacement := NewElement().Attr("final", "true").Text("published")
+	if err := status.ReplaceWith(replacement); err != nil {
+		t.Fatalf("ReplaceWith failed: %v", err)
+	}
+	got, _ := root.GetChild("status")
+	if text, _ := got.GetText(); text != "published" {
+		t.Errorf("replacement not in place, text = %q", text)
+	}
+	if p, _ := replacement.Parent(); p != root || replacement.Name() != "status" {
+		t.Error("replacement should take over parent and name")
+	}
+	if _, ok := status.Parent(); ok {
+		t.Error("replaced element should be detached")
+	}
+
+	out, err := root.XML("doc")
+	if err != nil {
+		t.Fatalf("XML failed: %v", err)
+	}
+	if !strings.Contains(out, `<status final="true">published</status>`) {
+		t.Errorf("unexpected output %s", out)
+	}
+}
+
+func TestElement_ReplaceWith_Errors(t *testing.T) {
+	if err := NewElement().ReplaceWith(NewElement()); err == nil {
+		t.Error("expected error replacing a root")
+	}
+
+	root := NewElement().Child("a", NewElement().Child("b", NewElement()))
+	a, _ := root.GetChild("a")
+	b, _ := a.GetChild("b")
+	if err := b.ReplaceWith(a); err == nil {
+		t.Error("expected error replacing an element with its ancestor")
+	}
+
+	// Moving an element from another tree detaches it there.
+	other := NewElement().Child("x", NewElement().Text("moved"))
+	x, _ := other.GetChild("x")
+	if err := b.ReplaceWith(x); err != nil {
+		t.Fatalf("ReplaceWith failed: %v", err)
+	}
+	if other.Has("x") {
+		t.Error("moved element should be removed from its old parent")
+	}
+	if got := x.Path(); got != "/a/b" {
+		t.Errorf("Path() after move = %q", got)
+	}
+}
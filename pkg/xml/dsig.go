@@ -0,0 +1,403 @@
+package xml
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+// XML Signature namespace and algorithm identifiers.
+const (
+	dsigNamespace      = "http://www.w3.org/2000/09/xmldsig#"
+	envelopedSignature = dsigNamespace + "enveloped-signature"
+	digestSHA256       = "http://www.w3.org/2001/04/xmlenc#sha256"
+	digestSHA512       = "http://www.w3.org/2001/04/xmlenc#sha512"
+	signatureRSA256    = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	signatureRSA512    = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+	signatureECDSA256  = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+	signatureECDSA512  = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha512"
+)
+
+var digestMethods = map[string]crypto.Hash{
+	digestSHA256: crypto.SHA256,
+	digestSHA512: crypto.SHA512,
+}
+
+var signatureMethods = map[string]crypto.Hash{
+	signatureRSA256:   crypto.SHA256,
+	signatureRSA512:   crypto.SHA512,
+	signatureECDSA256: crypto.SHA256,
+	signatureECDSA512: crypto.SHA512,
+}
+
+// ErrSignatureInvalid is returned, wrapped, by VerifySignature when the
+// signed content was modified or the signature value does not match the
+// key.
+var ErrSignatureInvalid = errors.New("xml: signature is invalid")
+
+// SignOptions configures Sign.
+type SignOptions struct {
+	// IDAttribute names an attribute of the root element, such as "ID" in
+	// SAML, whose value the signature's Reference points at, as in
+	// URI="#_a75adf55". By default the Reference is URI="", the whole
+	// document.
+	IDAttribute string
+
+	// Certificates, DER encoded and leaf first, are written to the
+	// signature's KeyInfo as X509Data, so that a verifier can tell which key
+	// signed the document.
+	Certificates [][]byte
+}
+
+// Sign computes an enveloped XML signature over the document whose root
+// element is named rootName, with the AST node, and returns the document
+// in canonical form with a <ds:Signature> appended as the root's last
+// child. The signature uses Exclusive XML Canonicalization and SHA-256:
+// RSA-SHA256 for RSA keys and ECDSA-SHA256 for ECDSA keys.
+//
+// The signed content is the canonical form of node, so whitespace and
+// ordering the AST does not keep are not signed either: parse with
+// ParseOptions.MixedContent to keep text and child elements in document
+// order, and write the returned bytes as they are.
+//
+// Example:
+//
+//	node, err := xml.ParseWithOptions(invoice, xml.WithMixedContent())
+//	if err != nil {
+//	    return err
+//	}
+//	signed, err := xml.Sign(node, "Invoice", key, xml.SignOptions{Certificates: [][]byte{cert.Raw}})
+func Sign(node ast.SchemaNode, rootName string, signer crypto.Signer, opts SignOptions) ([]byte, error) {
+	var method string
+	switch signer.Public().(type) {
+	case *rsa.PublicKey:
+		method = signatureRSA256
+	case *ecdsa.PublicKey:
+		method = signatureECDSA256
+	default:
+		return nil, fmt.Errorf("xml: cannot sign with a %T key", signer.Public())
+	}
+
+	root := astC14N(rootName, node)
+	uri := ""
+	if opts.IDAttribute != "" {
+		id, ok := root.attr(opts.IDAttribute)
+		if !ok {
+			return nil, fmt.Errorf("xml: root element %q has no %s attribute to refer to", rootName, opts.IDAttribute)
+		}
+		uri = "#" + id
+	}
+	var c canonicalizer
+	doc := &c14nDocument{root: root}
+	content := c.document(doc)
+	digest := crypto.SHA256.New()
+	digest.Write(content)
+
+	var b strings.Builder
+	b.WriteString(`<ds:SignedInfo xmlns:ds="` + dsigNamespace + `">`)
+	b.WriteString(`<ds:CanonicalizationMethod Algorithm="` + excC14N + `"></ds:CanonicalizationMethod>`)
+	b.WriteString(`<ds:SignatureMethod Algorithm="` + method + `"></ds:SignatureMethod>`)
+	b.WriteString(`<ds:Reference URI="`)
+	c14nAttrEscaper.WriteString(&b, uri)
+	b.WriteString(`"><ds:Transforms>`)
+	b.WriteString(`<ds:Transform Algorithm="` + envelopedSignature + `"></ds:Transform>`)
+	b.WriteString(`<ds:Transform Algorithm="` + excC14N + `"></ds:Transform>`)
+	b.WriteString(`</ds:Transforms><ds:DigestMethod Algorithm="` + digestSHA256 + `"></ds:DigestMethod>`)
+	b.WriteString(`<ds:DigestValue>` + base64.StdEncoding.EncodeToString(digest.Sum(nil)) + `</ds:DigestValue>`)
+	b.WriteString(`</ds:Reference></ds:SignedInfo>`)
+	signedInfo := b.String()
+
+	h := crypto.SHA256.New()
+	h.Write([]byte(signedInfo))
+	value, err := signer.Sign(rand.Reader, h.Sum(nil), crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("xml: signing failed: %w", err)
+	}
+	if key, ok := signer.Public().(*ecdsa.PublicKey); ok {
+		// XML signatures hold ECDSA values as r and s concatenated, not
+		// as the ASN.1 structure crypto.Signer returns.
+		if value, err = ecdsaRaw(value, key); err != nil {
+			return nil, err
+		}
+	}
+
+	b.Reset()
+	b.WriteString(`<ds:Signature xmlns:ds="` + dsigNamespace + `">`)
+	b.WriteString(strings.Replace(signedInfo, ` xmlns:ds="`+dsigNamespace+`"`, "", 1))
+	b.WriteString(`<ds:SignatureValue>` + base64.StdEncoding.EncodeToString(value) + `</ds:SignatureValue>`)
+	if len(opts.Certificates) > 0 {
+		b.WriteString(`<ds:KeyInfo><ds:X509Data>`)
+		for _, cert := range opts.Certificates {
+			b.WriteString(`<ds:X509Certificate>` + base64.StdEncoding.EncodeToString(cert) + `</ds:X509Certificate>`)
+		}
+		b.WriteString(`</ds:X509Data></ds:KeyInfo>`)
+	}
+	b.WriteString(`</ds:Signature>`)
+
+	end := len(content) - len("</"+rootName+">")
+	out := make([]byte, 0, len(content)+b.Len())
+	out = append(out, content[:end]...)
+	out = append(out, b.String()...)
+	return append(out, content[end:]...), nil
+}
+
+// ecdsaRaw converts an ASN.1 ECDSA signature into r and s concatenated,
+// each padded to the size of the curve.
+func ecdsaRaw(der []byte, key *ecdsa.PublicKey) ([]byte, error) {
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, fmt.Errorf("xml: signing failed: %w", err)
+	}
+	size := (key.Curve.Params().BitSize + 7) / 8
+	raw := make([]byte, 2*size)
+	sig.R.FillBytes(raw[:size])
+	sig.S.FillBytes(raw[size:])
+	return raw, nil
+}
+
+// VerifySignature checks the enveloped XML signature of input with key, an
+// *rsa.PublicKey or *ecdsa.PublicKey, such as the public key of the
+// certificate of a SAML identity provider. It returns nil only if the root
+// element holds exactly one <Signature> in the XML Signature namespace
+// whose single Reference covers the root element, by URI="" or by the
+// value of the root's ID, Id or id attribute, and whose digest and
+// signature value are valid. A modified document or a signature made with
+// another key is reported with an error wrapping ErrSignatureInvalid.
+//
+// The returned Element is the signed content: the canonical form that was
+// verified, without the signature, parsed with WithMixedContent. Read the
+// document from it rather than from input, which may hold comments and
+// CDATA sections that canonicalization drops or merges into text, so that
+// a parser of input could see text other than what was signed.
+//
+// The document is read as written rather than from an AST, because its
+// canonical form depends on whitespace the AST does not keep. Supported are
+// Exclusive XML Canonicalization without comments, with or without an
+// InclusiveNamespaces PrefixList; SHA-256 and SHA-512 digests; and RSA and
+// ECDSA signatures with SHA-256 or SHA-512. SHA-1 is rejected.
+//
+// Example:
+//
+//	cert, err := x509.ParseCertificate(idpCertificate)
+//	if err != nil {
+//	    return err
+//	}
+//	assertion, err := xml.VerifySignature(response, cert.PublicKey)
+//	if err != nil {
+//	    return err
+//	}
+//	subject, _ := assertion.GetChild("Subject")
+func VerifySignature(input string, key crypto.PublicKey) (*Element, error) {
+	doc, err := readC14N(input)
+	if err != nil {
+		return nil, err
+	}
+	rootScope := doc.root.declares(nsScope{"xml": xmlNamespace})
+
+	var sig *c14nElement
+	for _, item := range doc.root.content {
+		if child, ok := item.(*c14nElement); ok && isDsig(child, rootScope, "Signature") {
+			if sig != nil {
+				return nil, errors.New("xml: root element holds more than one signature")
+			}
+			sig = child
+		}
+	}
+	if sig == nil {
+		return nil, errors.New("xml: root element holds no signature")
+	}
+	sigScope := sig.declares(rootScope)
+	signedInfo := dsigChild(sig, sigScope, "SignedInfo")
+	signatureValue := dsigChild(sig, sigScope, "SignatureValue")
+	if signedInfo == nil || signatureValue == nil {
+		return nil, errors.New("xml: signature has no SignedInfo or SignatureValue")
+	}
+	infoScope := signedInfo.declares(sigScope)
+
+	// The Reference must cover the root element and nothing else.
+	var refs []*c14nElement
+	for _, item := range signedInfo.content {
+		if child, ok := item.(*c14nElement); ok && isDsig(child, infoScope, "Reference") {
+			refs = append(refs, child)
+		}
+	}
+	if len(refs) != 1 {
+		return nil, fmt.Errorf("xml: signature has %d references, want 1", len(refs))
+	}
+	ref := refs[0]
+	refScope := ref.declares(infoScope)
+	uri, _ := ref.attr("URI")
+	if uri != "" && !rootHasID(doc.root, strings.TrimPrefix(uri, "#")) {
+		return nil, fmt.Errorf("xml: signature reference %q does not identify the root element", uri)
+	}
+
+	content := canonicalizer{skip: sig}
+	transforms := dsigChild(ref, refScope, "Transforms")
+	if transforms == nil {
+		return nil, errors.New("xml: signature reference has no transforms")
+	}
+	var algorithms []string
+	for _, item := range transforms.content {
+		if child, ok := item.(*c14nElement); ok && isDsig(child, transforms.declares(refScope), "Transform") {
+			algorithm := child.algorithm()
+			algorithms = append(algorithms, algorithm)
+			if algorithm == excC14N {
+				content.inclusive = inclusivePrefixes(child)
+			}
+		}
+	}
+	if strings.Join(algorithms, " ") != envelopedSignature+" "+excC14N {
+		return nil, fmt.Errorf("xml: unsupported signature transforms %s", strings.Join(algorithms, ", "))
+	}
+
+	digestAlgorithm := dsigChild(ref, refScope, "DigestMethod").algorithm()
+	hash, ok := digestMethods[digestAlgorithm]
+	if !ok {
+		return nil, fmt.Errorf("xml: unsupported digest method %q", digestAlgorithm)
+	}
+	want, err := dsigValue(dsigChild(ref, refScope, "DigestValue"))
+	if err != nil {
+		return nil, fmt.Errorf("xml: invalid digest value: %w", err)
+	}
+	var signed []byte
+	if uri == "" {
+		signed = content.document(doc)
+	} else {
+		signed = content.canonicalize(doc.root, nsScope{"xml": xmlNamespace})
+	}
+	h := hash.New()
+	h.Write(signed)
+	if !bytes.Equal(h.Sum(nil), want) {
+		return nil, fmt.Errorf("%w: digest of the signed content does not match", ErrSignatureInvalid)
+	}
+
+	method := dsigChild(signedInfo, infoScope, "CanonicalizationMethod")
+	if algorithm := method.algorithm(); algorithm != excC14N {
+		return nil, fmt.Errorf("xml: unsupported canonicalization method %q", algorithm)
+	}
+	info := canonicalizer{inclusive: inclusivePrefixes(method)}
+	algorithm := dsigChild(signedInfo, infoScope, "SignatureMethod").algorithm()
+	hash, ok = signatureMethods[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("xml: unsupported signature method %q", algorithm)
+	}
+	value, err := dsigValue(signatureValue)
+	if err != nil {
+		return nil, fmt.Errorf("xml: invalid signature value: %w", err)
+	}
+	h = hash.New()
+	h.Write(info.canonicalize(signedInfo, sigScope))
+	hashed := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.Contains(algorithm, "#rsa-") {
+			return nil, fmt.Errorf("xml: signature method %q does not match an RSA key", algorithm)
+		}
+		if rsa.VerifyPKCS1v15(key, hash, hashed, value) != nil {
+			return nil, fmt.Errorf("%w: signature value does not match the key", ErrSignatureInvalid)
+		}
+	case *ecdsa.PublicKey:
+		if !strings.Contains(algorithm, "#ecdsa-") {
+			return nil, fmt.Errorf("xml: signature method %q does not match an ECDSA key", algorithm)
+		}
+		half := len(value) / 2
+		r, s := new(big.Int).SetBytes(value[:half]), new(big.Int).SetBytes(value[half:])
+		if half == 0 || len(value)%2 != 0 || !ecdsa.Verify(key, hashed, r, s) {
+			return nil, fmt.Errorf("%w: signature value does not match the key", ErrSignatureInvalid)
+		}
+	default:
+		return nil, fmt.Errorf("xml: cannot verify with a %T key", key)
+	}
+	return ParseElementWithOptions(string(signed), WithMixedContent())
+}
+
+// isDsig reports whether e, in scope, is the XML Signature element local.
+func isDsig(e *c14nElement, scope nsScope, local string) bool {
+	name, err := e.declares(scope).resolve(e.name, true)
+	return err == nil && name.Space == dsigNamespace && name.Local == local
+}
+
+// dsigChild returns the first child of e, in scope, that is the XML
+// Signature element local, or nil.
+func dsigChild(e *c14nElement, scope nsScope, local string) *c14nElement {
+	scope = e.declares(scope)
+	for _, item := range e.content {
+		if child, ok := item.(*c14nElement); ok && isDsig(child, scope, local) {
+			return child
+		}
+	}
+	return nil
+}
+
+// algorithm returns the Algorithm attribute of a method or transform
+// element, or "" if e is nil.
+func (e *c14nElement) algorithm() string {
+	if e == nil {
+		return ""
+	}
+	algorithm, _ := e.attr("Algorithm")
+	return algorithm
+}
+
+// dsigValue decodes the base64 text of e, ignoring whitespace.
+func dsigValue(e *c14nElement) ([]byte, error) {
+	if e == nil {
+		return nil, errors.New("missing")
+	}
+	var text strings.Builder
+	for _, item := range e.content {
+		if s, ok := item.(string); ok {
+			text.WriteString(s)
+		}
+	}
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text.String()), ""))
+}
+
+// rootHasID reports whether root has an ID, Id or id attribute with the
+// value id.
+func rootHasID(root *c14nElement, id string) bool {
+	for _, name := range []string{"ID", "Id", "id"} {
+		if value, ok := root.attr(name); ok && value == id {
+			return true
+		}
+	}
+	return false
+}
+
+// inclusivePrefixes returns the PrefixList of the InclusiveNamespaces child
+// of a canonicalization method or transform e, or nil.
+func inclusivePrefixes(e *c14nElement) map[string]bool {
+	if e == nil {
+		return nil
+	}
+	for _, item := range e.content {
+		child, ok := item.(*c14nElement)
+		if !ok {
+			continue
+		}
+		if _, local := SplitName(child.name); local != "InclusiveNamespaces" {
+			continue
+		}
+		list, _ := child.attr("PrefixList")
+		prefixes := make(map[string]bool)
+		for _, prefix := range strings.Fields(list) {
+			if prefix == "#default" {
+				prefix = ""
+			}
+			prefixes[prefix] = true
+		}
+		return prefixes
+	}
+	return nil
+}
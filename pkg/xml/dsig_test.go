@@ -0,0 +1,196 @@
+package xml
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const invoiceXML = `<?xml version="1.0"?>
+<Invoice xmlns="urn:example:invoice" xmlns:cac="urn:example:cac" ID="_inv1">
+  <cac:Supplier name="ACME &amp; Co">ACME</cac:Supplier>
+  <Line qty="2">Widgets <b>large</b></Line>
+  <Total currency="EUR">10.00</Total>
+</Invoice>`
+
+func signInvoice(t *testing.T, signer crypto.Signer, opts SignOptions) string {
+	t.Helper()
+	node, err := ParseWithOptions(invoiceXML, WithMixedContent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := Sign(node, "Invoice", signer, opts)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	return string(signed)
+}
+
+func TestSignAndVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name   string
+		signer crypto.Signer
+		opts   SignOptions
+	}{
+		{"rsa", rsaKey, SignOptions{}},
+		{"ecdsa", ecKey, SignOptions{}},
+		{"id reference", rsaKey, SignOptions{IDAttribute: "ID", Certificates: [][]byte{[]byte("cert")}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			signed := signInvoice(t, tt.signer, tt.opts)
+			if !strings.HasSuffix(signed, "</ds:Signature></Invoice>") {
+				t.Errorf("signature is not the root's last child:\n%s", signed)
+			}
+			if _, err := VerifySignature(signed, tt.signer.Public()); err != nil {
+				t.Fatalf("VerifySignature = %v\n%s", err, signed)
+			}
+
+			// The signed document is still parsed as usual.
+			if _, err := Parse(signed); err != nil {
+				t.Errorf("Parse(signed) = %v", err)
+			}
+
+			// Formatting outside the signed content may change.
+			reformatted := `<?xml version="1.0" encoding="UTF-8"?>` + "\n<!-- signed -->\n" +
+				strings.Replace(signed, "<ds:SignatureValue>", "\n  <ds:SignatureValue>", 1)
+			if _, err := VerifySignature(reformatted, tt.signer.Public()); err != nil {
+				t.Errorf("VerifySignature(reformatted) = %v", err)
+			}
+		})
+	}
+
+	signed := signInvoice(t, rsaKey, SignOptions{IDAttribute: "ID"})
+	if !strings.Contains(signed, `<ds:Reference URI="#_inv1">`) {
+		t.Errorf("reference does not point at the root's ID:\n%s", signed)
+	}
+	if !strings.Contains(signInvoice(t, rsaKey, SignOptions{Certificates: [][]byte{{1, 2, 3}}}), "<ds:X509Certificate>AQID</ds:X509Certificate>") {
+		t.Error("certificate missing from KeyInfo")
+	}
+}
+
+func TestVerifySignature_ReturnsSignedContent(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := ParseWithOptions(`<Assertion ID="_a1"><NameID>user@user.com.evil.com</NameID></Assertion>`, WithMixedContent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := Sign(node, "Assertion", key, SignOptions{IDAttribute: "ID"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// CDATA and comments leave the canonical form, and so the signature,
+	// unchanged, but split the text a parser of the input sees.
+	for _, tampered := range []string{
+		strings.Replace(string(signed), ".evil.com", "<![CDATA[.evil.com]]>", 1),
+		strings.Replace(string(signed), ".evil.com", "<!---->.evil.com", 1),
+	} {
+		assertion, err := VerifySignature(tampered, &key.PublicKey)
+		if err != nil {
+			t.Fatalf("VerifySignature = %v\n%s", err, tampered)
+		}
+		nameID, ok := assertion.GetChild("NameID")
+		if !ok {
+			t.Fatalf("no NameID in %v", assertion.ToMap())
+		}
+		if text, _ := nameID.GetText(); text != "user@user.com.evil.com" {
+			t.Errorf("NameID = %q, want the signed text", text)
+		}
+		if _, ok := assertion.GetChild("ds:Signature"); ok {
+			t.Error("signed content holds the signature")
+		}
+	}
+}
+
+func TestVerifySignature_Invalid(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := signInvoice(t, key, SignOptions{IDAttribute: "ID"})
+
+	tampered := []struct {
+		name  string
+		input string
+		key   crypto.PublicKey
+	}{
+		{"content", strings.Replace(signed, "10.00", "1000.00", 1), &key.PublicKey},
+		{"attribute", strings.Replace(signed, `qty="2"`, `qty="20"`, 1), &key.PublicKey},
+		{"digest", strings.Replace(signed, "<ds:DigestValue>", "<ds:DigestValue>AAAA", 1), &key.PublicKey},
+		{"signed info", strings.Replace(signed, "</ds:SignedInfo>", " </ds:SignedInfo>", 1), &key.PublicKey},
+		{"other key", signed, &other.PublicKey},
+	}
+	for _, tt := range tampered {
+		if _, err := VerifySignature(tt.input, tt.key); !errors.Is(err, ErrSignatureInvalid) {
+			t.Errorf("%s: VerifySignature = %v, want ErrSignatureInvalid", tt.name, err)
+		}
+	}
+
+	rejected := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"unsigned", invoiceXML, "no signature"},
+		{"reference", strings.Replace(signed, `URI="#_inv1"`, `URI="#other"`, 1), "does not identify the root"},
+		{"sha1", strings.Replace(signed, "xmlenc#sha256", "xmldsig#sha1", 1), "unsupported digest method"},
+		{"transforms", strings.Replace(signed, "enveloped-signature", "base64", 1), "unsupported signature transforms"},
+		{"malformed", signed[:len(signed)-3], ""},
+	}
+	for _, tt := range rejected {
+		_, err := VerifySignature(tt.input, &key.PublicKey)
+		if err == nil || errors.Is(err, ErrSignatureInvalid) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: VerifySignature = %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifySignature(signed, &ecKey.PublicKey); err == nil || !strings.Contains(err.Error(), "does not match an ECDSA key") {
+		t.Errorf("VerifySignature with an ECDSA key = %v", err)
+	}
+}
+
+func TestSign_Errors(t *testing.T) {
+	node, err := Parse(`<r/>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Sign(node, "r", edKey, SignOptions{}); err == nil {
+		t.Error("Sign with an Ed25519 key: expected an error")
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Sign(node, "r", key, SignOptions{IDAttribute: "ID"}); err == nil || !strings.Contains(err.Error(), "no ID attribute") {
+		t.Errorf("Sign without the ID attribute = %v", err)
+	}
+}
@@ -0,0 +1,388 @@
+package xml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/shapestone/shape-xml/internal/dtd"
+)
+
+// DTD is a document type definition read from an external subset, such as
+// the .dtd file a legacy document format ships with. Parse it once with
+// ParseDTD and validate any number of documents with Validate. A DTD is
+// safe for concurrent use.
+type DTD struct {
+	src []byte
+}
+
+// ParseDTD parses the declarations of an external subset. Element,
+// attribute-list and entity declarations are used for validation;
+// parameter entities are not expanded, and declarations that reference one
+// are ignored. Conditional sections (<![INCLUDE[ and <![IGNORE[) are not
+// supported.
+//
+// Example:
+//
+//	schema, err := os.ReadFile("invoice.dtd")
+//	if err != nil {
+//	    return err
+//	}
+//	invoiceDTD, err := xml.ParseDTD(string(schema))
+//	if err != nil {
+//	    return err
+//	}
+//	if err := invoiceDTD.Validate(input); err != nil {
+//	    fmt.Println(err)
+//	}
+func ParseDTD(src string) (*DTD, error) {
+	if err := new(dtd.Doctype).ParseExternal([]byte(src)); err != nil {
+		return nil, fmt.Errorf("xml: in DTD %v", err)
+	}
+	return &DTD{src: []byte(src)}, nil
+}
+
+// Validate checks that input is well-formed and valid against the DTD.
+// Declarations in the internal subset of the document's DOCTYPE come
+// first and take precedence; the DOCTYPE's SYSTEM identifier is never
+// loaded. A document without a DOCTYPE is validated against d alone.
+//
+// A document that is not well-formed is reported with the parse error.
+// Otherwise every violation is reported as a *ValidationError, joined with
+// errors.Join, so that NewReport counts each one:
+//
+//   - an element or attribute that is not declared
+//   - content that does not match the element's content model, such as a
+//     missing or unexpected child, or text where only elements may appear
+//   - a missing #REQUIRED attribute, or a #FIXED attribute with another value
+//   - an attribute value that does not match its type: an enumeration, an
+//     ID that is not unique, an IDREF without a matching ID, or a malformed
+//     name or name token
+//   - a root element other than the one the DOCTYPE names
+func (d *DTD) Validate(input string) error {
+	var external []byte
+	if d != nil {
+		external = d.src
+	}
+	return validateDTD(input, external)
+}
+
+// ValidateDTD checks that input is well-formed and valid against the
+// element and attribute-list declarations of its DOCTYPE's internal
+// subset. It reports violations as DTD.Validate does, and returns an error
+// for a document without a DOCTYPE. External subsets are never loaded; to
+// validate against one, use ParseDTD.
+//
+// Example:
+//
+//	input := `<!DOCTYPE note [
+//	  <!ELEMENT note (to, body)>
+//	  <!ELEMENT to (#PCDATA)>
+//	  <!ELEMENT body (#PCDATA)>
+//	]>
+//	<note><body>Hi</body></note>`
+//	err := xml.ValidateDTD(input)
+//	// xml: /note (line 6, column 1): element "note" content (body) does not match (to, body)
+func ValidateDTD(input string) error {
+	return validateDTD(input, nil)
+}
+
+// ValidationError is a violation of a schema, reported by ValidateDTD,
+// DTD.Validate and RNCSchema.Validate.
+type ValidationError struct {
+	// Path locates the element from the root, e.g. "/order/item[2]".
+	// Repeated elements carry their 1-based position among same-named
+	// siblings.
+	Path string
+	// Line and Column give the 1-based position of the element's start tag.
+	Line, Column int
+	// Msg describes the violation.
+	Msg string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("xml: %s (line %d, column %d): %s", e.Path, e.Line, e.Column, e.Msg)
+}
+
+// dtdNode is an element seen by the validator. Its path is only built once
+// the document is complete, when the number of same-named siblings is
+// known.
+type dtdNode struct {
+	parent *dtdNode
+	name   string
+	// index is the 1-based position among same-named siblings.
+	index int
+	// counts holds the number of children by name.
+	counts map[string]int
+	offset int64
+}
+
+func (n *dtdNode) path() string {
+	var segments []string
+	for ; n != nil; n = n.parent {
+		seg := n.name
+		if n.parent != nil && n.parent.counts[n.name] > 1 {
+			seg += "[" + strconv.Itoa(n.index) + "]"
+		}
+		segments = append(segments, seg)
+	}
+	var b strings.Builder
+	for i := len(segments) - 1; i >= 0; i-- {
+		b.WriteString("/" + segments[i])
+	}
+	return b.String()
+}
+
+// dtdFrame is an open element and the content read so far.
+type dtdFrame struct {
+	node     *dtdNode
+	decl     *dtd.ElementDecl
+	children []string
+	// text is set by non-whitespace character data, space by whitespace.
+	text, space bool
+	cdata       bool
+}
+
+// dtdViolation is a ValidationError whose location is resolved at the end.
+type dtdViolation struct {
+	node *dtdNode
+	msg  string
+}
+
+// dtdRef is an IDREF value to check once all IDs are known.
+type dtdRef struct {
+	node  *dtdNode
+	attr  string
+	value string
+}
+
+type dtdValidator struct {
+	doctype    *dtd.Doctype
+	stack      []*dtdFrame
+	ids        map[string]bool
+	refs       []dtdRef
+	violations []dtdViolation
+}
+
+// validateDTD validates input against the DOCTYPE's internal subset
+// followed by the external subset external, if not nil.
+func validateDTD(input string, external []byte) error {
+	dec := NewDecoder(strings.NewReader(input))
+	dec.external = external
+	if external != nil {
+		// Replaced when the document has a DOCTYPE.
+		dec.doctype = new(dtd.Doctype)
+		if err := dec.doctype.ParseExternal(external); err != nil {
+			return fmt.Errorf("xml: in DTD %v", err)
+		}
+	}
+	v := &dtdValidator{ids: make(map[string]bool)}
+	for {
+		tok, err := dec.readToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if v.doctype == nil {
+			if dec.doctype == nil {
+				return errors.New("xml: document has no DOCTYPE to validate against")
+			}
+			v.doctype = dec.doctype
+		}
+		switch tok.kind {
+		case tokenStart:
+			v.start(tok, dec.openAt[len(dec.openAt)-1])
+		case tokenEnd:
+			v.end()
+		case tokenText:
+			top := v.stack[len(v.stack)-1]
+			if strings.TrimSpace(tok.text) != "" {
+				top.text = true
+			} else if tok.text != "" {
+				top.space = true
+			}
+		case tokenCDATA:
+			v.stack[len(v.stack)-1].cdata = true
+		}
+	}
+	for _, ref := range v.refs {
+		if !v.ids[ref.value] {
+			v.report(ref.node, fmt.Sprintf("attribute %q refers to ID %q, which does not exist", ref.attr, ref.value))
+		}
+	}
+	if len(v.violations) == 0 {
+		return nil
+	}
+	errs := make([]error, len(v.violations))
+	for i, violation := range v.violations {
+		line, column := lineColumn(input, violation.node.offset)
+		errs[i] = &ValidationError{Path: violation.node.path(), Line: line, Column: column, Msg: violation.msg}
+	}
+	return errors.Join(errs...)
+}
+
+func (v *dtdValidator) report(node *dtdNode, msg string) {
+	v.violations = append(v.violations, dtdViolation{node: node, msg: msg})
+}
+
+// start checks a start tag at offset and opens its element.
+func (v *dtdValidator) start(tok rawToken, offset int64) {
+	node := &dtdNode{name: tok.name, index: 1, offset: offset}
+	if len(v.stack) > 0 {
+		parent := v.stack[len(v.stack)-1]
+		parent.children = append(parent.children, tok.name)
+		node.parent = parent.node
+		if node.parent.counts == nil {
+			node.parent.counts = make(map[string]int)
+		}
+		node.parent.counts[tok.name]++
+		node.index = node.parent.counts[tok.name]
+	} else if v.doctype.Name != "" && tok.name != v.doctype.Name {
+		v.report(node, fmt.Sprintf("root element %q does not match DOCTYPE %q", tok.name, v.doctype.Name))
+	}
+
+	decl := v.doctype.Element(tok.name)
+	if decl == nil {
+		v.report(node, fmt.Sprintf("element %q is not declared", tok.name))
+	}
+	v.stack = append(v.stack, &dtdFrame{node: node, decl: decl})
+
+	for _, attr := range tok.attrs {
+		def := v.doctype.Attribute(tok.name, attr.name)
+		if def == nil {
+			v.report(node, fmt.Sprintf("attribute %q is not declared", attr.name))
+			continue
+		}
+		v.checkAttr(node, def, attr.value)
+	}
+	for _, def := range v.doctype.Attributes[tok.name] {
+		if def.Default != "#REQUIRED" {
+			continue
+		}
+		found := false
+		for _, attr := range tok.attrs {
+			if attr.name == def.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.report(node, fmt.Sprintf("required attribute %q is missing", def.Name))
+		}
+	}
+}
+
+// checkAttr checks the value of an attribute against its definition.
+func (v *dtdValidator) checkAttr(node *dtdNode, def *dtd.AttDecl, value string) {
+	if def.Type != "CDATA" {
+		// Tokenized attribute values are compared with runs of spaces
+		// collapsed and leading and trailing spaces removed.
+		value = strings.Join(strings.Fields(value), " ")
+	}
+	if def.Default == "#FIXED" {
+		fixed := def.Value
+		if def.Type != "CDATA" {
+			fixed = strings.Join(strings.Fields(fixed), " ")
+		}
+		if value != fixed {
+			v.report(node, fmt.Sprintf("attribute %q is %q, but fixed to %q", def.Name, value, fixed))
+		}
+	}
+
+	switch def.Type {
+	case "", "NOTATION":
+		for _, allowed := range def.Enum {
+			if value == allowed {
+				return
+			}
+		}
+		v.report(node, fmt.Sprintf("attribute %q is %q, not one of %s", def.Name, value, strings.Join(def.Enum, ", ")))
+	case "ID":
+		switch {
+		case !isValidXMLName(value):
+			v.report(node, fmt.Sprintf("ID attribute %q is %q, which is not a name", def.Name, value))
+		case v.ids[value]:
+			v.report(node, fmt.Sprintf("ID %q is not unique", value))
+		default:
+			v.ids[value] = true
+		}
+	case "IDREF", "IDREFS", "ENTITY", "ENTITIES", "NMTOKEN", "NMTOKENS":
+		tokens := []string{value}
+		if strings.HasSuffix(def.Type, "S") {
+			tokens = strings.Fields(value)
+		}
+		if len(tokens) == 0 {
+			v.report(node, fmt.Sprintf("%s attribute %q is empty", def.Type, def.Name))
+			return
+		}
+		for _, tok := range tokens {
+			valid := isValidXMLName(tok)
+			if strings.HasPrefix(def.Type, "NMTOKEN") {
+				valid = isNameToken(tok)
+			}
+			if !valid {
+				v.report(node, fmt.Sprintf("%s attribute %q holds %q, which is not a valid %s", def.Type, def.Name, tok, strings.ToLower(strings.TrimSuffix(def.Type, "S"))))
+				continue
+			}
+			if strings.HasPrefix(def.Type, "IDREF") {
+				v.refs = append(v.refs, dtdRef{node: node, attr: def.Name, value: tok})
+			}
+		}
+	}
+}
+
+// end checks the content of the innermost element and closes it.
+func (v *dtdValidator) end() {
+	top := v.stack[len(v.stack)-1]
+	v.stack = v.stack[:len(v.stack)-1]
+	decl := top.decl
+	if decl == nil {
+		return
+	}
+	name := top.node.name
+	switch decl.Content {
+	case dtd.ContentEmpty:
+		if len(top.children) > 0 || top.text || top.space || top.cdata {
+			v.report(top.node, fmt.Sprintf("element %q is declared EMPTY but has content", name))
+		}
+		return
+	case dtd.ContentChildren:
+		if top.text || top.cdata {
+			v.report(top.node, fmt.Sprintf("element %q may only contain elements %s, not text", name, decl))
+		}
+	}
+	if !decl.Allows(top.children) {
+		v.report(top.node, fmt.Sprintf("element %q content (%s) does not match %s", name, strings.Join(top.children, ", "), decl))
+	}
+}
+
+// isNameToken reports whether s matches the XML 1.0 Nmtoken production.
+func isNameToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !isNameRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// lineColumn converts a byte offset into a 1-based line and column.
+func lineColumn(s string, offset int64) (line, column int) {
+	line, column = 1, 1
+	for _, c := range []byte(s[:offset]) {
+		if c == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return line, column
+}
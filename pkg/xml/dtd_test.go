@@ -0,0 +1,173 @@
+package xml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const orderDTD = `
+<!ELEMENT order (customer, item+, note?)>
+<!ATTLIST order
+    id       ID             #REQUIRED
+    currency (EUR|USD)      "EUR"
+    version  CDATA          #FIXED "2">
+<!ELEMENT customer (#PCDATA)>
+<!ELEMENT item EMPTY>
+<!ATTLIST item
+    sku   NMTOKEN  #REQUIRED
+    ref   IDREF    #IMPLIED
+    tags  NMTOKENS #IMPLIED>
+<!ELEMENT note (#PCDATA | b)*>
+<!ELEMENT b (#PCDATA)>
+`
+
+func TestValidateDTD(t *testing.T) {
+	valid := `<?xml version="1.0"?>
+<!DOCTYPE order [` + orderDTD + `]>
+<order id="o1" currency=" USD " version="2">
+  <customer>Ann &amp; Bob</customer>
+  <item sku="A-1" ref="o1" tags=" red  blue "/>
+  <item sku="B.2"></item>
+  <!-- comment -->
+  <note>ship <b>fast</b><![CDATA[<now>]]></note>
+</order>`
+	if err := ValidateDTD(valid); err != nil {
+		t.Fatalf("ValidateDTD(valid) = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"missing child", `<order id="o1"><item sku="a"/></order>`,
+			[]string{`xml: /order (line 16, column 1): element "order" content (item) does not match (customer, item+, note?)`}},
+		{"undeclared element", `<order id="o1"><customer/><item sku="a"/><extra/></order>`,
+			[]string{`/order/extra (line 16, column 42): element "extra" is not declared`, `content (customer, item, extra)`}},
+		{"text in element content", `<order id="o1">hi<customer/><item sku="a"/></order>`,
+			[]string{`element "order" may only contain elements (customer, item+, note?), not text`}},
+		{"empty with content", `<order id="o1"><customer/><item sku="a"> </item></order>`,
+			[]string{`/order/item (line 16, column 27): element "item" is declared EMPTY but has content`}},
+		{"mixed", `<order id="o1"><customer/><item sku="a"/><note><i/></note></order>`,
+			[]string{`element "i" is not declared`, `element "note" content (i) does not match (#PCDATA | b)*`}},
+		{"required attribute", `<order><customer/><item/></order>`,
+			[]string{`/order (line 16, column 1): required attribute "id" is missing`, `/order/item (line 16, column 19): required attribute "sku" is missing`}},
+		{"undeclared attribute", `<order id="o1" x="1"><customer/><item sku="a"/></order>`,
+			[]string{`attribute "x" is not declared`}},
+		{"enumeration", `<order id="o1" currency="GBP"><customer/><item sku="a"/></order>`,
+			[]string{`attribute "currency" is "GBP", not one of EUR, USD`}},
+		{"fixed", `<order id="o1" version="3"><customer/><item sku="a"/></order>`,
+			[]string{`attribute "version" is "3", but fixed to "2"`}},
+		{"id", `<order id="1"><customer/><item sku="a"/></order>`,
+			[]string{`ID attribute "id" is "1", which is not a name`}},
+		{"idref", `<order id="o1"><customer/><item sku="a" ref="o2"/><item sku="b" ref="o1"/></order>`,
+			[]string{`/order/item[1] (line 16, column 27): attribute "ref" refers to ID "o2", which does not exist`}},
+		{"nmtoken", `<order id="o1"><customer/><item sku="a b" tags="x y!"/></order>`,
+			[]string{`NMTOKEN attribute "sku" holds "a b", which is not a valid nmtoken`, `NMTOKENS attribute "tags" holds "y!"`}},
+		{"root", `<customer/>`,
+			[]string{`/customer (line 16, column 1): root element "customer" does not match DOCTYPE "order"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateDTD(`<!DOCTYPE order [` + orderDTD + "]>\n" + tt.body)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			msgs := errorMessages(err)
+			if len(msgs) != len(tt.want) {
+				t.Errorf("got %d errors, want %d:\n%s", len(msgs), len(tt.want), err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Errorf("error %T is not a *ValidationError", err)
+			}
+		})
+	}
+}
+
+func TestValidateDTD_Position(t *testing.T) {
+	input := "<!DOCTYPE r [\n<!ELEMENT r (a*)>\n<!ELEMENT a EMPTY>\n]>\n<r>\n  <a/>\n  <a>x</a>\n</r>"
+	err := ValidateDTD(input)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("ValidateDTD = %v, want a *ValidationError", err)
+	}
+	want := ValidationError{Path: "/r/a[2]", Line: 7, Column: 3, Msg: `element "a" is declared EMPTY but has content`}
+	if *verr != want {
+		t.Errorf("got %+v, want %+v", *verr, want)
+	}
+}
+
+func TestValidateDTD_Errors(t *testing.T) {
+	if err := ValidateDTD(`<r/>`); err == nil || !strings.Contains(err.Error(), "no DOCTYPE") {
+		t.Errorf("document without DOCTYPE: %v", err)
+	}
+	if err := ValidateDTD(`<!DOCTYPE r [<!ELEMENT r ANY>]><r><a></r>`); err == nil || errors.As(err, new(*ValidationError)) {
+		t.Errorf("malformed document: got %v, want the parse error", err)
+	}
+	if err := ValidateDTD(`<!DOCTYPE r [<!ELEMENT r (a,)>]><r/>`); err == nil {
+		t.Error("malformed declaration: expected an error")
+	}
+}
+
+func TestDTD_Validate(t *testing.T) {
+	d, err := ParseDTD(`<?xml version="1.0" encoding="UTF-8"?>` + orderDTD + `<!ENTITY shop "Example Shop">`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := `<order id="o1"><customer>&shop;</customer><item sku="a"/></order>`
+	if err := d.Validate(input); err != nil {
+		t.Errorf("Validate without DOCTYPE = %v", err)
+	}
+	if err := d.Validate(`<order id="o1"><item sku="a"/></order>`); err == nil {
+		t.Error("expected a content model error")
+	}
+
+	// The internal subset takes precedence over the external DTD.
+	input = `<!DOCTYPE order SYSTEM "order.dtd" [<!ATTLIST order version CDATA #FIXED "3">]>` +
+		`<order id="o1" version="3"><customer/><item sku="a"/></order>`
+	if err := d.Validate(input); err != nil {
+		t.Errorf("Validate with internal subset = %v", err)
+	}
+	if err := ValidateDTD(input); err == nil {
+		t.Error("ValidateDTD used declarations outside the internal subset")
+	}
+
+	if _, err := ParseDTD(`<!ELEMENT r (a`); err == nil {
+		t.Error("ParseDTD: expected an error for a malformed DTD")
+	}
+}
+
+func TestParse_DoctypeMarkupInSubset(t *testing.T) {
+	parsers := map[string]func(string) error{
+		"Parse":      func(s string) error { _, err := Parse(s); return err },
+		"ParseBytes": func(s string) error { _, err := ParseBytes([]byte(s)); return err },
+		"ParseReader": func(s string) error {
+			_, err := ParseReader(strings.NewReader(s))
+			return err
+		},
+		"Unmarshal": func(s string) error { var v interface{}; return Unmarshal([]byte(s), &v) },
+	}
+	for name, parse := range parsers {
+		for _, doc := range []string{
+			`<!DOCTYPE a [<!-- it's -->]><a/>`,
+			`<!DOCTYPE a [<!-- ] > -->]><a/>`,
+			`<!DOCTYPE a SYSTEM "x[y"><a/>`,
+		} {
+			if err := parse(doc); err != nil {
+				t.Errorf("%s(%s): %v", name, doc, err)
+			}
+		}
+		err := parse(`<!DOCTYPE a [garbage]><a/>`)
+		var se *SyntaxError
+		if !errors.As(err, &se) || se.Column != 14 || !strings.Contains(err.Error(), "in DOCTYPE") {
+			t.Errorf("%s: malformed DOCTYPE error = %v, want a syntax error at column 14", name, err)
+		}
+	}
+}
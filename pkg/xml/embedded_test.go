@@ -0,0 +1,88 @@
+package xml
+
+import (
+	"reflect"
+	"testing"
+)
+
+type embeddedBase struct {
+	ID   string `xml:"id,attr"`
+	Name string `xml:"name"`
+	Note string `xml:"note"`
+}
+
+type EmbeddedMeta struct {
+	Author string `xml:"author"`
+}
+
+type embeddedDoc struct {
+	embeddedBase
+	*EmbeddedMeta
+	Note string `xml:"note"` // hides embeddedBase.Note
+	Own  string `xml:"own"`
+}
+
+func TestMarshal_EmbeddedFieldsPromoted(t *testing.T) {
+	in := embeddedDoc{
+		embeddedBase: embeddedBase{ID: "1", Name: "n", Note: "hidden"},
+		EmbeddedMeta: &EmbeddedMeta{Author: "a"},
+		Note:         "outer",
+		Own:          "o",
+	}
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `<embeddedDoc id="1"><name>n</name><author>a</author><note>outer</note><own>o</own></embeddedDoc>`
+	if string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+
+	var out embeddedDoc
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	in.embeddedBase.Note = ""
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("Unmarshal(%s) = %+v, want %+v", data, out, in)
+	}
+}
+
+func TestMarshal_NilEmbeddedPointer(t *testing.T) {
+	data, err := Marshal(embeddedDoc{Own: "o"})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if want := `<embeddedDoc><name></name><note></note><own>o</own></embeddedDoc>`; string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+
+	var out embeddedDoc
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out.EmbeddedMeta != nil {
+		t.Errorf("EmbeddedMeta = %+v, want nil for a document without its fields", out.EmbeddedMeta)
+	}
+}
+
+func TestMarshal_TaggedEmbeddedStruct(t *testing.T) {
+	type doc struct {
+		EmbeddedMeta `xml:"meta"`
+	}
+	in := doc{EmbeddedMeta{Author: "a"}}
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if want := `<doc><meta><author>a</author></meta></doc>`; string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+	var out doc
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out != in {
+		t.Errorf("Unmarshal = %+v, want %+v", out, in)
+	}
+}
@@ -0,0 +1,66 @@
+package xml
+
+import "github.com/shapestone/shape-core/pkg/ast"
+
+// isEmptyElement reports whether m is an element without attributes and
+// content, or one marked xsi:nil="true".
+func isEmptyElement(m map[string]interface{}) bool {
+	return len(m) == 0 || m["@xsi:nil"] == "true"
+}
+
+// emptyToNil replaces the empty child elements of v with nil, in place.
+func emptyToNil(v interface{}) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			if m, ok := child.(map[string]interface{}); ok && isEmptyElement(m) {
+				val[k] = nil
+				continue
+			}
+			emptyToNil(child)
+		}
+	case []interface{}:
+		for i, item := range val {
+			if m, ok := item.(map[string]interface{}); ok && isEmptyElement(m) {
+				val[i] = nil
+				continue
+			}
+			emptyToNil(item)
+		}
+	}
+}
+
+// isEmptyElementNode is isEmptyElement for the AST.
+func isEmptyElementNode(obj *ast.ObjectNode) bool {
+	props := obj.Properties()
+	if len(props) == 0 {
+		return true
+	}
+	lit, ok := props["@xsi:nil"].(*ast.LiteralNode)
+	return ok && lit.Value() == "true"
+}
+
+// emptyToNilNodes is emptyToNil for the AST: empty elements become nil
+// literals.
+func emptyToNilNodes(node ast.SchemaNode) {
+	switch n := node.(type) {
+	case *ast.ObjectNode:
+		props := n.Properties()
+		for k, child := range props {
+			if obj, ok := child.(*ast.ObjectNode); ok && isEmptyElementNode(obj) {
+				props[k] = ast.NewLiteralNode(nil, obj.Position())
+				continue
+			}
+			emptyToNilNodes(child)
+		}
+	case *ast.ArrayDataNode:
+		elems := n.Elements()
+		for i, elem := range elems {
+			if obj, ok := elem.(*ast.ObjectNode); ok && isEmptyElementNode(obj) {
+				elems[i] = ast.NewLiteralNode(nil, obj.Position())
+				continue
+			}
+			emptyToNilNodes(elem)
+		}
+	}
+}
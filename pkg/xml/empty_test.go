@@ -0,0 +1,235 @@
+package xml
+
+import (
+	"reflect"
+	"testing"
+)
+
+const emptyDoc = `<r><selfclosed/><pair></pair><blank>  </blank><attr x=""/><nil xsi:nil="true"/></r>`
+
+func TestEmptyElements_Map(t *testing.T) {
+	var v map[string]interface{}
+	if err := Unmarshal([]byte(emptyDoc), &v); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	for _, name := range []string{"selfclosed", "pair", "blank"} {
+		if got, ok := v[name]; !ok || !reflect.DeepEqual(got, map[string]interface{}{}) {
+			t.Errorf("%s = %#v, want an empty map", name, got)
+		}
+	}
+	if _, ok := v["missing"]; ok {
+		t.Error("missing element has a key")
+	}
+
+	var withNil map[string]interface{}
+	if err := UnmarshalWithOptions([]byte(emptyDoc), &withNil, WithEmptyAsNil()); err != nil {
+		t.Fatalf("UnmarshalWithOptions failed: %v", err)
+	}
+	want := map[string]interface{}{
+		"selfclosed": nil,
+		"pair":       nil,
+		"blank":      nil,
+		"attr":       map[string]interface{}{"@x": ""},
+		"nil":        nil,
+	}
+	if !reflect.DeepEqual(withNil, want) {
+		t.Errorf("WithEmptyAsNil gave %#v", withNil)
+	}
+}
+
+func TestEmptyElements_Struct(t *testing.T) {
+	type doc struct {
+		SelfClosed *string   `xml:"selfclosed"`
+		Blank      *int      `xml:"blank"`
+		Nil        *float64  `xml:"nil"`
+		Missing    *string   `xml:"missing"`
+		List       []string  `xml:"pair"`
+		Attr       *struct{} `xml:"attr"`
+	}
+
+	var d doc
+	if err := Unmarshal([]byte(emptyDoc), &d); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if d.SelfClosed == nil || *d.SelfClosed != "" {
+		t.Errorf("SelfClosed = %v, want pointer to empty string", d.SelfClosed)
+	}
+	if d.Blank == nil || *d.Blank != 0 {
+		t.Errorf("Blank = %v, want pointer to zero", d.Blank)
+	}
+	if d.Nil != nil || d.Missing != nil {
+		t.Errorf("Nil and Missing should be nil: %+v", d)
+	}
+	if !reflect.DeepEqual(d.List, []string{""}) {
+		t.Errorf("List = %q, want one empty item", d.List)
+	}
+
+	var n doc
+	if err := UnmarshalWithOptions([]byte(emptyDoc), &n, WithEmptyAsNil()); err != nil {
+		t.Fatalf("UnmarshalWithOptions failed: %v", err)
+	}
+	if n.SelfClosed != nil || n.Blank != nil || n.Nil != nil || n.Missing != nil {
+		t.Errorf("WithEmptyAsNil left pointers set: %+v", n)
+	}
+	if n.Attr == nil {
+		t.Error("element with attributes must not be empty")
+	}
+}
+
+func TestEmptyElements_NilPointerRoundTrip(t *testing.T) {
+	type doc struct {
+		Name  *string `xml:"name"`
+		Empty *string `xml:"empty"`
+	}
+	empty := ""
+	in := doc{Empty: &empty}
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != `<doc><empty></empty></doc>` {
+		t.Fatalf("Marshal = %s", data)
+	}
+
+	// The nil pointer is left out, so it stays nil. <empty></empty> is an
+	// empty element: it decodes as nil with WithEmptyAsNil and as "" without.
+	var out doc
+	if err := UnmarshalWithOptions(data, &out, WithEmptyAsNil()); err != nil {
+		t.Fatalf("UnmarshalWithOptions failed: %v", err)
+	}
+	if out.Name != nil || out.Empty != nil {
+		t.Errorf("got %+v", out)
+	}
+	out = doc{}
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out.Name != nil || out.Empty == nil || *out.Empty != "" {
+		t.Errorf("got %+v", out)
+	}
+}
+
+func TestMarshal_NilPointerFieldsRoundTrip(t *testing.T) {
+	type inner struct {
+		V string `xml:"v"`
+	}
+	type doc struct {
+		P *int               `xml:"p"`
+		S *string            `xml:"s"`
+		I *inner             `xml:"i"`
+		A *string            `xml:"a,attr"`
+		W *int               `xml:"w>x"`
+		E interface{}        `xml:"e"`
+		L []*inner           `xml:"l"`
+		M *map[string]string `xml:"m"`
+	}
+	data, err := Marshal(doc{})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	// A nil slice writes nothing, so it stays nil.
+	if string(data) != `<doc/>` {
+		t.Errorf("Marshal = %s", data)
+	}
+	var out doc
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(out, doc{}) {
+		t.Errorf("Unmarshal = %+v, want the zero value", out)
+	}
+
+	// Set pointers to zero values still round-trip as such.
+	zero, empty := 0, ""
+	in := doc{P: &zero, S: &empty, I: &inner{}}
+	if data, err = Marshal(in); err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	out = doc{}
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal(%s) failed: %v", data, err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("Unmarshal(%s) = %+v, want %+v", data, out, in)
+	}
+}
+
+func TestEmptyElements_AST(t *testing.T) {
+	node, err := ParseWithOptions(emptyDoc, WithEmptyAsNil())
+	if err != nil {
+		t.Fatalf("ParseWithOptions failed: %v", err)
+	}
+	data, err := Render(node)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if got := string(data); got != `<root><attr x=""/><blank/><nil/><pair/><selfclosed/></root>` {
+		t.Errorf("Render = %s", got)
+	}
+
+	plain, err := Parse(emptyDoc)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	m := NodeToInterfaceWithOptions(plain, WithEmptyAsNil()).(map[string]interface{})
+	for _, name := range []string{"selfclosed", "pair", "blank", "nil"} {
+		if c, ok := m[name]; !ok || c != nil {
+			t.Errorf("%s = %#v, want nil", name, c)
+		}
+	}
+	if _, ok := m["attr"].(map[string]interface{}); !ok {
+		t.Errorf("attr = %#v, want a map", m["attr"])
+	}
+}
+
+func TestRender_NilLiteral(t *testing.T) {
+	node, err := InterfaceToNode(map[string]interface{}{"a": nil, "b": ""})
+	if err != nil {
+		t.Fatalf("InterfaceToNode failed: %v", err)
+	}
+	data, err := Render(node)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if string(data) != `<root><a/><b></b></root>` {
+		t.Errorf("Render = %s", data)
+	}
+}
+
+func TestMarshal_EmptySliceItemsRoundTrip(t *testing.T) {
+	type inner struct {
+		V string `xml:"v,attr"`
+	}
+	type doc struct {
+		I []inner  `xml:"i"`
+		S []string `xml:"s"`
+	}
+	tests := []struct {
+		in   doc
+		want string
+	}{
+		{doc{}, `<doc/>`},
+		{doc{I: []inner{}, S: []string{}}, `<doc/>`},
+		{doc{I: []inner{{}}, S: []string{""}}, `<doc><i/><s></s></doc>`},
+		{doc{I: []inner{{}, {V: "x"}}, S: []string{"", "y"}}, `<doc><i/><i v="x"/><s></s><s>y</s></doc>`},
+	}
+	for _, tt := range tests {
+		data, err := Marshal(tt.in)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("Marshal(%+v) = %s, want %s", tt.in, data, tt.want)
+		}
+		// An empty element decodes as one zero-valued item, and no
+		// element leaves the slice nil.
+		var out doc
+		if err := Unmarshal(data, &out); err != nil {
+			t.Fatalf("Unmarshal(%s) failed: %v", data, err)
+		}
+		if len(out.I) != len(tt.in.I) || len(out.S) != len(tt.in.S) ||
+			len(tt.in.I) > 0 && !reflect.DeepEqual(out, tt.in) {
+			t.Errorf("Unmarshal(%s) = %+v, want %+v", data, out, tt.in)
+		}
+	}
+}
@@ -1,98 +1,44 @@
 package xml
 
 import (
+	"encoding"
 	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
-	"sync/atomic"
+	"time"
+
+	"github.com/shapestone/shape-xml/internal/fastparser"
 )
 
 // xmlEncoderFunc appends XML encoding of rv to buf with the given element name.
-type xmlEncoderFunc func(buf []byte, rv reflect.Value, elemName string) ([]byte, error)
-
-// Encoder cache using copy-on-write pattern for lock-free reads.
-var xmlEncoderCache atomic.Value
-var xmlEncoderMu sync.Mutex
-
-func init() {
-	xmlEncoderCache.Store(make(map[reflect.Type]xmlEncoderFunc))
-}
-
-var xmlMarshalerType = reflect.TypeOf((*Marshaler)(nil)).Elem()
-
-// xmlBufPool pools []byte slices for the compiled-encoder fast path.
-var xmlBufPool = sync.Pool{
-	New: func() interface{} {
-		b := make([]byte, 0, 1024)
-		return &b
-	},
-}
-
-// xmlEncoderForType returns a cached encoder for the given type, creating one if needed.
-// Uses a copy-on-write map with a placeholder for recursive types.
-func xmlEncoderForType(t reflect.Type) xmlEncoderFunc {
-	// Fast path: check cache without lock.
-	cache := xmlEncoderCache.Load().(map[reflect.Type]xmlEncoderFunc)
-	if enc, ok := cache[t]; ok {
-		return enc
-	}
+type xmlEncoderFunc func(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error)
 
-	// Slow path: build encoder under lock.
-	xmlEncoderMu.Lock()
-
-	// Double-check after acquiring lock.
-	cache = xmlEncoderCache.Load().(map[reflect.Type]xmlEncoderFunc)
-	if enc, ok := cache[t]; ok {
-		xmlEncoderMu.Unlock()
-		return enc
-	}
-
-	// Insert a placeholder to handle recursive types.
-	// The placeholder blocks until the real encoder is built, matching the
-	// same WaitGroup pattern used in shape-json to prevent a data race where
-	// a concurrent goroutine invokes the placeholder before realEnc is assigned.
-	var wg sync.WaitGroup
-	wg.Add(1)
-	var realEnc xmlEncoderFunc
-	placeholder := func(buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
-		wg.Wait()
-		return realEnc(buf, rv, elemName)
-	}
+var (
+	xmlMarshalerType        = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	xmlElementMarshalerType = reflect.TypeOf((*ElementMarshaler)(nil)).Elem()
+	textMarshalerType       = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
 
-	// COW: copy the map, add placeholder, store.
-	newCache := make(map[reflect.Type]xmlEncoderFunc, len(cache)+1)
-	for k, v := range cache {
-		newCache[k] = v
+// buildXMLEncoder builds an encoder function for the given type.
+func buildXMLEncoder(t reflect.Type) xmlEncoderFunc {
+	// OrderedMap implements Marshaler, but inside a document it must take
+	// its element name from the field or key.
+	if t == orderedMapType || t == orderedMapType.Elem() {
+		return xmlOrderedMapEnc
 	}
-	newCache[t] = placeholder
-	xmlEncoderCache.Store(newCache)
-
-	// Release lock before building so that nested calls to xmlEncoderForType
-	// (e.g., for struct child fields) do not deadlock.
-	xmlEncoderMu.Unlock()
 
-	// Build the actual encoder. This may recursively call xmlEncoderForType
-	// for child types; those calls will find the placeholder in the cache.
-	realEnc = buildXMLEncoder(t)
-	wg.Done() // unblock any goroutines waiting on the placeholder
-
-	// Replace placeholder with real encoder under lock.
-	xmlEncoderMu.Lock()
-	cache = xmlEncoderCache.Load().(map[reflect.Type]xmlEncoderFunc)
-	newCache = make(map[reflect.Type]xmlEncoderFunc, len(cache))
-	for k, v := range cache {
-		newCache[k] = v
+	// Generated codecs append their element directly. Pointers to them
+	// are left to the pointer encoder so that nil writes an empty element.
+	if t.Implements(xmlElementMarshalerType) {
+		if t.Kind() == reflect.Ptr && t.Elem().Implements(xmlElementMarshalerType) {
+			return buildXMLPtrEncoder(t)
+		}
+		return xmlElementMarshalerEnc
 	}
-	newCache[t] = realEnc
-	xmlEncoderCache.Store(newCache)
-	xmlEncoderMu.Unlock()
-
-	return realEnc
-}
 
-// buildXMLEncoder builds an encoder function for the given type.
-func buildXMLEncoder(t reflect.Type) xmlEncoderFunc {
 	// Check if the type itself implements Marshaler.
 	if t.Implements(xmlMarshalerType) {
 		return xmlMarshalerEnc
@@ -103,6 +49,26 @@ func buildXMLEncoder(t reflect.Type) xmlEncoderFunc {
 		return buildXMLAddrMarshalerEnc(t)
 	}
 
+	// Types such as time.Time and net.IP are written as their text form.
+	if t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface {
+		if t.Implements(textMarshalerType) {
+			return xmlTextMarshalerEnc
+		}
+		if reflect.PointerTo(t).Implements(textMarshalerType) {
+			return buildXMLAddrTextMarshalerEnc(t)
+		}
+	}
+
+	if t == durationType {
+		return xmlDurationEnc
+	}
+	if t == rawXMLType {
+		return xmlRawEnc
+	}
+	if isBytesType(t) {
+		return xmlBytesEnc
+	}
+
 	switch t.Kind() {
 	case reflect.Ptr:
 		return buildXMLPtrEncoder(t)
@@ -133,7 +99,14 @@ func buildXMLEncoder(t reflect.Type) xmlEncoderFunc {
 
 // ---------- Marshaler encoders ----------
 
-func xmlMarshalerEnc(buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+func xmlElementMarshalerEnc(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+	if rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return appendEmptyElement(buf, elemName), nil
+	}
+	return rv.Interface().(ElementMarshaler).AppendXMLElement(buf, elemName)
+}
+
+func xmlMarshalerEnc(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
 	marshaler := rv.Interface().(Marshaler)
 	b, err := marshaler.MarshalXML()
 	if err != nil {
@@ -143,7 +116,11 @@ func xmlMarshalerEnc(buf []byte, rv reflect.Value, elemName string) ([]byte, err
 }
 
 func buildXMLAddrMarshalerEnc(t reflect.Type) xmlEncoderFunc {
-	return func(buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+	// The non-marshaler fallback is compiled at most once, on first use.
+	var fallbackOnce sync.Once
+	var fallback xmlEncoderFunc
+
+	return func(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
 		if rv.CanAddr() {
 			marshaler := rv.Addr().Interface().(Marshaler)
 			b, err := marshaler.MarshalXML()
@@ -153,14 +130,66 @@ func buildXMLAddrMarshalerEnc(t reflect.Type) xmlEncoderFunc {
 			return append(buf, b...), nil
 		}
 		// Can't take address; fall back to non-marshaler encoding.
-		fallback := buildXMLEncoderNoMarshaler(t)
-		return fallback(buf, rv, elemName)
+		fallbackOnce.Do(func() { fallback = buildXMLEncoderNoMarshaler(t) })
+		return fallback(es, buf, rv, elemName)
+	}
+}
+
+func xmlTextMarshalerEnc(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+	return appendTextMarshaler(buf, rv.Interface().(encoding.TextMarshaler), elemName)
+}
+
+func buildXMLAddrTextMarshalerEnc(t reflect.Type) xmlEncoderFunc {
+	// The fallback for values whose address cannot be taken is compiled at
+	// most once, on first use.
+	var fallbackOnce sync.Once
+	var fallback xmlEncoderFunc
+
+	return func(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+		if rv.CanAddr() {
+			return appendTextMarshaler(buf, rv.Addr().Interface().(encoding.TextMarshaler), elemName)
+		}
+		fallbackOnce.Do(func() { fallback = buildXMLEncoderNoMarshaler(t) })
+		return fallback(es, buf, rv, elemName)
+	}
+}
+
+// appendTextMarshaler appends the element elemName holding the text form
+// of m.
+func appendTextMarshaler(buf []byte, m encoding.TextMarshaler, elemName string) ([]byte, error) {
+	text, err := m.MarshalText()
+	if err != nil {
+		return buf, err
 	}
+	buf = append(buf, '<')
+	buf = append(buf, elemName...)
+	buf = append(buf, '>')
+	buf = AppendEscapedText(buf, string(text))
+	buf = append(buf, '<', '/')
+	buf = append(buf, elemName...)
+	buf = append(buf, '>')
+	return buf, nil
 }
 
 // buildXMLEncoderNoMarshaler builds an encoder skipping the Marshaler check.
 // Used as fallback when we cannot take the address.
 func buildXMLEncoderNoMarshaler(t reflect.Type) xmlEncoderFunc {
+	if t == orderedMapType || t == orderedMapType.Elem() {
+		return xmlOrderedMapEnc
+	}
+	if t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface && t.Implements(textMarshalerType) {
+		return xmlTextMarshalerEnc
+	}
+	if t == durationType {
+		return xmlDurationEnc
+	}
+	if t == rawXMLType {
+		return xmlRawEnc
+	}
+	if isBytesType(t) {
+		return xmlBytesEnc
+	}
+
 	switch t.Kind() {
 	case reflect.Ptr:
 		return buildXMLPtrEncoder(t)
@@ -192,19 +221,45 @@ func buildXMLEncoderNoMarshaler(t reflect.Type) xmlEncoderFunc {
 // ---------- Pointer / Interface encoders ----------
 
 func buildXMLPtrEncoder(t reflect.Type) xmlEncoderFunc {
-	elemEnc := xmlEncoderForType(t.Elem())
-	return func(buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+	elemEnc := nestedEncoderForType(t.Elem())
+	return func(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
 		if rv.IsNil() {
 			buf = append(buf, '<')
 			buf = append(buf, elemName...)
 			buf = append(buf, '/', '>')
 			return buf, nil
 		}
-		return elemEnc(buf, rv.Elem(), elemName)
+		return elemEnc(es, buf, rv.Elem(), elemName)
+	}
+}
+
+// lastEncoder remembers the encoder for the most recently seen concrete type.
+// Dynamic payloads ([]interface{}, map[string]interface{}) are usually
+// homogeneous, so checking the previous type first avoids a cache lookup per value.
+// A lastEncoder is used for the duration of one encoder call and is not shared.
+type lastEncoder struct {
+	t   reflect.Type
+	enc xmlEncoderFunc
+}
+
+// forType returns the encoder for t, reusing the previous one when t is unchanged.
+func (c *lastEncoder) forType(t reflect.Type) xmlEncoderFunc {
+	if c.t != t {
+		c.t = t
+		c.enc = xmlEncoderForType(t)
 	}
+	return c.enc
 }
 
-func xmlInterfaceEnc(buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+// encodeDynamic encodes an interface value using the per-call encoder cache.
+func (c *lastEncoder) encodeDynamic(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+	for rv.Kind() == reflect.Interface && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	return c.forType(rv.Type())(es, buf, rv, elemName)
+}
+
+func xmlInterfaceEnc(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
 	if rv.IsNil() {
 		buf = append(buf, '<')
 		buf = append(buf, elemName...)
@@ -214,34 +269,36 @@ func xmlInterfaceEnc(buf []byte, rv reflect.Value, elemName string) ([]byte, err
 	// Resolve the concrete type at runtime and dispatch.
 	elem := rv.Elem()
 	enc := xmlEncoderForType(elem.Type())
-	return enc(buf, elem, elemName)
+	return enc(es, buf, elem, elemName)
 }
 
 // ---------- Primitive encoders ----------
 
-func xmlStringEnc(buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+func xmlStringEnc(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
 	buf = append(buf, '<')
 	buf = append(buf, elemName...)
 	buf = append(buf, '>')
-	buf = appendEscapeXML(buf, rv.String())
+	buf = AppendEscapedText(buf, rv.String())
 	buf = append(buf, '<', '/')
 	buf = append(buf, elemName...)
 	buf = append(buf, '>')
 	return buf, nil
 }
 
-func xmlIntEnc(buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+// xmlBytesEnc encodes a []byte as the text of one element, as
+// encoding/xml does, rather than as a list of numbers.
+func xmlBytesEnc(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
 	buf = append(buf, '<')
 	buf = append(buf, elemName...)
 	buf = append(buf, '>')
-	buf = appendFormatValue(buf, rv)
+	buf = AppendEscapedText(buf, string(rv.Bytes()))
 	buf = append(buf, '<', '/')
 	buf = append(buf, elemName...)
 	buf = append(buf, '>')
 	return buf, nil
 }
 
-func xmlUintEnc(buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+func xmlIntEnc(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
 	buf = append(buf, '<')
 	buf = append(buf, elemName...)
 	buf = append(buf, '>')
@@ -252,7 +309,19 @@ func xmlUintEnc(buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
 	return buf, nil
 }
 
-func xmlFloatEnc(buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+// xmlDurationEnc encodes a time.Duration in the format selected by the options.
+func xmlDurationEnc(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+	buf = append(buf, '<')
+	buf = append(buf, elemName...)
+	buf = append(buf, '>')
+	buf = append(buf, formatDuration(time.Duration(rv.Int()), es.opts.DurationFormat)...)
+	buf = append(buf, '<', '/')
+	buf = append(buf, elemName...)
+	buf = append(buf, '>')
+	return buf, nil
+}
+
+func xmlUintEnc(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
 	buf = append(buf, '<')
 	buf = append(buf, elemName...)
 	buf = append(buf, '>')
@@ -263,7 +332,25 @@ func xmlFloatEnc(buf []byte, rv reflect.Value, elemName string) ([]byte, error)
 	return buf, nil
 }
 
-func xmlBoolEnc(buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+func xmlFloatEnc(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+	text, action, err := applyFloatPolicy(rv.Float(), floatBits(rv.Kind()), es.opts.FloatPolicy)
+	if err != nil {
+		return buf, err
+	}
+	if action == floatNil {
+		return appendNilElement(buf, elemName), nil
+	}
+	buf = append(buf, '<')
+	buf = append(buf, elemName...)
+	buf = append(buf, '>')
+	buf = append(buf, text...)
+	buf = append(buf, '<', '/')
+	buf = append(buf, elemName...)
+	buf = append(buf, '>')
+	return buf, nil
+}
+
+func xmlBoolEnc(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
 	buf = append(buf, '<')
 	buf = append(buf, elemName...)
 	buf = append(buf, '>')
@@ -278,40 +365,95 @@ func xmlBoolEnc(buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
 
 // xmlAttrField holds pre-computed metadata for a struct attribute field.
 type xmlAttrField struct {
-	index       int    // field index in the struct
+	index       []int  // field index path in the struct
 	name        string // attribute name for sorting
 	prefixBytes []byte // pre-encoded ` name="` (space + name + =")
+	layout      string // time layout (layout option)
+	count       bool   // write the field's length (countattr option)
+	omitEmpty   bool   // omit the attribute for an empty value (omitempty option)
+	method      bool   // index[0] is a method index: write its result (XMLAttr_ methods)
 }
 
 // xmlChildField holds pre-computed metadata for a struct child element field.
 type xmlChildField struct {
-	index     int
+	index     []int
 	name      string
 	encoder   xmlEncoderFunc
 	omitEmpty bool
+	list      bool // written as one element per item, see isListType
+	// parents holds the wrapper elements of an a>b>c path, outermost first.
+	parents []string
+}
+
+// omit reports whether the field value fv is left out: when it is empty
+// with omitempty, and when it is a nil pointer or interface, so that it
+// decodes back to nil as a missing element does.
+func (c xmlChildField) omit(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if fv.IsNil() {
+			return true
+		}
+	}
+	return c.omitEmpty && isEmptyValue(fv)
+}
+
+// writesNothing reports whether the field value fv, which omit keeps,
+// writes nothing at all: an empty list outside any path wrapper.
+func (c xmlChildField) writesNothing(fv reflect.Value) bool {
+	return c.list && len(c.parents) == 0 && fv.Len() == 0
 }
 
-// xmlFieldRef references a struct field by index.
+// isListType reports whether a field of type t is written as one element
+// per item, so that an empty or nil slice writes no element, rather than as
+// the text of a single element.
+func isListType(t reflect.Type) bool {
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Array || isBytesType(t) {
+		return false
+	}
+	pt := reflect.PointerTo(t)
+	return !t.Implements(xmlElementMarshalerType) && !t.Implements(xmlMarshalerType) && !pt.Implements(xmlMarshalerType) &&
+		!t.Implements(textMarshalerType) && !pt.Implements(textMarshalerType)
+}
+
+// xmlFieldRef references a struct field by index path.
 type xmlFieldRef struct {
-	index int
+	index     []int
+	omitEmpty bool
+	layout    string
+}
+
+// field returns the field f references in struct value rv; ok is false
+// if there is no such field or it lies behind a nil embedded pointer.
+func (f *xmlFieldRef) field(rv reflect.Value) (fv reflect.Value, ok bool) {
+	if f == nil {
+		return reflect.Value{}, false
+	}
+	return fastparser.FieldByIndex(rv, f.index, false)
 }
 
 // xmlStructEncoder holds all pre-computed struct encoding metadata.
 type xmlStructEncoder struct {
 	attrs    []xmlAttrField
+	declared []xmlAttrField // attrs in field order
 	chardata *xmlFieldRef
 	cdata    *xmlFieldRef
 	children []xmlChildField
+	xmlName  *xmlNameInfo
 }
 
 func buildXMLStructEncoder(t reflect.Type) xmlEncoderFunc {
 	se := &xmlStructEncoder{}
 
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
+	if info, ok, err := xmlNameFieldInfo(t); err != nil {
+		return xmlTagErrorEnc(err)
+	} else if ok {
+		se.xmlName = &info
+	}
 
-		// Skip unexported fields.
-		if field.PkgPath != "" {
+	for _, field := range fastparser.VisibleFields(t) {
+		// Skip the XMLName field, which names the element itself.
+		if se.xmlName != nil && len(field.Index) == 1 && field.Index[0] == se.xmlName.index {
 			continue
 		}
 
@@ -322,7 +464,23 @@ func buildXMLStructEncoder(t reflect.Type) xmlEncoderFunc {
 			continue
 		}
 
+		if info.space != "" && (info.attr || info.chardata || info.cdata) {
+			return xmlTagErrorEnc(fmt.Errorf("xml: field %s: a namespace applies only to elements", field.Name))
+		}
+		if strings.Contains(info.name, ">") && (info.attr || info.chardata || info.cdata) {
+			return xmlTagErrorEnc(fmt.Errorf("xml: field %s: a>b paths apply only to elements", field.Name))
+		}
+		if info.layout != "" {
+			text := info.attr || info.chardata || info.cdata
+			if text && derefType(field.Type) != timeType || !text && !isTimeType(field.Type) {
+				return xmlTagErrorEnc(fmt.Errorf("xml: field %s: layout needs a time.Time, not %s", field.Name, field.Type))
+			}
+		}
+
 		if info.attr {
+			if !isValidXMLName(info.name) {
+				return xmlTagErrorEnc(fmt.Errorf("xml: field %s: invalid attribute name %q", field.Name, info.name))
+			}
 			// Pre-encode attribute prefix: ` name="`
 			prefix := make([]byte, 0, 1+len(info.name)+2)
 			prefix = append(prefix, ' ')
@@ -330,51 +488,204 @@ func buildXMLStructEncoder(t reflect.Type) xmlEncoderFunc {
 			prefix = append(prefix, '=', '"')
 
 			se.attrs = append(se.attrs, xmlAttrField{
-				index:       i,
+				index:       field.Index,
 				name:        info.name,
 				prefixBytes: prefix,
+				omitEmpty:   info.omitEmpty,
+				layout:      info.layout,
 			})
 			continue
 		}
 
 		if info.chardata {
-			se.chardata = &xmlFieldRef{index: i}
+			se.chardata = &xmlFieldRef{index: field.Index, omitEmpty: info.omitEmpty, layout: info.layout}
 			continue
 		}
 
 		if info.cdata {
-			se.cdata = &xmlFieldRef{index: i}
+			se.cdata = &xmlFieldRef{index: field.Index, layout: info.layout}
 			continue
 		}
 
+		if info.countAttr != "" {
+			if !isValidXMLName(info.countAttr) {
+				return xmlTagErrorEnc(fmt.Errorf("xml: field %s: invalid countattr name %q", field.Name, info.countAttr))
+			}
+			if k := derefType(field.Type).Kind(); k != reflect.Slice && k != reflect.Array && k != reflect.Map {
+				return xmlTagErrorEnc(fmt.Errorf("xml: field %s: countattr needs a slice, array or map, not %s", field.Name, field.Type))
+			}
+			se.attrs = append(se.attrs, xmlAttrField{
+				index:       field.Index,
+				name:        info.countAttr,
+				prefixBytes: []byte(" " + info.countAttr + `="`),
+				count:       true,
+			})
+		}
+
+		// A path a>b>c writes the field as c inside wrappers a and b.
+		var parents []string
+		if strings.Contains(info.name, ">") {
+			parents = strings.Split(info.name, ">")
+			for _, name := range parents {
+				if !isValidXMLName(name) {
+					return xmlTagErrorEnc(fmt.Errorf("xml: field %s: invalid element name %q in path %q", field.Name, name, info.name))
+				}
+			}
+			info.name, parents = parents[len(parents)-1], parents[:len(parents)-1]
+		}
+
+		// The XMLName tag of the field's type names its elements unless the
+		// field's own tag does.
+		if st, typeName := xmlNameTypeTag(field.Type); typeName != "" {
+			if parseTag(field.Tag.Get("xml")).name == "" {
+				info.name = typeName
+			} else if info.name != typeName {
+				return xmlTagErrorEnc(fmt.Errorf("xml: name %q in tag of %s.%s conflicts with name %q in %s.XMLName",
+					info.name, t, field.Name, typeName, st))
+			}
+		}
+
 		// Regular child element - resolve encoder.
-		childEnc := xmlEncoderForType(field.Type)
+		var childEnc xmlEncoderFunc
+		if info.key != "" {
+			if !isValidXMLName(info.key) {
+				return xmlTagErrorEnc(fmt.Errorf("xml: field %s: invalid key name %q", field.Name, info.key))
+			}
+			if field.Type.Kind() != reflect.Map {
+				return xmlTagErrorEnc(fmt.Errorf("xml: field %s: key needs a map, not %s", field.Name, field.Type))
+			}
+			childEnc = buildXMLKeyedMapEncoder(field.Type, info.key)
+		} else if info.layout != "" {
+			childEnc = buildXMLTimeEncoder(field.Type, info.layout)
+		} else {
+			childEnc = nestedEncoderForType(field.Type)
+		}
+		if !isValidXMLName(info.name) {
+			return xmlTagErrorEnc(fmt.Errorf("xml: field %s: invalid element name %q", field.Name, info.name))
+		}
+		if info.space != "" {
+			childEnc = buildXMLNamespaceEncoder(field.Type, childEnc, info.name, info.space)
+		}
 
 		se.children = append(se.children, xmlChildField{
-			index:     i,
+			index:     field.Index,
 			name:      info.name,
 			encoder:   childEnc,
 			omitEmpty: info.omitEmpty,
+			list:      info.key == "" && isListType(field.Type),
+			parents:   parents,
 		})
 	}
 
-	// Sort attributes by name for deterministic output.
+	// Computed attributes: value-receiver methods named XMLAttr_<name>
+	// returning a string.
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		name, ok := strings.CutPrefix(m.Name, computedAttrPrefix)
+		if !ok {
+			continue
+		}
+		if m.Type.NumIn() != 1 || m.Type.NumOut() != 1 || m.Type.Out(0).Kind() != reflect.String {
+			return xmlTagErrorEnc(fmt.Errorf("xml: method %s.%s must have signature func() string", t, m.Name))
+		}
+		if !isValidXMLName(name) {
+			return xmlTagErrorEnc(fmt.Errorf("xml: method %s.%s: invalid attribute name %q", t, m.Name, name))
+		}
+		se.attrs = append(se.attrs, xmlAttrField{
+			index:       []int{i},
+			name:        name,
+			prefixBytes: []byte(" " + name + `="`),
+			method:      true,
+		})
+	}
+
+	// A count or computed attribute replaces a regular attribute field of
+	// the same name, which then receives the value when decoding.
+	derived := make(map[string]bool)
+	for _, a := range se.attrs {
+		if a.count || a.method {
+			derived[a.name] = true
+		}
+	}
+	if len(derived) > 0 {
+		attrs := se.attrs[:0]
+		for _, a := range se.attrs {
+			if a.count || a.method || !derived[a.name] {
+				attrs = append(attrs, a)
+			}
+		}
+		se.attrs = attrs
+	}
+
+	// Sort attributes by name for deterministic output, keeping the field
+	// order for AttrOrderDeclared.
+	se.declared = append([]xmlAttrField(nil), se.attrs...)
 	sort.Slice(se.attrs, func(i, j int) bool {
 		return se.attrs[i].name < se.attrs[j].name
 	})
 
-	return func(buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+	return func(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+		start := len(buf)
+		var nsMarkup []byte
+		if se.xmlName != nil {
+			name, space, err := se.xmlName.elementName(rv, elemName)
+			if err != nil {
+				return buf, err
+			}
+			if name != elemName {
+				elemName = name
+				defer func() { es.renamed = elemRename{pos: start, name: name} }()
+			}
+			if space != "" {
+				markup, binding := namespaceMarkup(name, space, &es.opts)
+				if bound, ok := es.lookupNamespace(binding.prefix); !ok || bound != space {
+					nsMarkup = markup
+					n := len(es.ns)
+					es.ns = append(es.ns, binding)
+					defer func() { es.ns = es.ns[:n] }()
+				}
+			}
+		}
+
 		// Start opening tag: `<elemName`
 		buf = append(buf, '<')
 		buf = append(buf, elemName...)
+		buf = append(buf, nsMarkup...)
 
-		// Write sorted attributes.
-		for _, attr := range se.attrs {
-			fv := rv.Field(attr.index)
-			attrVal := formatValue(fv)
-			if attrVal != "" {
+		// Write attributes in the selected order.
+		attrs := se.attrs
+		if es.opts.AttrOrder == AttrOrderDeclared {
+			attrs = se.declared
+		}
+		for _, attr := range attrs {
+			if attr.method {
+				if v := rv.Method(attr.index[0]).Call(nil)[0].String(); v != "" {
+					buf = append(buf, attr.prefixBytes...)
+					buf = appendEscapeAttr(buf, v, &es.opts)
+					buf = append(buf, '"')
+				}
+				continue
+			}
+			fv, ok := fastparser.FieldByIndex(rv, attr.index, false)
+			if !ok {
+				continue // behind a nil embedded pointer
+			}
+			if attr.count {
 				buf = append(buf, attr.prefixBytes...)
-				buf = appendEscapeXML(buf, attrVal)
+				buf = strconv.AppendInt(buf, int64(valueLen(fv)), 10)
+				buf = append(buf, '"')
+				continue
+			}
+			if attr.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			attrVal, ok, err := formatFieldText(es, fv, attr.layout)
+			if err != nil {
+				return buf, err
+			}
+			if ok && attrVal != "" {
+				buf = append(buf, attr.prefixBytes...)
+				buf = appendEscapeAttr(buf, attrVal, &es.opts)
 				buf = append(buf, '"')
 			}
 		}
@@ -382,24 +693,34 @@ func buildXMLStructEncoder(t reflect.Type) xmlEncoderFunc {
 		// Check if there is any content.
 		hasContent := false
 
-		if se.chardata != nil {
-			fv := rv.Field(se.chardata.index)
-			if formatValue(fv) != "" {
+		var chardata string
+		if fv, ok := se.chardata.field(rv); ok && !(se.chardata.omitEmpty && isEmptyValue(fv)) {
+			text, ok, err := formatFieldText(es, fv, se.chardata.layout)
+			if err != nil {
+				return buf, err
+			}
+			if ok && text != "" {
+				chardata = text
 				hasContent = true
 			}
 		}
 
-		if !hasContent && se.cdata != nil {
-			fv := rv.Field(se.cdata.index)
-			if formatValue(fv) != "" {
+		var cdata string
+		if fv, ok := se.cdata.field(rv); ok {
+			text, ok, err := formatFieldText(es, fv, se.cdata.layout)
+			if err != nil {
+				return buf, err
+			}
+			if ok && text != "" {
+				cdata = text
 				hasContent = true
 			}
 		}
 
 		if !hasContent {
 			for _, child := range se.children {
-				fv := rv.Field(child.index)
-				if child.omitEmpty && isEmptyValue(fv) {
+				fv, ok := fastparser.FieldByIndex(rv, child.index, false)
+				if !ok || child.omit(fv) || child.writesNothing(fv) {
 					continue
 				}
 				hasContent = true
@@ -416,37 +737,44 @@ func buildXMLStructEncoder(t reflect.Type) xmlEncoderFunc {
 		buf = append(buf, '>')
 
 		// Write chardata content.
-		if se.chardata != nil {
-			fv := rv.Field(se.chardata.index)
-			val := formatValue(fv)
-			if val != "" {
-				buf = appendEscapeXML(buf, val)
-			}
+		if chardata != "" {
+			buf = AppendEscapedText(buf, chardata)
 		}
 
 		// Write CDATA content.
-		if se.cdata != nil {
-			fv := rv.Field(se.cdata.index)
-			val := formatValue(fv)
-			if val != "" {
-				buf = append(buf, "<![CDATA["...)
-				buf = append(buf, val...)
-				buf = append(buf, "]]>"...)
-			}
+		if cdata != "" {
+			buf = appendCDATA(buf, cdata)
 		}
 
-		// Write child elements.
+		// Write child elements. Wrappers of a>b>c paths stay open while
+		// consecutive fields share them.
 		var err error
+		var wrappers []string
 		for _, child := range se.children {
-			fv := rv.Field(child.index)
-			if child.omitEmpty && isEmptyValue(fv) {
+			fv, ok := fastparser.FieldByIndex(rv, child.index, false)
+			if !ok || child.omit(fv) {
 				continue
 			}
-			buf, err = child.encoder(buf, fv, child.name)
+			shared := 0
+			for shared < len(wrappers) && shared < len(child.parents) && wrappers[shared] == child.parents[shared] {
+				shared++
+			}
+			buf, wrappers = closeWrappers(buf, wrappers, shared)
+			for _, name := range child.parents[shared:] {
+				buf = append(buf, '<')
+				buf = append(buf, name...)
+				buf = append(buf, '>')
+				wrappers = append(wrappers, name)
+			}
+			buf, err = child.encoder(es, buf, fv, child.name)
 			if err != nil {
 				return buf, err
 			}
+			if buf, err = es.maybeFlush(buf); err != nil {
+				return buf, err
+			}
 		}
+		buf, _ = closeWrappers(buf, wrappers, 0)
 
 		// Close element.
 		buf = append(buf, '<', '/')
@@ -457,16 +785,60 @@ func buildXMLStructEncoder(t reflect.Type) xmlEncoderFunc {
 	}
 }
 
+// closeWrappers writes the end tags of the open path wrappers beyond the
+// first keep and returns the wrappers left open.
+func closeWrappers(buf []byte, wrappers []string, keep int) ([]byte, []string) {
+	for len(wrappers) > keep {
+		name := wrappers[len(wrappers)-1]
+		buf = append(buf, '<', '/')
+		buf = append(buf, name...)
+		buf = append(buf, '>')
+		wrappers = wrappers[:len(wrappers)-1]
+	}
+	return buf, wrappers
+}
+
+// computedAttrPrefix starts the name of methods that supply computed
+// attribute values, see Marshal.
+const computedAttrPrefix = "XMLAttr_"
+
+// xmlTagErrorEnc returns an encoder that reports a struct tag error.
+func xmlTagErrorEnc(err error) xmlEncoderFunc {
+	return func(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+		return buf, err
+	}
+}
+
+// derefType returns the type t points to, through any number of pointers.
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// valueLen returns the length of a slice, array or map value, following
+// pointers; nil counts as 0.
+func valueLen(v reflect.Value) int {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return 0
+		}
+		v = v.Elem()
+	}
+	return v.Len()
+}
+
 // ---------- Map encoder ----------
 
 func buildXMLMapEncoder(t reflect.Type) xmlEncoderFunc {
 	if t.Key().Kind() != reflect.String {
-		return func(buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+		return func(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
 			return buf, fmt.Errorf("xml: unsupported map key type %s", t.Key())
 		}
 	}
 
-	return func(buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+	return func(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
 		if rv.IsNil() {
 			buf = append(buf, '<')
 			buf = append(buf, elemName...)
@@ -488,20 +860,22 @@ func buildXMLMapEncoder(t reflect.Type) xmlEncoderFunc {
 		sort.Strings(strKeys)
 
 		// Encode each value. We resolve the encoder per-value because map values
-		// can be interface{} and the concrete type may vary.
+		// can be interface{} and the concrete type may vary; consecutive values of
+		// the same type reuse the previously resolved encoder.
+		var last lastEncoder
 		for _, keyStr := range strKeys {
-			val := rv.MapIndex(reflect.ValueOf(keyStr))
-			// Resolve concrete type for interface values.
-			actual := val
-			for actual.Kind() == reflect.Interface && !actual.IsNil() {
-				actual = actual.Elem()
+			name, err := es.keyName(keyStr)
+			if err != nil {
+				return buf, err
 			}
-			enc := xmlEncoderForType(actual.Type())
-			var err error
-			buf, err = enc(buf, actual, keyStr)
+			val := rv.MapIndex(reflect.ValueOf(keyStr))
+			buf, err = last.encodeDynamic(es, buf, val, name)
 			if err != nil {
 				return buf, err
 			}
+			if buf, err = es.maybeFlush(buf); err != nil {
+				return buf, err
+			}
 		}
 
 		// Close element.
@@ -513,48 +887,180 @@ func buildXMLMapEncoder(t reflect.Type) xmlEncoderFunc {
 	}
 }
 
+// keyName returns the element or attribute name for the map key key:
+// escaped with DefaultNameEscaper if EscapeNames is set, otherwise key
+// itself, which must then be a valid name.
+func (es *encodeState) keyName(key string) (string, error) {
+	if es.opts.EscapeNames {
+		return EscapeName(key), nil
+	}
+	if !isValidXMLName(key) {
+		return "", fmt.Errorf("xml: map key %q is not a valid name", key)
+	}
+	return key, nil
+}
+
 // ---------- Slice / Array encoder ----------
 
 func buildXMLSliceEncoder(t reflect.Type) xmlEncoderFunc {
-	elemEnc := xmlEncoderForType(t.Elem())
+	if t.Elem().Kind() == reflect.Interface {
+		return buildXMLDynamicSliceEncoder(t)
+	}
+	return buildXMLSliceEncoderWith(nestedEncoderForType(t.Elem()))
+}
 
-	return func(buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
-		// Nil slices encode as self-closing element.
-		if rv.IsNil() {
-			buf = append(buf, '<')
-			buf = append(buf, elemName...)
-			buf = append(buf, '/', '>')
-			return buf, nil
+// buildXMLSliceEncoderWith encodes a slice with elemEnc for each element.
+func buildXMLSliceEncoderWith(elemEnc xmlEncoderFunc) xmlEncoderFunc {
+	return func(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+		// Encode each element with the same element name; an empty or nil
+		// slice writes nothing, as in encoding/xml.
+		start := len(buf)
+		length := rv.Len()
+		hint := length == 1 && es.opts.ArrayHints
+		if hint {
+			// The hint is inserted into the start tag afterwards, so the
+			// element must stay in buf.
+			es.holdFlush++
+		}
+		for i := 0; i < length; i++ {
+			var err error
+			if i > 0 {
+				if buf, err = es.maybeFlush(buf); err != nil {
+					return buf, err
+				}
+			}
+			buf, err = elemEnc(es, buf, rv.Index(i), elemName)
+			if err != nil {
+				return buf, err
+			}
+		}
+		if hint {
+			es.holdFlush--
+			buf = appendArrayHint(buf, start, es.writtenName(start, elemName))
 		}
 
-		// Encode each element with the same element name.
+		return buf, nil
+	}
+}
+
+// buildXMLDynamicSliceEncoder encodes slices and arrays of interface values,
+// resolving each element's concrete encoder through a per-call lastEncoder.
+func buildXMLDynamicSliceEncoder(t reflect.Type) xmlEncoderFunc {
+	return func(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+		var last lastEncoder
+		start := len(buf)
 		length := rv.Len()
+		hint := length == 1 && es.opts.ArrayHints
+		if hint {
+			// The hint is inserted into the start tag afterwards, so the
+			// element must stay in buf.
+			es.holdFlush++
+		}
 		for i := 0; i < length; i++ {
+			if i > 0 {
+				var err error
+				if buf, err = es.maybeFlush(buf); err != nil {
+					return buf, err
+				}
+			}
+			elem := rv.Index(i)
+			if elem.IsNil() {
+				buf = append(buf, '<')
+				buf = append(buf, elemName...)
+				buf = append(buf, '/', '>')
+				continue
+			}
 			var err error
-			buf, err = elemEnc(buf, rv.Index(i), elemName)
+			buf, err = last.encodeDynamic(es, buf, elem, elemName)
 			if err != nil {
 				return buf, err
 			}
 		}
+		if hint {
+			es.holdFlush--
+			buf = appendArrayHint(buf, start, es.writtenName(start, elemName))
+		}
 
 		return buf, nil
 	}
 }
 
 func buildXMLArrayEncoder(t reflect.Type) xmlEncoderFunc {
-	elemEnc := xmlEncoderForType(t.Elem())
+	if t.Elem().Kind() == reflect.Interface {
+		return buildXMLDynamicSliceEncoder(t)
+	}
+	return buildXMLArrayEncoderWith(nestedEncoderForType(t.Elem()))
+}
 
-	return func(buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+// buildXMLArrayEncoderWith encodes an array with elemEnc for each element.
+func buildXMLArrayEncoderWith(elemEnc xmlEncoderFunc) xmlEncoderFunc {
+	return func(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
 		// Encode each element with the same element name.
+		start := len(buf)
 		length := rv.Len()
+		hint := length == 1 && es.opts.ArrayHints
+		if hint {
+			// The hint is inserted into the start tag afterwards, so the
+			// element must stay in buf.
+			es.holdFlush++
+		}
 		for i := 0; i < length; i++ {
 			var err error
-			buf, err = elemEnc(buf, rv.Index(i), elemName)
+			if i > 0 {
+				if buf, err = es.maybeFlush(buf); err != nil {
+					return buf, err
+				}
+			}
+			buf, err = elemEnc(es, buf, rv.Index(i), elemName)
 			if err != nil {
 				return buf, err
 			}
 		}
+		if hint {
+			es.holdFlush--
+			buf = appendArrayHint(buf, start, es.writtenName(start, elemName))
+		}
+
+		return buf, nil
+	}
+}
+
+// ---------- time.Time layouts ----------
 
+// isTimeType reports whether t is time.Time, or a pointer, slice or array
+// of them, which the layout option applies to.
+func isTimeType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t == timeType
+}
+
+// buildXMLTimeEncoder builds the encoder for a field of type t, as accepted
+// by isTimeType, that writes times in the given layout.
+func buildXMLTimeEncoder(t reflect.Type, layout string) xmlEncoderFunc {
+	switch t.Kind() {
+	case reflect.Ptr:
+		elemEnc := buildXMLTimeEncoder(t.Elem(), layout)
+		return func(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+			if rv.IsNil() {
+				return appendEmptyElement(buf, elemName), nil
+			}
+			return elemEnc(es, buf, rv.Elem(), elemName)
+		}
+	case reflect.Slice:
+		return buildXMLSliceEncoderWith(buildXMLTimeEncoder(t.Elem(), layout))
+	case reflect.Array:
+		return buildXMLArrayEncoderWith(buildXMLTimeEncoder(t.Elem(), layout))
+	}
+	return func(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+		buf = append(buf, '<')
+		buf = append(buf, elemName...)
+		buf = append(buf, '>')
+		buf = AppendEscapedText(buf, rv.Interface().(time.Time).Format(layout))
+		buf = append(buf, '<', '/')
+		buf = append(buf, elemName...)
+		buf = append(buf, '>')
 		return buf, nil
 	}
 }
@@ -562,7 +1068,7 @@ func buildXMLArrayEncoder(t reflect.Type) xmlEncoderFunc {
 // ---------- Unsupported ----------
 
 func xmlUnsupportedEnc(t reflect.Type) xmlEncoderFunc {
-	return func(buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+	return func(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
 		return buf, fmt.Errorf("xml: unsupported type %s", t)
 	}
 }
@@ -0,0 +1,120 @@
+//go:build !tinygo && !shapexml_lean
+
+package xml
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Encoder cache using copy-on-write pattern for lock-free reads.
+var xmlEncoderCache atomic.Value
+var xmlEncoderMu sync.Mutex
+
+// xmlEncoderInflight tracks encoders currently being built, guarded by
+// xmlEncoderMu. It ensures each type is compiled by exactly one goroutine even
+// when many goroutines encounter a new type at the same time.
+var xmlEncoderInflight = make(map[reflect.Type]*xmlEncoderCall)
+
+// xmlEncoderBuilds counts encoder compilations (used by tests to verify
+// deduplication).
+var xmlEncoderBuilds atomic.Uint64
+
+// xmlEncoderCall is an in-progress encoder build.
+type xmlEncoderCall struct {
+	wg  sync.WaitGroup
+	enc xmlEncoderFunc
+}
+
+// placeholder returns an encoder that waits for the build to finish and then
+// delegates to the real encoder. It is handed out to builders that need the
+// encoder while it is still being compiled (recursive types, or a type being
+// compiled concurrently by another goroutine), where waiting at build time
+// could deadlock.
+func (c *xmlEncoderCall) placeholder() xmlEncoderFunc {
+	return func(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+		c.wg.Wait()
+		return c.enc(es, buf, rv, elemName)
+	}
+}
+
+func init() {
+	xmlEncoderCache.Store(make(map[reflect.Type]xmlEncoderFunc))
+}
+
+// xmlEncoderForType returns a cached encoder for the given type, creating one if needed.
+// If another goroutine is already compiling the type, it waits for that build
+// instead of compiling a duplicate.
+func xmlEncoderForType(t reflect.Type) xmlEncoderFunc {
+	return encoderForType(t, true)
+}
+
+// nestedEncoderForType returns the encoder for a child type while building a
+// parent encoder. Types that are still being compiled yield a placeholder
+// rather than blocking, which handles recursive types and avoids lock-order
+// deadlocks between goroutines compiling mutually recursive types.
+func nestedEncoderForType(t reflect.Type) xmlEncoderFunc {
+	return encoderForType(t, false)
+}
+
+// encoderForType implements the copy-on-write cache lookup with singleflight
+// deduplication of builds.
+func encoderForType(t reflect.Type, wait bool) xmlEncoderFunc {
+	// Fast path: check cache without lock.
+	cache := xmlEncoderCache.Load().(map[reflect.Type]xmlEncoderFunc)
+	if enc, ok := cache[t]; ok {
+		return enc
+	}
+
+	// Slow path: build encoder under lock.
+	xmlEncoderMu.Lock()
+
+	// Double-check after acquiring lock.
+	cache = xmlEncoderCache.Load().(map[reflect.Type]xmlEncoderFunc)
+	if enc, ok := cache[t]; ok {
+		xmlEncoderMu.Unlock()
+		return enc
+	}
+
+	// Someone is already building this type: wait for it, or hand out a
+	// placeholder when called from inside another build.
+	if call, ok := xmlEncoderInflight[t]; ok {
+		xmlEncoderMu.Unlock()
+		if wait {
+			call.wg.Wait()
+			return call.enc
+		}
+		return call.placeholder()
+	}
+
+	call := &xmlEncoderCall{}
+	call.wg.Add(1)
+	xmlEncoderInflight[t] = call
+
+	// Release lock before building so that nested calls to nestedEncoderForType
+	// (e.g., for struct child fields) do not deadlock.
+	xmlEncoderMu.Unlock()
+
+	// Build the actual encoder. This may recursively call nestedEncoderForType
+	// for child types; recursive references receive a placeholder.
+	xmlEncoderBuilds.Add(1)
+	enc := buildXMLEncoder(t)
+
+	// Publish: COW copy of the cache with the new encoder.
+	xmlEncoderMu.Lock()
+	cache = xmlEncoderCache.Load().(map[reflect.Type]xmlEncoderFunc)
+	newCache := make(map[reflect.Type]xmlEncoderFunc, len(cache)+1)
+	for k, v := range cache {
+		newCache[k] = v
+	}
+	newCache[t] = enc
+	xmlEncoderCache.Store(newCache)
+	delete(xmlEncoderInflight, t)
+	xmlEncoderMu.Unlock()
+
+	call.enc = enc
+	call.wg.Done() // unblock waiters and placeholders
+
+	return enc
+}
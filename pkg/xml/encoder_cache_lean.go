@@ -0,0 +1,27 @@
+//go:build tinygo || shapexml_lean
+
+package xml
+
+import "reflect"
+
+// Lean builds keep no encoder cache: the cache's locks and copy-on-write
+// maps live for the whole process, which WASM plugins cannot afford. Each
+// Marshal compiles the encoders it needs and drops them afterwards.
+
+// xmlEncoderForType compiles an encoder for the given type.
+func xmlEncoderForType(t reflect.Type) xmlEncoderFunc {
+	return buildXMLEncoder(t)
+}
+
+// nestedEncoderForType returns the encoder for a child type while building a
+// parent encoder. The child is compiled on first use and kept by the parent,
+// so recursive types compile one level at a time.
+func nestedEncoderForType(t reflect.Type) xmlEncoderFunc {
+	var enc xmlEncoderFunc
+	return func(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+		if enc == nil {
+			enc = buildXMLEncoder(t)
+		}
+		return enc(es, buf, rv, elemName)
+	}
+}
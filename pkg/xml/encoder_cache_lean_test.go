@@ -0,0 +1,35 @@
+//go:build tinygo || shapexml_lean
+
+package xml
+
+import "testing"
+
+func TestLean_RecursiveTypeWithoutCache(t *testing.T) {
+	tree := &recursiveNode{Name: "a", Children: []*recursiveNode{
+		{Name: "b", Children: []*recursiveNode{{Name: "c"}}},
+		{Name: "d"},
+	}}
+	want := `<recursiveNode name="a"><node name="b"><node name="c"/></node><node name="d"/></recursiveNode>`
+	for i := 0; i < 2; i++ {
+		b, err := Marshal(tree)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		if string(b) != want {
+			t.Errorf("got %s, want %s", b, want)
+		}
+	}
+}
+
+func TestLean_NoBufferPools(t *testing.T) {
+	node, err := Parse(`<a><b>x</b></a>`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, err := Render(node); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if stats := RenderPoolStats(); len(stats.Tiers) != 0 || stats.Discards != 0 {
+		t.Errorf("RenderPoolStats() = %+v, want empty in lean builds", stats)
+	}
+}
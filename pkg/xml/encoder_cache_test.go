@@ -0,0 +1,52 @@
+//go:build !tinygo && !shapexml_lean
+
+package xml
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestEncoderCache_ConcurrentFirstUseBuildsOnce(t *testing.T) {
+	type stormLeaf struct {
+		V string `xml:"v"`
+	}
+	type stormRoot struct {
+		ID     string      `xml:"id,attr"`
+		Leaves []stormLeaf `xml:"leaf"`
+	}
+
+	before := xmlEncoderBuilds.Load()
+
+	const goroutines = 64
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, goroutines)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			b, err := Marshal(stormRoot{ID: "1", Leaves: []stormLeaf{{V: "a"}}})
+			if err != nil {
+				errs <- err
+				return
+			}
+			if string(b) != `<stormRoot id="1"><leaf><v>a</v></leaf></stormRoot>` {
+				errs <- fmt.Errorf("unexpected output %s", b)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// stormRoot, []stormLeaf, stormLeaf and (possibly) string are compiled at most once each.
+	if built := xmlEncoderBuilds.Load() - before; built > 4 {
+		t.Errorf("expected each type to be compiled once, got %d builds", built)
+	}
+}
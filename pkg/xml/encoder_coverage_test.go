@@ -2,6 +2,7 @@ package xml
 
 import (
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -59,12 +60,8 @@ func TestMarshalEncoder_PointerFields(t *testing.T) {
 		if err != nil {
 			t.Fatalf("Marshal failed: %v", err)
 		}
-		s := string(out)
-		if !strings.Contains(s, "<name/>") {
-			t.Errorf("expected <name/> for nil *string, got %s", s)
-		}
-		if !strings.Contains(s, "<age/>") {
-			t.Errorf("expected <age/> for nil *int, got %s", s)
+		if s := string(out); s != "<WithPtrs/>" {
+			t.Errorf("expected nil pointer fields to be left out, got %s", s)
 		}
 	})
 }
@@ -102,9 +99,8 @@ func TestMarshalEncoder_InterfaceFields(t *testing.T) {
 		if err != nil {
 			t.Fatalf("Marshal failed: %v", err)
 		}
-		s := string(out)
-		if !strings.Contains(s, "<a/>") {
-			t.Errorf("expected <a/> for nil interface, got %s", s)
+		if s := string(out); s != "<WithIface/>" {
+			t.Errorf("expected nil interface fields to be left out, got %s", s)
 		}
 	})
 }
@@ -256,10 +252,11 @@ func TestMarshalEncoder_EscapeChars(t *testing.T) {
 	}{
 		{"a&b", "&amp;"},
 		{"a<b", "&lt;"},
-		{"a>b", "&gt;"},
-		{`a"b`, "&#34;"},
-		{"a'b", "&#39;"},
-		{"<>&\"'", "&lt;&gt;&amp;&#34;&#39;"},
+		{"a>b", "<value>a>b</value>"},
+		{`a"b`, `<value>a"b</value>`},
+		{"a'b", "<value>a'b</value>"},
+		{"<>&\"'", "&lt;>&amp;\"'"},
+		{"]]>", "]]&gt;"},
 		{"noescape", "<value>noescape</value>"},
 	}
 
@@ -348,8 +345,8 @@ func TestMarshalEncoder_NilSliceAndMap(t *testing.T) {
 		t.Fatalf("Marshal failed: %v", err)
 	}
 	s := string(out)
-	if !strings.Contains(s, "<items/>") {
-		t.Errorf("expected <items/> for nil slice, got %s", s)
+	if strings.Contains(s, "<items") {
+		t.Errorf("expected nothing for nil slice, got %s", s)
 	}
 	if !strings.Contains(s, "<props/>") {
 		t.Errorf("expected <props/> for nil map, got %s", s)
@@ -696,3 +693,125 @@ func TestMarshalEncoder_DoublePointer(t *testing.T) {
 		t.Errorf("expected <name>Bob</name>, got %s", s)
 	}
 }
+
+type recursiveNode struct {
+	Name     string           `xml:"name,attr"`
+	Children []*recursiveNode `xml:"node,omitempty"`
+}
+
+func TestEncoderCache_RecursiveTypeConcurrent(t *testing.T) {
+	tree := &recursiveNode{Name: "a", Children: []*recursiveNode{{Name: "b", Children: []*recursiveNode{{Name: "c"}}}}}
+	want := `<recursiveNode name="a"><node name="b"><node name="c"/></node></recursiveNode>`
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b, err := Marshal(tree)
+			if err != nil {
+				t.Errorf("Marshal failed: %v", err)
+				return
+			}
+			if string(b) != want {
+				t.Errorf("got %s, want %s", b, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestStructContentMatrix checks every combination of attribute, chardata,
+// cdata and child presence: attributes are always kept, and the element is
+// self-closing exactly when it has no content.
+func TestStructContentMatrix(t *testing.T) {
+	type child struct {
+		V string `xml:"v"`
+	}
+	type elem struct {
+		ID    string `xml:"id,attr"`
+		N     int    `xml:"n,attr,omitempty"`
+		Text  string `xml:",chardata"`
+		Raw   string `xml:",cdata"`
+		Child *child `xml:"c,omitempty"`
+	}
+
+	for mask := 0; mask < 32; mask++ {
+		var v elem
+		want := "<elem"
+		if mask&1 != 0 {
+			v.ID = "a&b"
+			want += ` id="a&amp;b"`
+		}
+		if mask&2 != 0 {
+			v.N = 7
+			want += ` n="7"`
+		}
+		content := ""
+		if mask&4 != 0 {
+			v.Text = "x<y"
+			content += "x&lt;y"
+		}
+		if mask&8 != 0 {
+			v.Raw = "<raw>"
+			content += "<![CDATA[<raw>]]>"
+		}
+		if mask&16 != 0 {
+			v.Child = &child{V: "c"}
+			content += "<c><v>c</v></c>"
+		}
+		if content == "" {
+			want += "/>"
+		} else {
+			want += ">" + content + "</elem>"
+		}
+
+		got, err := Marshal(v)
+		if err != nil {
+			t.Fatalf("mask %05b: Marshal failed: %v", mask, err)
+		}
+		if string(got) != want {
+			t.Errorf("mask %05b: got %s, want %s", mask, got, want)
+			continue
+		}
+		var back elem
+		if err := Unmarshal(got, &back); err != nil {
+			t.Fatalf("mask %05b: Unmarshal failed: %v", mask, err)
+		}
+		if back.ID != v.ID || back.N != v.N || back.Raw != v.Raw || (back.Child == nil) != (v.Child == nil) {
+			t.Errorf("mask %05b: round trip got %+v, want %+v", mask, back, v)
+		}
+	}
+}
+
+func TestOmitEmptyAttrAndChardata(t *testing.T) {
+	type counted struct {
+		Count int  `xml:"count,attr,omitempty"`
+		On    bool `xml:"on,attr,omitempty"`
+		Total int  `xml:",chardata,omitempty"`
+	}
+	for _, tt := range []struct {
+		v    counted
+		want string
+	}{
+		{counted{}, `<counted/>`},
+		{counted{Count: 2, On: true, Total: 5}, `<counted count="2" on="true">5</counted>`},
+	} {
+		got, err := Marshal(tt.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("got %s, want %s", got, tt.want)
+		}
+	}
+
+	// Without omitempty, zero numbers are written.
+	type plain struct {
+		Count int `xml:"count,attr"`
+		Total int `xml:",chardata"`
+	}
+	if got, _ := Marshal(plain{}); string(got) != `<plain count="0">0</plain>` {
+		t.Errorf("got %s", got)
+	}
+}
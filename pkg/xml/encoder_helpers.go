@@ -3,15 +3,167 @@ package xml
 import (
 	"reflect"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
-// appendEscapeXML appends XML-escaped text to buf without allocating.
-// Handles: & < > " '
-// This matches the behavior of html.EscapeString used by escapeXML in render.go.
-func appendEscapeXML(buf []byte, s string) []byte {
+// Escape lookup tables: true for bytes that must be replaced or, for control
+// characters and non-ASCII bytes, checked by xmlCharAt.
+var (
+	textEscapeTable        = escapeTable("&<>\r")
+	attrEscapeTable        = escapeTable("&<>\"'\t\n\r")
+	literalAttrEscapeTable = escapeTable("&<>\"\t\n\r")
+	minimalAttrEscapeTable = escapeTable("&<\"\t\n\r")
+)
+
+// escapeTable returns an escape lookup table marking the bytes in special,
+// the control characters other than tab, newline and carriage return, and
+// every byte of a multi-byte UTF-8 sequence.
+func escapeTable(special string) [256]bool {
+	var table [256]bool
+	for c := 0; c < 256; c++ {
+		table[c] = c < 0x20 && c != '\t' && c != '\n' && c != '\r' || c >= utf8.RuneSelf
+	}
+	for i := 0; i < len(special); i++ {
+		table[special[i]] = true
+	}
+	return table
+}
+
+// xmlCharAt returns the length of the character starting at s[i] and
+// whether XML can represent it. Each byte of invalid UTF-8 counts as a
+// character of its own. The escape functions write U+FFFD in place of
+// characters XML cannot represent, as encoding/xml does.
+func xmlCharAt(s string, i int) (int, bool) {
+	r, size := utf8.DecodeRuneInString(s[i:])
+	if r == utf8.RuneError && size == 1 {
+		return 1, false
+	}
+	return size, isXMLChar(r)
+}
+
+// isXMLChar reports whether r is in the Char production of the XML
+// specification.
+func isXMLChar(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
+
+// indexEscape returns the index of the first byte in s that table marks for
+// escaping, or -1 if s can be copied verbatim. The scan checks eight bytes per
+// iteration so clean strings - the common case - cost little more than a memcpy.
+func indexEscape(s string, table *[256]bool) int {
+	i := 0
+	for ; i+8 <= len(s); i += 8 {
+		if table[s[i]] || table[s[i+1]] || table[s[i+2]] || table[s[i+3]] ||
+			table[s[i+4]] || table[s[i+5]] || table[s[i+6]] || table[s[i+7]] {
+			break
+		}
+	}
+	for ; i < len(s); i++ {
+		if table[s[i]] {
+			return i
+		}
+	}
+	return -1
+}
+
+// AppendEscapedText appends s to dst, escaped for use as XML element text.
+//
+// The characters & and < are replaced by entity references, > only where it
+// would close "]]>", and carriage returns by "&#xD;" so they survive
+// end-of-line normalization. Quotes are left as-is since they have no special
+// meaning in element content. Characters XML cannot represent, such as NUL or
+// invalid UTF-8, are written as U+FFFD. Marshal and Render escape text the
+// same way under either EscapePolicy.
+//
+// AppendEscapedText is intended for custom emitters that build XML directly
+// into a byte slice:
+//
+//	buf = append(buf, "<note>"...)
+//	buf = xml.AppendEscapedText(buf, userInput)
+//	buf = append(buf, "</note>"...)
+func AppendEscapedText(dst []byte, s string) []byte {
+	i := indexEscape(s, &textEscapeTable)
+	if i < 0 {
+		return append(dst, s...)
+	}
 	start := 0
-	for i := 0; i < len(s); i++ {
+	for ; i < len(s); i++ {
+		var esc string
+		size := 1
+		switch s[i] {
+		case '&':
+			esc = "&amp;"
+		case '<':
+			esc = "&lt;"
+		case '>':
+			if !strings.HasSuffix(s[:i], "]]") {
+				continue
+			}
+			esc = "&gt;"
+		case '\r':
+			esc = "&#xD;"
+		default:
+			var ok bool
+			if size, ok = xmlCharAt(s, i); ok {
+				i += size - 1
+				continue
+			}
+			esc = "\uFFFD"
+		}
+		dst = append(dst, s[start:i]...)
+		dst = append(dst, esc...)
+		start = i + size
+		i += size - 1
+	}
+	return append(dst, s[start:]...)
+}
+
+// AppendEscapedAttr appends s to dst, escaped for use as a quoted XML attribute value.
+//
+// In addition to &, < and >, both quote characters are escaped so the result is
+// safe inside either '...' or "...", and tab, newline and carriage return are
+// written as character references so attribute-value normalization in the
+// reading parser does not turn them into spaces. Characters XML cannot
+// represent are written as U+FFFD.
+//
+//	buf = append(buf, `<link href="`...)
+//	buf = xml.AppendEscapedAttr(buf, url)
+//	buf = append(buf, `"/>`...)
+func AppendEscapedAttr(dst []byte, s string) []byte {
+	return appendEscapedAttrTable(dst, s, &attrEscapeTable)
+}
+
+// appendEscapeAttr appends s to dst escaped as an attribute value delimited
+// by ", as opts asks: like AppendEscapedAttr by default, with ' written as
+// itself under LiteralQuotes, and ' and > under EscapeMinimal.
+func appendEscapeAttr(dst []byte, s string, opts *MarshalOptions) []byte {
+	switch {
+	case opts.Escape == EscapeMinimal:
+		return appendEscapedAttrTable(dst, s, &minimalAttrEscapeTable)
+	case opts.LiteralQuotes:
+		return appendEscapedAttrTable(dst, s, &literalAttrEscapeTable)
+	}
+	return appendEscapedAttrTable(dst, s, &attrEscapeTable)
+}
+
+// appendEscapedAttrTable appends s to dst with the characters table marks
+// escaped for an attribute value.
+func appendEscapedAttrTable(dst []byte, s string, table *[256]bool) []byte {
+	i := indexEscape(s, table)
+	if i < 0 {
+		return append(dst, s...)
+	}
+	start := 0
+	for ; i < len(s); i++ {
+		if !table[s[i]] {
+			continue
+		}
 		var esc string
+		size := 1
 		switch s[i] {
 		case '&':
 			esc = "&amp;"
@@ -23,19 +175,60 @@ func appendEscapeXML(buf []byte, s string) []byte {
 			esc = "&#34;"
 		case '\'':
 			esc = "&#39;"
+		case '\t':
+			esc = "&#x9;"
+		case '\n':
+			esc = "&#xA;"
+		case '\r':
+			esc = "&#xD;"
 		default:
-			continue
+			var ok bool
+			if size, ok = xmlCharAt(s, i); ok {
+				i += size - 1
+				continue
+			}
+			esc = "\uFFFD"
 		}
-		buf = append(buf, s[start:i]...)
-		buf = append(buf, esc...)
-		start = i + 1
+		dst = append(dst, s[start:i]...)
+		dst = append(dst, esc...)
+		start = i + size
+		i += size - 1
 	}
-	buf = append(buf, s[start:]...)
-	return buf
+	return append(dst, s[start:]...)
 }
 
-// appendFormatValue appends a formatted reflect.Value to buf without allocating.
-// Zero-alloc replacement for formatValue() which returns string.
+// appendCDATA appends s to dst as a CDATA section. A "]]>" in s closes the
+// section after the "]]" and opens a new one for the ">", and characters XML
+// cannot represent are written as U+FFFD, so any string survives a round
+// trip.
+func appendCDATA(dst []byte, s string) []byte {
+	dst = append(dst, "<![CDATA["...)
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ']' && strings.HasPrefix(s[i:], "]]>"):
+			dst = append(dst, s[start:i+2]...)
+			dst = append(dst, "]]><![CDATA["...)
+			start = i + 2
+			i++
+		case c < 0x20 || c >= utf8.RuneSelf:
+			size, ok := xmlCharAt(s, i)
+			if !ok {
+				dst = append(dst, s[start:i]...)
+				dst = append(dst, "\uFFFD"...)
+				start = i + size
+			}
+			i += size - 1
+		}
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, "]]>"...)
+}
+
+// appendFormatValue appends a formatted reflect.Value to buf without
+// allocating. It is the single formatting routine for scalar values; float
+// and duration policies are applied on top of it by formatText.
 func appendFormatValue(buf []byte, rv reflect.Value) []byte {
 	if !rv.IsValid() {
 		return buf
@@ -48,9 +241,14 @@ func appendFormatValue(buf []byte, rv reflect.Value) []byte {
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		return strconv.AppendUint(buf, rv.Uint(), 10)
 	case reflect.Float32, reflect.Float64:
-		return strconv.AppendFloat(buf, rv.Float(), 'g', -1, 64)
+		return strconv.AppendFloat(buf, rv.Float(), 'g', -1, floatBits(rv.Kind()))
 	case reflect.Bool:
 		return strconv.AppendBool(buf, rv.Bool())
+	case reflect.Slice:
+		if isBytesType(rv.Type()) {
+			return append(buf, rv.Bytes()...)
+		}
+		return buf
 	case reflect.Ptr, reflect.Interface:
 		if rv.IsNil() {
 			return buf
@@ -60,3 +258,9 @@ func appendFormatValue(buf []byte, rv reflect.Value) []byte {
 		return buf
 	}
 }
+
+// isBytesType reports whether t is a slice of bytes, which is written as
+// text rather than as a list.
+func isBytesType(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
@@ -0,0 +1,120 @@
+package xml
+
+import (
+	"testing"
+)
+
+func TestAppendEscapedText(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"plain", "plain"},
+		{"", ""},
+		{"a & b", "a &amp; b"},
+		{"<tag>", "&lt;tag>"},
+		{"a > b", "a > b"},
+		{`"quoted" 'single'`, `"quoted" 'single'`},
+		{"line1\r\nline2", "line1&#xD;\nline2"},
+		{"]]>", "]]&gt;"},
+		{"]>]]>>", "]>]]&gt;>"},
+	}
+
+	for _, tt := range tests {
+		got := string(AppendEscapedText([]byte("x:"), tt.input))
+		if got != "x:"+tt.want {
+			t.Errorf("AppendEscapedText(%q) = %q, want %q", tt.input, got, "x:"+tt.want)
+		}
+	}
+}
+
+func TestAppendEscapedAttr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"plain", "plain"},
+		{"a & b", "a &amp; b"},
+		{"<tag>", "&lt;tag&gt;"},
+		{`say "hi"`, "say &#34;hi&#34;"},
+		{"it's", "it&#39;s"},
+		{"a\tb\nc\rd", "a&#x9;b&#xA;c&#xD;d"},
+	}
+
+	for _, tt := range tests {
+		got := string(AppendEscapedAttr(nil, tt.input))
+		if got != tt.want {
+			t.Errorf("AppendEscapedAttr(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestAppendEscapeAttr_Policy(t *testing.T) {
+	const input = "<a href='x'> & \"y\"\t"
+	tests := []struct {
+		opts MarshalOptions
+		want string
+	}{
+		{MarshalOptions{}, "&lt;a href=&#39;x&#39;&gt; &amp; &#34;y&#34;&#x9;"},
+		{MarshalOptions{LiteralQuotes: true}, "&lt;a href='x'&gt; &amp; &#34;y&#34;&#x9;"},
+		{MarshalOptions{Escape: EscapeMinimal}, "&lt;a href='x'> &amp; &#34;y&#34;&#x9;"},
+	}
+	for _, tt := range tests {
+		if got := string(appendEscapeAttr(nil, input, &tt.opts)); got != tt.want {
+			t.Errorf("appendEscapeAttr(%+v) = %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestIndexEscape(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"", -1},
+		{"short", -1},
+		{"exactly8", -1},
+		{"a much longer string without specials", -1},
+		{"&", 0},
+		{"1234567&", 7},
+		{"12345678<", 8},
+		{"0123456789abcdef>", 16},
+		{"it's", 2},
+	}
+
+	for _, tt := range tests {
+		if got := indexEscape(tt.input, &attrEscapeTable); got != tt.want {
+			t.Errorf("indexEscape(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestAppendEscapedText_CleanAndDirty(t *testing.T) {
+	clean := "The quick brown fox jumps over the lazy dog"
+	if got := string(AppendEscapedText(nil, clean)); got != clean {
+		t.Errorf("clean string changed: %q", got)
+	}
+	dirty := "The quick brown fox & the <lazy> \"dog\""
+	want := "The quick brown fox &amp; the &lt;lazy> \"dog\""
+	if got := string(AppendEscapedText(nil, dirty)); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func BenchmarkAppendEscapedText_Clean(b *testing.B) {
+	s := "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore"
+	buf := make([]byte, 0, 256)
+	b.SetBytes(int64(len(s)))
+	for i := 0; i < b.N; i++ {
+		buf = AppendEscapedText(buf[:0], s)
+	}
+}
+
+func BenchmarkAppendEscapedText_Dirty(b *testing.B) {
+	s := "Lorem ipsum <dolor> sit amet, consectetur & adipiscing elit, sed \"do\" eiusmod tempor incididunt ut labore"
+	buf := make([]byte, 0, 256)
+	b.SetBytes(int64(len(s)))
+	for i := 0; i < b.N; i++ {
+		buf = AppendEscapedText(buf[:0], s)
+	}
+}
@@ -0,0 +1,55 @@
+package xml
+
+import (
+	"reflect"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+// Equal reports whether the trees a and b, as returned by Parse, hold the
+// same document content: the same attributes, text, CDATA and child
+// elements, with repeated elements in the same order. Source positions
+// are ignored, so a tree equals the tree parsed from its Render output.
+//
+// Example:
+//
+//	a, _ := xml.Parse(`<user id="1"><name>Alice</name></user>`)
+//	b, _ := xml.Parse(`<user  id='1'>
+//	    <name>Alice</name>
+//	</user>`)
+//	xml.Equal(a, b) // true
+func Equal(a, b ast.SchemaNode) bool {
+	switch x := a.(type) {
+	case nil:
+		return b == nil
+	case *ast.LiteralNode:
+		y, ok := b.(*ast.LiteralNode)
+		return ok && reflect.DeepEqual(x.Value(), y.Value())
+	case *ast.ArrayDataNode:
+		y, ok := b.(*ast.ArrayDataNode)
+		if !ok || x.Len() != y.Len() {
+			return false
+		}
+		for i, elem := range x.Elements() {
+			if !Equal(elem, y.Get(i)) {
+				return false
+			}
+		}
+		return true
+	case *ast.ObjectNode:
+		y, ok := b.(*ast.ObjectNode)
+		if !ok || len(x.Properties()) != len(y.Properties()) {
+			return false
+		}
+		for key, prop := range x.Properties() {
+			other, ok := y.GetProperty(key)
+			if !ok || !Equal(prop, other) {
+				return false
+			}
+		}
+		return true
+	default:
+		// Other node kinds do not occur in parsed documents.
+		return reflect.DeepEqual(a, b)
+	}
+}
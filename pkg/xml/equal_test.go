@@ -0,0 +1,43 @@
+package xml
+
+import (
+	"testing"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{`<a x="1"><b>t</b></a>`, "<a  x='1'>\n  <b>t</b>\n</a>", true},
+		{`<a><b>1</b><b>2</b></a>`, `<z><b>1</b><b>2</b></z>`, true},
+		{`<a>&lt;b&gt;</a>`, `<a><![CDATA[<b>]]></a>`, false},
+		{`<a><b>1</b><b>2</b></a>`, `<a><b>2</b><b>1</b></a>`, false},
+		{`<a x="1"/>`, `<a x="2"/>`, false},
+		{`<a x="1"/>`, `<a y="1"/>`, false},
+		{`<a><b/></a>`, `<a><b/><c/></a>`, false},
+		{`<a><b>1</b></a>`, `<a><b>1</b><b>1</b></a>`, false},
+	}
+	for _, tt := range tests {
+		a, err := Parse(tt.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := Parse(tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Equal(a, b); got != tt.want {
+			t.Errorf("Equal(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := Equal(b, a); got != tt.want {
+			t.Errorf("Equal(%s, %s) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+
+	if !Equal(nil, nil) || Equal(nil, ast.NewLiteralNode("x", ast.ZeroPosition())) {
+		t.Error("Equal mishandles nil")
+	}
+}
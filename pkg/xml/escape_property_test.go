@@ -0,0 +1,222 @@
+package xml
+
+import (
+	"bytes"
+	stdxml "encoding/xml"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+	"unicode/utf8"
+)
+
+// escapePieces are the fragments escapeString builds strings from: markup
+// characters, quotes, whitespace that XML normalizes, control characters,
+// surrogate and invalid UTF-8 encodings, and the CDATA terminator.
+var escapePieces = []string{
+	"a", "Z", "0", " ", "  ", "\t", "\n", "\r", "\r\n",
+	"<", ">", "&", `"`, "'", "&amp;", "&#34;", "<![CDATA[", "]]>", "]", "]]",
+	"\x00", "\x01", "\x1f", "\x7f", "\u0085", "￾", "￿",
+	"\xed\xa0\x80", "\xed\xbf\xbf", "\xff", "\xc3",
+	"é", "中", "😀", " ",
+}
+
+// escapeString is a quick.Config Values function generating strings from
+// escapePieces.
+func escapeString(args []reflect.Value, r *rand.Rand) {
+	for i := range args {
+		var sb strings.Builder
+		for n := r.Intn(12); n > 0; n-- {
+			sb.WriteString(escapePieces[r.Intn(len(escapePieces))])
+		}
+		args[i] = reflect.ValueOf(sb.String())
+	}
+}
+
+// xmlChars returns s with the characters XML cannot represent replaced by
+// U+FFFD, which is what the encoders write in their place. Each byte of
+// invalid UTF-8 becomes one U+FFFD.
+func xmlChars(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if !isXMLChar(r) {
+			r = utf8.RuneError
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// normalizeNewlines replaces "\r\n" and "\r" by "\n", as the parser does.
+func normalizeNewlines(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\r", "\n")
+}
+
+// trimXMLSpace trims the whitespace the parser trims from element text.
+func trimXMLSpace(s string) string {
+	return strings.Trim(s, " \t\r\n")
+}
+
+type escapeDoc struct {
+	Attr  string `xml:"a,attr"`
+	Text  string `xml:"t"`
+	CDATA string `xml:"c"`
+}
+
+type escapeCDATA struct {
+	Value string `xml:",cdata"`
+}
+
+type escapeDocCDATA struct {
+	Attr  string      `xml:"a,attr"`
+	Text  string      `xml:"t"`
+	CDATA escapeCDATA `xml:"c"`
+}
+
+func TestEscape_RoundTripProperty(t *testing.T) {
+	cfg := &quick.Config{MaxCount: 2000, Rand: rand.New(rand.NewSource(1)), Values: escapeString}
+
+	check := func(attr, text, cdata string) bool {
+		// Element text is trimmed when parsed; attribute values and CDATA
+		// are not. Carriage returns in CDATA cannot be escaped, so they
+		// are normalized to newlines.
+		want := escapeDoc{Attr: xmlChars(attr), Text: trimXMLSpace(xmlChars(text)), CDATA: normalizeNewlines(xmlChars(cdata))}
+		doc := escapeDocCDATA{Attr: attr, Text: text, CDATA: escapeCDATA{Value: cdata}}
+
+		marshaled, err := Marshal(doc)
+		if err != nil {
+			t.Logf("Marshal: %v", err)
+			return false
+		}
+
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		if err := enc.Encode(doc); err != nil {
+			t.Logf("Encode: %v", err)
+			return false
+		}
+		if err := enc.Close(); err != nil {
+			t.Logf("Close: %v", err)
+			return false
+		}
+		streamed := buf.Bytes()
+
+		// The token writer has no CDATA call, so c is written as text.
+		var written bytes.Buffer
+		enc = NewEncoder(&written)
+		for _, err := range []error{
+			enc.WriteStartElement("escapeDoc"),
+			enc.WriteAttr("a", attr),
+			enc.WriteStartElement("t"), enc.WriteText(text), enc.WriteEnd(),
+			enc.WriteStartElement("c"), enc.WriteText(cdata), enc.WriteEnd(),
+			enc.WriteEnd(), enc.Close(),
+		} {
+			if err != nil {
+				t.Logf("token writer: %v", err)
+				return false
+			}
+		}
+
+		writtenWant := want
+		writtenWant.CDATA = trimXMLSpace(xmlChars(cdata))
+
+		for _, out := range []struct {
+			name string
+			data []byte
+			want escapeDoc
+		}{
+			{"Marshal", marshaled, want},
+			{"Encoder.Encode", streamed, want},
+			{"Encoder.Write", written.Bytes(), writtenWant},
+		} {
+			var got escapeDoc
+			if err := Unmarshal(out.data, &got); err != nil {
+				t.Logf("%s: Unmarshal(%q): %v", out.name, out.data, err)
+				return false
+			}
+			if got != out.want {
+				t.Logf("%s: %q\n got  %+q\n want %+q", out.name, out.data, got, out.want)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(check, cfg); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMarshal_CDATATerminatorAndInvalidChars(t *testing.T) {
+	got, err := Marshal(escapeDocCDATA{Attr: "\x00", Text: "a\xffb", CDATA: escapeCDATA{Value: "x]]>y\x01"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<escapeDocCDATA a="�"><t>a�b</t><c><![CDATA[x]]]]><![CDATA[>y�]]></c></escapeDocCDATA>`
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+// Tabs, newlines and carriage returns in attribute values must be written as
+// character references under either policy: a conforming parser turns
+// literal ones into spaces.
+func TestEscape_AttrWhitespace(t *testing.T) {
+	type attrDoc struct {
+		E string `xml:"e,attr"`
+	}
+	for _, value := range []string{"a\n\tb", "a\rb", "a\r\nb", "\t", " \n "} {
+		for _, escape := range []EscapePolicy{EscapeAggressive, EscapeMinimal} {
+			opts := MarshalOptions{RootName: "a", Escape: escape}
+			marshaled, err := MarshalWithOptions(attrDoc{E: value}, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			node, err := Parse(string(marshaled))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rendered, err := RenderWithOptions(node, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, out := range []struct {
+				name string
+				data []byte
+			}{{"Marshal", marshaled}, {"Render", rendered}} {
+				if bytes.ContainsAny(out.data, "\t\n\r") {
+					t.Errorf("%s %q, policy %d: literal whitespace in %q", out.name, value, escape, out.data)
+				}
+				var got attrDoc
+				if err := Unmarshal(out.data, &got); err != nil {
+					t.Fatalf("%s: Unmarshal(%q): %v", out.name, out.data, err)
+				}
+				if got.E != value {
+					t.Errorf("%s %q, policy %d: round trip gave %q", out.name, value, escape, got.E)
+				}
+				var std struct {
+					E string `xml:"e,attr"`
+				}
+				if err := stdxml.Unmarshal(out.data, &std); err != nil {
+					t.Fatalf("%s: encoding/xml Unmarshal(%q): %v", out.name, out.data, err)
+				}
+				if std.E != value {
+					t.Errorf("%s %q, policy %d: encoding/xml read %q", out.name, value, escape, std.E)
+				}
+			}
+		}
+	}
+
+	// A parsed reference is rendered as a reference again.
+	node, err := Parse(`<a e="a&#xA;&#x9;b"/>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := RenderWithOptions(node, MarshalOptions{RootName: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<a e="a&#xA;&#x9;b"/>`; string(got) != want {
+		t.Errorf("Render: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,146 @@
+package xml
+
+import "io"
+
+// Position is a location in the input: a byte offset, and the line and
+// column of that offset, both starting at 1 and the column counted in
+// bytes.
+type Position struct {
+	Offset       int64
+	Line, Column int
+}
+
+// EventHandler receives the events of ParseEvents. Each method is given the
+// position at which its construct starts. Returning an error stops parsing;
+// ParseEvents then returns that error.
+type EventHandler interface {
+	// StartElement is called for a start tag, with its attributes in
+	// document order and their entities decoded. A self-closing tag is
+	// followed by EndElement, at the same position.
+	StartElement(name string, attrs []Attr, pos Position) error
+	// EndElement is called for an end tag.
+	EndElement(name string, pos Position) error
+	// Text is called for character data, with entities decoded, including
+	// the whitespace between elements.
+	Text(text string, pos Position) error
+	// CData is called for the content of a CDATA section.
+	CData(text string, pos Position) error
+	// Comment is called for the text of a comment, without its delimiters.
+	Comment(text string, pos Position) error
+}
+
+// EventFuncs is an EventHandler calling the functions that are set; events
+// without a function are ignored.
+//
+// Example:
+//
+//	depth := 0
+//	err := xml.ParseEvents(file, xml.EventFuncs{
+//	    OnStartElement: func(name string, attrs []xml.Attr, pos xml.Position) error {
+//	        depth++
+//	        return nil
+//	    },
+//	    OnEndElement: func(name string, pos xml.Position) error {
+//	        depth--
+//	        return nil
+//	    },
+//	})
+type EventFuncs struct {
+	OnStartElement func(name string, attrs []Attr, pos Position) error
+	OnEndElement   func(name string, pos Position) error
+	OnText         func(text string, pos Position) error
+	OnCData        func(text string, pos Position) error
+	OnComment      func(text string, pos Position) error
+}
+
+// StartElement calls OnStartElement, if set.
+func (f EventFuncs) StartElement(name string, attrs []Attr, pos Position) error {
+	if f.OnStartElement == nil {
+		return nil
+	}
+	return f.OnStartElement(name, attrs, pos)
+}
+
+// EndElement calls OnEndElement, if set.
+func (f EventFuncs) EndElement(name string, pos Position) error {
+	if f.OnEndElement == nil {
+		return nil
+	}
+	return f.OnEndElement(name, pos)
+}
+
+// Text calls OnText, if set.
+func (f EventFuncs) Text(text string, pos Position) error {
+	if f.OnText == nil {
+		return nil
+	}
+	return f.OnText(text, pos)
+}
+
+// CData calls OnCData, if set.
+func (f EventFuncs) CData(text string, pos Position) error {
+	if f.OnCData == nil {
+		return nil
+	}
+	return f.OnCData(text, pos)
+}
+
+// Comment calls OnComment, if set.
+func (f EventFuncs) Comment(text string, pos Position) error {
+	if f.OnComment == nil {
+		return nil
+	}
+	return f.OnComment(text, pos)
+}
+
+// ParseEvents reads an XML document from r and reports its content to
+// handler as it goes, SAX style, holding only the open elements in memory.
+// It returns nil after the root element has been closed and the input is
+// exhausted, the first error in the input, or the first error returned by
+// handler. Events already delivered for a malformed document are not taken
+// back.
+//
+// Processing instructions, the XML declaration and the document type
+// declaration are not reported, though entities declared in the DOCTYPE
+// are expanded.
+//
+// Example:
+//
+//	var titles int
+//	err := xml.ParseEvents(file, xml.EventFuncs{
+//	    OnStartElement: func(name string, attrs []xml.Attr, pos xml.Position) error {
+//	        if name == "title" {
+//	            titles++
+//	        }
+//	        return nil
+//	    },
+//	})
+func ParseEvents(r io.Reader, handler EventHandler) error {
+	d := NewDecoder(r)
+	d.emitMarkup = true
+	for {
+		tok, err := d.readToken()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		pos := d.tokenPos
+		switch tok.kind {
+		case tokenStart:
+			err = handler.StartElement(tok.name, startElement(tok).Attr, pos)
+		case tokenEnd:
+			err = handler.EndElement(tok.name, pos)
+		case tokenText:
+			err = handler.Text(tok.text, pos)
+		case tokenCDATA:
+			err = handler.CData(tok.text, pos)
+		case tokenComment:
+			err = handler.Comment(tok.text, pos)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package xml
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// eventRecorder records events as strings, with the line and column.
+type eventRecorder struct {
+	events []string
+}
+
+func (r *eventRecorder) add(pos Position, format string, args ...interface{}) error {
+	r.events = append(r.events, fmt.Sprintf("%d:%d ", pos.Line, pos.Column)+fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (r *eventRecorder) StartElement(name string, attrs []Attr, pos Position) error {
+	return r.add(pos, "start %s %v", name, attrs)
+}
+func (r *eventRecorder) EndElement(name string, pos Position) error {
+	return r.add(pos, "end %s", name)
+}
+func (r *eventRecorder) Text(text string, pos Position) error {
+	return r.add(pos, "text %q", text)
+}
+func (r *eventRecorder) CData(text string, pos Position) error {
+	return r.add(pos, "cdata %q", text)
+}
+func (r *eventRecorder) Comment(text string, pos Position) error {
+	return r.add(pos, "comment %q", text)
+}
+
+func TestParseEvents(t *testing.T) {
+	input := "<?xml version=\"1.0\"?>\n<!DOCTYPE r [<!ENTITY e \"E\">]>\n" +
+		"<r a=\"1\"\n   b='&amp;'>\n  <x/>t &e;<!-- c -->\n<![CDATA[<d>]]><?pi?><é>é</é></r>\n"
+	var r eventRecorder
+	if err := ParseEvents(strings.NewReader(input), &r); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`3:1 start r [{a 1} {b &}]`,
+		`4:14 text "\n  "`,
+		`5:3 start x []`,
+		`5:3 end x`,
+		`5:7 text "t E"`,
+		`5:12 comment " c "`,
+		`5:22 text "\n"`,
+		`6:1 cdata "<d>"`,
+		`6:22 start é []`,
+		`6:26 text "é"`,
+		`6:28 end é`,
+		`6:33 end r`,
+	}
+	if strings.Join(r.events, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(r.events, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestParseEvents_Offsets(t *testing.T) {
+	input := "<a>\r\n<b>x</b>\n\n<c/></a>"
+	var offsets []int64
+	err := ParseEvents(strings.NewReader(input), EventFuncs{
+		OnStartElement: func(name string, attrs []Attr, pos Position) error {
+			offsets = append(offsets, pos.Offset)
+			if input[pos.Offset] != '<' || input[pos.Offset+1:pos.Offset+2] != name {
+				t.Errorf("<%s> reported at offset %d", name, pos.Offset)
+			}
+			return nil
+		},
+	})
+	if err != nil || fmt.Sprint(offsets) != "[0 5 15]" {
+		t.Errorf("offsets = %v, %v", offsets, err)
+	}
+}
+
+func TestParseEvents_Errors(t *testing.T) {
+	stop := errors.New("stop")
+	var starts int
+	err := ParseEvents(strings.NewReader(`<a><b/><c/></a>`), EventFuncs{
+		OnStartElement: func(name string, attrs []Attr, pos Position) error {
+			starts++
+			if name == "b" {
+				return stop
+			}
+			return nil
+		},
+	})
+	if err != stop || starts != 2 {
+		t.Errorf("ParseEvents = %v after %d start tags, want the handler's error after 2", err, starts)
+	}
+
+	var r eventRecorder
+	err = ParseEvents(strings.NewReader(`<a><b></a>`), &r)
+	if err == nil || !strings.Contains(err.Error(), "mismatched tags") {
+		t.Errorf("ParseEvents(malformed) = %v", err)
+	}
+	if len(r.events) != 2 {
+		t.Errorf("events before the error = %v", r.events)
+	}
+	if err := ParseEvents(strings.NewReader(``), EventFuncs{}); err == nil {
+		t.Error("ParseEvents(empty input): expected an error")
+	}
+}
@@ -0,0 +1,35 @@
+package xml
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Unmarshal decodes without building an AST; into an interface{} it must
+// produce what the AST path, Parse followed by NodeToInterface, produces.
+func TestUnmarshal_FastPathMatchesAST(t *testing.T) {
+	docs := []string{
+		`<root/>`,
+		`<user id="1"><name>Alice</name><tag>a</tag><tag>b</tag></user>`,
+		"<a>\n  <b x='1'>text</b>\n  <b/>\n  <c>é &amp; ✓</c>\n</a>",
+		`<a><![CDATA[<raw>]]></a>`,
+		`<a>text<b>child</b></a>`,
+		`<!DOCTYPE a><a><!-- c -->text &lt;&#65;&gt;</a>`,
+		`<ns:a xmlns:ns="urn:x" ns:id="1"><ns:b>1</ns:b></ns:a>`,
+	}
+	for _, doc := range docs {
+		node, err := Parse(doc)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", doc, err)
+		}
+		want := NodeToInterface(node)
+
+		var got interface{}
+		if err := Unmarshal([]byte(doc), &got); err != nil {
+			t.Fatalf("Unmarshal(%q): %v", doc, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Unmarshal(%q)\ngot  %#v\nwant %#v", doc, got, want)
+		}
+	}
+}
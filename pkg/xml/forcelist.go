@@ -0,0 +1,117 @@
+package xml
+
+import (
+	"strings"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+// forceList matches the element paths given to WithForceList.
+type forceList struct {
+	// paths holds full paths from the root element; "*" matches any name.
+	paths [][]string
+	// names holds bare element names, matched at any depth.
+	names map[string]bool
+}
+
+// newForceList parses paths written as "rss/channel/item", "rss.channel.item"
+// or a bare name such as "item".
+func newForceList(paths []string) *forceList {
+	fl := &forceList{names: make(map[string]bool)}
+	for _, p := range paths {
+		segments := splitElementPath(p)
+		if len(segments) == 1 {
+			fl.names[segments[0]] = true
+			continue
+		}
+		fl.paths = append(fl.paths, segments)
+	}
+	return fl
+}
+
+// splitElementPath splits a path written as "a/b/c" or "a.b.c" into its
+// segments. A leading '/' is ignored.
+func splitElementPath(p string) []string {
+	p = strings.TrimPrefix(p, "/")
+	if strings.Contains(p, "/") {
+		return strings.Split(p, "/")
+	}
+	return strings.Split(p, ".")
+}
+
+// matchPath reports whether path matches pattern segment by segment, with
+// "*" matching any name. If rooted, path starts at the root element, whose
+// name may be "" to match any root.
+func matchPath(pattern, path []string, rooted bool) bool {
+	if len(pattern) != len(path) {
+		return false
+	}
+	for i, seg := range pattern {
+		if seg != "*" && seg != path[i] && !(rooted && i == 0 && path[0] == "") {
+			return false
+		}
+	}
+	return true
+}
+
+// match reports whether the element at path, which starts with the root
+// element's name, must be a list. An empty root name matches any root.
+func (fl *forceList) match(path []string) bool {
+	if fl.names[path[len(path)-1]] {
+		return true
+	}
+	for _, p := range fl.paths {
+		if matchPath(p, path, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply wraps every matching child element of m, the element at path, in a
+// one-item slice unless it is already a list.
+func (fl *forceList) apply(m map[string]interface{}, path []string) {
+	for k, child := range m {
+		if k == "" || k[0] == '@' || k[0] == '#' {
+			continue
+		}
+		childPath := append(path[:len(path):len(path)], k)
+		switch v := child.(type) {
+		case map[string]interface{}:
+			if fl.match(childPath) {
+				m[k] = []interface{}{v}
+			}
+			fl.apply(v, childPath)
+		case []interface{}:
+			for _, item := range v {
+				if im, ok := item.(map[string]interface{}); ok {
+					fl.apply(im, childPath)
+				}
+			}
+		}
+	}
+}
+
+// applyNodes is apply for the AST.
+func (fl *forceList) applyNodes(node *ast.ObjectNode, path []string) {
+	props := node.Properties()
+	for k, child := range props {
+		if k == "" || k[0] == '@' || k[0] == '#' {
+			continue
+		}
+		childPath := append(path[:len(path):len(path)], k)
+		switch v := child.(type) {
+		case *ast.ObjectNode:
+			if fl.match(childPath) {
+				props[k] = ast.NewArrayDataNode([]ast.SchemaNode{v}, v.Position())
+			}
+			fl.applyNodes(v, childPath)
+		case *ast.ArrayDataNode:
+			for _, item := range v.Elements() {
+				if obj, ok := item.(*ast.ObjectNode); ok {
+					fl.applyNodes(obj, childPath)
+				}
+			}
+		}
+	}
+}
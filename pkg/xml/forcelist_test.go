@@ -0,0 +1,74 @@
+package xml
+
+import (
+	"reflect"
+	"testing"
+)
+
+const forceListFeed = `<rss><channel><title>News</title><item><title>One</title></item></channel></rss>`
+
+func TestWithForceList(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+	}{
+		{"dotted", []string{"rss.channel.item"}},
+		{"slashed", []string{"/rss/channel/item"}},
+		{"wildcard", []string{"rss.*.item"}},
+		{"bare name", []string{"item"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v map[string]interface{}
+			if err := UnmarshalWithOptions([]byte(forceListFeed), &v, WithForceList(tt.paths...)); err != nil {
+				t.Fatalf("UnmarshalWithOptions failed: %v", err)
+			}
+			channel := v["channel"].(map[string]interface{})
+			items, ok := channel["item"].([]interface{})
+			if !ok || len(items) != 1 {
+				t.Fatalf("item = %#v, want a one-item list", channel["item"])
+			}
+			if _, ok := channel["title"].(map[string]interface{}); !ok {
+				t.Errorf("title = %#v, want it left as a single element", channel["title"])
+			}
+		})
+	}
+}
+
+func TestWithForceList_Unaffected(t *testing.T) {
+	// Repeated elements are already lists and must not be nested again.
+	input := `<rss><channel><item>a</item><item>b</item></channel></rss>`
+	var v map[string]interface{}
+	if err := UnmarshalWithOptions([]byte(input), &v, WithForceList("rss.channel.item")); err != nil {
+		t.Fatalf("UnmarshalWithOptions failed: %v", err)
+	}
+	want := []interface{}{
+		map[string]interface{}{"#text": "a"},
+		map[string]interface{}{"#text": "b"},
+	}
+	if got := v["channel"].(map[string]interface{})["item"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("item = %#v", got)
+	}
+
+	// A path under a different root does not match.
+	v = nil
+	if err := UnmarshalWithOptions([]byte(forceListFeed), &v, WithForceList("feed.channel.item")); err != nil {
+		t.Fatalf("UnmarshalWithOptions failed: %v", err)
+	}
+	if _, ok := v["channel"].(map[string]interface{})["item"].(map[string]interface{}); !ok {
+		t.Errorf("item under another root was forced into a list: %#v", v)
+	}
+}
+
+func TestWithForceList_NestedInLists(t *testing.T) {
+	input := `<order><line><sku>a</sku></line><line><sku>b</sku></line></order>`
+	var v map[string]interface{}
+	if err := UnmarshalWithOptions([]byte(input), &v, WithForceList("order.line.sku")); err != nil {
+		t.Fatalf("UnmarshalWithOptions failed: %v", err)
+	}
+	for i, line := range v["line"].([]interface{}) {
+		if _, ok := line.(map[string]interface{})["sku"].([]interface{}); !ok {
+			t.Errorf("line %d: sku = %#v, want a list", i, line)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package xml
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// appendDeclaration appends the XML declaration described by opts and a
+// newline to dst.
+func appendDeclaration(dst []byte, opts *MarshalOptions) []byte {
+	encoding := opts.Encoding
+	if encoding == "" {
+		encoding = "UTF-8"
+	}
+	dst = append(dst, `<?xml version="1.0" encoding="`...)
+	dst = append(dst, encoding...)
+	dst = append(dst, '"')
+	if opts.Standalone != "" {
+		dst = append(dst, ` standalone="`...)
+		dst = append(dst, opts.Standalone...)
+		dst = append(dst, '"')
+	}
+	return append(dst, "?>\n"...)
+}
+
+// validate checks the options holding names and declaration values.
+func (o *MarshalOptions) validate() error {
+	if o.RootName != "" && !isValidXMLName(o.RootName) {
+		return fmt.Errorf("xml: invalid root name %q", o.RootName)
+	}
+	if o.Encoding != "" && !isEncodingName(o.Encoding) {
+		return fmt.Errorf("xml: invalid declaration encoding %q", o.Encoding)
+	}
+	if o.Standalone != "" && o.Standalone != "yes" && o.Standalone != "no" {
+		return fmt.Errorf("xml: invalid declaration standalone %q, want \"yes\" or \"no\"", o.Standalone)
+	}
+	return nil
+}
+
+// isEncodingName reports whether s matches the EncName production of the
+// XML specification, [A-Za-z] ([A-Za-z0-9._] | '-')*.
+func isEncodingName(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case i > 0 && (c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-'):
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// AttrOrder selects the order in which Marshal writes the attributes of a
+// struct.
+type AttrOrder int
+
+const (
+	// AttrOrderSorted writes attributes sorted by name. This is the default.
+	AttrOrderSorted AttrOrder = iota
+	// AttrOrderDeclared writes attributes in the order of their struct
+	// fields, followed by computed XMLAttr_ attributes sorted by name.
+	AttrOrderDeclared
+)
+
+// EmptyElementStyle selects how elements without content are written.
+type EmptyElementStyle int
+
+const (
+	// EmptySelfClosing writes empty elements as <a/>. This is the default.
+	EmptySelfClosing EmptyElementStyle = iota
+	// EmptyExpanded writes empty elements as <a></a>.
+	EmptyExpanded
+)
+
+// EscapePolicy selects how much Marshal and Render escape attribute
+// values. Both write the same references under either policy. Text is
+// escaped as AppendEscapedText does under either policy: & and < always, >
+// only where it would close "]]>", and quotes never.
+type EscapePolicy int
+
+const (
+	// EscapeAggressive escapes &, <, >, ' and " in attribute values, as
+	// &amp;, &lt;, &gt;, &#39; and &#34;. This is the default.
+	EscapeAggressive EscapePolicy = iota
+	// EscapeMinimal escapes only what XML requires in attribute values: &,
+	// < and the " that delimits them. Carriage returns, and tabs and
+	// newlines in attribute values, are still written as character
+	// references so that parsing keeps them.
+	EscapeMinimal
+)
+
+// reformats reports whether o changes the layout of the compact encoding,
+// which formatXML then applies.
+func (o *MarshalOptions) reformats() bool {
+	return o.indents() || o.EmptyElements == EmptyExpanded
+}
+
+// indents reports whether o asks for indented output.
+func (o *MarshalOptions) indents() bool {
+	return o.Prefix != "" || o.Indent != ""
+}
+
+// markupKind classifies the pieces formatXML splits a document into.
+type markupKind int
+
+const (
+	markupText markupKind = iota
+	markupCDATA
+	markupStart
+	markupEmpty // self-closing start tag
+	markupEnd
+	markupOther // comments, processing instructions and declarations
+	markupRaw   // a RawXML fragment, enclosed in rawMark
+)
+
+// markup is one piece of a document: a tag, text or other markup.
+type markup struct {
+	kind markupKind
+	raw  []byte
+	// mixed is set on a start tag whose element holds text or CDATA
+	// directly, so that its content is written unchanged.
+	mixed bool
+}
+
+// splitMarkup splits the well-formed XML in src into its pieces. Quoted
+// attribute values may contain '>'.
+func splitMarkup(src []byte) []markup {
+	var out []markup
+	for len(src) > 0 {
+		if src[0] == rawMark {
+			var raw []byte
+			raw, src = cutRaw(src)
+			out = append(out, markup{kind: markupRaw, raw: raw})
+			continue
+		}
+		if src[0] != '<' {
+			i := bytes.IndexAny(src, "<\x00")
+			if i < 0 {
+				i = len(src)
+			}
+			out = append(out, markup{kind: markupText, raw: src[:i]})
+			src = src[i:]
+			continue
+		}
+		var end int
+		kind := markupOther
+		switch {
+		case bytes.HasPrefix(src, []byte("<![CDATA[")):
+			kind, end = markupCDATA, indexAfter(src, "]]>")
+		case bytes.HasPrefix(src, []byte("<!--")):
+			end = indexAfter(src, "-->")
+		case bytes.HasPrefix(src, []byte("<?")):
+			end = indexAfter(src, "?>")
+		default:
+			end = tagEnd(src)
+			switch {
+			case src[1] == '/':
+				kind = markupEnd
+			case src[1] == '!':
+				kind = markupOther
+			case end >= 2 && src[end-2] == '/':
+				kind = markupEmpty
+			default:
+				kind = markupStart
+			}
+		}
+		out = append(out, markup{kind: kind, raw: src[:end]})
+		src = src[end:]
+	}
+	return out
+}
+
+// indexAfter returns the index just past the first delim in src, or
+// len(src) if there is none.
+func indexAfter(src []byte, delim string) int {
+	if i := bytes.Index(src, []byte(delim)); i >= 0 {
+		return i + len(delim)
+	}
+	return len(src)
+}
+
+// tagEnd returns the index just past the '>' closing the tag at the start
+// of src, skipping quoted attribute values.
+func tagEnd(src []byte) int {
+	var quote byte
+	for i := 1; i < len(src); i++ {
+		switch c := src[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i + 1
+		}
+	}
+	return len(src)
+}
+
+// formatXML appends the compact XML in src to dst, laid out as opts asks:
+// indented with opts.Prefix and opts.Indent starting at depth, and empty
+// elements expanded. An element holding text is written unchanged, so
+// indentation never alters text content; raw fragments are copied as they
+// are. If newline is set, the first indented line starts on a new line.
+func formatXML(dst, src []byte, opts *MarshalOptions, depth int, newline bool) []byte {
+	pieces := splitMarkup(src)
+
+	// Mark the elements holding text, whose content keeps its layout.
+	var open []int
+	for i, p := range pieces {
+		switch p.kind {
+		case markupStart:
+			open = append(open, i)
+		case markupEnd:
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		case markupText, markupCDATA, markupRaw:
+			if len(open) > 0 {
+				pieces[open[len(open)-1]].mixed = true
+			}
+		}
+	}
+
+	indent := opts.indents()
+	writeIndent := func(depth int) {
+		if newline {
+			dst = append(dst, '\n')
+		}
+		newline = true
+		dst = append(dst, opts.Prefix...)
+		for i := 0; i < depth; i++ {
+			dst = append(dst, opts.Indent...)
+		}
+	}
+
+	// frames records, for each open element, whether its content is
+	// indented and whether it has started a line for a child.
+	type frame struct {
+		indented bool
+		children bool
+	}
+	frames := []frame{{indented: indent}}
+	for _, p := range pieces {
+		top := &frames[len(frames)-1]
+		switch p.kind {
+		case markupStart, markupEmpty, markupOther:
+			if top.indented {
+				writeIndent(depth + len(frames) - 1)
+				top.children = true
+			}
+			switch {
+			case p.kind == markupOther:
+				dst = append(dst, p.raw...)
+			case p.kind == markupEmpty && opts.EmptyElements == EmptyExpanded:
+				dst = append(dst, p.raw[:len(p.raw)-2]...)
+				dst = append(dst, '>', '<', '/')
+				dst = append(dst, tagName(p.raw)...)
+				dst = append(dst, '>')
+			default:
+				dst = append(dst, p.raw...)
+			}
+			if p.kind == markupStart {
+				frames = append(frames, frame{indented: top.indented && !p.mixed})
+			}
+		case markupEnd:
+			if len(frames) > 1 {
+				if top.indented && top.children {
+					writeIndent(depth + len(frames) - 2)
+				}
+				frames = frames[:len(frames)-1]
+			}
+			dst = append(dst, p.raw...)
+		default:
+			dst = append(dst, p.raw...)
+		}
+	}
+	return dst
+}
+
+// tagName returns the element name of the start tag raw.
+func tagName(raw []byte) []byte {
+	end := 1
+	for end < len(raw) && !strings.ContainsRune(" \t\r\n/>", rune(raw[end])) {
+		end++
+	}
+	return raw[1:end]
+}
@@ -0,0 +1,291 @@
+package xml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type fmtAddress struct {
+	City string `xml:"city"`
+	Zip  string `xml:"zip,attr"`
+}
+
+type fmtPerson struct {
+	Name    string      `xml:"name,attr"`
+	ID      int         `xml:"id,attr"`
+	Age     int         `xml:"age"`
+	Note    string      `xml:"note"`
+	Address *fmtAddress `xml:"address"`
+	Tags    []string    `xml:"tag"`
+	Bio     string      `xml:"bio,omitempty"`
+}
+
+func (p fmtPerson) XMLAttr_kind() string { return "person" }
+
+type fmtMixed struct {
+	Text  string   `xml:",chardata"`
+	Items []string `xml:"i"`
+}
+
+func TestMarshalWithOptions_Layout(t *testing.T) {
+	p := fmtPerson{Name: `O'Neil "Jr"`, ID: 7, Age: 40, Address: &fmtAddress{City: "Cork", Zip: "T12"}, Tags: []string{"a", "b"}}
+
+	tests := []struct {
+		name string
+		opts MarshalOptions
+		want string
+	}{
+		{"default", MarshalOptions{},
+			`<fmtPerson id="7" kind="person" name="O&#39;Neil &#34;Jr&#34;"><age>40</age><note></note>` +
+				`<address zip="T12"><city>Cork</city></address><tag>a</tag><tag>b</tag></fmtPerson>`},
+		{"root name and declaration", MarshalOptions{RootName: "p", Declaration: true},
+			`<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`<p id="7" kind="person" name="O&#39;Neil &#34;Jr&#34;"><age>40</age><note></note>` +
+				`<address zip="T12"><city>Cork</city></address><tag>a</tag><tag>b</tag></p>`},
+		{"declared attribute order", MarshalOptions{AttrOrder: AttrOrderDeclared},
+			`<fmtPerson name="O&#39;Neil &#34;Jr&#34;" id="7" kind="person"><age>40</age>`},
+		{"literal quotes", MarshalOptions{LiteralQuotes: true},
+			`<fmtPerson id="7" kind="person" name="O'Neil &#34;Jr&#34;">`},
+		{"expanded empty elements", MarshalOptions{EmptyElements: EmptyExpanded, RootName: "p"},
+			`<p id="7" kind="person" name="O&#39;Neil &#34;Jr&#34;"><age>40</age><note></note>`},
+		{"indent", MarshalOptions{Prefix: "# ", Indent: "  ", Declaration: true},
+			`<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`# <fmtPerson id="7" kind="person" name="O&#39;Neil &#34;Jr&#34;">` + "\n" +
+				`#   <age>40</age>` + "\n" +
+				`#   <note></note>` + "\n" +
+				`#   <address zip="T12">` + "\n" +
+				`#     <city>Cork</city>` + "\n" +
+				`#   </address>` + "\n" +
+				`#   <tag>a</tag>` + "\n" +
+				`#   <tag>b</tag>` + "\n" +
+				`# </fmtPerson>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MarshalWithOptions(p, tt.opts)
+			if err != nil {
+				t.Fatalf("MarshalWithOptions failed: %v", err)
+			}
+			if !strings.HasPrefix(string(got), tt.want) {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalWithOptions_EmptyElements(t *testing.T) {
+	v := struct {
+		A *fmtAddress `xml:"a"`
+		B []string    `xml:"b"`
+		C fmtMixed    `xml:"c"`
+	}{}
+	got, err := MarshalWithOptions(v, MarshalOptions{EmptyElements: EmptyExpanded, RootName: "r"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<r><c></c></r>`; string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	got, _ = MarshalWithOptions(nil, MarshalOptions{EmptyElements: EmptyExpanded})
+	if string(got) != `<root></root>` {
+		t.Errorf("nil = %s", got)
+	}
+}
+
+func TestMarshalWithOptions_IndentKeepsText(t *testing.T) {
+	v := struct {
+		Mixed fmtMixed `xml:"m"`
+		Space string   `xml:"s"`
+	}{Mixed: fmtMixed{Text: "t", Items: []string{"1", "2"}}, Space: "  "}
+	got, err := MarshalWithOptions(v, MarshalOptions{Indent: "\t", RootName: "r"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<r>\n\t<m>t<i>1</i><i>2</i></m>\n\t<s>  </s>\n</r>"
+	if string(got) != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestMarshalWithOptions_InvalidRootName(t *testing.T) {
+	if _, err := MarshalWithOptions(1, MarshalOptions{RootName: "1st"}); err == nil {
+		t.Error("expected an error for an invalid root name")
+	}
+}
+
+func TestMarshalIndent(t *testing.T) {
+	got, err := MarshalIndent(fmtAddress{City: "Cork"}, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<fmtAddress>\n  <city>Cork</city>\n</fmtAddress>"; string(got) != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestFormatXML_Markup(t *testing.T) {
+	// Output of MarshalXML methods may hold any markup, which is laid out
+	// with its escaping kept.
+	src := `<?pi x?><a q='it&#39;s' r="&#34;&#39;>"><!-- c --><b/><![CDATA[&#34;]]></a><c><d>&#34;</d></c>`
+	opts := &MarshalOptions{Indent: " ", LiteralQuotes: true, EmptyElements: EmptyExpanded}
+	got := formatXML(nil, []byte(src), opts, 0, false)
+	want := "<?pi x?>\n<a q='it&#39;s' r=\"&#34;&#39;>\"><!-- c --><b></b><![CDATA[&#34;]]></a>\n<c>\n <d>&#34;</d>\n</c>"
+	if string(got) != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestRenderWithOptions_Layout(t *testing.T) {
+	node, err := Parse(`<a x="it's"><b/><c>t</c></a>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := RenderWithOptions(node, MarshalOptions{RootName: "a", Indent: "  ", EmptyElements: EmptyExpanded, LiteralQuotes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<a x=\"it's\">\n  <b></b>\n  <c>t</c>\n</a>"; string(got) != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestEscapePolicy(t *testing.T) {
+	v := fmtAddress{City: `a<b>"c" & 'd' ]]>`, Zip: `x>'"`}
+	tests := []struct {
+		escape EscapePolicy
+		want   string
+	}{
+		{EscapeAggressive, `<a zip="x&gt;&#39;&#34;"><city>a&lt;b>"c" &amp; 'd' ]]&gt;</city></a>`},
+		{EscapeMinimal, `<a zip="x>'&#34;"><city>a&lt;b>"c" &amp; 'd' ]]&gt;</city></a>`},
+	}
+	for _, tt := range tests {
+		opts := MarshalOptions{RootName: "a", Escape: tt.escape}
+		got, err := MarshalWithOptions(v, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("Marshal, policy %d:\ngot  %q\nwant %q", tt.escape, got, tt.want)
+		}
+
+		// Render writes what Marshal does.
+		node, err := Parse(tt.want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err = RenderWithOptions(node, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("Render, policy %d:\ngot  %q\nwant %q", tt.escape, got, tt.want)
+		}
+	}
+}
+
+func TestEncoder_SetOptions(t *testing.T) {
+	var out bytes.Buffer
+	enc := NewEncoder(&out)
+	enc.SetOptions(MarshalOptions{Declaration: true, Indent: "  ", EmptyElements: EmptyExpanded})
+	if err := enc.WriteStartElement("people"); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.EncodeElement(fmtAddress{City: "Cork"}, "address"); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.EncodeElement((*fmtAddress)(nil), "none"); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		"<people>\n  <address>\n    <city>Cork</city>\n  </address>\n  <none></none></people>"
+	if out.String() != want {
+		t.Errorf("got  %q\nwant %q", out.String(), want)
+	}
+
+	out.Reset()
+	enc = NewEncoder(&out)
+	enc.SetOptions(MarshalOptions{RootName: "addr"})
+	if err := enc.Encode(fmtAddress{City: "Cork"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<addr><city>Cork</city></addr>`; out.String() != want {
+		t.Errorf("got %s, want %s", out.String(), want)
+	}
+}
+
+func TestMarshalWithOptions_Declaration(t *testing.T) {
+	tests := []struct {
+		opts MarshalOptions
+		want string
+	}{
+		{MarshalOptions{Declaration: true}, `<?xml version="1.0" encoding="UTF-8"?>` + "\n<r/>"},
+		{MarshalOptions{Declaration: true, Encoding: "ISO-8859-1", Standalone: "yes"},
+			`<?xml version="1.0" encoding="ISO-8859-1" standalone="yes"?>` + "\n<r/>"},
+		{MarshalOptions{Declaration: true, Standalone: "no"}, `<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n<r/>"},
+		{MarshalOptions{Encoding: "ISO-8859-1"}, `<r/>`},
+	}
+	for _, tt := range tests {
+		tt.opts.RootName = "r"
+		got, err := MarshalWithOptions(nil, tt.opts)
+		if err != nil {
+			t.Fatalf("%+v: %v", tt.opts, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+
+	for _, opts := range []MarshalOptions{
+		{Encoding: "UTF 8"}, {Encoding: "8bit"}, {Standalone: "true"},
+	} {
+		if _, err := MarshalWithOptions(1, opts); err == nil {
+			t.Errorf("%+v: expected an error", opts)
+		}
+		if _, err := RenderWithOptions(nil, opts); err == nil {
+			t.Errorf("RenderWithOptions %+v: expected an error", opts)
+		}
+		if err := NewEncoder(&bytes.Buffer{}).SetOptions(opts); err == nil {
+			t.Errorf("SetOptions %+v: expected an error", opts)
+		}
+	}
+}
+
+func TestEncoder_WriteHeader(t *testing.T) {
+	var out bytes.Buffer
+	enc := NewEncoder(&out)
+	if err := enc.SetOptions(MarshalOptions{Encoding: "US-ASCII", Standalone: "yes"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteHeader(); err == nil {
+		t.Error("expected an error for a second header")
+	}
+	if err := enc.Encode(fmtAddress{City: "Cork"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="US-ASCII" standalone="yes"?>` + "\n<fmtAddress><city>Cork</city></fmtAddress>"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+
+	enc = NewEncoder(&bytes.Buffer{})
+	enc.WriteStartElement("a")
+	if err := enc.WriteHeader(); err == nil {
+		t.Error("expected an error for a header after an element")
+	}
+}
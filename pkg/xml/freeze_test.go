@@ -0,0 +1,120 @@
+package xml
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestElement_Freeze(t *testing.T) {
+	doc, err := ParseElement(`<order id="7"><item sku="a">1</item><item sku="b">2</item><note>n</note></order>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frozen := doc.Freeze()
+	if !frozen.Frozen() || doc.Frozen() || frozen.Freeze() != frozen {
+		t.Fatal("Frozen reports the wrong state")
+	}
+
+	// Later changes to the original do not show through.
+	doc.Attr("id", "8")
+	if id, _ := frozen.GetAttr("id"); id != "7" {
+		t.Errorf("snapshot id = %q, want 7", id)
+	}
+
+	// Setters are copy-on-write.
+	changed := frozen.Attr("id", "9").ChildText("extra", "x")
+	if changed == frozen || changed.Frozen() {
+		t.Fatal("setter on a frozen element did not return a mutable copy")
+	}
+	if id, _ := frozen.GetAttr("id"); id != "7" || frozen.Has("extra") {
+		t.Errorf("frozen element changed: %v", frozen.ToMap())
+	}
+	if id, _ := changed.GetAttr("id"); id != "9" || !changed.Has("extra") {
+		t.Errorf("copy did not change: %v", changed.ToMap())
+	}
+
+	// Children inherit the state, and changing them leaves the tree alone.
+	note, _ := frozen.GetChild("note")
+	if !note.Frozen() || note.Path() != "/order/note" {
+		t.Errorf("child: frozen %v, path %s", note.Frozen(), note.Path())
+	}
+	note.Text("changed")
+	if text, _ := note.GetText(); text != "n" {
+		t.Errorf("frozen child text = %q", text)
+	}
+	items := MustCompileQuery("item").Find(frozen)
+	if len(items) != 2 || !items[1].Frozen() {
+		t.Fatalf("query results: %v", items)
+	}
+	if detached := items[1].Detach(); detached.Frozen() || detached.Path() != "/item" {
+		t.Errorf("Detach of a frozen element: frozen %v, path %s", detached.Frozen(), detached.Path())
+	}
+	if len(MustCompileQuery("item").Find(frozen)) != 2 {
+		t.Error("Detach changed the frozen tree")
+	}
+	if err := items[0].ReplaceWith(NewElement()); err == nil {
+		t.Error("ReplaceWith on a frozen element succeeded")
+	}
+
+	// Maps handed out are copies.
+	frozen.ToMap()["@id"] = "10"
+	if m, _ := frozen.Get("note"); m != nil {
+		m.(map[string]interface{})["#text"] = "changed"
+	}
+	if id, _ := frozen.GetAttr("id"); id != "7" {
+		t.Errorf("ToMap exposed the frozen map")
+	}
+	if text, _ := note.GetText(); text != "n" {
+		t.Errorf("Get exposed a frozen child map")
+	}
+
+	// A frozen child added to a mutable tree is copied, not shared.
+	tree := NewElement().Child("note", note)
+	child, _ := tree.GetChild("note")
+	child.Text("mine")
+	if text, _ := note.GetText(); text != "n" {
+		t.Errorf("Child shared the frozen map")
+	}
+	other, _ := doc.GetChild("note")
+	if err := other.ReplaceWith(note); err != nil {
+		t.Fatal(err)
+	}
+	replaced, _ := doc.GetChild("note")
+	replaced.Text("again")
+	if text, _ := note.GetText(); text != "n" || note.Path() != "/order/note" {
+		t.Errorf("ReplaceWith changed the frozen element: %q %s", text, note.Path())
+	}
+}
+
+func TestElement_FreezeConcurrentReads(t *testing.T) {
+	doc, err := ParseElement(`<feed><entry id="1"><title>a</title></entry><entry id="2"><title>b</title></entry></feed>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := doc.Freeze()
+	q := MustCompileQuery("entry/title")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if got := q.Values(shared); len(got) != 2 {
+					t.Errorf("Values = %v", got)
+					return
+				}
+				if _, err := shared.XML("feed"); err != nil {
+					t.Error(err)
+					return
+				}
+				mine := shared.Attr("reader", "x").Remove("entry")
+				if shared.HasAttr("reader") || !shared.Has("entry") || mine.Has("entry") {
+					t.Error("copy-on-write changed the shared tree")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
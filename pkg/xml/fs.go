@@ -0,0 +1,134 @@
+package xml
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+// FileResult is the outcome for one file processed by ParseFS or ValidateFS.
+type FileResult struct {
+	// Path is the slash-separated path of the file within the file system.
+	Path string
+	// Node is the parsed AST; it is nil for ValidateFS and when Err is set.
+	Node ast.SchemaNode
+	// Err is the read, parse or validation error, nil on success.
+	Err error
+}
+
+// ParseFS parses every file in fsys whose path matches pattern and returns
+// one result per file, ordered by path. Files are parsed concurrently by a
+// bounded pool of GOMAXPROCS workers.
+//
+// Patterns use path.Match syntax. A pattern without '/' is matched against
+// file names at any depth, so "*.xml" selects every XML file in the tree;
+// a pattern with '/' is matched against the full path, as in "feeds/*.xml".
+//
+// Errors for individual files are reported in their FileResult. The returned
+// error is non-nil only if the pattern is malformed or the file system cannot
+// be walked.
+//
+// Example:
+//
+//	results, err := xml.ParseFS(os.DirFS("data"), "*.xml")
+//	if err != nil {
+//	    return err
+//	}
+//	for _, r := range results {
+//	    if r.Err != nil {
+//	        log.Printf("%s: %v", r.Path, r.Err)
+//	    }
+//	}
+func ParseFS(fsys fs.FS, pattern string) ([]FileResult, error) {
+	return processFS(fsys, pattern, func(data []byte) (ast.SchemaNode, error) {
+		return ParseBytes(data)
+	})
+}
+
+// ValidateFS checks every file in fsys whose path matches pattern for
+// well-formedness, like Validate, and returns one result per file, ordered
+// by path. Pattern matching and concurrency are as for ParseFS.
+//
+// Example:
+//
+//	results, err := xml.ValidateFS(os.DirFS("."), "*.xml")
+func ValidateFS(fsys fs.FS, pattern string) ([]FileResult, error) {
+	return processFS(fsys, pattern, func(data []byte) (ast.SchemaNode, error) {
+		return nil, ValidateBytes(data)
+	})
+}
+
+// processFS runs fn over the matching files of fsys with a bounded worker pool.
+func processFS(fsys fs.FS, pattern string, fn func([]byte) (ast.SchemaNode, error)) ([]FileResult, error) {
+	paths, err := matchFS(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]FileResult, len(paths))
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(paths) {
+		workers = len(paths)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				r := &results[i]
+				data, err := fs.ReadFile(fsys, r.Path)
+				if err != nil {
+					r.Err = err
+					continue
+				}
+				r.Node, r.Err = fn(data)
+			}
+		}()
+	}
+	for i, p := range paths {
+		results[i].Path = p
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results, nil
+}
+
+// matchFS returns the paths of the regular files in fsys matching pattern,
+// in lexical order.
+func matchFS(fsys fs.FS, pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("xml: invalid pattern %q: %w", pattern, err)
+	}
+	byName := !strings.Contains(pattern, "/")
+
+	var paths []string
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		subject := p
+		if byName {
+			subject = d.Name()
+		}
+		if ok, _ := path.Match(pattern, subject); ok {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("xml: walking file system: %w", err)
+	}
+	return paths, nil
+}
@@ -0,0 +1,93 @@
+package xml
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"a.xml":             {Data: []byte(`<a>1</a>`)},
+		"b.xml":             {Data: []byte(`<b><c></b>`)},
+		"notes.txt":         {Data: []byte(`not xml`)},
+		"feeds/one.xml":     {Data: []byte(`<feed/>`)},
+		"feeds/two.xml":     {Data: []byte(`<feed><entry/></feed>`)},
+		"feeds/old/bad.xml": {Data: []byte(`<feed>`)},
+	}
+}
+
+func TestValidateFS(t *testing.T) {
+	results, err := ValidateFS(testFS(), "*.xml")
+	if err != nil {
+		t.Fatalf("ValidateFS failed: %v", err)
+	}
+
+	var paths, failed []string
+	for _, r := range results {
+		paths = append(paths, r.Path)
+		if r.Node != nil {
+			t.Errorf("%s: ValidateFS returned a node", r.Path)
+		}
+		if r.Err != nil {
+			failed = append(failed, r.Path)
+		}
+	}
+	if got := strings.Join(paths, ","); got != "a.xml,b.xml,feeds/old/bad.xml,feeds/one.xml,feeds/two.xml" {
+		t.Errorf("paths = %s", got)
+	}
+	if got := strings.Join(failed, ","); got != "b.xml,feeds/old/bad.xml" {
+		t.Errorf("failed = %s", got)
+	}
+}
+
+func TestParseFS(t *testing.T) {
+	results, err := ParseFS(testFS(), "feeds/*.xml")
+	if err != nil {
+		t.Fatalf("ParseFS failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Err != nil || r.Node == nil {
+			t.Errorf("%s: node %v, err %v", r.Path, r.Node, r.Err)
+		}
+	}
+	if results[0].Path != "feeds/one.xml" {
+		t.Errorf("first path = %s", results[0].Path)
+	}
+}
+
+func TestParseFS_NoMatches(t *testing.T) {
+	results, err := ParseFS(testFS(), "*.json")
+	if err != nil || len(results) != 0 {
+		t.Errorf("got %v, %v", results, err)
+	}
+}
+
+func TestParseFS_BadPattern(t *testing.T) {
+	if _, err := ParseFS(testFS(), "[.xml"); err == nil {
+		t.Error("expected error for malformed pattern")
+	}
+}
+
+func TestValidateFS_ManyFiles(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for i := 0; i < 200; i++ {
+		name := "f" + strings.Repeat("x", i%7) + string(rune('a'+i%26)) + "/" + strings.Repeat("n", i) + ".xml"
+		fsys[name] = &fstest.MapFile{Data: []byte(`<r/>`)}
+	}
+	results, err := ValidateFS(fsys, "*.xml")
+	if err != nil {
+		t.Fatalf("ValidateFS failed: %v", err)
+	}
+	if len(results) != 200 {
+		t.Fatalf("expected 200 results, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("%s: %v", r.Path, r.Err)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package xml
+
+// WithHTML makes the parser read HTML snippets as well as XML, so that one
+// parser serves both configuration files and scraped pages. It implies
+// WithLenient, and also:
+//   - element and attribute names are lower-cased, so <TD> matches </td>
+//   - void elements such as <br>, <img>, <input> and <meta> have no content
+//     and no end tag
+//   - the content of <script> and <style> is kept as text up to their end
+//     tag, so "<" and "&" in it need no escaping
+//   - elements whose end tag HTML allows to leave out, such as <p>, <li>,
+//     <tr> and <td>, are closed by the start tags that end them (a <li>
+//     ends the previous <li>), by the end tag of an enclosing element and
+//     by the end of the input
+//
+// What HTML allows, such as leaving out those end tags or the quotes
+// around attribute values, is not reported by ParseLenient. Entities other
+// than the five predefined by XML, such as &nbsp;, are kept verbatim, and
+// the document must still have a single root element.
+//
+// Example:
+//
+//	page, _ := xml.ParseElementWithOptions(`<ul class=nav><li>Home<li>About</ul>`, xml.WithHTML())
+//	items := page.GetChildren("li") // two items
+func WithHTML() ParseOption {
+	return func(o *ParseOptions) {
+		o.HTML = true
+		o.Lenient = true
+	}
+}
@@ -0,0 +1,45 @@
+package xml
+
+import "testing"
+
+func TestWithHTML(t *testing.T) {
+	page, err := ParseElementWithOptions(`<UL class=nav><li>Home<li><a href="/about">About</a></ul>`, WithHTML())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Name() != "ul" {
+		t.Errorf("root %q, want ul", page.Name())
+	}
+	if class, _ := page.GetAttr("class"); class != "nav" {
+		t.Errorf("class = %q", class)
+	}
+	items := page.GetChildren("li")
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+	a, ok := items[1].GetChild("a")
+	if !ok {
+		t.Fatal("no link in second item")
+	}
+	if href, _ := a.GetAttr("href"); href != "/about" {
+		t.Errorf("href = %q", href)
+	}
+
+	// What HTML allows is not reported.
+	_, errs, err := ParseLenient(`<p>Q&A<br><input disabled value=1>`, WithHTML())
+	if err != nil || len(errs) != 0 {
+		t.Errorf("ParseLenient: %v, %v", errs, err)
+	}
+
+	var v interface{}
+	if err := UnmarshalWithOptions([]byte(`<p>a<br>b</p>`), &v, WithHTML()); err != nil {
+		t.Errorf("UnmarshalWithOptions: %v", err)
+	}
+
+	// Input ending inside a start tag is closed there.
+	for _, input := range []string{`<a`, `<a b`, `<br`, `<a><b`} {
+		if _, err := ParseWithOptions(input, WithHTML()); err != nil {
+			t.Errorf("ParseWithOptions(%q): %v", input, err)
+		}
+	}
+}
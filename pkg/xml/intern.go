@@ -0,0 +1,47 @@
+package xml
+
+import "github.com/shapestone/shape-xml/internal/intern"
+
+// InternPool deduplicates element and attribute names across documents.
+// Services parsing many documents with the same vocabulary can share one
+// pool, so that each name is allocated once instead of once per occurrence
+// and the maps they keep hold shared strings. A pool holds a bounded number
+// of names and is safe for concurrent use. Its methods are:
+//
+//	Intern(b []byte) string // the pooled string equal to b
+//	Len() int               // the number of names held
+type InternPool = intern.Pool
+
+// NewInternPool returns an InternPool holding at most maxNames names. Once
+// it is full, new names are allocated as if there were no pool, so a
+// document with an unbounded vocabulary cannot grow it without limit.
+//
+// Example:
+//
+//	var names = xml.NewInternPool(4096)
+//
+//	func decode(body []byte) (map[string]interface{}, error) {
+//	    var v map[string]interface{}
+//	    err := xml.UnmarshalWithOptions(body, &v, xml.WithInternPool(names))
+//	    return v, err
+//	}
+func NewInternPool(maxNames int) *InternPool {
+	return intern.New(maxNames)
+}
+
+// WithInternPool makes UnmarshalWithOptions and ParseElementWithOptions take
+// element names and attribute keys from pool. ParseWithOptions ignores it,
+// as the AST parser already shares names through shape-core. See also
+// Decoder.SetInternPool.
+func WithInternPool(pool *InternPool) ParseOption {
+	return func(o *ParseOptions) {
+		o.InternPool = pool
+	}
+}
+
+// SetInternPool makes the Decoder take element and attribute names from
+// pool, including for the values DecodeElement decodes. A nil pool turns
+// interning off.
+func (d *Decoder) SetInternPool(pool *InternPool) {
+	d.names = pool
+}
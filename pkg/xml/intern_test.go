@@ -0,0 +1,126 @@
+package xml
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// sameString reports whether a and b share their bytes.
+func sameString(a, b string) bool {
+	return a == b && unsafe.StringData(a) == unsafe.StringData(b)
+}
+
+// mapKey returns the key of m equal to key, so its storage can be compared.
+func mapKey(m map[string]interface{}, key string) string {
+	for k := range m {
+		if k == key {
+			return k
+		}
+	}
+	return ""
+}
+
+func TestWithInternPool_Unmarshal(t *testing.T) {
+	pool := NewInternPool(16)
+	var a, b map[string]interface{}
+	if err := UnmarshalWithOptions([]byte(`<r id="1"><item>x</item></r>`), &a, WithInternPool(pool)); err != nil {
+		t.Fatal(err)
+	}
+	if err := UnmarshalWithOptions([]byte(`<r id="2"><item>y</item></r>`), &b, WithInternPool(pool)); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"@id", "item"} {
+		if !sameString(mapKey(a, key), mapKey(b, key)) {
+			t.Errorf("key %q not shared between documents", key)
+		}
+	}
+	if item, _ := b["item"].(map[string]interface{}); b["@id"] != "2" || item["#text"] != "y" {
+		t.Errorf("unexpected result %v", b)
+	}
+
+	// Struct targets and other options go through the same parser.
+	var s struct {
+		ID   int    `xml:"id,attr"`
+		Item string `xml:"item"`
+	}
+	if err := UnmarshalWithOptions([]byte(`<r id="3"><item>z</item></r>`), &s, WithInternPool(pool), WithUseNumber()); err != nil || s.ID != 3 || s.Item != "z" {
+		t.Errorf("struct: %v %+v", err, s)
+	}
+
+	e, err := ParseElementWithOptions(`<r id="4"/>`, WithInternPool(pool))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameString(mapKey(e.data, "@id"), mapKey(a, "@id")) {
+		t.Error("ParseElementWithOptions did not use the pool")
+	}
+}
+
+func TestWithInternPool_Bounded(t *testing.T) {
+	pool := NewInternPool(3)
+	var v map[string]interface{}
+	doc := `<r a="1" b="2" c="3" d="4"><e/><f/></r>`
+	if err := UnmarshalWithOptions([]byte(doc), &v, WithInternPool(pool)); err != nil {
+		t.Fatal(err)
+	}
+	if pool.Len() != 3 {
+		t.Errorf("Len = %d, want 3", pool.Len())
+	}
+	if len(v) != 6 {
+		t.Errorf("names lost once the pool was full: %v", v)
+	}
+}
+
+func TestDecoder_SetInternPool(t *testing.T) {
+	pool := NewInternPool(16)
+	names := func(doc string) []string {
+		d := NewDecoder(strings.NewReader(doc))
+		d.SetInternPool(pool)
+		var out []string
+		for {
+			tok, err := d.Token()
+			if err != nil {
+				return out
+			}
+			if se, ok := tok.(StartElement); ok {
+				out = append(out, se.Name)
+				for _, a := range se.Attr {
+					out = append(out, a.Name)
+				}
+			}
+		}
+	}
+	first := names(`<r><item id="1"/></r>`)
+	second := names(`<r><item id="2"/></r>`)
+	if len(first) != 3 || len(second) != 3 {
+		t.Fatalf("unexpected tokens %v %v", first, second)
+	}
+	for i := range first {
+		if !sameString(first[i], second[i]) {
+			t.Errorf("name %q not shared between documents", first[i])
+		}
+	}
+
+	d := NewDecoder(strings.NewReader(`<r><item id="5"><v>x</v></item></r>`))
+	d.SetInternPool(pool)
+	var item struct {
+		ID int    `xml:"id,attr"`
+		V  string `xml:"v"`
+	}
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if se, ok := tok.(StartElement); ok && se.Name == "item" {
+			if err := d.DecodeElement(&item, &se); err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+	}
+	if item.ID != 5 || item.V != "x" {
+		t.Errorf("DecodeElement = %+v", item)
+	}
+}
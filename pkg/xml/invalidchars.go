@@ -0,0 +1,55 @@
+package xml
+
+import "github.com/shapestone/shape-xml/internal/charset"
+
+// InvalidChars selects what the parsers do with characters that XML does
+// not allow: the control characters other than tab, line feed and carriage
+// return, U+FFFE and U+FFFF, and bytes that are not UTF-8.
+type InvalidChars int
+
+const (
+	// InvalidCharsError rejects the document with a *SyntaxError locating
+	// the first such character. This is the default.
+	InvalidCharsError InvalidChars = iota
+	// InvalidCharsStrip removes them.
+	InvalidCharsStrip
+	// InvalidCharsReplace replaces each of them with U+FFFD.
+	InvalidCharsReplace
+)
+
+// WithInvalidChars selects what ParseWithOptions, ParseElementWithOptions,
+// UnmarshalWithOptions, ParseLenient and Stream do with characters that
+// XML does not allow, such as those left in text by legacy systems. By
+// default they are an error, as in every other function. Character
+// references to them, such as &#1;, are handled in the same way: an error,
+// removed, or decoded as U+FFFD.
+//
+// Stripping or replacing characters written literally happens before
+// parsing, so positions refer to the cleaned document.
+//
+// Example:
+//
+//	err := xml.UnmarshalWithOptions(data, &v, xml.WithInvalidChars(xml.InvalidCharsReplace))
+func WithInvalidChars(mode InvalidChars) ParseOption {
+	return func(o *ParseOptions) {
+		o.InvalidChars = mode
+	}
+}
+
+// SetInvalidChars selects what the Decoder does with characters that XML
+// does not allow, and references to them, see WithInvalidChars. It has no
+// effect once reading has started.
+func (d *Decoder) SetInvalidChars(mode InvalidChars) {
+	d.charset.Mode = charset.Mode(mode)
+}
+
+// clean returns data with the characters XML does not allow handled as
+// o.InvalidChars says.
+func (o *ParseOptions) clean(data []byte) []byte {
+	return charset.Clean(data, charset.Mode(o.InvalidChars))
+}
+
+// cleanString is clean for a string.
+func (o *ParseOptions) cleanString(s string) string {
+	return charset.CleanString(s, charset.Mode(o.InvalidChars))
+}
@@ -0,0 +1,280 @@
+package xml
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const controlDoc = "<a x=\"1\x01\">\n<b>bell\x07</b>\xEF\xBF\xBF</a>"
+
+func TestInvalidChars_Rejected(t *testing.T) {
+	checks := map[string]func() error{
+		"Parse":       func() error { _, err := Parse(controlDoc); return err },
+		"ParseBytes":  func() error { _, err := ParseBytes([]byte(controlDoc)); return err },
+		"ParseReader": func() error { _, err := ParseReader(strings.NewReader(controlDoc)); return err },
+		"Validate":    func() error { return Validate(controlDoc) },
+		"ValidateReader": func() error {
+			return ValidateReader(strings.NewReader(controlDoc))
+		},
+		"Unmarshal": func() error {
+			var v interface{}
+			return Unmarshal([]byte(controlDoc), &v)
+		},
+		"ParseElementWithOptions": func() error {
+			_, err := ParseElementWithOptions(controlDoc, WithPreserveOrder())
+			return err
+		},
+		"ParseLenient": func() error { _, _, err := ParseLenient(controlDoc); return err },
+	}
+	for name, check := range checks {
+		err := check()
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%s: got %v, want a *SyntaxError", name, err)
+			continue
+		}
+		if syntaxErr.Line != 1 || syntaxErr.Column != 8 || syntaxErr.Err.Error() != "illegal character U+0001" {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
+
+func TestInvalidChars_StripAndReplace(t *testing.T) {
+	tests := []struct {
+		mode      InvalidChars
+		attr, txt string
+	}{
+		{InvalidCharsStrip, "1", "bell"},
+		{InvalidCharsReplace, "1�", "bell�"},
+	}
+	for _, tt := range tests {
+		var v map[string]interface{}
+		if err := UnmarshalWithOptions([]byte(controlDoc), &v, WithInvalidChars(tt.mode)); err != nil {
+			t.Errorf("UnmarshalWithOptions(%d) failed: %v", tt.mode, err)
+			continue
+		}
+		want := map[string]interface{}{"@x": tt.attr, "b": map[string]interface{}{"#text": tt.txt}}
+		if tt.mode == InvalidCharsReplace {
+			want["#text"] = "�"
+		}
+		if !reflect.DeepEqual(v, want) {
+			t.Errorf("UnmarshalWithOptions(%d) = %#v, want %#v", tt.mode, v, want)
+		}
+
+		e, err := ParseElementWithOptions(controlDoc, WithInvalidChars(tt.mode), WithComments())
+		if err != nil {
+			t.Errorf("ParseElementWithOptions(%d) failed: %v", tt.mode, err)
+		} else if x, _ := e.GetAttr("x"); x != tt.attr {
+			t.Errorf("ParseElementWithOptions(%d): x = %q, want %q", tt.mode, x, tt.attr)
+		}
+
+		if _, err := ParseWithOptions(controlDoc, WithInvalidChars(tt.mode)); err != nil {
+			t.Errorf("ParseWithOptions(%d) failed: %v", tt.mode, err)
+		}
+	}
+}
+
+const refDoc = "<a x=\"1&#1;\">\n<b>A&#x41;&#xFFFF;</b></a>"
+
+func TestInvalidChars_CharacterReference(t *testing.T) {
+	checks := map[string]func() error{
+		"Parse":       func() error { _, err := Parse(refDoc); return err },
+		"ParseBytes":  func() error { _, err := ParseBytes([]byte(refDoc)); return err },
+		"ParseReader": func() error { _, err := ParseReader(strings.NewReader(refDoc)); return err },
+		"Validate":    func() error { return Validate(refDoc) },
+		"Unmarshal": func() error {
+			var v interface{}
+			return Unmarshal([]byte(refDoc), &v)
+		},
+		"Unmarshal struct": func() error {
+			var v struct {
+				X string `xml:"x,attr"`
+				B string `xml:"b"`
+			}
+			return Unmarshal([]byte(refDoc), &v)
+		},
+		"ParseElementWithOptions": func() error {
+			_, err := ParseElementWithOptions(refDoc, WithPreserveOrder())
+			return err
+		},
+		"Decoder": func() error {
+			d := NewDecoder(strings.NewReader(refDoc))
+			for {
+				if _, err := d.Token(); err != nil {
+					return err
+				}
+			}
+		},
+	}
+	for name, check := range checks {
+		err := check()
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%s: got %v, want a *SyntaxError", name, err)
+			continue
+		}
+		if syntaxErr.Line != 1 || syntaxErr.Column != 8 || !strings.HasSuffix(syntaxErr.Err.Error(), "reference &#1; to illegal character U+0001") {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+
+	_, errs, err := ParseLenient(refDoc)
+	if err != nil || len(errs) != 2 {
+		t.Errorf("ParseLenient: got %v, %v, want two recorded errors", errs, err)
+	}
+
+	tests := []struct {
+		mode      InvalidChars
+		attr, txt string
+	}{
+		{InvalidCharsStrip, "1", "AA"},
+		{InvalidCharsReplace, "1�", "AA�"},
+	}
+	for _, tt := range tests {
+		var v map[string]interface{}
+		if err := UnmarshalWithOptions([]byte(refDoc), &v, WithInvalidChars(tt.mode)); err != nil {
+			t.Errorf("UnmarshalWithOptions(%d) failed: %v", tt.mode, err)
+			continue
+		}
+		want := map[string]interface{}{"@x": tt.attr, "b": map[string]interface{}{"#text": tt.txt}}
+		if !reflect.DeepEqual(v, want) {
+			t.Errorf("UnmarshalWithOptions(%d) = %#v, want %#v", tt.mode, v, want)
+		}
+
+		e, err := ParseElementWithOptions(refDoc, WithInvalidChars(tt.mode), WithComments())
+		if err != nil {
+			t.Errorf("ParseElementWithOptions(%d) failed: %v", tt.mode, err)
+		} else if x, _ := e.GetAttr("x"); x != tt.attr {
+			t.Errorf("ParseElementWithOptions(%d): x = %q, want %q", tt.mode, x, tt.attr)
+		}
+
+		d := NewDecoder(strings.NewReader(refDoc))
+		d.SetInvalidChars(tt.mode)
+		var tokens []Token
+		for tok, err := d.Token(); err == nil; tok, err = d.Token() {
+			tokens = append(tokens, tok)
+		}
+		wantTokens := []Token{StartElement{Name: "a", Attr: []Attr{{Name: "x", Value: tt.attr}}}, CharData("\n"),
+			StartElement{Name: "b"}, CharData(tt.txt), EndElement{Name: "b"}, EndElement{Name: "a"}}
+		if !reflect.DeepEqual(tokens, wantTokens) {
+			t.Errorf("Decoder(%d) = %#v, want %#v", tt.mode, tokens, wantTokens)
+		}
+
+		// Render writes what was decoded, not the escaped reference.
+		node, err := ParseWithOptions(refDoc, WithInvalidChars(tt.mode))
+		if err != nil {
+			t.Fatalf("ParseWithOptions(%d) failed: %v", tt.mode, err)
+		}
+		out, err := Render(node)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(out), "&amp;#") {
+			t.Errorf("Render(%d) = %s", tt.mode, out)
+		}
+	}
+}
+
+func TestInvalidChars_Decoder(t *testing.T) {
+	d := NewDecoder(strings.NewReader("<a>\nok\x1B</a>"))
+	if _, err := d.Token(); err != nil {
+		t.Fatalf("Token failed: %v", err)
+	}
+	_, err := d.Token()
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Line != 2 || syntaxErr.Column != 3 {
+		t.Fatalf("got %v, want an error at line 2, column 3", err)
+	}
+
+	d = NewDecoder(strings.NewReader("<a>\nok\x1B</a>"))
+	d.SetInvalidChars(InvalidCharsStrip)
+	var tokens []Token
+	for tok, err := d.Token(); err == nil; tok, err = d.Token() {
+		tokens = append(tokens, tok)
+	}
+	want := []Token{StartElement{Name: "a"}, CharData("\nok"), EndElement{Name: "a"}}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Errorf("got %#v, want %#v", tokens, want)
+	}
+}
+
+// badUTF8Doc holds bytes that are not UTF-8: 0xFF and a Latin-1 é.
+const badUTF8Doc = "<a x=\"1\xFF\">\n<b>caf\xE9</b></a>"
+
+func TestInvalidChars_BadUTF8(t *testing.T) {
+	type doc struct {
+		X string `xml:"x,attr"`
+		B string `xml:"b"`
+	}
+	drain := func(d *Decoder) error {
+		var err error
+		for err == nil {
+			_, err = d.Token()
+		}
+		return err
+	}
+	checks := map[string]func() error{
+		"Parse":       func() error { _, err := Parse(badUTF8Doc); return err },
+		"ParseBytes":  func() error { _, err := ParseBytes([]byte(badUTF8Doc)); return err },
+		"ParseReader": func() error { _, err := ParseReader(strings.NewReader(badUTF8Doc)); return err },
+		"Validate":    func() error { return Validate(badUTF8Doc) },
+		"Unmarshal": func() error {
+			var v interface{}
+			return Unmarshal([]byte(badUTF8Doc), &v)
+		},
+		"Unmarshal struct": func() error {
+			var v doc
+			return Unmarshal([]byte(badUTF8Doc), &v)
+		},
+		"Decoder": func() error { return drain(NewDecoder(strings.NewReader(badUTF8Doc))) },
+		"ParseEvents": func() error {
+			return ParseEvents(strings.NewReader(badUTF8Doc), EventFuncs{})
+		},
+		"Stream": func() error {
+			return Stream(strings.NewReader(badUTF8Doc)).Each("b", func(*Element) error { return nil })
+		},
+	}
+	for name, check := range checks {
+		err := check()
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%s: got %v, want a *SyntaxError", name, err)
+			continue
+		}
+		if syntaxErr.Line != 1 || syntaxErr.Column != 8 || syntaxErr.Err.Error() != "invalid UTF-8" {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+
+	want := doc{X: "1\uFFFD", B: "caf\uFFFD"}
+	var v doc
+	if err := UnmarshalWithOptions([]byte(badUTF8Doc), &v, WithInvalidChars(InvalidCharsReplace)); err != nil || v != want {
+		t.Errorf("UnmarshalWithOptions = %q, %v; want %q", v, err, want)
+	}
+	e, err := ParseElementWithOptions(badUTF8Doc, WithInvalidChars(InvalidCharsReplace))
+	if err != nil {
+		t.Fatalf("ParseElementWithOptions failed: %v", err)
+	}
+	if x, _ := e.GetAttr("x"); x != want.X {
+		t.Errorf("ParseElementWithOptions: x = %q, want %q", x, want.X)
+	}
+
+	d := NewDecoder(strings.NewReader(badUTF8Doc))
+	d.SetInvalidChars(InvalidCharsReplace)
+	v = doc{}
+	if err := d.DecodeElement(&v, nil); err != nil || v != want {
+		t.Errorf("Decoder = %q, %v; want %q", v, err, want)
+	}
+
+	var texts []string
+	err = Stream(strings.NewReader(badUTF8Doc), WithInvalidChars(InvalidCharsStrip)).Each("b", func(e *Element) error {
+		text, _ := e.GetText()
+		texts = append(texts, text)
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(texts, []string{"caf"}) {
+		t.Errorf("Stream = %q, %v", texts, err)
+	}
+}
@@ -0,0 +1,314 @@
+package xml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+// JSONAttrStyle selects how ToJSON and FromJSON represent attributes.
+type JSONAttrStyle int
+
+const (
+	// JSONAttrsPrefixed writes attributes as "@name" keys next to the
+	// child elements, as NodeToInterface does. This is the default.
+	JSONAttrsPrefixed JSONAttrStyle = iota
+	// JSONAttrsNested collects the attributes of an element in an
+	// "_attrs" object keyed by attribute name.
+	JSONAttrsNested
+)
+
+// JSONArrayStyle selects when ToJSON writes child elements as JSON arrays.
+type JSONArrayStyle int
+
+const (
+	// JSONArraysRepeated writes repeated child elements as an array and
+	// single ones as a value. This is the default.
+	JSONArraysRepeated JSONArrayStyle = iota
+	// JSONArraysAlways writes every child element as an array, so that
+	// consumers see the same shape however many occurrences there are.
+	JSONArraysAlways
+)
+
+// jsonAttrsKey is the key of the attribute object in the JSONAttrsNested
+// style.
+const jsonAttrsKey = "_attrs"
+
+// JSONOptions configures ToJSON, NodeToJSON and FromJSON, and the YAML
+// conversions built on them. The zero value uses the map conventions of
+// NodeToInterface: "@name" attributes, "#text" and "#cdata" content, and
+// arrays for repeated elements.
+//
+// FromJSON accepts both shapes ToJSON can produce for text and arrays
+// whatever SimpleText and Arrays say, so only Attrs, TextKey and
+// EscapeNames need to match between the two directions.
+type JSONOptions struct {
+	// Attrs selects how attributes are represented.
+	Attrs JSONAttrStyle
+
+	// TextKey replaces "#text" as the key of an element's text.
+	TextKey string
+
+	// SimpleText writes elements holding only text, or nothing, as JSON
+	// strings: {"name":"Alice"} instead of {"name":{"#text":"Alice"}}.
+	SimpleText bool
+
+	// Arrays selects when child elements are written as arrays.
+	Arrays JSONArrayStyle
+
+	// EscapeNames makes FromJSON turn keys that are not valid element
+	// names, such as "0" or "first name", into names with
+	// DefaultNameEscaper, and ToJSON turn them back. Without it, such keys
+	// are an error.
+	EscapeNames bool
+
+	// Indent, if set, indents the JSON written by ToJSON and the XML
+	// written by FromJSON and FromYAML, one Indent per level of nesting.
+	Indent string
+}
+
+func (o *JSONOptions) textKey() string {
+	if o.TextKey == "" {
+		return "#text"
+	}
+	return o.TextKey
+}
+
+// ToJSON converts an XML document into JSON with the conventions set by
+// opts. The root element becomes the top-level value, without its name,
+// and object keys are sorted.
+//
+// Example:
+//
+//	out, _ := xml.ToJSON([]byte(`<user id="1"><name>Alice</name></user>`),
+//	    xml.JSONOptions{SimpleText: true})
+//	// out: {"@id":"1","name":"Alice"}
+func ToJSON(input []byte, opts JSONOptions) ([]byte, error) {
+	node, err := ParseBytes(input)
+	if err != nil {
+		return nil, err
+	}
+	return NodeToJSON(node, opts)
+}
+
+// NodeToJSON converts a parsed document into JSON like ToJSON.
+func NodeToJSON(node ast.SchemaNode, opts JSONOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// Text taken from XML often holds markup; keep it readable.
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", opts.Indent)
+	if err := enc.Encode(opts.toJSON(NodeToInterface(node))); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// toJSON converts the content of an element, as returned by
+// NodeToInterface.
+func (o *JSONOptions) toJSON(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	out := make(map[string]interface{}, len(m))
+	var attrs map[string]interface{}
+	for key, value := range m {
+		switch {
+		case strings.HasPrefix(key, "@") && o.Attrs == JSONAttrsNested:
+			if attrs == nil {
+				attrs = make(map[string]interface{})
+			}
+			attrs[key[1:]] = value
+		case strings.HasPrefix(key, "@"):
+			out[key] = value
+		case key == "#text":
+			out[o.textKey()] = value
+		case strings.HasPrefix(key, "#"):
+			out[key] = value
+		default:
+			if o.EscapeNames {
+				key = UnescapeName(key)
+			}
+			out[key] = o.childToJSON(value)
+		}
+	}
+	if attrs != nil {
+		out[jsonAttrsKey] = attrs
+	}
+	if o.SimpleText {
+		if len(out) == 0 {
+			return ""
+		}
+		if text, ok := out[o.textKey()]; ok && len(out) == 1 {
+			return text
+		}
+	}
+	return out
+}
+
+func (o *JSONOptions) childToJSON(v interface{}) interface{} {
+	if items, ok := v.([]interface{}); ok {
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			out[i] = o.toJSON(item)
+		}
+		return out
+	}
+	if o.Arrays == JSONArraysAlways {
+		return []interface{}{o.toJSON(v)}
+	}
+	return o.toJSON(v)
+}
+
+// FromJSON converts a JSON value into an XML document whose root element is
+// named rootName, reading attributes, text and names with the conventions
+// set by opts. A JSON array becomes repeated elements, a string, number or
+// boolean the text of an element, and null an empty element. Numbers keep
+// their JSON spelling. Child elements are written sorted by name.
+//
+// Example:
+//
+//	out, _ := xml.FromJSON([]byte(`{"@id":"1","name":"Alice","tag":["a","b"]}`),
+//	    "user", xml.JSONOptions{})
+//	// out: <user id="1"><name>Alice</name><tag>a</tag><tag>b</tag></user>
+func FromJSON(data []byte, rootName string, opts JSONOptions) ([]byte, error) {
+	if !isValidXMLName(rootName) {
+		return nil, fmt.Errorf("xml: invalid root element name %q", rootName)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("xml: invalid JSON: %w", err)
+	}
+	if _, err := dec.Token(); err == nil {
+		return nil, fmt.Errorf("xml: invalid JSON: data after the top-level value")
+	}
+	return opts.document(v, rootName, opts.Indent)
+}
+
+// document renders the decoded JSON or YAML value v as a document whose
+// root element is named rootName.
+func (o *JSONOptions) document(v interface{}, rootName, indent string) ([]byte, error) {
+	if _, ok := v.([]interface{}); ok {
+		return nil, fmt.Errorf("xml: an array cannot be the root element %q", rootName)
+	}
+	root, err := o.fromJSON(v, rootName)
+	if err != nil {
+		return nil, err
+	}
+	node, err := InterfaceToNode(root)
+	if err != nil {
+		return nil, err
+	}
+	return RenderWithOptions(node, MarshalOptions{RootName: rootName, Indent: indent})
+}
+
+// fromJSON converts the JSON value of the element named name into the map
+// form InterfaceToNode reads.
+func (o *JSONOptions) fromJSON(v interface{}, name string) (map[string]interface{}, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		if v == nil {
+			return map[string]interface{}{}, nil
+		}
+		text, err := jsonText(v, name)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"#text": text}, nil
+	}
+
+	m := make(map[string]interface{}, len(obj))
+	for key, value := range obj {
+		switch {
+		case key == o.textKey() || key == "#cdata":
+			text, err := jsonText(value, key)
+			if err != nil {
+				return nil, err
+			}
+			if key == "#cdata" {
+				m["#cdata"] = text
+			} else {
+				m["#text"] = text
+			}
+		case key == jsonAttrsKey && o.Attrs == JSONAttrsNested:
+			attrs, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("xml: key %q of element %q must hold an object", key, name)
+			}
+			for attr, value := range attrs {
+				if err := o.setAttr(m, attr, value); err != nil {
+					return nil, err
+				}
+			}
+		case strings.HasPrefix(key, "@") && o.Attrs == JSONAttrsPrefixed:
+			if err := o.setAttr(m, key[1:], value); err != nil {
+				return nil, err
+			}
+		default:
+			child := key
+			if o.EscapeNames {
+				child = EscapeName(key)
+			} else if !isValidXMLName(key) {
+				return nil, fmt.Errorf("xml: key %q is not a valid element name", key)
+			}
+			items, repeated := value.([]interface{})
+			if !repeated {
+				c, err := o.fromJSON(value, key)
+				if err != nil {
+					return nil, err
+				}
+				m[child] = c
+				continue
+			}
+			list := make([]interface{}, 0, len(items))
+			for _, item := range items {
+				if _, nested := item.([]interface{}); nested {
+					return nil, fmt.Errorf("xml: key %q holds nested arrays", key)
+				}
+				c, err := o.fromJSON(item, key)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, c)
+			}
+			if len(list) > 0 {
+				m[child] = list
+			}
+		}
+	}
+	return m, nil
+}
+
+func (o *JSONOptions) setAttr(m map[string]interface{}, name string, value interface{}) error {
+	if !isValidXMLName(name) {
+		return fmt.Errorf("xml: attribute %q is not a valid attribute name", name)
+	}
+	text, err := jsonText(value, "@"+name)
+	if err != nil {
+		return err
+	}
+	m["@"+name] = text
+	return nil
+}
+
+// jsonText returns a JSON scalar as text.
+func jsonText(v interface{}, key string) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("xml: key %q must hold a string, number or boolean", key)
+}
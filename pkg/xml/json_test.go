@@ -0,0 +1,130 @@
+package xml
+
+import (
+	"strings"
+	"testing"
+)
+
+const jsonTestXML = `<order id="7"><item sku="a">Pen</item><item sku="b">Ink</item><note>fragile</note><empty/><raw><![CDATA[<b>]]></raw></order>`
+
+func TestToJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		opts JSONOptions
+		want string
+	}{
+		{"default", JSONOptions{},
+			`{"@id":"7","empty":{},"item":[{"#text":"Pen","@sku":"a"},{"#text":"Ink","@sku":"b"}],"note":{"#text":"fragile"},"raw":{"#cdata":"<b>"}}`},
+		{"nested attributes", JSONOptions{Attrs: JSONAttrsNested, TextKey: "value"},
+			`{"_attrs":{"id":"7"},"empty":{},"item":[{"_attrs":{"sku":"a"},"value":"Pen"},{"_attrs":{"sku":"b"},"value":"Ink"}],"note":{"value":"fragile"},"raw":{"#cdata":"<b>"}}`},
+		{"simple text", JSONOptions{SimpleText: true},
+			`{"@id":"7","empty":"","item":[{"#text":"Pen","@sku":"a"},{"#text":"Ink","@sku":"b"}],"note":"fragile","raw":{"#cdata":"<b>"}}`},
+		{"arrays always", JSONOptions{SimpleText: true, Arrays: JSONArraysAlways},
+			`{"@id":"7","empty":[""],"item":[{"#text":"Pen","@sku":"a"},{"#text":"Ink","@sku":"b"}],"note":["fragile"],"raw":[{"#cdata":"<b>"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToJSON([]byte(jsonTestXML), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	got, err := ToJSON([]byte(`<r><a>1</a></r>`), JSONOptions{Indent: "  "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\n  \"a\": {\n    \"#text\": \"1\"\n  }\n}"; string(got) != want {
+		t.Errorf("indented: got\n%s\nwant\n%s", got, want)
+	}
+	if _, err := ToJSON([]byte(`<r>`), JSONOptions{}); err == nil {
+		t.Error("ToJSON of malformed XML: expected an error")
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  JSONOptions
+		want  string
+	}{
+		{"prefixed", `{"@id":7,"item":[{"@sku":"a","#text":"Pen"},"Ink"],"note":"a<b","ok":true,"none":null,"zero":[]}`, JSONOptions{},
+			`<order id="7"><item sku="a">Pen</item><item>Ink</item><none/><note>a&lt;b</note><ok>true</ok></order>`},
+		{"nested", `{"_attrs":{"id":"7"},"item":{"_attrs":{"sku":"a"},"value":"Pen"},"@x":"child"}`, JSONOptions{Attrs: JSONAttrsNested, TextKey: "value"},
+			"",
+		},
+		{"scalar root", `1.50`, JSONOptions{}, `<order>1.50</order>`},
+		{"cdata", `{"#cdata":"<b>"}`, JSONOptions{}, `<order><![CDATA[<b>]]></order>`},
+		{"escaped names", `{"first name":"A","0":"zero"}`, JSONOptions{EscapeNames: true},
+			`<order><_0>zero</_0><first_x0020_name>A</first_x0020_name></order>`},
+		{"indent", `{"a":{"b":"1"}}`, JSONOptions{Indent: "  "}, "<order>\n  <a>\n    <b>1</b>\n  </a>\n</order>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromJSON([]byte(tt.input), "order", tt.opts)
+			if tt.want == "" {
+				// "@x" is not an attribute in the nested style, and not a
+				// valid element name either.
+				if err == nil || !strings.Contains(err.Error(), `"@x" is not a valid element name`) {
+					t.Errorf("got %s, %v", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		input string
+		root  string
+		want  string
+	}{
+		{`{}`, "1root", `invalid root element name "1root"`},
+		{`{"a":`, "r", "invalid JSON"},
+		{`{} {}`, "r", "data after the top-level value"},
+		{`[1]`, "r", "an array cannot be the root element"},
+		{`{"a b":1}`, "r", `"a b" is not a valid element name`},
+		{`{"@a b":1}`, "r", `attribute "a b" is not a valid attribute name`},
+		{`{"#text":{}}`, "r", `"#text" must hold a string, number or boolean`},
+		{`{"a":[[1]]}`, "r", "nested arrays"},
+	} {
+		if _, err := FromJSON([]byte(tt.input), tt.root, JSONOptions{}); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("FromJSON(%s) = %v, want an error containing %q", tt.input, err, tt.want)
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, opts := range []JSONOptions{
+		{},
+		{Attrs: JSONAttrsNested, TextKey: "$", SimpleText: true},
+		{SimpleText: true, Arrays: JSONArraysAlways},
+	} {
+		j, err := ToJSON([]byte(jsonTestXML), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x, err := FromJSON(j, "order", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, _ := Parse(jsonTestXML)
+		b, err := Parse(string(x))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Equal(a, b) {
+			t.Errorf("%+v: round trip changed the document:\n%s\n%s", opts, jsonTestXML, x)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package xml
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/shapestone/shape-xml/internal/fastparser"
+)
+
+// buildXMLKeyedMapEncoder encodes a map field tagged key=attr as one
+// element per entry, in key order, with the map key written as attribute
+// attr of the entry's start tag. If the value type already has an attribute
+// field named attr, that field is written instead.
+func buildXMLKeyedMapEncoder(t reflect.Type, attr string) xmlEncoderFunc {
+	var less func(a, b reflect.Value) bool
+	switch t.Key().Kind() {
+	case reflect.String:
+		less = func(a, b reflect.Value) bool { return a.String() < b.String() }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		less = func(a, b reflect.Value) bool { return a.Int() < b.Int() }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		less = func(a, b reflect.Value) bool { return a.Uint() < b.Uint() }
+	default:
+		return xmlTagErrorEnc(fmt.Errorf("xml: unsupported map key type %s for key=%s", t.Key(), attr))
+	}
+	elemEnc := nestedEncoderForType(t.Elem())
+	inject := !hasAttrField(t.Elem(), attr)
+
+	return func(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+
+		for _, key := range keys {
+			start := len(buf)
+			// The key is inserted into the start tag afterwards, so the
+			// element must stay in buf.
+			es.holdFlush++
+			var err error
+			buf, err = elemEnc(es, buf, rv.MapIndex(key), elemName)
+			es.holdFlush--
+			if err != nil {
+				return buf, err
+			}
+			if inject {
+				var markup []byte
+				markup = append(markup, ' ')
+				markup = append(markup, attr...)
+				markup = append(markup, '=', '"')
+				markup = appendEscapeAttr(markup, formatMapKey(key), &es.opts)
+				markup = append(markup, '"')
+				buf = insertStartTagAttrs(buf, start, es.writtenName(start, elemName), markup)
+			}
+			if buf, err = es.maybeFlush(buf); err != nil {
+				return buf, err
+			}
+		}
+		return buf, nil
+	}
+}
+
+// formatMapKey formats a string or integer map key.
+func formatMapKey(key reflect.Value) string {
+	switch key.Kind() {
+	case reflect.String:
+		return key.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10)
+	default:
+		return strconv.FormatUint(key.Uint(), 10)
+	}
+}
+
+// hasAttrField reports whether struct type t, or the struct t points to,
+// has an attribute field named name.
+func hasAttrField(t reflect.Type, name string) bool {
+	t = derefType(t)
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for _, field := range fastparser.VisibleFields(t) {
+		if info := getFieldInfo(field); info.attr && !info.skip && info.name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package xml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type keyedPlugin struct {
+	Path    string `xml:"path"`
+	Enabled bool   `xml:"enabled,attr"`
+}
+
+type keyedConfig struct {
+	Plugins map[string]keyedPlugin `xml:"plugin,key=name"`
+}
+
+func TestKeyedMap_RoundTrip(t *testing.T) {
+	cfg := keyedConfig{Plugins: map[string]keyedPlugin{
+		"cache": {Path: "/lib/cache.so"},
+		"auth":  {Path: "/lib/a&b.so", Enabled: true},
+	}}
+	data, err := Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `<keyedConfig><plugin name="auth" enabled="true"><path>/lib/a&amp;b.so</path></plugin>` +
+		`<plugin name="cache" enabled="false"><path>/lib/cache.so</path></plugin></keyedConfig>`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+
+	var got keyedConfig
+	if err := Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, cfg) {
+		t.Errorf("got %+v, want %+v", got, cfg)
+	}
+}
+
+func TestKeyedMap_Unmarshal(t *testing.T) {
+	input := `<config>
+  <plugin name="auth" enabled="true"><path>/a.so</path></plugin>
+  <plugin name="auth"><path>/b.so</path></plugin>
+  <plugin name="log"/>
+</config>`
+	var cfg keyedConfig
+	if err := Unmarshal([]byte(input), &cfg); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	want := map[string]keyedPlugin{
+		"auth": {Path: "/b.so", Enabled: true}, // merged
+		"log":  {},
+	}
+	if !reflect.DeepEqual(cfg.Plugins, want) {
+		t.Errorf("got %+v, want %+v", cfg.Plugins, want)
+	}
+
+	// A single element and integer keys.
+	var ports struct {
+		Ports map[int]string `xml:"port,key=n"`
+	}
+	if err := Unmarshal([]byte(`<s><port n="80">http</port></s>`), &ports); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(ports.Ports, map[int]string{80: "http"}) {
+		t.Errorf("got %v", ports.Ports)
+	}
+}
+
+func TestKeyedMap_Errors(t *testing.T) {
+	var cfg keyedConfig
+	err := Unmarshal([]byte(`<config><plugin name="a"/><plugin/></config>`), &cfg)
+	if err == nil || !strings.Contains(err.Error(), `/config/plugin[2]`) || !strings.Contains(err.Error(), `missing key attribute "name"`) {
+		t.Errorf("got %v", err)
+	}
+
+	var ports struct {
+		Ports map[int]string `xml:"port,key=n"`
+	}
+	err = Unmarshal([]byte(`<s><port n="x">http</port></s>`), &ports)
+	if err == nil || !strings.Contains(err.Error(), `/s/port/@n`) {
+		t.Errorf("got %v", err)
+	}
+
+	var notMap struct {
+		Plugins []keyedPlugin `xml:"plugin,key=name"`
+	}
+	if _, err := Marshal(notMap); err == nil {
+		t.Error("expected an error for key= on a slice")
+	}
+	var badKey struct {
+		M map[float64]string `xml:"m,key=k"`
+	}
+	if _, err := Marshal(badKey); err == nil {
+		t.Error("expected an error for a float map key")
+	}
+}
+
+func TestKeyedMap_AttrField(t *testing.T) {
+	// The value's own attribute field is written instead of the key.
+	type user struct {
+		Name string `xml:"name,attr"`
+		Mail string `xml:"mail"`
+	}
+	type users struct {
+		Users map[string]*user `xml:"user,key=name"`
+	}
+	v := users{Users: map[string]*user{"ann": {Name: "ann", Mail: "a@x"}}}
+	data, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if want := `<users><user name="ann"><mail>a@x</mail></user></users>`; string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+	var got users
+	if err := Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, v) {
+		t.Errorf("got %+v", got.Users["ann"])
+	}
+}
@@ -0,0 +1,54 @@
+package xml
+
+import (
+	"github.com/shapestone/shape-core/pkg/ast"
+	"github.com/shapestone/shape-xml/internal/parser"
+)
+
+// WithLenient makes the parser recover from errors common in hand-written
+// and scraped XML, instead of rejecting the document:
+//   - an end tag that does not match the innermost open element closes the
+//     open elements up to the one it names, or is skipped if it names none,
+//     so <a><b>text</a> parses as <a><b>text</b></a>
+//   - elements still open at the end of the input are closed there
+//   - a '&' that does not start a reference, as in "Q&A", is kept as text
+//   - an attribute value without quotes, as in <td width=80>, runs up to
+//     whitespace, ">" or "/>"; an attribute without a value, as in
+//     <input disabled>, is empty
+//   - content after the root element is ignored
+//
+// Other errors still fail the parse. Use ParseLenient to learn which
+// errors were recovered from; the other functions drop them.
+//
+// WithLenient applies to ParseWithOptions, ParseElementWithOptions and
+// UnmarshalWithOptions into interface{}; it is not supported together with
+// WithElementFilter or WithMaxDepth.
+func WithLenient() ParseOption {
+	return func(o *ParseOptions) {
+		o.Lenient = true
+	}
+}
+
+// ParseLenient parses input like ParseWithOptions with WithLenient, and
+// returns the errors it recovered from along with the best-effort AST, in
+// document order. err is only set for errors that could not be recovered
+// from, or for invalid options. At most 100 errors are reported, the last
+// one then saying that there were too many.
+//
+// Example:
+//
+//	node, problems, err := xml.ParseLenient(`<p>Q&A <b>bold</p>`)
+//	if err != nil {
+//	    // not even a best-effort tree
+//	}
+//	for _, p := range problems {
+//	    log.Printf("line %d: %v", p.Line, p.Err)
+//	}
+func ParseLenient(input string, opts ...ParseOption) (node ast.SchemaNode, errs []*SyntaxError, err error) {
+	o := newParseOptions(opts)
+	o.Lenient = true
+	defer o.recoverPanic(&err)
+	p := parser.NewParser(o.cleanString(input))
+	node, _, err = parseDocument(p, o)
+	return node, p.Errors(), err
+}
@@ -0,0 +1,79 @@
+package xml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLenient(t *testing.T) {
+	input := "<table>\n<tr><td width=80>Q&A<td>x</tr>\n</table"
+	node, errs, err := ParseLenient(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NodeToInterface(node).(map[string]interface{})
+	td := v["tr"].(map[string]interface{})["td"].(map[string]interface{})
+	if td["@width"] != "80" || td["#text"] != "Q&A" {
+		t.Errorf("td = %v", td)
+	}
+	var msgs []string
+	for _, e := range errs {
+		msgs = append(msgs, e.Error())
+	}
+	want := []string{
+		`xml: syntax error at line 2, column 15: value of attribute "width" is not quoted`,
+		`xml: syntax error at line 2, column 19: '&' does not start a reference`,
+		`xml: syntax error at line 2, column 26: element "td" not closed before closing tag "tr"`,
+		`xml: syntax error at line 2, column 26: element "td" not closed before closing tag "tr"`,
+		`xml: syntax error at line 3, column 8: expected > in closing tag for element "table"`,
+	}
+	if strings.Join(msgs, "\n") != strings.Join(want, "\n") {
+		t.Errorf("errors:\n%s\nwant:\n%s", strings.Join(msgs, "\n"), strings.Join(want, "\n"))
+	}
+	if errs[0].Element != "td" || errs[0].Snippet != "<tr><td width=80>Q&A<td>x</tr>" {
+		t.Errorf("first error: element %q, snippet %q", errs[0].Element, errs[0].Snippet)
+	}
+
+	// Errors that cannot be recovered from still fail.
+	if _, _, err := ParseLenient(`<a><`); err == nil {
+		t.Error("expected an error")
+	}
+
+	// Input ending inside a start tag is closed there.
+	for _, input := range []string{`<a`, `<a b`, `<a><b`} {
+		if _, errs, err := ParseLenient(input); err != nil || len(errs) == 0 {
+			t.Errorf("ParseLenient(%q): %v, %v", input, errs, err)
+		}
+	}
+}
+
+func TestWithLenient(t *testing.T) {
+	input := `<a><b x=1>t</a>`
+	if _, err := ParseWithOptions(input); err == nil {
+		t.Fatal("expected an error without WithLenient")
+	}
+	if _, err := ParseWithOptions(input, WithLenient()); err != nil {
+		t.Errorf("ParseWithOptions: %v", err)
+	}
+	e, err := ParseElementWithOptions(input, WithLenient())
+	if err != nil {
+		t.Fatalf("ParseElementWithOptions: %v", err)
+	}
+	if b, _ := e.GetChild("b"); b == nil {
+		t.Errorf("no child b in %v", e.ToMap())
+	} else if x, _ := b.GetAttr("x"); x != "1" {
+		t.Errorf("x = %q", x)
+	}
+	var v interface{}
+	if err := UnmarshalWithOptions([]byte(input), &v, WithLenient()); err != nil {
+		t.Errorf("UnmarshalWithOptions: %v", err)
+	}
+
+	var s struct{}
+	if err := UnmarshalWithOptions([]byte(input), &s, WithLenient()); err == nil {
+		t.Error("expected an error unmarshaling into a struct")
+	}
+	if _, err := ParseElementWithOptions(input, WithLenient(), WithMaxDepth(2, DepthSummaryRaw)); err == nil {
+		t.Error("expected an error with WithMaxDepth")
+	}
+}
@@ -0,0 +1,82 @@
+package xml
+
+import (
+	"github.com/shapestone/shape-xml/internal/fastparser"
+	"github.com/shapestone/shape-xml/internal/limits"
+)
+
+// DefaultMaxDepth is the element depth every parse allows unless
+// Limits.MaxDepth says otherwise. It matches the limit of encoding/xml.
+const DefaultMaxDepth = limits.DefaultMaxDepth
+
+// Limits bounds the resources spent on a document, so that hostile input
+// is rejected with a *LimitError rather than exhausting the stack or
+// memory. Set it with WithLimits. A zero field leaves its resource
+// unbounded, except MaxDepth: the plain functions such as Parse, Validate
+// and Unmarshal apply DefaultMaxDepth too.
+type Limits struct {
+	// MaxDepth is the deepest element nesting allowed, with the root at
+	// depth 1. Zero means DefaultMaxDepth and a negative value any depth.
+	// Unlike WithMaxDepth, which summarizes deeper content, exceeding it is
+	// an error.
+	MaxDepth int
+
+	// MaxAttributes is the most attributes one element may have.
+	MaxAttributes int
+
+	// MaxTokenLength is the longest name, attribute value, comment, CDATA
+	// section or run of text between markup allowed, in bytes of input.
+	MaxTokenLength int
+
+	// MaxDocumentSize is the largest document allowed, in bytes.
+	MaxDocumentSize int64
+}
+
+// LimitError reports a document exceeding its Limits. Limit is one of the
+// Limit* constants, Max the value of the limit, and Line and Column locate
+// the construct exceeding it; they are 0 for LimitDocumentSize. Use
+// errors.As to inspect it.
+type LimitError = limits.Error
+
+// Values of LimitError.Limit.
+const (
+	LimitDepth        = limits.Depth
+	LimitAttributes   = limits.Attributes
+	LimitTokenLength  = limits.TokenLength
+	LimitDocumentSize = limits.DocumentSize
+)
+
+// WithLimits bounds the documents ParseWithOptions, ParseElementWithOptions,
+// UnmarshalWithOptions and ValidateWithOptions accept. Content skipped by
+// WithElementFilter or summarized by WithMaxDepth is only checked for
+// well-formedness.
+//
+// Example:
+//
+//	err := xml.UnmarshalWithOptions(body, &order, xml.WithLimits(xml.Limits{
+//	    MaxDepth:        64,
+//	    MaxAttributes:   32,
+//	    MaxTokenLength:  1 << 16,
+//	    MaxDocumentSize: 1 << 20,
+//	}))
+//	var limitErr *xml.LimitError
+//	if errors.As(err, &limitErr) {
+//	    // reject the request
+//	}
+func WithLimits(l Limits) ParseOption {
+	return func(o *ParseOptions) {
+		o.Limits = l
+	}
+}
+
+// ValidateWithOptions checks if input is valid XML like Validate, applying
+// WithLimits and WithRecover. Other options do not affect validation and
+// are ignored.
+func ValidateWithOptions(input string, opts ...ParseOption) (err error) {
+	o := newParseOptions(opts)
+	defer o.recoverPanic(&err)
+	p := fastparser.NewParser([]byte(input))
+	p.SetLimits(limits.Limits(o.Limits))
+	_, err = p.Parse()
+	return err
+}
@@ -0,0 +1,119 @@
+package xml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func nested(depth int) string {
+	return strings.Repeat("<a>", depth) + strings.Repeat("</a>", depth)
+}
+
+// parseFuncs runs each parsing path with opts: the AST parser and the
+// fast parser.
+var limitParseFuncs = map[string]func(input string, opts ...ParseOption) error{
+	"ParseWithOptions": func(input string, opts ...ParseOption) error {
+		_, err := ParseWithOptions(input, opts...)
+		return err
+	},
+	"ParseElementWithOptions": func(input string, opts ...ParseOption) error {
+		_, err := ParseElementWithOptions(input, opts...)
+		return err
+	},
+	"UnmarshalWithOptions": func(input string, opts ...ParseOption) error {
+		var v interface{}
+		return UnmarshalWithOptions([]byte(input), &v, opts...)
+	},
+	"UnmarshalWithOptions/mixed": func(input string, opts ...ParseOption) error {
+		var v interface{}
+		return UnmarshalWithOptions([]byte(input), &v, append(opts, WithMixedContent())...)
+	},
+	"ValidateWithOptions": ValidateWithOptions,
+}
+
+func TestLimits_DefaultMaxDepth(t *testing.T) {
+	deep := nested(DefaultMaxDepth + 1)
+	var v interface{}
+	for name, err := range map[string]error{
+		"Parse":     func() error { _, err := Parse(deep); return err }(),
+		"Validate":  Validate(deep),
+		"Unmarshal": Unmarshal([]byte(deep), &v),
+	} {
+		var limitErr *LimitError
+		if !errors.As(err, &limitErr) || limitErr.Limit != LimitDepth {
+			t.Errorf("%s: error = %v, want a depth LimitError", name, err)
+			continue
+		}
+		if limitErr.Max != DefaultMaxDepth || limitErr.Line != 1 || limitErr.Column != 3*DefaultMaxDepth+1 {
+			t.Errorf("%s: %+v, want the default limit at line 1, column %d", name, limitErr, 3*DefaultMaxDepth+1)
+		}
+		if len(err.Error()) > 200 {
+			t.Errorf("%s: error message of %d bytes, want it unwrapped", name, len(err.Error()))
+		}
+	}
+
+	if err := Validate(nested(DefaultMaxDepth)); err != nil {
+		t.Errorf("Validate at the default depth: %v", err)
+	}
+	if err := ValidateWithOptions(deep, WithLimits(Limits{MaxDepth: -1})); err != nil {
+		t.Errorf("ValidateWithOptions with MaxDepth -1: %v", err)
+	}
+}
+
+func TestLimits_Exceeded(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		limits Limits
+		limit  string
+		line   int
+		column int
+	}{
+		{"depth", "<a>\n  <b><c/></b>\n</a>", Limits{MaxDepth: 2}, LimitDepth, 2, 6},
+		{"attributes", `<a><b x="1" y="2" z="3"/></a>`, Limits{MaxAttributes: 2}, LimitAttributes, 1, 19},
+		{"element name", `<a><abcdef/></a>`, Limits{MaxTokenLength: 5}, LimitTokenLength, 1, 5},
+		{"attribute value", `<a x="123456"/>`, Limits{MaxTokenLength: 5}, LimitTokenLength, 1, 0},
+		{"text", "<a><b>12</b>\n123456</a>", Limits{MaxTokenLength: 5}, LimitTokenLength, 2, 1},
+		{"cdata", `<a><![CDATA[123456]]></a>`, Limits{MaxTokenLength: 5}, LimitTokenLength, 1, 0},
+		{"comment", `<a><!--123456--></a>`, Limits{MaxTokenLength: 5}, LimitTokenLength, 1, 0},
+		{"document size", `<a>1234</a>`, Limits{MaxDocumentSize: 10}, LimitDocumentSize, 0, 0},
+	}
+	for _, tt := range tests {
+		for name, parse := range limitParseFuncs {
+			err := parse(tt.input, WithLimits(tt.limits))
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Errorf("%s %s: error = %v, want a LimitError", tt.name, name, err)
+				continue
+			}
+			if limitErr.Limit != tt.limit || limitErr.Line != tt.line {
+				t.Errorf("%s %s: %+v, want limit %q at line %d", tt.name, name, limitErr, tt.limit, tt.line)
+			}
+			if tt.column != 0 && limitErr.Column != tt.column {
+				t.Errorf("%s %s: column %d, want %d", tt.name, name, limitErr.Column, tt.column)
+			}
+		}
+	}
+}
+
+func TestLimits_WithinLimits(t *testing.T) {
+	input := `<a x="12345"><b>12345</b><!--12345--><![CDATA[12345]]></a>`
+	l := Limits{MaxDepth: 2, MaxAttributes: 1, MaxTokenLength: 5, MaxDocumentSize: int64(len(input))}
+	for name, parse := range limitParseFuncs {
+		if err := parse(input, WithLimits(l)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestLimitError_Error(t *testing.T) {
+	err := &LimitError{Limit: LimitDepth, Max: 2, Line: 3, Column: 4}
+	if got, want := err.Error(), "xml: depth limit of 2 exceeded at line 3, column 4"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	err = &LimitError{Limit: LimitDocumentSize, Max: 10}
+	if got, want := err.Error(), "xml: document size limit of 10 exceeded"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
@@ -1,16 +1,24 @@
 package xml
 
 import (
+	"errors"
 	"reflect"
-	"strconv"
 
+	"github.com/shapestone/shape-xml/internal/charset"
 	"github.com/shapestone/shape-xml/internal/fastparser"
+	"github.com/shapestone/shape-xml/internal/parser"
 )
 
 // Marshal returns the XML encoding of v.
 //
 // Marshal traverses the value v recursively. If an encountered value implements
-// the xml.Marshaler interface, Marshal calls its MarshalXML method to produce XML.
+// the xml.ElementMarshaler interface, Marshal calls its AppendXMLElement method
+// with the element name it would have used. Otherwise, if it implements the
+// xml.Marshaler interface, Marshal calls its MarshalXML method to produce XML.
+// Otherwise, if it implements encoding.TextMarshaler, as time.Time and
+// net.IP do, its MarshalText result is written as the element's text, or as
+// the attribute or character data value of a field. A method on the
+// pointer is used when the value is addressable.
 //
 // Otherwise, Marshal uses the following type-dependent default encodings:
 //
@@ -20,11 +28,15 @@ import (
 //
 // String values encode as XML text with proper escaping.
 //
-// Array and slice values encode as a sequence of XML elements with the same name.
+// Array and slice values encode as a sequence of XML elements with the same name,
+// except []byte, which encodes as XML text.
 //
 // Struct values encode as XML elements. Each exported struct field becomes
 // either an XML element or attribute, using the field name as the element/attribute name,
-// unless the field is omitted for one of the reasons given below.
+// unless the field is omitted for one of the reasons given below. The fields
+// of an embedded struct without an xml tag are encoded as if they were
+// fields of the outer struct, even when its type is unexported; a field of
+// the outer struct hides one of the same name in the embedded struct.
 //
 // The encoding of each struct field can be customized by the format string
 // stored under the "xml" key in the struct field's tag. The format string
@@ -32,6 +44,19 @@ import (
 // of options. The name may be empty in order to specify options without
 // overriding the default field name.
 //
+// A name preceded by a namespace URI and a space, as in
+// `xml:"http://schemas.xmlsoap.org/soap/envelope/ soap:Body"`, puts the
+// element in that namespace: the element declares the URI for its prefix,
+// or as the default namespace if the name has none, unless an enclosing
+// element already declared it. Items of a slice each carry the declaration.
+// Unmarshal matches such fields by the name as written.
+//
+// A name that is a path such as `xml:"tags>tag"` writes the field inside
+// wrapper elements, so a []string becomes
+// <tags><tag>a</tag><tag>b</tag></tags>. Consecutive fields whose paths
+// start alike share the wrappers, and a field left out by omitempty opens
+// none. Paths apply only to elements.
+//
 // The "attr" option specifies that the field should be encoded as an XML attribute.
 //
 // The "chardata" option specifies that the field contains the text content of the element.
@@ -42,67 +67,170 @@ import (
 // encoding if the field has an empty value, defined as false, 0, a nil pointer,
 // a nil interface value, and any empty array, slice, map, or string.
 //
+// time.Time values encode in RFC 3339 through their MarshalText method. The
+// "layout=layout" option on a time.Time field, or a pointer, slice or array
+// of them, writes and parses the times in that layout instead. The layout
+// extends to the end of the tag, so it must be the last option and may
+// contain commas. For example:
+//
+//	Day    time.Time   `xml:"day,attr,layout=2006-01-02"`
+//	Hours  []time.Time `xml:"hour,layout=15:04"`
+//
+// The "countattr=name" option, on a slice, array or map field, additionally
+// writes the field's length as attribute name on the enclosing element,
+// computed at encode time:
+//
+//	type Items struct {
+//	    List []Item `xml:"item,countattr=count"`
+//	}
+//	// <Items count="2"><item>...</item><item>...</item></Items>
+//
+// A regular attribute field with the same name is not written, so an int
+// field tagged `xml:"count,attr"` receives the count when unmarshaling.
+//
+// Attributes whose values are derived rather than stored are supplied by
+// methods: a value-receiver method named XMLAttr_name with signature
+// func() string is called at encode time and its result written as
+// attribute name, unless empty. As with countattr, it replaces a regular
+// attribute field of the same name:
+//
+//	func (o Order) XMLAttr_total() string {
+//	    return strconv.Itoa(o.Sum())
+//	}
+//	// <Order total="42">...</Order>
+//
+// The "key=name" option, on a map field, writes one element per entry in
+// key order, with the map key as attribute name, and decodes repeated
+// elements back into the map by that attribute:
+//
+//	type Config struct {
+//	    Plugins map[string]Plugin `xml:"plugin,key=name"`
+//	}
+//	// <Config><plugin name="auth">...</plugin><plugin name="cache">...</plugin></Config>
+//
+// Keys may be strings or integers. If the value type has an attribute field
+// of the same name, that field is written instead of the key.
+//
+// A struct can name its own element with a field named XMLName of type
+// Name, or encoding/xml's Name, which is not written as a child. The element
+// name is, in order of precedence, the name in the XMLName field's tag, the
+// XMLName value if its Local is set, the tag or name of the field holding
+// the struct, and for the root the struct's type name. A namespace in the XMLName tag or value
+// is declared on the element unless already in scope:
+//
+//	type Feed struct {
+//	    XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+//	    Title   string   `xml:"title"`
+//	}
+//	// <feed xmlns="http://www.w3.org/2005/Atom"><title>...</title></feed>
+//
+// A field of such a type without a name in its own tag takes the XMLName
+// tag's name; a different name in the field's tag is an error.
+//
 // As a special case, if the field tag is "-", the field is always omitted.
 //
 // Map values encode as XML elements with map keys as element names.
 // The map's key type must be a string; the map keys are used as XML element names.
+// A key that is not a valid XML name, or a tag naming an element or attribute
+// with one, is an error; MarshalOptions.EscapeNames escapes such keys instead.
 //
-// Pointer values encode as the value pointed to. A nil pointer encodes as
-// an empty XML element.
+// Pointer values encode as the value pointed to. A struct field holding a
+// nil pointer is left out, as in encoding/xml, so that Unmarshal leaves it
+// nil; elsewhere, such as in a slice, a nil pointer encodes as an empty XML
+// element.
 //
 // Interface values encode as the value contained in the interface.
-// A nil interface value encodes as an empty XML element.
+// Nil interface values are treated like nil pointers.
+//
+// Float values that are NaN or infinite are rejected with an *UnsupportedValueError;
+// use MarshalWithOptions with a FloatPolicy to encode them instead.
 //
 // XML cannot represent cyclic data structures and Marshal does not handle them.
 // Passing cyclic structures to Marshal will result in an error.
 func Marshal(v interface{}) ([]byte, error) {
-	if v == nil {
-		return []byte("<root/>"), nil
+	return MarshalWithOptions(v, MarshalOptions{})
+}
+
+// MarshalWithOptions works like Marshal but applies the given options.
+//
+// Example:
+//
+//	b, err := xml.MarshalWithOptions(v, xml.MarshalOptions{FloatPolicy: xml.FloatPolicyXSD})
+func MarshalWithOptions(v interface{}, opts MarshalOptions) ([]byte, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
 	}
 
 	rv := reflect.ValueOf(v)
-	for rv.Kind() == reflect.Ptr {
-		if rv.IsNil() {
-			return []byte("<root/>"), nil
-		}
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
 		rv = rv.Elem()
 	}
 
-	// Determine root element name.
-	rootName := "root"
-	if rv.Kind() == reflect.Struct {
-		if name := rv.Type().Name(); name != "" {
-			rootName = name
+	bp := getEncodeBuffer()
+	buf := *bp
+	defer func() {
+		*bp = buf
+		putEncodeBuffer(bp)
+	}()
+
+	var err error
+	if !rv.IsValid() || rv.Kind() == reflect.Ptr {
+		// nil interface or nil pointer
+		buf = appendEmptyElement(buf, rootName(opts, "root"))
+	} else {
+		es := &encodeState{opts: opts}
+		buf, err = xmlEncoderForType(rv.Type())(es, buf, rv, rootName(opts, rootElementName(rv)))
+		if err != nil {
+			return nil, err
 		}
 	}
+	return finishDocument(buf, &opts), nil
+}
 
-	enc := xmlEncoderForType(rv.Type())
-
-	bp := xmlBufPool.Get().(*[]byte)
-	buf := (*bp)[:0]
+// rootName returns the root element name set by opts, or name.
+func rootName(opts MarshalOptions, name string) string {
+	if opts.RootName != "" {
+		return opts.RootName
+	}
+	return name
+}
 
-	var err error
-	buf, err = enc(buf, rv, rootName)
-	if err != nil {
-		*bp = buf
-		xmlBufPool.Put(bp)
-		return nil, err
+// finishDocument returns a copy of the compact document in buf with the
+// declaration and layout opts asks for.
+func finishDocument(buf []byte, opts *MarshalOptions) []byte {
+	size := len(buf)
+	if opts.Declaration {
+		size += 64
+	}
+	if opts.reformats() {
+		size += size / 4
 	}
+	result := make([]byte, 0, size)
+	if opts.Declaration {
+		result = appendDeclaration(result, opts)
+	}
+	if opts.reformats() {
+		return formatXML(result, buf, opts, 0, false)
+	}
+	return append(result, buf...)
+}
 
-	result := make([]byte, len(buf))
-	copy(result, buf)
-	*bp = buf
-	xmlBufPool.Put(bp)
-	return result, nil
+// rootElementName returns the element name Marshal uses for rv: the type
+// name of a named struct, "root" otherwise.
+func rootElementName(rv reflect.Value) string {
+	if rv.Kind() == reflect.Struct && rv.Type() != orderedMapType.Elem() {
+		if name := rv.Type().Name(); name != "" {
+			return name
+		}
+	}
+	return "root"
 }
 
 // MarshalIndent works like Marshal but with indentation for readability.
 // Each XML element begins on a new line starting with prefix followed by one or more
 // copies of indent according to the nesting depth.
 func MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) {
-	// For now, just call Marshal - pretty printing can be added later
-	// This matches the shape-json pattern
-	return Marshal(v)
+	return MarshalWithOptions(v, MarshalOptions{Prefix: prefix, Indent: indent})
 }
 
 // Marshaler is the interface implemented by types that can marshal themselves into valid XML.
@@ -110,31 +238,18 @@ type Marshaler interface {
 	MarshalXML() ([]byte, error)
 }
 
-// formatValue formats a reflect.Value as a string for attribute values or text content
+// ElementMarshaler is the interface implemented by types that append their
+// own XML encoding under an element name chosen by the caller, such as the
+// methods written by cmd/shapexml-gen. Unlike Marshaler, the element keeps
+// the name of the field or slice holding the value.
+type ElementMarshaler interface {
+	AppendXMLElement(buf []byte, name string) ([]byte, error)
+}
+
+// formatValue formats a reflect.Value as a string for attribute values or
+// text content. It is the string form of appendFormatValue.
 func formatValue(rv reflect.Value) string {
-	if !rv.IsValid() {
-		return ""
-	}
-
-	switch rv.Kind() {
-	case reflect.String:
-		return rv.String()
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return strconv.FormatInt(rv.Int(), 10)
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return strconv.FormatUint(rv.Uint(), 10)
-	case reflect.Float32, reflect.Float64:
-		return strconv.FormatFloat(rv.Float(), 'g', -1, 64)
-	case reflect.Bool:
-		return strconv.FormatBool(rv.Bool())
-	case reflect.Ptr, reflect.Interface:
-		if rv.IsNil() {
-			return ""
-		}
-		return formatValue(rv.Elem())
-	default:
-		return ""
-	}
+	return string(appendFormatValue(nil, rv))
 }
 
 // Unmarshal parses the XML-encoded data and stores the result in the value pointed to by v.
@@ -151,6 +266,59 @@ func formatValue(rv reflect.Value) string {
 //	    Bio  string `xml:",chardata"`    // Text content
 //	}
 //
+// The tag options attr, chardata, cdata, omitempty and layout are understood
+// as by Marshal, so any value Marshal produces decodes back into the same
+// type.
+// Text is converted to the field's type: strings, []byte, integers,
+// unsigned integers, floats, bools and time.Duration; empty text decodes as
+// zero. Fields of untagged embedded structs are filled in as fields of the
+// outer struct, allocating embedded pointers as needed.
+// Surrounding whitespace is ignored, and a number outside the range of its
+// field fails with an error wrapping strconv.ErrRange.
+// Types implementing encoding.TextUnmarshaler on their pointer, such as
+// time.Time and net.IP, receive the text through UnmarshalText.
+// Nested structs, pointers and slices are filled in recursively; a slice
+// field collects every repeated element, and a single element decodes as
+// a one-item slice. An array field takes as many elements as it holds and
+// zeroes the rest. Elements marked xsi:nil="true" decode as zero values.
+// An XMLName field of type Name receives the name of the element the struct
+// was decoded from, with Space set when the element itself declares the
+// namespace of its prefix.
+//
+// Empty and missing elements follow one contract. <a/> and <a></a> are the
+// same empty element; whitespace-only content also counts as empty. Into a
+// struct, an empty element sets a string to "" and a number or bool to zero,
+// and allocates a pointer to a zero value; a missing element
+// leaves its field untouched, so pointer fields stay nil. Into an interface
+// value, an empty element is an empty map[string]interface{}{} under its
+// key and a missing element has no key. UnmarshalWithOptions with
+// WithEmptyAsNil decodes empty elements as nil instead: a nil map value and
+// a nil pointer. An empty element into a slice is one zero-valued item.
+// Marshal leaves out nil pointer and interface fields, so they decode back
+// to nil, and writes nothing for an empty or nil slice, as encoding/xml
+// does.
+//
+// A field tagged with a nested path such as `xml:"tags>tag"` decodes the
+// tag elements inside the tags wrapper element. A wrapper holding a single
+// item decodes into a one-item slice, whitespace between items is ignored,
+// and the items of repeated wrappers are collected into one list. An empty
+// or missing wrapper leaves the field untouched. Several fields may share
+// a wrapper, as in `xml:"meta>author"` and `xml:"meta>year"`.
+//
+// A map field tagged key=name collects repeated elements by their
+// attribute name; when two elements share a key, the later one is decoded
+// over the earlier entry, so their fields merge and the later one wins
+// where both are set. An element without the attribute is an error.
+//
+// A value that cannot be converted is reported as an *UnmarshalError naming
+// its location in the document:
+//
+//	xml: /orders/order[3]/total (line 14, column 5): cannot parse "abc" as int
+//
+// A DOCTYPE is accepted, and the general entities declared in its internal
+// subset are expanded in text and attribute values. External entities are
+// never loaded; referencing one is an error, as is expansion beyond 10 MB.
+//
 // To unmarshal XML into an interface value, Unmarshal stores a map[string]interface{}
 // representation:
 //   - "@attrname" for attributes
@@ -158,6 +326,80 @@ func formatValue(rv reflect.Value) string {
 //   - "#cdata" for CDATA sections
 //   - "childname" for child elements
 func Unmarshal(data []byte, v interface{}) error {
+	data, err := charset.Convert(data, nil)
+	if err != nil {
+		return err
+	}
 	// Fast path: Direct parsing without AST construction (4-5x faster)
 	return fastparser.Unmarshal(data, v)
 }
+
+// UnmarshalError reports a value that could not be decoded by Unmarshal,
+// with its path from the root element, e.g. "/orders/order[3]/total" or
+// "/orders/order/@id", and the line and column of the element's start tag.
+// Repeated elements carry their 1-based position among same-named siblings.
+// Use errors.As to inspect it; Unwrap returns the conversion error.
+type UnmarshalError = fastparser.UnmarshalError
+
+// UnmarshalWithOptions works like Unmarshal but applies the given options.
+// With WithUseNumber, numeric values stored into interface{} targets are
+// Number instead of string; typed fields are unaffected. With WithSortedKeys,
+// an interface{} target receives an *OrderedMap tree instead of maps. With
+// WithElementFilter, elements outside the whitelisted paths are skipped
+// and never reach v. With WithRecover, a panic while decoding is returned as
+// a *PanicError. With WithPreserveOrder, an interface{} target receives an
+// *OrderedMap tree in document order. With WithMixedContent, interface{}
+// targets receive the "#content" order of mixed content, and with
+// WithComments the comments; typed fields are unaffected.
+func UnmarshalWithOptions(data []byte, v interface{}, opts ...ParseOption) (err error) {
+	o := newParseOptions(opts)
+	defer o.recoverPanic(&err)
+	if data, err = o.convert(data); err != nil {
+		return err
+	}
+	if !o.postProcess() && o.Limits == (Limits{}) {
+		return fastparser.UnmarshalInterned(data, v, o.InternPool)
+	}
+
+	rv := reflect.ValueOf(v)
+	if v == nil || rv.Kind() != reflect.Ptr || rv.IsNil() {
+		// Let Unmarshal report the invalid target.
+		return fastparser.Unmarshal(data, v)
+	}
+	if u, ok := v.(fastparser.Unmarshaler); ok {
+		return u.UnmarshalXML(data)
+	}
+
+	target := rv.Elem()
+	if (o.PreserveOrder || o.MixedContent || o.Comments || o.Lenient) && target.Kind() == reflect.Interface && target.NumMethod() == 0 {
+		if o.mapOnly() {
+			return errors.New("xml: WithPreserveOrder, WithMixedContent, WithComments and WithLenient are not supported with WithElementFilter, WithMaxDepth or WithBackslashEscapes")
+		}
+		// Only the AST records where each attribute and element occurred.
+		node, _, err := parseDocument(parser.NewParserFromBytes(data), o)
+		if err != nil {
+			return err
+		}
+		value := NodeToInterface(node)
+		if o.PreserveOrder {
+			value = sourceOrdered(node, value)
+		} else if o.SortedKeys {
+			value = sortedKeys(value)
+		}
+		target.Set(reflect.ValueOf(value))
+		return nil
+	}
+	if o.Lenient {
+		return errors.New("xml: WithLenient is only supported when unmarshaling into interface{}")
+	}
+
+	value, root, err := o.parseMap(data)
+	if err != nil {
+		return err
+	}
+	if o.SortedKeys && target.Kind() == reflect.Interface && target.NumMethod() == 0 {
+		target.Set(reflect.ValueOf(sortedKeys(value)))
+		return nil
+	}
+	return fastparser.UnmarshalDocumentValue(value, target, root, data)
+}
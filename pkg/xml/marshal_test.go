@@ -1,6 +1,8 @@
 package xml
 
 import (
+	stdxml "encoding/xml"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -293,3 +295,299 @@ func TestUnmarshal_NotPointer(t *testing.T) {
 		t.Error("Expected error when not passing pointer")
 	}
 }
+
+func TestMarshal_DynamicSliceMixedTypes(t *testing.T) {
+	type Payload struct {
+		Values []interface{} `xml:"v"`
+	}
+	p := Payload{Values: []interface{}{"a", "b", 1, int64(2), nil, 3.5, "c", true}}
+
+	bytes, err := Marshal(p)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	want := `<Payload><v>a</v><v>b</v><v>1</v><v>2</v><v/><v>3.5</v><v>c</v><v>true</v></Payload>`
+	if string(bytes) != want {
+		t.Errorf("got  %s\nwant %s", bytes, want)
+	}
+}
+
+func TestMarshal_DynamicMapMixedTypes(t *testing.T) {
+	m := map[string]interface{}{
+		"a": "x",
+		"b": "y",
+		"c": 7,
+		"d": []interface{}{"p", "q"},
+		"e": map[string]interface{}{"f": "z"},
+		"g": nil,
+	}
+
+	bytes, err := Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	want := `<root><a>x</a><b>y</b><c>7</c><d>p</d><d>q</d><e><f>z</f></e><g/></root>`
+	if string(bytes) != want {
+		t.Errorf("got  %s\nwant %s", bytes, want)
+	}
+}
+
+func BenchmarkMarshal_DynamicSlice(b *testing.B) {
+	values := make([]interface{}, 1000)
+	for i := range values {
+		values[i] = "item"
+	}
+	m := map[string]interface{}{"item": values}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestUnmarshal_DoctypeEntities(t *testing.T) {
+	input := `<!DOCTYPE user [<!ENTITY org "Acme &amp; Sons">]>
+<user org="&org;"><name>Alice of &org;</name></user>`
+	var u struct {
+		Org  string `xml:"org,attr"`
+		Name string `xml:"name"`
+	}
+	if err := Unmarshal([]byte(input), &u); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if u.Org != "Acme & Sons" || u.Name != "Alice of Acme & Sons" {
+		t.Errorf("got %+v", u)
+	}
+}
+
+type pathPost struct {
+	Title   string   `xml:"title"`
+	Tags    []string `xml:"tags>tag"`
+	Author  string   `xml:"meta>author"`
+	Year    int      `xml:"meta>year,omitempty"`
+	Editor  string   `xml:"meta>staff>editor,omitempty"`
+	License string   `xml:"license"`
+}
+
+func TestMarshal_ListWrapper(t *testing.T) {
+	tests := []struct {
+		name string
+		v    pathPost
+		want string
+	}{
+		{
+			"shared wrappers",
+			pathPost{Title: "t", Tags: []string{"go", "xml"}, Author: "a", Year: 2024, Editor: "e", License: "MIT"},
+			`<pathPost><title>t</title><tags><tag>go</tag><tag>xml</tag></tags>` +
+				`<meta><author>a</author><year>2024</year><staff><editor>e</editor></staff></meta>` +
+				`<license>MIT</license></pathPost>`,
+		},
+		{
+			"omitted leaf opens no wrapper",
+			pathPost{Title: "t", Tags: []string{"go"}, Author: "a"},
+			`<pathPost><title>t</title><tags><tag>go</tag></tags><meta><author>a</author></meta><license></license></pathPost>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.v)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("got  %s\nwant %s", data, tt.want)
+			}
+
+			var back pathPost
+			if err := Unmarshal(data, &back); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if !reflect.DeepEqual(back, tt.v) {
+				t.Errorf("round trip = %+v, want %+v", back, tt.v)
+			}
+
+			// encoding/xml reads the same structure.
+			var std pathPost
+			if err := stdxml.Unmarshal(data, &std); err != nil {
+				t.Fatalf("encoding/xml Unmarshal failed: %v", err)
+			}
+			if !reflect.DeepEqual(std, tt.v) {
+				t.Errorf("encoding/xml decoded %+v, want %+v", std, tt.v)
+			}
+		})
+	}
+}
+
+func TestMarshal_ListWrapperErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"empty segment", struct {
+			A string `xml:"a>>b"`
+		}{}, "invalid element name"},
+		{"attr path", struct {
+			A string `xml:"a>b,attr"`
+		}{}, "apply only to elements"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Marshal(tt.v)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshal_InvalidNames(t *testing.T) {
+	om := NewOrderedMap()
+	om.Set("@a b", "1")
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"element tag", struct {
+			A string `xml:"a<b"`
+		}{}, `field A: invalid element name "a<b"`},
+		{"attribute tag", struct {
+			A string `xml:"1a,attr"`
+		}{}, `field A: invalid attribute name "1a"`},
+		{"map key", map[string]interface{}{"first name": "Ada"}, `map key "first name" is not a valid name`},
+		{"nested map key", map[string]map[string]int{"a": {"<b>": 1}}, `map key "<b>"`},
+		{"ordered map attribute", om, `map key "a b"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Marshal(tt.v)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalWithOptions_EscapeNames(t *testing.T) {
+	v := map[string]interface{}{"first name": "Ada", "0": 1, "ok": true}
+	data, err := MarshalWithOptions(v, MarshalOptions{RootName: "r", EscapeNames: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<r><_0>1</_0><first_x0020_name>Ada</first_x0020_name><ok>true</ok></r>`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+	if _, err := Parse(string(data)); err != nil {
+		t.Errorf("Parse failed: %v", err)
+	}
+}
+
+type wrappedItem struct {
+	ID int `xml:"id,attr"`
+}
+
+type wrappedSlices struct {
+	Names []string      `xml:"items>name"`
+	Items []wrappedItem `xml:"items>item,omitempty"`
+	Refs  []string      `xml:"refs>ref,omitempty"`
+}
+
+func TestMarshal_SliceWrapper(t *testing.T) {
+	tests := []struct {
+		name string
+		v    wrappedSlices
+		opts MarshalOptions
+		want string
+	}{
+		{
+			"slices share one wrapper",
+			wrappedSlices{Names: []string{"a", "b"}, Items: []wrappedItem{{1}, {2}}},
+			MarshalOptions{},
+			`<wrappedSlices><items><name>a</name><name>b</name><item id="1"/><item id="2"/></items></wrappedSlices>`,
+		},
+		{
+			"empty omitempty slice writes no wrapper",
+			wrappedSlices{Names: []string{"a"}},
+			MarshalOptions{},
+			`<wrappedSlices><items><name>a</name></items></wrappedSlices>`,
+		},
+		{
+			"array hint lands on the item",
+			wrappedSlices{Names: []string{"a"}},
+			MarshalOptions{ArrayHints: true},
+			`<wrappedSlices><items><name shape:array="true" xmlns:shape="urn:shapestone:xml:array">a</name></items></wrappedSlices>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := MarshalWithOptions(tt.v, tt.opts)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("got  %s\nwant %s", data, tt.want)
+			}
+
+			var back wrappedSlices
+			if err := Unmarshal(data, &back); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if !reflect.DeepEqual(back, tt.v) {
+				t.Errorf("round trip = %+v, want %+v", back, tt.v)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_ListWrapper(t *testing.T) {
+	var v struct {
+		Tags []string `xml:"tags>tag"`
+	}
+	if err := Unmarshal([]byte("<post>\n <tags>\n  <tag>go</tag>\n </tags>\n</post>"), &v); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(v.Tags) != 1 || v.Tags[0] != "go" {
+		t.Errorf("Tags = %q", v.Tags)
+	}
+}
+
+func TestMarshal_BytesAsText(t *testing.T) {
+	type doc struct {
+		ID   []byte `xml:"id,attr"`
+		Body []byte `xml:"body"`
+		Nil  []byte `xml:"nil"`
+	}
+	in := doc{ID: []byte("7"), Body: []byte("a < b")}
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if want := `<doc id="7"><body>a &lt; b</body><nil></nil></doc>`; string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+	var out doc
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("Unmarshal(%s) = %+v, want %+v", data, out, in)
+	}
+}
+
+func TestUnmarshal_BytesChardata(t *testing.T) {
+	var v struct {
+		Text []byte `xml:",chardata"`
+	}
+	if err := Unmarshal([]byte(`<r>raw &amp; text</r>`), &v); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if string(v.Text) != "raw & text" {
+		t.Errorf("Text = %q", v.Text)
+	}
+}
@@ -0,0 +1,390 @@
+package xml
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+// WithMixedContent makes the parser record the order of element content,
+// which the map form otherwise loses: in <p>Hello <b>world</b>!</p>, "#text"
+// alone cannot say where <b> went. Every element with more than one item
+// of content gets a "#content" list of single-key items in document order:
+//
+//	{"#text": "Hello "}  a run of text, untrimmed
+//	{"#cdata": "..."}    a CDATA section
+//	{"#element": "b"}    the next occurrence of child element b
+//
+// The "#text" of such an element holds all of its text, trimmed, and its
+// children stay under their names as usual. Render, Marshal of an
+// *OrderedMap and Element.XML write "#content" back in order, so mixed
+// content survives a round trip; Element.Content returns it as a list.
+// Runs of whitespace between child elements are only kept in elements that
+// also hold other text.
+//
+// WithMixedContent applies to ParseWithOptions, ParseElementWithOptions and
+// UnmarshalWithOptions into interface{}; it is not supported together with
+// WithElementFilter or WithMaxDepth.
+//
+// Example:
+//
+//	elem, _ := xml.ParseElementWithOptions(`<p>Hello <b>world</b>!</p>`, xml.WithMixedContent())
+//	for _, n := range elem.Content() {
+//	    // "Hello ", <b>, "!"
+//	}
+func WithMixedContent() ParseOption {
+	return func(o *ParseOptions) {
+		o.MixedContent = true
+	}
+}
+
+// ContentNode is one item of an element's content: a run of text, a CDATA
+// section, a comment or a child element.
+type ContentNode struct {
+	// Name is the name of a child element, and empty otherwise.
+	Name string
+	// Element is the child element, nil otherwise.
+	Element *Element
+	// Text is the text of a text run, CDATA section or comment.
+	Text string
+	// CDATA reports whether the node is a CDATA section.
+	CDATA bool
+	// Comment reports whether the node is a comment, see WithComments.
+	Comment bool
+}
+
+// Content returns the content of the element in order. For elements parsed
+// with WithMixedContent, or built with SetContent, that is document order;
+// otherwise the text comes first, then the CDATA section, then the child
+// elements sorted by name, as Render writes them. Child elements share
+// their data with the element, as with GetChild.
+func (e *Element) Content() []ContentNode {
+	if items, ok := e.data["#content"].([]interface{}); ok {
+		nodes := make([]ContentNode, 0, len(items))
+		used := make(map[string]int)
+		for _, item := range items {
+			key, value := contentItem(item)
+			switch key {
+			case "#text":
+				nodes = append(nodes, ContentNode{Text: textValue(value)})
+			case "#cdata":
+				nodes = append(nodes, ContentNode{Text: textValue(value), CDATA: true})
+			case "#comment":
+				nodes = append(nodes, ContentNode{Text: textValue(value), Comment: true})
+			case "#element":
+				name := textValue(value)
+				v, exists := e.data[name]
+				if child, ok := occurrence(v, used[name]); ok && exists {
+					used[name]++
+					nodes = append(nodes, ContentNode{Name: name, Element: e.contentChild(child, name)})
+				}
+			}
+		}
+		return append(nodes, e.remainingChildren(used)...)
+	}
+
+	var nodes []ContentNode
+	if text, ok := e.data["#text"]; ok && text != nil {
+		nodes = append(nodes, ContentNode{Text: textValue(text)})
+	}
+	if cdata, ok := e.data["#cdata"]; ok && cdata != nil {
+		nodes = append(nodes, ContentNode{Text: textValue(cdata), CDATA: true})
+	}
+	return append(nodes, e.remainingChildren(nil)...)
+}
+
+// SetContent replaces the text, CDATA, comments and child elements of the
+// element with nodes, in order, and returns the Element for chaining. Nodes
+// with an Element become children named Name; repeated names become
+// repeated elements. As with Child, frozen children are copied into the
+// tree.
+//
+// Example:
+//
+//	p := xml.NewElement().SetContent(
+//	    xml.ContentNode{Text: "Hello "},
+//	    xml.ContentNode{Name: "b", Element: xml.NewElement().Text("world")},
+//	    xml.ContentNode{Text: "!"},
+//	)
+//	// <p>Hello <b>world</b>!</p>
+func (e *Element) SetContent(nodes ...ContentNode) *Element {
+	e = e.mutable()
+	for key := range e.data {
+		if (len(key) == 0 || key[0] != '@') && key != "#prolog" && key != "#epilog" {
+			delete(e.data, key)
+		}
+	}
+
+	items := make([]interface{}, 0, len(nodes))
+	var text, cdata strings.Builder
+	hasCDATA := false
+	for _, n := range nodes {
+		switch {
+		case n.Element != nil:
+			data := n.Element.data
+			if n.Element.frozen {
+				data = deepCopyMap(data)
+			} else {
+				n.Element.parent, n.Element.name = e, n.Name
+			}
+			switch existing := e.data[n.Name].(type) {
+			case nil:
+				e.data[n.Name] = data
+			case []interface{}:
+				e.data[n.Name] = append(existing, data)
+			default:
+				e.data[n.Name] = []interface{}{existing, data}
+			}
+			items = append(items, map[string]interface{}{"#element": n.Name})
+		case n.Comment:
+			items = append(items, map[string]interface{}{"#comment": n.Text})
+		case n.CDATA:
+			cdata.WriteString(n.Text)
+			hasCDATA = true
+			items = append(items, map[string]interface{}{"#cdata": n.Text})
+		default:
+			text.WriteString(n.Text)
+			items = append(items, map[string]interface{}{"#text": n.Text})
+		}
+	}
+	if t := strings.TrimSpace(text.String()); t != "" {
+		e.data["#text"] = t
+	}
+	if hasCDATA {
+		e.data["#cdata"] = cdata.String()
+	}
+	e.data["#content"] = items
+	return e
+}
+
+// remainingChildren returns the child elements not yet listed, by name,
+// skipping the first used[name] occurrences of each.
+func (e *Element) remainingChildren(used map[string]int) []ContentNode {
+	var nodes []ContentNode
+	for _, name := range childNames(e.data) {
+		for i := used[name]; ; i++ {
+			child, ok := occurrence(e.data[name], i)
+			if !ok {
+				break
+			}
+			nodes = append(nodes, ContentNode{Name: name, Element: e.contentChild(child, name)})
+		}
+	}
+	return nodes
+}
+
+// contentChild returns the Element for a child value. Values that are not
+// maps, such as the text of a hand-built child, are wrapped in a new map.
+func (e *Element) contentChild(v interface{}, name string) *Element {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		m = map[string]interface{}{}
+		if v != nil {
+			m["#text"] = v
+		}
+	}
+	return e.child(m, name)
+}
+
+// childNames returns the sorted child element keys of m.
+func childNames(m map[string]interface{}) []string {
+	var names []string
+	for k := range m {
+		if len(k) > 0 && k[0] != '@' && k[0] != '#' {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// occurrence returns the i-th element stored under a child key: an item of
+// a repeated element, or the value itself for i == 0.
+func occurrence(v interface{}, i int) (interface{}, bool) {
+	if items, ok := v.([]interface{}); ok {
+		if i < len(items) {
+			return items[i], true
+		}
+		return nil, false
+	}
+	return v, i == 0
+}
+
+// contentItem returns the key and value of a "#content" item, which is a
+// map or *OrderedMap with a single key.
+func contentItem(item interface{}) (string, interface{}) {
+	switch m := item.(type) {
+	case map[string]interface{}:
+		for k, v := range m {
+			return k, v
+		}
+	case *OrderedMap:
+		if m.Len() > 0 {
+			k := m.keys[0]
+			return k, m.values[k]
+		}
+	}
+	return "", nil
+}
+
+// textValue returns a text, CDATA or name value as a string.
+func textValue(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case Number:
+		return string(s)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// renderContent writes the "#content" order of an element: text runs,
+// CDATA sections and comments as they are, and each "#element" item as the next
+// occurrence of that child. Children it does not list follow in name
+// order. formatXML leaves content holding text without indentation, which
+// would change the text; comments alone do not prevent it.
+func (r *renderer) renderContent(content *ast.ArrayDataNode, props map[string]ast.SchemaNode, depth int) error {
+	buf := r.buf
+	used := make(map[string]int)
+	for _, item := range content.Elements() {
+		obj, ok := item.(*ast.ObjectNode)
+		if !ok {
+			continue
+		}
+		for key, value := range obj.Properties() {
+			literal, ok := value.(*ast.LiteralNode)
+			if !ok {
+				continue
+			}
+			switch key {
+			case "#text":
+				text, _, err := formatLiteral(literal.Value(), &r.opts)
+				if err != nil {
+					return err
+				}
+				r.writeText(literal.Value(), text)
+			case "#cdata":
+				buf.Write(appendCDATA(nil, textValue(literal.Value())))
+			case "#comment":
+				buf.Write(appendComment(nil, textValue(literal.Value())))
+			case "#element":
+				name := textValue(literal.Value())
+				child, ok := nodeOccurrence(props[name], used[name])
+				if !ok {
+					continue
+				}
+				used[name]++
+				if err := r.renderNode(child, depth+1, name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	var names []string
+	for key := range props {
+		if !strings.HasPrefix(key, "@") && !strings.HasPrefix(key, "#") {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for i := used[name]; ; i++ {
+			child, ok := nodeOccurrence(props[name], i)
+			if !ok {
+				break
+			}
+			if err := r.renderNode(child, depth+1, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// nodeOccurrence is occurrence for AST nodes.
+func nodeOccurrence(node ast.SchemaNode, i int) (ast.SchemaNode, bool) {
+	if arr, ok := node.(*ast.ArrayDataNode); ok {
+		if elems := arr.Elements(); i < len(elems) {
+			return elems[i], true
+		}
+		return nil, false
+	}
+	return node, node != nil && i == 0
+}
+
+// appendContent writes the "#content" order of m, like renderContent.
+func (m *OrderedMap) appendContent(es *encodeState, buf []byte, content []interface{}) ([]byte, error) {
+	var last lastEncoder
+	used := make(map[string]int)
+	appendChild := func(buf []byte, child interface{}, name string) ([]byte, error) {
+		if child == nil {
+			return appendEmptyElement(buf, name), nil
+		}
+		return last.encodeDynamic(es, buf, reflect.ValueOf(child), name)
+	}
+
+	var err error
+	for _, item := range content {
+		key, value := contentItem(item)
+		switch key {
+		case "#text", "#cdata":
+			if value == nil {
+				continue
+			}
+			text, _, err := formatText(es, reflect.ValueOf(value))
+			if err != nil {
+				return buf, err
+			}
+			if key == "#text" {
+				buf = AppendEscapedText(buf, text)
+			} else {
+				buf = appendCDATA(buf, text)
+			}
+		case "#comment":
+			buf = appendComment(buf, textValue(value))
+		case "#element":
+			name := textValue(value)
+			child, ok := m.values[name]
+			if !ok {
+				continue
+			}
+			if items, isList := child.([]interface{}); isList {
+				if used[name] >= len(items) {
+					continue
+				}
+				child = items[used[name]]
+			} else if used[name] > 0 {
+				continue
+			}
+			used[name]++
+			if buf, err = appendChild(buf, child, name); err != nil {
+				return buf, err
+			}
+		}
+	}
+
+	for _, k := range m.keys {
+		if len(k) == 0 || k[0] == '@' || k[0] == '#' {
+			continue
+		}
+		child := m.values[k]
+		if items, isList := child.([]interface{}); isList {
+			for i := used[k]; i < len(items); i++ {
+				if buf, err = appendChild(buf, items[i], k); err != nil {
+					return buf, err
+				}
+			}
+		} else if used[k] == 0 {
+			if buf, err = appendChild(buf, child, k); err != nil {
+				return buf, err
+			}
+		}
+	}
+	return buf, nil
+}
@@ -0,0 +1,193 @@
+package xml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWithMixedContent_RoundTrip(t *testing.T) {
+	tests := []string{
+		`<p>Hello <b>world</b>!</p>`,
+		`<p>Say <em>a</em> and <b>b</b> then <em>c</em> &amp; done</p>`,
+		`<p>x <![CDATA[<raw>]]> y</p>`,
+		`<doc><a>1</a><b>2</b><a>3</a></doc>`,
+		`<p class="note">See <a href="/x">this</a> <a href="/y">that</a>.</p>`,
+	}
+	for _, doc := range tests {
+		elem, err := ParseElementWithOptions(doc, WithMixedContent())
+		if err != nil {
+			t.Fatalf("%s: %v", doc, err)
+		}
+		node, err := ParseWithOptions(doc, WithMixedContent())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := RenderWithOptions(node, MarshalOptions{RootName: elem.Name()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != doc {
+			t.Errorf("Render\ngot  %s\nwant %s", got, doc)
+		}
+
+		// Element.XML names the root "root".
+		out, _ := elem.XML(elem.Name())
+		if want := strings.Replace(strings.Replace(doc, "<"+elem.Name(), "<root", 1), "</"+elem.Name()+">", "</root>", 1); out != want {
+			t.Errorf("Element.XML\ngot  %s\nwant %s", out, want)
+		}
+
+		var v interface{}
+		if err := UnmarshalWithOptions([]byte(doc), &v, WithMixedContent(), WithPreserveOrder()); err != nil {
+			t.Fatal(err)
+		}
+		out2, err := MarshalWithOptions(v, MarshalOptions{RootName: elem.Name()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out2) != doc {
+			t.Errorf("OrderedMap\ngot  %s\nwant %s", out2, doc)
+		}
+	}
+}
+
+func TestWithMixedContent_Map(t *testing.T) {
+	elem, err := ParseElementWithOptions(`<p>Hello <b>world</b>!</p>`, WithMixedContent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := elem.GetText(); text != "Hello !" {
+		t.Errorf("text = %q, want all of the text", text)
+	}
+	if b, ok := elem.GetChild("b"); !ok {
+		t.Error("child b missing")
+	} else if text, _ := b.GetText(); text != "world" {
+		t.Errorf("b = %q", text)
+	}
+
+	// Layout whitespace between child elements is not recorded, and
+	// elements with a single item get no order.
+	plain, _ := ParseElementWithOptions("<r>\n  <b>text</b>\n  <a>1</a>\n</r>", WithMixedContent())
+	order, _ := plain.Get("#content")
+	if items, ok := order.([]interface{}); !ok || len(items) != 2 {
+		t.Errorf("#content = %v, want the two children", order)
+	}
+	if b, _ := plain.GetChild("b"); b.Has("#content") {
+		t.Error("text-only element has #content")
+	}
+
+	// Without the option nothing changes.
+	elem, _ = ParseElement(`<p>Hello <b>world</b>!</p>`)
+	if elem.Has("#content") {
+		t.Error("#content recorded without WithMixedContent")
+	}
+}
+
+func TestElement_Content(t *testing.T) {
+	elem, err := ParseElementWithOptions(`<p>Say <em>a</em> and <b>b</b> then <em>c</em></p>`, WithMixedContent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, n := range elem.Content() {
+		switch {
+		case n.Element != nil:
+			text, _ := n.Element.GetText()
+			got = append(got, "<"+n.Name+">"+text)
+		default:
+			got = append(got, n.Text)
+		}
+	}
+	want := []string{"Say ", "<em>a", " and ", "<b>b", " then ", "<em>c"}
+	if !equalStrings(got, want) {
+		t.Errorf("Content = %q, want %q", got, want)
+	}
+
+	// Children share data with the element.
+	elem.Content()[1].Element.Attr("id", "1")
+	if out, _ := elem.XML("p"); !strings.Contains(out, `<em id="1">a</em>`) {
+		t.Errorf("got %s", out)
+	}
+
+	// Without a recorded order: text, CDATA, then children by name.
+	plain := NewElement().Text("t").CDATA("c").ChildText("z", "1").ChildText("a", "2")
+	var kinds []string
+	for _, n := range plain.Content() {
+		switch {
+		case n.Element != nil:
+			kinds = append(kinds, n.Name)
+		case n.CDATA:
+			kinds = append(kinds, "cdata:"+n.Text)
+		default:
+			kinds = append(kinds, "text:"+n.Text)
+		}
+	}
+	if !equalStrings(kinds, []string{"text:t", "cdata:c", "a", "z"}) {
+		t.Errorf("Content = %v", kinds)
+	}
+
+	// Text drops the recorded order.
+	if elem.Text("plain").Has("#content") {
+		t.Error("Text kept #content")
+	}
+}
+
+func TestElement_SetContent(t *testing.T) {
+	p := NewElement().Attr("id", "1").Text("old").SetContent(
+		ContentNode{Text: "Hello "},
+		ContentNode{Name: "b", Element: NewElement().Text("big")},
+		ContentNode{Text: " and "},
+		ContentNode{Name: "b", Element: NewElement().Text("bold").Freeze()},
+		ContentNode{CDATA: true, Text: "<x>"},
+	)
+	out, err := p.XML("p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<root id="1">Hello <b>big</b> and <b>bold</b><![CDATA[<x>]]></root>`; out != want {
+		t.Errorf("got  %s\nwant %s", out, want)
+	}
+	if text, _ := p.GetText(); text != "Hello  and" {
+		t.Errorf("text = %q", text)
+	}
+
+	// Children removed after the fact are skipped, new ones follow.
+	p = p.Remove("b").ChildText("i", "x")
+	if out, _ := p.XML("p"); out != `<root id="1">Hello  and <![CDATA[<x>]]><i>x</i></root>` {
+		t.Errorf("got %s", out)
+	}
+	if n := len(p.Content()); n != 4 {
+		t.Errorf("len(Content) = %d, want 4", n)
+	}
+}
+
+func TestWithMixedContent_Indent(t *testing.T) {
+	node, err := ParseWithOptions(`<doc><a>1</a><b>2</b><a>3</a><p>Hi <i>x</i></p></doc>`, WithMixedContent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := RenderIndent(node, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<root>\n  <a>1</a>\n  <b>2</b>\n  <a>3</a>\n  <p>Hi <i>x</i></p>\n</root>"
+	if string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWithMixedContent_Unsupported(t *testing.T) {
+	if _, err := ParseElementWithOptions(`<p>a<b/>c</p>`, WithMixedContent(), WithMaxDepth(1, DepthSummaryCount)); err == nil {
+		t.Error("expected an error with WithMaxDepth")
+	}
+	var v interface{}
+	if err := UnmarshalWithOptions([]byte(`<p>a<b/>c</p>`), &v, WithMixedContent(), WithElementFilter("p")); err == nil {
+		t.Error("expected an error with WithElementFilter")
+	}
+	// Typed targets ignore the option.
+	var s struct {
+		B string `xml:"b"`
+	}
+	if err := UnmarshalWithOptions([]byte(`<p>a<b>x</b>c</p>`), &s, WithMixedContent()); err != nil || s.B != "x" {
+		t.Errorf("struct target: %v %+v", err, s)
+	}
+}
@@ -0,0 +1,202 @@
+package xml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// isValidXMLName reports whether name matches the XML 1.0 Name production.
+//
+//	NameStartChar ::= ":" | [A-Z] | "_" | [a-z] | [#xC0-#xD6] | [#xD8-#xF6] | [#xF8-#x2FF] |
+//	                  [#x370-#x37D] | [#x37F-#x1FFF] | [#x200C-#x200D] | [#x2070-#x218F] |
+//	                  [#x2C00-#x2FEF] | [#x3001-#xD7FF] | [#xF900-#xFDCF] | [#xFDF0-#xFFFD] |
+//	                  [#x10000-#xEFFFF]
+//	NameChar      ::= NameStartChar | "-" | "." | [0-9] | #xB7 | [#x0300-#x036F] | [#x203F-#x2040]
+func isValidXMLName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r == utf8.RuneError {
+			return false
+		}
+		if i == 0 {
+			if !isNameStartRune(r) {
+				return false
+			}
+			continue
+		}
+		if !isNameRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isNameStartRune reports whether r may start an XML name.
+func isNameStartRune(r rune) bool {
+	switch {
+	case r == ':' || r == '_' || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z'):
+		return true
+	case r >= 0xC0 && r <= 0xD6, r >= 0xD8 && r <= 0xF6, r >= 0xF8 && r <= 0x2FF,
+		r >= 0x370 && r <= 0x37D, r >= 0x37F && r <= 0x1FFF, r >= 0x200C && r <= 0x200D,
+		r >= 0x2070 && r <= 0x218F, r >= 0x2C00 && r <= 0x2FEF, r >= 0x3001 && r <= 0xD7FF,
+		r >= 0xF900 && r <= 0xFDCF, r >= 0xFDF0 && r <= 0xFFFD, r >= 0x10000 && r <= 0xEFFFF:
+		return true
+	}
+	return false
+}
+
+// isNameRune reports whether r may appear after the first character of an XML name.
+func isNameRune(r rune) bool {
+	if isNameStartRune(r) {
+		return true
+	}
+	return r == '-' || r == '.' || (r >= '0' && r <= '9') || r == 0xB7 ||
+		(r >= 0x300 && r <= 0x36F) || (r >= 0x203F && r <= 0x2040)
+}
+
+// NameEscaper converts arbitrary map keys, such as JSON object keys, into
+// valid XML element names and back. Escape and Unescape are exact inverses,
+// so keys like "0", "foo bar" or "a:b" survive a round trip through XML.
+//
+// Keys that are already valid names, other than ones containing "_x" or
+// "_U", are left unchanged. Otherwise:
+//   - a key starting with a digit, '-' or '.' gets Prefix prepended, so "0"
+//     becomes "_0" with the default prefix
+//   - any other invalid character, and ':', is written as "_xHHHH_" (or
+//     "_UHHHHHHHH_" above U+FFFF), so "foo bar" becomes "foo_x0020_bar"
+//   - the empty key becomes "_x_"
+//
+// The "_x", "_U" and Prefix sequences in original keys are escaped in turn,
+// which keeps the mapping reversible without extra metadata.
+type NameEscaper struct {
+	// Prefix is prepended to keys whose first character may appear in a
+	// name but not start one. It must be a valid name. If empty, the first
+	// character is hex-escaped instead.
+	Prefix string
+}
+
+// DefaultNameEscaper escapes with the "_" prefix.
+var DefaultNameEscaper = NameEscaper{Prefix: "_"}
+
+// EscapeName escapes key with DefaultNameEscaper.
+func EscapeName(key string) string {
+	return DefaultNameEscaper.Escape(key)
+}
+
+// UnescapeName reverses EscapeName.
+func UnescapeName(name string) string {
+	return DefaultNameEscaper.Unescape(name)
+}
+
+// Escape returns key as a valid XML name.
+func (e NameEscaper) Escape(key string) string {
+	if key == "" {
+		return "_x_"
+	}
+	if !e.needsEscape(key) {
+		return key
+	}
+
+	var b strings.Builder
+	for i, r := range key {
+		if i == 0 {
+			switch {
+			case e.Prefix != "" && isNameRune(r) && !isNameStartRune(r):
+				b.WriteString(e.Prefix)
+				b.WriteRune(r)
+				continue
+			case e.Prefix != "" && strings.HasPrefix(key, e.Prefix) && e.stripsPrefix(key):
+				// The key would read back as prefixed; escape its first rune.
+				writeHexRune(&b, r)
+				continue
+			}
+		}
+		switch {
+		case r == '_' && (strings.HasPrefix(key[i+1:], "x") || strings.HasPrefix(key[i+1:], "U")):
+			writeHexRune(&b, r)
+		case r == ':' || r == utf8.RuneError:
+			writeHexRune(&b, r)
+		case i == 0 && !isNameStartRune(r), i > 0 && !isNameRune(r):
+			writeHexRune(&b, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Unescape reverses Escape.
+func (e NameEscaper) Unescape(name string) string {
+	if name == "_x_" {
+		return ""
+	}
+	if e.stripsPrefix(name) {
+		name = name[len(e.Prefix):]
+	}
+	if !strings.Contains(name, "_x") && !strings.Contains(name, "_U") {
+		return name
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(name); {
+		if r, n := readHexRune(name[i:]); n > 0 {
+			b.WriteRune(r)
+			i += n
+			continue
+		}
+		b.WriteByte(name[i])
+		i++
+	}
+	return b.String()
+}
+
+// needsEscape reports whether key differs from its escaped form.
+func (e NameEscaper) needsEscape(key string) bool {
+	return !isValidXMLName(key) || strings.ContainsAny(key, ":") ||
+		strings.Contains(key, "_x") || strings.Contains(key, "_U") || e.stripsPrefix(key)
+}
+
+// stripsPrefix reports whether Unescape treats s as carrying the prefix:
+// it starts with Prefix followed by a rune that cannot start a name.
+func (e NameEscaper) stripsPrefix(s string) bool {
+	if e.Prefix == "" || !strings.HasPrefix(s, e.Prefix) {
+		return false
+	}
+	r, size := utf8.DecodeRuneInString(s[len(e.Prefix):])
+	return size > 0 && isNameRune(r) && !isNameStartRune(r)
+}
+
+// writeHexRune writes r as "_xHHHH_" or "_UHHHHHHHH_".
+func writeHexRune(b *strings.Builder, r rune) {
+	if r > 0xFFFF {
+		fmt.Fprintf(b, "_U%08X_", r)
+		return
+	}
+	fmt.Fprintf(b, "_x%04X_", r)
+}
+
+// readHexRune decodes a "_xHHHH_" or "_UHHHHHHHH_" sequence at the start of
+// s, returning the rune and the number of bytes read, or 0 if there is none.
+func readHexRune(s string) (rune, int) {
+	var digits int
+	switch {
+	case strings.HasPrefix(s, "_x"):
+		digits = 4
+	case strings.HasPrefix(s, "_U"):
+		digits = 8
+	default:
+		return 0, 0
+	}
+	if len(s) < digits+3 || s[digits+2] != '_' {
+		return 0, 0
+	}
+	n, err := strconv.ParseUint(s[2:digits+2], 16, 32)
+	if err != nil {
+		return 0, 0
+	}
+	return rune(n), digits + 3
+}
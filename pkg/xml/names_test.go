@@ -0,0 +1,89 @@
+package xml
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestEscapeName(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"name", "name"},
+		{"0", "_0"},
+		{"123abc", "_123abc"},
+		{"-x", "_-x"},
+		{"foo bar", "foo_x0020_bar"},
+		{"a:b", "a_x003A_b"},
+		{"", "_x_"},
+		{" lead", "_x0020_lead"},
+		{"_0", "_x005F_0"},
+		{"_x0020_", "_x005F_x0020_"},
+		{"_under", "_under"},
+		{"_", "_"},
+		{"a/b", "a_x002F_b"},
+		{"emoji😀", "emoji😀"},
+		{"pua\U000F0000", "pua_U000F0000_"},
+		{"日本", "日本"},
+	}
+	for _, tt := range tests {
+		got := EscapeName(tt.key)
+		if got != tt.want {
+			t.Errorf("EscapeName(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+		if !isValidXMLName(got) {
+			t.Errorf("EscapeName(%q) = %q is not a valid name", tt.key, got)
+		}
+		if back := UnescapeName(got); back != tt.key {
+			t.Errorf("UnescapeName(%q) = %q, want %q", got, back, tt.key)
+		}
+	}
+}
+
+func TestNameEscaper_CustomPrefix(t *testing.T) {
+	for _, e := range []NameEscaper{{Prefix: "n"}, {Prefix: "num-"}, {}} {
+		for _, key := range []string{"0", "n0", "num-1", "n", "9 lives", "_x", "x_U1", "plain", "", "é"} {
+			name := e.Escape(key)
+			if !isValidXMLName(name) {
+				t.Errorf("prefix %q: Escape(%q) = %q is not a valid name", e.Prefix, key, name)
+			}
+			if back := e.Unescape(name); back != key {
+				t.Errorf("prefix %q: Unescape(Escape(%q)) = %q via %q", e.Prefix, key, back, name)
+			}
+		}
+	}
+	if got := (NameEscaper{Prefix: "n"}).Escape("0"); got != "n0" {
+		t.Errorf("Escape(0) = %q, want n0", got)
+	}
+	if got := (NameEscaper{}).Escape("0"); got != "_x0030_" {
+		t.Errorf("Escape(0) without prefix = %q, want _x0030_", got)
+	}
+}
+
+func TestUnescapeName_Malformed(t *testing.T) {
+	// Sequences that are not complete escapes are kept literally.
+	for _, name := range []string{"a_x12", "a_x12G4_", "a_U1234_"} {
+		if got := UnescapeName(name); got != name {
+			t.Errorf("UnescapeName(%q) = %q", name, got)
+		}
+	}
+}
+
+func FuzzEscapeName(f *testing.F) {
+	for _, seed := range []string{"", "0", "foo bar", "_x0020_", "_0", "a:b", "😀"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, key string) {
+		if !utf8.ValidString(key) {
+			return
+		}
+		name := EscapeName(key)
+		if !isValidXMLName(name) {
+			t.Fatalf("EscapeName(%q) = %q is not a valid name", key, name)
+		}
+		if back := UnescapeName(name); back != key {
+			t.Fatalf("round trip of %q gave %q via %q", key, back, name)
+		}
+	})
+}
@@ -0,0 +1,173 @@
+package xml
+
+import (
+	"sort"
+	"strings"
+)
+
+// XMLNamespace is the namespace permanently bound to the "xml" prefix.
+const XMLNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// XMLNSNamespace is the namespace of xmlns attributes.
+const XMLNSNamespace = "http://www.w3.org/2000/xmlns/"
+
+// Name is a namespace-qualified name: the namespace URI a prefix resolves
+// to and the local part of the name. Space is "" for names in no namespace.
+type Name struct {
+	Space string
+	Local string
+}
+
+// String returns the name in Clark notation, "{uri}local", or just the
+// local name when it is in no namespace.
+func (n Name) String() string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return "{" + n.Space + "}" + n.Local
+}
+
+// SplitName splits a qualified name such as "soap:Body" into its prefix and
+// local part. Names without a colon have an empty prefix.
+func SplitName(qname string) (prefix, local string) {
+	if i := strings.IndexByte(qname, ':'); i > 0 && i < len(qname)-1 {
+		return qname[:i], qname[i+1:]
+	}
+	return "", qname
+}
+
+// NamespaceContext maps the prefixes in scope at an element to their
+// namespace URIs. The default namespace is stored under "".
+type NamespaceContext map[string]string
+
+// Namespaces returns the namespace declarations in scope at e: its own
+// xmlns and xmlns:prefix attributes and those of its ancestors, the nearest
+// declaration of each prefix winning. The xml prefix is always bound, and
+// an empty xmlns="" undeclares the default namespace.
+//
+// Scope follows the element's ancestry, so it is complete for elements
+// reached from a root returned by ParseElement.
+func (e *Element) Namespaces() NamespaceContext {
+	ctx := NamespaceContext{"xml": XMLNamespace}
+	var chain []*Element
+	for cur := e; cur != nil; cur = cur.parent {
+		chain = append(chain, cur)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		for key, v := range chain[i].data {
+			prefix, ok := xmlnsPrefix(key)
+			if !ok {
+				continue
+			}
+			uri, _ := v.(string)
+			if uri == "" {
+				delete(ctx, prefix)
+			} else {
+				ctx[prefix] = uri
+			}
+		}
+	}
+	return ctx
+}
+
+// LookupNamespace returns the namespace URI bound to prefix at e, "" for
+// the default namespace. ok is false if the prefix is not bound.
+func (e *Element) LookupNamespace(prefix string) (uri string, ok bool) {
+	if prefix == "xml" {
+		return XMLNamespace, true
+	}
+	key := "@xmlns"
+	if prefix != "" {
+		key += ":" + prefix
+	}
+	for cur := e; cur != nil; cur = cur.parent {
+		if v, found := cur.data[key]; found {
+			uri, _ := v.(string)
+			return uri, uri != ""
+		}
+	}
+	return "", false
+}
+
+// LocalName returns the element name without its prefix.
+func (e *Element) LocalName() string {
+	_, local := SplitName(e.name)
+	return local
+}
+
+// NamespaceURI returns the namespace of the element, resolved from its
+// prefix, or the default namespace for an unprefixed name. It is "" if the
+// element is in no namespace or its prefix is not declared.
+func (e *Element) NamespaceURI() string {
+	prefix, _ := SplitName(e.name)
+	uri, _ := e.LookupNamespace(prefix)
+	return uri
+}
+
+// QName returns the element's resolved name.
+func (e *Element) QName() Name {
+	return Name{Space: e.NamespaceURI(), Local: e.LocalName()}
+}
+
+// ChildrenNS returns the child elements whose resolved name is {space}local,
+// whatever prefix the document uses for space. They are ordered like the
+// results of Query.Find.
+func (e *Element) ChildrenNS(space, local string) []*Element {
+	var out []*Element
+	for _, child := range childElements(e) {
+		if child.LocalName() == local && child.NamespaceURI() == space {
+			out = append(out, child)
+		}
+	}
+	return out
+}
+
+// ChildNS returns the first child element whose resolved name is
+// {space}local. Returns nil and false if there is none.
+func (e *Element) ChildNS(space, local string) (*Element, bool) {
+	children := e.ChildrenNS(space, local)
+	if len(children) == 0 {
+		return nil, false
+	}
+	return children[0], true
+}
+
+// GetAttrNS gets the value of the attribute whose resolved name is
+// {space}local. Unprefixed attributes are in no namespace; the default
+// namespace does not apply to them.
+func (e *Element) GetAttrNS(space, local string) (string, bool) {
+	names := e.Attrs()
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := xmlnsPrefix("@" + name); ok {
+			continue
+		}
+		prefix, l := SplitName(name)
+		if l != local {
+			continue
+		}
+		uri := ""
+		if prefix != "" {
+			var ok bool
+			if uri, ok = e.LookupNamespace(prefix); !ok {
+				continue
+			}
+		}
+		if uri == space {
+			return e.GetAttr(name)
+		}
+	}
+	return "", false
+}
+
+// xmlnsPrefix reports whether the map key is a namespace declaration and
+// returns the prefix it declares, "" for the default namespace.
+func xmlnsPrefix(key string) (string, bool) {
+	if key == "@xmlns" {
+		return "", true
+	}
+	if prefix, ok := strings.CutPrefix(key, "@xmlns:"); ok {
+		return prefix, true
+	}
+	return "", false
+}
@@ -0,0 +1,61 @@
+package xml
+
+import (
+	"reflect"
+)
+
+// nsBinding is a namespace declaration in scope while encoding.
+type nsBinding struct {
+	prefix string
+	uri    string
+}
+
+// lookupNamespace returns the URI bound to prefix by the enclosing elements.
+func (es *encodeState) lookupNamespace(prefix string) (string, bool) {
+	for i := len(es.ns) - 1; i >= 0; i-- {
+		if es.ns[i].prefix == prefix {
+			return es.ns[i].uri, true
+		}
+	}
+	return "", false
+}
+
+// buildXMLNamespaceEncoder wraps enc, the encoder of a field tagged
+// "uri name", so that each element it writes declares namespace uri for the
+// prefix of name, or as the default namespace for an unprefixed name. The
+// declaration is left out where an enclosing element already made it.
+// Slices and arrays declare the namespace on every item.
+func buildXMLNamespaceEncoder(t reflect.Type, enc xmlEncoderFunc, name, uri string) xmlEncoderFunc {
+	prefix, _ := SplitName(name)
+	binding := nsBinding{prefix: prefix, uri: uri}
+
+	item := func(itemEnc xmlEncoderFunc) xmlEncoderFunc {
+		return func(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+			if bound, ok := es.lookupNamespace(binding.prefix); ok && bound == uri {
+				return itemEnc(es, buf, rv, elemName)
+			}
+			start := len(buf)
+			// The declaration is inserted into the start tag afterwards, so
+			// the element must stay in buf.
+			es.holdFlush++
+			es.ns = append(es.ns, binding)
+			buf, err := itemEnc(es, buf, rv, elemName)
+			es.ns = es.ns[:len(es.ns)-1]
+			es.holdFlush--
+			if err != nil {
+				return buf, err
+			}
+			markup, _ := namespaceMarkup(name, uri, &es.opts)
+			return insertStartTagAttrs(buf, start, es.writtenName(start, elemName), markup), nil
+		}
+	}
+
+	switch {
+	case t.Kind() == reflect.Slice:
+		return buildXMLSliceEncoderWith(item(nestedEncoderForType(t.Elem())))
+	case t.Kind() == reflect.Array:
+		return buildXMLArrayEncoderWith(item(nestedEncoderForType(t.Elem())))
+	default:
+		return item(enc)
+	}
+}
@@ -0,0 +1,116 @@
+package xml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const soapNS = "http://schemas.xmlsoap.org/soap/envelope/"
+
+type soapFault struct {
+	Code   string `xml:"faultcode"`
+	String string `xml:"faultstring"`
+}
+
+type soapBody struct {
+	Fault *soapFault `xml:"http://schemas.xmlsoap.org/soap/envelope/ soap:Fault"`
+}
+
+type soapEnvelope struct {
+	Header string   `xml:"http://schemas.xmlsoap.org/soap/envelope/ soap:Header,omitempty"`
+	Body   soapBody `xml:"http://schemas.xmlsoap.org/soap/envelope/ soap:Body"`
+}
+
+func TestMarshal_Namespace(t *testing.T) {
+	env := soapEnvelope{Body: soapBody{Fault: &soapFault{Code: "soap:Server", String: "boom"}}}
+	data, err := Marshal(env)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	// The Fault inherits the declaration made on Body.
+	want := `<soapEnvelope><soap:Body xmlns:soap="` + soapNS + `"><soap:Fault>` +
+		`<faultcode>soap:Server</faultcode><faultstring>boom</faultstring></soap:Fault></soap:Body></soapEnvelope>`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+
+	var got soapEnvelope
+	if err := Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if got.Body.Fault == nil || *got.Body.Fault != *env.Body.Fault {
+		t.Errorf("round trip lost the fault: %+v", got.Body.Fault)
+	}
+
+	// The resolved names are visible to namespace-aware readers.
+	root, err := ParseElement(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, ok := root.ChildNS(soapNS, "Body")
+	if !ok {
+		t.Fatal("no {soap}Body")
+	}
+	if _, ok := body.ChildNS(soapNS, "Fault"); !ok {
+		t.Error("no {soap}Fault")
+	}
+}
+
+func TestMarshal_DefaultNamespace(t *testing.T) {
+	type entry struct {
+		Title string `xml:"title"`
+	}
+	type feed struct {
+		Entries []entry   `xml:"http://www.w3.org/2005/Atom entry"`
+		Links   [2]string `xml:"urn:links link"`
+	}
+	data, err := Marshal(feed{Entries: []entry{{"a"}, {"b"}}, Links: [2]string{"x", "y"}})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `<feed><entry xmlns="http://www.w3.org/2005/Atom"><title>a</title></entry>` +
+		`<entry xmlns="http://www.w3.org/2005/Atom"><title>b</title></entry>` +
+		`<link xmlns="urn:links">x</link><link xmlns="urn:links">y</link></feed>`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+
+	var bad struct {
+		Lang string `xml:"urn:x lang,attr"`
+	}
+	if _, err := Marshal(bad); err == nil || !strings.Contains(err.Error(), "only to elements") {
+		t.Errorf("got %v, want a namespace error for an attribute", err)
+	}
+}
+
+func TestEncoder_NamespaceStreaming(t *testing.T) {
+	type item struct {
+		V string `xml:"v"`
+	}
+	type list struct {
+		Items []item `xml:"urn:items i:item"`
+	}
+	v := list{Items: make([]item, 5000)}
+	for i := range v.Items {
+		v.Items[i].V = strings.Repeat("x", 20)
+	}
+	want, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.Encode(v); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(want) {
+		t.Error("streamed output differs from Marshal")
+	}
+	if n := strings.Count(buf.String(), `xmlns:i="urn:items"`); n != 5000 {
+		t.Errorf("got %d declarations, want 5000", n)
+	}
+}
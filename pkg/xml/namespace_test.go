@@ -0,0 +1,116 @@
+package xml
+
+import (
+	"reflect"
+	"testing"
+)
+
+const nsFeed = `<feed xmlns="http://www.w3.org/2005/Atom" xmlns:m="urn:media" xmlns:x="urn:ext">
+  <entry m:id="1"><title>A</title><m:thumb url="a.png"/></entry>
+  <entry><title>B</title><media:thumb xmlns:media="urn:media" url="b.png"/></entry>
+  <x:meta><plain xmlns="">none</plain></x:meta>
+</feed>`
+
+func TestElementNamespaces(t *testing.T) {
+	root, err := ParseElement(nsFeed)
+	if err != nil {
+		t.Fatalf("ParseElement failed: %v", err)
+	}
+	if got := root.QName(); got != (Name{Space: "http://www.w3.org/2005/Atom", Local: "feed"}) {
+		t.Errorf("root QName = %v", got)
+	}
+
+	entries := root.ChildrenNS("http://www.w3.org/2005/Atom", "entry")
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	for i, want := range []string{"a.png", "b.png"} {
+		thumb, ok := entries[i].ChildNS("urn:media", "thumb")
+		if !ok {
+			t.Fatalf("entry %d: no {urn:media}thumb", i)
+		}
+		if url, _ := thumb.GetAttr("url"); url != want {
+			t.Errorf("entry %d: url = %q, want %q", i, url, want)
+		}
+	}
+	if id, ok := entries[0].GetAttrNS("urn:media", "id"); !ok || id != "1" {
+		t.Errorf("GetAttrNS = %q, %v", id, ok)
+	}
+	if _, ok := entries[0].GetAttrNS("", "id"); ok {
+		t.Error("a prefixed attribute is not in no namespace")
+	}
+
+	meta, _ := root.ChildNS("urn:ext", "meta")
+	plain, ok := meta.ChildNS("", "plain")
+	if !ok {
+		t.Fatal("xmlns=\"\" must undeclare the default namespace")
+	}
+	if uri, ok := plain.LookupNamespace(""); ok || uri != "" {
+		t.Errorf("LookupNamespace(\"\") = %q, %v", uri, ok)
+	}
+	if uri, _ := plain.LookupNamespace("xml"); uri != XMLNamespace {
+		t.Errorf("xml prefix bound to %q", uri)
+	}
+
+	want := NamespaceContext{"xml": XMLNamespace, "m": "urn:media", "x": "urn:ext"}
+	if got := plain.Namespaces(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Namespaces = %v, want %v", got, want)
+	}
+}
+
+func TestSplitName(t *testing.T) {
+	tests := []struct{ in, prefix, local string }{
+		{"soap:Body", "soap", "Body"},
+		{"Body", "", "Body"},
+		{":x", "", ":x"},
+		{"x:", "", "x:"},
+	}
+	for _, tt := range tests {
+		if p, l := SplitName(tt.in); p != tt.prefix || l != tt.local {
+			t.Errorf("SplitName(%q) = %q, %q", tt.in, p, l)
+		}
+	}
+	if s := (Name{Space: "urn:a", Local: "b"}).String(); s != "{urn:a}b" {
+		t.Errorf("String = %q", s)
+	}
+}
+
+func TestCompileQueryNS(t *testing.T) {
+	root, err := ParseElement(nsFeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns := NamespaceContext{"a": "http://www.w3.org/2005/Atom", "media": "urn:media"}
+
+	q, err := CompileQueryNS("/a:feed/a:entry/media:thumb/@url", ns)
+	if err != nil {
+		t.Fatalf("CompileQueryNS failed: %v", err)
+	}
+	if got := q.Values(root); !reflect.DeepEqual(got, []string{"a.png", "b.png"}) {
+		t.Errorf("Values = %q", got)
+	}
+
+	// The default namespace applies to unprefixed query names.
+	q = mustCompileQueryNS(t, "//entry/title", NamespaceContext{"": "http://www.w3.org/2005/Atom"})
+	if got := q.Values(root); !reflect.DeepEqual(got, []string{"A", "B"}) {
+		t.Errorf("Values = %q", got)
+	}
+	// Without it, unprefixed names are in no namespace.
+	q = mustCompileQueryNS(t, "//plain", nil)
+	if got := q.Values(root); !reflect.DeepEqual(got, []string{"none"}) {
+		t.Errorf("Values = %q", got)
+	}
+
+	if _, err := CompileQueryNS("/x:feed", ns); err == nil {
+		t.Error("expected an error for an unbound prefix")
+	}
+}
+
+func mustCompileQueryNS(t *testing.T, expr string, ns NamespaceContext) *Query {
+	t.Helper()
+	q, err := CompileQueryNS(expr, ns)
+	if err != nil {
+		t.Fatalf("CompileQueryNS(%q) failed: %v", expr, err)
+	}
+	return q
+}
@@ -0,0 +1,138 @@
+package xml
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewlineNormalization(t *testing.T) {
+	input := "<r a=\"1\r\n2\">x\r\ny</r>"
+
+	var v map[string]interface{}
+	if err := Unmarshal([]byte(input), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v["@a"] != "1\n2" || v["#text"] != "x\ny" {
+		t.Errorf("Unmarshal = %q", v)
+	}
+	elem, err := ParseElement(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := elem.GetText(); text != "x\ny" {
+		t.Errorf("ParseElement text = %q", text)
+	}
+
+	v = nil
+	if err := UnmarshalWithOptions([]byte(input), &v, WithRawNewlines()); err != nil {
+		t.Fatal(err)
+	}
+	if v["@a"] != "1\r\n2" || v["#text"] != "x\r\ny" {
+		t.Errorf("UnmarshalWithOptions = %q", v)
+	}
+	elem, err = ParseElementWithOptions(input, WithRawNewlines())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := elem.GetText(); text != "x\r\ny" {
+		t.Errorf("ParseElementWithOptions text = %q", text)
+	}
+}
+
+func TestNewlineNormalization_MarshalRoundTrip(t *testing.T) {
+	type doc struct {
+		A    string `xml:"a,attr"`
+		Text string `xml:"t"`
+	}
+	in := doc{A: "1\r\n2", Text: "x\ry"}
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out doc
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Errorf("round trip of %s = %q, want %q", data, out, in)
+	}
+
+	node, err := Parse("<r>x&#13;y</r>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := Render(node); string(got) != "<root>x&#xD;y</root>" {
+		t.Errorf("Render = %s", got)
+	}
+}
+
+// decoderTokens reads every token of input with a Decoder.
+func decoderTokens(t *testing.T, input string, raw bool) []Token {
+	t.Helper()
+	d := NewDecoder(strings.NewReader(input))
+	d.SetRawNewlines(raw)
+	var toks []Token
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return toks
+		}
+		if err != nil {
+			t.Fatalf("Token failed: %v", err)
+		}
+		toks = append(toks, tok)
+	}
+}
+
+func TestNewlineNormalization_Decoder(t *testing.T) {
+	input := "<r a=\"1\r\n2\r3\">x\r\ny\rz<![CDATA[d\r\ne]]></r>"
+
+	// The Decoder reads the same values as Parse.
+	node, err := Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ := NodeToInterface(node).(map[string]interface{})
+	want := []Token{
+		StartElement{Name: "r", Attr: []Attr{{Name: "a", Value: m["@a"].(string)}}},
+		CharData(m["#text"].(string)),
+		CharData(m["#cdata"].(string)),
+		EndElement{Name: "r"},
+	}
+	if got := decoderTokens(t, input, false); !reflect.DeepEqual(got, want) {
+		t.Errorf("Token = %#v\nwant %#v", got, want)
+	}
+	if m["@a"] != "1\n2\n3" || m["#text"] != "x\ny\nz" {
+		t.Errorf("Parse = %q", m)
+	}
+
+	want = []Token{
+		StartElement{Name: "r", Attr: []Attr{{Name: "a", Value: "1\r\n2\r3"}}},
+		CharData("x\r\ny\rz"),
+		CharData("d\r\ne"),
+		EndElement{Name: "r"},
+	}
+	if got := decoderTokens(t, input, true); !reflect.DeepEqual(got, want) {
+		t.Errorf("Token with SetRawNewlines = %#v\nwant %#v", got, want)
+	}
+
+	if got := decoderTokens(t, "<r><!--c\r\n--><?pi a\rb?></r>", false); !reflect.DeepEqual(got, []Token{
+		StartElement{Name: "r"}, Comment("c\n"), ProcInst{Target: "pi", Inst: []byte("a\nb")}, EndElement{Name: "r"},
+	}) {
+		t.Errorf("Token = %#v", got)
+	}
+
+	var v struct {
+		A    string `xml:"a,attr"`
+		Text string `xml:",chardata"`
+	}
+	d := NewDecoder(strings.NewReader(input))
+	if err := d.DecodeElement(&v, nil); err != nil {
+		t.Fatal(err)
+	}
+	if v.A != "1\n2\n3" || !strings.HasPrefix(v.Text, "x\ny\nz") {
+		t.Errorf("DecodeElement = %q", v)
+	}
+}
@@ -0,0 +1,124 @@
+package xml
+
+import (
+	"math/big"
+	"strconv"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+// Number is numeric text content kept exactly as written in the document.
+//
+// Parsing with WithUseNumber stores attribute values and text content that
+// look like numbers as Number instead of string. Because Number is never
+// routed through float64, values such as "1234567890.123456789" or "12.30"
+// render back unchanged after a parse-modify-render cycle, which matters for
+// financial and other decimal data.
+//
+// Use Rat for exact arithmetic, or Float64 and Int64 when precision loss is
+// acceptable.
+type Number string
+
+// String returns the literal text of the number.
+func (n Number) String() string {
+	return string(n)
+}
+
+// Float64 returns the number as a float64.
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// Int64 returns the number as an int64.
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+// Rat returns the exact value of the number. The result is false if n is
+// not a valid number.
+func (n Number) Rat() (*big.Rat, bool) {
+	return new(big.Rat).SetString(string(n))
+}
+
+// isNumber reports whether s is a decimal number, optionally with a sign and
+// an exponent: [+-]?(digits[.digits?]|.digits)([eE][+-]?digits)?.
+// Surrounding whitespace, hexadecimal, NaN and INF are not numbers.
+func isNumber(s string) bool {
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+		digits++
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+			digits++
+		}
+	}
+	if digits == 0 {
+		return false
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		exp := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+			exp++
+		}
+		if exp == 0 {
+			return false
+		}
+	}
+	return i == len(s)
+}
+
+// useNumbers replaces numeric attribute values and text content in a parsed
+// map representation with Number, in place.
+func useNumbers(v interface{}) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			if s, ok := child.(string); ok && (k == "#text" || (len(k) > 0 && k[0] == '@')) {
+				if isNumber(s) {
+					val[k] = Number(s)
+				}
+				continue
+			}
+			useNumbers(child)
+		}
+	case []interface{}:
+		for _, item := range val {
+			useNumbers(item)
+		}
+	}
+}
+
+// useNumberNodes replaces numeric attribute values and text content in a
+// parsed AST with Number literals, in place.
+func useNumberNodes(node ast.SchemaNode) {
+	switch n := node.(type) {
+	case *ast.ObjectNode:
+		props := n.Properties()
+		for k, child := range props {
+			if lit, ok := child.(*ast.LiteralNode); ok && (k == "#text" || (len(k) > 0 && k[0] == '@')) {
+				if s, ok := lit.Value().(string); ok && isNumber(s) {
+					props[k] = ast.NewLiteralNode(Number(s), lit.Position())
+				}
+				continue
+			}
+			useNumberNodes(child)
+		}
+	case *ast.ArrayDataNode:
+		for _, elem := range n.Elements() {
+			useNumberNodes(elem)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package xml
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+func TestIsNumber(t *testing.T) {
+	for _, s := range []string{"0", "-1", "+2", "12.30", ".5", "5.", "1e10", "-1.5E-3", "1234567890.123456789012"} {
+		if !isNumber(s) {
+			t.Errorf("isNumber(%q) = false, want true", s)
+		}
+	}
+	for _, s := range []string{"", "-", ".", "1e", "e5", " 1", "1 ", "0x1F", "NaN", "INF", "1.2.3", "12abc"} {
+		if isNumber(s) {
+			t.Errorf("isNumber(%q) = true, want false", s)
+		}
+	}
+}
+
+func TestNumber_Conversions(t *testing.T) {
+	n := Number("12.30")
+	if n.String() != "12.30" {
+		t.Errorf("String() = %q", n.String())
+	}
+	if f, err := n.Float64(); err != nil || f != 12.3 {
+		t.Errorf("Float64() = %v, %v", f, err)
+	}
+	if _, err := n.Int64(); err == nil {
+		t.Error("Int64() expected error for a fraction")
+	}
+	r, ok := n.Rat()
+	if !ok || r.FloatString(2) != "12.30" {
+		t.Errorf("Rat() = %v, %v", r, ok)
+	}
+}
+
+func TestParseWithOptions_UseNumber(t *testing.T) {
+	input := `<invoice currency="EUR" rate="1.0850000000000001">1234567890.123456789</invoice>`
+
+	node, err := ParseWithOptions(input, WithUseNumber())
+	if err != nil {
+		t.Fatalf("ParseWithOptions failed: %v", err)
+	}
+	obj := node.(*ast.ObjectNode)
+	rate, _ := obj.GetProperty("@rate")
+	if v := rate.(*ast.LiteralNode).Value(); v != Number("1.0850000000000001") {
+		t.Errorf("@rate = %#v, want Number", v)
+	}
+	currency, _ := obj.GetProperty("@currency")
+	if v := currency.(*ast.LiteralNode).Value(); v != "EUR" {
+		t.Errorf("@currency = %#v, want string", v)
+	}
+
+	data := NodeToInterface(node).(map[string]interface{})
+	if total := data["#text"]; total != Number("1234567890.123456789") {
+		t.Errorf("#text = %#v, want Number", total)
+	}
+
+	out, err := Render(node)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	want := `<root currency="EUR" rate="1.0850000000000001">1234567890.123456789</root>`
+	if string(out) != want {
+		t.Errorf("Render = %s, want %s", out, want)
+	}
+}
+
+func TestParseWithOptions_Default(t *testing.T) {
+	node, err := ParseWithOptions(`<a n="1">2</a>`)
+	if err != nil {
+		t.Fatalf("ParseWithOptions failed: %v", err)
+	}
+	data := NodeToInterface(node).(map[string]interface{})
+	if data["@n"] != "1" || data["#text"] != "2" {
+		t.Errorf("expected strings without options, got %#v", data)
+	}
+}
+
+func TestElement_NumberRoundTrip(t *testing.T) {
+	elem, err := ParseElementWithOptions(`<amount currency="EUR">0.10</amount>`, WithUseNumber())
+	if err != nil {
+		t.Fatalf("ParseElementWithOptions failed: %v", err)
+	}
+
+	n, ok := elem.GetNumber()
+	if !ok || n != "0.10" {
+		t.Fatalf("GetNumber() = %q, %v", n, ok)
+	}
+	if text, _ := elem.GetText(); text != "0.10" {
+		t.Errorf("GetText() = %q", text)
+	}
+	if _, ok := NewElement().Text("n/a").GetNumber(); ok {
+		t.Error("GetNumber() on non-numeric text should fail")
+	}
+
+	// Modify the amount exactly.
+	r, _ := n.Rat()
+	r.Mul(r, r)
+	elem.Number(Number(r.FloatString(4)))
+
+	out, err := elem.XML("amount")
+	if err != nil {
+		t.Fatalf("XML failed: %v", err)
+	}
+	if !strings.Contains(out, ">0.0100<") {
+		t.Errorf("output %s lost decimal text", out)
+	}
+}
+
+func TestUnmarshalWithOptions_UseNumber(t *testing.T) {
+	input := []byte(`<order id="7"><amount>19.990</amount><label>x</label></order>`)
+
+	var m map[string]interface{}
+	if err := UnmarshalWithOptions(input, &m, WithUseNumber()); err != nil {
+		t.Fatalf("UnmarshalWithOptions failed: %v", err)
+	}
+	if m["@id"] != Number("7") {
+		t.Errorf("@id = %#v", m["@id"])
+	}
+	if got := m["amount"].(map[string]interface{})["#text"]; got != Number("19.990") {
+		t.Errorf("amount = %#v", got)
+	}
+
+	type Order struct {
+		ID     string `xml:"id,attr"`
+		Amount Number `xml:"amount"`
+		Label  string `xml:"label"`
+	}
+	var o Order
+	if err := UnmarshalWithOptions(input, &o, WithUseNumber()); err != nil {
+		t.Fatalf("UnmarshalWithOptions into struct failed: %v", err)
+	}
+	if o.ID != "7" || o.Amount != "19.990" || o.Label != "x" {
+		t.Errorf("got %+v", o)
+	}
+
+	b, err := Marshal(o)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(b), "<amount>19.990</amount>") {
+		t.Errorf("Marshal lost precision: %s", b)
+	}
+
+	if err := UnmarshalWithOptions(input, nil, WithUseNumber()); err == nil {
+		t.Error("expected error for nil target")
+	}
+}
@@ -0,0 +1,658 @@
+package xml
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/shapestone/shape-xml/internal/charset"
+	"github.com/shapestone/shape-xml/internal/fastparser"
+	"github.com/shapestone/shape-xml/internal/lexical"
+	"github.com/shapestone/shape-xml/internal/limits"
+)
+
+// FloatPolicy controls how Marshal and Render encode NaN and infinite float values,
+// which most XML schemas reject when written in Go's default spelling.
+type FloatPolicy int
+
+const (
+	// FloatPolicyError rejects NaN and ±Inf with an *UnsupportedValueError. This is the default.
+	FloatPolicyError FloatPolicy = iota
+
+	// FloatPolicyNil encodes an element holding NaN or ±Inf as an empty element
+	// marked xsi:nil="true". Attributes holding such values are omitted.
+	FloatPolicyNil
+
+	// FloatPolicyXSD writes the XML Schema spellings "NaN", "INF" and "-INF".
+	FloatPolicyXSD
+)
+
+// DurationFormat controls how Marshal and Render encode time.Duration values.
+type DurationFormat int
+
+const (
+	// DurationFormatGo writes durations in Go's own spelling, e.g. "1m30s". This is the default.
+	DurationFormatGo DurationFormat = iota
+
+	// DurationFormatISO8601 writes ISO 8601 / xsd:duration values, e.g. "PT1M30S".
+	DurationFormatISO8601
+)
+
+// durationType is the reflect.Type of time.Duration, which is encoded as a
+// duration string rather than as its underlying int64.
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// timeType is the reflect.Type of time.Time, whose layout the layout=
+// tag option sets.
+var timeType = reflect.TypeOf(time.Time{})
+
+// xsiNamespace is the XML Schema instance namespace used for xsi:nil.
+const xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+
+// MarshalOptions configures MarshalWithOptions and RenderWithOptions.
+// The zero value gives the same output as Marshal and Render.
+type MarshalOptions struct {
+	// FloatPolicy selects how NaN and ±Inf float values are encoded.
+	FloatPolicy FloatPolicy
+
+	// DurationFormat selects how time.Duration values are written.
+	// Unmarshal accepts either format regardless of this setting.
+	DurationFormat DurationFormat
+
+	// ArrayHints marks lists holding a single item with a shape:array
+	// attribute, so that parsing with WithArrayHints keeps them as lists.
+	// See ArrayHintNamespace.
+	ArrayHints bool
+
+	// RootName names the root element in place of the type name Marshal
+	// uses, or "root". An XMLName field of the root struct still names its
+	// element.
+	RootName string
+
+	// Declaration writes an XML declaration,
+	// <?xml version="1.0" encoding="UTF-8"?>, on its own line before the
+	// root element.
+	Declaration bool
+
+	// Encoding replaces "UTF-8" as the encoding named by the declaration,
+	// and Standalone, if set to "yes" or "no", adds a standalone attribute
+	// to it. The output is UTF-8 regardless; a writer transcoding it must
+	// name its encoding here.
+	Encoding   string
+	Standalone string
+
+	// Prefix and Indent, if either is set, put each element on its own
+	// line, starting with Prefix and one Indent per level of nesting, as
+	// MarshalIndent does. Elements holding text keep their content on one
+	// line, so indentation never changes text.
+	Prefix string
+	Indent string
+
+	// AttrOrder selects the order of struct attributes. Types with their
+	// own MarshalXML or AppendXMLElement method order theirs as they like.
+	AttrOrder AttrOrder
+
+	// EmptyElements selects how elements without content are written.
+	EmptyElements EmptyElementStyle
+
+	// LiteralQuotes writes ' in attribute values as itself instead of the
+	// character reference &#39;. Attribute values are delimited by ", so "
+	// stays escaped in them. Text never escapes quotes.
+	LiteralQuotes bool
+
+	// Escape selects which characters attribute values escape.
+	// EscapeMinimal implies LiteralQuotes. Neither applies to what an
+	// AppendXMLElement method writes.
+	Escape EscapePolicy
+
+	// EscapeNames makes Marshal turn map and OrderedMap keys that are not
+	// valid names, such as "0" or "first name", into names with
+	// DefaultNameEscaper, which UnescapeName reverses. Without it, such keys
+	// are an error.
+	EscapeNames bool
+
+	// PreserveOrder makes RenderWithOptions write attributes and child
+	// elements in the order of their source positions, as recorded by
+	// Parse, instead of sorted by name. Nodes without a position follow in
+	// name order. Marshal ignores it: struct fields have their own order,
+	// and *OrderedMap values are always written in key order.
+	PreserveOrder bool
+}
+
+// ParseOptions configures ParseWithOptions, ParseElementWithOptions and
+// UnmarshalWithOptions. The zero value gives the same result as the plain
+// functions.
+type ParseOptions struct {
+	// UseNumber stores numeric attribute values and text content as Number
+	// instead of string, see WithUseNumber.
+	UseNumber bool
+
+	// SortedKeys makes interface{} results use *OrderedMap with sorted keys
+	// instead of maps, see WithSortedKeys.
+	SortedKeys bool
+
+	// ElementFilter lists the element paths to keep; everything else is
+	// skipped while scanning, see WithElementFilter.
+	ElementFilter []string
+
+	// MaxDepth stops parsing below this element depth, with the root at
+	// depth 1, see WithMaxDepth. Zero means no limit.
+	MaxDepth int
+
+	// DepthSummary selects how content below MaxDepth is represented.
+	DepthSummary DepthSummary
+
+	// BackslashEscapes reads backslash escapes in attribute values, see
+	// WithBackslashEscapes.
+	BackslashEscapes bool
+
+	// ArrayHints stores elements carrying the shape:array hint as one-item
+	// lists, see WithArrayHints.
+	ArrayHints bool
+
+	// ForceList lists the element paths that always decode as lists, see
+	// WithForceList.
+	ForceList []string
+
+	// Types maps attribute and text paths to the type their values are
+	// converted to, see WithTypes.
+	Types map[string]ValueType
+
+	// EmptyAsNil represents empty elements as nil, see WithEmptyAsNil.
+	EmptyAsNil bool
+
+	// Recover turns panics during parsing into a *PanicError, see
+	// WithRecover.
+	Recover bool
+
+	// PanicHook is called with each *PanicError returned because of
+	// Recover.
+	PanicHook func(*PanicError)
+
+	// InternPool supplies element names and attribute keys, see
+	// WithInternPool.
+	InternPool *InternPool
+
+	// PreserveOrder makes interface{} results use *OrderedMap with keys in
+	// document order, see WithPreserveOrder.
+	PreserveOrder bool
+
+	// MixedContent records the order of element content under "#content",
+	// see WithMixedContent.
+	MixedContent bool
+
+	// Comments keeps comments as "#comment" content items, see
+	// WithComments.
+	Comments bool
+
+	// RawNewlines keeps "\r\n" and "\r" line ends as written, see
+	// WithRawNewlines.
+	RawNewlines bool
+
+	// PreserveSpace keeps whitespace in text content, see
+	// WithPreserveSpace.
+	PreserveSpace bool
+
+	// Limits bounds the resources spent on the document, see WithLimits.
+	Limits Limits
+
+	// Lenient recovers from common errors in hand-written documents, see
+	// WithLenient.
+	Lenient bool
+
+	// HTML reads HTML as well as XML, see WithHTML. It implies Lenient.
+	HTML bool
+
+	// CharsetReader converts documents in charsets the package does not
+	// know to UTF-8, see WithCharsetReader.
+	CharsetReader CharsetReader
+
+	// InvalidChars selects what happens to characters XML does not allow,
+	// see WithInvalidChars.
+	InvalidChars InvalidChars
+}
+
+// DepthSummary selects how WithMaxDepth represents the content of elements
+// at the depth limit.
+type DepthSummary int
+
+const (
+	// DepthSummaryRaw stores the unparsed inner XML under the "#inner" key.
+	DepthSummaryRaw DepthSummary = iota
+	// DepthSummaryCount stores the number of child elements, as an int,
+	// under the "#count" key.
+	DepthSummaryCount
+)
+
+// ParseOption sets a field of ParseOptions.
+type ParseOption func(*ParseOptions)
+
+// WithUseNumber makes numeric attribute values and text content parse as
+// Number, preserving their exact decimal text for later rendering.
+func WithUseNumber() ParseOption {
+	return func(o *ParseOptions) {
+		o.UseNumber = true
+	}
+}
+
+// WithSortedKeys makes NodeToInterfaceWithOptions and UnmarshalWithOptions
+// (for interface{} targets) return every element as an *OrderedMap with keys
+// in sorted order instead of a map[string]interface{}. The result has a
+// stable order, so its Marshal or MarshalJSON output can be compared against
+// golden files or hashed directly.
+func WithSortedKeys() ParseOption {
+	return func(o *ParseOptions) {
+		o.SortedKeys = true
+	}
+}
+
+// WithArrayHints makes elements written with MarshalOptions.ArrayHints
+// parse back as lists even when they occur once, and removes the hint
+// attributes. Without this option the hints are ordinary attributes.
+func WithArrayHints() ParseOption {
+	return func(o *ParseOptions) {
+		o.ArrayHints = true
+	}
+}
+
+// WithForceList makes the elements on the given paths decode as lists
+// ([]interface{}, or an ArrayDataNode in the AST) even when they occur once,
+// so consumers see the same shape regardless of the number of occurrences.
+//
+// Paths start at the root element and may be separated by '/' or '.', as in
+// "rss/channel/item" or "rss.channel.item"; "*" matches any element name. A
+// bare name such as "item" matches elements of that name at any depth.
+//
+// Example:
+//
+//	var v map[string]interface{}
+//	err := xml.UnmarshalWithOptions(data, &v, xml.WithForceList("rss.channel.item"))
+//	items := v["channel"].(map[string]interface{})["item"].([]interface{})
+func WithForceList(paths ...string) ParseOption {
+	return func(o *ParseOptions) {
+		o.ForceList = append(o.ForceList, paths...)
+	}
+}
+
+// WithTypes converts the attribute values and text content on the given
+// paths to typed Go values in dynamic (map and AST) results, a lightweight
+// alternative to declaring structs or schemas. See ValueType for the
+// available types; values without a declared type stay strings.
+//
+// Paths are separated by '/' or '.' and match the end of a value's path, so
+// "@id" applies to every id attribute, "price.#text" (or just "price") to the
+// text of every price element and "order/@id" only to id attributes of order
+// elements. "*" matches any element name. The longest matching path wins, and
+// a named segment beats "*".
+//
+// A value that cannot be converted fails the parse with an error naming its
+// path. NodeToInterfaceWithOptions cannot report errors and leaves such
+// values as strings.
+//
+// Example:
+//
+//	var v map[string]interface{}
+//	err := xml.UnmarshalWithOptions(data, &v, xml.WithTypes(map[string]xml.ValueType{
+//	    "@id":         xml.TypeInt,
+//	    "price.#text": xml.TypeDecimal,
+//	}))
+//	// v["@id"] is int64(7), v["price"].(map[string]interface{})["#text"] is xml.Number("9.99")
+func WithTypes(types map[string]ValueType) ParseOption {
+	return func(o *ParseOptions) {
+		if o.Types == nil {
+			o.Types = make(map[string]ValueType, len(types))
+		}
+		for p, t := range types {
+			o.Types[p] = t
+		}
+	}
+}
+
+// WithEmptyAsNil makes empty elements, such as <a/> or <a></a>, decode as
+// nil instead of an empty map: the key stays present with a nil value, and
+// pointer fields of struct targets stay nil. Elements marked xsi:nil="true"
+// are treated as empty. An element with attributes, text or children is
+// never empty. See Unmarshal for how empty and missing elements decode by
+// default.
+func WithEmptyAsNil() ParseOption {
+	return func(o *ParseOptions) {
+		o.EmptyAsNil = true
+	}
+}
+
+// WithRawNewlines turns off end-of-line normalization. By default both
+// parsers turn each "\r\n" and "\r" in text, CDATA sections, comments and
+// attribute values into "\n", as the XML specification requires; a
+// character reference such as &#13; still yields "\r". With
+// WithRawNewlines, line ends are kept byte for byte, so that documents
+// written on Windows round-trip unchanged.
+func WithRawNewlines() ParseOption {
+	return func(o *ParseOptions) {
+		o.RawNewlines = true
+	}
+}
+
+// SetRawNewlines turns off end-of-line normalization in the Decoder when
+// raw is true, see WithRawNewlines. By default Token, DecodeElement, Match
+// and ParseEvents see each "\r\n" and "\r" as "\n", like Parse.
+func (d *Decoder) SetRawNewlines(raw bool) {
+	d.rawNewlines = raw
+}
+
+// WithPreserveSpace keeps text content exactly as written. By default
+// "#text" values are trimmed of leading and trailing whitespace and text
+// that is only whitespace, such as the indentation between child elements,
+// is dropped. Every parse keeps the whitespace inside elements marked
+// xml:space="preserve", and their descendants, up to an element marked
+// xml:space="default", which returns to the parse's own setting.
+//
+// Example:
+//
+//	var v map[string]interface{}
+//	err := xml.UnmarshalWithOptions([]byte("<code>  x := 1\n</code>"), &v, xml.WithPreserveSpace())
+//	// v["#text"] is "  x := 1\n"
+func WithPreserveSpace() ParseOption {
+	return func(o *ParseOptions) {
+		o.PreserveSpace = true
+	}
+}
+
+// WithElementFilter makes UnmarshalWithOptions and ParseElementWithOptions
+// keep only the elements on the given paths, skipping every other subtree at
+// scan speed instead of building maps for it. Paths start at the root
+// element, as in "/order/item/sku"; "*" matches any element name. A matched
+// element keeps its whole subtree, and its ancestors keep their attributes
+// and text. Skipped subtrees are still checked for matching tags.
+//
+// ParseWithOptions does not support WithElementFilter and returns an error.
+//
+// Example:
+//
+//	var v map[string]interface{}
+//	err := xml.UnmarshalWithOptions(data, &v,
+//	    xml.WithElementFilter("/feed/entry/title", "/feed/entry/id"))
+func WithElementFilter(paths ...string) ParseOption {
+	return func(o *ParseOptions) {
+		o.ElementFilter = append(o.ElementFilter, paths...)
+	}
+}
+
+// WithMaxDepth makes UnmarshalWithOptions and ParseElementWithOptions stop
+// descending below depth, where the root element has depth 1. Elements at
+// the limit keep their attributes and text; if they have child elements,
+// their content is replaced by a summary chosen by summary. This gives a
+// cheap preview of large or unfamiliar documents.
+//
+// ParseWithOptions does not support WithMaxDepth and returns an error.
+//
+// Example:
+//
+//	e, err := xml.ParseElementWithOptions(input, xml.WithMaxDepth(2, xml.DepthSummaryCount))
+//	// each child of the root has "#count" set to its number of children
+func WithMaxDepth(depth int, summary DepthSummary) ParseOption {
+	return func(o *ParseOptions) {
+		o.MaxDepth = depth
+		o.DepthSummary = summary
+	}
+}
+
+// WithBackslashEscapes makes UnmarshalWithOptions and
+// ParseElementWithOptions read \", \', \\, \n, \t and \r in attribute
+// values as escape sequences, as earlier versions did. XML has no such
+// escapes: by default a backslash is an ordinary character, and quotes and
+// line breaks are written as references such as &quot; and &#10;. Use it
+// only for documents written for those versions.
+//
+// ParseWithOptions does not support WithBackslashEscapes and returns an
+// error.
+func WithBackslashEscapes() ParseOption {
+	return func(o *ParseOptions) {
+		o.BackslashEscapes = true
+	}
+}
+
+// newParseOptions applies opts to the default ParseOptions.
+func newParseOptions(opts []ParseOption) ParseOptions {
+	var o ParseOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// postProcess reports whether the options require changes to the parsed
+// map representation.
+func (o *ParseOptions) postProcess() bool {
+	return o.UseNumber || o.SortedKeys || o.PreserveOrder || o.MixedContent || o.Comments || o.RawNewlines || o.PreserveSpace || o.Lenient || o.ArrayHints || len(o.ForceList) > 0 || len(o.Types) > 0 || o.EmptyAsNil || o.InvalidChars != InvalidCharsError || o.mapOnly()
+}
+
+// mapOnly reports whether the options are only supported by the fast parser,
+// which builds maps directly.
+func (o *ParseOptions) mapOnly() bool {
+	return len(o.ElementFilter) > 0 || o.MaxDepth > 0 || o.BackslashEscapes
+}
+
+// parseMap parses data with the fast parser, applying the intern pool,
+// element filter, depth limit, backslash escapes and UseNumber, and returns
+// the root map and root element name.
+func (o *ParseOptions) parseMap(data []byte) (map[string]interface{}, string, error) {
+	p := fastparser.NewParser(data)
+	p.SetInternPool(o.InternPool)
+	p.SetLimits(limits.Limits(o.Limits))
+	p.SetRawNewlines(o.RawNewlines)
+	p.SetPreserveSpace(o.PreserveSpace)
+	p.SetBackslashEscapes(o.BackslashEscapes)
+	p.SetInvalidChars(charset.Mode(o.InvalidChars))
+	if len(o.ElementFilter) > 0 {
+		f, err := fastparser.NewFilter(o.ElementFilter)
+		if err != nil {
+			return nil, "", fmt.Errorf("xml: invalid element filter: %w", err)
+		}
+		p.SetFilter(f)
+	}
+	if o.MaxDepth > 0 {
+		summary := fastparser.SummaryRaw
+		if o.DepthSummary == DepthSummaryCount {
+			summary = fastparser.SummaryCount
+		}
+		p.SetMaxDepth(o.MaxDepth, summary)
+	}
+	value, err := p.Parse()
+	if err != nil {
+		return nil, "", err
+	}
+	m := value.(map[string]interface{})
+	if o.ArrayHints {
+		applyArrayHints(m)
+	}
+	if len(o.ForceList) > 0 {
+		newForceList(o.ForceList).apply(m, []string{p.RootName()})
+	}
+	if len(o.Types) > 0 {
+		if err := newTypeMap(o.Types).apply(m, []string{p.RootName()}); err != nil {
+			return nil, "", err
+		}
+	}
+	if o.EmptyAsNil {
+		emptyToNil(m)
+	}
+	if o.UseNumber {
+		useNumbers(m)
+	}
+	return m, p.RootName(), nil
+}
+
+// UnsupportedValueError is returned when Marshal or Render is given a value
+// that cannot be represented under the active options, such as NaN with
+// FloatPolicyError.
+type UnsupportedValueError struct {
+	Str string
+}
+
+func (e *UnsupportedValueError) Error() string {
+	return "xml: unsupported value: " + e.Str
+}
+
+// encodeState carries per-call options through the compiled encoders.
+type encodeState struct {
+	opts MarshalOptions
+
+	// flush, if set, writes out the completed output in buf and returns the
+	// buffer to continue with. Encoder sets it to stream large values.
+	flush func(buf []byte) ([]byte, error)
+	// holdFlush, while positive, keeps maybeFlush from flushing.
+	holdFlush int
+	// ns holds the namespace declarations written on the currently open
+	// elements, innermost last.
+	ns []nsBinding
+	// renamed records the last element a struct wrote under its XMLName
+	// rather than the name it was given.
+	renamed elemRename
+}
+
+// maybeFlush hands buf to es.flush once it has grown past the Encoder's flush
+// threshold. Encoders call it only between complete child elements, so
+// everything in buf is final.
+func (es *encodeState) maybeFlush(buf []byte) ([]byte, error) {
+	if es.flush == nil || es.holdFlush > 0 || len(buf) < encoderFlushThreshold {
+		return buf, nil
+	}
+	es.renamed = elemRename{}
+	return es.flush(buf)
+}
+
+// floatAction is the outcome of applying a FloatPolicy to a value.
+type floatAction int
+
+const (
+	floatWrite floatAction = iota // write the returned text
+	floatNil                      // write xsi:nil (or omit an attribute)
+)
+
+// applyFloatPolicy formats f according to policy. Finite values are always
+// formatted with the shortest 'g' representation.
+func applyFloatPolicy(f float64, bitSize int, policy FloatPolicy) (string, floatAction, error) {
+	if !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, bitSize), floatWrite, nil
+	}
+	switch policy {
+	case FloatPolicyNil:
+		return "", floatNil, nil
+	case FloatPolicyXSD:
+		switch {
+		case math.IsNaN(f):
+			return "NaN", floatWrite, nil
+		case f > 0:
+			return "INF", floatWrite, nil
+		default:
+			return "-INF", floatWrite, nil
+		}
+	default:
+		return "", floatWrite, &UnsupportedValueError{Str: strconv.FormatFloat(f, 'g', -1, bitSize)}
+	}
+}
+
+// formatDuration formats d according to format.
+func formatDuration(d time.Duration, format DurationFormat) string {
+	if format == DurationFormatISO8601 {
+		return lexical.FormatDuration(d)
+	}
+	return d.String()
+}
+
+// appendNilElement appends an empty element marked xsi:nil="true".
+func appendNilElement(buf []byte, elemName string) []byte {
+	buf = append(buf, '<')
+	buf = append(buf, elemName...)
+	buf = append(buf, ` xsi:nil="true" xmlns:xsi="`...)
+	buf = append(buf, xsiNamespace...)
+	buf = append(buf, '"', '/', '>')
+	return buf
+}
+
+// floatBits returns the bit size of a float kind.
+func floatBits(k reflect.Kind) int {
+	if k == reflect.Float32 {
+		return 32
+	}
+	return 64
+}
+
+// formatText formats a field value used as attribute or character data,
+// applying the float policy. Pointers and interfaces are dereferenced.
+// It returns ok=false when the value should be omitted.
+func formatText(es *encodeState, rv reflect.Value) (string, bool, error) {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return "", true, nil
+		}
+		rv = rv.Elem()
+	}
+	if m, ok := textMarshaler(rv); ok {
+		text, err := m.MarshalText()
+		if err != nil {
+			return "", false, err
+		}
+		return string(text), true, nil
+	}
+	if rv.Type() == durationType {
+		return formatDuration(time.Duration(rv.Int()), es.opts.DurationFormat), true, nil
+	}
+	if k := rv.Kind(); k == reflect.Float32 || k == reflect.Float64 {
+		text, action, err := applyFloatPolicy(rv.Float(), floatBits(k), es.opts.FloatPolicy)
+		if err != nil {
+			return "", false, err
+		}
+		return text, action == floatWrite, nil
+	}
+	return formatValue(rv), true, nil
+}
+
+// formatFieldText is formatText for an attribute or text field, formatting
+// times in layout if the field has the layout option.
+func formatFieldText(es *encodeState, rv reflect.Value, layout string) (string, bool, error) {
+	if layout != "" {
+		for rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return "", true, nil
+			}
+			rv = rv.Elem()
+		}
+		return rv.Interface().(time.Time).Format(layout), true, nil
+	}
+	return formatText(es, rv)
+}
+
+// textMarshaler returns rv as an encoding.TextMarshaler, taking its
+// address when only the pointer implements it.
+func textMarshaler(rv reflect.Value) (encoding.TextMarshaler, bool) {
+	if rv.Type().Implements(textMarshalerType) {
+		return rv.Interface().(encoding.TextMarshaler), true
+	}
+	if rv.CanAddr() && rv.Addr().Type().Implements(textMarshalerType) {
+		return rv.Addr().Interface().(encoding.TextMarshaler), true
+	}
+	return nil, false
+}
+
+// formatLiteral formats a literal AST value for Render, applying the float policy.
+// The returned action reports whether the value should be written as xsi:nil.
+func formatLiteral(v interface{}, opts *MarshalOptions) (string, floatAction, error) {
+	switch val := v.(type) {
+	case nil:
+		return "", floatWrite, nil
+	case string:
+		return val, floatWrite, nil
+	case float64:
+		return applyFloatPolicy(val, 64, opts.FloatPolicy)
+	case float32:
+		return applyFloatPolicy(float64(val), 32, opts.FloatPolicy)
+	case time.Duration:
+		return formatDuration(val, opts.DurationFormat), floatWrite, nil
+	default:
+		return fmt.Sprintf("%v", v), floatWrite, nil
+	}
+}
@@ -0,0 +1,328 @@
+package xml
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+func TestMarshal_FloatPolicy(t *testing.T) {
+	type Reading struct {
+		Unit  float64 `xml:"unit,attr"`
+		Value float64 `xml:"value"`
+		Low   float32 `xml:"low"`
+	}
+
+	nan := Reading{Unit: math.Inf(1), Value: math.NaN(), Low: float32(math.Inf(-1))}
+
+	t.Run("default errors", func(t *testing.T) {
+		_, err := Marshal(nan)
+		var uve *UnsupportedValueError
+		if !errors.As(err, &uve) {
+			t.Fatalf("expected *UnsupportedValueError, got %v", err)
+		}
+	})
+
+	t.Run("finite values unaffected", func(t *testing.T) {
+		b, err := Marshal(Reading{Unit: 1, Value: 2.5, Low: -0.5})
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		want := `<Reading unit="1"><value>2.5</value><low>-0.5</low></Reading>`
+		if string(b) != want {
+			t.Errorf("got %s, want %s", b, want)
+		}
+	})
+
+	t.Run("xsd spellings", func(t *testing.T) {
+		b, err := MarshalWithOptions(nan, MarshalOptions{FloatPolicy: FloatPolicyXSD})
+		if err != nil {
+			t.Fatalf("MarshalWithOptions failed: %v", err)
+		}
+		want := `<Reading unit="INF"><value>NaN</value><low>-INF</low></Reading>`
+		if string(b) != want {
+			t.Errorf("got %s, want %s", b, want)
+		}
+	})
+
+	t.Run("xsi nil", func(t *testing.T) {
+		b, err := MarshalWithOptions(nan, MarshalOptions{FloatPolicy: FloatPolicyNil})
+		if err != nil {
+			t.Fatalf("MarshalWithOptions failed: %v", err)
+		}
+		result := string(b)
+		if strings.Contains(result, "unit=") {
+			t.Errorf("expected attribute to be omitted, got %s", result)
+		}
+		if !strings.Contains(result, `<value xsi:nil="true" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"/>`) {
+			t.Errorf("expected xsi:nil element, got %s", result)
+		}
+		if err := Validate(result); err != nil {
+			t.Errorf("output is not valid XML: %v", err)
+		}
+	})
+}
+
+func TestRender_FloatPolicy(t *testing.T) {
+	node := ast.NewObjectNode(map[string]ast.SchemaNode{
+		"@scale": ast.NewLiteralNode(math.Inf(-1), ast.Position{}),
+		"value":  ast.NewLiteralNode(math.NaN(), ast.Position{}),
+	}, ast.Position{})
+
+	if _, err := Render(node); err == nil {
+		t.Error("expected error rendering NaN with default policy")
+	}
+
+	b, err := RenderWithOptions(node, MarshalOptions{FloatPolicy: FloatPolicyXSD})
+	if err != nil {
+		t.Fatalf("RenderWithOptions failed: %v", err)
+	}
+	if string(b) != `<root scale="-INF"><value>NaN</value></root>` {
+		t.Errorf("unexpected output %s", b)
+	}
+
+	b, err = RenderWithOptions(node, MarshalOptions{FloatPolicy: FloatPolicyNil})
+	if err != nil {
+		t.Fatalf("RenderWithOptions failed: %v", err)
+	}
+	if !strings.Contains(string(b), `<value xsi:nil="true"`) || strings.Contains(string(b), "scale=") {
+		t.Errorf("unexpected output %s", b)
+	}
+}
+
+func TestMarshal_Duration(t *testing.T) {
+	type Job struct {
+		Timeout time.Duration  `xml:"timeout,attr"`
+		Retry   time.Duration  `xml:"retry"`
+		Backoff *time.Duration `xml:"backoff"`
+	}
+	backoff := 1500 * time.Millisecond
+	job := Job{Timeout: 90 * time.Second, Retry: 2 * time.Hour, Backoff: &backoff}
+
+	t.Run("go format by default", func(t *testing.T) {
+		b, err := Marshal(job)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		want := `<Job timeout="1m30s"><retry>2h0m0s</retry><backoff>1.5s</backoff></Job>`
+		if string(b) != want {
+			t.Errorf("got %s, want %s", b, want)
+		}
+	})
+
+	t.Run("iso 8601", func(t *testing.T) {
+		b, err := MarshalWithOptions(job, MarshalOptions{DurationFormat: DurationFormatISO8601})
+		if err != nil {
+			t.Fatalf("MarshalWithOptions failed: %v", err)
+		}
+		want := `<Job timeout="PT1M30S"><retry>PT2H</retry><backoff>PT1.5S</backoff></Job>`
+		if string(b) != want {
+			t.Errorf("got %s, want %s", b, want)
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		for _, opts := range []MarshalOptions{{}, {DurationFormat: DurationFormatISO8601}} {
+			b, err := MarshalWithOptions(job, opts)
+			if err != nil {
+				t.Fatalf("MarshalWithOptions failed: %v", err)
+			}
+			var got Job
+			if err := Unmarshal(b, &got); err != nil {
+				t.Fatalf("Unmarshal(%s) failed: %v", b, err)
+			}
+			if got.Timeout != job.Timeout || got.Retry != job.Retry || got.Backoff == nil || *got.Backoff != backoff {
+				t.Errorf("round trip of %s gave %+v", b, got)
+			}
+		}
+	})
+
+	t.Run("map value", func(t *testing.T) {
+		b, err := MarshalWithOptions(map[string]interface{}{"wait": 5 * time.Second}, MarshalOptions{DurationFormat: DurationFormatISO8601})
+		if err != nil {
+			t.Fatalf("MarshalWithOptions failed: %v", err)
+		}
+		if !strings.Contains(string(b), "<wait>PT5S</wait>") {
+			t.Errorf("got %s", b)
+		}
+	})
+}
+
+func TestRender_Duration(t *testing.T) {
+	node := ast.NewObjectNode(map[string]ast.SchemaNode{
+		"@ttl":  ast.NewLiteralNode(time.Minute, ast.Position{}),
+		"#text": ast.NewLiteralNode(10*time.Second, ast.Position{}),
+	}, ast.Position{})
+
+	b, err := RenderWithOptions(node, MarshalOptions{DurationFormat: DurationFormatISO8601})
+	if err != nil {
+		t.Fatalf("RenderWithOptions failed: %v", err)
+	}
+	want := `<root ttl="PT1M">PT10S</root>`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
+
+func TestWithElementFilter(t *testing.T) {
+	input := []byte(`<feed><entry id="1"><title>A</title><price>1.50</price><body>long</body></entry>` +
+		`<entry id="2"><title>B</title><price>2</price><body>long</body></entry></feed>`)
+
+	t.Run("map target", func(t *testing.T) {
+		var v map[string]interface{}
+		if err := UnmarshalWithOptions(input, &v, WithElementFilter("/feed/entry/title")); err != nil {
+			t.Fatalf("UnmarshalWithOptions failed: %v", err)
+		}
+		entries := v["entry"].([]interface{})
+		first := entries[0].(map[string]interface{})
+		if _, ok := first["body"]; ok {
+			t.Errorf("body should have been skipped: %#v", first)
+		}
+		if first["@id"] != "1" || first["title"].(map[string]interface{})["#text"] != "A" {
+			t.Errorf("unexpected entry %#v", first)
+		}
+	})
+
+	t.Run("struct target", func(t *testing.T) {
+		type Entry struct {
+			Title string `xml:"title"`
+			Body  string `xml:"body"`
+		}
+		type Feed struct {
+			Entries []Entry `xml:"entry"`
+		}
+		var feed Feed
+		if err := UnmarshalWithOptions(input, &feed, WithElementFilter("/feed/entry/title")); err != nil {
+			t.Fatalf("UnmarshalWithOptions failed: %v", err)
+		}
+		if len(feed.Entries) != 2 || feed.Entries[1].Title != "B" || feed.Entries[1].Body != "" {
+			t.Errorf("got %+v", feed)
+		}
+	})
+
+	t.Run("combined with UseNumber", func(t *testing.T) {
+		var v interface{}
+		if err := UnmarshalWithOptions(input, &v, WithElementFilter("/feed/entry/price"), WithUseNumber()); err != nil {
+			t.Fatalf("UnmarshalWithOptions failed: %v", err)
+		}
+		first := v.(map[string]interface{})["entry"].([]interface{})[0].(map[string]interface{})
+		if first["price"].(map[string]interface{})["#text"] != Number("1.50") {
+			t.Errorf("got %#v", first)
+		}
+	})
+
+	t.Run("element", func(t *testing.T) {
+		e, err := ParseElementWithOptions(string(input), WithElementFilter("/feed/entry/title"))
+		if err != nil {
+			t.Fatalf("ParseElementWithOptions failed: %v", err)
+		}
+		if e.Name() != "feed" {
+			t.Errorf("Name() = %q", e.Name())
+		}
+		if got := MustCompileQuery("//title").Values(e); len(got) != 2 || got[0] != "A" {
+			t.Errorf("titles = %v", got)
+		}
+		if got := MustCompileQuery("//body").Find(e); len(got) != 0 {
+			t.Errorf("expected no body elements, got %d", len(got))
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var v interface{}
+		if err := UnmarshalWithOptions(input, &v, WithElementFilter("/feed//title")); err == nil || !strings.Contains(err.Error(), "invalid element filter") {
+			t.Errorf("expected invalid filter error, got %v", err)
+		}
+		if _, err := ParseWithOptions(string(input), WithElementFilter("/feed")); err == nil {
+			t.Error("expected ParseWithOptions to reject WithElementFilter")
+		}
+		bad := []byte(`<feed><entry><body></title></entry></feed>`)
+		if err := UnmarshalWithOptions(bad, &v, WithElementFilter("/feed/other")); err == nil {
+			t.Error("expected error for mismatched tags in skipped subtree")
+		}
+	})
+}
+
+func TestWithMaxDepth(t *testing.T) {
+	input := `<library><shelf id="a"><book>One</book><book>Two</book></shelf><note>hi</note></library>`
+
+	e, err := ParseElementWithOptions(input, WithMaxDepth(2, DepthSummaryRaw))
+	if err != nil {
+		t.Fatalf("ParseElementWithOptions failed: %v", err)
+	}
+	shelf, ok := e.GetChild("shelf")
+	if !ok {
+		t.Fatal("missing shelf")
+	}
+	if v, _ := shelf.Get("#inner"); v != "<book>One</book><book>Two</book>" {
+		t.Errorf("#inner = %#v", v)
+	}
+	if id, _ := shelf.GetAttr("id"); id != "a" {
+		t.Errorf("id = %q", id)
+	}
+	if note, _ := e.GetChild("note"); note == nil {
+		t.Error("missing note")
+	} else if text, _ := note.GetText(); text != "hi" {
+		t.Errorf("note text = %q", text)
+	}
+
+	var v map[string]interface{}
+	if err := UnmarshalWithOptions([]byte(input), &v, WithMaxDepth(1, DepthSummaryCount)); err != nil {
+		t.Fatalf("UnmarshalWithOptions failed: %v", err)
+	}
+	if v["#count"] != 2 || len(v) != 1 {
+		t.Errorf("got %#v", v)
+	}
+
+	if _, err := ParseWithOptions(input, WithMaxDepth(1, DepthSummaryRaw)); err == nil {
+		t.Error("expected ParseWithOptions to reject WithMaxDepth")
+	}
+}
+
+func TestWithBackslashEscapes(t *testing.T) {
+	input := `<file path="C:\temp\new" title="say \"hi\""/>`
+	type file struct {
+		Path  string `xml:"path,attr"`
+		Title string `xml:"title,attr"`
+	}
+
+	// A backslash is an ordinary character, so the title ends at \".
+	if err := Validate(input); err == nil {
+		t.Error("expected Validate to reject the text after the title")
+	}
+	var plain file
+	if err := Unmarshal([]byte(`<file path="C:\temp\new"/>`), &plain); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if plain.Path != `C:\temp\new` {
+		t.Errorf("Path = %q, want the backslashes kept", plain.Path)
+	}
+	node, err := Parse(`<file path="C:\temp\new\"/>`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := node.(*ast.ObjectNode).Properties()["@path"].(*ast.LiteralNode).Value(); got != `C:\temp\new\` {
+		t.Errorf("Parse: path = %q", got)
+	}
+
+	var f file
+	if err := UnmarshalWithOptions([]byte(input), &f, WithBackslashEscapes()); err != nil {
+		t.Fatalf("UnmarshalWithOptions failed: %v", err)
+	}
+	if want := (file{Path: "C:\temp\new", Title: `say "hi"`}); f != want {
+		t.Errorf("got %#v, want %#v", f, want)
+	}
+	e, err := ParseElementWithOptions(input, WithBackslashEscapes())
+	if err != nil {
+		t.Fatalf("ParseElementWithOptions failed: %v", err)
+	}
+	if title, _ := e.GetAttr("title"); title != `say "hi"` {
+		t.Errorf("title = %q", title)
+	}
+	if _, err := ParseWithOptions(input, WithBackslashEscapes()); err == nil {
+		t.Error("expected ParseWithOptions to reject WithBackslashEscapes")
+	}
+}
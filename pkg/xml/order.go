@@ -0,0 +1,111 @@
+package xml
+
+import (
+	"sort"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+// WithPreserveOrder makes UnmarshalWithOptions (for interface{} targets) and
+// NodeToInterfaceWithOptions return every element as an *OrderedMap whose
+// keys follow the document: attributes in the order they were written, then
+// content and child elements in the order they first occur. Marshal writes
+// an OrderedMap in key order, so the result can be written back without
+// reordering, as canonicalized or signed documents require. It takes
+// precedence over WithSortedKeys.
+//
+// Repeated elements are still grouped under one key at the position of the
+// first occurrence; elements interleaved with others of another name, as in
+// <a/><b/><a/>, come back as <a/><a/><b/>; WithMixedContent records that
+// order too.
+//
+// The AST returned by ParseWithOptions always records source positions; pass
+// MarshalOptions.PreserveOrder to RenderWithOptions to write it in source
+// order. UnmarshalWithOptions does not support WithPreserveOrder together
+// with WithElementFilter or WithMaxDepth, and Element maps have no order.
+//
+// Example:
+//
+//	var v interface{}
+//	err := xml.UnmarshalWithOptions(data, &v, xml.WithPreserveOrder())
+//	out, err := xml.MarshalWithOptions(v, xml.MarshalOptions{RootName: "Signature"})
+func WithPreserveOrder() ParseOption {
+	return func(o *ParseOptions) {
+		o.PreserveOrder = true
+	}
+}
+
+// sortBySource orders keys, sorted by name, by the source position of their
+// nodes in props. Keys without a node or a position, such as those of
+// hand-built nodes, follow in name order.
+func sortBySource(keys []string, props map[string]ast.SchemaNode) {
+	sort.SliceStable(keys, func(i, j int) bool {
+		return sourceBefore(nodePosition(props[keys[i]]), nodePosition(props[keys[j]]))
+	})
+}
+
+// nodePosition returns the source position of node, or the zero position for
+// nil.
+func nodePosition(node ast.SchemaNode) ast.Position {
+	if node == nil {
+		return ast.ZeroPosition()
+	}
+	return node.Position()
+}
+
+// sourceBefore reports whether position a comes before b. Invalid positions
+// come after all valid ones.
+func sourceBefore(a, b ast.Position) bool {
+	switch {
+	case !a.IsValid():
+		return false
+	case !b.IsValid():
+		return true
+	case a.Line != b.Line:
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
+
+// sourceOrdered converts every map in v, the map representation of node,
+// into an *OrderedMap with keys in the source order of node's properties.
+// Slices keep their order; other values are returned unchanged.
+func sourceOrdered(node ast.SchemaNode, v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		var props map[string]ast.SchemaNode
+		if obj, ok := node.(*ast.ObjectNode); ok {
+			props = obj.Properties()
+		}
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		sortBySource(keys, props)
+		m := NewOrderedMap()
+		for _, k := range keys {
+			m.Set(k, sourceOrdered(props[k], val[k]))
+		}
+		return m
+	case []interface{}:
+		var items []ast.SchemaNode
+		switch n := node.(type) {
+		case *ast.ArrayDataNode:
+			items = n.Elements()
+		case *ast.ObjectNode:
+			items = []ast.SchemaNode{n} // a single element made a list
+		}
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			var itemNode ast.SchemaNode
+			if i < len(items) {
+				itemNode = items[i]
+			}
+			out[i] = sourceOrdered(itemNode, item)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,117 @@
+package xml
+
+import (
+	"testing"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+const orderDoc = `<Signature z="1" a="2" m="3"><SignedInfo><Method alg="x"/><Reference uri="#a"/><Reference uri="#b"/></SignedInfo><Value>v</Value><KeyInfo/></Signature>`
+
+func TestWithPreserveOrder_Unmarshal(t *testing.T) {
+	var v interface{}
+	if err := UnmarshalWithOptions([]byte(orderDoc), &v, WithPreserveOrder(), WithSortedKeys()); err != nil {
+		t.Fatal(err)
+	}
+	m, ok := v.(*OrderedMap)
+	if !ok {
+		t.Fatalf("got %T, want *OrderedMap", v)
+	}
+	want := []string{"@z", "@a", "@m", "SignedInfo", "Value", "KeyInfo"}
+	if got := m.Keys(); !equalStrings(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+	info, _ := m.Get("SignedInfo")
+	if got := info.(*OrderedMap).Keys(); !equalStrings(got, []string{"Method", "Reference"}) {
+		t.Errorf("SignedInfo keys = %v", got)
+	}
+
+	out, err := MarshalWithOptions(v, MarshalOptions{RootName: "Signature"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != orderDoc {
+		t.Errorf("round trip\ngot  %s\nwant %s", out, orderDoc)
+	}
+
+	// Typed targets are unaffected.
+	var s struct {
+		Z string `xml:"z,attr"`
+		V string `xml:"Value"`
+	}
+	if err := UnmarshalWithOptions([]byte(orderDoc), &s, WithPreserveOrder()); err != nil || s.Z != "1" || s.V != "v" {
+		t.Errorf("struct target: %v %+v", err, s)
+	}
+	if err := UnmarshalWithOptions([]byte(orderDoc), &v, WithPreserveOrder(), WithMaxDepth(1, DepthSummaryCount)); err == nil {
+		t.Error("expected an error combining WithPreserveOrder and WithMaxDepth")
+	}
+}
+
+func TestWithPreserveOrder_Options(t *testing.T) {
+	var v interface{}
+	err := UnmarshalWithOptions([]byte(`<r b="1"><y>2</y><x>3</x></r>`), &v,
+		WithPreserveOrder(), WithForceList("r/x"), WithTypes(map[string]ValueType{"y": TypeInt}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := v.(*OrderedMap)
+	if got := m.Keys(); !equalStrings(got, []string{"@b", "y", "x"}) {
+		t.Errorf("keys = %v", got)
+	}
+	if x, _ := m.Get("x"); len(x.([]interface{})) != 1 {
+		t.Errorf("x = %v, want a one-item list", x)
+	}
+	if y, _ := m.Get("y"); y.(*OrderedMap).Len() != 1 {
+		t.Errorf("y = %v", y)
+	}
+}
+
+func TestNodeToInterfaceWithOptions_PreserveOrder(t *testing.T) {
+	node, err := Parse(orderDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NodeToInterfaceWithOptions(node, WithPreserveOrder()).(*OrderedMap)
+	if got := m.Keys(); !equalStrings(got, []string{"@z", "@a", "@m", "SignedInfo", "Value", "KeyInfo"}) {
+		t.Errorf("keys = %v", got)
+	}
+}
+
+func TestRenderWithOptions_PreserveOrder(t *testing.T) {
+	node, err := Parse(orderDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := RenderWithOptions(node, MarshalOptions{RootName: "Signature", PreserveOrder: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != orderDoc {
+		t.Errorf("got  %s\nwant %s", out, orderDoc)
+	}
+
+	sorted, _ := RenderWithOptions(node, MarshalOptions{RootName: "Signature"})
+	if want := `<Signature a="2" m="3" z="1"><KeyInfo/>`; string(sorted[:len(want)]) != want {
+		t.Errorf("default order changed: %s", sorted)
+	}
+
+	// Hand-built nodes have no position and follow in name order.
+	obj := node.(*ast.ObjectNode)
+	obj.Properties()["@b"] = ast.NewLiteralNode("4", ast.ZeroPosition())
+	out, _ = RenderWithOptions(node, MarshalOptions{RootName: "Signature", PreserveOrder: true})
+	if want := `<Signature z="1" a="2" m="3" b="4"><SignedInfo>`; string(out[:len(want)]) != want {
+		t.Errorf("got %s", out)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,253 @@
+package xml
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+)
+
+// OrderedMap is a map that remembers the order in which keys were inserted.
+//
+// It is the element representation returned by the WithSortedKeys mode, where
+// keys are inserted in sorted order, and can be built by hand for
+// order-sensitive output. Keys follow the same conventions as the
+// map[string]interface{} form: "@name" for attributes, "#text" and "#cdata"
+// for content, "#content" for the order of mixed content (see
+// WithMixedContent), "#prolog" and "#epilog" for comments around the root
+// (see WithComments), "#inner" for raw content (see RawXML), anything else
+// for child elements, whose values are
+// *OrderedMap, []interface{} for repeated elements, or literals.
+//
+// Marshal and MarshalXML write attributes and children in key order;
+// MarshalJSON writes a JSON object with keys in order.
+//
+// The zero value is an empty map ready to use. An OrderedMap is not safe for
+// concurrent use.
+type OrderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// NewOrderedMap returns an empty OrderedMap.
+func NewOrderedMap() *OrderedMap {
+	return &OrderedMap{}
+}
+
+// Get returns the value stored under key.
+func (m *OrderedMap) Get(key string) (interface{}, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+// Set stores value under key. A new key is appended at the end; an existing
+// key keeps its position. Set returns m for chaining.
+func (m *OrderedMap) Set(key string, value interface{}) *OrderedMap {
+	if m.values == nil {
+		m.values = make(map[string]interface{})
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+	return m
+}
+
+// Delete removes key, preserving the order of the remaining keys.
+func (m *OrderedMap) Delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Keys returns the keys in order. The returned slice is a copy.
+func (m *OrderedMap) Keys() []string {
+	return append([]string(nil), m.keys...)
+}
+
+// Len returns the number of keys.
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+// Range calls fn for each key and value in order until fn returns false.
+// fn must not add or delete keys.
+func (m *OrderedMap) Range(fn func(key string, value interface{}) bool) {
+	for _, k := range m.keys {
+		if !fn(k, m.values[k]) {
+			return
+		}
+	}
+}
+
+// Pairs returns the entries in order.
+func (m *OrderedMap) Pairs() []KeyValue {
+	pairs := make([]KeyValue, len(m.keys))
+	for i, k := range m.keys {
+		pairs[i] = KeyValue{Key: k, Value: m.values[k]}
+	}
+	return pairs
+}
+
+// MarshalJSON encodes the map as a JSON object with keys in order.
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		val, err := json.Marshal(m.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// MarshalXML encodes the map as an element named "root", matching Render.
+// Inside Marshal, an OrderedMap field or map value takes its element name
+// from the field or key instead.
+func (m *OrderedMap) MarshalXML() ([]byte, error) {
+	return m.appendXML(&encodeState{}, nil, "root")
+}
+
+// orderedMapType is the reflect.Type of *OrderedMap, which the encoder
+// handles ahead of its MarshalXML method so it can use the field name.
+var orderedMapType = reflect.TypeOf((*OrderedMap)(nil))
+
+// xmlOrderedMapEnc encodes an *OrderedMap or OrderedMap value as elemName.
+func xmlOrderedMapEnc(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+	if rv.Kind() != reflect.Ptr {
+		m := rv.Interface().(OrderedMap)
+		return m.appendXML(es, buf, elemName)
+	}
+	if rv.IsNil() {
+		buf = append(buf, '<')
+		buf = append(buf, elemName...)
+		buf = append(buf, '/', '>')
+		return buf, nil
+	}
+	return rv.Interface().(*OrderedMap).appendXML(es, buf, elemName)
+}
+
+// appendXML appends the element form of m, using the same key conventions
+// as Render but in insertion order.
+func (m *OrderedMap) appendXML(es *encodeState, buf []byte, elemName string) ([]byte, error) {
+	buf = m.appendComments(buf, "#prolog")
+	buf = append(buf, '<')
+	buf = append(buf, elemName...)
+
+	hasContent := false
+	for _, k := range m.keys {
+		if k == "#prolog" || k == "#epilog" {
+			continue
+		}
+		if len(k) == 0 || k[0] != '@' {
+			hasContent = true
+			continue
+		}
+		v := m.values[k]
+		if v == nil {
+			continue
+		}
+		text, ok, err := formatText(es, reflect.ValueOf(v))
+		if err != nil {
+			return buf, err
+		}
+		if !ok {
+			continue
+		}
+		name, err := es.keyName(k[1:])
+		if err != nil {
+			return buf, err
+		}
+		buf = append(buf, ' ')
+		buf = append(buf, name...)
+		buf = append(buf, '=', '"')
+		buf = appendEscapeAttr(buf, text, &es.opts)
+		buf = append(buf, '"')
+	}
+
+	if !hasContent {
+		buf = append(buf, '/', '>')
+		return m.appendComments(buf, "#epilog"), nil
+	}
+	buf = append(buf, '>')
+
+	if content, ok := m.values["#content"].([]interface{}); ok {
+		var err error
+		if buf, err = m.appendContent(es, buf, content); err != nil {
+			return buf, err
+		}
+		buf = append(buf, '<', '/')
+		buf = append(buf, elemName...)
+		buf = append(buf, '>')
+		return m.appendComments(buf, "#epilog"), nil
+	}
+
+	var last lastEncoder
+	for _, k := range m.keys {
+		if len(k) > 0 && k[0] == '@' {
+			continue
+		}
+		v := m.values[k]
+		switch {
+		case k == "#prolog" || k == "#epilog":
+		case k == "#inner":
+			buf = appendRaw(buf, textValue(v), &es.opts)
+		case k == "#text":
+			if raw, ok := v.(RawXML); ok {
+				buf = appendRaw(buf, string(raw), &es.opts)
+			} else if v != nil {
+				text, _, err := formatText(es, reflect.ValueOf(v))
+				if err != nil {
+					return buf, err
+				}
+				buf = AppendEscapedText(buf, text)
+			}
+		case k == "#cdata":
+			if v != nil {
+				text, _, err := formatText(es, reflect.ValueOf(v))
+				if err != nil {
+					return buf, err
+				}
+				buf = appendCDATA(buf, text)
+			}
+		default:
+			name, err := es.keyName(k)
+			if err != nil {
+				return buf, err
+			}
+			if v == nil {
+				buf = append(buf, '<')
+				buf = append(buf, name...)
+				buf = append(buf, '/', '>')
+				continue
+			}
+			buf, err = last.encodeDynamic(es, buf, reflect.ValueOf(v), name)
+			if err != nil {
+				return buf, err
+			}
+		}
+	}
+
+	buf = append(buf, '<', '/')
+	buf = append(buf, elemName...)
+	buf = append(buf, '>')
+	return m.appendComments(buf, "#epilog"), nil
+}
@@ -0,0 +1,165 @@
+package xml
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestOrderedMap_SetGetDelete(t *testing.T) {
+	m := NewOrderedMap()
+	m.Set("b", 1).Set("a", 2).Set("c", 3)
+	m.Set("b", 4) // existing key keeps its position
+
+	if got := m.Keys(); !reflect.DeepEqual(got, []string{"b", "a", "c"}) {
+		t.Errorf("Keys() = %v", got)
+	}
+	if v, ok := m.Get("b"); !ok || v != 4 {
+		t.Errorf("Get(b) = %v, %v", v, ok)
+	}
+	if _, ok := m.Get("missing"); ok {
+		t.Error("Get(missing) reported ok")
+	}
+
+	m.Delete("a")
+	m.Delete("missing")
+	if got := m.Keys(); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("Keys() after Delete = %v", got)
+	}
+	if m.Len() != 2 {
+		t.Errorf("Len() = %d", m.Len())
+	}
+
+	keys := m.Keys()
+	keys[0] = "changed"
+	if m.Keys()[0] != "b" {
+		t.Error("Keys() returned internal slice")
+	}
+}
+
+func TestOrderedMap_ZeroValue(t *testing.T) {
+	var m OrderedMap
+	if m.Len() != 0 {
+		t.Errorf("Len() = %d", m.Len())
+	}
+	m.Set("x", "1")
+	if v, _ := m.Get("x"); v != "1" {
+		t.Errorf("Get(x) = %v", v)
+	}
+}
+
+func TestOrderedMap_Range(t *testing.T) {
+	m := NewOrderedMap().Set("z", 1).Set("y", 2).Set("x", 3)
+
+	var seen []string
+	m.Range(func(key string, value interface{}) bool {
+		seen = append(seen, key)
+		return key != "y"
+	})
+	if !reflect.DeepEqual(seen, []string{"z", "y"}) {
+		t.Errorf("Range visited %v", seen)
+	}
+
+	want := []KeyValue{{Key: "z", Value: 1}, {Key: "y", Value: 2}, {Key: "x", Value: 3}}
+	if got := m.Pairs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Pairs() = %v", got)
+	}
+}
+
+func TestOrderedMap_MarshalJSON(t *testing.T) {
+	m := NewOrderedMap().
+		Set("zeta", "last").
+		Set("alpha", NewOrderedMap().Set("y", 1).Set("x", true)).
+		Set("list", []interface{}{"a", nil})
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	want := `{"zeta":"last","alpha":{"y":1,"x":true},"list":["a",null]}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+
+	b, err = json.Marshal(NewOrderedMap())
+	if err != nil || string(b) != `{}` {
+		t.Errorf("empty map: %s, %v", b, err)
+	}
+}
+
+func TestOrderedMap_MarshalXML(t *testing.T) {
+	m := NewOrderedMap().
+		Set("@id", 7).
+		Set("@note", `a<"b">`).
+		Set("zeta", "z & z").
+		Set("alpha", NewOrderedMap().Set("@sku", "x").Set("#text", "first")).
+		Set("item", []interface{}{"1", "2"}).
+		Set("empty", nil)
+
+	want := `<root id="7" note="a&lt;&#34;b&#34;&gt;"><zeta>z &amp; z</zeta>` +
+		`<alpha sku="x">first</alpha><item>1</item><item>2</item><empty/></root>`
+
+	b, err := m.MarshalXML()
+	if err != nil {
+		t.Fatalf("MarshalXML failed: %v", err)
+	}
+	if string(b) != want {
+		t.Errorf("MarshalXML:\ngot  %s\nwant %s", b, want)
+	}
+
+	b, err = Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(b) != want {
+		t.Errorf("Marshal:\ngot  %s\nwant %s", b, want)
+	}
+}
+
+func TestOrderedMap_MarshalXMLEmptyAndCDATA(t *testing.T) {
+	b, err := NewOrderedMap().Set("@a", "1").MarshalXML()
+	if err != nil || string(b) != `<root a="1"/>` {
+		t.Errorf("attribute-only: %s, %v", b, err)
+	}
+
+	b, err = NewOrderedMap().Set("#cdata", "<raw>").MarshalXML()
+	if err != nil || string(b) != `<root><![CDATA[<raw>]]></root>` {
+		t.Errorf("cdata: %s, %v", b, err)
+	}
+}
+
+func TestOrderedMap_StructField(t *testing.T) {
+	type Envelope struct {
+		Header *OrderedMap `xml:"header"`
+		Body   OrderedMap  `xml:"body"`
+		Extra  *OrderedMap `xml:"extra"`
+	}
+	env := Envelope{Header: NewOrderedMap().Set("to", "a").Set("from", "b")}
+	env.Body.Set("@kind", "ping")
+
+	b, err := Marshal(env)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `<Envelope><header><to>a</to><from>b</from></header><body kind="ping"/></Envelope>`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
+
+func TestOrderedMap_SortedKeysRoundTrip(t *testing.T) {
+	input := []byte(`<order id="7"><total>9.50</total><item sku="b"/><item sku="a"/></order>`)
+
+	var v interface{}
+	if err := UnmarshalWithOptions(input, &v, WithSortedKeys()); err != nil {
+		t.Fatalf("UnmarshalWithOptions failed: %v", err)
+	}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `<root id="7"><item sku="b"/><item sku="a"/><total>9.50</total></root>`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
@@ -110,6 +110,26 @@ func BenchmarkShapeXML_Parse_Large(b *testing.B) {
 	}
 }
 
+// BenchmarkShapeXML_ParseBytes_Large benchmarks ParseBytes with large XML
+// already held as bytes, for comparison with Parse.
+func BenchmarkShapeXML_ParseBytes_Large(b *testing.B) {
+	if err := loadBenchmarkData(); err != nil {
+		b.Fatalf("Failed to load benchmark data: %v", err)
+	}
+
+	data := []byte(largeXML)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		node, err := shapexml.ParseBytes(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		// Prevent compiler optimization
+		_ = node
+	}
+}
+
 // BenchmarkShapeXML_ParseReader_Small benchmarks ParseReader with small XML
 func BenchmarkShapeXML_ParseReader_Small(b *testing.B) {
 	if err := loadBenchmarkData(); err != nil {
@@ -671,6 +691,28 @@ func BenchmarkShapeXML_Unmarshal_Large(b *testing.B) {
 	}
 }
 
+// BenchmarkShapeXML_UnmarshalFiltered_Large benchmarks unmarshaling only the
+// product names from the large file, skipping every other subtree
+func BenchmarkShapeXML_UnmarshalFiltered_Large(b *testing.B) {
+	if err := loadBenchmarkData(); err != nil {
+		b.Fatalf("Failed to load benchmark data: %v", err)
+	}
+
+	filter := shapexml.WithElementFilter("/catalog/products/product/name")
+
+	b.SetBytes(int64(len(largeXML)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var v map[string]interface{}
+		err := shapexml.UnmarshalWithOptions([]byte(largeXML), &v, filter)
+		if err != nil {
+			b.Fatal(err)
+		}
+		// Prevent compiler optimization
+		_ = v
+	}
+}
+
 // ================================
 // Round-trip Benchmarks
 // ================================
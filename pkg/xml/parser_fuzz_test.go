@@ -1,7 +1,9 @@
 package xml
 
 import (
+	"net"
 	"testing"
+	"time"
 )
 
 // FuzzParse fuzzes the Parse function with random XML input
@@ -56,6 +58,38 @@ func FuzzRender(f *testing.F) {
 	})
 }
 
+// FuzzRenderRoundTrip fuzzes Render by checking that its output parses
+// back into an equal tree
+func FuzzRenderRoundTrip(f *testing.F) {
+	// Seed corpus with markup that needs escaping or keeps its order
+	f.Add("<root></root>")
+	f.Add("<user id=\"123\">Alice</user>")
+	f.Add(`<a x="&lt;&amp;&quot;'&gt;">1 &lt; 2 &amp;&amp; 3 &gt; 2</a>`)
+	f.Add(`<a>t<![CDATA[<b>&amp;]]></a>`)
+	f.Add(`<list><i>1</i><i>2</i><j/><i>3</i></list>`)
+	f.Add(`<a xmlns:p="urn:p"><p:b p:c="d">e</p:b></a>`)
+	f.Add(`<!DOCTYPE a [<!ENTITY e "&#38;#60;">]><a>&e;&#x9;&#10;</a>`)
+
+	f.Fuzz(func(t *testing.T, input string) {
+		node, err := Parse(input)
+		if err != nil {
+			// Invalid XML, nothing to round-trip
+			return
+		}
+		out, err := Render(node)
+		if err != nil {
+			return
+		}
+		again, err := Parse(string(out))
+		if err != nil {
+			t.Fatalf("Render output does not parse: %v\ninput:  %q\noutput: %q", err, input, out)
+		}
+		if !Equal(node, again) {
+			t.Fatalf("round trip changed the tree\ninput:  %q\noutput: %q", input, out)
+		}
+	})
+}
+
 // FuzzMarshal fuzzes the Marshal function
 func FuzzMarshal(f *testing.F) {
 	// Seed with simple string values
@@ -74,3 +108,75 @@ func FuzzMarshal(f *testing.F) {
 		_, _ = Marshal(s)
 	})
 }
+
+// fuzzChild is a nested struct target for FuzzUnmarshalStruct.
+type fuzzChild struct {
+	XMLName Name   `xml:"child"`
+	ID      int    `xml:"id,attr"`
+	Text    string `xml:",chardata"`
+	Deeper  *fuzzChild
+}
+
+// fuzzTarget has a field of every kind Unmarshal decodes into.
+type fuzzTarget struct {
+	XMLName  Name                   `xml:"doc"`
+	Attr     string                 `xml:"a,attr"`
+	IntAttr  int8                   `xml:"i,attr"`
+	Count    int                    `xml:"count,attr"`
+	Text     string                 `xml:",chardata"`
+	CData    string                 `xml:",cdata"`
+	Str      string                 `xml:"str"`
+	Int      int64                  `xml:"int"`
+	Uint     uint16                 `xml:"uint"`
+	Float    float32                `xml:"float"`
+	Bool     bool                   `xml:"bool"`
+	Duration time.Duration          `xml:"dur"`
+	Time     time.Time              `xml:"time"`
+	Day      *time.Time             `xml:"day,layout=2006-01-02"`
+	IP       net.IP                 `xml:"ip"`
+	Ptr      *int                   `xml:"ptr"`
+	PtrPtr   **string               `xml:"pp"`
+	Strings  []string               `xml:"s"`
+	Ints     [2]int                 `xml:"n"`
+	Bytes    []byte                 `xml:"b"`
+	Child    fuzzChild              `xml:"child"`
+	Children []*fuzzChild           `xml:"kids>child"`
+	Map      map[string]string      `xml:"map"`
+	Keyed    map[string]fuzzChild   `xml:"entry,key=k"`
+	Any      interface{}            `xml:"any"`
+	Anys     []interface{}          `xml:"anys"`
+	Nested   map[string]interface{} `xml:"nested"`
+	Space    string                 `xml:"urn:x x:space"`
+	Tags     []string               `xml:"tags>tag,countattr=count"`
+}
+
+// FuzzUnmarshalStruct fuzzes Unmarshal into a struct with every supported
+// field kind, and Marshal of whatever it decoded.
+func FuzzUnmarshalStruct(f *testing.F) {
+	// Seed corpus covering each field
+	f.Add(`<doc a="x" i="-3" count="1">text<![CDATA[<c>]]><str>s</str><int>-9</int><uint>7</uint>` +
+		`<float>1.5</float><bool>true</bool><dur>PT1M</dur><time>2024-01-02T03:04:05Z</time>` +
+		`<day>2024-01-02</day><ip>10.0.0.1</ip><ptr>1</ptr><pp>p</pp><s>a</s><s>b</s><n>1</n><n>2</n><n>3</n>` +
+		`<b>raw</b><child id="1">c<Deeper id="2"/></child><kids><child/><child id="3"/></kids>` +
+		`<map><k>v</k></map><entry k="a" id="4"/><entry k="a"/><any x="1"><y/></any><anys>1</anys><anys/>` +
+		`<nested><a><b>c</b></a></nested><x:space xmlns:x="urn:x">ns</x:space><tags><tag>t</tag></tags></doc>`)
+	f.Add(`<doc><int>999999999999999999999</int><uint>-1</uint><float>NaN</float><dur>forever</dur></doc>`)
+	f.Add(`<doc i="200"><time/><day>tomorrow</day><ip>::1</ip><ptr/><s/><entry/></doc>`)
+	f.Add(`<!DOCTYPE doc [<!ENTITY e "&#60;str&#62;">]><doc a="&e;">&e;</doc>`)
+	f.Add(`<doc><child><child><child/></child></child><xsi:nil xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"/></doc>`)
+	f.Add(`<doc><int xsi:nil="true"/><kids>text</kids><map/></doc>`)
+	f.Add(`<!DOCTYPE 00000>`)
+
+	f.Fuzz(func(t *testing.T, input string) {
+		// Ensure neither Unmarshal nor Marshal of its result panics
+		// Errors are expected for invalid input and unconvertible values
+		var v fuzzTarget
+		if err := Unmarshal([]byte(input), &v); err != nil {
+			return
+		}
+		_, _ = Marshal(&v)
+
+		var n interface{}
+		_ = Unmarshal([]byte(input), &n)
+	})
+}
@@ -118,10 +118,14 @@ func TestParse_NestedElements(t *testing.T) {
 		t.Fatalf("Expected *ast.ObjectNode, got %T", node)
 	}
 
-	// For now, we expect child elements under "child" key
-	// In the future, this should use actual element names
-	if len(obj.Properties()) == 0 {
-		t.Error("Expected child elements")
+	props := obj.Properties()
+	for _, name := range []string{"name", "email"} {
+		if _, ok := props[name].(*ast.ObjectNode); !ok {
+			t.Errorf("Expected child element %q, got %v", name, props)
+		}
+	}
+	if _, ok := props["child"]; ok {
+		t.Error("children must be stored under their element names")
 	}
 }
 
@@ -0,0 +1,611 @@
+package xml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shapestone/shape-xml/internal/fastparser"
+)
+
+// Document is a parsed XML document that remembers its original source text.
+//
+// Edits made through the Element returned by Root are written back by Bytes,
+// which rewrites only the byte ranges of the nodes that actually changed: an
+// attribute value, an added or removed attribute or child, the text of a leaf
+// element. Everything else - the XML declaration, comments, whitespace,
+// attribute quoting and element ordering - is copied from the original
+// document unchanged. New children follow their last sibling of the same
+// name, or the last child, indented like it. Names that are not valid XML
+// names make Bytes fail.
+//
+// This makes Document suitable for programmatic editing of hand-maintained files
+// such as configuration documents:
+//
+//	doc, _ := xml.ParseDocument(src)
+//	server, _ := doc.Root().GetChild("server")
+//	server.Attr("port", "8443")
+//	out, _ := doc.Bytes() // only the port value is rewritten
+type Document struct {
+	src  string
+	root *Element
+	span *fastparser.Span
+	orig map[string]interface{}
+}
+
+// documentEdit replaces src[start:end] with text.
+type documentEdit struct {
+	start, end int
+	text       string
+	path       string
+}
+
+// ParseDocument parses XML into a Document that can be edited and written back
+// with minimal changes to the original text.
+func ParseDocument(input string) (*Document, error) {
+	p := fastparser.NewParser([]byte(input))
+	data, span, err := p.ParseWithSpans()
+	if err != nil {
+		return nil, err
+	}
+	return &Document{
+		src:  input,
+		root: &Element{data: data, name: span.Name},
+		span: span,
+		orig: deepCopyMap(data),
+	}, nil
+}
+
+// Root returns the document's root element.
+// Changes made through the returned Element (and elements obtained from it)
+// are picked up by Bytes and Modified.
+func (d *Document) Root() *Element {
+	return d.root
+}
+
+// RootName returns the name of the document's root element.
+func (d *Document) RootName() string {
+	return d.span.Name
+}
+
+// Modified returns the paths of the subtrees that will be rewritten by Bytes,
+// in document order. Paths use the form "/root/child" with a 1-based index
+// for repeated elements, e.g. "/config/server[2]". Attribute-only changes are
+// reported with a trailing "/@".
+func (d *Document) Modified() []string {
+	edits, err := d.edits()
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	seen := make(map[string]bool)
+	for _, e := range edits {
+		if !seen[e.path] {
+			seen[e.path] = true
+			paths = append(paths, e.path)
+		}
+	}
+	return paths
+}
+
+// Bytes returns the document text with all modifications applied.
+// If nothing was modified, the original text is returned unchanged.
+func (d *Document) Bytes() ([]byte, error) {
+	edits, err := d.edits()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(d.src))
+	pos := 0
+	for _, e := range edits {
+		buf.WriteString(d.src[pos:e.start])
+		buf.WriteString(e.text)
+		pos = e.end
+	}
+	buf.WriteString(d.src[pos:])
+	return buf.Bytes(), nil
+}
+
+// String returns the document text with all modifications applied.
+func (d *Document) String() string {
+	b, err := d.Bytes()
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// edits computes the minimal set of non-overlapping edits, sorted by offset.
+// An insertion sorts before a replacement starting at the same offset, and
+// insertions at one offset keep the order they were made in.
+func (d *Document) edits() ([]documentEdit, error) {
+	df := &differ{src: d.src}
+	if err := df.element(d.span, d.orig, d.root.data, "/"+d.span.Name); err != nil {
+		return nil, err
+	}
+	edits := df.edits
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		return edits[i].end < edits[j].end
+	})
+	return edits, nil
+}
+
+// differ collects the edits that bring src up to date with a changed tree.
+type differ struct {
+	src   string
+	edits []documentEdit
+}
+
+// add records the edit replacing src[start:end] with text.
+func (df *differ) add(start, end int, text, path string) {
+	df.edits = append(df.edits, documentEdit{start: start, end: end, text: text, path: path})
+}
+
+// element compares the original and current values of the element at span
+// and records the edits for the parts that changed: single attribute values,
+// added and removed attributes and children, and the text of leaf elements.
+// Only a change to the text of an element with children rewrites it whole.
+func (df *differ) element(span *fastparser.Span, orig, cur map[string]interface{}, path string) error {
+	if reflect.DeepEqual(orig, cur) {
+		return nil
+	}
+	mark := len(df.edits)
+
+	if !reflect.DeepEqual(orig["#text"], cur["#text"]) || !reflect.DeepEqual(orig["#cdata"], cur["#cdata"]) {
+		if len(span.Children) > 0 || hasChildElements(cur) {
+			text, err := renderElementText(span.Name, cur)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			df.add(span.Start, span.End, text, path)
+			return nil
+		}
+		if span.SelfClosing() {
+			df.add(span.StartTagEnd-len("/>"), span.End, ">"+renderContentText(cur)+"</"+span.Name+">", path)
+		} else {
+			df.leafText(span, renderContentText(cur), path)
+		}
+	}
+
+	if err := df.attrs(span, orig, cur, path+"/@"); err != nil {
+		return err
+	}
+	err := df.children(span, orig, cur, path)
+	if err == errRewrite {
+		// Drop the edits made inside the element for the whole rewrite.
+		df.edits = df.edits[:mark]
+		return df.rewrite(span, cur, path)
+	}
+	return err
+}
+
+// leafText records the edits that set the content of the leaf element at
+// span to text. Comments in the content stay where they are: text takes
+// the place of the first run of text between them that is not all
+// whitespace, or of the last run if there is none,
+// and the other runs that are not all whitespace are removed.
+func (df *differ) leafText(span *fastparser.Span, text, path string) {
+	end := span.ContentEnd
+	var runs [][2]int
+	run := span.StartTagEnd
+	for i := run; i < end; {
+		var open, close string
+		switch {
+		case strings.HasPrefix(df.src[i:end], "<![CDATA["):
+			open, close = "<![CDATA[", "]]>"
+		case strings.HasPrefix(df.src[i:end], "<!--"):
+			open, close = "<!--", "-->"
+		default:
+			i++
+			continue
+		}
+		next := i + len(open) + strings.Index(df.src[i+len(open):end], close) + len(close)
+		if open != "<![CDATA[" {
+			runs = append(runs, [2]int{run, i})
+			run = next
+		}
+		i = next
+	}
+	runs = append(runs, [2]int{run, end})
+
+	placed := -1
+	for j, r := range runs {
+		if !isWhitespaceText(df.src[r[0]:r[1]]) {
+			placed = j
+			break
+		}
+	}
+	if placed < 0 {
+		placed = len(runs) - 1
+	}
+	for j, r := range runs {
+		switch {
+		case j == placed:
+			df.add(r[0], r[1], text, path)
+		case !isWhitespaceText(df.src[r[0]:r[1]]):
+			df.add(r[0], r[1], "", path)
+		}
+	}
+}
+
+// errRewrite is returned by children for an element it cannot edit in
+// place.
+var errRewrite = errors.New("xml: element must be rewritten")
+
+// attrs records the edits for the attributes that changed: a new value
+// replaces the old one between its quotes, a removed attribute is deleted
+// with the whitespace before it, and added ones follow the last attribute,
+// quoted like the first.
+func (df *differ) attrs(span *fastparser.Span, orig, cur map[string]interface{}, path string) error {
+	if sameAttrs(orig, cur) {
+		return nil
+	}
+	quote := byte('"')
+	if len(span.Attrs) > 0 {
+		quote = df.src[span.Attrs[0].End-1]
+	}
+	insertAt := span.Start + 1 + len(span.Name)
+	inSource := make(map[string]bool, len(span.Attrs))
+	for _, a := range span.Attrs {
+		key := "@" + a.Name
+		inSource[key] = true
+		insertAt = a.End
+		v, ok := cur[key]
+		switch {
+		case !ok:
+			df.add(df.spaceBefore(a.Start), a.End, "", path)
+		case !reflect.DeepEqual(orig[key], v):
+			q := df.src[a.End-1]
+			valueStart := a.Start + strings.IndexByte(df.src[a.Start:a.End], q) + 1
+			df.add(valueStart, a.End-1, string(AppendEscapedAttr(nil, textValue(v))), path)
+		}
+	}
+
+	// Attributes without a span, such as DTD defaults, are written only
+	// when they change.
+	var added []string
+	for k, v := range cur {
+		if len(k) > 0 && k[0] == '@' && !inSource[k] {
+			if ov, ok := orig[k]; !ok || !reflect.DeepEqual(ov, v) {
+				added = append(added, k)
+			}
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	sort.Strings(added)
+	var sb strings.Builder
+	for _, k := range added {
+		if !isValidXMLName(k[1:]) {
+			return fmt.Errorf("%s: invalid attribute name %q", path, k[1:])
+		}
+		sb.WriteByte(' ')
+		sb.WriteString(k[1:])
+		sb.WriteByte('=')
+		sb.WriteByte(quote)
+		sb.Write(AppendEscapedAttr(nil, textValue(cur[k])))
+		sb.WriteByte(quote)
+	}
+	df.add(insertAt, insertAt, sb.String(), path)
+	return nil
+}
+
+// children records the edits for the child elements of span. Children are
+// matched to their source by identity, so untouched siblings, and the
+// comments and whitespace between them, stay as they are. Between matched
+// children, a replaced child is compared with the one it replaced, a
+// removed one is deleted with the whitespace before it, and an added one is
+// inserted after its preceding sibling on a line indented like it. Children
+// of a name the element did not have follow its last child. If the
+// children of a name were reordered, the element is rewritten whole.
+func (df *differ) children(span *fastparser.Span, orig, cur map[string]interface{}, path string) error {
+	spans := make(map[string][]*fastparser.Span)
+	var names []string
+	for _, child := range span.Children {
+		if _, ok := spans[child.Name]; !ok {
+			names = append(names, child.Name)
+		}
+		spans[child.Name] = append(spans[child.Name], child)
+	}
+	var added []string
+	for k := range cur {
+		if _, ok := spans[k]; !ok && isChildName(k) {
+			added = append(added, k)
+		}
+	}
+	sort.Strings(added)
+
+	// Children of new names follow the last child, or fill an empty
+	// element, whose "/>" then becomes an end tag.
+	end := span.ContentEnd
+	indent := ""
+	if n := len(span.Children); n > 0 {
+		end = span.Children[n-1].End
+		indent = df.src[df.spaceBefore(span.Children[n-1].Start):span.Children[n-1].Start]
+	}
+	expand := len(added) > 0 && span.SelfClosing()
+	if expand {
+		df.add(span.StartTagEnd-len("/>"), span.End, ">", path)
+	}
+
+	for _, name := range append(names, added...) {
+		items, ok := childMaps(cur[name])
+		if !ok {
+			return errRewrite
+		}
+		childSpans := spans[name]
+		repeated := len(items) > 1 || len(childSpans) > 1
+		childPath := func(i int) string {
+			if repeated {
+				return path + "/" + name + "[" + strconv.Itoa(i+1) + "]"
+			}
+			return path + "/" + name
+		}
+
+		if len(childSpans) == 0 {
+			for i, item := range items {
+				text, err := renderChildText(name, item)
+				if err != nil {
+					return fmt.Errorf("%s: %w", childPath(i), err)
+				}
+				df.add(end, end, indent+text, childPath(i))
+			}
+			continue
+		}
+
+		// Match the current children to their spans.
+		index := make(map[uintptr]int, len(childSpans))
+		for k, cs := range childSpans {
+			index[reflect.ValueOf(cs.Value).Pointer()] = k
+		}
+		match := make([]int, len(items))
+		last := -1
+		for j, item := range items {
+			match[j] = -1
+			if k, ok := index[reflect.ValueOf(item).Pointer()]; ok && k > last {
+				match[j], last = k, k
+			} else if ok {
+				return errRewrite
+			}
+		}
+
+		// Walk the gaps between matched children.
+		k := 0      // next span
+		after := -1 // span the next insertion follows
+		for j := 0; j <= len(items); j++ {
+			next := len(childSpans)
+			if j < len(items) && match[j] >= 0 {
+				next = match[j]
+			}
+			if j < len(items) && match[j] < 0 {
+				if k < next {
+					// The child replaces the span at k.
+					o, _ := childMapAt(orig, name, k)
+					if err := df.element(childSpans[k], o, items[j], childPath(k)); err != nil {
+						return err
+					}
+					after = k
+					k++
+					continue
+				}
+				text, err := renderChildText(name, items[j])
+				if err != nil {
+					return fmt.Errorf("%s: %w", childPath(j), err)
+				}
+				if after >= 0 {
+					cs := childSpans[after]
+					df.add(cs.End, cs.End, df.src[df.spaceBefore(cs.Start):cs.Start]+text, childPath(j))
+				} else {
+					cs := childSpans[next]
+					df.add(cs.Start, cs.Start, text+df.src[df.spaceBefore(cs.Start):cs.Start], childPath(j))
+				}
+				continue
+			}
+			for ; k < next; k++ {
+				cs := childSpans[k]
+				df.add(df.spaceBefore(cs.Start), cs.End, "", childPath(k))
+			}
+			if j < len(items) {
+				o, _ := childMapAt(orig, name, k)
+				if err := df.element(childSpans[k], o, items[j], childPath(k)); err != nil {
+					return err
+				}
+				after = k
+				k++
+			}
+		}
+	}
+
+	if expand {
+		df.add(span.End, span.End, "</"+span.Name+">", path)
+	}
+	return nil
+}
+
+// rewrite records the edit replacing the whole element at span with cur.
+func (df *differ) rewrite(span *fastparser.Span, cur map[string]interface{}, path string) error {
+	text, err := renderElementText(span.Name, cur)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	df.add(span.Start, span.End, text, path)
+	return nil
+}
+
+// spaceBefore returns the offset of the whitespace run ending at pos.
+func (df *differ) spaceBefore(pos int) int {
+	for pos > 0 && isSpaceByte(df.src[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+// isSpaceByte reports whether c is XML whitespace.
+func isSpaceByte(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// childMaps returns the element maps stored under a child name, none if
+// there is nothing stored, or false if any of them is not an element map.
+func childMaps(v interface{}) ([]map[string]interface{}, bool) {
+	switch val := v.(type) {
+	case nil:
+		return nil, true
+	case map[string]interface{}:
+		return []map[string]interface{}{val}, true
+	case []interface{}:
+		out := make([]map[string]interface{}, len(val))
+		for i, item := range val {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			out[i] = m
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// sameAttrs reports whether two element maps have identical attributes.
+func sameAttrs(a, b map[string]interface{}) bool {
+	count := 0
+	for k, v := range a {
+		if len(k) > 0 && k[0] == '@' {
+			count++
+			if bv, ok := b[k]; !ok || !reflect.DeepEqual(v, bv) {
+				return false
+			}
+		}
+	}
+	for k := range b {
+		if len(k) > 0 && k[0] == '@' {
+			count--
+		}
+	}
+	return count == 0
+}
+
+// hasChildElements reports whether an element map has any child elements.
+func hasChildElements(m map[string]interface{}) bool {
+	for k := range m {
+		if len(k) > 0 && k[0] != '@' && k[0] != '#' {
+			return true
+		}
+	}
+	return false
+}
+
+// childMapAt returns the i-th child element with the given name.
+func childMapAt(m map[string]interface{}, name string, i int) (map[string]interface{}, bool) {
+	switch v := m[name].(type) {
+	case map[string]interface{}:
+		if i == 0 {
+			return v, true
+		}
+	case []interface{}:
+		if i < len(v) {
+			child, ok := v[i].(map[string]interface{})
+			return child, ok
+		}
+	}
+	return nil, false
+}
+
+// renderChildText renders a new child element after checking its names.
+func renderChildText(name string, m map[string]interface{}) (string, error) {
+	if err := checkNames(name, m); err != nil {
+		return "", err
+	}
+	return renderElementText(name, m)
+}
+
+// checkNames reports the first element or attribute name in the element
+// map m, named name, that is not a valid XML name.
+func checkNames(name string, m map[string]interface{}) error {
+	if !isValidXMLName(name) {
+		return fmt.Errorf("xml: invalid element name %q", name)
+	}
+	for k, v := range m {
+		switch {
+		case len(k) > 0 && k[0] == '@':
+			if !isValidXMLName(k[1:]) {
+				return fmt.Errorf("xml: invalid attribute name %q", k[1:])
+			}
+		case isChildName(k):
+			items, ok := childMaps(v)
+			if !ok {
+				if !isValidXMLName(k) {
+					return fmt.Errorf("xml: invalid element name %q", k)
+				}
+				continue
+			}
+			for _, item := range items {
+				if err := checkNames(k, item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// renderElementText renders a complete element from its map representation.
+func renderElementText(name string, m map[string]interface{}) (string, error) {
+	node, err := InterfaceToNode(m)
+	if err != nil {
+		return "", err
+	}
+	out, err := RenderWithOptions(node, MarshalOptions{RootName: name})
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// renderContentText renders only the content (text and CDATA) of a leaf element.
+func renderContentText(m map[string]interface{}) string {
+	var sb strings.Builder
+	if text, ok := m["#text"]; ok {
+		sb.WriteString(escapeXML(textValue(text)))
+	}
+	if cdata, ok := m["#cdata"]; ok {
+		sb.Write(appendCDATA(nil, textValue(cdata)))
+	}
+	return sb.String()
+}
+
+// deepCopyMap returns a deep copy of an element map.
+func deepCopyMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = deepCopyValue(v)
+	}
+	return out
+}
+
+// deepCopyValue returns a deep copy of a value in the map representation.
+func deepCopyValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return deepCopyMap(val)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = deepCopyValue(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,228 @@
+package xml
+
+import (
+	"strings"
+	"testing"
+)
+
+const patchTestDoc = `<?xml version="1.0"?>
+<!-- service configuration -->
+<config version='1'>
+    <server host="localhost"   port="80"/>
+    <name>demo</name>
+    <!-- backends -->
+    <backend>a</backend>
+    <backend>b</backend>
+</config>
+`
+
+func TestDocument_Unmodified(t *testing.T) {
+	doc, err := ParseDocument(patchTestDoc)
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	out, err := doc.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error = %v", err)
+	}
+	if string(out) != patchTestDoc {
+		t.Errorf("expected unchanged document, got:\n%s", out)
+	}
+	if len(doc.Modified()) != 0 {
+		t.Errorf("expected no modifications, got %v", doc.Modified())
+	}
+	if doc.RootName() != "config" {
+		t.Errorf("RootName() = %q", doc.RootName())
+	}
+}
+
+func TestDocument_AttributeChange(t *testing.T) {
+	doc, err := ParseDocument(patchTestDoc)
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	server, ok := doc.Root().GetChild("server")
+	if !ok {
+		t.Fatal("expected server child")
+	}
+	server.Attr("port", "8443")
+
+	out := doc.String()
+	want := strings.Replace(patchTestDoc, `<server host="localhost"   port="80"/>`, `<server host="localhost"   port="8443"/>`, 1)
+	if out != want {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	if got := doc.Modified(); len(got) != 1 || got[0] != "/config/server/@" {
+		t.Errorf("Modified() = %v", got)
+	}
+}
+
+func TestDocument_TextChange(t *testing.T) {
+	doc, err := ParseDocument(patchTestDoc)
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	name, _ := doc.Root().GetChild("name")
+	name.Text("prod & staging")
+
+	out := doc.String()
+	want := strings.Replace(patchTestDoc, "<name>demo</name>", "<name>prod &amp; staging</name>", 1)
+	if out != want {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
+
+func TestDocument_TextChangeKeepsComments(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"text around a comment", "<a>x<!-- note -->y</a>", "<a>new<!-- note --></a>"},
+		{"comment first", "<a><!-- note -->x</a>", "<a><!-- note -->new</a>"},
+		{"only a comment", "<a>\n  <!-- note -->\n</a>", "<a>\n  <!-- note -->new</a>"},
+		{"comment text in CDATA", "<a><![CDATA[<!--x-->]]><!--c--></a>", "<a>new<!--c--></a>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseDocument(tt.src)
+			if err != nil {
+				t.Fatalf("ParseDocument() error = %v", err)
+			}
+			doc.Root().Remove("#cdata").Text("new")
+			if got := doc.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDocument_RepeatedChildChange(t *testing.T) {
+	doc, err := ParseDocument(patchTestDoc)
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	backends := doc.Root().ToMap()["backend"].([]interface{})
+	backends[1].(map[string]interface{})["#text"] = "c"
+
+	out := doc.String()
+	want := strings.Replace(patchTestDoc, "<backend>b</backend>", "<backend>c</backend>", 1)
+	if out != want {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	if got := doc.Modified(); len(got) != 1 || got[0] != "/config/backend[2]" {
+		t.Errorf("Modified() = %v", got)
+	}
+}
+
+func TestDocument_StructuralChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		edit     func(root *Element)
+		old, new string
+		modified []string
+	}{
+		{
+			"remove children", func(root *Element) { root.Remove("backend") },
+			"\n    <backend>a</backend>\n    <backend>b</backend>", "",
+			[]string{"/config/backend[1]", "/config/backend[2]"},
+		},
+		{
+			"remove child at", func(root *Element) { root.RemoveChildAt("backend", 0) },
+			"\n    <backend>a</backend>", "",
+			[]string{"/config/backend[1]"},
+		},
+		{
+			"append child", func(root *Element) { root.AppendChild("backend", NewElement().Text("c")) },
+			"<backend>b</backend>", "<backend>b</backend>\n    <backend>c</backend>",
+			[]string{"/config/backend[3]"},
+		},
+		{
+			"new child name", func(root *Element) { root.AppendChild("item", NewElement().Attr("id", "1")) },
+			"<backend>b</backend>", "<backend>b</backend>\n    <item id=\"1\"/>",
+			[]string{"/config/item"},
+		},
+		{
+			"replace child", func(root *Element) { root.ChildText("name", "prod") },
+			"<name>demo</name>", "<name>prod</name>",
+			[]string{"/config/name"},
+		},
+		{
+			"child of empty element", func(root *Element) {
+				server, _ := root.GetChild("server")
+				server.ChildText("tls", "on")
+			},
+			`port="80"/>`, `port="80"><tls>on</tls></server>`,
+			[]string{"/config/server", "/config/server/tls"},
+		},
+		{
+			"attributes keep their quotes", func(root *Element) { root.Attr("version", "2").Attr("mode", "x") },
+			"<config version='1'>", "<config version='2' mode='x'>",
+			[]string{"/config/@"},
+		},
+		{
+			"remove attribute", func(root *Element) {
+				server, _ := root.GetChild("server")
+				server.RemoveAttr("port")
+			},
+			`<server host="localhost"   port="80"/>`, `<server host="localhost"/>`,
+			[]string{"/config/server/@"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseDocument(patchTestDoc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(doc.Root())
+			want := strings.Replace(patchTestDoc, tt.old, tt.new, 1)
+			if got := doc.String(); got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+			if got := doc.Modified(); strings.Join(got, " ") != strings.Join(tt.modified, " ") {
+				t.Errorf("Modified() = %v, want %v", got, tt.modified)
+			}
+		})
+	}
+}
+
+func TestDocument_InvalidNames(t *testing.T) {
+	doc, err := ParseDocument(patchTestDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, _ := doc.Root().GetChild("server")
+	server.Attr("@port", "8443")
+	if _, err := doc.Bytes(); err == nil || !strings.Contains(err.Error(), `invalid attribute name "@port"`) {
+		t.Errorf("Bytes() error = %v", err)
+	}
+
+	doc, err = ParseDocument(patchTestDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Root().AppendChild("first name", NewElement())
+	if _, err := doc.Bytes(); err == nil || !strings.Contains(err.Error(), `invalid element name "first name"`) {
+		t.Errorf("Bytes() error = %v", err)
+	}
+}
+
+func TestParseDocument_Invalid(t *testing.T) {
+	if _, err := ParseDocument("<a><b></a>"); err == nil {
+		t.Error("expected error for malformed document")
+	}
+}
+
+func TestDocument_CDATAChange(t *testing.T) {
+	doc, err := ParseDocument(patchTestDoc)
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	name, _ := doc.Root().GetChild("name")
+	name.Remove("#text").CDATA("x]]>y")
+
+	out := doc.String()
+	want := strings.Replace(patchTestDoc, "<name>demo</name>", "<name><![CDATA[x]]]]><![CDATA[>y]]></name>", 1)
+	if out != want {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
@@ -0,0 +1,442 @@
+package xml
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Query is a compiled path expression that can be evaluated against many
+// documents. Compile once with CompileQuery and reuse the result; a Query is
+// immutable and safe for concurrent use by multiple goroutines.
+//
+// The supported syntax is a small subset of XPath:
+//
+//	/orders/order/total     absolute path from the root element
+//	order/total             path relative to the element passed to Find
+//	//total                 "//" matches at any depth
+//	/orders/*/total         "*" matches any element name
+//	/orders/order[2]        1-based position among same-named siblings
+//	//order[@status]        element has the attribute
+//	//order[@status='paid'] attribute has the value (single or double quotes)
+//	/orders/order/@id       trailing attribute step selects attribute values
+//
+// Example:
+//
+//	var totals = xml.MustCompileQuery("/orders/order/total")
+//
+//	func sum(doc *xml.Element) []string {
+//	    return totals.Values(doc)
+//	}
+type Query struct {
+	expr     string
+	absolute bool
+	steps    []queryStep
+	// attr is the attribute selected by a trailing "@name" step, "" if none.
+	attr string
+	// namespaces is the context given to CompileQueryNS, nil for CompileQuery.
+	namespaces NamespaceContext
+}
+
+// queryStep is one element step of a Query.
+type queryStep struct {
+	// descendant is true when the step was reached via "//".
+	descendant bool
+	// name is the element name, or "*" for any element.
+	name  string
+	preds []queryPred
+	// qualified is set by CompileQueryNS: elements then match by their
+	// resolved name {space}local instead of by name.
+	qualified bool
+	space     string
+	local     string
+}
+
+// queryPred is a bracketed step predicate: either a position or an attribute test.
+type queryPred struct {
+	// index is the 1-based position for "[n]", 0 for attribute predicates.
+	index    int
+	attr     string
+	value    string
+	hasValue bool
+}
+
+// CompileQuery parses a path expression into a reusable Query.
+func CompileQuery(expr string) (*Query, error) {
+	qp := queryParser{expr: expr}
+	q, err := qp.parse()
+	if err != nil {
+		return nil, fmt.Errorf("xml: invalid query %q: %w", expr, err)
+	}
+	return q, nil
+}
+
+// CompileQueryNS is like CompileQuery, but element names in expr are
+// resolved with namespaces and match elements by namespace URI and local
+// name, whatever prefixes the document uses. An unprefixed name is in the
+// namespace bound to "" in namespaces, or in no namespace if there is none.
+// Attribute names are matched as written.
+//
+// Example:
+//
+//	q, err := xml.CompileQueryNS("/s:Envelope/s:Body", xml.NamespaceContext{
+//	    "s": "http://schemas.xmlsoap.org/soap/envelope/",
+//	})
+//	// matches <soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+//	// as well as <env:Envelope xmlns:env="..."> with the same URI
+func CompileQueryNS(expr string, namespaces NamespaceContext) (*Query, error) {
+	q, err := CompileQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	for i := range q.steps {
+		step := &q.steps[i]
+		if step.name == "*" {
+			continue
+		}
+		prefix, local := SplitName(step.name)
+		space, ok := namespaces[prefix]
+		if !ok && prefix != "" {
+			return nil, fmt.Errorf("xml: invalid query %q: prefix %q is not bound", expr, prefix)
+		}
+		step.qualified, step.space, step.local = true, space, local
+	}
+	q.namespaces = NamespaceContext{}
+	for prefix, uri := range namespaces {
+		q.namespaces[prefix] = uri
+	}
+	return q, nil
+}
+
+// MustCompileQuery is like CompileQuery but panics if the expression is invalid.
+// It simplifies initialization of global query variables.
+func MustCompileQuery(expr string) *Query {
+	q, err := CompileQuery(expr)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// String returns the source text of the query.
+func (q *Query) String() string {
+	return q.expr
+}
+
+// Find returns the elements matched by the query, evaluated against e.
+// Absolute queries start at e's root element, relative queries at e itself.
+// For queries ending in an attribute step, Find returns the elements that
+// carry the attribute.
+//
+// Same-named siblings are returned in document order; siblings with
+// different names are returned in name order because the map representation
+// does not record their relative order.
+func (q *Query) Find(e *Element) []*Element {
+	var ctx []*Element
+	steps := q.steps
+	if q.absolute {
+		root := e.Root()
+		groups := [][]*Element{{root}}
+		if steps[0].descendant {
+			groups = append(groups, childElements(root))
+			groups = append(groups, descendantGroups(root)...)
+		}
+		ctx = steps[0].filter(groups)
+		steps = steps[1:]
+	} else {
+		ctx = []*Element{e}
+	}
+
+	for _, step := range steps {
+		if len(ctx) == 0 {
+			break
+		}
+		var groups [][]*Element
+		for _, c := range ctx {
+			groups = append(groups, childElements(c))
+			if step.descendant {
+				groups = append(groups, descendantGroups(c)...)
+			}
+		}
+		ctx = dedupElements(step.filter(groups))
+	}
+
+	if q.attr == "" {
+		return ctx
+	}
+	matched := ctx[:0]
+	for _, c := range ctx {
+		if q.attr == "*" && len(c.Attrs()) > 0 || c.HasAttr(q.attr) {
+			matched = append(matched, c)
+		}
+	}
+	return matched
+}
+
+// First returns the first element matched by the query.
+// Returns nil and false if nothing matches.
+func (q *Query) First(e *Element) (*Element, bool) {
+	found := q.Find(e)
+	if len(found) == 0 {
+		return nil, false
+	}
+	return found[0], true
+}
+
+// Values returns the values matched by the query: attribute values for
+// queries ending in an attribute step, otherwise the text content of each
+// matched element ("" for elements without text).
+func (q *Query) Values(e *Element) []string {
+	found := q.Find(e)
+	values := make([]string, 0, len(found))
+	for _, el := range found {
+		if q.attr == "" {
+			text, _ := el.GetText()
+			values = append(values, text)
+			continue
+		}
+		if q.attr != "*" {
+			v, _ := el.GetAttr(q.attr)
+			values = append(values, v)
+			continue
+		}
+		attrs := el.Attrs()
+		sort.Strings(attrs)
+		for _, a := range attrs {
+			v, _ := el.GetAttr(a)
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// filter applies the step's name test and predicates to each group of
+// sibling candidates and returns the survivors in order.
+func (s *queryStep) filter(groups [][]*Element) []*Element {
+	var out []*Element
+	for _, group := range groups {
+		matched := make([]*Element, 0, len(group))
+		for _, el := range group {
+			if s.name == "*" || s.matchName(el) {
+				matched = append(matched, el)
+			}
+		}
+		for _, pred := range s.preds {
+			matched = pred.apply(matched)
+		}
+		out = append(out, matched...)
+	}
+	return out
+}
+
+// matchName reports whether el has the step's element name.
+func (s *queryStep) matchName(el *Element) bool {
+	if s.qualified {
+		return el.LocalName() == s.local && el.NamespaceURI() == s.space
+	}
+	return el.name == s.name
+}
+
+// apply filters candidates by the predicate.
+func (p *queryPred) apply(candidates []*Element) []*Element {
+	if p.index > 0 {
+		if p.index > len(candidates) {
+			return nil
+		}
+		return candidates[p.index-1 : p.index]
+	}
+	out := candidates[:0:0]
+	for _, el := range candidates {
+		if p.matchAttr(el.data) {
+			out = append(out, el)
+		}
+	}
+	return out
+}
+
+// matchAttr reports whether an element map satisfies an attribute predicate.
+func (p *queryPred) matchAttr(data map[string]interface{}) bool {
+	v, ok := data["@"+p.attr]
+	if !ok {
+		return false
+	}
+	if !p.hasValue {
+		return true
+	}
+	return fmt.Sprint(v) == p.value
+}
+
+// childElements returns e's child elements, ordered by name and then by
+// position among same-named siblings.
+func childElements(e *Element) []*Element {
+	names := e.Children()
+	sort.Strings(names)
+
+	var out []*Element
+	for _, name := range names {
+		switch v := e.data[name].(type) {
+		case map[string]interface{}:
+			out = append(out, e.child(v, name))
+		case []interface{}:
+			for _, item := range v {
+				if m, ok := item.(map[string]interface{}); ok {
+					out = append(out, e.child(m, name))
+				}
+			}
+		}
+	}
+	return out
+}
+
+// descendantGroups returns the children of every descendant of e, grouped by
+// parent, in depth-first order. Together with childElements(e) this covers
+// every element below e.
+func descendantGroups(e *Element) [][]*Element {
+	var groups [][]*Element
+	var walk func([]*Element)
+	walk = func(children []*Element) {
+		for _, child := range children {
+			if kids := childElements(child); len(kids) > 0 {
+				groups = append(groups, kids)
+				walk(kids)
+			}
+		}
+	}
+	walk(childElements(e))
+	return groups
+}
+
+// dedupElements removes elements wrapping the same map, which "//" steps can
+// reach through more than one context element.
+func dedupElements(els []*Element) []*Element {
+	if len(els) < 2 {
+		return els
+	}
+	seen := make(map[uintptr]bool, len(els))
+	out := els[:0]
+	for _, el := range els {
+		key := reflect.ValueOf(el.data).Pointer()
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, el)
+		}
+	}
+	return out
+}
+
+// queryParser parses a path expression.
+type queryParser struct {
+	expr string
+	pos  int
+}
+
+func (p *queryParser) parse() (*Query, error) {
+	if p.expr == "" {
+		return nil, fmt.Errorf("empty expression")
+	}
+	q := &Query{expr: p.expr}
+	q.absolute = strings.HasPrefix(p.expr, "/")
+
+	first := true
+	for {
+		descendant := false
+		switch {
+		case strings.HasPrefix(p.expr[p.pos:], "//"):
+			descendant = true
+			p.pos += 2
+		case strings.HasPrefix(p.expr[p.pos:], "/"):
+			p.pos++
+		case !first:
+			return nil, fmt.Errorf("expected '/' at offset %d", p.pos)
+		}
+		first = false
+
+		if p.pos < len(p.expr) && p.expr[p.pos] == '@' {
+			p.pos++
+			name := p.name()
+			if name == "" {
+				return nil, fmt.Errorf("expected attribute name at offset %d", p.pos)
+			}
+			if descendant || p.pos != len(p.expr) {
+				return nil, fmt.Errorf("attribute step must be the last step")
+			}
+			if q.absolute && len(q.steps) == 0 {
+				return nil, fmt.Errorf("attribute step needs an element step before it")
+			}
+			q.attr = name
+			return q, nil
+		}
+
+		name := p.name()
+		if name == "" {
+			return nil, fmt.Errorf("expected element name at offset %d", p.pos)
+		}
+		step := queryStep{descendant: descendant, name: name}
+		for p.pos < len(p.expr) && p.expr[p.pos] == '[' {
+			pred, err := p.predicate()
+			if err != nil {
+				return nil, err
+			}
+			step.preds = append(step.preds, pred)
+		}
+		q.steps = append(q.steps, step)
+
+		if p.pos == len(p.expr) {
+			return q, nil
+		}
+	}
+}
+
+// name scans an XML name or "*".
+func (p *queryParser) name() string {
+	if p.pos < len(p.expr) && p.expr[p.pos] == '*' {
+		p.pos++
+		return "*"
+	}
+	start := p.pos
+	for p.pos < len(p.expr) && !strings.ContainsRune("/[]@='\"", rune(p.expr[p.pos])) {
+		p.pos++
+	}
+	name := p.expr[start:p.pos]
+	if !isValidXMLName(name) {
+		p.pos = start
+		return ""
+	}
+	return name
+}
+
+// predicate scans "[n]", "[@name]" or "[@name='value']".
+func (p *queryParser) predicate() (queryPred, error) {
+	p.pos++ // '['
+	end := strings.IndexByte(p.expr[p.pos:], ']')
+	if end < 0 {
+		return queryPred{}, fmt.Errorf("unterminated predicate at offset %d", p.pos-1)
+	}
+	body := p.expr[p.pos : p.pos+end]
+	p.pos += end + 1
+
+	if n, err := strconv.Atoi(body); err == nil {
+		if n < 1 {
+			return queryPred{}, fmt.Errorf("position must be at least 1, got %d", n)
+		}
+		return queryPred{index: n}, nil
+	}
+	if !strings.HasPrefix(body, "@") {
+		return queryPred{}, fmt.Errorf("unsupported predicate [%s]", body)
+	}
+	body = body[1:]
+	name, value, hasValue := strings.Cut(body, "=")
+	if !isValidXMLName(name) {
+		return queryPred{}, fmt.Errorf("invalid attribute name %q in predicate", name)
+	}
+	pred := queryPred{attr: name, hasValue: hasValue}
+	if hasValue {
+		if len(value) < 2 || (value[0] != '\'' && value[0] != '"') || value[len(value)-1] != value[0] {
+			return queryPred{}, fmt.Errorf("predicate value must be quoted: [@%s]", body)
+		}
+		pred.value = value[1 : len(value)-1]
+	}
+	return pred, nil
+}
@@ -0,0 +1,158 @@
+package xml
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const queryTestDoc = `<orders region="eu">
+  <order id="1" status="paid"><total>10.00</total><item sku="a"/><item sku="b"/></order>
+  <order id="2" status="open"><total>20.00</total><note><total>ignored</total></note></order>
+  <order id="3" status="paid"><total>30.00</total></order>
+</orders>`
+
+func queryTestRoot(t *testing.T) *Element {
+	t.Helper()
+	doc, err := ParseDocument(queryTestDoc)
+	if err != nil {
+		t.Fatalf("ParseDocument failed: %v", err)
+	}
+	return doc.Root()
+}
+
+func TestQuery_Values(t *testing.T) {
+	root := queryTestRoot(t)
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"/orders/order/total", []string{"10.00", "20.00", "30.00"}},
+		{"/orders/order[2]/total", []string{"20.00"}},
+		{"/orders/order[@status='paid']/total", []string{"10.00", "30.00"}},
+		{`/orders/order[@status="open"]/@id`, []string{"2"}},
+		{"/orders/order/@id", []string{"1", "2", "3"}},
+		{"/orders/*/total", []string{"10.00", "20.00", "30.00"}},
+		{"//total", []string{"10.00", "20.00", "ignored", "30.00"}},
+		{"/orders//note/total", []string{"ignored"}},
+		{"//item/@sku", []string{"a", "b"}},
+		{"//order[@status][3]/@id", []string{"3"}},
+		{"/orders/@region", []string{"eu"}},
+		{"//orders/@region", []string{"eu"}},
+		{"/orders/order[4]", []string{}},
+		{"/other/order", []string{}},
+		{"/orders/order/missing", []string{}},
+	}
+	for _, tt := range tests {
+		q, err := CompileQuery(tt.expr)
+		if err != nil {
+			t.Errorf("CompileQuery(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if got := q.Values(root); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestQuery_Relative(t *testing.T) {
+	root := queryTestRoot(t)
+	first, ok := MustCompileQuery("/orders/order").First(root)
+	if !ok {
+		t.Fatal("expected a first order")
+	}
+
+	if got := MustCompileQuery("total").Values(first); !reflect.DeepEqual(got, []string{"10.00"}) {
+		t.Errorf("relative query got %q", got)
+	}
+	if got := MustCompileQuery("@status").Values(first); !reflect.DeepEqual(got, []string{"paid"}) {
+		t.Errorf("relative attribute query got %q", got)
+	}
+	// Absolute queries always start at the root.
+	if got := MustCompileQuery("/orders/order/total").Values(first); len(got) != 3 {
+		t.Errorf("absolute query from a child got %q", got)
+	}
+}
+
+func TestQuery_FindElements(t *testing.T) {
+	root := queryTestRoot(t)
+	items := MustCompileQuery("//item").Find(root)
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(items))
+	}
+	if got := items[1].Path(); got != "/orders/order[1]/item[2]" {
+		t.Errorf("Path() = %q", got)
+	}
+
+	// Results are live: edits show up in the document.
+	items[1].Attr("sku", "z")
+	if got := MustCompileQuery("//item/@sku").Values(root); !reflect.DeepEqual(got, []string{"a", "z"}) {
+		t.Errorf("edit not visible, got %q", got)
+	}
+
+	if _, ok := MustCompileQuery("//missing").First(root); ok {
+		t.Error("First should report no match")
+	}
+}
+
+func TestCompileQuery_Errors(t *testing.T) {
+	for _, expr := range []string{
+		"", "/", "//", "/a/", "/a//", "/a/@", "/@id", "/a/@id/b", "/a//@id",
+		"/a[", "/a[0]", "/a[-1]", "/a[b]", "/a[@]", "/a[@x=y]", "/a[@x='y\"]", "/1a", "a b",
+	} {
+		if _, err := CompileQuery(expr); err == nil {
+			t.Errorf("CompileQuery(%q) expected error", expr)
+		} else if !strings.HasPrefix(err.Error(), "xml: invalid query") {
+			t.Errorf("CompileQuery(%q) error = %v", expr, err)
+		}
+	}
+}
+
+func TestMustCompileQuery_Panics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	MustCompileQuery("/a[")
+}
+
+func TestQuery_String(t *testing.T) {
+	if got := MustCompileQuery("//a[@b='c']").String(); got != "//a[@b='c']" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestQuery_Concurrent(t *testing.T) {
+	q := MustCompileQuery("/orders/order[@status='paid']/total")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			doc, err := ParseDocument(queryTestDoc)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for j := 0; j < 50; j++ {
+				if got := q.Values(doc.Root()); len(got) != 2 {
+					t.Errorf("got %q", got)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkQuery_Compiled(b *testing.B) {
+	doc, _ := ParseDocument(queryTestDoc)
+	q := MustCompileQuery("/orders/order[@status='paid']/total")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.Values(doc.Root())
+	}
+}
@@ -0,0 +1,110 @@
+package xml
+
+import (
+	"bytes"
+	"reflect"
+)
+
+// RawXML is an XML fragment that Marshal and Render write exactly as it
+// is: neither escaped nor laid out again by indentation or EmptyElements.
+// Use it to combine pre-serialized content, such as a signed fragment whose
+// digest must not change, with generated content. The fragment is not
+// checked; it must be well-formed element content.
+//
+// Marshal writes a RawXML value as the content of its element. Render
+// writes literal RawXML values the same way, and also writes the "#inner"
+// content captured by WithMaxDepth and set by Element.Raw verbatim.
+//
+// Example:
+//
+//	type Envelope struct {
+//	    Body      Body
+//	    Signature xml.RawXML
+//	}
+//	env := Envelope{Body: body, Signature: xml.RawXML(signed)}
+//	out, _ := xml.MarshalIndent(env, "", "  ")
+//	// <Signature> holds signed byte for byte.
+type RawXML string
+
+// rawXMLType is the reflect.Type of RawXML, which is written verbatim
+// rather than escaped as a string.
+var rawXMLType = reflect.TypeOf(RawXML(""))
+
+// rawMark encloses raw fragments in output that formatXML lays out
+// afterwards, so that it copies them unchanged. It cannot occur in XML.
+const rawMark = '\x00'
+
+// appendRaw appends the fragment s, enclosed in rawMark if opts reformat
+// the output.
+func appendRaw(buf []byte, s string, opts *MarshalOptions) []byte {
+	if !opts.reformats() {
+		return append(buf, s...)
+	}
+	buf = append(buf, rawMark)
+	buf = append(buf, s...)
+	return append(buf, rawMark)
+}
+
+// xmlRawEnc writes a RawXML value as the content of elemName.
+func xmlRawEnc(es *encodeState, buf []byte, rv reflect.Value, elemName string) ([]byte, error) {
+	buf = append(buf, '<')
+	buf = append(buf, elemName...)
+	buf = append(buf, '>')
+	buf = appendRaw(buf, rv.String(), &es.opts)
+	buf = append(buf, '<', '/')
+	buf = append(buf, elemName...)
+	return append(buf, '>'), nil
+}
+
+// cutRaw splits a raw fragment enclosed in rawMark off the start of src.
+func cutRaw(src []byte) (raw, rest []byte) {
+	end := bytes.IndexByte(src[1:], rawMark)
+	if end < 0 {
+		return src[1:], nil
+	}
+	return src[1 : end+1], src[end+2:]
+}
+
+// Raw replaces the text, CDATA and child elements of the element with the
+// XML fragment xml, which Render and Element.XML write exactly as it is,
+// and returns the Element for chaining. The fragment is stored under
+// "#inner", where WithMaxDepth keeps the inner XML of the elements it cuts
+// off, so that those render unchanged too.
+//
+// Example:
+//
+//	doc := xml.NewElement().
+//	    Child("data", xml.NewElement().Text("payload")).
+//	    Child("Signature", xml.NewElement().Raw(signed))
+func (e *Element) Raw(xml string) *Element {
+	e = e.mutable()
+	for key := range e.data {
+		if (len(key) == 0 || key[0] != '@') && key != "#prolog" && key != "#epilog" {
+			delete(e.data, key)
+		}
+	}
+	e.data["#inner"] = xml
+	return e
+}
+
+// writeText writes the text of the literal value v: escaped, or verbatim
+// if v is RawXML.
+func (r *renderer) writeText(v interface{}, text string) {
+	if _, ok := v.(RawXML); ok {
+		r.writeRaw(text)
+		return
+	}
+	r.buf.WriteString(escapeXML(text))
+}
+
+// writeRaw writes the fragment s unchanged.
+func (r *renderer) writeRaw(s string) {
+	mark := r.opts.reformats()
+	if mark {
+		r.buf.WriteByte(rawMark)
+	}
+	r.buf.WriteString(s)
+	if mark {
+		r.buf.WriteByte(rawMark)
+	}
+}
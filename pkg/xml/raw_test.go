@@ -0,0 +1,96 @@
+package xml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// signedFragment is laid out in a way indentation, EmptyElements and
+// LiteralQuotes would all change.
+const signedFragment = "<SignedInfo>\n\t<Ref  URI='#a'/><Digest></Digest>&#34;\n</SignedInfo>"
+
+type rawEnvelope struct {
+	Body      string
+	Signature RawXML
+}
+
+func TestRawXML_Marshal(t *testing.T) {
+	env := rawEnvelope{Body: "a<b", Signature: RawXML(signedFragment)}
+	for _, opts := range []MarshalOptions{
+		{},
+		{Indent: "  "},
+		{Indent: "\t", EmptyElements: EmptyExpanded, LiteralQuotes: true},
+	} {
+		out, err := MarshalWithOptions(env, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(out, []byte("<Signature>"+signedFragment+"</Signature>")) {
+			t.Errorf("%+v: fragment changed:\n%s", opts, out)
+		}
+		if !bytes.Contains(out, []byte("a&lt;b")) || bytes.IndexByte(out, rawMark) >= 0 {
+			t.Errorf("%+v: output = %q", opts, out)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.SetOptions(MarshalOptions{Indent: "  "}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(env); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), signedFragment) {
+		t.Errorf("Encoder: fragment changed:\n%s", buf.String())
+	}
+}
+
+func TestRawXML_Render(t *testing.T) {
+	doc := NewElement().
+		Child("body", NewElement().Text("x")).
+		Child("Signature", NewElement().Attr("id", "s").Raw(signedFragment))
+	want := `<root><Signature id="s">` + signedFragment + `</Signature><body>x</body></root>`
+	if out, _ := doc.XML("root"); out != want {
+		t.Errorf("XML\ngot  %s\nwant %s", out, want)
+	}
+	out, err := doc.XMLIndent("root", "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "  <Signature id=\"s\">"+signedFragment+"</Signature>\n") {
+		t.Errorf("XMLIndent:\n%s", out)
+	}
+
+	node, err := InterfaceToNode(map[string]interface{}{"sig": RawXML("<a>\n</a>"), "t": "<"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := RenderWithOptions(node, MarshalOptions{Indent: " "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<root>\n <sig><a>\n</a></sig>\n <t>&lt;</t>\n</root>"; string(got) != want {
+		t.Errorf("RenderWithOptions\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestRawXML_MaxDepthRoundTrip(t *testing.T) {
+	input := "<doc><part id=\"1\">\n\t<x  a='1'/>\r\n\t<y></y>\n</part><part id=\"2\">text</part></doc>"
+	elem, err := ParseElementWithOptions(input, WithMaxDepth(2, DepthSummaryRaw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := elem.XMLIndent("doc", "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := "<part id=\"1\">\n\t<x  a='1'/>\r\n\t<y></y>\n</part>"
+	if !strings.Contains(out, inner) {
+		t.Errorf("inner XML changed:\n%q", out)
+	}
+}
@@ -0,0 +1,65 @@
+package xml
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is returned in place of a panic that occurred while parsing
+// with WithRecover. Value is the value passed to panic and Stack the stack
+// trace of the panicking goroutine.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("xml: internal panic: %v", e.Value)
+}
+
+// Unwrap returns Value if it is an error, such as a runtime.Error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// WithRecover makes ParseWithOptions, ParseElementWithOptions and
+// UnmarshalWithOptions return a *PanicError instead of panicking if parsing
+// or decoding panics, whether in the parser or in an Unmarshaler or
+// UnmarshalText method it calls. If hook is not nil, it is called with the
+// error before it is returned, e.g. to log the stack or count occurrences.
+//
+// The parser is not expected to panic on any input; WithRecover is defense
+// in depth for services handling untrusted documents, so that a bug costs
+// one request rather than the process. NodeToInterfaceWithOptions cannot
+// return errors and ignores WithRecover.
+//
+// Example:
+//
+//	err := xml.UnmarshalWithOptions(body, &v, xml.WithRecover(func(e *xml.PanicError) {
+//	    log.Printf("xml: recovered %v\n%s", e.Value, e.Stack)
+//	}))
+func WithRecover(hook func(*PanicError)) ParseOption {
+	return func(o *ParseOptions) {
+		o.Recover = true
+		o.PanicHook = hook
+	}
+}
+
+// recoverPanic stores a panic in progress as a *PanicError in *err if
+// o.Recover is set, and reports it to o.PanicHook. It must be deferred
+// directly so that recover stops the panic.
+func (o *ParseOptions) recoverPanic(err *error) {
+	if !o.Recover {
+		return
+	}
+	r := recover()
+	if r == nil {
+		return
+	}
+	pe := &PanicError{Value: r, Stack: debug.Stack()}
+	if o.PanicHook != nil {
+		o.PanicHook(pe)
+	}
+	*err = pe
+}
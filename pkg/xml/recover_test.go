@@ -0,0 +1,94 @@
+package xml
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type panicUnmarshaler struct{}
+
+func (*panicUnmarshaler) UnmarshalXML(data []byte) error {
+	var m map[string]int
+	m["boom"]++ // nil map write: a runtime.Error
+	return nil
+}
+
+type panicText struct{}
+
+func (*panicText) UnmarshalText(text []byte) error {
+	panic("bad " + string(text))
+}
+
+func TestWithRecover_Unmarshal(t *testing.T) {
+	var hooked *PanicError
+	var v panicUnmarshaler
+	err := UnmarshalWithOptions([]byte(`<a/>`), &v, WithRecover(func(e *PanicError) { hooked = e }))
+
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected *PanicError, got %v", err)
+	}
+	if hooked != pe {
+		t.Error("hook was not called with the returned error")
+	}
+	var re runtime.Error
+	if !errors.As(err, &re) {
+		t.Errorf("expected the runtime.Error to unwrap, got %v", err)
+	}
+	if !strings.Contains(string(pe.Stack), "UnmarshalXML") {
+		t.Errorf("stack does not show the panicking method:\n%s", pe.Stack)
+	}
+	if !strings.HasPrefix(err.Error(), "xml: internal panic: ") {
+		t.Errorf("unexpected message %q", err)
+	}
+}
+
+func TestWithRecover_NestedFieldWithoutHook(t *testing.T) {
+	var v struct {
+		F panicText `xml:"f"`
+	}
+	err := UnmarshalWithOptions([]byte(`<a><f>x</f></a>`), &v, WithRecover(nil))
+	var pe *PanicError
+	if !errors.As(err, &pe) || pe.Value != "bad x" {
+		t.Fatalf("expected *PanicError for \"bad x\", got %v", err)
+	}
+	if pe.Unwrap() != nil {
+		t.Errorf("Unwrap of a non-error value = %v, want nil", pe.Unwrap())
+	}
+}
+
+func TestWithRecover_NoPanic(t *testing.T) {
+	called := false
+	hook := WithRecover(func(*PanicError) { called = true })
+
+	var v struct {
+		B string `xml:"b"`
+	}
+	if err := UnmarshalWithOptions([]byte(`<a><b>x</b></a>`), &v, hook); err != nil || v.B != "x" {
+		t.Errorf("UnmarshalWithOptions = %v, %+v", err, v)
+	}
+	if _, err := ParseWithOptions(`<a><b>x</b></a>`, hook); err != nil {
+		t.Errorf("ParseWithOptions: %v", err)
+	}
+	if _, err := ParseWithOptions(`<a>`, hook); err == nil || errors.As(err, new(*PanicError)) {
+		t.Errorf("ParseWithOptions on bad input = %v, want a syntax error", err)
+	}
+	if _, err := ParseElementWithOptions(`<a x="1"/>`, hook); err != nil {
+		t.Errorf("ParseElementWithOptions: %v", err)
+	}
+	if called {
+		t.Error("hook called without a panic")
+	}
+}
+
+func TestWithoutRecover_Panics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected the panic to propagate")
+		}
+	}()
+	var v panicUnmarshaler
+	UnmarshalWithOptions([]byte(`<a/>`), &v)
+}
@@ -7,37 +7,12 @@ package xml
 import (
 	"bytes"
 	"fmt"
-	"html"
 	"sort"
 	"strings"
-	"sync"
 
 	"github.com/shapestone/shape-core/pkg/ast"
 )
 
-// bufferPool is a pool of bytes.Buffer instances to reduce allocations during rendering.
-// Buffers are returned to the pool after use to minimize GC pressure.
-var bufferPool = sync.Pool{
-	New: func() interface{} {
-		return bytes.NewBuffer(make([]byte, 0, 1024))
-	},
-}
-
-// getBuffer retrieves a buffer from the pool and resets it for use.
-func getBuffer() *bytes.Buffer {
-	buf := bufferPool.Get().(*bytes.Buffer)
-	buf.Reset()
-	return buf
-}
-
-// putBuffer returns a buffer to the pool if it's not too large.
-// Buffers larger than 64KB are not pooled to avoid holding excessive memory.
-func putBuffer(buf *bytes.Buffer) {
-	if buf.Cap() <= 64*1024 {
-		bufferPool.Put(buf)
-	}
-}
-
 // Render converts an AST node to compact XML bytes.
 //
 // The node should be the result of Parse() or ParseReader().
@@ -47,6 +22,9 @@ func putBuffer(buf *bytes.Buffer) {
 //   - Properties prefixed with "@" are attributes
 //   - Property "#text" contains text content
 //   - Property "#cdata" contains CDATA sections
+//   - Property "#content" holds the content order (see WithMixedContent),
+//     and "#prolog" and "#epilog" the comments around the root (see
+//     WithComments)
 //   - Other properties are child elements
 //
 // Example:
@@ -55,23 +33,36 @@ func putBuffer(buf *bytes.Buffer) {
 //	bytes, _ := xml.Render(node)
 //	// bytes: <user id="123"><name>Alice</name></user>
 func Render(node ast.SchemaNode) ([]byte, error) {
-	buf := getBuffer()
+	return RenderWithOptions(node, MarshalOptions{})
+}
+
+// RenderWithOptions works like Render but applies the given options, such as
+// the FloatPolicy used for NaN and infinite literal values. The root name,
+// declaration and layout options apply as in MarshalWithOptions; AttrOrder
+// does not, as the AST holds no attribute order. PreserveOrder writes
+// attributes and children in the order the parser recorded for them.
+func RenderWithOptions(node ast.SchemaNode, opts MarshalOptions) ([]byte, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	name := rootName(opts, "root")
+	buf := getBuffer(estimateRenderSize(node, name))
 	defer putBuffer(buf)
 
-	if err := renderNode(node, buf, false, "", "", "root"); err != nil {
+	r := &renderer{buf: buf, opts: opts}
+	if err := r.renderNode(node, 0, name); err != nil {
 		return nil, err
 	}
 
-	// Must copy since buffer will be returned to pool
-	result := make([]byte, buf.Len())
-	copy(result, buf.Bytes())
-	return result, nil
+	// finishDocument copies, since the buffer is returned to the pool
+	return finishDocument(buf.Bytes(), &opts), nil
 }
 
 // RenderIndent converts an AST node to pretty-printed XML bytes with indentation.
 //
 // The prefix is added to the beginning of each line, and indent specifies
-// the indentation string (typically spaces or tabs).
+// the indentation string (typically spaces or tabs). It is RenderWithOptions
+// with Prefix and Indent set, so the layout is that of MarshalIndent.
 //
 // Common usage:
 //   - RenderIndent(node, "", "  ") - 2-space indentation
@@ -87,70 +78,112 @@ func Render(node ast.SchemaNode) ([]byte, error) {
 //	//   <name>Alice</name>
 //	// </user>
 func RenderIndent(node ast.SchemaNode, prefix, indent string) ([]byte, error) {
-	buf := getBuffer()
-	defer putBuffer(buf)
+	return RenderWithOptions(node, MarshalOptions{Prefix: prefix, Indent: indent})
+}
 
-	if err := renderNode(node, buf, true, prefix, indent, "root"); err != nil {
-		return nil, err
+// estimateRenderSize estimates the compact rendered size of node, so Render
+// can size its buffer up front instead of growing it repeatedly for large
+// trees. It does not count escapes, indentation or namespace declarations,
+// so the buffer may still have to grow.
+func estimateRenderSize(node ast.SchemaNode, elementName string) int {
+	// "<name>" + "</name>"
+	size := 2*len(elementName) + 5
+
+	switch n := node.(type) {
+	case *ast.ObjectNode:
+		for key, child := range n.Properties() {
+			switch {
+			case strings.HasPrefix(key, "@"):
+				// ` name="value"`
+				size += len(key) + 3 + estimateLiteralSize(child)
+			case key == "#text" || key == "#cdata":
+				// CDATA adds "<![CDATA[" and "]]>"
+				size += estimateLiteralSize(child) + 12
+			default:
+				size += estimateRenderSize(child, key)
+			}
+		}
+	case *ast.ArrayDataNode:
+		size = 0
+		for _, elem := range n.Elements() {
+			size += estimateRenderSize(elem, elementName)
+		}
+	case *ast.LiteralNode:
+		size += estimateLiteralSize(n)
 	}
+	return size
+}
 
-	// Must copy since buffer will be returned to pool
-	result := make([]byte, buf.Len())
-	copy(result, buf.Bytes())
-	return result, nil
+// estimateLiteralSize estimates the rendered size of a literal value.
+// Strings are counted at their raw length; escaping may grow them slightly
+// beyond the estimate, in which case the buffer grows as usual.
+func estimateLiteralSize(node ast.SchemaNode) int {
+	literal, ok := node.(*ast.LiteralNode)
+	if !ok {
+		return 0
+	}
+	if s, ok := literal.Value().(string); ok {
+		return len(s)
+	}
+	// Numbers and booleans
+	return 24
 }
 
-// renderNode recursively renders an AST node to the buffer.
-//
-// Parameters:
-//   - node: The AST node to render
-//   - buf: The output buffer
-//   - prettyPrint: Whether to add whitespace for readability
-//   - prefix: String to add at the start of each line
-//   - indent: Indentation string (spaces or tabs)
-//   - elementName: The name of the XML element to render
-func renderNode(node ast.SchemaNode, buf *bytes.Buffer, prettyPrint bool, prefix, indent, elementName string) error {
-	return renderNodeWithDepth(node, buf, prettyPrint, prefix, indent, 0, elementName)
+// renderer holds the settings for a single render call.
+type renderer struct {
+	buf  *bytes.Buffer
+	opts MarshalOptions
 }
 
-// renderNodeWithDepth renders a node with tracking of indentation depth.
-func renderNodeWithDepth(node ast.SchemaNode, buf *bytes.Buffer, prettyPrint bool, prefix, indent string, depth int, elementName string) error {
+// renderNode renders node as the element elementName at depth.
+func (r *renderer) renderNode(node ast.SchemaNode, depth int, elementName string) error {
+	buf := r.buf
 	if node == nil {
 		// Render self-closing tag for nil nodes
-		if prettyPrint && depth > 0 {
-			buf.WriteString(prefix)
-			buf.WriteString(strings.Repeat(indent, depth))
-		}
 		buf.WriteString("<")
 		buf.WriteString(elementName)
 		buf.WriteString("/>")
-		if prettyPrint {
-			buf.WriteString("\n")
-		}
 		return nil
 	}
 
 	switch n := node.(type) {
 	case *ast.ObjectNode:
-		return renderElement(n, buf, prettyPrint, prefix, indent, depth, elementName)
+		if depth > 0 {
+			return r.renderElement(n, depth, elementName)
+		}
+		// Comments kept around the root by WithComments
+		r.renderComments(n.Properties()["#prolog"])
+		if err := r.renderElement(n, depth, elementName); err != nil {
+			return err
+		}
+		r.renderComments(n.Properties()["#epilog"])
+		return nil
 	case *ast.ArrayDataNode:
-		return renderArrayElements(n, buf, prettyPrint, prefix, indent, depth, elementName)
+		return r.renderArrayElements(n, depth, elementName)
 	case *ast.LiteralNode:
 		// Literal nodes should be rendered as text content within an element
-		if prettyPrint && depth > 0 {
-			buf.WriteString(prefix)
-			buf.WriteString(strings.Repeat(indent, depth))
+		text, action, err := formatLiteral(n.Value(), &r.opts)
+		if err != nil {
+			return err
+		}
+		if action == floatNil {
+			buf.Write(appendNilElement(nil, elementName))
+			return nil
+		}
+		if n.Value() == nil {
+			// A nil value is an empty element, as in Marshal.
+			buf.WriteString("<")
+			buf.WriteString(elementName)
+			buf.WriteString("/>")
+			return nil
 		}
 		buf.WriteString("<")
 		buf.WriteString(elementName)
 		buf.WriteString(">")
-		buf.WriteString(escapeXML(fmt.Sprintf("%v", n.Value())))
+		r.writeText(n.Value(), text)
 		buf.WriteString("</")
 		buf.WriteString(elementName)
 		buf.WriteString(">")
-		if prettyPrint {
-			buf.WriteString("\n")
-		}
 		return nil
 	default:
 		return fmt.Errorf("unknown node type: %T", node)
@@ -158,15 +191,10 @@ func renderNodeWithDepth(node ast.SchemaNode, buf *bytes.Buffer, prettyPrint boo
 }
 
 // renderElement renders an ObjectNode as an XML element.
-func renderElement(node *ast.ObjectNode, buf *bytes.Buffer, prettyPrint bool, prefix, indent string, depth int, elementName string) error {
+func (r *renderer) renderElement(node *ast.ObjectNode, depth int, elementName string) error {
+	buf := r.buf
 	props := node.Properties()
 
-	// Add indentation if pretty printing
-	if prettyPrint && depth > 0 {
-		buf.WriteString(prefix)
-		buf.WriteString(strings.Repeat(indent, depth))
-	}
-
 	// Start opening tag
 	buf.WriteString("<")
 	buf.WriteString(elementName)
@@ -179,19 +207,51 @@ func renderElement(node *ast.ObjectNode, buf *bytes.Buffer, prettyPrint bool, pr
 		}
 	}
 	sort.Strings(attrs) // Sort for consistent output
+	if r.opts.PreserveOrder {
+		sortBySource(attrs, props)
+	}
 
 	for _, attrKey := range attrs {
 		attrName := attrKey[1:] // Remove @ prefix
 		attrNode := props[attrKey]
 		if literal, ok := attrNode.(*ast.LiteralNode); ok {
+			text, action, err := formatLiteral(literal.Value(), &r.opts)
+			if err != nil {
+				return err
+			}
+			if action == floatNil {
+				continue
+			}
 			buf.WriteString(" ")
 			buf.WriteString(attrName)
 			buf.WriteString("=\"")
-			buf.WriteString(escapeXML(fmt.Sprintf("%v", literal.Value())))
+			buf.WriteString(escapeAttr(text, &r.opts))
 			buf.WriteString("\"")
 		}
 	}
 
+	// Inner XML kept by WithMaxDepth or set by Element.Raw is the whole
+	// content, written as it is.
+	if inner, ok := props["#inner"].(*ast.LiteralNode); ok {
+		buf.WriteString(">")
+		r.writeRaw(textValue(inner.Value()))
+		buf.WriteString("</")
+		buf.WriteString(elementName)
+		buf.WriteString(">")
+		return nil
+	}
+
+	if content, ok := props["#content"].(*ast.ArrayDataNode); ok {
+		buf.WriteString(">")
+		if err := r.renderContent(content, props, depth); err != nil {
+			return err
+		}
+		buf.WriteString("</")
+		buf.WriteString(elementName)
+		buf.WriteString(">")
+		return nil
+	}
+
 	// Check for text content or CDATA
 	textNode, hasText := props["#text"]
 	cdataNode, hasCDATA := props["#cdata"]
@@ -204,15 +264,15 @@ func renderElement(node *ast.ObjectNode, buf *bytes.Buffer, prettyPrint bool, pr
 		}
 	}
 	sort.Strings(childKeys) // Sort for consistent output
+	if r.opts.PreserveOrder {
+		sortBySource(childKeys, props)
+	}
 
 	hasChildren := len(childKeys) > 0
 
 	// If no text, no CDATA, and no children, render as self-closing tag
 	if !hasText && !hasCDATA && !hasChildren {
 		buf.WriteString("/>")
-		if prettyPrint {
-			buf.WriteString("\n")
-		}
 		return nil
 	}
 
@@ -222,71 +282,73 @@ func renderElement(node *ast.ObjectNode, buf *bytes.Buffer, prettyPrint bool, pr
 	// Render text content (no newline before/after text)
 	if hasText {
 		if literal, ok := textNode.(*ast.LiteralNode); ok {
-			buf.WriteString(escapeXML(fmt.Sprintf("%v", literal.Value())))
+			text, _, err := formatLiteral(literal.Value(), &r.opts)
+			if err != nil {
+				return err
+			}
+			r.writeText(literal.Value(), text)
 		}
 	}
 
 	// Render CDATA content
 	if hasCDATA {
 		if literal, ok := cdataNode.(*ast.LiteralNode); ok {
-			buf.WriteString("<![CDATA[")
-			buf.WriteString(fmt.Sprintf("%v", literal.Value()))
-			buf.WriteString("]]>")
+			buf.Write(appendCDATA(nil, textValue(literal.Value())))
 		}
 	}
 
 	// Render child elements
 	if hasChildren {
-		if prettyPrint && !hasText {
-			buf.WriteString("\n")
-		}
-
 		for _, childKey := range childKeys {
 			childNode := props[childKey]
-			if err := renderNodeWithDepth(childNode, buf, prettyPrint, prefix, indent, depth+1, childKey); err != nil {
+			if err := r.renderNode(childNode, depth+1, childKey); err != nil {
 				return err
 			}
 		}
-
-		if prettyPrint && !hasText {
-			buf.WriteString(prefix)
-			buf.WriteString(strings.Repeat(indent, depth))
-		}
 	}
 
 	// Close tag
 	buf.WriteString("</")
 	buf.WriteString(elementName)
 	buf.WriteString(">")
-	if prettyPrint {
-		buf.WriteString("\n")
-	}
 
 	return nil
 }
 
 // renderArrayElements renders an ArrayDataNode as multiple XML elements.
-func renderArrayElements(node *ast.ArrayDataNode, buf *bytes.Buffer, prettyPrint bool, prefix, indent string, depth int, elementName string) error {
+func (r *renderer) renderArrayElements(node *ast.ArrayDataNode, depth int, elementName string) error {
 	elements := node.Elements()
+	start := r.buf.Len()
 
 	for _, elem := range elements {
-		if err := renderNodeWithDepth(elem, buf, prettyPrint, prefix, indent, depth, elementName); err != nil {
+		if err := r.renderNode(elem, depth, elementName); err != nil {
 			return err
 		}
 	}
 
+	if len(elements) == 1 && r.opts.ArrayHints {
+		item := appendArrayHint(append([]byte(nil), r.buf.Bytes()[start:]...), 0, elementName)
+		r.buf.Truncate(start)
+		r.buf.Write(item)
+	}
+
 	return nil
 }
 
-// escapeXML escapes special XML characters.
-//
-// Handles:
-//   - & → &amp;
-//   - < → &lt;
-//   - > → &gt;
-//   - " → &quot;
-//   - ' → &apos;
+// escapeXML escapes s as text in the same way as the compiled encoder,
+// with AppendEscapedText.
 func escapeXML(s string) string {
-	// Use html.EscapeString which handles &, <, >, ", and '
-	return html.EscapeString(s)
+	if indexEscape(s, &textEscapeTable) < 0 {
+		return s
+	}
+	return string(AppendEscapedText(nil, s))
+}
+
+// escapeAttr escapes s as an attribute value in the same way as the
+// compiled encoder, with the escaping opts selects.
+func escapeAttr(s string, opts *MarshalOptions) string {
+	if indexEscape(s, &attrEscapeTable) < 0 {
+		return s
+	}
+	return string(appendEscapeAttr(nil, s, opts))
 }
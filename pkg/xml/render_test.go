@@ -76,13 +76,8 @@ func TestRender_NestedElements(t *testing.T) {
 	}
 
 	result := string(bytes)
-	// Parser currently puts children under "child" element
-	// Just verify rendering works and produces XML
-	if !strings.Contains(result, "root") || !strings.Contains(result, "child") {
-		t.Errorf("Expected rendered XML with root and child elements, got: %s", result)
-	}
-	if !strings.Contains(result, "Alice") {
-		t.Errorf("Expected Alice in rendered output, got: %s", result)
+	if want := `<root><email>alice@example.com</email><name>Alice</name></root>`; result != want {
+		t.Errorf("Render = %s, want %s", result, want)
 	}
 }
 
@@ -152,6 +147,36 @@ func TestRenderIndent_Simple(t *testing.T) {
 	}
 }
 
+func TestRenderIndent_MatchesMarshalIndent(t *testing.T) {
+	type address struct {
+		City string `xml:"city"`
+		Zip  string `xml:"zip"`
+	}
+	type user struct {
+		ID      string  `xml:"id,attr"`
+		Address address `xml:"address"`
+		Name    string  `xml:"name"`
+	}
+	v := user{ID: "1", Address: address{City: "NYC", Zip: "10001"}, Name: "Alice"}
+	data, err := MarshalIndent(v, "> ", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.ReplaceAll(string(data), "user", "root")
+
+	elem := NewElement().
+		Attr("id", "1").
+		ChildText("name", "Alice").
+		Child("address", NewElement().ChildText("city", "NYC").ChildText("zip", "10001"))
+	got, err := elem.XMLIndent("root", "> ", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("XMLIndent:\n%s\nMarshalIndent:\n%s", got, want)
+	}
+}
+
 func TestRenderIndent_Nested(t *testing.T) {
 	elem := NewElement().
 		Attr("id", "123").
@@ -177,3 +202,48 @@ func TestRenderIndent_Nested(t *testing.T) {
 		t.Errorf("Expected multiple lines of indented output, got: %s", result)
 	}
 }
+
+func TestEstimateRenderSize(t *testing.T) {
+	inputs := []string{
+		`<user id="123"><name>Alice</name><email>alice@example.com</email></user>`,
+		`<list><item>a</item><item>b</item><item>c</item></list>`,
+		generateLargeXML(200),
+	}
+
+	for _, input := range inputs {
+		node, err := Parse(input)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+
+		compact, err := Render(node)
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		if est := estimateRenderSize(node, "root"); est < len(compact) {
+			t.Errorf("estimate %d is below rendered size %d", est, len(compact))
+		}
+	}
+}
+
+func TestRender_CDATAEnd(t *testing.T) {
+	// A "]]>" in the content is split across two sections.
+	want := `<root><![CDATA[a]]]]><![CDATA[>b]]></root>`
+	out, err := NewElement().CDATA("a]]>b").XML("root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != want {
+		t.Errorf("got  %s\nwant %s", out, want)
+	}
+	mixed, err := NewElement().SetContent(ContentNode{CDATA: true, Text: "a]]>b"}).XML("root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mixed != want {
+		t.Errorf("mixed content: got  %s\nwant %s", mixed, want)
+	}
+	if _, err := Parse(out); err != nil {
+		t.Errorf("Parse(%s) error = %v", out, err)
+	}
+}
@@ -0,0 +1,152 @@
+package xml
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+)
+
+// Report aggregates per-file results from ParseFS or ValidateFS into counts
+// and a list of failing files, for use as a CI gate over directories of XML.
+// A Report built from the same results is always identical, regardless of
+// the order in which the files were processed.
+//
+// Example:
+//
+//	report, err := xml.ValidateFSReport(os.DirFS("assets"), "*.xml")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	report.WriteText(os.Stdout)
+//	if report.Err() != nil {
+//	    os.Exit(1)
+//	}
+type Report struct {
+	// Files is the number of files checked.
+	Files int `json:"files"`
+	// Passed is the number of files without errors.
+	Passed int `json:"passed"`
+	// Failed is the number of files with at least one error.
+	Failed int `json:"failed"`
+	// Errors is the total number of errors over all files.
+	Errors int `json:"errors"`
+	// Failures lists the failing files, worst offenders first: ordered by
+	// error count, most first, then by path.
+	Failures []FileFailure `json:"failures"`
+}
+
+// FileFailure lists the errors reported for one file.
+type FileFailure struct {
+	Path   string   `json:"path"`
+	Errors []string `json:"errors"`
+}
+
+// NewReport builds a Report from per-file results. Errors that wrap several
+// errors, such as those from errors.Join, count once per wrapped error.
+func NewReport(results []FileResult) *Report {
+	r := &Report{Files: len(results), Failures: []FileFailure{}}
+	for _, res := range results {
+		if res.Err == nil {
+			r.Passed++
+			continue
+		}
+		msgs := errorMessages(res.Err)
+		r.Failed++
+		r.Errors += len(msgs)
+		r.Failures = append(r.Failures, FileFailure{Path: res.Path, Errors: msgs})
+	}
+	sort.Slice(r.Failures, func(i, j int) bool {
+		a, b := r.Failures[i], r.Failures[j]
+		if len(a.Errors) != len(b.Errors) {
+			return len(a.Errors) > len(b.Errors)
+		}
+		return a.Path < b.Path
+	})
+	return r
+}
+
+// ValidateFSReport runs ValidateFS and aggregates the results into a Report.
+func ValidateFSReport(fsys fs.FS, pattern string) (*Report, error) {
+	results, err := ValidateFS(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return NewReport(results), nil
+}
+
+// Worst returns up to n failing files with the most errors.
+func (r *Report) Worst(n int) []FileFailure {
+	if n > len(r.Failures) {
+		n = len(r.Failures)
+	}
+	return r.Failures[:n]
+}
+
+// Err returns an error summarizing the failures, or nil if every file passed.
+func (r *Report) Err() error {
+	if r.Failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("xml: %d of %d files failed with %d errors", r.Failed, r.Files, r.Errors)
+}
+
+// WriteText writes a human-readable summary followed by each failing file
+// and its errors, worst offenders first.
+func (r *Report) WriteText(w io.Writer) error {
+	_, err := io.WriteString(w, r.String())
+	return err
+}
+
+// String returns the text written by WriteText.
+func (r *Report) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d %s checked: %d passed, %d failed (%d %s)\n",
+		r.Files, plural(r.Files, "file"), r.Passed, r.Failed, r.Errors, plural(r.Errors, "error"))
+	for _, f := range r.Failures {
+		fmt.Fprintf(&buf, "\n%s: %d %s\n", f.Path, len(f.Errors), plural(len(f.Errors), "error"))
+		for _, msg := range f.Errors {
+			fmt.Fprintf(&buf, "  %s\n", msg)
+		}
+	}
+	return buf.String()
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = w.Write(b)
+	return err
+}
+
+// errorMessages flattens err into one message per wrapped error.
+func errorMessages(err error) []string {
+	var multi interface{ Unwrap() []error }
+	if errors.As(err, &multi) {
+		var msgs []string
+		for _, e := range multi.Unwrap() {
+			if e != nil {
+				msgs = append(msgs, errorMessages(e)...)
+			}
+		}
+		if len(msgs) > 0 {
+			return msgs
+		}
+	}
+	return []string{err.Error()}
+}
+
+// plural returns word with an "s" appended unless n is 1.
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
@@ -0,0 +1,93 @@
+package xml
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewReport(t *testing.T) {
+	results := []FileResult{
+		{Path: "ok.xml"},
+		{Path: "z.xml", Err: errors.New("bad z")},
+		{Path: "multi.xml", Err: errors.Join(errors.New("first"), errors.New("second"))},
+		{Path: "a.xml", Err: errors.New("bad a")},
+	}
+	r := NewReport(results)
+
+	if r.Files != 4 || r.Passed != 1 || r.Failed != 3 || r.Errors != 4 {
+		t.Errorf("counts = %+v", r)
+	}
+	var order []string
+	for _, f := range r.Failures {
+		order = append(order, f.Path)
+	}
+	if got := strings.Join(order, ","); got != "multi.xml,a.xml,z.xml" {
+		t.Errorf("failure order = %s", got)
+	}
+	if worst := r.Worst(1); len(worst) != 1 || worst[0].Path != "multi.xml" || len(worst[0].Errors) != 2 {
+		t.Errorf("Worst(1) = %+v", worst)
+	}
+	if len(r.Worst(10)) != 3 {
+		t.Errorf("Worst(10) = %+v", r.Worst(10))
+	}
+	if err := r.Err(); err == nil || err.Error() != "xml: 3 of 4 files failed with 4 errors" {
+		t.Errorf("Err() = %v", err)
+	}
+
+	// The same results in another order give the same report.
+	reversed := []FileResult{results[3], results[2], results[1], results[0]}
+	if NewReport(reversed).String() != r.String() {
+		t.Error("report depends on result order")
+	}
+}
+
+func TestReport_Text(t *testing.T) {
+	r := NewReport([]FileResult{
+		{Path: "good.xml"},
+		{Path: "bad.xml", Err: errors.New("mismatched tags")},
+	})
+	want := "2 files checked: 1 passed, 1 failed (1 error)\n\nbad.xml: 1 error\n  mismatched tags\n"
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText failed: %v", err)
+	}
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	clean := NewReport([]FileResult{{Path: "good.xml"}})
+	if clean.Err() != nil || clean.String() != "1 file checked: 1 passed, 0 failed (0 errors)\n" {
+		t.Errorf("clean report: %q, %v", clean.String(), clean.Err())
+	}
+}
+
+func TestReport_JSON(t *testing.T) {
+	r := NewReport([]FileResult{{Path: "good.xml"}})
+	var buf bytes.Buffer
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON %s: %v", buf.String(), err)
+	}
+	if decoded["files"] != float64(1) || decoded["failures"] == nil {
+		t.Errorf("decoded = %v", decoded)
+	}
+}
+
+func TestValidateFSReport(t *testing.T) {
+	r, err := ValidateFSReport(testFS(), "*.xml")
+	if err != nil {
+		t.Fatalf("ValidateFSReport failed: %v", err)
+	}
+	if r.Files != 5 || r.Failed != 2 {
+		t.Errorf("report = %+v", r)
+	}
+	if _, err := ValidateFSReport(testFS(), "["); err == nil {
+		t.Error("expected pattern error")
+	}
+}
@@ -0,0 +1,390 @@
+package xml
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+	"github.com/shapestone/shape-xml/internal/parser"
+	"github.com/shapestone/shape-xml/internal/rnc"
+)
+
+// RNCSchema is a RELAX NG schema written in the compact syntax. Compile it
+// once with CompileRNC and validate any number of documents with Validate.
+// An RNCSchema is safe for concurrent use.
+type RNCSchema struct {
+	schema *rnc.Schema
+}
+
+// CompileRNC compiles a RELAX NG schema written in the compact syntax.
+// Namespace and datatypes declarations, named patterns combined with |=
+// and &=, div blocks, name classes and the XML Schema datatypes with their
+// facets are supported. Schemas must be self-contained: include, external
+// and parent references and nested grammars are rejected. Annotations are
+// skipped.
+//
+// Example:
+//
+//	schema, err := xml.CompileRNC(`
+//	    element note {
+//	        attribute id { xsd:ID },
+//	        element to { text },
+//	        element body { text }
+//	    }`)
+//	if err != nil {
+//	    return err
+//	}
+//	if err := schema.Validate(input); err != nil {
+//	    fmt.Println(err)
+//	}
+func CompileRNC(src string) (*RNCSchema, error) {
+	schema, err := rnc.Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("xml: in RELAX NG schema at %v", err)
+	}
+	return &RNCSchema{schema: schema}, nil
+}
+
+// MustCompileRNC is like CompileRNC but panics if the schema is invalid.
+// It simplifies initialization of global schema variables.
+func MustCompileRNC(src string) *RNCSchema {
+	s, err := CompileRNC(src)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Validate checks that input is well-formed and valid against the schema.
+// A document that is not well-formed is reported with the parse error.
+// Otherwise every violation is reported as a *ValidationError, joined with
+// errors.Join:
+//
+//   - an element or attribute the schema does not allow where it appears
+//   - a missing required attribute, or an element whose content ends early
+//   - text where only elements may appear
+//   - an attribute value or text that does not match its datatype or value
+//
+// After a violation, validation continues as if the offending element or
+// attribute were absent, so that later violations are reported as well.
+func (s *RNCSchema) Validate(input string) error {
+	node, rootName, err := parseDocument(parser.NewParser(input), ParseOptions{MixedContent: true})
+	if err != nil {
+		return err
+	}
+	return s.ValidateNode(node, rootName)
+}
+
+// ValidateNode checks an AST returned by Parse, whose root element is
+// named rootName, against the schema, and reports violations as Validate
+// does. Text and child elements are taken in document order when node was
+// parsed with ParseOptions.MixedContent, and in position order otherwise.
+func (s *RNCSchema) ValidateNode(node ast.SchemaNode, rootName string) error {
+	w := &rncWalker{v: s.schema.NewValidator()}
+	w.element(w.v.Start(), rootName, node, "/"+rootName, nsScope{"xml": xmlNamespace})
+	return errors.Join(w.errs...)
+}
+
+// xmlNamespace is the namespace bound to the xml prefix.
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// nsScope maps prefixes to namespace URIs, "" to the default namespace.
+type nsScope map[string]string
+
+// nodeItem is a piece of element content: a child element or text.
+type nodeItem struct {
+	name   string
+	node   ast.SchemaNode
+	text   string
+	isText bool
+	offset int
+}
+
+type rncWalker struct {
+	v    *rnc.Validator
+	errs []error
+}
+
+func (w *rncWalker) report(path string, node ast.SchemaNode, msg string) {
+	pos := node.Position()
+	w.errs = append(w.errs, &ValidationError{Path: path, Line: pos.Line, Column: pos.Column, Msg: msg})
+}
+
+// element validates the element name with the AST node against p, and
+// returns the pattern for what may follow it. An element that p does not
+// allow is reported and skipped.
+func (w *rncWalker) element(p *rnc.Pattern, name string, node ast.SchemaNode, path string, scope nsScope) *rnc.Pattern {
+	obj, _ := node.(*ast.ObjectNode)
+	var attrs []string
+	if obj != nil {
+		for key, value := range obj.Properties() {
+			if !strings.HasPrefix(key, "@") {
+				continue
+			}
+			attr := key[1:]
+			if attr == "xmlns" || strings.HasPrefix(attr, "xmlns:") {
+				prefix := strings.TrimPrefix(strings.TrimPrefix(attr, "xmlns"), ":")
+				if lit, ok := value.(*ast.LiteralNode); ok && scope[prefix] != stringValue(lit) {
+					scope = scope.with(prefix, stringValue(lit))
+				}
+				continue
+			}
+			attrs = append(attrs, attr)
+		}
+	}
+	sort.Strings(attrs)
+
+	qname, err := scope.resolve(name, true)
+	if err != nil {
+		w.report(path, node, err.Error())
+		return p
+	}
+	p1 := w.v.StartTagOpen(p, qname)
+	if p1.NotAllowed() {
+		w.report(path, node, fmt.Sprintf("element %q not allowed here; expected %s", name, expectedList(p)))
+		return p
+	}
+
+	invalid := make(map[string]bool)
+	for _, attr := range attrs {
+		aname, err := scope.resolve(attr, false)
+		if err != nil {
+			w.report(path, node, err.Error())
+			continue
+		}
+		value := ""
+		if lit, ok := obj.Properties()["@"+attr].(*ast.LiteralNode); ok {
+			value = stringValue(lit)
+		}
+		next := w.v.Attribute(p1, aname, value)
+		if !next.NotAllowed() {
+			p1 = next
+			continue
+		}
+		if !rnc.AllowsAttribute(p1, aname) {
+			w.report(path, node, fmt.Sprintf("attribute %q not allowed here", attr))
+			continue
+		}
+		invalid[attr] = true
+		msg := fmt.Sprintf("attribute %q has invalid value %q", attr, value)
+		if why := rnc.Explain(rnc.AttributeValue(p1, aname), value); why != "" {
+			msg += ": " + why
+		}
+		w.report(path, node, msg)
+	}
+
+	p2 := w.v.StartTagClose(p1, false)
+	if p2.NotAllowed() {
+		var missing []string
+		for _, attr := range rnc.RequiredAttributes(p1) {
+			if !invalid[attr] {
+				missing = append(missing, strconv.Quote(attr))
+			}
+		}
+		if len(missing) == 1 {
+			w.report(path, node, "required attribute "+missing[0]+" is missing")
+		} else if len(missing) > 1 {
+			w.report(path, node, "required attributes "+strings.Join(missing, ", ")+" are missing")
+		}
+		p2 = w.v.StartTagClose(p1, true)
+	}
+
+	items := contentItems(node)
+	hasElements := false
+	for _, item := range items {
+		if !item.isText {
+			hasElements = true
+			break
+		}
+	}
+	counts := make(map[string]int)
+	for _, item := range items {
+		if !item.isText {
+			counts[item.name]++
+		}
+	}
+	seen := make(map[string]int)
+	// After invalid data, the element is not reported as incomplete too.
+	text, invalidText := "", false
+	flush := func() {
+		if hasElements && isWhitespaceText(text) {
+			text = ""
+			return
+		}
+		var next *rnc.Pattern
+		if isWhitespaceText(text) {
+			next = w.v.Whitespace(p2, text)
+		} else {
+			next = w.v.Text(p2, text)
+		}
+		if next.NotAllowed() {
+			if why := rnc.Explain(p2, text); why != "" {
+				w.report(path, node, fmt.Sprintf("invalid text %q: %s", strings.TrimSpace(text), why))
+				invalidText = true
+			} else {
+				w.report(path, node, fmt.Sprintf("text not allowed here; expected %s", expectedList(p2)))
+			}
+		} else {
+			p2 = next
+		}
+		text = ""
+	}
+	for _, item := range items {
+		if item.isText {
+			text += item.text
+			continue
+		}
+		if text != "" {
+			flush()
+		}
+		seen[item.name]++
+		childPath := path + "/" + item.name
+		if counts[item.name] > 1 {
+			childPath += "[" + strconv.Itoa(seen[item.name]) + "]"
+		}
+		p2 = w.element(p2, item.name, item.node, childPath, scope)
+	}
+	if text != "" || !hasElements {
+		flush()
+	}
+
+	p3 := w.v.EndTag(p2, false)
+	if p3.NotAllowed() {
+		if !invalidText {
+			w.report(path, node, fmt.Sprintf("element %q is incomplete; expected %s", name, expectedList(p2)))
+		}
+		p3 = w.v.EndTag(p2, true)
+		if p3.NotAllowed() {
+			return p
+		}
+	}
+	return p3
+}
+
+// contentItems returns the child elements and text of an element node in
+// document order.
+func contentItems(node ast.SchemaNode) []nodeItem {
+	switch n := node.(type) {
+	case *ast.LiteralNode:
+		return []nodeItem{{node: n, text: stringValue(n), isText: true}}
+	case *ast.ObjectNode:
+		props := n.Properties()
+		if content, ok := props["#content"].(*ast.ArrayDataNode); ok {
+			return mixedItems(props, content)
+		}
+		var items []nodeItem
+		for key, value := range props {
+			switch {
+			case key == "#text" || key == "#cdata":
+				if lit, ok := value.(*ast.LiteralNode); ok {
+					items = append(items, nodeItem{node: lit, text: stringValue(lit), isText: true, offset: lit.Position().Offset})
+				}
+			case isChildName(key):
+				if arr, ok := value.(*ast.ArrayDataNode); ok {
+					for _, child := range arr.Elements() {
+						items = append(items, nodeItem{name: key, node: child, offset: child.Position().Offset})
+					}
+				} else {
+					items = append(items, nodeItem{name: key, node: value, offset: value.Position().Offset})
+				}
+			}
+		}
+		sort.SliceStable(items, func(i, j int) bool { return items[i].offset < items[j].offset })
+		return items
+	}
+	return nil
+}
+
+// mixedItems resolves the "#content" entries of a mixed-content element,
+// where the nth reference to a name is the nth child element of that name.
+func mixedItems(props map[string]ast.SchemaNode, content *ast.ArrayDataNode) []nodeItem {
+	var items []nodeItem
+	next := make(map[string]int)
+	for _, entry := range content.Elements() {
+		obj, ok := entry.(*ast.ObjectNode)
+		if !ok {
+			continue
+		}
+		for key, value := range obj.Properties() {
+			lit, ok := value.(*ast.LiteralNode)
+			if !ok {
+				continue
+			}
+			switch key {
+			case "#text", "#cdata":
+				items = append(items, nodeItem{node: lit, text: stringValue(lit), isText: true})
+			case "#element":
+				name := stringValue(lit)
+				i := next[name]
+				next[name]++
+				var child ast.SchemaNode
+				if arr, ok := props[name].(*ast.ArrayDataNode); ok {
+					if i < len(arr.Elements()) {
+						child = arr.Elements()[i]
+					}
+				} else if i == 0 {
+					child = props[name]
+				}
+				if child != nil {
+					items = append(items, nodeItem{name: name, node: child})
+				}
+			}
+		}
+	}
+	return items
+}
+
+// with returns a copy of scope with prefix bound to uri.
+func (scope nsScope) with(prefix, uri string) nsScope {
+	copied := make(nsScope, len(scope)+1)
+	for k, v := range scope {
+		copied[k] = v
+	}
+	copied[prefix] = uri
+	return copied
+}
+
+// resolve expands a prefixed name. Unprefixed element names are in the
+// default namespace; unprefixed attribute names are in no namespace.
+func (scope nsScope) resolve(name string, isElement bool) (rnc.QName, error) {
+	prefix, local := SplitName(name)
+	if prefix == "" {
+		if isElement {
+			return rnc.QName{Space: scope[""], Local: local}, nil
+		}
+		return rnc.QName{Local: local}, nil
+	}
+	space, ok := scope[prefix]
+	if !ok {
+		return rnc.QName{}, fmt.Errorf("namespace prefix %q of %q is not declared", prefix, name)
+	}
+	return rnc.QName{Space: space, Local: local}, nil
+}
+
+// expectedList describes what p allows next, for error messages.
+func expectedList(p *rnc.Pattern) string {
+	list := rnc.Expected(p)
+	switch len(list) {
+	case 0:
+		return "nothing"
+	case 1:
+		return list[0]
+	}
+	return strings.Join(list[:len(list)-1], ", ") + " or " + list[len(list)-1]
+}
+
+// isWhitespaceText reports whether s holds only XML whitespace.
+func isWhitespaceText(s string) bool {
+	return strings.Trim(s, " \t\r\n") == ""
+}
+
+// stringValue returns the value of a literal as a string.
+func stringValue(lit *ast.LiteralNode) string {
+	if s, ok := lit.Value().(string); ok {
+		return s
+	}
+	return fmt.Sprint(lit.Value())
+}
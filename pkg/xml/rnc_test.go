@@ -0,0 +1,135 @@
+package xml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const orderRNC = `
+namespace inv = "urn:example:invoice"
+
+start = element order {
+    attribute id { xsd:ID },
+    attribute currency { "EUR" | "USD" }?,
+    element customer { text },
+    item+,
+    element note { mixed { element b { text }* } }?
+}
+
+item = element item {
+    attribute sku { xsd:NMTOKEN },
+    attribute inv:ref { xsd:NCName }?,
+    element qty { xsd:positiveInteger },
+    element price { xsd:decimal { fractionDigits = "2" } }
+}
+`
+
+func TestRNCSchema_Validate(t *testing.T) {
+	schema := MustCompileRNC(orderRNC)
+	valid := `<?xml version="1.0"?>
+<order id="o1" currency="USD" xmlns:i="urn:example:invoice">
+  <customer>Ann &amp; Bob</customer>
+  <item sku="A-1" i:ref="r1"><qty> 2 </qty><price>9.99</price></item>
+  <!-- comment -->
+  <item sku="B.2"><qty>1</qty><price><![CDATA[5.00]]></price></item>
+  <note>ship <b>fast</b>!</note>
+</order>`
+	if err := schema.Validate(valid); err != nil {
+		t.Fatalf("Validate(valid) = %v", err)
+	}
+
+	item := `<item sku="a"><qty>1</qty><price>1</price></item>`
+	tests := []struct {
+		name string
+		doc  string
+		want []string
+	}{
+		{"root", `<invoice/>`,
+			[]string{`/invoice (line 1, column 1): element "invoice" not allowed here; expected element order`}},
+		{"missing child", `<order id="o1">` + item + `</order>`,
+			[]string{`/order/item (line 1, column 16): element "item" not allowed here; expected element customer`,
+				`/order (line 1, column 1): element "order" is incomplete; expected element customer`}},
+		{"unexpected child", `<order id="o1"><customer/>` + item + `<extra/></order>`,
+			[]string{`element "extra" not allowed here; expected element item or element note`}},
+		{"incomplete", `<order id="o1"><customer/></order>`,
+			[]string{`/order (line 1, column 1): element "order" is incomplete; expected element item`}},
+		{"required attribute", `<order><customer/>` + item + `</order>`,
+			[]string{`/order (line 1, column 1): required attribute "id" is missing`}},
+		{"unknown attribute", `<order id="o1" x="1"><customer/>` + item + `</order>`,
+			[]string{`attribute "x" not allowed here`}},
+		{"attribute value", `<order id="o1" currency="GBP"><customer/>` + item + `</order>`,
+			[]string{`attribute "currency" has invalid value "GBP": expected "EUR" or "USD"`}},
+		{"attribute datatype", `<order id="1"><customer/>` + item + `</order>`,
+			[]string{`attribute "id" has invalid value "1": ID:`}},
+		{"text datatype", `<order id="o1"><customer/><item sku="a"><qty>0</qty><price>1.999</price></item>` + item + `</order>`,
+			[]string{`/order/item[1]/qty (line 1, column 41): invalid text "0": positiveInteger:`, `/order/item[1]/price`}},
+		{"text in element content", `<order id="o1">hi<customer/>` + item + `</order>`,
+			[]string{`text not allowed here; expected element customer`}},
+		{"namespace", `<order id="o1" xmlns:x="urn:other"><customer/><item sku="a" x:ref="r"><qty>1</qty><price>1</price></item></order>`,
+			[]string{`attribute "x:ref" not allowed here`}},
+		{"several", `<order><extra/><customer/>` + item + `</order>`,
+			[]string{`required attribute "id" is missing`, `element "extra" not allowed here`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Validate(tt.doc)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			msgs := errorMessages(err)
+			if len(msgs) != len(tt.want) {
+				t.Errorf("got %d errors, want %d:\n%s", len(msgs), len(tt.want), err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Errorf("error %T is not a *ValidationError", err)
+			}
+		})
+	}
+}
+
+func TestRNCSchema_ValidateNode(t *testing.T) {
+	schema := MustCompileRNC(`element r { element a { xsd:int }, element b { empty }, element a { xsd:int } }`)
+	input := "<r>\n<a>1</a>\n<b/>\n<a>x</a>\n</r>"
+	node, err := Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = schema.ValidateNode(node, "r")
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("ValidateNode = %v, want a *ValidationError", err)
+	}
+	if verr.Path != "/r/a[2]" || verr.Line != 4 {
+		t.Errorf("got %+v", *verr)
+	}
+	if err := schema.Validate(strings.Replace(input, "x", "2", 1)); err != nil {
+		t.Errorf("Validate = %v", err)
+	}
+}
+
+func TestCompileRNC_Errors(t *testing.T) {
+	_, err := CompileRNC("element r {\n  element a { tex }\n}")
+	if err == nil || !strings.Contains(err.Error(), "xml: in RELAX NG schema at line 2") {
+		t.Errorf("CompileRNC = %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("MustCompileRNC did not panic")
+		}
+	}()
+	MustCompileRNC(`start = missing`)
+}
+
+func TestRNCSchema_Malformed(t *testing.T) {
+	schema := MustCompileRNC(`element r { text }`)
+	if err := schema.Validate(`<r><a></r>`); err == nil || errors.As(err, new(*ValidationError)) {
+		t.Errorf("malformed document: got %v, want the parse error", err)
+	}
+}
@@ -1,8 +1,10 @@
 package xml
 
 import (
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 // TestRoundtrip_Simple tests basic parse -> render round trip
@@ -48,11 +50,10 @@ func TestRoundtrip_Nested(t *testing.T) {
 
 	result := string(bytes)
 
-	// Parser puts children under "child" - just verify round trip works
-	if !strings.Contains(result, "Alice") {
+	if !strings.Contains(result, "<name>Alice</name>") {
 		t.Errorf("Expected 'Alice' in result: %s", result)
 	}
-	if !strings.Contains(result, "alice@example.com") {
+	if !strings.Contains(result, "<email>alice@example.com</email>") {
 		t.Errorf("Expected email in result: %s", result)
 	}
 }
@@ -164,3 +165,80 @@ func TestRoundtrip_Unmarshal(t *testing.T) {
 		t.Errorf("Expected text content in result: %s", result)
 	}
 }
+
+type roundtripLine struct {
+	SKU   string  `xml:"sku,attr"`
+	Qty   uint16  `xml:"qty"`
+	Price float64 `xml:"price"`
+}
+
+type roundtripNote struct {
+	Lang string `xml:"lang,attr,omitempty"`
+	Text string `xml:",chardata"`
+}
+
+type roundtripOrder struct {
+	ID       int64           `xml:"id,attr"`
+	Paid     bool            `xml:"paid,attr"`
+	Customer string          `xml:"customer"`
+	Lines    []roundtripLine `xml:"line"`
+	Tags     []string        `xml:"tag,omitempty"`
+	Note     *roundtripNote  `xml:"note,omitempty"`
+	Script   string          `xml:",cdata"`
+	Discount *float32        `xml:"discount"`
+	Timeout  time.Duration   `xml:"timeout"`
+	Extra    map[string]int  `xml:"-"`
+	Raw      []byte          `xml:"raw"`
+}
+
+// TestRoundtrip_Struct tests that a Go value survives marshal -> unmarshal
+func TestRoundtrip_Struct(t *testing.T) {
+	discount := float32(0.5)
+	orders := []roundtripOrder{
+		{
+			ID:       42,
+			Paid:     true,
+			Customer: "Ann & Co",
+			Lines:    []roundtripLine{{SKU: "a", Qty: 2, Price: 9.99}, {SKU: "b", Qty: 1, Price: 0.25}},
+			Tags:     []string{"rush", "gift"},
+			Note:     &roundtripNote{Lang: "en", Text: "leave at door"},
+			Script:   "if a < b {}",
+			Discount: &discount,
+			Timeout:  90 * time.Second,
+			Raw:      []byte("b"),
+		},
+		{
+			// Single-item slices must come back as slices.
+			ID:       -1,
+			Discount: new(float32),
+			Lines:    []roundtripLine{{SKU: "only", Qty: 65535, Price: -1e-7}},
+			Tags:     []string{"one"},
+		},
+	}
+
+	for _, want := range orders {
+		data, err := Marshal(want)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		var got roundtripOrder
+		if err := Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal(%s) failed: %v", data, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("round trip of %s\ngot  %+v\nwant %+v", data, got, want)
+		}
+	}
+}
+
+// TestRoundtrip_StructErrors tests that conversion failures name the element path
+func TestRoundtrip_StructErrors(t *testing.T) {
+	var order roundtripOrder
+	err := Unmarshal([]byte(`<order><line sku="a"><qty>70000</qty></line></order>`), &order)
+	if err == nil {
+		t.Fatal("expected overflow error")
+	}
+	if want := `xml: /order/line/qty (line 1, column 22): cannot parse "70000" as uint16: value out of range`; err.Error() != want {
+		t.Errorf("got  %v\nwant %s", err, want)
+	}
+}
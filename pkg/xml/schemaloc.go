@@ -0,0 +1,216 @@
+package xml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// SchemaLocation is a schema hint declared by a document with
+// xsi:schemaLocation or xsi:noNamespaceSchemaLocation.
+type SchemaLocation struct {
+	// Namespace is the target namespace, "" for noNamespaceSchemaLocation.
+	Namespace string
+	// Location is the schema URL or path exactly as written in the document.
+	Location string
+}
+
+// SchemaLocations reads the root start tag from r and returns its schema
+// hints in document order: the namespace/location pairs of
+// xsi:schemaLocation followed by xsi:noNamespaceSchemaLocation. Only the
+// start tag is read, so this is cheap even for very large documents.
+//
+// The xsi prefix is recognized by its namespace binding on the root element,
+// so any prefix bound to the XML Schema instance namespace works.
+//
+// Example:
+//
+//	locs, err := xml.SchemaLocations(file)
+//	// [{Namespace: "urn:orders", Location: "orders.xsd"}]
+func SchemaLocations(r io.Reader) ([]SchemaLocation, error) {
+	d := NewDecoder(r)
+	for {
+		tok, err := d.readToken()
+		if err != nil {
+			return nil, err
+		}
+		if tok.kind == tokenStart {
+			return schemaLocations(tok.attrs)
+		}
+	}
+}
+
+// schemaLocations extracts the schema hints from root element attributes.
+func schemaLocations(attrs []rawAttr) ([]SchemaLocation, error) {
+	prefix := ""
+	for _, a := range attrs {
+		if strings.HasPrefix(a.name, "xmlns:") && a.value == xsiNamespace {
+			prefix = a.name[len("xmlns:"):]
+			break
+		}
+	}
+	if prefix == "" {
+		return nil, nil
+	}
+
+	var locs []SchemaLocation
+	for _, a := range attrs {
+		if a.name != prefix+":schemaLocation" {
+			continue
+		}
+		fields := strings.Fields(a.value)
+		if len(fields)%2 != 0 {
+			return nil, fmt.Errorf("xml: %s must hold namespace/location pairs, got %d values", a.name, len(fields))
+		}
+		for i := 0; i < len(fields); i += 2 {
+			locs = append(locs, SchemaLocation{Namespace: fields[i], Location: fields[i+1]})
+		}
+	}
+	for _, a := range attrs {
+		if a.name == prefix+":noNamespaceSchemaLocation" {
+			if loc := strings.TrimSpace(a.value); loc != "" {
+				locs = append(locs, SchemaLocation{Location: loc})
+			}
+		}
+	}
+	return locs, nil
+}
+
+// SchemaResolver loads the schema document for a location hint. Resolvers
+// decide how locations map to content, for example by fetching URLs or
+// reading bundled files; the package never opens network connections itself.
+type SchemaResolver interface {
+	ResolveSchema(loc SchemaLocation) (io.ReadCloser, error)
+}
+
+// SchemaResolverFunc adapts a function to the SchemaResolver interface.
+type SchemaResolverFunc func(loc SchemaLocation) (io.ReadCloser, error)
+
+// ResolveSchema calls f(loc).
+func (f SchemaResolverFunc) ResolveSchema(loc SchemaLocation) (io.ReadCloser, error) {
+	return f(loc)
+}
+
+// ErrSchemaNotAllowed is returned for schema locations rejected by an
+// allowlist, see AllowSchemas.
+var ErrSchemaNotAllowed = errors.New("xml: schema location not allowed")
+
+// AllowSchemas wraps r so that only locations under one of the allowed
+// URLs or paths are resolved; others fail with ErrSchemaNotAllowed. A
+// location is under an allowed entry when both have the same scheme and
+// host and the location's path, with "." and ".." segments resolved, is
+// the entry's path or lies in it segment by segment: "schemas" allows
+// "schemas/orders.xsd" but not "schemas-old/orders.xsd". Locations with
+// user information and entries that are not valid URLs match nothing. Use
+// it to keep untrusted documents from pointing the resolver at arbitrary
+// URLs or files.
+//
+// Example:
+//
+//	resolver := xml.AllowSchemas(httpResolver, "https://schemas.example.com/")
+func AllowSchemas(r SchemaResolver, allowed ...string) SchemaResolver {
+	var bases []*url.URL
+	for _, a := range allowed {
+		if u, err := url.Parse(a); err == nil {
+			bases = append(bases, u)
+		}
+	}
+	return SchemaResolverFunc(func(loc SchemaLocation) (io.ReadCloser, error) {
+		if u, err := url.Parse(loc.Location); err == nil && u.User == nil {
+			for _, base := range bases {
+				if schemaUnder(u, base) {
+					return r.ResolveSchema(loc)
+				}
+			}
+		}
+		return nil, fmt.Errorf("%w: %q", ErrSchemaNotAllowed, loc.Location)
+	})
+}
+
+// schemaUnder reports whether u has the scheme and host of base and a path
+// that is base's path or lies in it.
+func schemaUnder(u, base *url.URL) bool {
+	if u.Opaque != "" || base.Opaque != "" ||
+		!strings.EqualFold(u.Scheme, base.Scheme) || !strings.EqualFold(u.Host, base.Host) {
+		return false
+	}
+	p, dir := schemaPath(u), schemaPath(base)
+	if p == dir {
+		return true
+	}
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	return strings.HasPrefix(p, dir)
+}
+
+// schemaPath returns the path of u with "." and ".." segments resolved,
+// "/" for the empty path of a URL with a host.
+func schemaPath(u *url.URL) string {
+	if u.Path == "" && u.Host != "" {
+		return "/"
+	}
+	return path.Clean(u.Path)
+}
+
+// FSSchemaResolver resolves locations as slash-separated paths in fsys,
+// which suits schemas bundled with an application via embed.FS. Locations
+// that are not valid fs.FS paths, such as URLs, fail with fs.ErrInvalid.
+func FSSchemaResolver(fsys fs.FS) SchemaResolver {
+	return SchemaResolverFunc(func(loc SchemaLocation) (io.ReadCloser, error) {
+		if !fs.ValidPath(loc.Location) {
+			return nil, &fs.PathError{Op: "open", Path: loc.Location, Err: fs.ErrInvalid}
+		}
+		return fsys.Open(loc.Location)
+	})
+}
+
+// ResolvedSchema is a schema hint together with the loaded schema document.
+type ResolvedSchema struct {
+	SchemaLocation
+	Data []byte
+}
+
+// maxSchemaSize bounds the schema documents ResolveSchemas loads.
+const maxSchemaSize = 16 << 20
+
+// ResolveSchemas discovers the schema hints of the document in r, as
+// SchemaLocations does, and loads each one through resolver. It stops at the
+// first failure, returning an error that names the location. A schema
+// document larger than 16 MiB fails with a *LimitError.
+func ResolveSchemas(r io.Reader, resolver SchemaResolver) ([]ResolvedSchema, error) {
+	locs, err := SchemaLocations(r)
+	if err != nil {
+		return nil, err
+	}
+	schemas := make([]ResolvedSchema, 0, len(locs))
+	for _, loc := range locs {
+		data, err := readSchema(resolver, loc)
+		if err != nil {
+			return nil, fmt.Errorf("xml: resolving schema %q: %w", loc.Location, err)
+		}
+		schemas = append(schemas, ResolvedSchema{SchemaLocation: loc, Data: data})
+	}
+	return schemas, nil
+}
+
+// readSchema loads one schema through resolver and closes the reader.
+func readSchema(resolver SchemaResolver, loc SchemaLocation) ([]byte, error) {
+	rc, err := resolver.ResolveSchema(loc)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxSchemaSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSchemaSize {
+		return nil, &LimitError{Limit: LimitDocumentSize, Max: maxSchemaSize}
+	}
+	return data, nil
+}
@@ -0,0 +1,158 @@
+package xml
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const schemaLocDoc = `<?xml version="1.0"?>
+<!-- orders -->
+<o:orders xmlns:o="urn:orders" xmlns:si="http://www.w3.org/2001/XMLSchema-instance"
+    si:schemaLocation="urn:orders  schemas/orders.xsd
+                       urn:common schemas/common.xsd"
+    si:noNamespaceSchemaLocation="schemas/plain.xsd">
+  <o:order/>
+</o:orders>`
+
+func TestSchemaLocations(t *testing.T) {
+	got, err := SchemaLocations(strings.NewReader(schemaLocDoc))
+	if err != nil {
+		t.Fatalf("SchemaLocations failed: %v", err)
+	}
+	want := []SchemaLocation{
+		{Namespace: "urn:orders", Location: "schemas/orders.xsd"},
+		{Namespace: "urn:common", Location: "schemas/common.xsd"},
+		{Location: "schemas/plain.xsd"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestSchemaLocations_None(t *testing.T) {
+	tests := []string{
+		`<a/>`,
+		// The prefix must be bound to the XSI namespace.
+		`<a xmlns:xsi="urn:other" xsi:noNamespaceSchemaLocation="a.xsd"/>`,
+	}
+	for _, input := range tests {
+		got, err := SchemaLocations(strings.NewReader(input))
+		if err != nil || len(got) != 0 {
+			t.Errorf("%s: got %v, %v", input, got, err)
+		}
+	}
+}
+
+func TestSchemaLocations_Errors(t *testing.T) {
+	odd := `<a xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="urn:x"/>`
+	if _, err := SchemaLocations(strings.NewReader(odd)); err == nil {
+		t.Error("expected error for unpaired schemaLocation")
+	}
+	if _, err := SchemaLocations(strings.NewReader(`<a x=1>`)); err == nil {
+		t.Error("expected syntax error")
+	}
+}
+
+func TestResolveSchemas(t *testing.T) {
+	fsys := fstest.MapFS{
+		"schemas/orders.xsd": {Data: []byte("<orders-schema/>")},
+		"schemas/common.xsd": {Data: []byte("<common-schema/>")},
+		"schemas/plain.xsd":  {Data: []byte("<plain-schema/>")},
+	}
+
+	got, err := ResolveSchemas(strings.NewReader(schemaLocDoc), FSSchemaResolver(fsys))
+	if err != nil {
+		t.Fatalf("ResolveSchemas failed: %v", err)
+	}
+	if len(got) != 3 || got[0].Namespace != "urn:orders" || string(got[0].Data) != "<orders-schema/>" {
+		t.Errorf("got %+v", got)
+	}
+
+	delete(fsys, "schemas/plain.xsd")
+	if _, err := ResolveSchemas(strings.NewReader(schemaLocDoc), FSSchemaResolver(fsys)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestAllowSchemas(t *testing.T) {
+	var resolved []string
+	base := SchemaResolverFunc(func(loc SchemaLocation) (io.ReadCloser, error) {
+		resolved = append(resolved, loc.Location)
+		return io.NopCloser(strings.NewReader("schema")), nil
+	})
+	r := AllowSchemas(base, "schemas/orders.xsd", "schemas/common.xsd")
+
+	_, err := ResolveSchemas(strings.NewReader(schemaLocDoc), r)
+	if !errors.Is(err, ErrSchemaNotAllowed) {
+		t.Fatalf("expected ErrSchemaNotAllowed, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "schemas/plain.xsd") {
+		t.Errorf("error should name the location: %v", err)
+	}
+	if want := []string{"schemas/orders.xsd", "schemas/common.xsd"}; !reflect.DeepEqual(resolved, want) {
+		t.Errorf("resolved %q, want %q", resolved, want)
+	}
+}
+
+func TestAllowSchemas_Match(t *testing.T) {
+	base := SchemaResolverFunc(func(loc SchemaLocation) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("schema")), nil
+	})
+	r := AllowSchemas(base, "https://schemas.example.com/xsd", "schemas/", "http://%zz")
+	for _, tt := range []struct {
+		location string
+		allowed  bool
+	}{
+		{"https://schemas.example.com/xsd", true},
+		{"https://schemas.example.com/xsd/orders.xsd", true},
+		{"https://SCHEMAS.example.com/xsd/a/b.xsd?v=2", true},
+		{"https://schemas.example.com/xsd-old/orders.xsd", false},
+		{"https://schemas.example.com/xsd/../private/key.xsd", false},
+		{"https://schemas.example.com.evil.com/xsd/orders.xsd", false},
+		{"https://schemas.example.com@evil.com/xsd/orders.xsd", false},
+		{"https://user@schemas.example.com/xsd/orders.xsd", false},
+		{"http://schemas.example.com/xsd/orders.xsd", false},
+		{"schemas/orders.xsd", true},
+		{"./schemas/common/types.xsd", true},
+		{"schemas/../secret.xsd", false},
+		{"/schemas/orders.xsd", false},
+		{"schemas-old/orders.xsd", false},
+		{"http://%zz", false},
+	} {
+		_, err := r.ResolveSchema(SchemaLocation{Location: tt.location})
+		if allowed := err == nil; allowed != tt.allowed {
+			t.Errorf("%q: allowed = %v, want %v (%v)", tt.location, allowed, tt.allowed, err)
+		}
+	}
+}
+
+func TestResolveSchemas_SizeLimit(t *testing.T) {
+	r := SchemaResolverFunc(func(loc SchemaLocation) (io.ReadCloser, error) {
+		return io.NopCloser(io.LimitReader(zeroReader{}, maxSchemaSize+1)), nil
+	})
+	_, err := ResolveSchemas(strings.NewReader(schemaLocDoc), r)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != LimitDocumentSize {
+		t.Errorf("expected a document size *LimitError, got %v", err)
+	}
+}
+
+// zeroReader reads an endless run of zero bytes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestFSSchemaResolver_RejectsURLs(t *testing.T) {
+	r := FSSchemaResolver(fstest.MapFS{})
+	if _, err := r.ResolveSchema(SchemaLocation{Location: "https://example.com/a.xsd"}); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("expected fs.ErrInvalid, got %v", err)
+	}
+}
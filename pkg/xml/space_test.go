@@ -0,0 +1,79 @@
+package xml
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+const spaceDoc = "<poem>\n  <title>  Ode  </title>\n  <verse xml:space=\"preserve\">  roses\n    are red  </verse>\n</poem>"
+
+type poem struct {
+	Title string `xml:"title"`
+	Verse string `xml:"verse"`
+}
+
+func TestPreserveSpace_XMLSpaceAttribute(t *testing.T) {
+	var p poem
+	if err := Unmarshal([]byte(spaceDoc), &p); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	want := poem{Title: "Ode", Verse: "  roses\n    are red  "}
+	if p != want {
+		t.Errorf("got %#v, want %#v", p, want)
+	}
+
+	node, err := Parse(spaceDoc)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	verse := node.(*ast.ObjectNode).Properties()["verse"].(*ast.ObjectNode)
+	if got := verse.Properties()["#text"].(*ast.LiteralNode).Value(); got != want.Verse {
+		t.Errorf("Parse: verse = %q, want %q", got, want.Verse)
+	}
+}
+
+func TestPreserveSpace_Option(t *testing.T) {
+	var p poem
+	if err := UnmarshalWithOptions([]byte(spaceDoc), &p, WithPreserveSpace()); err != nil {
+		t.Fatalf("UnmarshalWithOptions failed: %v", err)
+	}
+	if want := (poem{Title: "  Ode  ", Verse: "  roses\n    are red  "}); p != want {
+		t.Errorf("got %#v, want %#v", p, want)
+	}
+
+	// The fast parser and the AST agree.
+	var fromMap, fromAST interface{}
+	if err := UnmarshalWithOptions([]byte(spaceDoc), &fromMap, WithPreserveSpace()); err != nil {
+		t.Fatal(err)
+	}
+	node, err := ParseWithOptions(spaceDoc, WithPreserveSpace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromAST = NodeToInterface(node)
+	if !reflect.DeepEqual(fromMap, fromAST) {
+		t.Errorf("fast parser gave %#v, AST %#v", fromMap, fromAST)
+	}
+	if text := fromMap.(map[string]interface{})["#text"]; text != "\n" {
+		t.Errorf("#text = %q, want the whitespace after the last child", text)
+	}
+}
+
+func TestPreserveSpace_MixedContent(t *testing.T) {
+	var v interface{}
+	err := UnmarshalWithOptions([]byte("<p><b>bold</b> <i>italic</i></p>"), &v, WithMixedContent(), WithPreserveSpace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := v.(map[string]interface{})["#content"]
+	want := []interface{}{
+		map[string]interface{}{"#element": "b"},
+		map[string]interface{}{"#text": " "},
+		map[string]interface{}{"#element": "i"},
+	}
+	if !reflect.DeepEqual(content, want) {
+		t.Errorf("#content = %#v, want %#v", content, want)
+	}
+}
@@ -0,0 +1,88 @@
+package xml
+
+import (
+	"errors"
+	"io"
+)
+
+// ElementStream extracts elements selected by path from an input stream,
+// materializing each one as an Element while discarding everything else.
+// Only the element being extracted and the names of its ancestors are held
+// in memory, so record dumps of any size can be processed in one pass.
+//
+// An ElementStream is not safe for concurrent use.
+type ElementStream struct {
+	d    *Decoder
+	opts []ParseOption
+}
+
+// Stream returns an ElementStream reading from r. The options apply to
+// every extracted element, as in ParseElementWithOptions.
+//
+// Example:
+//
+//	err := xml.Stream(file).Each("users/user", func(user *xml.Element) error {
+//	    id, _ := user.GetAttr("id")
+//	    name, _ := user.GetChild("name")
+//	    text, _ := name.GetText()
+//	    return store(id, text)
+//	})
+func Stream(r io.Reader, opts ...ParseOption) *ElementStream {
+	o := newParseOptions(opts)
+	d := NewDecoder(r)
+	d.SetRawNewlines(o.RawNewlines)
+	d.SetInvalidChars(o.InvalidChars)
+	return &ElementStream{d: d, opts: opts}
+}
+
+// Each reads the rest of the input and calls fn with every element on
+// path, in document order. Paths start at the root element and may be
+// separated by '/' or '.', as in "users/user"; "*" matches any element
+// name, and a bare name such as "user" matches elements of that name at
+// any depth. Elements nested in a matched element are part of it and are
+// not matched on their own.
+//
+// Each element is parsed on its own, so its Path starts at the element
+// itself, and namespace declarations of its ancestors are not in scope.
+//
+// Each returns nil at the end of the input, the first error in the input,
+// or the first error returned by fn, which stops reading.
+func (s *ElementStream) Each(path string, fn func(e *Element) error) error {
+	if path == "" {
+		return errors.New("xml: Each requires an element path")
+	}
+	pattern := splitElementPath(path)
+	for {
+		tok, err := s.d.readToken()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if tok.kind != tokenStart || !s.matches(pattern) {
+			continue
+		}
+		start := startElement(tok)
+		data, err := s.d.readElement(&start)
+		if err != nil {
+			return err
+		}
+		e, err := parseElementBytes(data, s.opts...)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+}
+
+// matches reports whether the element just opened is on pattern.
+func (s *ElementStream) matches(pattern []string) bool {
+	open := s.d.open
+	if len(pattern) == 1 {
+		return pattern[0] == "*" || pattern[0] == open[len(open)-1]
+	}
+	return matchPath(pattern, open, true)
+}
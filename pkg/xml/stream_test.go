@@ -0,0 +1,137 @@
+package xml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+const streamTestXML = `<?xml version="1.0"?>
+<dump>
+  <users>
+    <user id="1"><name>Ann</name><user id="nested"/></user>
+    <!-- skipped -->
+    <user id="2"><name><![CDATA[Bo & Co]]></name></user>
+  </users>
+  <groups><group><user id="3"/></group></groups>
+</dump>`
+
+func collectIDs(t *testing.T, path string) []string {
+	t.Helper()
+	var ids []string
+	err := Stream(strings.NewReader(streamTestXML)).Each(path, func(e *Element) error {
+		id, _ := e.GetAttr("id")
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestStream_Each(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"dump/users/user", "[1 2]"},
+		{"/dump/users/user", "[1 2]"},
+		{"dump.users.user", "[1 2]"},
+		{"dump/*/user", "[1 2]"},
+		{"user", "[1 2 3]"},
+		{"other/users/user", "[]"},
+		{"users/user", "[]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(collectIDs(t, tt.path)); got != tt.want {
+			t.Errorf("Each(%q) ids = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestStream_Elements(t *testing.T) {
+	var names []string
+	err := Stream(strings.NewReader(streamTestXML)).Each("dump/users/user", func(e *Element) error {
+		if e.Name() != "user" || e.Path() != "/user" {
+			t.Errorf("Name() = %q, Path() = %q", e.Name(), e.Path())
+		}
+		name, _ := e.GetChild("name")
+		text, ok := name.GetText()
+		if !ok {
+			text, _ = name.GetCDATA()
+		}
+		names = append(names, text)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(names) != "[Ann Bo & Co]" {
+		t.Errorf("names = %q", names)
+	}
+
+	// Parse options apply to each element.
+	err = Stream(strings.NewReader(`<r><a>x<b/>y</a></r>`), WithMixedContent()).Each("r/a", func(e *Element) error {
+		if n := len(e.Content()); n != 3 {
+			t.Errorf("mixed content has %d nodes, want 3", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStream_Errors(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := Stream(strings.NewReader(streamTestXML)).Each("user", func(e *Element) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Each = %v after %d calls, want the callback's error after 1", err, calls)
+	}
+
+	err = Stream(strings.NewReader(`<r><a><b></a></r>`)).Each("r/a", func(e *Element) error {
+		t.Error("callback called for a malformed element")
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "mismatched tags") {
+		t.Errorf("Each(malformed) = %v", err)
+	}
+
+	if err := Stream(strings.NewReader(`<r/>`)).Each("", nil); err == nil {
+		t.Error("Each with an empty path: expected an error")
+	}
+}
+
+// TestStream_Large checks that records are extracted one at a time from a
+// generated stream.
+func TestStream_Large(t *testing.T) {
+	const n = 10000
+	pr, pw := io.Pipe()
+	go func() {
+		fmt.Fprint(pw, "<dump>")
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(pw, `<row n="%d"><v>%d</v></row>`, i, i*2)
+		}
+		fmt.Fprint(pw, "</dump>")
+		pw.Close()
+	}()
+	count := 0
+	err := Stream(pr).Each("dump/row", func(e *Element) error {
+		v, _ := e.GetChild("v")
+		if text, _ := v.GetText(); text != fmt.Sprint(count*2) {
+			return fmt.Errorf("row %d holds %s", count, text)
+		}
+		count++
+		return nil
+	})
+	if err != nil || count != n {
+		t.Errorf("Each = %v after %d rows", err, count)
+	}
+}
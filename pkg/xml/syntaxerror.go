@@ -0,0 +1,23 @@
+package xml
+
+import "github.com/shapestone/shape-xml/internal/xmlerr"
+
+// SyntaxError reports a document that is not well-formed, as returned by
+// the parsing, validation and unmarshaling functions. Besides the message
+// in Err, it locates the error for diagnostics: the byte Offset, the Line
+// and Column (in bytes) starting at 1, the Element innermost open at the
+// error, and a Snippet of the source line around it. Use errors.As to
+// inspect it.
+//
+// For documents read by ParseReader, Offset and Column count characters
+// and Snippet is empty. Exceeding a limit set with WithLimits is reported
+// as a *LimitError instead.
+//
+// Example:
+//
+//	_, err := xml.Parse(input)
+//	var syntaxErr *xml.SyntaxError
+//	if errors.As(err, &syntaxErr) {
+//	    fmt.Printf("%d:%d: %v\n    %s\n", syntaxErr.Line, syntaxErr.Column, syntaxErr.Err, syntaxErr.Snippet)
+//	}
+type SyntaxError = xmlerr.SyntaxError
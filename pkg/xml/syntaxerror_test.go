@@ -0,0 +1,126 @@
+package xml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// syntaxErrorFuncs runs the AST parser and the fast parser on input.
+var syntaxErrorFuncs = map[string]func(input string) error{
+	"Parse": func(input string) error {
+		_, err := Parse(input)
+		return err
+	},
+	"ParseBytes": func(input string) error {
+		_, err := ParseBytes([]byte(input))
+		return err
+	},
+	"ParseElement": func(input string) error {
+		_, err := ParseElement(input)
+		return err
+	},
+	"Validate": Validate,
+	"Unmarshal": func(input string) error {
+		var v interface{}
+		return Unmarshal([]byte(input), &v)
+	},
+}
+
+func TestSyntaxError(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		line    int
+		column  int
+		element string
+		snippet string
+	}{
+		{"mismatched tags", "<a>\n  <b x=\"1\">text</c>\n</a>", 2, 16, "b", `  <b x="1">text</c>`},
+		{"unclosed element", "<a>\n<b>", 2, 4, "b", "<b>"},
+		{"unquoted attribute", `<a x=1/>`, 1, 6, "a", `<a x=1/>`},
+		{"content after root", `<a></a>junk`, 1, 8, "", `<a></a>junk`},
+		{"unterminated CDATA", `<a><![CDATA[x</a>`, 1, 18, "a", `<a><![CDATA[x</a>`},
+		{"missing equals", `<a><b attr></b></a>`, 1, 11, "b", `<a><b attr></b></a>`},
+		{"empty", ``, 1, 1, "", ``},
+		{"bad DOCTYPE", "<!DOCTYPE a [garbage]><a/>", 1, 14, "", "<!DOCTYPE a [garbage]><a/>"},
+		{"missing attribute name", `<a><b ="1"/></a>`, 1, 7, "b", `<a><b ="1"/></a>`},
+	}
+	for _, tt := range tests {
+		for name, parse := range syntaxErrorFuncs {
+			err := parse(tt.input)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Errorf("%s %s: error = %v, want a SyntaxError", tt.name, name, err)
+				continue
+			}
+			if syntaxErr.Line != tt.line || syntaxErr.Column != tt.column || syntaxErr.Element != tt.element || syntaxErr.Snippet != tt.snippet {
+				t.Errorf("%s %s: line %d, column %d, element %q, snippet %q; want %d, %d, %q, %q",
+					tt.name, name, syntaxErr.Line, syntaxErr.Column, syntaxErr.Element, syntaxErr.Snippet,
+					tt.line, tt.column, tt.element, tt.snippet)
+			}
+			if !strings.HasPrefix(err.Error(), "xml: syntax error at line ") {
+				t.Errorf("%s %s: Error() = %q", tt.name, name, err)
+			}
+			// The position and element are given once, by the SyntaxError.
+			if msg := syntaxErr.Err.Error(); strings.Contains(msg, "position") || strings.Contains(msg, "in element") {
+				t.Errorf("%s %s: message %q repeats the position or element", tt.name, name, msg)
+			}
+		}
+	}
+}
+
+func TestSyntaxError_NonASCIIOffsets(t *testing.T) {
+	input := "<a>\n  <b>héllo</b><c"
+	for name, parse := range syntaxErrorFuncs {
+		var syntaxErr *SyntaxError
+		if err := parse(input); !errors.As(err, &syntaxErr) {
+			t.Errorf("%s: error = %v, want a SyntaxError", name, err)
+			continue
+		}
+		if syntaxErr.Offset != len(input) || syntaxErr.Line != 2 || syntaxErr.Column != len("  <b>héllo</b><c")+1 {
+			t.Errorf("%s: offset %d, line %d, column %d; want the end of input in bytes", name,
+				syntaxErr.Offset, syntaxErr.Line, syntaxErr.Column)
+		}
+	}
+}
+
+func TestSyntaxError_ParseReader(t *testing.T) {
+	_, err := ParseReader(strings.NewReader("<a>\n<b></c></a>"))
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("error = %v, want a SyntaxError", err)
+	}
+	if syntaxErr.Line != 2 || syntaxErr.Column != 4 || syntaxErr.Element != "b" || syntaxErr.Snippet != "" {
+		t.Errorf("%+v, want line 2, column 4 in b without a snippet", syntaxErr)
+	}
+}
+
+func TestSyntaxError_LimitErrorsStayDistinct(t *testing.T) {
+	err := ValidateWithOptions(`<a><b/></a>`, WithLimits(Limits{MaxDepth: 1}))
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) {
+		t.Errorf("limit error %v reported as a SyntaxError", err)
+	}
+}
+
+func TestSyntaxError_NoRootElement(t *testing.T) {
+	// The input ends where the root element should start; a slice without
+	// spare capacity must not be read past its end.
+	for _, input := range []string{`<!DOCTYPE a>`, `<?xml version="1.0"?>`, `<!-- c -->`, `<!DOCTYPE 00000>`} {
+		data := []byte(input)
+		data = data[:len(data):len(data)]
+		var v interface{}
+		var s struct {
+			A string `xml:"a"`
+		}
+		for _, err := range []error{Unmarshal(data, &v), Unmarshal(data, &s), Validate(input)} {
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Errorf("%q: error = %v, want a SyntaxError", input, err)
+			} else if syntaxErr.Element != "" {
+				t.Errorf("%q: element %q, want none", input, syntaxErr.Element)
+			}
+		}
+	}
+}
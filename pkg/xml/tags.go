@@ -8,16 +8,22 @@ import (
 // fieldInfo contains parsed information from a struct field's xml tag
 type fieldInfo struct {
 	name      string // XML field name (empty means use Go field name)
+	space     string // namespace URI from a "uri name" tag
 	attr      bool   // field is an XML attribute (attr option)
 	cdata     bool   // field is CDATA content (cdata option)
 	chardata  bool   // field is text content (chardata option)
 	omitEmpty bool   // omitempty option
 	skip      bool   // skip this field (tag is "-")
+	countAttr string // countattr=name option: parent attribute holding the length
+	key       string // key=name option: attribute holding the map key of each element
+	layout    string // layout=layout option: time.Time layout for a time field
 }
 
 // parseTag parses a struct field's xml tag value
-// Format: "fieldname" or "fieldname,option1,option2"
-// Options: attr, cdata, chardata, omitempty
+// Format: "fieldname" or "fieldname,option1,option2"; the name may be
+// preceded by a namespace URI and a space, as in "urn:example prefix:name"
+// Options: attr, cdata, chardata, omitempty, countattr=name, key=name,
+// layout=layout
 // Special: "-" means skip field
 //
 // XML tag conventions:
@@ -25,6 +31,13 @@ type fieldInfo struct {
 //   - chardata: Field contains text content
 //   - cdata: Field contains CDATA content
 //   - omitempty: Omit field if value is empty
+//   - countattr=name: Write the length of a slice, array or map field as
+//     attribute name on the enclosing element
+//   - key=name: Write a map field as one element per entry, with the map
+//     key as attribute name
+//   - layout=layout: Format and parse a time.Time field with the given
+//     time layout. It must be the last option, as the layout extends to
+//     the end of the tag and may contain commas.
 func parseTag(tag string) fieldInfo {
 	info := fieldInfo{}
 
@@ -37,10 +50,19 @@ func parseTag(tag string) fieldInfo {
 	parts := strings.Split(tag, ",")
 	if len(parts) > 0 {
 		info.name = parts[0]
+		// "uri name" puts the element in namespace uri.
+		if i := strings.LastIndexByte(info.name, ' '); i >= 0 {
+			info.space = strings.TrimSpace(info.name[:i])
+			info.name = info.name[i+1:]
+		}
 	}
 
 	// Parse options
 	for i := 1; i < len(parts); i++ {
+		if layout, ok := strings.CutPrefix(strings.TrimLeft(parts[i], " "), "layout="); ok {
+			info.layout = strings.Join(append([]string{layout}, parts[i+1:]...), ",")
+			break
+		}
 		switch strings.TrimSpace(parts[i]) {
 		case "attr":
 			info.attr = true
@@ -50,6 +72,13 @@ func parseTag(tag string) fieldInfo {
 			info.chardata = true
 		case "omitempty":
 			info.omitEmpty = true
+		default:
+			opt := strings.TrimSpace(parts[i])
+			if name, ok := strings.CutPrefix(opt, "countattr="); ok {
+				info.countAttr = name
+			} else if name, ok := strings.CutPrefix(opt, "key="); ok {
+				info.key = name
+			}
 		}
 	}
 
@@ -0,0 +1,103 @@
+package xml
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// textLevel implements the text interfaces on its pointer only.
+type textLevel struct {
+	n int
+}
+
+func (l *textLevel) MarshalText() ([]byte, error) {
+	if l.n < 0 {
+		return nil, errors.New("negative level")
+	}
+	return []byte(strings.Repeat("*", l.n)), nil
+}
+
+func (l *textLevel) UnmarshalText(b []byte) error {
+	if strings.Trim(string(b), "*") != "" {
+		return errors.New("not a level")
+	}
+	l.n = len(b)
+	return nil
+}
+
+type textEvent struct {
+	At    time.Time   `xml:"at,attr"`
+	Host  net.IP      `xml:"host"`
+	Level textLevel   `xml:"level"`
+	Seen  *time.Time  `xml:"seen"`
+	Log   []time.Time `xml:"log"`
+	Note  textLevel   `xml:",chardata"`
+}
+
+func TestMarshal_TextMarshaler(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	ev := textEvent{
+		At:    at,
+		Host:  net.ParseIP("10.0.0.1"),
+		Level: textLevel{n: 2},
+		Seen:  &at,
+		Log:   []time.Time{at, at.Add(time.Hour)},
+		Note:  textLevel{n: 1},
+	}
+	data, err := Marshal(&ev)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `<textEvent at="2024-01-02T03:04:05Z">*<host>10.0.0.1</host><level>**</level>` +
+		`<seen>2024-01-02T03:04:05Z</seen><log>2024-01-02T03:04:05Z</log><log>2024-01-02T04:04:05Z</log></textEvent>`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+
+	var got textEvent
+	if err := Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, ev) {
+		t.Errorf("round trip = %+v, want %+v", got, ev)
+	}
+}
+
+func TestMarshal_TextMarshalerUnaddressable(t *testing.T) {
+	// Without an address the pointer method is unavailable, so a value
+	// passed directly encodes as a plain struct.
+	data, err := Marshal(map[string]interface{}{"level": textLevel{n: 3}})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != `<root><level/></root>` {
+		t.Errorf("got %s", data)
+	}
+}
+
+func TestMarshal_TextMarshalerError(t *testing.T) {
+	if _, err := Marshal(&textEvent{Level: textLevel{n: -1}}); err == nil || !strings.Contains(err.Error(), "negative level") {
+		t.Errorf("err = %v, want the MarshalText error", err)
+	}
+}
+
+func TestUnmarshal_TextUnmarshalerError(t *testing.T) {
+	var ev textEvent
+	err := Unmarshal([]byte(`<textEvent><level>**x</level></textEvent>`), &ev)
+	var ue *UnmarshalError
+	if !errors.As(err, &ue) || ue.Path != "/textEvent/level" {
+		t.Fatalf("err = %v, want an *UnmarshalError at /textEvent/level", err)
+	}
+	if !strings.Contains(err.Error(), "not a level") {
+		t.Errorf("err = %v, want the UnmarshalText error", err)
+	}
+
+	err = Unmarshal([]byte(`<textEvent><level><x/></level></textEvent>`), &ev)
+	if err == nil {
+		t.Error("expected an error for child elements in a text value")
+	}
+}
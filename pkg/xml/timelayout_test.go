@@ -0,0 +1,115 @@
+package xml
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type timeLayouts struct {
+	Day     time.Time    `xml:"day,attr,layout=2006-01-02"`
+	Stamp   time.Time    `xml:"stamp"`
+	Header  time.Time    `xml:"header,layout=02-Jan-2006,15:04"`
+	Expires *time.Time   `xml:"expires,layout=2006-01-02"`
+	Hours   []time.Time  `xml:"hour,layout=15:04"`
+	Missing *time.Time   `xml:"missing"`
+	Pair    [2]time.Time `xml:"pair,layout=2006"`
+}
+
+type timeText struct {
+	At time.Time `xml:",chardata,layout=2006-01-02"`
+}
+
+func TestMarshal_TimeLayout(t *testing.T) {
+	at := time.Date(2024, 3, 9, 14, 30, 0, 0, time.UTC)
+	v := timeLayouts{
+		Day:     at,
+		Stamp:   at,
+		Header:  at,
+		Expires: &at,
+		Hours:   []time.Time{at, at.Add(time.Hour)},
+		Pair:    [2]time.Time{at, at.AddDate(1, 0, 0)},
+	}
+	data, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	// time.Time defaults to RFC 3339.
+	want := `<timeLayouts day="2024-03-09"><stamp>2024-03-09T14:30:00Z</stamp>` +
+		`<header>09-Mar-2024,14:30</header><expires>2024-03-09</expires>` +
+		`<hour>14:30</hour><hour>15:30</hour><pair>2024</pair><pair>2025</pair></timeLayouts>`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+
+	var got timeLayouts
+	if err := Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	day := time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)
+	wantV := timeLayouts{
+		Day:     day,
+		Stamp:   at,
+		Header:  at,
+		Expires: &day,
+		Hours:   []time.Time{time.Date(0, 1, 1, 14, 30, 0, 0, time.UTC), time.Date(0, 1, 1, 15, 30, 0, 0, time.UTC)},
+		Pair:    [2]time.Time{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(got, wantV) {
+		t.Errorf("Unmarshal = %+v\nwant %+v", got, wantV)
+	}
+}
+
+func TestMarshal_TimeLayoutChardata(t *testing.T) {
+	data, err := Marshal(timeText{At: time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)})
+	if err != nil || string(data) != `<timeText>2024-03-09</timeText>` {
+		t.Fatalf("Marshal = %s, %v", data, err)
+	}
+	var got timeText
+	if err := Unmarshal([]byte(`<timeText> 2024-03-09 </timeText>`), &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !got.At.Equal(time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("At = %v", got.At)
+	}
+}
+
+func TestMarshal_TimeLayoutErrors(t *testing.T) {
+	type notTime struct {
+		N int `xml:"n,layout=2006"`
+	}
+	if _, err := Marshal(notTime{}); err == nil || !strings.Contains(err.Error(), "layout needs a time.Time") {
+		t.Errorf("err = %v, want a layout error", err)
+	}
+	type sliceAttr struct {
+		T []time.Time `xml:"t,attr,layout=2006"`
+	}
+	if _, err := Marshal(sliceAttr{}); err == nil || !strings.Contains(err.Error(), "layout needs a time.Time") {
+		t.Errorf("err = %v, want a layout error", err)
+	}
+}
+
+func TestUnmarshal_TimeLayoutErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		path string
+	}{
+		{`<timeLayouts day="2024/03/09"/>`, "/timeLayouts/@day"},
+		{`<timeLayouts><hour>1:00</hour><hour>noon</hour></timeLayouts>`, "/timeLayouts/hour[2]"},
+		{`<timeLayouts><stamp>yesterday</stamp></timeLayouts>`, "/timeLayouts/stamp"},
+	}
+	for _, tt := range tests {
+		var v timeLayouts
+		err := Unmarshal([]byte(tt.data), &v)
+		var ue *UnmarshalError
+		if !errors.As(err, &ue) {
+			t.Errorf("%s: err = %v, want *UnmarshalError", tt.data, err)
+			continue
+		}
+		if ue.Path != tt.path {
+			t.Errorf("%s: Path = %q, want %q", tt.data, ue.Path, tt.path)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package xml
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+	"github.com/shapestone/shape-xml/internal/lexical"
+)
+
+// ValueType selects the Go type that WithTypes converts a value to.
+type ValueType int
+
+const (
+	// TypeString keeps the value as a string.
+	TypeString ValueType = iota
+	// TypeInt converts the value to int64.
+	TypeInt
+	// TypeFloat converts the value to float64.
+	TypeFloat
+	// TypeBool converts the value to bool; "true", "false", "1" and "0" are accepted.
+	TypeBool
+	// TypeDecimal checks that the value is a decimal number and stores it as
+	// a Number, keeping every digit.
+	TypeDecimal
+	// TypeDuration converts a Go ("1m30s") or ISO 8601 ("PT1M30S") duration
+	// to time.Duration.
+	TypeDuration
+)
+
+// String returns the name of the type as used in error messages.
+func (t ValueType) String() string {
+	switch t {
+	case TypeString:
+		return "string"
+	case TypeInt:
+		return "int"
+	case TypeFloat:
+		return "float"
+	case TypeBool:
+		return "bool"
+	case TypeDecimal:
+		return "decimal"
+	case TypeDuration:
+		return "duration"
+	default:
+		return "ValueType(" + strconv.Itoa(int(t)) + ")"
+	}
+}
+
+// convert converts the text s to t.
+func (t ValueType) convert(s string) (interface{}, error) {
+	trimmed := strings.TrimSpace(s)
+	switch t {
+	case TypeString:
+		return s, nil
+	case TypeInt:
+		return strconv.ParseInt(trimmed, 10, 64)
+	case TypeFloat:
+		return strconv.ParseFloat(trimmed, 64)
+	case TypeBool:
+		return strconv.ParseBool(trimmed)
+	case TypeDecimal:
+		if !isNumber(trimmed) {
+			return nil, fmt.Errorf("not a decimal number")
+		}
+		return Number(trimmed), nil
+	case TypeDuration:
+		return lexical.ParseDuration(trimmed)
+	default:
+		return nil, fmt.Errorf("unknown type %v", t)
+	}
+}
+
+// typeEntry is one path given to WithTypes.
+type typeEntry struct {
+	path []string
+	typ  ValueType
+}
+
+// typeMap matches the paths given to WithTypes. Entries are ordered most
+// specific first, so the longest matching path wins and, among paths of the
+// same length, the one with fewer wildcards.
+type typeMap []typeEntry
+
+// newTypeMap compiles the paths given to WithTypes.
+func newTypeMap(types map[string]ValueType) typeMap {
+	tm := make(typeMap, 0, len(types))
+	for p, typ := range types {
+		tm = append(tm, typeEntry{path: splitElementPath(p), typ: typ})
+	}
+	sort.Slice(tm, func(i, j int) bool {
+		a, b := tm[i].path, tm[j].path
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		if wa, wb := wildcards(a), wildcards(b); wa != wb {
+			return wa < wb
+		}
+		return strings.Join(a, "/") < strings.Join(b, "/")
+	})
+	return tm
+}
+
+// wildcards returns the number of "*" segments in path.
+func wildcards(path []string) int {
+	n := 0
+	for _, seg := range path {
+		if seg == "*" {
+			n++
+		}
+	}
+	return n
+}
+
+// lookup returns the type for the value at path. Paths match the end of
+// path, so "@id" applies to every id attribute and "price" to the text of
+// every price element.
+func (tm typeMap) lookup(path []string) (ValueType, bool) {
+	elem := path
+	if path[len(path)-1] == "#text" {
+		elem = path[:len(path)-1]
+	}
+	for _, e := range tm {
+		for _, p := range [][]string{path, elem} {
+			if len(e.path) <= len(p) && matchPath(e.path, p[len(p)-len(e.path):], len(p) == len(e.path)) {
+				return e.typ, true
+			}
+		}
+	}
+	return TypeString, false
+}
+
+// convert converts the value at path if a type is declared for it.
+func (tm typeMap) convert(path []string, s string) (interface{}, error) {
+	typ, ok := tm.lookup(path)
+	if !ok {
+		return s, nil
+	}
+	v, err := typ.convert(s)
+	if err != nil {
+		return nil, fmt.Errorf("xml: %s: cannot convert %q to %v", strings.Join(path, "/"), s, typ)
+	}
+	return v, nil
+}
+
+// apply converts the attributes and text of m, the element at path, and of
+// its descendants in place. Values that fail to convert are left as text and
+// an error naming one of them is returned.
+func (tm typeMap) apply(m map[string]interface{}, path []string) (firstErr error) {
+	for k, child := range m {
+		childPath := append(path[:len(path):len(path)], k)
+		switch v := child.(type) {
+		case string:
+			if k == "" || (k[0] != '@' && k != "#text" && k != "#cdata") {
+				continue
+			}
+			converted, err := tm.convert(childPath, v)
+			if err != nil {
+				firstErr = firstError(firstErr, err)
+				continue
+			}
+			m[k] = converted
+		case map[string]interface{}:
+			firstErr = firstError(firstErr, tm.apply(v, childPath))
+		case []interface{}:
+			for _, item := range v {
+				if im, ok := item.(map[string]interface{}); ok {
+					firstErr = firstError(firstErr, tm.apply(im, childPath))
+				}
+			}
+		}
+	}
+	return firstErr
+}
+
+// applyNodes is apply for the AST.
+func (tm typeMap) applyNodes(node *ast.ObjectNode, path []string) (firstErr error) {
+	props := node.Properties()
+	for k, child := range props {
+		childPath := append(path[:len(path):len(path)], k)
+		switch v := child.(type) {
+		case *ast.LiteralNode:
+			s, ok := v.Value().(string)
+			if !ok || k == "" || (k[0] != '@' && k != "#text" && k != "#cdata") {
+				continue
+			}
+			converted, err := tm.convert(childPath, s)
+			if err != nil {
+				firstErr = firstError(firstErr, err)
+				continue
+			}
+			props[k] = ast.NewLiteralNode(converted, v.Position())
+		case *ast.ObjectNode:
+			firstErr = firstError(firstErr, tm.applyNodes(v, childPath))
+		case *ast.ArrayDataNode:
+			for _, item := range v.Elements() {
+				if obj, ok := item.(*ast.ObjectNode); ok {
+					firstErr = firstError(firstErr, tm.applyNodes(obj, childPath))
+				}
+			}
+		}
+	}
+	return firstErr
+}
+
+// firstError returns err if first is nil, and first otherwise.
+func firstError(first, err error) error {
+	if first != nil {
+		return first
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package xml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const typedOrder = `<order id="7" paid="true"><price currency="EUR">12.30</price><qty>3</qty><ttl>PT1M</ttl>` +
+	`<line id="1"><price>0.5</price></line><line id="2"><price>1e3</price></line></order>`
+
+func TestWithTypes(t *testing.T) {
+	var v map[string]interface{}
+	err := UnmarshalWithOptions([]byte(typedOrder), &v, WithTypes(map[string]ValueType{
+		"@id":         TypeInt,
+		"@paid":       TypeBool,
+		"price.#text": TypeDecimal,
+		"qty":         TypeInt,
+		"ttl":         TypeDuration,
+	}))
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions failed: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"@id":   int64(7),
+		"@paid": true,
+		"price": map[string]interface{}{"@currency": "EUR", "#text": Number("12.30")},
+		"qty":   map[string]interface{}{"#text": int64(3)},
+		"ttl":   map[string]interface{}{"#text": time.Minute},
+		"line": []interface{}{
+			map[string]interface{}{"@id": int64(1), "price": map[string]interface{}{"#text": Number("0.5")}},
+			map[string]interface{}{"@id": int64(2), "price": map[string]interface{}{"#text": Number("1e3")}},
+		},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("got  %#v\nwant %#v", v, want)
+	}
+}
+
+func TestWithTypes_MostSpecificPath(t *testing.T) {
+	var v map[string]interface{}
+	err := UnmarshalWithOptions([]byte(typedOrder), &v, WithTypes(map[string]ValueType{
+		"price":            TypeDecimal,
+		"line/price/#text": TypeFloat,
+		"order/@id":        TypeString,
+		"*/@id":            TypeInt,
+	}))
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions failed: %v", err)
+	}
+	if v["@id"] != "7" {
+		t.Errorf("root @id = %#v, want the anchored string type", v["@id"])
+	}
+	if got := v["price"].(map[string]interface{})["#text"]; got != Number("12.30") {
+		t.Errorf("order price = %#v", got)
+	}
+	line := v["line"].([]interface{})[1].(map[string]interface{})
+	if line["@id"] != int64(2) {
+		t.Errorf("line @id = %#v", line["@id"])
+	}
+	if got := line["price"].(map[string]interface{})["#text"]; got != 1000.0 {
+		t.Errorf("line price = %#v", got)
+	}
+}
+
+func TestWithTypes_ConversionError(t *testing.T) {
+	var v map[string]interface{}
+	err := UnmarshalWithOptions([]byte(`<order><qty>three</qty></order>`), &v,
+		WithTypes(map[string]ValueType{"qty": TypeInt}))
+	if err == nil || !strings.Contains(err.Error(), `order/qty/#text: cannot convert "three" to int`) {
+		t.Errorf("err = %v", err)
+	}
+
+	// The AST reports the same failure; NodeToInterfaceWithOptions keeps the text.
+	if _, err := ParseWithOptions(`<order qty="three"/>`, WithTypes(map[string]ValueType{"@qty": TypeInt})); err == nil {
+		t.Error("expected ParseWithOptions to fail")
+	}
+	node, err := Parse(`<order id="x"/>`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	m := NodeToInterfaceWithOptions(node, WithTypes(map[string]ValueType{"@id": TypeInt})).(map[string]interface{})
+	if m["@id"] != "x" {
+		t.Errorf("@id = %#v, want the original text", m["@id"])
+	}
+}
+
+func TestWithTypes_AST(t *testing.T) {
+	node, err := ParseWithOptions(`<order id="7"><total>9.5</total></order>`,
+		WithTypes(map[string]ValueType{"@id": TypeInt, "total": TypeFloat}))
+	if err != nil {
+		t.Fatalf("ParseWithOptions failed: %v", err)
+	}
+	m := NodeToInterface(node).(map[string]interface{})
+	if m["@id"] != int64(7) {
+		t.Errorf("@id = %#v", m["@id"])
+	}
+}
+
+func TestWithTypes_StructTarget(t *testing.T) {
+	type Order struct {
+		ID  int    `xml:"id,attr"`
+		Qty uint   `xml:"qty"`
+		TTL string `xml:"ttl"`
+	}
+	var o Order
+	err := UnmarshalWithOptions([]byte(`<order id="7"><qty>3</qty><ttl>1m</ttl></order>`), &o,
+		WithTypes(map[string]ValueType{"@id": TypeInt, "qty": TypeInt}))
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions failed: %v", err)
+	}
+	if o != (Order{ID: 7, Qty: 3, TTL: "1m"}) {
+		t.Errorf("got %+v", o)
+	}
+}
+
+func TestValueType_String(t *testing.T) {
+	if TypeDecimal.String() != "decimal" || ValueType(99).String() != "ValueType(99)" {
+		t.Errorf("unexpected names %q, %q", TypeDecimal, ValueType(99))
+	}
+}
@@ -0,0 +1,267 @@
+package xml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// encoderFlushThreshold is the buffered size at which the Encoder writes its
+// buffer through to the underlying io.Writer.
+const encoderFlushThreshold = 32 * 1024
+
+// Encoder writes XML to an output stream.
+//
+// The low-level writer methods (WriteStartElement, WriteAttr, WriteText,
+// WriteEnd) emit XML directly into an internal buffer without building any
+// intermediate structures, which makes them suitable for generating very large
+// documents programmatically:
+//
+//	enc := xml.NewEncoder(w)
+//	enc.WriteStartElement("users")
+//	for _, u := range users {
+//	    enc.WriteStartElement("user")
+//	    enc.WriteAttr("id", u.ID)
+//	    enc.WriteText(u.Name)
+//	    enc.WriteEnd()
+//	}
+//	enc.WriteEnd()
+//	err := enc.Flush()
+//
+// The writer methods guarantee well-formed output: element and attribute names
+// are validated, duplicate attributes are rejected, every WriteEnd must match an
+// open element, and nothing may be written once the root element is closed.
+// Violations are reported as errors and leave the output unchanged.
+//
+// Encode and EncodeElement write whole Go values using the same compiled
+// encoders as Marshal, see Encode.
+//
+// Output is buffered; call Flush when done to write any remaining data, or
+// Close to also verify that every element was closed.
+// An Encoder is not safe for concurrent use.
+type Encoder struct {
+	w   io.Writer
+	buf []byte
+	err error
+
+	// open holds the names of the currently open elements.
+	open []string
+	// inStartTag is true while the most recent start tag is still open for attributes.
+	inStartTag bool
+	// attrs holds the attribute names written on the pending start tag.
+	attrs []string
+	// done is set once the root element has been closed.
+	done bool
+	// opts holds the options set by SetOptions.
+	opts MarshalOptions
+	// started is set once anything has been written.
+	started bool
+}
+
+// NewEncoder returns a new Encoder that writes to w. Its buffer comes from
+// the pool shared with Marshal and is returned by a successful Close.
+func NewEncoder(w io.Writer) *Encoder {
+	bp := getEncodeBuffer()
+	return &Encoder{
+		w:   w,
+		buf: *bp,
+	}
+}
+
+// SetOptions sets the options Encode and EncodeElement apply to the values
+// they write. The root name applies to a value written as the root
+// element, and the declaration is written before the first output. The
+// layout options lay out each encoded value on its own, indented by the
+// number of elements open around it; the writer methods are unaffected.
+// SetOptions returns an error, and keeps the previous options, if opts
+// holds an invalid root name or declaration value.
+func (e *Encoder) SetOptions(opts MarshalOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	e.opts = opts
+	return nil
+}
+
+// WriteHeader writes an XML declaration, with the encoding and standalone
+// values set by SetOptions, whether or not the Declaration option is set. It
+// must be called before anything else is written.
+func (e *Encoder) WriteHeader() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.started {
+		return errors.New("xml: WriteHeader called after output was written")
+	}
+	e.started = true
+	e.buf = appendDeclaration(e.buf, &e.opts)
+	return e.maybeFlush()
+}
+
+// begin writes what precedes the first output: the declaration, if the
+// options ask for one.
+func (e *Encoder) begin() {
+	if !e.started {
+		e.started = true
+		if e.opts.Declaration {
+			e.buf = appendDeclaration(e.buf, &e.opts)
+		}
+	}
+}
+
+// WriteStartElement writes the start of an element named name.
+// Attributes may be added with WriteAttr until content is written.
+func (e *Encoder) WriteStartElement(name string) error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.done {
+		return fmt.Errorf("xml: cannot start element %q after the root element was closed", name)
+	}
+	if !isValidXMLName(name) {
+		return fmt.Errorf("xml: invalid element name %q", name)
+	}
+	e.begin()
+	e.closeStartTag()
+	e.buf = append(e.buf, '<')
+	e.buf = append(e.buf, name...)
+	e.open = append(e.open, name)
+	e.inStartTag = true
+	e.attrs = e.attrs[:0]
+	return e.maybeFlush()
+}
+
+// WriteAttr writes an attribute on the element most recently started with
+// WriteStartElement. It returns an error if content has already been written
+// to that element, if name is not a valid XML name, or if the element already
+// has an attribute with the same name.
+func (e *Encoder) WriteAttr(name, value string) error {
+	if e.err != nil {
+		return e.err
+	}
+	if !e.inStartTag {
+		return errors.New("xml: WriteAttr called outside of a start tag")
+	}
+	if !isValidXMLName(name) {
+		return fmt.Errorf("xml: invalid attribute name %q", name)
+	}
+	for _, existing := range e.attrs {
+		if existing == name {
+			return fmt.Errorf("xml: duplicate attribute %q on element %q", name, e.open[len(e.open)-1])
+		}
+	}
+	e.attrs = append(e.attrs, name)
+	e.buf = append(e.buf, ' ')
+	e.buf = append(e.buf, name...)
+	e.buf = append(e.buf, '=', '"')
+	e.buf = appendEscapeAttr(e.buf, value, &e.opts)
+	e.buf = append(e.buf, '"')
+	return e.maybeFlush()
+}
+
+// WriteText writes escaped character data inside the current element.
+func (e *Encoder) WriteText(text string) error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.open) == 0 {
+		if e.done {
+			return errors.New("xml: WriteText called after the root element was closed")
+		}
+		return errors.New("xml: WriteText called outside of an element")
+	}
+	e.closeStartTag()
+	e.buf = AppendEscapedText(e.buf, text)
+	return e.maybeFlush()
+}
+
+// WriteEnd closes the innermost open element. Elements without content are
+// written in self-closing form (<name/>).
+func (e *Encoder) WriteEnd() error {
+	if e.err != nil {
+		return e.err
+	}
+	n := len(e.open)
+	if n == 0 {
+		return errors.New("xml: WriteEnd called with no open element")
+	}
+	name := e.open[n-1]
+	e.open = e.open[:n-1]
+	e.done = len(e.open) == 0
+
+	if e.inStartTag {
+		e.buf = append(e.buf, '/', '>')
+		e.inStartTag = false
+	} else {
+		e.buf = append(e.buf, '<', '/')
+		e.buf = append(e.buf, name...)
+		e.buf = append(e.buf, '>')
+	}
+	return e.maybeFlush()
+}
+
+// WriteEndElement closes the innermost open element like WriteEnd, but first
+// checks that it is named name. A mismatch is reported as an error and nothing
+// is written.
+func (e *Encoder) WriteEndElement(name string) error {
+	if e.err != nil {
+		return e.err
+	}
+	n := len(e.open)
+	if n == 0 {
+		return fmt.Errorf("xml: end element %q with no open element", name)
+	}
+	if open := e.open[n-1]; open != name {
+		return fmt.Errorf("xml: end element %q does not match open element %q", name, open)
+	}
+	return e.WriteEnd()
+}
+
+// Close flushes buffered output and reports an error if any element is still open.
+func (e *Encoder) Close() error {
+	if err := e.Flush(); err != nil {
+		return err
+	}
+	if n := len(e.open); n > 0 {
+		return fmt.Errorf("xml: %d unclosed element(s), innermost %q", n, e.open[n-1])
+	}
+	if e.done && cap(e.buf) <= encoderFlushThreshold*4 {
+		// The document is complete, so nothing can be written any more.
+		buf := e.buf[:0]
+		e.buf = nil
+		putEncodeBuffer(&buf)
+	}
+	return nil
+}
+
+// Flush writes any buffered XML to the underlying writer.
+func (e *Encoder) Flush() error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.buf) == 0 {
+		return nil
+	}
+	if _, err := e.w.Write(e.buf); err != nil {
+		e.err = err
+		return err
+	}
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// closeStartTag terminates a pending start tag with '>'.
+func (e *Encoder) closeStartTag() {
+	if e.inStartTag {
+		e.buf = append(e.buf, '>')
+		e.inStartTag = false
+	}
+}
+
+// maybeFlush writes the buffer through once it grows past the flush threshold.
+func (e *Encoder) maybeFlush() error {
+	if len(e.buf) < encoderFlushThreshold {
+		return nil
+	}
+	return e.Flush()
+}
@@ -0,0 +1,105 @@
+package xml
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Encode writes the XML encoding of v to the stream, following the same
+// rules and root element name as Marshal.
+//
+// Encode may be mixed with the writer methods: called inside an open element
+// it writes v as a child, so a large feed can be produced one record at a
+// time without holding the whole document in memory:
+//
+//	enc := xml.NewEncoder(file)
+//	enc.WriteStartElement("orders")
+//	for rows.Next() {
+//	    enc.EncodeElement(order, "order")
+//	}
+//	enc.WriteEnd()
+//	err := enc.Close()
+//
+// Large slices and maps inside v are written through to the underlying writer
+// as they are encoded rather than buffered whole.
+func (e *Encoder) Encode(v interface{}) error {
+	return e.EncodeElement(v, "")
+}
+
+// EncodeElement works like Encode but uses name as the element name of v.
+// An empty name selects the name Marshal would use. The XMLName field of a
+// struct takes precedence over name, as it does over field names.
+func (e *Encoder) EncodeElement(v interface{}, name string) error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.done {
+		return fmt.Errorf("xml: cannot encode a value after the root element was closed")
+	}
+	if name != "" && !isValidXMLName(name) {
+		return fmt.Errorf("xml: invalid element name %q", name)
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if name == "" {
+		name = "root"
+		if rv.IsValid() {
+			name = rootElementName(rv)
+		}
+		if len(e.open) == 0 {
+			name = rootName(e.opts, name)
+		}
+	}
+
+	start, inStartTag, started := len(e.buf), e.inStartTag, e.started
+	e.begin()
+	e.closeStartTag()
+	valueStart := len(e.buf)
+	flushed := false
+	es := &encodeState{opts: e.opts}
+	if !e.opts.reformats() {
+		// A value that is laid out afterwards must stay in the buffer.
+		es.flush = func(buf []byte) ([]byte, error) {
+			e.buf = buf
+			if err := e.Flush(); err != nil {
+				return buf, err
+			}
+			flushed = true
+			return e.buf, nil
+		}
+	}
+
+	var err error
+	if !rv.IsValid() || rv.Kind() == reflect.Ptr {
+		// nil interface or nil pointer
+		e.buf = appendEmptyElement(e.buf, name)
+	} else {
+		e.buf, err = xmlEncoderForType(rv.Type())(es, e.buf, rv, name)
+	}
+	if err != nil {
+		if flushed {
+			// Part of the value has been written; the stream is unusable.
+			e.err = err
+		} else {
+			e.buf, e.inStartTag, e.started = e.buf[:start], inStartTag, started
+		}
+		return err
+	}
+	if e.opts.reformats() {
+		value := append([]byte(nil), e.buf[valueStart:]...)
+		e.buf = formatXML(e.buf[:valueStart], value, &e.opts, len(e.open), len(e.open) > 0)
+	}
+
+	e.done = len(e.open) == 0
+	return e.maybeFlush()
+}
+
+// appendEmptyElement appends <name/>, which Marshal writes for nil values.
+func appendEmptyElement(buf []byte, name string) []byte {
+	buf = append(buf, '<')
+	buf = append(buf, name...)
+	return append(buf, '/', '>')
+}
@@ -0,0 +1,163 @@
+package xml
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type encodeItem struct {
+	ID   int    `xml:"id,attr"`
+	Name string `xml:"name"`
+}
+
+type encodeFeed struct {
+	Title string       `xml:"title"`
+	Items []encodeItem `xml:"item"`
+}
+
+func TestEncoder_EncodeMatchesMarshal(t *testing.T) {
+	values := []interface{}{
+		encodeFeed{Title: "t", Items: []encodeItem{{ID: 1, Name: "a & b"}}},
+		&encodeItem{ID: 2},
+		map[string]interface{}{"b": 1, "a": []string{"x", "y"}},
+		"text",
+	}
+	for _, v := range values {
+		want, err := Marshal(v)
+		if err != nil {
+			t.Fatalf("Marshal(%#v) failed: %v", v, err)
+		}
+		var out bytes.Buffer
+		enc := NewEncoder(&out)
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("Encode(%#v) failed: %v", v, err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		if out.String() != string(want) {
+			t.Errorf("Encode(%#v) = %s, want %s", v, out.String(), want)
+		}
+	}
+}
+
+func TestEncoder_EncodeElement(t *testing.T) {
+	var out bytes.Buffer
+	enc := NewEncoder(&out)
+	steps := []error{
+		enc.WriteStartElement("feed"),
+		enc.WriteAttr("version", "2"),
+		enc.EncodeElement(encodeItem{ID: 1, Name: "one"}, "entry"),
+		enc.EncodeElement(nil, "empty"),
+		enc.Encode((*encodeItem)(nil)),
+		enc.WriteEnd(),
+		enc.Close(),
+	}
+	for i, err := range steps {
+		if err != nil {
+			t.Fatalf("step %d: unexpected error %v", i, err)
+		}
+	}
+	want := `<feed version="2"><entry id="1"><name>one</name></entry><empty/><root/></feed>`
+	if out.String() != want {
+		t.Errorf("got  %s\nwant %s", out.String(), want)
+	}
+
+	if err := enc.Encode(encodeItem{}); err == nil {
+		t.Error("expected error encoding after the root element was closed")
+	}
+	if err := NewEncoder(&out).EncodeElement(1, "bad name"); err == nil {
+		t.Error("expected error for an invalid element name")
+	}
+}
+
+func TestEncoder_EncodeErrorLeavesOutputUnchanged(t *testing.T) {
+	var out bytes.Buffer
+	enc := NewEncoder(&out)
+	if err := enc.WriteStartElement("r"); err != nil {
+		t.Fatal(err)
+	}
+	err := enc.Encode(map[string]interface{}{"x": math.NaN()})
+	if err == nil {
+		t.Fatal("expected an error for NaN")
+	}
+	// The start tag is still open, so attributes can be added.
+	if err := enc.WriteAttr("ok", "1"); err != nil {
+		t.Fatalf("WriteAttr after failed Encode: %v", err)
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != `<r ok="1"/>` {
+		t.Errorf("got %s", out.String())
+	}
+}
+
+// writeRecorder records the size of each write.
+type writeRecorder struct {
+	bytes.Buffer
+	writes []int
+}
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, len(p))
+	return w.Buffer.Write(p)
+}
+
+func TestEncoder_EncodeStreamsLargeValues(t *testing.T) {
+	feed := encodeFeed{Title: "big"}
+	for i := 0; i < 20000; i++ {
+		feed.Items = append(feed.Items, encodeItem{ID: i, Name: strings.Repeat("n", 20)})
+	}
+	want, err := Marshal(feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out writeRecorder
+	enc := NewEncoder(&out)
+	if err := enc.Encode(feed); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if len(out.writes) == 0 {
+		t.Fatal("nothing was written before Flush")
+	}
+	for _, n := range out.writes {
+		if n > 2*encoderFlushThreshold {
+			t.Errorf("write of %d bytes exceeds the flush threshold", n)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != string(want) {
+		t.Error("streamed output differs from Marshal")
+	}
+}
+
+func TestEncoder_EncodeArrayHintsStayIntact(t *testing.T) {
+	// A flush inside a single hinted item would separate the hint from its
+	// start tag; holdFlush prevents it.
+	var out bytes.Buffer
+	es := &encodeState{opts: MarshalOptions{ArrayHints: true}}
+	es.flush = func(buf []byte) ([]byte, error) {
+		out.Write(buf)
+		return buf[:0], nil
+	}
+	big := encodeFeed{Items: []encodeItem{{ID: 1}, {Name: strings.Repeat("x", 2*encoderFlushThreshold)}}}
+	rv := reflect.ValueOf(map[string]interface{}{"feed": []encodeFeed{big}})
+	buf, err := xmlEncoderForType(rv.Type())(es, nil, rv, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Write(buf)
+	if !strings.HasPrefix(out.String(), `<root><feed shape:array="true"`) {
+		t.Errorf("hint misplaced: %.60s", out.String())
+	}
+}
@@ -0,0 +1,176 @@
+package xml
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncoder_WriterAPI(t *testing.T) {
+	var out bytes.Buffer
+	enc := NewEncoder(&out)
+
+	steps := []error{
+		enc.WriteStartElement("users"),
+		enc.WriteStartElement("user"),
+		enc.WriteAttr("id", "1"),
+		enc.WriteAttr("note", `a "b" & c`),
+		enc.WriteText("Alice <admin>"),
+		enc.WriteEnd(),
+		enc.WriteStartElement("user"),
+		enc.WriteAttr("id", "2"),
+		enc.WriteEnd(),
+		enc.WriteEnd(),
+		enc.Flush(),
+	}
+	for i, err := range steps {
+		if err != nil {
+			t.Fatalf("step %d: unexpected error %v", i, err)
+		}
+	}
+
+	want := `<users><user id="1" note="a &#34;b&#34; &amp; c">Alice &lt;admin></user><user id="2"/></users>`
+	if out.String() != want {
+		t.Errorf("got  %s\nwant %s", out.String(), want)
+	}
+	if err := Validate(out.String()); err != nil {
+		t.Errorf("output is not valid XML: %v", err)
+	}
+}
+
+func TestEncoder_WriterErrors(t *testing.T) {
+	var out bytes.Buffer
+	enc := NewEncoder(&out)
+
+	if err := enc.WriteText("x"); err == nil {
+		t.Error("expected error for WriteText outside element")
+	}
+	if err := enc.WriteEnd(); err == nil {
+		t.Error("expected error for WriteEnd with no open element")
+	}
+	if err := enc.WriteAttr("a", "b"); err == nil {
+		t.Error("expected error for WriteAttr outside start tag")
+	}
+
+	_ = enc.WriteStartElement("a")
+	_ = enc.WriteText("content")
+	if err := enc.WriteAttr("late", "1"); err == nil {
+		t.Error("expected error for WriteAttr after content")
+	}
+}
+
+func TestEncoder_LargeOutputFlushes(t *testing.T) {
+	var out bytes.Buffer
+	enc := NewEncoder(&out)
+
+	_ = enc.WriteStartElement("items")
+	for i := 0; i < 5000; i++ {
+		_ = enc.WriteStartElement("item")
+		_ = enc.WriteText("value")
+		_ = enc.WriteEnd()
+	}
+	if out.Len() == 0 {
+		t.Error("expected buffered output to be flushed before Flush is called")
+	}
+	_ = enc.WriteEnd()
+	if err := enc.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got := strings.Count(out.String(), "<item>"); got != 5000 {
+		t.Errorf("expected 5000 items, got %d", got)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestEncoder_WriteErrorIsSticky(t *testing.T) {
+	enc := NewEncoder(failingWriter{})
+	_ = enc.WriteStartElement("a")
+	if err := enc.Flush(); err == nil {
+		t.Fatal("expected write error")
+	}
+	if err := enc.WriteEnd(); err == nil {
+		t.Error("expected sticky error after failed write")
+	}
+}
+
+func TestEncoder_NestingGuard(t *testing.T) {
+	t.Run("mismatched end element", func(t *testing.T) {
+		enc := NewEncoder(&bytes.Buffer{})
+		_ = enc.WriteStartElement("a")
+		_ = enc.WriteStartElement("b")
+		if err := enc.WriteEndElement("a"); err == nil {
+			t.Error("expected error for mismatched end element")
+		}
+		if err := enc.WriteEndElement("b"); err != nil {
+			t.Errorf("unexpected error closing b: %v", err)
+		}
+		if err := enc.WriteEndElement("a"); err != nil {
+			t.Errorf("unexpected error closing a: %v", err)
+		}
+	})
+
+	t.Run("invalid names", func(t *testing.T) {
+		enc := NewEncoder(&bytes.Buffer{})
+		for _, name := range []string{"", "My Field", "a<b", "1abc", "-x", "a\"b"} {
+			if err := enc.WriteStartElement(name); err == nil {
+				t.Errorf("expected error for element name %q", name)
+			}
+		}
+		_ = enc.WriteStartElement("ok")
+		if err := enc.WriteAttr("bad name", "v"); err == nil {
+			t.Error("expected error for invalid attribute name")
+		}
+		if err := enc.WriteAttr("ns:attr", "v"); err != nil {
+			t.Errorf("unexpected error for prefixed attribute: %v", err)
+		}
+		if err := enc.WriteAttr("ns:attr", "v"); err == nil {
+			t.Error("expected error for duplicate attribute")
+		}
+	})
+
+	t.Run("writes after document end", func(t *testing.T) {
+		enc := NewEncoder(&bytes.Buffer{})
+		_ = enc.WriteStartElement("root")
+		_ = enc.WriteEnd()
+		if err := enc.WriteStartElement("second"); err == nil {
+			t.Error("expected error for second root element")
+		}
+		if err := enc.WriteText("trailing"); err == nil {
+			t.Error("expected error for text after root")
+		}
+		if err := enc.Close(); err != nil {
+			t.Errorf("unexpected Close() error: %v", err)
+		}
+	})
+
+	t.Run("unclosed elements on close", func(t *testing.T) {
+		var out bytes.Buffer
+		enc := NewEncoder(&out)
+		_ = enc.WriteStartElement("root")
+		_ = enc.WriteStartElement("child")
+		if err := enc.Close(); err == nil {
+			t.Error("expected error for unclosed elements")
+		}
+	})
+}
+
+func TestIsValidXMLName(t *testing.T) {
+	valid := []string{"a", "_x", "ns:item", "item-1", "item.2", "élément", "名前"}
+	for _, name := range valid {
+		if !isValidXMLName(name) {
+			t.Errorf("expected %q to be valid", name)
+		}
+	}
+	invalid := []string{"", "1a", "-a", ".a", "a b", "a>b", "a&b", "a/b", "\xff"}
+	for _, name := range invalid {
+		if isValidXMLName(name) {
+			t.Errorf("expected %q to be invalid", name)
+		}
+	}
+}
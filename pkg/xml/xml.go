@@ -14,6 +14,12 @@
 // All functions in this package are safe for concurrent use by multiple goroutines.
 // Each function call creates its own parser instance with no shared mutable state.
 //
+// # TinyGo and WASM
+//
+// Under TinyGo, or with the shapexml_lean build tag, the package keeps no
+// buffer pools and no encoder or struct field caches, trading speed for
+// smaller binaries and a flat memory footprint. The API is unchanged.
+//
 // # Parsing APIs
 //
 // The package provides two parsing functions:
@@ -49,11 +55,14 @@
 package xml
 
 import (
+	"errors"
 	"io"
 
 	"github.com/shapestone/shape-core/pkg/ast"
 	"github.com/shapestone/shape-core/pkg/tokenizer"
+	"github.com/shapestone/shape-xml/internal/charset"
 	"github.com/shapestone/shape-xml/internal/fastparser"
+	"github.com/shapestone/shape-xml/internal/limits"
 	"github.com/shapestone/shape-xml/internal/parser"
 )
 
@@ -80,6 +89,93 @@ func Parse(input string) (ast.SchemaNode, error) {
 	return p.Parse()
 }
 
+// ParseWithOptions parses XML into an AST like Parse, applying the given options.
+//
+// Example preserving decimal text exactly:
+//
+//	node, err := xml.ParseWithOptions(`<price>12.30</price>`, xml.WithUseNumber())
+//	// the #text literal holds xml.Number("12.30")
+func ParseWithOptions(input string, opts ...ParseOption) (node ast.SchemaNode, err error) {
+	o := newParseOptions(opts)
+	defer o.recoverPanic(&err)
+	node, _, err = parseDocument(parser.NewParser(o.cleanString(input)), o)
+	return node, err
+}
+
+// parseBytesWithOptions is ParseWithOptions for input held in a byte slice.
+func parseBytesWithOptions(data []byte, opts ...ParseOption) (node ast.SchemaNode, err error) {
+	o := newParseOptions(opts)
+	defer o.recoverPanic(&err)
+	if data, err = o.convert(data); err != nil {
+		return nil, err
+	}
+	node, _, err = parseDocument(parser.NewParserFromBytes(data), o)
+	return node, err
+}
+
+// parseDocument parses the input of p into an AST, applies o, and also
+// returns the name of the root element.
+func parseDocument(p *parser.Parser, o ParseOptions) (ast.SchemaNode, string, error) {
+	if o.mapOnly() {
+		return nil, "", errors.New("xml: WithElementFilter, WithMaxDepth and WithBackslashEscapes are not supported when parsing to an AST")
+	}
+	p.SetLimits(limits.Limits(o.Limits))
+	p.SetMixedContent(o.MixedContent)
+	p.SetComments(o.Comments)
+	p.SetRawNewlines(o.RawNewlines)
+	p.SetPreserveSpace(o.PreserveSpace)
+	p.SetLenient(o.Lenient)
+	p.SetHTML(o.HTML)
+	p.SetInvalidChars(charset.Mode(o.InvalidChars))
+	node, err := p.Parse()
+	if err != nil {
+		return nil, "", err
+	}
+	if o.ArrayHints {
+		applyArrayHintNodes(node)
+	}
+	if obj, ok := node.(*ast.ObjectNode); ok && len(o.ForceList) > 0 {
+		newForceList(o.ForceList).applyNodes(obj, []string{p.RootName()})
+	}
+	if obj, ok := node.(*ast.ObjectNode); ok && len(o.Types) > 0 {
+		if err := newTypeMap(o.Types).applyNodes(obj, []string{p.RootName()}); err != nil {
+			return nil, "", err
+		}
+	}
+	if o.EmptyAsNil {
+		emptyToNilNodes(node)
+	}
+	if o.UseNumber {
+		useNumberNodes(node)
+	}
+	return node, p.RootName(), nil
+}
+
+// ParseBytes parses XML into an AST like Parse, reading data in place
+// rather than converting it to a string first. Use it when the document is
+// already held as bytes, as returned by io.ReadAll or os.ReadFile.
+//
+// The offsets of positions in the AST count bytes rather than characters,
+// so they differ from those of Parse for non-ASCII input; lines and columns
+// are the same. data must not be modified while ParseBytes runs; the AST
+// does not refer to it afterwards.
+//
+// Example:
+//
+//	data, err := os.ReadFile("config.xml")
+//	if err != nil {
+//	    // handle error
+//	}
+//	node, err := xml.ParseBytes(data)
+func ParseBytes(data []byte) (ast.SchemaNode, error) {
+	data, err := charset.Convert(data, nil)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.NewParserFromBytes(data)
+	return p.Parse()
+}
+
 // ParseReader parses XML format into an AST from an io.Reader.
 //
 // This function is designed for parsing large XML files or streaming data with
@@ -112,9 +208,14 @@ func Parse(input string) (ast.SchemaNode, error) {
 //	}
 //	// node is now a *ast.ObjectNode representing the XML data
 func ParseReader(reader io.Reader) (ast.SchemaNode, error) {
-	stream := tokenizer.NewStreamFromReader(reader)
-	p := parser.NewParserFromStream(stream)
-	return p.Parse()
+	cr := charset.NewReader(reader)
+	p := parser.NewParserFromStream(tokenizer.NewStreamFromReader(cr))
+	node, err := p.Parse()
+	// The stream ends at a read error, such as an invalid character.
+	if cr.Err() != nil {
+		return nil, cr.Err()
+	}
+	return node, err
 }
 
 // Format returns the format identifier for this parser.
@@ -139,8 +240,18 @@ func Format() string {
 //
 // For validating large files or streaming data, use ValidateReader instead.
 func Validate(input string) error {
-	parser := fastparser.NewParser([]byte(input))
-	_, err := parser.Parse()
+	return ValidateBytes([]byte(input))
+}
+
+// ValidateBytes checks if data is valid XML like Validate, without
+// converting it to a string first.
+func ValidateBytes(data []byte) error {
+	data, err := charset.Convert(data, nil)
+	if err != nil {
+		return err
+	}
+	parser := fastparser.NewParser(data)
+	_, err = parser.Parse()
 	return err
 }
 
@@ -167,7 +278,7 @@ func Validate(input string) error {
 //	}
 //	// Valid XML - err is nil
 func ValidateReader(reader io.Reader) error {
-	data, err := io.ReadAll(reader)
+	data, err := io.ReadAll(charset.NewReader(reader))
 	if err != nil {
 		return err
 	}
@@ -385,3 +385,29 @@ func TestConcurrent_MixedOperations(t *testing.T) {
 		<-done
 	}
 }
+
+func TestParse_CDATA(t *testing.T) {
+	node, err := Parse(`<data><![CDATA[<raw> & more]]></data>`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	m := NodeToInterface(node).(map[string]interface{})
+	if m["#cdata"] != "<raw> & more" {
+		t.Errorf("#cdata = %#v", m["#cdata"])
+	}
+}
+
+func TestParse_Doctype(t *testing.T) {
+	input := `<!DOCTYPE html>
+<html><head><title>Page</title></head></html>`
+	node, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := NodeToInterface(node).(map[string]interface{}); got["head"] == nil {
+		t.Errorf("got %#v", got)
+	}
+	if _, err := ParseElement(input); err != nil {
+		t.Errorf("ParseElement failed: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package xml
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// xmlNameInfo describes the XMLName field of a struct type.
+type xmlNameInfo struct {
+	index int
+	name  string // element name from the field's tag; "" to use the value
+	space string // namespace URI from the field's tag
+	value bool   // the field has the fields of Name and may hold the name
+}
+
+// xmlNameFieldInfo returns the XMLName field of struct type t. A field of
+// that name of any type names the element with its tag, as in encoding/xml;
+// one whose type has the fields of Name, such as Name itself or
+// encoding/xml's Name, may hold the name instead. ok is false if t has
+// none.
+func xmlNameFieldInfo(t reflect.Type) (info xmlNameInfo, ok bool, err error) {
+	f, found := t.FieldByName("XMLName")
+	if !found || len(f.Index) != 1 {
+		return info, false, nil
+	}
+	tag := parseTag(f.Tag.Get("xml"))
+	info = xmlNameInfo{index: f.Index[0], name: tag.name, space: tag.space, value: isNameType(f.Type)}
+	if tag.skip {
+		info.name = ""
+	}
+	if info.name != "" && !isValidXMLName(info.name) {
+		return info, false, fmt.Errorf("xml: %s.XMLName: invalid element name %q", t, info.name)
+	}
+	return info, true, nil
+}
+
+// xmlNameTypeTag returns the struct type t leads to, through pointers,
+// slices and arrays, and the element name the tag of its XMLName field
+// gives its elements, "" if there is none.
+func xmlNameTypeTag(t reflect.Type) (reflect.Type, string) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t.Implements(xmlMarshalerType) || reflect.PointerTo(t).Implements(xmlMarshalerType) {
+		return t, ""
+	}
+	info, ok, _ := xmlNameFieldInfo(t)
+	if !ok {
+		return t, ""
+	}
+	return t, info.name
+}
+
+// elementName returns the name and namespace URI of the element for struct
+// value rv, given the name its parent chose: the XMLName tag wins, then a
+// non-empty XMLName value, then elemName.
+func (n *xmlNameInfo) elementName(rv reflect.Value, elemName string) (name, space string, err error) {
+	if n.name != "" {
+		return n.name, n.space, nil
+	}
+	if !n.value {
+		return elemName, "", nil
+	}
+	f := rv.Field(n.index)
+	v := Name{Space: f.Field(0).String(), Local: f.Field(1).String()}
+	if v.Local == "" {
+		return elemName, "", nil
+	}
+	if !isValidXMLName(v.Local) {
+		return "", "", fmt.Errorf("xml: %s.XMLName: invalid element name %q", rv.Type(), v.Local)
+	}
+	return v.Local, v.Space, nil
+}
+
+// isNameType reports whether t is a struct made of the string fields Space
+// and Local, in that order.
+func isNameType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.NumField() == 2 &&
+		t.Field(0).Name == "Space" && t.Field(0).Type.Kind() == reflect.String &&
+		t.Field(1).Name == "Local" && t.Field(1).Type.Kind() == reflect.String
+}
+
+// elemRename records that the element starting at pos was written under
+// name instead of the name its encoder was given.
+type elemRename struct {
+	pos  int
+	name string
+}
+
+// writtenName returns the name of the element encoded at buf[start:] in
+// place of elemName, which differs from elemName when a struct took its
+// name from its XMLName field.
+func (es *encodeState) writtenName(start int, elemName string) string {
+	if es.renamed.name != "" && es.renamed.pos == start {
+		return es.renamed.name
+	}
+	return elemName
+}
+
+// namespaceMarkup returns the xmlns attribute that declares uri for the
+// prefix of name, or as the default namespace for an unprefixed name,
+// together with the binding it makes.
+func namespaceMarkup(name, uri string, opts *MarshalOptions) ([]byte, nsBinding) {
+	prefix, _ := SplitName(name)
+	markup := []byte(" xmlns")
+	if prefix != "" {
+		markup = append(markup, ':')
+		markup = append(markup, prefix...)
+	}
+	markup = append(markup, '=', '"')
+	markup = appendEscapeAttr(markup, uri, opts)
+	markup = append(markup, '"')
+	return markup, nsBinding{prefix: prefix, uri: uri}
+}
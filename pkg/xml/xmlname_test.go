@@ -0,0 +1,223 @@
+package xml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type taggedFeed struct {
+	XMLName Name   `xml:"feed"`
+	Title   string `xml:"title"`
+}
+
+type atomFeed struct {
+	XMLName Name   `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string `xml:"title"`
+}
+
+type dynamicItem struct {
+	XMLName Name
+	ID      string `xml:"id,attr"`
+}
+
+type feedHolder struct {
+	Feed  taggedFeed
+	Items []dynamicItem `xml:"item"`
+}
+
+func TestMarshal_XMLNameRoot(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"tag", taggedFeed{Title: "x"}, `<feed><title>x</title></feed>`},
+		{"tag beats value", taggedFeed{XMLName: Name{Local: "other"}}, `<feed><title></title></feed>`},
+		{"tag namespace", atomFeed{Title: "x"}, `<feed xmlns="http://www.w3.org/2005/Atom"><title>x</title></feed>`},
+		{"value", dynamicItem{XMLName: Name{Local: "entry"}, ID: "1"}, `<entry id="1"/>`},
+		{"value namespace", dynamicItem{XMLName: Name{Space: "urn:a", Local: "a:entry"}}, `<a:entry xmlns:a="urn:a"/>`},
+		{"zero value", dynamicItem{ID: "1"}, `<dynamicItem id="1"/>`},
+		{"pointer", &taggedFeed{Title: "x"}, `<feed><title>x</title></feed>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.v)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("got  %s\nwant %s", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshal_XMLNameOtherType(t *testing.T) {
+	// An XMLName field of any type names the element with its tag, as in
+	// encoding/xml; only a Name holds a name of its own.
+	type tagged struct {
+		XMLName struct{} `xml:"c"`
+		V       string   `xml:"v,attr"`
+	}
+	type untagged struct {
+		XMLName string
+		V       string `xml:"v,attr"`
+	}
+	type holder struct {
+		C []tagged
+		U untagged `xml:"u"`
+	}
+	h := holder{C: []tagged{{V: "1"}, {V: "2"}}, U: untagged{XMLName: "ignored", V: "3"}}
+	data, err := Marshal(h)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `<holder><c v="1"/><c v="2"/><u v="3"/></holder>`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+
+	var got holder
+	if err := Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(got.C) != 2 || got.C[1].V != "2" || got.U.V != "3" || got.U.XMLName != "" {
+		t.Errorf("Unmarshal = %+v", got)
+	}
+}
+
+func TestMarshal_XMLNameNested(t *testing.T) {
+	v := feedHolder{
+		Feed: taggedFeed{Title: "x"},
+		Items: []dynamicItem{
+			{XMLName: Name{Local: "first"}, ID: "1"},
+			{ID: "2"},
+		},
+	}
+	data, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `<feedHolder><feed><title>x</title></feed><first id="1"/><item id="2"/></feedHolder>`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+}
+
+func TestMarshal_XMLNameNamespaceInScope(t *testing.T) {
+	type entry struct {
+		XMLName Name   `xml:"http://www.w3.org/2005/Atom entry"`
+		ID      string `xml:"id"`
+	}
+	type feed struct {
+		XMLName Name    `xml:"http://www.w3.org/2005/Atom feed"`
+		Entries []entry `xml:"entry"`
+	}
+	data, err := Marshal(feed{Entries: []entry{{ID: "1"}}})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `<feed xmlns="http://www.w3.org/2005/Atom"><entry><id>1</id></entry></feed>`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+}
+
+func TestMarshal_XMLNameWithInsertedAttrs(t *testing.T) {
+	type holder struct {
+		Items  []dynamicItem          `xml:"item"`
+		ByName map[string]dynamicItem `xml:"entry,key=name"`
+	}
+	v := holder{
+		Items:  []dynamicItem{{XMLName: Name{Local: "one"}}},
+		ByName: map[string]dynamicItem{"k": {XMLName: Name{Local: "keyed"}}},
+	}
+	data, err := MarshalWithOptions(v, MarshalOptions{ArrayHints: true})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	// The array hint and the key attribute still land in the renamed
+	// start tags.
+	if !bytes.HasPrefix(data, []byte(`<holder><one `+arrayHintAttr)) {
+		t.Errorf("array hint not inserted: %s", data)
+	}
+	if !bytes.Contains(data, []byte(`<keyed name="k"/>`)) {
+		t.Errorf("key attribute not inserted: %s", data)
+	}
+}
+
+func TestMarshal_XMLNameErrors(t *testing.T) {
+	type badTag struct {
+		XMLName Name `xml:"1bad"`
+	}
+	type conflict struct {
+		Feed taggedFeed `xml:"channel"`
+	}
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"invalid tag", badTag{}, "invalid element name"},
+		{"invalid value", dynamicItem{XMLName: Name{Local: "a b"}}, "invalid element name"},
+		{"conflict", conflict{}, "conflicts with name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Marshal(tt.v)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_XMLName(t *testing.T) {
+	var feed atomFeed
+	if err := Unmarshal([]byte(`<a:feed xmlns:a="http://www.w3.org/2005/Atom"><title>x</title></a:feed>`), &feed); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if want := (Name{Space: "http://www.w3.org/2005/Atom", Local: "feed"}); feed.XMLName != want {
+		t.Errorf("XMLName = %+v, want %+v", feed.XMLName, want)
+	}
+	if feed.Title != "x" {
+		t.Errorf("Title = %q", feed.Title)
+	}
+
+	// The XMLName tag names a field that has no tag of its own.
+	var h feedHolder
+	data := `<feedHolder><feed><title>t</title></feed><item id="1"/><item id="2"/></feedHolder>`
+	if err := Unmarshal([]byte(data), &h); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if h.Feed.Title != "t" || h.Feed.XMLName.Local != "feed" {
+		t.Errorf("Feed = %+v", h.Feed)
+	}
+	if len(h.Items) != 2 || h.Items[1].XMLName.Local != "item" || h.Items[1].ID != "2" {
+		t.Errorf("Items = %+v", h.Items)
+	}
+
+	// A decoded value marshals back under the same name.
+	out, err := Marshal(h)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(out) != data {
+		t.Errorf("round trip:\ngot  %s\nwant %s", out, data)
+	}
+}
+
+func TestEncoder_XMLName(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.Encode(taggedFeed{Title: "x"}); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if want := `<feed><title>x</title></feed>`; buf.String() != want {
+		t.Errorf("got %s, want %s", buf.String(), want)
+	}
+}
@@ -0,0 +1,140 @@
+package xml
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/shapestone/shape-core/pkg/ast"
+)
+
+// YAMLCodec encodes and decodes YAML documents for ToYAML and FromYAML.
+// shape-xml has no YAML dependency of its own; any YAML library can be
+// plugged in with a small adapter, for example for gopkg.in/yaml.v3:
+//
+//	type yamlCodec struct{}
+//
+//	func (yamlCodec) Marshal(v interface{}) ([]byte, error)      { return yaml.Marshal(v) }
+//	func (yamlCodec) Unmarshal(data []byte, v interface{}) error { return yaml.Unmarshal(data, v) }
+//
+// Unmarshal is called with a *interface{}, and may decode mappings as
+// map[string]interface{} or map[interface{}]interface{}.
+type YAMLCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// ToYAML converts an XML document into YAML with codec, using the
+// attribute, text and array conventions of opts as ToJSON does. The layout
+// of the YAML is left to the codec, so opts.Indent does not apply.
+//
+// Example:
+//
+//	out, err := xml.ToYAML(pom, yamlCodec{}, xml.JSONOptions{SimpleText: true})
+func ToYAML(input []byte, codec YAMLCodec, opts JSONOptions) ([]byte, error) {
+	node, err := ParseBytes(input)
+	if err != nil {
+		return nil, err
+	}
+	return NodeToYAML(node, codec, opts)
+}
+
+// NodeToYAML converts a parsed document into YAML like ToYAML.
+func NodeToYAML(node ast.SchemaNode, codec YAMLCodec, opts JSONOptions) ([]byte, error) {
+	if codec == nil {
+		return nil, errors.New("xml: NodeToYAML requires a YAMLCodec")
+	}
+	return codec.Marshal(opts.toJSON(NodeToInterface(node)))
+}
+
+// FromYAML converts a YAML document, decoded with codec, into an XML
+// document whose root element is named rootName, as FromJSON does.
+// Scalars are written as the codec decoded them, so an unquoted 1.50
+// becomes 1.5 and a timestamp is written in RFC 3339 form; quote values
+// whose spelling matters.
+//
+// Example:
+//
+//	out, err := xml.FromYAML(config, "configuration", yamlCodec{}, xml.JSONOptions{})
+func FromYAML(data []byte, rootName string, codec YAMLCodec, opts JSONOptions) ([]byte, error) {
+	if codec == nil {
+		return nil, errors.New("xml: FromYAML requires a YAMLCodec")
+	}
+	if !isValidXMLName(rootName) {
+		return nil, fmt.Errorf("xml: invalid root element name %q", rootName)
+	}
+	var v interface{}
+	if err := codec.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("xml: invalid YAML: %w", err)
+	}
+	v, err := yamlValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return opts.document(v, rootName, opts.Indent)
+}
+
+// yamlValue converts a value decoded by a YAML codec into the types
+// encoding/json decodes with UseNumber, which fromJSON reads.
+func yamlValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, string, bool, json.Number:
+		return v, nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			item, err := yamlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = item
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			item, err := yamlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = item
+		}
+		return out, nil
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			item, err := yamlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			out[fmt.Sprint(key)] = item
+		}
+		return out, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return json.Number(strconv.FormatInt(rv.Int(), 10)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return json.Number(strconv.FormatUint(rv.Uint(), 10)), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		switch {
+		case math.IsNaN(f):
+			return "NaN", nil
+		case math.IsInf(f, 1):
+			return "INF", nil
+		case math.IsInf(f, -1):
+			return "-INF", nil
+		}
+		return json.Number(strconv.FormatFloat(f, 'g', -1, rv.Type().Bits())), nil
+	}
+	return nil, fmt.Errorf("xml: cannot convert YAML value of type %T", v)
+}
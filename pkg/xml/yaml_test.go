@@ -0,0 +1,97 @@
+package xml
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// jsonCodec stands in for a YAML library: JSON is valid YAML.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// decodedCodec returns a fixed decoded value, shaped as yaml.v2 decodes
+// mappings and scalars.
+type decodedCodec struct{ value interface{} }
+
+func (decodedCodec) Marshal(v interface{}) ([]byte, error) { return nil, errors.New("unused") }
+func (c decodedCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*interface{}) = c.value
+	return nil
+}
+
+func TestToYAML(t *testing.T) {
+	got, err := ToYAML([]byte(jsonTestXML), jsonCodec{}, JSONOptions{Attrs: JSONAttrsNested, SimpleText: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"_attrs":{"id":"7"},"empty":"","item":[{"#text":"Pen","_attrs":{"sku":"a"}},{"#text":"Ink","_attrs":{"sku":"b"}}],"note":"fragile","raw":{"#cdata":"\u003cb\u003e"}}`
+	if string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if _, err := ToYAML([]byte(jsonTestXML), nil, JSONOptions{}); err == nil {
+		t.Error("ToYAML without a codec: expected an error")
+	}
+}
+
+func TestFromYAML(t *testing.T) {
+	decoded := map[interface{}]interface{}{
+		"name":    "app",
+		"port":    8080,
+		"ratio":   1.50,
+		"debug":   false,
+		"nothing": nil,
+		"started": time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC),
+		"limit":   math.Inf(1),
+		"hosts":   []interface{}{"a", map[interface{}]interface{}{"@primary": true, "#text": "b"}},
+		1:         "one",
+	}
+	got, err := FromYAML(nil, "config", decodedCodec{decoded}, JSONOptions{EscapeNames: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<config><_1>one</_1><debug>false</debug><hosts>a</hosts><hosts primary="true">b</hosts>` +
+		`<limit>INF</limit><name>app</name><nothing/><port>8080</port><ratio>1.5</ratio><started>2024-03-15T09:30:00Z</started></config>`
+	if string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	for _, tt := range []struct {
+		codec YAMLCodec
+		want  string
+	}{
+		{nil, "requires a YAMLCodec"},
+		{decodedCodec{map[string]interface{}{"c": make(chan int)}}, "cannot convert YAML value of type chan int"},
+		{decodedCodec{[]interface{}{1}}, "an array cannot be the root element"},
+		{jsonCodec{}, "invalid YAML"},
+	} {
+		if _, err := FromYAML([]byte("{"), "config", tt.codec, JSONOptions{}); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("FromYAML = %v, want an error containing %q", err, tt.want)
+		}
+	}
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	opts := JSONOptions{SimpleText: true}
+	y, err := ToYAML([]byte(jsonTestXML), jsonCodec{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := FromYAML(y, "order", jsonCodec{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := Parse(jsonTestXML)
+	b, err := Parse(string(x))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(a, b) {
+		t.Errorf("round trip changed the document:\n%s\n%s", jsonTestXML, x)
+	}
+}
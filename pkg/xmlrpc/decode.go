@@ -0,0 +1,539 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shapestone/shape-xml/pkg/xml"
+)
+
+// DecodeCall reads a <methodCall> payload and returns the method name and
+// the parameters as interface{} values.
+//
+// Example:
+//
+//	method, params, err := xmlrpc.DecodeCall(body)
+//	if err != nil {
+//	    return err
+//	}
+//	var post Post
+//	if err := xmlrpc.Decode(params[1], &post); err != nil {
+//	    return err
+//	}
+func DecodeCall(data []byte) (method string, params []interface{}, err error) {
+	r := newReader(data)
+	if err := r.expect("methodCall"); err != nil {
+		return "", nil, err
+	}
+	if err := r.expect("methodName"); err != nil {
+		return "", nil, err
+	}
+	if method, err = r.text("methodName"); err != nil {
+		return "", nil, err
+	}
+	method = strings.TrimSpace(method)
+	if method == "" {
+		return "", nil, fmt.Errorf("xmlrpc: empty method name")
+	}
+	// <params> is optional for a call without parameters.
+	start, err := r.next()
+	if err != nil {
+		return "", nil, err
+	}
+	if start != nil {
+		if start.Name != "params" {
+			return "", nil, fmt.Errorf("xmlrpc: unexpected element <%s> in <methodCall>", start.Name)
+		}
+		if params, err = r.params(); err != nil {
+			return "", nil, err
+		}
+		if start, err = r.next(); err != nil {
+			return "", nil, err
+		}
+		if start != nil {
+			return "", nil, fmt.Errorf("xmlrpc: unexpected element <%s> in <methodCall>", start.Name)
+		}
+	}
+	return method, params, nil
+}
+
+// DecodeResponse reads a <methodResponse> payload and stores its result in
+// the value pointed to by result, as Decode does. A fault response is
+// returned as a *Fault error, and result is left unchanged. A nil result
+// discards the returned value.
+//
+// Example:
+//
+//	var postID int
+//	err := xmlrpc.DecodeResponse(body, &postID)
+//	var fault *xmlrpc.Fault
+//	if errors.As(err, &fault) {
+//	    log.Printf("server refused the post: %s", fault.Message)
+//	}
+func DecodeResponse(data []byte, result interface{}) error {
+	r := newReader(data)
+	if err := r.expect("methodResponse"); err != nil {
+		return err
+	}
+	start, err := r.next()
+	if err != nil {
+		return err
+	}
+	if start == nil {
+		return fmt.Errorf("xmlrpc: empty <methodResponse>")
+	}
+	switch start.Name {
+	case "fault":
+		return r.fault()
+	case "params":
+		params, err := r.params()
+		if err != nil {
+			return err
+		}
+		if len(params) > 1 {
+			return fmt.Errorf("xmlrpc: response has %d values, expected one", len(params))
+		}
+		if len(params) == 0 || result == nil {
+			return nil
+		}
+		return Decode(params[0], result)
+	default:
+		return fmt.Errorf("xmlrpc: unexpected element <%s> in <methodResponse>", start.Name)
+	}
+}
+
+// reader reads the elements of an XML-RPC payload from an xml.Decoder,
+// skipping comments, processing instructions and whitespace between
+// elements.
+type reader struct {
+	dec *xml.Decoder
+}
+
+func newReader(data []byte) *reader {
+	return &reader{dec: xml.NewDecoder(bytes.NewReader(data))}
+}
+
+// next returns the next start tag, or nil if the current element ends
+// first. Text other than whitespace is an error.
+func (r *reader) next() (*xml.StartElement, error) {
+	for {
+		tok, err := r.dec.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("xmlrpc: unexpected end of payload")
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			return &tok, nil
+		case xml.EndElement:
+			return nil, nil
+		case xml.CharData:
+			if len(bytes.TrimSpace(tok)) != 0 {
+				return nil, fmt.Errorf("xmlrpc: unexpected text %q", tok)
+			}
+		}
+	}
+}
+
+// expect reads the start tag of an element named name.
+func (r *reader) expect(name string) error {
+	start, err := r.next()
+	if err != nil {
+		return err
+	}
+	if start == nil {
+		return fmt.Errorf("xmlrpc: missing <%s>", name)
+	}
+	if start.Name != name {
+		return fmt.Errorf("xmlrpc: unexpected element <%s>, expected <%s>", start.Name, name)
+	}
+	return nil
+}
+
+// end reads the end of the element named name.
+func (r *reader) end(name string) error {
+	start, err := r.next()
+	if err != nil {
+		return err
+	}
+	if start != nil {
+		return fmt.Errorf("xmlrpc: unexpected element <%s> in <%s>", start.Name, name)
+	}
+	return nil
+}
+
+// text reads the text of the element named name up to its end tag.
+func (r *reader) text(name string) (string, error) {
+	var b strings.Builder
+	for {
+		tok, err := r.dec.Token()
+		if err != nil {
+			return "", err
+		}
+		switch tok := tok.(type) {
+		case xml.CharData:
+			b.Write(tok)
+		case xml.StartElement:
+			return "", fmt.Errorf("xmlrpc: unexpected element <%s> in <%s>", tok.Name, name)
+		case xml.EndElement:
+			return b.String(), nil
+		}
+	}
+}
+
+// params reads the <param> elements of <params>.
+func (r *reader) params() ([]interface{}, error) {
+	params := []interface{}{}
+	for {
+		start, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if start == nil {
+			return params, nil
+		}
+		if start.Name != "param" {
+			return nil, fmt.Errorf("xmlrpc: unexpected element <%s> in <params>", start.Name)
+		}
+		if err := r.expect("value"); err != nil {
+			return nil, err
+		}
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		if err := r.end("param"); err != nil {
+			return nil, err
+		}
+		params = append(params, v)
+	}
+}
+
+// fault reads the struct of <fault> and returns it as a *Fault.
+func (r *reader) fault() error {
+	if err := r.expect("value"); err != nil {
+		return err
+	}
+	v, err := r.value()
+	if err != nil {
+		return err
+	}
+	var f struct {
+		Code    int    `xmlrpc:"faultCode"`
+		Message string `xmlrpc:"faultString"`
+	}
+	if err := Decode(v, &f); err != nil {
+		return fmt.Errorf("xmlrpc: invalid fault: %w", err)
+	}
+	return &Fault{Code: f.Code, Message: f.Message}
+}
+
+// value reads the content of a <value> element, after its start tag, up to
+// and including its end tag.
+func (r *reader) value() (interface{}, error) {
+	var text strings.Builder
+	var start *xml.StartElement
+	for start == nil {
+		tok, err := r.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.CharData:
+			text.Write(tok)
+		case xml.StartElement:
+			start = &tok
+		case xml.EndElement:
+			// A value without a type element is a string.
+			return text.String(), nil
+		}
+	}
+	if strings.TrimSpace(text.String()) != "" {
+		return nil, fmt.Errorf("xmlrpc: unexpected text %q in <value>", text.String())
+	}
+
+	var v interface{}
+	var err error
+	switch start.Name {
+	case "array":
+		v, err = r.array()
+	case "struct":
+		v, err = r.structValue()
+	default:
+		var s string
+		if s, err = r.text(start.Name); err == nil {
+			v, err = scalar(start.Name, s)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, r.end("value")
+}
+
+// scalar converts the text of a scalar type element.
+func scalar(typ, s string) (interface{}, error) {
+	switch typ {
+	case "string":
+		return s, nil
+	case "int", "i4":
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("xmlrpc: invalid <%s> %q", typ, s)
+		}
+		return int(n), nil
+	case "boolean":
+		switch strings.TrimSpace(s) {
+		case "1":
+			return true, nil
+		case "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("xmlrpc: invalid <boolean> %q", s)
+	case "double":
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("xmlrpc: invalid <double> %q", s)
+		}
+		return f, nil
+	case "dateTime.iso8601":
+		return parseDateTime(strings.TrimSpace(s))
+	case "base64":
+		b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+		if err != nil {
+			return nil, fmt.Errorf("xmlrpc: invalid <base64>: %v", err)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("xmlrpc: unsupported value type <%s>", typ)
+}
+
+// array reads <array><data> and its values.
+func (r *reader) array() (interface{}, error) {
+	if err := r.expect("data"); err != nil {
+		return nil, err
+	}
+	values := []interface{}{}
+	for {
+		start, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if start == nil {
+			break
+		}
+		if start.Name != "value" {
+			return nil, fmt.Errorf("xmlrpc: unexpected element <%s> in <data>", start.Name)
+		}
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, r.end("array")
+}
+
+// structValue reads the members of <struct>.
+func (r *reader) structValue() (interface{}, error) {
+	members := map[string]interface{}{}
+	for {
+		start, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if start == nil {
+			return members, nil
+		}
+		if start.Name != "member" {
+			return nil, fmt.Errorf("xmlrpc: unexpected element <%s> in <struct>", start.Name)
+		}
+		if err := r.expect("name"); err != nil {
+			return nil, err
+		}
+		name, err := r.text("name")
+		if err != nil {
+			return nil, err
+		}
+		if err := r.expect("value"); err != nil {
+			return nil, err
+		}
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		if err := r.end("member"); err != nil {
+			return nil, err
+		}
+		members[name] = v
+	}
+}
+
+// Decode stores value, as returned by DecodeCall, in the value pointed to
+// by v. Arrays are stored in slices and arrays, structs in structs, whose
+// fields are matched as Encode* names them, and in maps with string keys.
+// Ints and doubles are stored in any numeric type that holds them, and
+// anything is stored as is in an interface{}.
+func Decode(value interface{}, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("xmlrpc: Decode requires a non-nil pointer, got %T", v)
+	}
+	return assign(rv.Elem(), value)
+}
+
+// assign stores the decoded value in dst.
+func assign(dst reflect.Value, value interface{}) error {
+	if dst.Kind() == reflect.Interface && dst.NumMethod() == 0 {
+		if value == nil {
+			dst.Set(reflect.Zero(dst.Type()))
+		} else {
+			dst.Set(reflect.ValueOf(value))
+		}
+		return nil
+	}
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return assign(dst.Elem(), value)
+	}
+	mismatch := func() error {
+		return fmt.Errorf("xmlrpc: cannot store %s in %s", typeName(value), dst.Type())
+	}
+
+	switch value := value.(type) {
+	case bool:
+		if dst.Kind() != reflect.Bool {
+			return mismatch()
+		}
+		dst.SetBool(value)
+	case string:
+		if dst.Kind() != reflect.String {
+			return mismatch()
+		}
+		dst.SetString(value)
+	case int:
+		return assignNumber(dst, float64(value), mismatch)
+	case float64:
+		return assignNumber(dst, value, mismatch)
+	case time.Time:
+		if dst.Type() != timeType {
+			return mismatch()
+		}
+		dst.Set(reflect.ValueOf(value))
+	case []byte:
+		if dst.Kind() != reflect.Slice || dst.Type().Elem().Kind() != reflect.Uint8 {
+			return mismatch()
+		}
+		dst.SetBytes(append([]byte(nil), value...))
+	case []interface{}:
+		switch dst.Kind() {
+		case reflect.Slice:
+			s := reflect.MakeSlice(dst.Type(), len(value), len(value))
+			for i, item := range value {
+				if err := assign(s.Index(i), item); err != nil {
+					return err
+				}
+			}
+			dst.Set(s)
+		case reflect.Array:
+			if len(value) != dst.Len() {
+				return fmt.Errorf("xmlrpc: cannot store an array of %d values in %s", len(value), dst.Type())
+			}
+			for i, item := range value {
+				if err := assign(dst.Index(i), item); err != nil {
+					return err
+				}
+			}
+		default:
+			return mismatch()
+		}
+	case map[string]interface{}:
+		switch dst.Kind() {
+		case reflect.Map:
+			if dst.Type().Key().Kind() != reflect.String {
+				return mismatch()
+			}
+			m := reflect.MakeMapWithSize(dst.Type(), len(value))
+			for name, item := range value {
+				elem := reflect.New(dst.Type().Elem()).Elem()
+				if err := assign(elem, item); err != nil {
+					return err
+				}
+				m.SetMapIndex(reflect.ValueOf(name).Convert(dst.Type().Key()), elem)
+			}
+			dst.Set(m)
+		case reflect.Struct:
+			for _, f := range structFields(dst.Type()) {
+				item, ok := value[f.name]
+				if !ok {
+					continue
+				}
+				if err := assign(dst.FieldByIndex(f.index), item); err != nil {
+					return fmt.Errorf("%w (member %q)", err, f.name)
+				}
+			}
+		default:
+			return mismatch()
+		}
+	default:
+		return mismatch()
+	}
+	return nil
+}
+
+// assignNumber stores an int or double in a numeric dst, if it holds it
+// exactly.
+func assignNumber(dst reflect.Value, f float64, mismatch func() error) error {
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := int64(f)
+		if float64(n) != f || dst.OverflowInt(n) {
+			return mismatch()
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if f < 0 || f > math.MaxUint32 || float64(uint64(f)) != f || dst.OverflowUint(uint64(f)) {
+			return mismatch()
+		}
+		dst.SetUint(uint64(f))
+	case reflect.Float32, reflect.Float64:
+		dst.SetFloat(f)
+	default:
+		return mismatch()
+	}
+	return nil
+}
+
+// typeName names the XML-RPC type of a decoded value in errors.
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case bool:
+		return "a boolean"
+	case string:
+		return "a string"
+	case int:
+		return "an int"
+	case float64:
+		return "a double"
+	case time.Time:
+		return "a dateTime.iso8601"
+	case []byte:
+		return "a base64 value"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "a struct"
+	}
+	return fmt.Sprintf("%T", value)
+}
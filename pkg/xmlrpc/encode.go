@@ -0,0 +1,250 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shapestone/shape-xml/pkg/xml"
+)
+
+// EncodeCall returns the <methodCall> payload that calls method with
+// params.
+//
+// Example:
+//
+//	payload, err := xmlrpc.EncodeCall("blog.newPost", blogID, post, true)
+//	resp, err := http.Post(endpoint, "text/xml", bytes.NewReader(payload))
+func EncodeCall(method string, params ...interface{}) ([]byte, error) {
+	return encode(func(w *writer) {
+		w.start("methodCall")
+		w.element("methodName", method)
+		w.params(params)
+		w.end()
+	})
+}
+
+// EncodeResponse returns the <methodResponse> payload that returns result.
+func EncodeResponse(result interface{}) ([]byte, error) {
+	return encode(func(w *writer) {
+		w.start("methodResponse")
+		w.params([]interface{}{result})
+		w.end()
+	})
+}
+
+// EncodeFault returns the <methodResponse> payload that reports f.
+func EncodeFault(f *Fault) ([]byte, error) {
+	return encode(func(w *writer) {
+		w.start("methodResponse")
+		w.start("fault")
+		w.value(reflect.ValueOf(map[string]interface{}{
+			"faultCode":   f.Code,
+			"faultString": f.Message,
+		}))
+		w.end()
+		w.end()
+	})
+}
+
+// writer writes XML-RPC values to an xml.Encoder, keeping the first error.
+type writer struct {
+	enc *xml.Encoder
+	err error
+}
+
+func encode(write func(w *writer)) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := xml.NewEncoder(&buf)
+	if err := enc.SetOptions(xml.MarshalOptions{Declaration: true}); err != nil {
+		return nil, err
+	}
+	w := &writer{enc: enc}
+	write(w)
+	if w.err == nil {
+		w.err = enc.Close()
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+	return buf.Bytes(), nil
+}
+
+func (w *writer) start(name string) {
+	if w.err == nil {
+		w.err = w.enc.WriteStartElement(name)
+	}
+}
+
+func (w *writer) end() {
+	if w.err == nil {
+		w.err = w.enc.WriteEnd()
+	}
+}
+
+func (w *writer) text(s string) {
+	if w.err == nil {
+		w.err = w.enc.WriteText(s)
+	}
+}
+
+// element writes <name>text</name>.
+func (w *writer) element(name, text string) {
+	w.start(name)
+	w.text(text)
+	w.end()
+}
+
+func (w *writer) params(params []interface{}) {
+	w.start("params")
+	for _, p := range params {
+		w.start("param")
+		w.value(reflect.ValueOf(p))
+		w.end()
+	}
+	w.end()
+}
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+// value writes <value> holding v.
+func (w *writer) value(v reflect.Value) {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			break
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || isNil(v) {
+		w.fail(fmt.Errorf("xmlrpc: cannot encode nil"))
+		return
+	}
+
+	w.start("value")
+	switch {
+	case v.Type() == timeType:
+		w.element("dateTime.iso8601", v.Interface().(time.Time).Format(dateTimeLayout))
+	case v.Type() == bytesType || (v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8):
+		w.element("base64", base64.StdEncoding.EncodeToString(v.Bytes()))
+	default:
+		switch v.Kind() {
+		case reflect.Bool:
+			text := "0"
+			if v.Bool() {
+				text = "1"
+			}
+			w.element("boolean", text)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n := v.Int(); n < math.MinInt32 || n > math.MaxInt32 {
+				w.fail(fmt.Errorf("xmlrpc: %d overflows the 32-bit int type", n))
+			} else {
+				w.element("int", strconv.FormatInt(n, 10))
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if n := v.Uint(); n > math.MaxInt32 {
+				w.fail(fmt.Errorf("xmlrpc: %d overflows the 32-bit int type", n))
+			} else {
+				w.element("int", strconv.FormatUint(n, 10))
+			}
+		case reflect.Float32, reflect.Float64:
+			f := v.Float()
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				w.fail(fmt.Errorf("xmlrpc: cannot encode %v as a double", f))
+			} else {
+				w.element("double", strconv.FormatFloat(f, 'f', -1, v.Type().Bits()))
+			}
+		case reflect.String:
+			w.element("string", v.String())
+		case reflect.Slice, reflect.Array:
+			w.start("array")
+			w.start("data")
+			for i := 0; i < v.Len(); i++ {
+				w.value(v.Index(i))
+			}
+			w.end()
+			w.end()
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				w.fail(fmt.Errorf("xmlrpc: cannot encode %s: map keys must be strings", v.Type()))
+				break
+			}
+			keys := v.MapKeys()
+			sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+			w.start("struct")
+			for _, key := range keys {
+				w.member(key.String(), v.MapIndex(key))
+			}
+			w.end()
+		case reflect.Struct:
+			w.start("struct")
+			for _, f := range structFields(v.Type()) {
+				field := v.FieldByIndex(f.index)
+				if f.omitEmpty && field.IsZero() || isNil(field) {
+					continue
+				}
+				w.member(f.name, field)
+			}
+			w.end()
+		default:
+			w.fail(fmt.Errorf("xmlrpc: cannot encode %s", v.Type()))
+		}
+	}
+	w.end()
+}
+
+// isNil reports whether v is a nil pointer or interface, which XML-RPC
+// has no value for.
+func isNil(v reflect.Value) bool {
+	return (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil()
+}
+
+func (w *writer) member(name string, v reflect.Value) {
+	w.start("member")
+	w.element("name", name)
+	w.value(v)
+	w.end()
+}
+
+func (w *writer) fail(err error) {
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// field is an exported struct field encoded as a struct member.
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// structFields returns the members of struct type t, from the fields'
+// names and xmlrpc tags.
+func structFields(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		tag := sf.Tag.Get("xmlrpc")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, field{name: name, index: sf.Index, omitEmpty: opts == "omitempty"})
+	}
+	return fields
+}
@@ -0,0 +1,34 @@
+package xmlrpc_test
+
+import (
+	"fmt"
+
+	"github.com/shapestone/shape-xml/pkg/xmlrpc"
+)
+
+func ExampleEncodeCall() {
+	payload, err := xmlrpc.EncodeCall("examples.getStateName", 41)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(payload))
+	// Output:
+	// <?xml version="1.0" encoding="UTF-8"?>
+	// <methodCall><methodName>examples.getStateName</methodName><params><param><value><int>41</int></value></param></params></methodCall>
+}
+
+func ExampleDecodeResponse() {
+	body := []byte(`<?xml version="1.0"?>
+<methodResponse>
+  <params>
+    <param><value><string>South Dakota</string></value></param>
+  </params>
+</methodResponse>`)
+
+	var state string
+	if err := xmlrpc.DecodeResponse(body, &state); err != nil {
+		panic(err)
+	}
+	fmt.Println(state)
+	// Output: South Dakota
+}
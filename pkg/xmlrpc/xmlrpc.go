@@ -0,0 +1,72 @@
+// Package xmlrpc encodes and decodes XML-RPC payloads: the <methodCall> a
+// client sends and the <methodResponse> a server returns.
+//
+// Go values map to XML-RPC value types as follows:
+//
+//	bool                      <boolean>
+//	int, int8 ... uint32      <int>, which holds 32 bits
+//	float32, float64          <double>
+//	string                    <string>
+//	[]byte                    <base64>
+//	time.Time                 <dateTime.iso8601>, e.g. 20240315T09:30:00
+//	slices and arrays         <array>
+//	structs, map[string]T     <struct>
+//
+// Struct fields are encoded as members named after the field, or after its
+// xmlrpc tag. Fields holding a nil pointer or interface are left out, as
+// XML-RPC has no nil value:
+//
+//	type Post struct {
+//	    Title string    `xmlrpc:"title"`
+//	    Tags  []string  `xmlrpc:"tags,omitempty"`
+//	    Draft bool      `xmlrpc:"-"`
+//	}
+//
+// DecodeCall returns parameters as interface{} values: bool, int, float64,
+// string, []byte, time.Time, []interface{} and map[string]interface{}.
+// Decode stores such a value in a typed Go value, and DecodeResponse does so
+// for the result of a call.
+//
+// The payloads are written with xml.Encoder and read with xml.Decoder, so
+// strings keep their whitespace and markup characters are escaped.
+package xmlrpc
+
+import (
+	"fmt"
+	"time"
+)
+
+// Fault is an XML-RPC fault response, returned as an error by
+// DecodeResponse.
+type Fault struct {
+	Code    int
+	Message string
+}
+
+func (f *Fault) Error() string {
+	return fmt.Sprintf("xmlrpc: fault %d: %s", f.Code, f.Message)
+}
+
+// dateTimeLayout is the dateTime.iso8601 format written by Encode*.
+// Decoding also accepts the extended form with dashes and a time zone.
+const dateTimeLayout = "20060102T15:04:05"
+
+var dateTimeLayouts = []string{
+	dateTimeLayout,
+	"2006-01-02T15:04:05",
+	"20060102T15:04:05Z07:00",
+	"2006-01-02T15:04:05Z07:00",
+	"20060102T150405",
+	"20060102T150405Z07:00",
+}
+
+// parseDateTime parses a dateTime.iso8601 value. Values without a time
+// zone are in UTC.
+func parseDateTime(s string) (time.Time, error) {
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("xmlrpc: invalid dateTime.iso8601 %q", s)
+}
@@ -0,0 +1,237 @@
+package xmlrpc
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type post struct {
+	Title   string            `xmlrpc:"title"`
+	Tags    []string          `xmlrpc:"tags,omitempty"`
+	Views   int               `xmlrpc:"views"`
+	Meta    map[string]string `xmlrpc:"meta,omitempty"`
+	Draft   bool              `xmlrpc:"-"`
+	private string
+}
+
+func TestEncodeCall(t *testing.T) {
+	when := time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)
+	got, err := EncodeCall("blog.newPost", 7, post{Title: "a < b", Views: 3, Draft: true}, true, 1.5, when, []byte("hi"), []interface{}{"x", 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<methodCall><methodName>blog.newPost</methodName><params>` +
+		`<param><value><int>7</int></value></param>` +
+		`<param><value><struct><member><name>title</name><value><string>a &lt; b</string></value></member>` +
+		`<member><name>views</name><value><int>3</int></value></member></struct></value></param>` +
+		`<param><value><boolean>1</boolean></value></param>` +
+		`<param><value><double>1.5</double></value></param>` +
+		`<param><value><dateTime.iso8601>20240315T09:30:00</dateTime.iso8601></value></param>` +
+		`<param><value><base64>aGk=</base64></value></param>` +
+		`<param><value><array><data><value><string>x</string></value><value><int>2</int></value></data></array></value></param>` +
+		`</params></methodCall>`
+	if string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestEncode_NilFields(t *testing.T) {
+	type entry struct {
+		Title  string      `xmlrpc:"title"`
+		Parent *post       `xmlrpc:"parent"`
+		Extra  interface{} `xmlrpc:"extra"`
+	}
+	got, err := EncodeResponse(entry{Title: "t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<methodResponse><params><param><value><struct>` +
+		`<member><name>title</name><value><string>t</string></value></member>` +
+		`</struct></value></param></params></methodResponse>`
+	if string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestEncode_Errors(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		param interface{}
+		want  string
+	}{
+		{"nil", nil, "cannot encode nil"},
+		{"nil pointer", (*post)(nil), "cannot encode nil"},
+		{"int64", int64(1) << 40, "overflows the 32-bit int type"},
+		{"map key", map[int]string{1: "a"}, "map keys must be strings"},
+		{"channel", make(chan int), "cannot encode chan int"},
+		{"nested", []interface{}{1, nil}, "cannot encode nil"},
+	} {
+		if _, err := EncodeCall("m", tt.param); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: EncodeCall = %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestDecodeCall(t *testing.T) {
+	input := `<?xml version="1.0"?>
+<!-- a call -->
+<methodCall>
+  <methodName> examples.getStateName </methodName>
+  <params>
+    <param><value><i4>41</i4></value></param>
+    <param><value>  untyped  </value></param>
+    <param><value><string>  kept  &amp; </string></value></param>
+    <param><value><boolean>0</boolean></value></param>
+    <param><value><double>-2.5</double></value></param>
+    <param><value><dateTime.iso8601>19980717T14:08:55</dateTime.iso8601></value></param>
+    <param><value><base64>
+      aGVs
+      bG8=
+    </base64></value></param>
+    <param><value><array><data/></array></value></param>
+    <param><value><struct>
+      <member><name>a</name><value><array><data><value><int>1</int></value></data></array></value></member>
+      <member><name>b</name><value><struct/></value></member>
+    </struct></value></param>
+    <param><value><string/></value></param>
+  </params>
+</methodCall>`
+	method, params, err := DecodeCall([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method != "examples.getStateName" {
+		t.Errorf("method = %q", method)
+	}
+	want := []interface{}{
+		41, "  untyped  ", "  kept  & ", false, -2.5,
+		time.Date(1998, 7, 17, 14, 8, 55, 0, time.UTC),
+		[]byte("hello"),
+		[]interface{}{},
+		map[string]interface{}{"a": []interface{}{1}, "b": map[string]interface{}{}},
+		"",
+	}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("params = %#v\nwant %#v", params, want)
+	}
+
+	method, params, err = DecodeCall([]byte(`<methodCall><methodName>system.listMethods</methodName></methodCall>`))
+	if err != nil || method != "system.listMethods" || len(params) != 0 {
+		t.Errorf("DecodeCall without params = %q, %v, %v", method, params, err)
+	}
+}
+
+func TestDecodeCall_Errors(t *testing.T) {
+	for _, tt := range []struct {
+		input string
+		want  string
+	}{
+		{`<methodResponse/>`, "unexpected element <methodResponse>, expected <methodCall>"},
+		{`<methodCall><methodName> </methodName></methodCall>`, "empty method name"},
+		{`<methodCall><methodName>m</methodName><params><param><value><int>x</int></value></param></params></methodCall>`, `invalid <int> "x"`},
+		{`<methodCall><methodName>m</methodName><params><param><value><int>2147483648</int></value></param></params></methodCall>`, "invalid <int>"},
+		{`<methodCall><methodName>m</methodName><params><param><value><boolean>true</boolean></value></param></params></methodCall>`, "invalid <boolean>"},
+		{`<methodCall><methodName>m</methodName><params><param><value><nil/></value></param></params></methodCall>`, "unsupported value type <nil>"},
+		{`<methodCall><methodName>m</methodName><params><param><value><struct><member><value/></member></struct></value></param></params></methodCall>`, "unexpected element <value>, expected <name>"},
+		{`<methodCall><methodName>m</methodName><params><param>text</param></params></methodCall>`, `unexpected text "text"`},
+		{`<methodCall><methodName>m</methodName><params><param><value>x<int>1</int></value></param></params></methodCall>`, "unexpected text"},
+		{`<methodCall><methodName>m</methodName><params>`, ""},
+	} {
+		if _, _, err := DecodeCall([]byte(tt.input)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("DecodeCall(%s) = %v, want an error containing %q", tt.input, err, tt.want)
+		}
+	}
+}
+
+func TestResponseRoundTrip(t *testing.T) {
+	in := post{Title: "T", Tags: []string{"a", "b"}, Views: 9, Meta: map[string]string{"k": "v"}}
+	payload, err := EncodeResponse(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out post
+	if err := DecodeResponse(payload, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+
+	var generic interface{}
+	if err := DecodeResponse(payload, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := generic.(map[string]interface{}); !ok || m["views"] != 9 {
+		t.Errorf("generic result = %#v", generic)
+	}
+	if err := DecodeResponse(payload, nil); err != nil {
+		t.Errorf("DecodeResponse with a nil result = %v", err)
+	}
+}
+
+func TestDecodeResponse_Fault(t *testing.T) {
+	payload, err := EncodeFault(&Fault{Code: 4, Message: "Too many parameters."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<methodResponse><fault><value><struct>` +
+		`<member><name>faultCode</name><value><int>4</int></value></member>` +
+		`<member><name>faultString</name><value><string>Too many parameters.</string></value></member>` +
+		`</struct></value></fault></methodResponse>`
+	if string(payload) != want {
+		t.Errorf("got\n%s\nwant\n%s", payload, want)
+	}
+
+	result := "unchanged"
+	err = DecodeResponse(payload, &result)
+	var fault *Fault
+	if !errors.As(err, &fault) || fault.Code != 4 || fault.Message != "Too many parameters." {
+		t.Fatalf("DecodeResponse = %v, want the fault", err)
+	}
+	if err.Error() != "xmlrpc: fault 4: Too many parameters." || result != "unchanged" {
+		t.Errorf("err = %q, result = %q", err, result)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	var n int8
+	if err := Decode(100, &n); err != nil || n != 100 {
+		t.Errorf("Decode(100, int8) = %v, %d", err, n)
+	}
+	var f float32
+	if err := Decode(3, &f); err != nil || f != 3 {
+		t.Errorf("Decode(3, float32) = %v, %v", err, f)
+	}
+	var p *string
+	if err := Decode("s", &p); err != nil || p == nil || *p != "s" {
+		t.Errorf("Decode into a pointer = %v", err)
+	}
+	var arr [2]int
+	if err := Decode([]interface{}{1, 2}, &arr); err != nil || arr != [2]int{1, 2} {
+		t.Errorf("Decode into an array = %v, %v", err, arr)
+	}
+
+	for _, tt := range []struct {
+		value interface{}
+		dst   interface{}
+		want  string
+	}{
+		{300, new(int8), "cannot store an int in int8"},
+		{-1, new(uint), "cannot store an int in uint"},
+		{2.5, new(int), "cannot store a double in int"},
+		{"s", new(int), "cannot store a string in int"},
+		{[]interface{}{1, 2, 3}, new([2]int), "array of 3 values"},
+		{map[string]interface{}{"views": "many"}, new(post), `cannot store a string in int (member "views")`},
+		{1, post{}, "requires a non-nil pointer"},
+	} {
+		if err := Decode(tt.value, tt.dst); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Decode(%v, %T) = %v, want an error containing %q", tt.value, tt.dst, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package xsdtypes
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/shapestone/shape-xml/internal/lexical"
+)
+
+// durationPattern matches the xsd:duration lexical space. The additional
+// rules that at least one component is present and that "T" is followed by
+// a time component are checked separately.
+var durationPattern = regexp.MustCompile(`^-?P(\d+Y)?(\d+M)?(\d+D)?(T(\d+H)?(\d+M)?(\d+(\.\d+)?S)?)?$`)
+
+// Duration is an xsd:duration value such as "PT1M30S" or "P1Y2M".
+type Duration string
+
+// NewDuration returns the xsd:duration for d using day-time components only,
+// e.g. 90*time.Second becomes "PT1M30S".
+func NewDuration(d time.Duration) Duration {
+	return Duration(lexical.FormatDuration(d))
+}
+
+// ParseDuration parses and validates an xsd:duration. Year and month
+// components are accepted, although they cannot be converted with Duration.
+func ParseDuration(s string) (Duration, error) {
+	d := Duration(s)
+	if err := d.Validate(); err != nil {
+		return "", err
+	}
+	return d, nil
+}
+
+// Validate reports whether d is a valid xsd:duration.
+func (d Duration) Validate() error {
+	s := string(d)
+	n := len(s)
+	if !durationPattern.MatchString(s) || s[n-1] == 'P' || s[n-1] == 'T' {
+		return fmt.Errorf("xsdtypes: invalid xsd:duration %q", s)
+	}
+	return nil
+}
+
+// Duration converts d to a time.Duration, treating a day as 24 hours. It
+// returns an error if d has year or month components, whose length in time
+// is not fixed.
+func (d Duration) Duration() (time.Duration, error) {
+	if err := d.Validate(); err != nil {
+		return 0, err
+	}
+	v, err := lexical.ParseDuration(string(d))
+	if err != nil {
+		return 0, fmt.Errorf("xsdtypes: %w", err)
+	}
+	return v, nil
+}
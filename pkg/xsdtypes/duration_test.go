@@ -0,0 +1,36 @@
+package xsdtypes
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDuration_Validate(t *testing.T) {
+	for _, s := range []string{"P1Y", "P1Y2M3DT4H5M6.7S", "PT0S", "-P1D", "PT1M30S", "P0D"} {
+		if _, err := ParseDuration(s); err != nil {
+			t.Errorf("ParseDuration(%q) error = %v", s, err)
+		}
+	}
+	for _, s := range []string{"", "P", "PT", "-P", "P1DT", "1m30s", "P1S", "PT1.5M", "P1W"} {
+		if _, err := ParseDuration(s); err == nil {
+			t.Errorf("ParseDuration(%q) expected error", s)
+		}
+	}
+}
+
+func TestDuration_Conversion(t *testing.T) {
+	d := NewDuration(26*time.Hour + 90*time.Second)
+	if d != "PT26H1M30S" {
+		t.Errorf("NewDuration = %q", d)
+	}
+	got, err := Duration("P1DT2H1M30S").Duration()
+	if err != nil {
+		t.Fatalf("Duration failed: %v", err)
+	}
+	if got != 26*time.Hour+90*time.Second {
+		t.Errorf("got %v", got)
+	}
+	if _, err := Duration("P1M").Duration(); err == nil {
+		t.Error("expected error converting a month duration")
+	}
+}
@@ -0,0 +1,34 @@
+package xsdtypes_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shapestone/shape-xml/pkg/xml"
+	"github.com/shapestone/shape-xml/pkg/xsdtypes"
+)
+
+func Example() {
+	type Invoice struct {
+		Issued xsdtypes.Date     `xml:"issued,attr"`
+		Due    xsdtypes.DateTime `xml:"due"`
+		Period xsdtypes.Duration `xml:"period"`
+	}
+
+	issued := time.Date(2024, 3, 15, 9, 0, 0, 0, time.UTC)
+	inv := Invoice{
+		Issued: xsdtypes.NewDate(issued),
+		Due:    xsdtypes.NewDateTime(issued.AddDate(0, 0, 30)),
+		Period: xsdtypes.NewDuration(30 * 24 * time.Hour),
+	}
+	out, _ := xml.Marshal(inv)
+	fmt.Println(string(out))
+
+	var back Invoice
+	_ = xml.Unmarshal(out, &back)
+	due, _ := back.Due.Time()
+	fmt.Println(due.Format(time.RFC1123))
+	// Output:
+	// <Invoice issued="2024-03-15"><due>2024-04-14T09:00:00Z</due><period>PT720H</period></Invoice>
+	// Sun, 14 Apr 2024 09:00:00 UTC
+}
@@ -0,0 +1,164 @@
+// Package xsdtypes provides XML Schema date, time and duration types with the
+// lexical forms required by schema validators.
+//
+// Each type is a string holding the value in its XSD lexical form, so it can be
+// used directly as a struct field type in element or attribute position and
+// round-trips unchanged through xml.Marshal and xml.Unmarshal:
+//
+//	type Invoice struct {
+//	    Issued  xsdtypes.Date     `xml:"issued,attr"`
+//	    Due     xsdtypes.Date     `xml:"due"`
+//	    Period  xsdtypes.Duration `xml:"period"`
+//	}
+//
+//	inv := Invoice{
+//	    Issued: xsdtypes.NewDate(time.Now()),
+//	    Period: xsdtypes.NewDuration(30 * 24 * time.Hour),
+//	}
+//
+// The New* constructors always produce valid lexical forms. Values decoded from
+// documents are not checked until they are converted back with Time or
+// Duration, or explicitly with Validate.
+package xsdtypes
+
+import (
+	"fmt"
+	"time"
+)
+
+// Layouts for the XSD lexical forms. Timezones are optional in XSD; the
+// layouts are tried with and without one when parsing.
+const (
+	dateLayout       = "2006-01-02"
+	timeLayout       = "15:04:05.999999999"
+	dateTimeLayout   = "2006-01-02T15:04:05.999999999"
+	gYearMonthLayout = "2006-01"
+	tzSuffix         = "Z07:00"
+)
+
+// parseWithOptionalTZ parses s with layout, first with a timezone suffix and
+// then without one (in which case the result is in UTC).
+func parseWithOptionalTZ(kind, layout, s string) (time.Time, error) {
+	if t, err := time.Parse(layout+tzSuffix, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("xsdtypes: invalid %s %q", kind, s)
+	}
+	return t, nil
+}
+
+// Date is an xsd:date value such as "2024-03-15".
+type Date string
+
+// NewDate returns the xsd:date for the calendar date of t in t's location.
+// No timezone is written.
+func NewDate(t time.Time) Date {
+	return Date(t.Format(dateLayout))
+}
+
+// ParseDate parses and validates an xsd:date, with or without a timezone.
+func ParseDate(s string) (Date, error) {
+	if _, err := parseWithOptionalTZ("xsd:date", dateLayout, s); err != nil {
+		return "", err
+	}
+	return Date(s), nil
+}
+
+// Time returns the date as midnight at the start of the day, in the date's
+// timezone or UTC if it has none.
+func (d Date) Time() (time.Time, error) {
+	return parseWithOptionalTZ("xsd:date", dateLayout, string(d))
+}
+
+// Validate reports whether d is a valid xsd:date.
+func (d Date) Validate() error {
+	_, err := d.Time()
+	return err
+}
+
+// Time is an xsd:time value such as "13:20:00Z" or "13:20:00.5+01:00".
+type Time string
+
+// NewTime returns the xsd:time for the clock time of t, including fractional
+// seconds when present and t's timezone offset ("Z" for UTC).
+func NewTime(t time.Time) Time {
+	return Time(t.Format(timeLayout + tzSuffix))
+}
+
+// ParseTime parses and validates an xsd:time, with or without a timezone.
+func ParseTime(s string) (Time, error) {
+	if _, err := parseWithOptionalTZ("xsd:time", timeLayout, s); err != nil {
+		return "", err
+	}
+	return Time(s), nil
+}
+
+// Time returns the clock time on January 1, year 0, in the value's timezone
+// or UTC if it has none.
+func (t Time) Time() (time.Time, error) {
+	return parseWithOptionalTZ("xsd:time", timeLayout, string(t))
+}
+
+// Validate reports whether t is a valid xsd:time.
+func (t Time) Validate() error {
+	_, err := t.Time()
+	return err
+}
+
+// DateTime is an xsd:dateTime value such as "2024-03-15T13:20:00Z".
+type DateTime string
+
+// NewDateTime returns the xsd:dateTime for t, including fractional seconds
+// when present and t's timezone offset ("Z" for UTC).
+func NewDateTime(t time.Time) DateTime {
+	return DateTime(t.Format(dateTimeLayout + tzSuffix))
+}
+
+// ParseDateTime parses and validates an xsd:dateTime, with or without a timezone.
+func ParseDateTime(s string) (DateTime, error) {
+	if _, err := parseWithOptionalTZ("xsd:dateTime", dateTimeLayout, s); err != nil {
+		return "", err
+	}
+	return DateTime(s), nil
+}
+
+// Time returns the instant, in the value's timezone or UTC if it has none.
+func (dt DateTime) Time() (time.Time, error) {
+	return parseWithOptionalTZ("xsd:dateTime", dateTimeLayout, string(dt))
+}
+
+// Validate reports whether dt is a valid xsd:dateTime.
+func (dt DateTime) Validate() error {
+	_, err := dt.Time()
+	return err
+}
+
+// GYearMonth is an xsd:gYearMonth value such as "2024-03".
+type GYearMonth string
+
+// NewGYearMonth returns the xsd:gYearMonth for the year and month of t.
+func NewGYearMonth(t time.Time) GYearMonth {
+	return GYearMonth(t.Format(gYearMonthLayout))
+}
+
+// ParseGYearMonth parses and validates an xsd:gYearMonth, with or without a timezone.
+func ParseGYearMonth(s string) (GYearMonth, error) {
+	if _, err := parseWithOptionalTZ("xsd:gYearMonth", gYearMonthLayout, s); err != nil {
+		return "", err
+	}
+	return GYearMonth(s), nil
+}
+
+// Time returns midnight on the first day of the month, in the value's
+// timezone or UTC if it has none.
+func (g GYearMonth) Time() (time.Time, error) {
+	return parseWithOptionalTZ("xsd:gYearMonth", gYearMonthLayout, string(g))
+}
+
+// Validate reports whether g is a valid xsd:gYearMonth.
+func (g GYearMonth) Validate() error {
+	_, err := g.Time()
+	return err
+}
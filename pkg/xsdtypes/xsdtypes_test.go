@@ -0,0 +1,82 @@
+package xsdtypes
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewFormats(t *testing.T) {
+	ts := time.Date(2024, 3, 15, 13, 20, 5, 500000000, time.UTC)
+	plus1 := time.Date(2024, 3, 15, 13, 20, 0, 0, time.FixedZone("", 3600))
+
+	tests := []struct {
+		got, want string
+	}{
+		{string(NewDate(ts)), "2024-03-15"},
+		{string(NewTime(ts)), "13:20:05.5Z"},
+		{string(NewTime(plus1)), "13:20:00+01:00"},
+		{string(NewDateTime(ts)), "2024-03-15T13:20:05.5Z"},
+		{string(NewDateTime(plus1)), "2024-03-15T13:20:00+01:00"},
+		{string(NewGYearMonth(ts)), "2024-03"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	valid := []func() error{
+		func() error { _, err := ParseDate("2024-03-15"); return err },
+		func() error { _, err := ParseDate("2024-03-15Z"); return err },
+		func() error { _, err := ParseDate("2024-03-15-05:00"); return err },
+		func() error { _, err := ParseTime("13:20:00"); return err },
+		func() error { _, err := ParseTime("13:20:00.123+01:00"); return err },
+		func() error { _, err := ParseDateTime("2024-03-15T13:20:00"); return err },
+		func() error { _, err := ParseDateTime("2024-03-15T13:20:00.5Z"); return err },
+		func() error { _, err := ParseGYearMonth("2024-03"); return err },
+		func() error { _, err := ParseGYearMonth("2024-03Z"); return err },
+	}
+	for i, f := range valid {
+		if err := f(); err != nil {
+			t.Errorf("valid case %d: %v", i, err)
+		}
+	}
+
+	invalid := []func() error{
+		func() error { _, err := ParseDate("2024-3-15"); return err },
+		func() error { _, err := ParseDate("2024-02-30"); return err },
+		func() error { _, err := ParseTime("25:00:00"); return err },
+		func() error { _, err := ParseDateTime("2024-03-15 13:20:00"); return err },
+		func() error { _, err := ParseGYearMonth("2024-13"); return err },
+	}
+	for i, f := range invalid {
+		if err := f(); err == nil {
+			t.Errorf("invalid case %d: expected error", i)
+		}
+	}
+}
+
+func TestTimeConversion(t *testing.T) {
+	want := time.Date(2024, 3, 15, 13, 20, 5, 0, time.UTC)
+	got, err := NewDateTime(want).Time()
+	if err != nil {
+		t.Fatalf("Time failed: %v", err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	d, err := Date("2024-03-15").Time()
+	if err != nil {
+		t.Fatalf("Time failed: %v", err)
+	}
+	if d.Year() != 2024 || d.Month() != time.March || d.Day() != 15 || d.Location() != time.UTC {
+		t.Errorf("unexpected date %v", d)
+	}
+
+	if err := Date("not a date").Validate(); err == nil {
+		t.Error("expected Validate to fail")
+	}
+}